@@ -0,0 +1,210 @@
+// caldavはWebDAV・CalDAV(RFC 4918・RFC 4791・RFC 6578)のXMLのリクエストとレスポンスを扱うパッケージ
+// どのリソースがあるか・プロパティの値は何かは呼び出し側(コントローラー)が決めて、
+// ここではPROPFIND・REPORTのリクエストの読み込みと、multistatusのレスポンスの書き出しだけを行います。
+package caldav
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// XMLの名前空間
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+)
+
+// prefixesはレスポンスのルート要素で宣言する名前空間の接頭辞
+// PropertyのXMLの値の中では、この接頭辞(d:・c:・cs:)を使って要素を書きます。
+var prefixes = []struct {
+	Prefix    string
+	Namespace string
+}{
+	{"d", NamespaceDAV},
+	{"c", NamespaceCalDAV},
+	{"cs", NamespaceCalendarServer},
+}
+
+// Requestはリクエストのボディ(propfind・calendar-query・calendar-multiget・sync-collection)
+type Request struct {
+	XMLName  xml.Name
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     *struct {
+		Names []struct {
+			XMLName xml.Name
+		} `xml:",any"`
+	} `xml:"DAV: prop"`
+	// Hrefsはcalendar-multigetで取得するリソース
+	Hrefs []string `xml:"DAV: href"`
+	// SyncTokenはsync-collectionの前回の同期トークン(初回は空)
+	SyncToken string `xml:"DAV: sync-token"`
+	Filter    *struct {
+		CompFilter CompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// CompFilterはcalendar-queryのコンポーネントの条件
+type CompFilter struct {
+	Name        string       `xml:"name,attr"`
+	CompFilters []CompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// ParseRequestはリクエストのボディを読み込む
+// ボディが空のPROPFINDは、allpropと同じ扱いにします(RFC 4918 9.1)。
+func ParseRequest(r io.Reader) (Request, error) {
+	req := Request{}
+	if err := xml.NewDecoder(r).Decode(&req); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Request{XMLName: xml.Name{Space: NamespaceDAV, Local: "propfind"}, AllProp: &struct{}{}}, nil
+		}
+		return Request{}, err
+	}
+	return req, nil
+}
+
+// PropNamesはリクエストで指定されたプロパティの名前(allpropの場合はnil)
+func (r Request) PropNames() []xml.Name {
+	if r.Prop == nil || r.AllProp != nil {
+		return nil
+	}
+	names := []xml.Name{}
+	for _, n := range r.Prop.Names {
+		names = append(names, n.XMLName)
+	}
+	return names
+}
+
+// Componentsはcalendar-queryのfilterで、VCALENDARの中に指定されたコンポーネントの名前(条件が無い場合はnil)
+func (r Request) Components() []string {
+	if r.Filter == nil {
+		return nil
+	}
+	names := []string{}
+	for _, f := range r.Filter.CompFilter.CompFilters {
+		names = append(names, strings.ToUpper(f.Name))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
+
+// Propertyはレスポンスのプロパティ
+// 値はTextのテキストか、XMLの要素(接頭辞d:・c:・cs:を使ったXMLの文字列)のどちらかです。
+type Property struct {
+	Name xml.Name
+	Text string
+	XML  string
+}
+
+// TextPropertyはテキストの値のプロパティを作る
+func TextProperty(namespace string, local string, text string) Property {
+	return Property{Name: xml.Name{Space: namespace, Local: local}, Text: text}
+}
+
+// XMLPropertyはXMLの要素を値に持つプロパティを作る
+func XMLProperty(namespace string, local string, value string) Property {
+	return Property{Name: xml.Name{Space: namespace, Local: local}, XML: value}
+}
+
+// Hrefはd:hrefの要素を作る
+func Href(href string) string {
+	return "<d:href>" + escape(href) + "</d:href>"
+}
+
+// Responseはmultistatusの中の1つのリソースの結果
+// Statusが0以外の場合はプロパティを返さずに、リソース全体の状態(404など)だけを返します。
+type Response struct {
+	Href     string
+	Status   int
+	Props    []Property
+	NotFound []xml.Name
+}
+
+// Multistatusは207 Multi-Statusのレスポンスのボディ
+// SyncTokenはsync-collectionのレスポンスにだけ付けます。
+type Multistatus struct {
+	Responses []Response
+	SyncToken string
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// nameTagは要素の開始タグと終了タグの名前を返す(宣言していない名前空間の場合はその場で宣言します)
+func nameTag(name xml.Name) (string, string) {
+	for _, p := range prefixes {
+		if p.Namespace == name.Space {
+			return p.Prefix + ":" + name.Local, p.Prefix + ":" + name.Local
+		}
+	}
+	return fmt.Sprintf(`x:%s xmlns:x="%s"`, name.Local, escape(name.Space)), "x:" + name.Local
+}
+
+func statusLine(status int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", status, http.StatusText(status))
+}
+
+// Stringはmultistatusのレスポンスを、XMLの文字列にする
+func (m Multistatus) String() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n<d:multistatus")
+	for _, p := range prefixes {
+		fmt.Fprintf(&b, ` xmlns:%s="%s"`, p.Prefix, p.Namespace)
+	}
+	b.WriteString(">")
+	for _, r := range m.Responses {
+		b.WriteString("<d:response>" + Href(r.Href))
+		if r.Status != 0 {
+			b.WriteString("<d:status>" + statusLine(r.Status) + "</d:status>")
+		} else {
+			if len(r.Props) > 0 || len(r.NotFound) == 0 {
+				b.WriteString("<d:propstat><d:prop>")
+				for _, p := range r.Props {
+					start, end := nameTag(p.Name)
+					if p.Text == "" && p.XML == "" {
+						b.WriteString("<" + start + "/>")
+						continue
+					}
+					b.WriteString("<" + start + ">" + escape(p.Text) + p.XML + "</" + end + ">")
+				}
+				b.WriteString("</d:prop><d:status>" + statusLine(http.StatusOK) + "</d:status></d:propstat>")
+			}
+			if len(r.NotFound) > 0 {
+				b.WriteString("<d:propstat><d:prop>")
+				for _, n := range r.NotFound {
+					start, _ := nameTag(n)
+					b.WriteString("<" + start + "/>")
+				}
+				b.WriteString("</d:prop><d:status>" + statusLine(http.StatusNotFound) + "</d:status></d:propstat>")
+			}
+		}
+		b.WriteString("</d:response>")
+	}
+	if m.SyncToken != "" {
+		b.WriteString("<d:sync-token>" + escape(m.SyncToken) + "</d:sync-token>")
+	}
+	b.WriteString("</d:multistatus>\n")
+	return b.String()
+}
+
+// Errorは条件を満たさないリクエストのエラーのボディ(例: valid-sync-token)
+func Error(namespace string, condition string) string {
+	start, _ := nameTag(xml.Name{Space: namespace, Local: condition})
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n<d:error")
+	for _, p := range prefixes {
+		fmt.Fprintf(&b, ` xmlns:%s="%s"`, p.Prefix, p.Namespace)
+	}
+	b.WriteString("><" + start + "/></d:error>\n")
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"errors"
+	"go-rest-api/usecase"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IAttachmentController interface {
+	GetAttachments(c echo.Context) error
+	UploadAttachment(c echo.Context) error
+	DownloadAttachment(c echo.Context) error
+	DownloadThumbnail(c echo.Context) error
+	DeleteAttachment(c echo.Context) error
+}
+
+type attachmentController struct {
+	au usecase.IAttachmentUsecase
+	// maxBytesはアップロードできるファイルの最大サイズ
+	// multipartの解析で一時ファイルに書き出す前にリクエストボディーのサイズを制限するために使います。
+	maxBytes int64
+}
+
+func NewAttachmentController(au usecase.IAttachmentUsecase, maxBytes int64) IAttachmentController {
+	return &attachmentController{au, maxBytes}
+}
+
+// attachmentErrorはユースケースのエラーをステータスコードに変換してレスポンスを返す
+func attachmentError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrAttachmentTooLarge):
+		return c.JSON(http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, usecase.ErrAttachmentTypeNotAllowed):
+		return c.JSON(http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, usecase.ErrAttachmentNotFound):
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusInternalServerError, err.Error())
+}
+
+func (ac *attachmentController) GetAttachments(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	attachmentsRes, err := ac.au.GetAttachments(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return attachmentError(c, err)
+	}
+	return c.JSON(http.StatusOK, attachmentsRes)
+}
+
+func (ac *attachmentController) UploadAttachment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	// multipartのヘッダーなどの分として1MBの余裕を持たせてリクエストボディーのサイズを制限します。
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, ac.maxBytes+1<<20)
+	// multipart/form-dataのfileという名前のフィールドからファイルを取り出す
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return c.JSON(http.StatusRequestEntityTooLarge, usecase.ErrAttachmentTooLarge.Error())
+		}
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	defer file.Close()
+
+	attachmentRes, err := ac.au.UploadAttachment(c.Request().Context(), uint(userId.(float64)), uint(taskId), fileHeader.Filename, file, fileHeader.Size)
+	if err != nil {
+		return attachmentError(c, err)
+	}
+	return c.JSON(http.StatusCreated, attachmentRes)
+}
+
+func (ac *attachmentController) DownloadAttachment(c echo.Context) error {
+	return ac.download(c, false)
+}
+
+func (ac *attachmentController) DownloadThumbnail(c echo.Context) error {
+	return ac.download(c, true)
+}
+
+// downloadは添付ファイルの中身を返す
+// http.ServeContentを使うので、RangeヘッダーやIf-None-Matchヘッダーにも対応しています。
+func (ac *attachmentController) download(c echo.Context, thumbnail bool) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	attachmentId, _ := strconv.Atoi(c.Param("attachmentId"))
+
+	attachment, blob, err := ac.au.OpenAttachment(c.Request().Context(), uint(userId.(float64)), uint(taskId), uint(attachmentId), thumbnail)
+	if err != nil {
+		return attachmentError(c, err)
+	}
+	defer blob.Close()
+
+	header := c.Response().Header()
+	etag := attachment.SHA256
+	contentType := attachment.ContentType
+	disposition := "attachment"
+	if thumbnail {
+		etag += "-thumb"
+		contentType = "image/png"
+		disposition = "inline"
+	}
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set(echo.HeaderContentDisposition, disposition+"; filename*=UTF-8''"+url.PathEscape(attachment.FileName))
+	header.Set("ETag", `"`+etag+`"`)
+	// ブラウザがMIMEタイプを推測してHTMLとして表示しないようにする
+	header.Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Response(), c.Request(), attachment.FileName, attachment.CreatedAt, blob)
+	return nil
+}
+
+func (ac *attachmentController) DeleteAttachment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	attachmentId, _ := strconv.Atoi(c.Param("attachmentId"))
+
+	if err := ac.au.DeleteAttachment(c.Request().Context(), uint(userId.(float64)), uint(taskId), uint(attachmentId)); err != nil {
+		return attachmentError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IBoardController interface {
+	GetBoards(c echo.Context) error
+	GetBoardById(c echo.Context) error
+	CreateBoard(c echo.Context) error
+	UpdateBoard(c echo.Context) error
+	DeleteBoard(c echo.Context) error
+	CreateColumn(c echo.Context) error
+	UpdateColumn(c echo.Context) error
+	DeleteColumn(c echo.Context) error
+	MoveColumn(c echo.Context) error
+	MoveTask(c echo.Context) error
+	RemoveTask(c echo.Context) error
+}
+
+type boardController struct {
+	bu usecase.IBoardUsecase
+}
+
+func NewBoardController(bu usecase.IBoardUsecase) IBoardController {
+	return &boardController{bu}
+}
+
+func (bc *boardController) GetBoards(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	boardsRes, err := bc.bu.GetBoards(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, boardsRes)
+}
+
+func (bc *boardController) GetBoardById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+
+	boardRes, err := bc.bu.GetBoardById(c.Request().Context(), uint(userId.(float64)), uint(boardId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, boardRes)
+}
+
+func (bc *boardController) CreateBoard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	board := model.Board{}
+	if err := c.Bind(&board); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	board.UserId = uint(userId.(float64))
+	boardRes, err := bc.bu.CreateBoard(c.Request().Context(), board)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, boardRes)
+}
+
+func (bc *boardController) UpdateBoard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+
+	board := model.Board{}
+	if err := c.Bind(&board); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	boardRes, err := bc.bu.UpdateBoard(c.Request().Context(), board, uint(userId.(float64)), uint(boardId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, boardRes)
+}
+
+func (bc *boardController) DeleteBoard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+
+	err := bc.bu.DeleteBoard(c.Request().Context(), uint(userId.(float64)), uint(boardId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (bc *boardController) CreateColumn(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+
+	column := model.BoardColumn{}
+	if err := c.Bind(&column); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	columnRes, err := bc.bu.CreateColumn(c.Request().Context(), column, uint(userId.(float64)), uint(boardId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, columnRes)
+}
+
+func (bc *boardController) UpdateColumn(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+	columnId, _ := strconv.Atoi(c.Param("columnId"))
+
+	column := model.BoardColumn{}
+	if err := c.Bind(&column); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	columnRes, err := bc.bu.UpdateColumn(c.Request().Context(), column, uint(userId.(float64)), uint(boardId), uint(columnId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, columnRes)
+}
+
+func (bc *boardController) DeleteColumn(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+	columnId, _ := strconv.Atoi(c.Param("columnId"))
+
+	err := bc.bu.DeleteColumn(c.Request().Context(), uint(userId.(float64)), uint(boardId), uint(columnId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (bc *boardController) MoveColumn(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+	columnId, _ := strconv.Atoi(c.Param("columnId"))
+
+	req := model.BoardColumnMoveRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	columnRes, err := bc.bu.MoveColumn(c.Request().Context(), req, uint(userId.(float64)), uint(boardId), uint(columnId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, columnRes)
+}
+
+func (bc *boardController) MoveTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	// リクエストボディーのcolumn_idで移動先の列、beforeかafterで列の中の位置を指定します。
+	req := model.BoardTaskMoveRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	taskRes, err := bc.bu.MoveTask(c.Request().Context(), req, uint(userId.(float64)), uint(boardId), uint(taskId))
+	if err != nil {
+		// WIPの上限に達している場合は、移動せずに409を返します。
+		if errors.Is(err, usecase.ErrWipLimitExceeded) {
+			return c.JSON(http.StatusConflict, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+func (bc *boardController) RemoveTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	boardId, _ := strconv.Atoi(c.Param("boardId"))
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	err := bc.bu.RemoveTask(c.Request().Context(), uint(userId.(float64)), uint(boardId), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
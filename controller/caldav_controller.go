@@ -0,0 +1,468 @@
+package controller
+
+import (
+	"encoding/xml"
+	"errors"
+	"go-rest-api/caldav"
+	"go-rest-api/ical"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/usecase"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CalDAVのURLの構成(組織のタスクは/caldav/orgs/:orgIdの下に同じ構成で置きます)
+//
+//	/caldav/                             ルート(current-user-principalを返す)
+//	/caldav/principal/                   ユーザー(calendar-home-setを返す)
+//	/caldav/calendars/                   カレンダーの一覧
+//	/caldav/calendars/:calendar/         カレンダー(tasksまたはproject-<ID>)
+//	/caldav/calendars/:calendar/:object  VTODO(task-<ID>.ics、またはクライアントが決めた名前)
+type ICalDAVController interface {
+	// WellKnownは/.well-known/caldavから/caldav/にリダイレクトする
+	WellKnown(c echo.Context) error
+	Options(c echo.Context) error
+	PropfindRoot(c echo.Context) error
+	PropfindPrincipal(c echo.Context) error
+	PropfindHome(c echo.Context) error
+	PropfindCalendar(c echo.Context) error
+	// Reportはcalendar-query・calendar-multiget・sync-collectionのREPORTに答える
+	Report(c echo.Context) error
+	PropfindObject(c echo.Context) error
+	GetObject(c echo.Context) error
+	PutObject(c echo.Context) error
+	DeleteObject(c echo.Context) error
+}
+
+type calDAVController struct {
+	cu usecase.ICalDAVUsecase
+}
+
+func NewCalDAVController(cu usecase.ICalDAVUsecase) ICalDAVController {
+	return &calDAVController{cu}
+}
+
+// calDAVMaxBytesはPUTで受け取るVTODOの最大サイズ
+const calDAVMaxBytes = 1 << 20
+
+// syncTokenPrefixは同期トークン(タスクの変更履歴のID)をURIの形にするための接頭辞
+const syncTokenPrefix = "urn:go-rest-api:sync:"
+
+// calendarContentTypeはVTODOのリソースのContent-Type
+const calendarContentType = "text/calendar; charset=utf-8; component=VTODO"
+
+// calDAVBaseはリクエストのCalDAVのURLのルート(組織を指定した場合は/caldav/orgs/:orgId)
+func calDAVBase(c echo.Context) string {
+	if orgId := c.Param("orgId"); orgId != "" {
+		return "/caldav/orgs/" + orgId
+	}
+	return "/caldav"
+}
+
+func calDAVUserId(c echo.Context) uint {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	return uint(userId.(float64))
+}
+
+// depthはDepthヘッダーの値(infinityは1として扱います)
+func depth(c echo.Context) int {
+	if c.Request().Header.Get("Depth") == "0" {
+		return 0
+	}
+	return 1
+}
+
+func writeMultistatus(c echo.Context, ms caldav.Multistatus) error {
+	return c.Blob(http.StatusMultiStatus, echo.MIMEApplicationXMLCharsetUTF8, []byte(ms.String()))
+}
+
+// calDAVErrorはユースケースのエラーをCalDAVのステータスコードにする
+func calDAVError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, permission.ErrForbidden):
+		return c.String(http.StatusForbidden, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, repository.ErrObjectNotExist):
+		return c.String(http.StatusNotFound, "object does not exist")
+	case errors.Is(err, usecase.ErrPreconditionFailed):
+		return c.String(http.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, usecase.ErrResourceConflict):
+		return c.String(http.StatusConflict, err.Error())
+	case errors.Is(err, usecase.ErrInvalidSyncToken):
+		return c.Blob(http.StatusForbidden, echo.MIMEApplicationXMLCharsetUTF8, []byte(caldav.Error(caldav.NamespaceDAV, "valid-sync-token")))
+	}
+	var validationErrors validation.Errors
+	if errors.As(err, &validationErrors) || errors.Is(err, usecase.ErrRRuleRequiresFutureScope) {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return c.String(http.StatusInternalServerError, err.Error())
+}
+
+// propResponseはリクエストされたプロパティを、availableの中から選んでレスポンスにする
+// allpropの場合は、calendar-data以外の全てのプロパティを返します。
+func propResponse(href string, available []caldav.Property, req caldav.Request) caldav.Response {
+	res := caldav.Response{Href: href, Props: []caldav.Property{}}
+	names := req.PropNames()
+	if names == nil {
+		for _, p := range available {
+			if p.Name.Local != "calendar-data" {
+				res.Props = append(res.Props, p)
+			}
+		}
+		return res
+	}
+	for _, name := range names {
+		found := false
+		for _, p := range available {
+			if p.Name == name {
+				res.Props = append(res.Props, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			res.NotFound = append(res.NotFound, name)
+		}
+	}
+	return res
+}
+
+func principalProps(base string) []caldav.Property {
+	return []caldav.Property{
+		caldav.XMLProperty(caldav.NamespaceDAV, "current-user-principal", caldav.Href(base+"/principal/")),
+		caldav.XMLProperty(caldav.NamespaceDAV, "principal-URL", caldav.Href(base+"/principal/")),
+		caldav.XMLProperty(caldav.NamespaceCalDAV, "calendar-home-set", caldav.Href(base+"/calendars/")),
+	}
+}
+
+func calendarProps(base string, calendar model.CalDAVCalendar) []caldav.Property {
+	token := syncTokenPrefix + strconv.FormatUint(uint64(calendar.SyncToken), 10)
+	return []caldav.Property{
+		caldav.XMLProperty(caldav.NamespaceDAV, "resourcetype", "<d:collection/><c:calendar/>"),
+		caldav.TextProperty(caldav.NamespaceDAV, "displayname", calendar.DisplayName),
+		caldav.XMLProperty(caldav.NamespaceCalDAV, "supported-calendar-component-set", `<c:comp name="VTODO"/>`),
+		caldav.XMLProperty(caldav.NamespaceDAV, "supported-report-set",
+			"<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>"+
+				"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>"+
+				"<d:supported-report><d:report><d:sync-collection/></d:report></d:supported-report>"),
+		// 権限はタスクの操作の時にユースケースで確認するので、ここでは読み書きできるものとして返します。
+		caldav.XMLProperty(caldav.NamespaceDAV, "current-user-privilege-set",
+			"<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>"+
+				"<d:privilege><d:write-content/></d:privilege><d:privilege><d:bind/></d:privilege><d:privilege><d:unbind/></d:privilege>"),
+		caldav.XMLProperty(caldav.NamespaceDAV, "current-user-principal", caldav.Href(base+"/principal/")),
+		caldav.TextProperty(caldav.NamespaceDAV, "sync-token", token),
+		caldav.TextProperty(caldav.NamespaceCalendarServer, "getctag", token),
+	}
+}
+
+func objectProps(object model.CalDAVObject) []caldav.Property {
+	return []caldav.Property{
+		caldav.XMLProperty(caldav.NamespaceDAV, "resourcetype", ""),
+		caldav.TextProperty(caldav.NamespaceDAV, "getetag", object.ETag),
+		caldav.TextProperty(caldav.NamespaceDAV, "getcontenttype", calendarContentType),
+		caldav.TextProperty(caldav.NamespaceDAV, "getlastmodified", object.Task.UpdatedAt.UTC().Format(http.TimeFormat)),
+		caldav.TextProperty(caldav.NamespaceCalDAV, "calendar-data", objectICS(object)),
+	}
+}
+
+func calendarHref(base string, name string) string {
+	return base + "/calendars/" + url.PathEscape(name) + "/"
+}
+
+func objectHref(base string, calendar string, name string) string {
+	return calendarHref(base, calendar) + url.PathEscape(name)
+}
+
+// objectICSはVTODOを1つ含むVCALENDARの文字列を作る(UIDはクライアントが作成した時のUIDを返します)
+func objectICS(object model.CalDAVObject) string {
+	var b strings.Builder
+	w := ical.NewWriter(&b)
+	w.Begin("VCALENDAR")
+	w.Property(ical.Property{Name: "VERSION", Value: "2.0"})
+	w.Property(ical.Property{Name: "PRODID", Value: "-//go-rest-api//tasks//EN"})
+	todo := taskToVTodo(object.Task)
+	todo.Get("UID").Value = object.Uid
+	w.WriteComponent(todo)
+	w.End("VCALENDAR")
+	w.Flush()
+	return b.String()
+}
+
+func (cc *calDAVController) WellKnown(c echo.Context) error {
+	return c.Redirect(http.StatusMovedPermanently, "/caldav/")
+}
+
+func (cc *calDAVController) Options(c echo.Context) error {
+	header := c.Response().Header()
+	header.Set("DAV", "1, 3, calendar-access")
+	header.Set(echo.HeaderAllow, "OPTIONS, GET, PUT, DELETE, PROPFIND, REPORT")
+	return c.NoContent(http.StatusOK)
+}
+
+func (cc *calDAVController) PropfindRoot(c echo.Context) error {
+	req, err := caldav.ParseRequest(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	base := calDAVBase(c)
+	props := append(principalProps(base), caldav.XMLProperty(caldav.NamespaceDAV, "resourcetype", "<d:collection/>"))
+	return writeMultistatus(c, caldav.Multistatus{Responses: []caldav.Response{propResponse(base+"/", props, req)}})
+}
+
+func (cc *calDAVController) PropfindPrincipal(c echo.Context) error {
+	req, err := caldav.ParseRequest(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	base := calDAVBase(c)
+	props := append(principalProps(base), caldav.XMLProperty(caldav.NamespaceDAV, "resourcetype", "<d:principal/>"))
+	return writeMultistatus(c, caldav.Multistatus{Responses: []caldav.Response{propResponse(base+"/principal/", props, req)}})
+}
+
+func (cc *calDAVController) PropfindHome(c echo.Context) error {
+	req, err := caldav.ParseRequest(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	base := calDAVBase(c)
+	props := append(principalProps(base), caldav.XMLProperty(caldav.NamespaceDAV, "resourcetype", "<d:collection/>"))
+	ms := caldav.Multistatus{Responses: []caldav.Response{propResponse(base+"/calendars/", props, req)}}
+	if depth(c) > 0 {
+		calendars, err := cc.cu.GetCalendars(c.Request().Context(), calDAVUserId(c))
+		if err != nil {
+			return calDAVError(c, err)
+		}
+		for _, calendar := range calendars {
+			ms.Responses = append(ms.Responses, propResponse(calendarHref(base, calendar.Name), calendarProps(base, calendar), req))
+		}
+	}
+	return writeMultistatus(c, ms)
+}
+
+func (cc *calDAVController) PropfindCalendar(c echo.Context) error {
+	req, err := caldav.ParseRequest(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	ctx := c.Request().Context()
+	userId := calDAVUserId(c)
+	base := calDAVBase(c)
+	calendar, err := cc.cu.GetCalendar(ctx, userId, c.Param("calendar"))
+	if err != nil {
+		return calDAVError(c, err)
+	}
+	ms := caldav.Multistatus{Responses: []caldav.Response{propResponse(calendarHref(base, calendar.Name), calendarProps(base, calendar), req)}}
+	if depth(c) > 0 {
+		objects, err := cc.cu.GetObjects(ctx, userId, calendar.Name, nil)
+		if err != nil {
+			return calDAVError(c, err)
+		}
+		for _, object := range objects {
+			ms.Responses = append(ms.Responses, propResponse(objectHref(base, calendar.Name, object.Name), objectProps(object), req))
+		}
+	}
+	return writeMultistatus(c, ms)
+}
+
+func (cc *calDAVController) Report(c echo.Context) error {
+	req, err := caldav.ParseRequest(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	ctx := c.Request().Context()
+	userId := calDAVUserId(c)
+	base := calDAVBase(c)
+	calendar := c.Param("calendar")
+	ms := caldav.Multistatus{Responses: []caldav.Response{}}
+	switch req.XMLName {
+	case xml.Name{Space: caldav.NamespaceCalDAV, Local: "calendar-query"}:
+		// VTODO以外(VEVENTなど)のコンポーネントの検索には、空の結果を返します。
+		components := req.Components()
+		if components != nil && !containsString(components, "VTODO") {
+			return writeMultistatus(c, ms)
+		}
+		objects, err := cc.cu.GetObjects(ctx, userId, calendar, nil)
+		if err != nil {
+			return calDAVError(c, err)
+		}
+		for _, object := range objects {
+			ms.Responses = append(ms.Responses, propResponse(objectHref(base, calendar, object.Name), objectProps(object), req))
+		}
+	case xml.Name{Space: caldav.NamespaceCalDAV, Local: "calendar-multiget"}:
+		names := []string{}
+		for _, href := range req.Hrefs {
+			name, err := url.PathUnescape(path.Base(strings.TrimSpace(href)))
+			if err != nil {
+				return c.String(http.StatusBadRequest, err.Error())
+			}
+			names = append(names, name)
+		}
+		objects, err := cc.cu.GetObjects(ctx, userId, calendar, names)
+		if err != nil {
+			return calDAVError(c, err)
+		}
+		found := map[string]model.CalDAVObject{}
+		for _, object := range objects {
+			found[object.Name] = object
+		}
+		for _, name := range names {
+			href := objectHref(base, calendar, name)
+			if object, ok := found[name]; ok {
+				ms.Responses = append(ms.Responses, propResponse(href, objectProps(object), req))
+			} else {
+				ms.Responses = append(ms.Responses, caldav.Response{Href: href, Status: http.StatusNotFound})
+			}
+		}
+	case xml.Name{Space: caldav.NamespaceDAV, Local: "sync-collection"}:
+		since := uint64(0)
+		if req.SyncToken != "" {
+			if since, err = strconv.ParseUint(strings.TrimPrefix(req.SyncToken, syncTokenPrefix), 10, 64); err != nil ||
+				!strings.HasPrefix(req.SyncToken, syncTokenPrefix) {
+				return calDAVError(c, usecase.ErrInvalidSyncToken)
+			}
+		}
+		changes, err := cc.cu.GetChanges(ctx, userId, calendar, uint(since))
+		if err != nil {
+			return calDAVError(c, err)
+		}
+		for _, object := range changes.Changed {
+			ms.Responses = append(ms.Responses, propResponse(objectHref(base, calendar, object.Name), objectProps(object), req))
+		}
+		for _, name := range changes.Deleted {
+			ms.Responses = append(ms.Responses, caldav.Response{Href: objectHref(base, calendar, name), Status: http.StatusNotFound})
+		}
+		ms.SyncToken = syncTokenPrefix + strconv.FormatUint(uint64(changes.SyncToken), 10)
+	default:
+		return c.Blob(http.StatusForbidden, echo.MIMEApplicationXMLCharsetUTF8, []byte(caldav.Error(caldav.NamespaceDAV, "supported-report")))
+	}
+	return writeMultistatus(c, ms)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// getObjectはパスのVTODOを取得する(見つからない場合はnil)
+func (cc *calDAVController) getObject(c echo.Context) (*model.CalDAVObject, error) {
+	objects, err := cc.cu.GetObjects(c.Request().Context(), calDAVUserId(c), c.Param("calendar"), []string{c.Param("object")})
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+	return &objects[0], nil
+}
+
+func (cc *calDAVController) PropfindObject(c echo.Context) error {
+	req, err := caldav.ParseRequest(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	object, err := cc.getObject(c)
+	if err != nil {
+		return calDAVError(c, err)
+	}
+	if object == nil {
+		return c.String(http.StatusNotFound, "object does not exist")
+	}
+	href := objectHref(calDAVBase(c), c.Param("calendar"), object.Name)
+	return writeMultistatus(c, caldav.Multistatus{Responses: []caldav.Response{propResponse(href, objectProps(*object), req)}})
+}
+
+func (cc *calDAVController) GetObject(c echo.Context) error {
+	object, err := cc.getObject(c)
+	if err != nil {
+		return calDAVError(c, err)
+	}
+	if object == nil {
+		return c.String(http.StatusNotFound, "object does not exist")
+	}
+	if match := c.Request().Header.Get("If-None-Match"); match != "" && match == object.ETag {
+		return c.NoContent(http.StatusNotModified)
+	}
+	c.Response().Header().Set("ETag", object.ETag)
+	c.Response().Header().Set("Last-Modified", object.Task.UpdatedAt.UTC().Format(http.TimeFormat))
+	return c.Blob(http.StatusOK, calendarContentType, []byte(objectICS(*object)))
+}
+
+// vTodoToTaskはPUTで受け取ったVCALENDARのVTODOをタスクにする
+// タスクに無い項目(PRIORITY・CATEGORIESなど)は読み込みません。
+func vTodoToTask(calendar *ical.Component) (model.Task, string, error) {
+	if calendar.Name != "VCALENDAR" {
+		return model.Task{}, "", errors.New("body must be a VCALENDAR")
+	}
+	for _, todo := range calendar.Components {
+		if todo.Name != "VTODO" {
+			continue
+		}
+		task := model.Task{
+			Title:       todo.Text("SUMMARY"),
+			Description: todo.Text("DESCRIPTION"),
+			Completed:   strings.EqualFold(todo.Text("STATUS"), "COMPLETED") || todo.Get("COMPLETED") != nil,
+		}
+		if due := todo.Get("DUE"); due != nil {
+			t, err := ical.ParseDateTime(*due)
+			if err != nil {
+				return model.Task{}, "", err
+			}
+			task.DueDate = &t
+			task.Timezone = due.Params["TZID"]
+		}
+		if rrule := todo.Get("RRULE"); rrule != nil {
+			task.RRule = rrule.Value
+		}
+		return task, todo.Text("UID"), nil
+	}
+	return model.Task{}, "", errors.New("body must contain a VTODO")
+}
+
+func (cc *calDAVController) PutObject(c echo.Context) error {
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, calDAVMaxBytes)
+	calendar, err := ical.Parse(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	task, uid, err := vTodoToTask(calendar)
+	if err != nil {
+		return c.Blob(http.StatusForbidden, echo.MIMEApplicationXMLCharsetUTF8, []byte(caldav.Error(caldav.NamespaceCalDAV, "supported-calendar-component")))
+	}
+	name := c.Param("object")
+	if uid == "" {
+		uid = strings.TrimSuffix(name, ".ics")
+	}
+	object, created, err := cc.cu.PutObject(c.Request().Context(), calDAVUserId(c), c.Param("calendar"), name, uid, task,
+		c.Request().Header.Get("If-Match"), c.Request().Header.Get("If-None-Match"))
+	if err != nil {
+		return calDAVError(c, err)
+	}
+	c.Response().Header().Set("ETag", object.ETag)
+	if created {
+		return c.NoContent(http.StatusCreated)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (cc *calDAVController) DeleteObject(c echo.Context) error {
+	err := cc.cu.DeleteObject(c.Request().Context(), calDAVUserId(c), c.Param("calendar"), c.Param("object"), c.Request().Header.Get("If-Match"))
+	if err != nil {
+		return calDAVError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"errors"
+	"go-rest-api/ical"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type ICalendarFeedController interface {
+	GetFeed(c echo.Context) error
+	RegenerateFeed(c echo.Context) error
+	DeleteFeed(c echo.Context) error
+	// AuthenticateはURLのフィードのトークンでユーザーを認証するミドルウェア
+	// カレンダーのアプリはクッキーもAuthorizationヘッダーも送れないので、URLのトークンだけで認証します。
+	Authenticate(next echo.HandlerFunc) echo.HandlerFunc
+	// GetFeedCalendarは期限日のあるタスクをiCalendarのフィードで返す
+	GetFeedCalendar(c echo.Context) error
+}
+
+type calendarFeedController struct {
+	cfu usecase.ICalendarFeedUsecase
+}
+
+func NewCalendarFeedController(cfu usecase.ICalendarFeedUsecase) ICalendarFeedController {
+	return &calendarFeedController{cfu}
+}
+
+// feedEventDurationはフィードの予定の長さ(期限日の時刻から始まる短い予定にします)
+const feedEventDuration = 30 * time.Minute
+
+func (fc *calendarFeedController) GetFeed(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	feedRes, err := fc.cfu.GetFeed(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, "feed does not exist")
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, feedRes)
+}
+
+func (fc *calendarFeedController) RegenerateFeed(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	feedRes, err := fc.cfu.RegenerateFeed(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, feedRes)
+}
+
+func (fc *calendarFeedController) DeleteFeed(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	if err := fc.cfu.DeleteFeed(c.Request().Context(), uint(userId.(float64))); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (fc *calendarFeedController) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// パスは/feeds/<トークン>.icsの形です(echoのパラメーターは拡張子を含むので、ここで取り除きます)。
+		token, ok := strings.CutSuffix(c.Param("token"), ".ics")
+		if !ok || token == "" {
+			return c.JSON(http.StatusNotFound, "feed does not exist")
+		}
+		userId, err := fc.cfu.Authenticate(c.Request().Context(), token)
+		if err != nil {
+			// トークンが違う場合は、フィードの存在を知られないように見つからない場合と同じにします。
+			return c.JSON(http.StatusNotFound, "feed does not exist")
+		}
+		c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"user_id": float64(userId)}, Valid: true})
+		return next(c)
+	}
+}
+
+func (fc *calendarFeedController) GetFeedCalendar(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	// project_idとtagで絞り込み、completed=trueで完了したタスクも含めます。
+	filter := model.CalendarFeedFilter{Tag: c.QueryParam("tag"), IncludeCompleted: c.QueryParam("completed") == "true"}
+	if id := c.QueryParam("project_id"); id != "" {
+		projectId, err := strconv.Atoi(id)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		pid := uint(projectId)
+		filter.ProjectId = &pid
+	}
+
+	// エクスポートと同じように、レスポンスのヘッダーは最初のタスクを読み込んだ時に書き出します。
+	res := c.Response()
+	w := ical.NewWriter(res)
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		res.Header().Set(echo.HeaderContentType, "text/calendar; charset=utf-8")
+		res.Header().Set("Cache-Control", "private, max-age=300")
+		res.WriteHeader(http.StatusOK)
+		w.Begin("VCALENDAR")
+		w.Property(ical.Property{Name: "VERSION", Value: "2.0"})
+		w.Property(ical.Property{Name: "PRODID", Value: "-//go-rest-api//tasks//EN"})
+		w.Property(ical.Property{Name: "X-WR-CALNAME", Value: "Tasks"})
+		// カレンダーのアプリに1時間ごとに読み込み直すように伝えます。
+		w.Property(ical.Property{Name: "REFRESH-INTERVAL", Params: map[string]string{"VALUE": "DURATION"}, Value: "PT1H"})
+		return w.Property(ical.Property{Name: "X-PUBLISHED-TTL", Value: "PT1H"})
+	}
+	err := fc.cfu.GetFeedTasks(c.Request().Context(), uint(userId.(float64)), filter, func(tasks []model.TaskResponse) error {
+		if err := start(); err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			if err := w.WriteComponent(taskToFeedEvent(t)); err != nil {
+				return err
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	})
+	if err != nil {
+		if started {
+			return err
+		}
+		if errRes, ok := queryError(err); ok {
+			return c.JSON(http.StatusBadRequest, errRes)
+		}
+		if errors.Is(err, permission.ErrForbidden) {
+			return c.JSON(http.StatusForbidden, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if err := start(); err != nil {
+		return err
+	}
+	if err := w.End("VCALENDAR"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// taskToFeedEventはタスクの期限日をiCalendarのVEVENTにする
+// 購読したカレンダーでVTODOを表示しないアプリが多いので、フィードでは予定として出力します。
+func taskToFeedEvent(t model.TaskResponse) ical.Component {
+	// 完了したタスク(completed=trueの場合だけ含めます)は、タイトルの先頭に印を付けます。
+	summary := t.Title
+	if t.Completed {
+		summary = "✓ " + summary
+	}
+	return ical.Component{Name: "VEVENT", Properties: []ical.Property{
+		{Name: "UID", Value: model.TaskUID(t.ID)},
+		{Name: "DTSTAMP", Value: ical.FormatDateTime(t.UpdatedAt)},
+		{Name: "LAST-MODIFIED", Value: ical.FormatDateTime(t.UpdatedAt)},
+		{Name: "DTSTART", Value: ical.FormatDateTime(*t.DueDate)},
+		{Name: "DTEND", Value: ical.FormatDateTime(t.DueDate.Add(feedEventDuration))},
+		{Name: "SUMMARY", Value: ical.EscapeText(summary)},
+		{Name: "TRANSP", Value: "TRANSPARENT"},
+	}}
+}
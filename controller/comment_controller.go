@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ICommentController interface {
+	GetComments(c echo.Context) error
+	CreateComment(c echo.Context) error
+	UpdateComment(c echo.Context) error
+	DeleteComment(c echo.Context) error
+	GetCommentRevisions(c echo.Context) error
+}
+
+type commentController struct {
+	cu usecase.ICommentUsecase
+}
+
+func NewCommentController(cu usecase.ICommentUsecase) ICommentController {
+	return &commentController{cu}
+}
+
+func (cc *commentController) GetComments(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	commentsRes, err := cc.cu.GetComments(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, commentsRes)
+}
+
+func (cc *commentController) CreateComment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	comment := model.Comment{}
+	if err := c.Bind(&comment); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	commentRes, err := cc.cu.CreateComment(c.Request().Context(), comment, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, commentRes)
+}
+
+func (cc *commentController) UpdateComment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	commentId, _ := strconv.Atoi(c.Param("commentId"))
+
+	comment := model.Comment{}
+	if err := c.Bind(&comment); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	commentRes, err := cc.cu.UpdateComment(c.Request().Context(), comment, uint(userId.(float64)), uint(taskId), uint(commentId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, commentRes)
+}
+
+func (cc *commentController) DeleteComment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	commentId, _ := strconv.Atoi(c.Param("commentId"))
+
+	err := cc.cu.DeleteComment(c.Request().Context(), uint(userId.(float64)), uint(taskId), uint(commentId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (cc *commentController) GetCommentRevisions(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	commentId, _ := strconv.Atoi(c.Param("commentId"))
+
+	revisionsRes, err := cc.cu.GetCommentRevisions(c.Request().Context(), uint(userId.(float64)), uint(taskId), uint(commentId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, revisionsRes)
+}
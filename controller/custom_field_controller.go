@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ICustomFieldController interface {
+	GetFields(c echo.Context) error
+	CreateField(c echo.Context) error
+	UpdateField(c echo.Context) error
+	DeleteField(c echo.Context) error
+}
+
+type customFieldController struct {
+	cfu usecase.ICustomFieldUsecase
+}
+
+func NewCustomFieldController(cfu usecase.ICustomFieldUsecase) ICustomFieldController {
+	return &customFieldController{cfu}
+}
+
+func (cfc *customFieldController) GetFields(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	fieldsRes, err := cfc.cfu.GetFields(c.Request().Context(), uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, fieldsRes)
+}
+
+func (cfc *customFieldController) CreateField(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	field := model.CustomField{}
+	if err := c.Bind(&field); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	fieldRes, err := cfc.cfu.CreateField(c.Request().Context(), field, uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, fieldRes)
+}
+
+func (cfc *customFieldController) UpdateField(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+	fieldId, _ := strconv.Atoi(c.Param("fieldId"))
+
+	field := model.CustomField{}
+	if err := c.Bind(&field); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	fieldRes, err := cfc.cfu.UpdateField(c.Request().Context(), field, uint(userId.(float64)), uint(projectId), uint(fieldId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, fieldRes)
+}
+
+func (cfc *customFieldController) DeleteField(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+	fieldId, _ := strconv.Atoi(c.Param("fieldId"))
+
+	err := cfc.cfu.DeleteField(c.Request().Context(), uint(userId.(float64)), uint(projectId), uint(fieldId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
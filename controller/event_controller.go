@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-rest-api/usecase"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+type IEventController interface {
+	// StreamはタスクのイベントをServer-Sent Events(text/event-stream)で送り続ける
+	// イベントの種類(task.created・task.updated・task.deleted)をevent、TaskChangeEventのJSONをdataにします。
+	Stream(c echo.Context) error
+	// WebSocketはStreamと同じイベントのJSONを、WebSocketのテキストメッセージで送り続ける
+	// イベントが無い間は{"event":"ping"}を送ります。
+	WebSocket(c echo.Context) error
+}
+
+type eventController struct {
+	eu usecase.IEventUsecase
+	// allowedOriginsはWebSocketの接続を受け付けるフロントエンドのOrigin(CORSのAllowOriginsと同じ)
+	allowedOrigins []string
+}
+
+// eventKeepAliveはイベントが無い間も、プロキシに接続を切られないように空のメッセージを送る間隔
+const eventKeepAlive = 25 * time.Second
+
+func NewEventController(eu usecase.IEventUsecase, allowedOrigins []string) IEventController {
+	return &eventController{eu, allowedOrigins}
+}
+
+func (ec *eventController) Stream(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	ctx := c.Request().Context()
+	events := ec.eu.Subscribe(ctx, uint(userId.(float64)))
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	// nginxなどのプロキシにバッファリングさせないようにします。
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	// 接続が切れた場合に、ブラウザ(EventSource)が3秒後につなぎ直すようにします。
+	fmt.Fprint(res, "retry: 3000\n\n")
+	res.Flush()
+
+	ticker := time.NewTicker(eventKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// ":"で始まる行はコメントで、EventSourceは読み飛ばします。
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case event, ok := <-events:
+			if !ok {
+				// イベントを取りこぼした可能性があるので、接続を切ってつなぎ直してもらいます。
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Event, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+func (ec *eventController) WebSocket(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	server := websocket.Server{
+		// ブラウザはWebSocketの接続にCORSを適用せずクッキーを送るので、
+		// 他のサイトから接続されないように、Originがフロントエンドのものかを確認します。
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			origin := req.Header.Get(echo.HeaderOrigin)
+			for _, o := range ec.allowedOrigins {
+				if o != "" && origin == o {
+					return nil
+				}
+			}
+			return fmt.Errorf("origin %q is not allowed", origin)
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			ctx, cancel := context.WithCancel(c.Request().Context())
+			defer cancel()
+			// クライアントからのメッセージは使いませんが、接続が閉じたことを知るために読み続けます。
+			go func() {
+				defer cancel()
+				var msg string
+				for websocket.Message.Receive(ws, &msg) == nil {
+				}
+			}()
+			events := ec.eu.Subscribe(ctx, uint(userId.(float64)))
+			ticker := time.NewTicker(eventKeepAlive)
+			defer ticker.Stop()
+			for {
+				var msg interface{}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					msg = map[string]string{"event": "ping"}
+				case event, ok := <-events:
+					if !ok {
+						return
+					}
+					msg = event
+				}
+				ws.SetWriteDeadline(time.Now().Add(eventKeepAlive))
+				if err := websocket.JSON.Send(ws, msg); err != nil {
+					return
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-rest-api/graphql"
+	"go-rest-api/usecase"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+type IGraphQLController interface {
+	// QueryはPOST /graphqlで受け取ったクエリかミューテーションを実行する
+	// GraphQLのエラーはレスポンスのerrorsで返すので、リクエストを読めない場合以外はStatusOKです。
+	Query(c echo.Context) error
+	// WebSocketはgraphql-transport-wsのプロトコルで、サブスクリプション(とクエリ・ミューテーション)を実行する
+	WebSocket(c echo.Context) error
+}
+
+type graphqlController struct {
+	uu usecase.IUserUsecase
+	tu usecase.ITaskUsecase
+	pu usecase.IProjectUsecase
+	cu usecase.ICommentUsecase
+	eu usecase.IEventUsecase
+	// allowedOriginsはWebSocketの接続を受け付けるフロントエンドのOrigin(CORSのAllowOriginsと同じ)
+	allowedOrigins []string
+	schema         *graphql.Schema
+}
+
+func NewGraphQLController(uu usecase.IUserUsecase, tu usecase.ITaskUsecase, pu usecase.IProjectUsecase, cu usecase.ICommentUsecase,
+	eu usecase.IEventUsecase, allowedOrigins []string) IGraphQLController {
+	gc := &graphqlController{uu: uu, tu: tu, pu: pu, cu: cu, eu: eu, allowedOrigins: allowedOrigins}
+	gc.schema = gc.newGraphQLSchema()
+	return gc
+}
+
+func (gc *graphqlController) Query(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	req := graphql.Request{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if req.Query == "" {
+		return c.JSON(http.StatusBadRequest, "query is required")
+	}
+	ctx := gc.newGraphQLContext(c.Request().Context(), uint(userId.(float64)))
+	return c.JSON(http.StatusOK, gc.schema.Execute(ctx, req))
+}
+
+// graphql-transport-wsのメッセージ(https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md)
+const (
+	gqlConnectionInit = "connection_init"
+	gqlConnectionAck  = "connection_ack"
+	gqlPing           = "ping"
+	gqlPong           = "pong"
+	gqlSubscribe      = "subscribe"
+	gqlNext           = "next"
+	gqlError          = "error"
+	gqlComplete       = "complete"
+)
+
+// graphqlConnectionInitTimeoutはconnection_initを待つ時間(送られてこない場合は接続を切ります)
+const graphqlConnectionInitTimeout = 10 * time.Second
+
+type graphqlWSMessage struct {
+	Id      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// graphqlWSConnは1つのWebSocketの接続で、実行中の操作をIDごとに管理する
+type graphqlWSConn struct {
+	ws *websocket.Conn
+	// writeMuは複数の操作の結果を同じ接続に書き込む時に、メッセージが混ざらないようにする
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	// operationsは実行中の操作のIDと、操作を止めるための関数
+	operations map[string]context.CancelFunc
+}
+
+func (conn *graphqlWSConn) send(msg graphqlWSMessage) error {
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	conn.ws.SetWriteDeadline(time.Now().Add(eventKeepAlive))
+	return websocket.JSON.Send(conn.ws, msg)
+}
+
+func (conn *graphqlWSConn) sendPayload(id string, msgType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return conn.send(graphqlWSMessage{Id: id, Type: msgType, Payload: data})
+}
+
+// closeWithはgraphql-transport-wsで決められた理由で接続を閉じる
+// x/net/websocketは閉じる時のステータスコードを送れないので、理由をエラーのメッセージとして送ってから閉じます。
+func (conn *graphqlWSConn) closeWith(code int, reason string) {
+	conn.sendPayload("", gqlError, []*graphql.Error{{Message: fmt.Sprintf("%d: %s", code, reason)}})
+	conn.ws.Close()
+}
+
+func (gc *graphqlController) WebSocket(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	server := websocket.Server{
+		// /events/wsと同じく、他のサイトから接続されないようにOriginを確認して、サブプロトコルを決めます。
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			origin := req.Header.Get(echo.HeaderOrigin)
+			allowed := false
+			for _, o := range gc.allowedOrigins {
+				if o != "" && origin == o {
+					allowed = true
+				}
+			}
+			if !allowed {
+				return fmt.Errorf("origin %q is not allowed", origin)
+			}
+			config.Protocol = []string{"graphql-transport-ws"}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			ctx, cancel := context.WithCancel(gc.newGraphQLContext(c.Request().Context(), uint(userId.(float64))))
+			defer cancel()
+			conn := &graphqlWSConn{ws: ws, operations: map[string]context.CancelFunc{}}
+			gc.serveWebSocket(ctx, conn)
+		},
+	}
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// serveWebSocketは接続が閉じるまでクライアントのメッセージを読んで処理する
+func (gc *graphqlController) serveWebSocket(ctx context.Context, conn *graphqlWSConn) {
+	initialized := false
+	// connection_initが送られてこない接続は、一定の時間で切ります。
+	initTimer := time.AfterFunc(graphqlConnectionInitTimeout, func() {
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		if !initialized {
+			conn.closeWith(4408, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+	for {
+		var msg graphqlWSMessage
+		if err := websocket.JSON.Receive(conn.ws, &msg); err != nil {
+			return
+		}
+		conn.mu.Lock()
+		ready := initialized
+		conn.mu.Unlock()
+		switch msg.Type {
+		case gqlConnectionInit:
+			if ready {
+				conn.closeWith(4429, "Too many initialisation requests")
+				return
+			}
+			conn.mu.Lock()
+			initialized = true
+			conn.mu.Unlock()
+			if err := conn.send(graphqlWSMessage{Type: gqlConnectionAck}); err != nil {
+				return
+			}
+		case gqlPing:
+			if err := conn.send(graphqlWSMessage{Type: gqlPong}); err != nil {
+				return
+			}
+		case gqlPong:
+		case gqlSubscribe:
+			if !ready {
+				conn.closeWith(4401, "Unauthorized")
+				return
+			}
+			if msg.Id == "" {
+				conn.closeWith(4400, "Subscribe message must have an id")
+				return
+			}
+			req := graphql.Request{}
+			if err := json.Unmarshal(msg.Payload, &req); err != nil {
+				conn.closeWith(4400, "Invalid subscribe payload")
+				return
+			}
+			conn.mu.Lock()
+			if _, ok := conn.operations[msg.Id]; ok {
+				conn.mu.Unlock()
+				conn.closeWith(4409, fmt.Sprintf("Subscriber for %s already exists", msg.Id))
+				return
+			}
+			opCtx, cancel := context.WithCancel(ctx)
+			conn.operations[msg.Id] = cancel
+			conn.mu.Unlock()
+			go gc.runOperation(opCtx, conn, msg.Id, req)
+		case gqlComplete:
+			// クライアントが操作を止めた場合は、completeを送り返しません。
+			conn.mu.Lock()
+			if cancel, ok := conn.operations[msg.Id]; ok {
+				cancel()
+				delete(conn.operations, msg.Id)
+			}
+			conn.mu.Unlock()
+		default:
+			conn.closeWith(4400, fmt.Sprintf("Unknown message type %q", msg.Type))
+			return
+		}
+	}
+}
+
+// runOperationは1つの操作を実行して、結果をnextで送り、終わったらcompleteを送る
+func (gc *graphqlController) runOperation(ctx context.Context, conn *graphqlWSConn, id string, req graphql.Request) {
+	// finishは操作を一覧から外して、クライアントが止めたのでなければ結果の最後のメッセージを送る
+	finish := func(msgType string, payload interface{}) {
+		conn.mu.Lock()
+		cancel, running := conn.operations[id]
+		if running {
+			cancel()
+			delete(conn.operations, id)
+		}
+		conn.mu.Unlock()
+		if !running {
+			return
+		}
+		if payload != nil {
+			conn.sendPayload(id, msgType, payload)
+		} else {
+			conn.send(graphqlWSMessage{Id: id, Type: msgType})
+		}
+	}
+	if gc.schema.OperationType(req) != graphql.OperationSubscription {
+		res := gc.schema.Execute(ctx, req)
+		// パースや検証でエラーになり実行できなかった操作は、errorでエラーの一覧を送ります。
+		if !res.Executed() {
+			finish(gqlError, res.Errors)
+			return
+		}
+		if err := conn.sendPayload(id, gqlNext, res); err != nil {
+			return
+		}
+		finish(gqlComplete, nil)
+		return
+	}
+	results, res := gc.schema.Subscribe(ctx, req)
+	if res != nil {
+		finish(gqlError, res.Errors)
+		return
+	}
+	for result := range results {
+		if err := conn.sendPayload(id, gqlNext, result); err != nil {
+			return
+		}
+	}
+	finish(gqlComplete, nil)
+}
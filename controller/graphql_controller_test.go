@@ -0,0 +1,399 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// テストで使うユースケースのスタブ(テストで呼ばないメソッドは埋め込んだnilのインターフェースのままです)
+type stubTaskUsecase struct {
+	usecase.ITaskUsecase
+	tasks []model.TaskResponse
+	calls []model.TaskFilter
+}
+
+func (s *stubTaskUsecase) GetAllTasks(ctx context.Context, userId uint, filter model.TaskFilter) ([]model.TaskResponse, error) {
+	s.calls = append(s.calls, filter)
+	return s.tasks, nil
+}
+
+type stubProjectUsecase struct {
+	usecase.IProjectUsecase
+	projects []model.ProjectResponse
+	calls    int
+}
+
+func (s *stubProjectUsecase) GetProjects(ctx context.Context, userId uint) ([]model.ProjectResponse, error) {
+	s.calls++
+	return s.projects, nil
+}
+
+type stubCommentUsecase struct {
+	usecase.ICommentUsecase
+	calls int
+}
+
+func (s *stubCommentUsecase) GetCommentsByTasks(ctx context.Context, userId uint, taskIds []uint) (map[uint][]model.CommentResponse, error) {
+	s.calls++
+	return map[uint][]model.CommentResponse{}, nil
+}
+
+// stubSubscriptionは1回のSubscribeの呼び出しで、テストからイベントを送るチャンネルと購読のctx
+type stubSubscription struct {
+	ctx    context.Context
+	userId uint
+	events chan model.TaskChangeEvent
+}
+
+type stubEventUsecase struct {
+	subscribed chan *stubSubscription
+}
+
+func (s *stubEventUsecase) Subscribe(ctx context.Context, userId uint) <-chan model.TaskChangeEvent {
+	sub := &stubSubscription{ctx: ctx, userId: userId, events: make(chan model.TaskChangeEvent)}
+	out := make(chan model.TaskChangeEvent)
+	// 本物のハブと同じく、ctxがキャンセルされたらチャンネルを閉じます。
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case event, ok := <-sub.events:
+				if !ok {
+					return
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	s.subscribed <- sub
+	return out
+}
+
+const testGraphQLOrigin = "http://localhost:3000"
+
+func idPtr(v uint) *uint {
+	return &v
+}
+
+func newTestGraphQLController() (*graphqlController, *stubTaskUsecase, *stubProjectUsecase, *stubCommentUsecase, *stubEventUsecase) {
+	tu := &stubTaskUsecase{tasks: []model.TaskResponse{
+		{ID: 1, Title: "Buy milk", ProjectId: idPtr(10), Tags: model.TaskTags{"shopping"}},
+		{ID: 2, Title: "Write report", ProjectId: idPtr(20), Tags: model.TaskTags{"work", "urgent"}},
+		{ID: 3, Title: "Call mom", ProjectId: idPtr(10), ParentId: idPtr(1), Tags: model.TaskTags{}},
+		{ID: 4, Title: "Inbox", Tags: model.TaskTags{}},
+	}}
+	pu := &stubProjectUsecase{projects: []model.ProjectResponse{{ID: 10, Name: "Home"}, {ID: 20, Name: "Work"}}}
+	cu := &stubCommentUsecase{}
+	eu := &stubEventUsecase{subscribed: make(chan *stubSubscription, 1)}
+	gc := NewGraphQLController(nil, tu, pu, cu, eu, []string{testGraphQLOrigin}).(*graphqlController)
+	return gc, tu, pu, cu, eu
+}
+
+// withTestUserはjwtのミドルウェアの代わりに、ユーザーIDが1のトークンを設定する
+func withTestUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"user_id": float64(1)}})
+		return next(c)
+	}
+}
+
+func postGraphQL(t *testing.T, gc *graphqlController, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	if err := withTestUser(gc.Query)(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	gc, tu, pu, _, _ := newTestGraphQLController()
+	rec := postGraphQL(t, gc, `{"query": "query ($scope: TaskScope) { tasks(scope: $scope) { id title tags parentId project { name } } }", "variables": {"scope": "all"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	want := `{"data":{"tasks":[` +
+		`{"id":"1","title":"Buy milk","tags":["shopping"],"parentId":null,"project":{"name":"Home"}},` +
+		`{"id":"2","title":"Write report","tags":["work","urgent"],"parentId":null,"project":{"name":"Work"}},` +
+		`{"id":"3","title":"Call mom","tags":[],"parentId":"1","project":{"name":"Home"}},` +
+		`{"id":"4","title":"Inbox","tags":[],"parentId":null,"project":null}]}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if len(tu.calls) != 1 || tu.calls[0].Scope != model.TaskScopeAll {
+		t.Errorf("GetAllTasks calls = %+v, want one call with scope all", tu.calls)
+	}
+	// 全てのタスクのプロジェクトを、Loaderで1回の取得にまとめます。
+	if pu.calls != 1 {
+		t.Errorf("GetProjects calls = %d, want 1", pu.calls)
+	}
+}
+
+func TestGraphQLQueryErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"missing query", `{"variables": {}}`, http.StatusBadRequest, `"query is required"`},
+		{"invalid json", `{"query":`, http.StatusBadRequest, ""},
+		{"syntax error", `{"query": "{ tasks { id }"}`, http.StatusOK,
+			`{"errors":[{"message":"Syntax Error: Unexpected \u003cEOF\u003e.","locations":[{"line":1,"column":15}]}]}`},
+		{"unknown field", `{"query": "{ tasks { owner } }"}`, http.StatusOK,
+			`{"errors":[{"message":"Cannot query field \"owner\" on type \"Task\".","locations":[{"line":1,"column":11}]}]}`},
+		{"subscription over http", `{"query": "subscription { taskChanged { event } }"}`, http.StatusOK,
+			`{"errors":[{"message":"Subscriptions are only available over WebSocket.","locations":[{"line":1,"column":1}]}]}`},
+		{"too deep", `{"query": "{ tasks { comments { task { comments { task { comments { task { comments { task { comments { id } } } } } } } } } } }"}`, http.StatusOK,
+			`{"errors":[{"message":"Query is too deep: depth 11 exceeds the maximum of 10.","locations":[{"line":1,"column":1}]}]}`},
+		// 1 + (1 + (1 + (1 + 2 * 10)) * 10) * 10 = 2211
+		{"too complex", `{"query": "{ tasks { comments { task { comments { id body } } } } }"}`, http.StatusOK,
+			`{"errors":[{"message":"Query is too complex: complexity 2211 exceeds the maximum of 1000.","locations":[{"line":1,"column":1}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc, tu, _, cu, _ := newTestGraphQLController()
+			rec := postGraphQL(t, gc, tt.body)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); tt.want != "" && got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+			// 検証でエラーになったクエリはユースケースを呼びません。
+			if len(tu.calls) != 0 || cu.calls != 0 {
+				t.Errorf("usecases were called: tasks %d, comments %d", len(tu.calls), cu.calls)
+			}
+		})
+	}
+}
+
+// graphqlWSTestは/graphqlのWebSocketに接続したクライアント
+type graphqlWSTest struct {
+	t  *testing.T
+	ws *websocket.Conn
+}
+
+func newGraphQLWSServer(t *testing.T, gc *graphqlController) *httptest.Server {
+	t.Helper()
+	e := echo.New()
+	e.GET("/graphql", gc.WebSocket, withTestUser)
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialGraphQLWS(srv *httptest.Server, origin string) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(srv.URL, "http")+"/graphql", origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = []string{"graphql-transport-ws"}
+	return websocket.DialConfig(config)
+}
+
+func connectGraphQLWS(t *testing.T, srv *httptest.Server) *graphqlWSTest {
+	t.Helper()
+	ws, err := dialGraphQLWS(srv, testGraphQLOrigin)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	if ws.Config().Protocol[0] != "graphql-transport-ws" {
+		t.Errorf("protocol = %v, want graphql-transport-ws", ws.Config().Protocol)
+	}
+	return &graphqlWSTest{t: t, ws: ws}
+}
+
+func (c *graphqlWSTest) send(msgType string, id string, payload string) {
+	c.t.Helper()
+	msg := graphqlWSMessage{Id: id, Type: msgType}
+	if payload != "" {
+		msg.Payload = json.RawMessage(payload)
+	}
+	if err := websocket.JSON.Send(c.ws, msg); err != nil {
+		c.t.Fatalf("send %s: %v", msgType, err)
+	}
+}
+
+// expectは次のメッセージが種類・ID・payloadのJSONに一致することを確認する
+func (c *graphqlWSTest) expect(msgType string, id string, payload string) {
+	c.t.Helper()
+	c.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg graphqlWSMessage
+	if err := websocket.JSON.Receive(c.ws, &msg); err != nil {
+		c.t.Fatalf("receive %s: %v", msgType, err)
+	}
+	if msg.Type != msgType || msg.Id != id || string(msg.Payload) != payload {
+		c.t.Fatalf("got message %s %q %s, want %s %q %s", msg.Type, msg.Id, msg.Payload, msgType, id, payload)
+	}
+}
+
+// expectClosedはサーバーが接続を閉じたことを確認する
+func (c *graphqlWSTest) expectClosed() {
+	c.t.Helper()
+	c.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg graphqlWSMessage
+	if err := websocket.JSON.Receive(c.ws, &msg); err == nil {
+		c.t.Fatalf("got message %s %q %s, want the connection to be closed", msg.Type, msg.Id, msg.Payload)
+	}
+}
+
+func waitSubscription(t *testing.T, eu *stubEventUsecase) *stubSubscription {
+	t.Helper()
+	select {
+	case sub := <-eu.subscribed:
+		return sub
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription did not start")
+	}
+	return nil
+}
+
+func TestGraphQLWebSocketSubscription(t *testing.T) {
+	gc, _, _, _, eu := newTestGraphQLController()
+	c := connectGraphQLWS(t, newGraphQLWSServer(t, gc))
+	c.send(gqlConnectionInit, "", "")
+	c.expect(gqlConnectionAck, "", "")
+	c.send(gqlPing, "", "")
+	c.expect(gqlPong, "", "")
+
+	c.send(gqlSubscribe, "1", `{"query": "subscription ($p: ID) { taskChanged(projectId: $p) { event taskId task { title } } }", "variables": {"p": "5"}}`)
+	sub := waitSubscription(t, eu)
+	if sub.userId != 1 {
+		t.Errorf("Subscribe userId = %d, want 1", sub.userId)
+	}
+	// 指定したプロジェクト以外のタスクの変更は送りません。
+	sub.events <- model.TaskChangeEvent{Event: "task.updated", TaskId: 2, ProjectId: idPtr(6), Task: &model.TaskResponse{ID: 2, Title: "Other"}}
+	sub.events <- model.TaskChangeEvent{Event: "task.updated", TaskId: 3, ProjectId: idPtr(5), Task: &model.TaskResponse{ID: 3, Title: "Call mom"}}
+	c.expect(gqlNext, "1", `{"data":{"taskChanged":{"event":"task.updated","taskId":"3","task":{"title":"Call mom"}}}}`)
+	sub.events <- model.TaskChangeEvent{Event: "task.deleted", TaskId: 4, ProjectId: idPtr(5)}
+	c.expect(gqlNext, "1", `{"data":{"taskChanged":{"event":"task.deleted","taskId":"4","task":null}}}`)
+
+	// イベントのチャンネルが閉じたら、completeを送ります。
+	close(sub.events)
+	c.expect(gqlComplete, "1", "")
+}
+
+func TestGraphQLWebSocketClientComplete(t *testing.T) {
+	gc, _, _, _, eu := newTestGraphQLController()
+	c := connectGraphQLWS(t, newGraphQLWSServer(t, gc))
+	c.send(gqlConnectionInit, "", "")
+	c.expect(gqlConnectionAck, "", "")
+	c.send(gqlSubscribe, "a", `{"query": "subscription { taskChanged { event } }"}`)
+	sub := waitSubscription(t, eu)
+
+	// クライアントが止めた操作は、購読のctxをキャンセルしてcompleteを送り返しません。
+	c.send(gqlComplete, "a", "")
+	select {
+	case <-sub.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription was not canceled")
+	}
+	// 同じIDで新しい操作を始められます。
+	c.send(gqlSubscribe, "a", `{"query": "{ tasks { id } }"}`)
+	c.expect(gqlNext, "a", `{"data":{"tasks":[{"id":"1"},{"id":"2"},{"id":"3"},{"id":"4"}]}}`)
+	c.expect(gqlComplete, "a", "")
+}
+
+func TestGraphQLWebSocketOperations(t *testing.T) {
+	gc, _, _, _, _ := newTestGraphQLController()
+	c := connectGraphQLWS(t, newGraphQLWSServer(t, gc))
+	c.send(gqlConnectionInit, "", `{"authorization": "ignored"}`)
+	c.expect(gqlConnectionAck, "", "")
+
+	// クエリもWebSocketで実行できます。
+	c.send(gqlSubscribe, "q", `{"query": "query Named { tasks { title } }", "operationName": "Named"}`)
+	c.expect(gqlNext, "q", `{"data":{"tasks":[{"title":"Buy milk"},{"title":"Write report"},{"title":"Call mom"},{"title":"Inbox"}]}}`)
+	c.expect(gqlComplete, "q", "")
+
+	// 検証でエラーになった操作は、errorでエラーの一覧を送ります(completeは送りません)。
+	c.send(gqlSubscribe, "bad", `{"query": "subscription { taskChanged { owner } }"}`)
+	c.expect(gqlError, "bad", `[{"message":"Cannot query field \"owner\" on type \"TaskChangeEvent\".","locations":[{"line":1,"column":30}]}]`)
+	c.send(gqlSubscribe, "deep", `{"query": "{ tasks { comments { task { comments { task { comments { task { comments { task { comments { id } } } } } } } } } } }"}`)
+	c.expect(gqlError, "deep", `[{"message":"Query is too deep: depth 11 exceeds the maximum of 10.","locations":[{"line":1,"column":1}]}]`)
+	c.send(gqlSubscribe, "id", `{"query": "subscription { taskChanged(projectId: \"x\") { event } }"}`)
+	c.expect(gqlError, "id", `[{"message":"invalid id \"x\"","locations":[{"line":1,"column":16}],"path":["taskChanged"]}]`)
+
+	// 接続は閉じずに、次の操作を実行できます。
+	c.send(gqlPing, "", "")
+	c.expect(gqlPong, "", "")
+}
+
+func TestGraphQLWebSocketProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     bool
+		msgType  string
+		id       string
+		payload  string
+		closeMsg string
+	}{
+		{"subscribe before init", false, gqlSubscribe, "1", `{"query": "{ tasks { id } }"}`, `[{"message":"4401: Unauthorized"}]`},
+		{"second init", true, gqlConnectionInit, "", "", `[{"message":"4429: Too many initialisation requests"}]`},
+		{"subscribe without id", true, gqlSubscribe, "", `{"query": "{ tasks { id } }"}`, `[{"message":"4400: Subscribe message must have an id"}]`},
+		{"invalid payload", true, gqlSubscribe, "1", `"query"`, `[{"message":"4400: Invalid subscribe payload"}]`},
+		{"unknown type", true, "start", "1", "", `[{"message":"4400: Unknown message type \"start\""}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc, _, _, _, _ := newTestGraphQLController()
+			c := connectGraphQLWS(t, newGraphQLWSServer(t, gc))
+			if tt.init {
+				c.send(gqlConnectionInit, "", "")
+				c.expect(gqlConnectionAck, "", "")
+			}
+			c.send(tt.msgType, tt.id, tt.payload)
+			c.expect(gqlError, "", tt.closeMsg)
+			c.expectClosed()
+		})
+	}
+}
+
+func TestGraphQLWebSocketDuplicateId(t *testing.T) {
+	gc, _, _, _, eu := newTestGraphQLController()
+	c := connectGraphQLWS(t, newGraphQLWSServer(t, gc))
+	c.send(gqlConnectionInit, "", "")
+	c.expect(gqlConnectionAck, "", "")
+	c.send(gqlSubscribe, "1", `{"query": "subscription { taskChanged { event } }"}`)
+	sub := waitSubscription(t, eu)
+	c.send(gqlSubscribe, "1", `{"query": "subscription { taskChanged { event } }"}`)
+	c.expect(gqlError, "", `[{"message":"4409: Subscriber for 1 already exists"}]`)
+	c.expectClosed()
+	// 接続を閉じたら、実行中のサブスクリプションも止めます。
+	select {
+	case <-sub.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription was not canceled after the connection was closed")
+	}
+}
+
+func TestGraphQLWebSocketOrigin(t *testing.T) {
+	gc, _, _, _, _ := newTestGraphQLController()
+	srv := newGraphQLWSServer(t, gc)
+	// 許可していないOriginからの接続は、ハンドシェイクで断ります。
+	if ws, err := dialGraphQLWS(srv, "http://evil.example"); err == nil {
+		ws.Close()
+		t.Fatal("connected from a disallowed origin")
+	}
+}
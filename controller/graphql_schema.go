@@ -0,0 +1,531 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/graphql"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// graphqlUserKeyはリゾルバーにログインしているユーザーのIDを渡すためのcontextのキー
+type graphqlUserKey struct{}
+
+// graphqlLoadersKeyはリクエストごとのLoaderを渡すためのcontextのキー
+type graphqlLoadersKey struct{}
+
+// graphqlLoadersはリクエストの中でIDで取得した結果をキャッシュする
+type graphqlLoaders struct {
+	projects *graphql.Loader
+}
+
+// graphqlMaxDepthとgraphqlMaxComplexityは1回のリクエストで実行できるクエリの大きさの上限
+// 深く入れ子にしたクエリや、リストを何重にも展開するクエリでデータベースに負荷をかけられないようにします。
+const (
+	graphqlMaxDepth      = 10
+	graphqlMaxComplexity = 1000
+)
+
+// newGraphQLContextはリゾルバーで使うユーザーのIDとLoaderをctxに設定する
+func (gc *graphqlController) newGraphQLContext(ctx context.Context, userId uint) context.Context {
+	ctx = context.WithValue(ctx, graphqlUserKey{}, userId)
+	return context.WithValue(ctx, graphqlLoadersKey{}, &graphqlLoaders{
+		// プロジェクトは見えるプロジェクトの一覧を1回で取得して、IDで引けるようにします。
+		projects: graphql.NewLoader(func(ctx context.Context, keys []uint) (map[uint]interface{}, error) {
+			projects, err := gc.pu.GetProjects(ctx, graphqlUserId(ctx))
+			if err != nil {
+				return nil, err
+			}
+			values := map[uint]interface{}{}
+			for _, p := range projects {
+				values[p.ID] = p
+			}
+			return values, nil
+		}),
+	})
+}
+
+func graphqlUserId(ctx context.Context) uint {
+	userId, _ := ctx.Value(graphqlUserKey{}).(uint)
+	return userId
+}
+
+func graphqlLoadersFrom(ctx context.Context) *graphqlLoaders {
+	return ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+}
+
+// parseGraphQLIdはID型の引数(文字列)をデータベースのIDにする
+func parseGraphQLId(v interface{}) (uint, error) {
+	s, _ := v.(string)
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid id %q", s)
+	}
+	return uint(id), nil
+}
+
+// optionalGraphQLIdはnullにできるID型の引数をポインターにする(nullの場合はnil)
+func optionalGraphQLId(v interface{}) (*uint, error) {
+	if v == nil {
+		return nil, nil
+	}
+	id, err := parseGraphQLId(v)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// taskSourceはリゾルバーの親のオブジェクト(タスクの値かポインター)をTaskResponseにする
+func taskSource(source interface{}) model.TaskResponse {
+	if t, ok := source.(*model.TaskResponse); ok {
+		return *t
+	}
+	return source.(model.TaskResponse)
+}
+
+// hiddenAsNullは見つからないか見られない場合にnullを返す(IDで取得するフィールドで、存在を知られないようにします)
+func hiddenAsNull(v interface{}, err error) (interface{}, error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, permission.ErrForbidden) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// newGraphQLSchemaは/graphqlのスキーマを組み立てる
+// リゾルバーは全てRESTのエンドポイントと同じユースケースを呼び出すので、アクセス権の確認も同じです。
+// 一覧の中のプロジェクト・タスク・コメントは、同じ階層の全ての親についてBatchで1回のクエリにまとめます。
+func (gc *graphqlController) newGraphQLSchema() *graphql.Schema {
+	user := &graphql.Object{Name: "User", Fields: map[string]*graphql.Field{
+		"id":    {Type: graphql.NewNonNull(graphql.ID)},
+		"email": {Type: graphql.NewNonNull(graphql.String)},
+	}}
+	project := &graphql.Object{Name: "Project", Fields: map[string]*graphql.Field{
+		"id":             {Type: graphql.NewNonNull(graphql.ID)},
+		"name":           {Type: graphql.NewNonNull(graphql.String)},
+		"userId":         {Type: graphql.NewNonNull(graphql.ID)},
+		"organizationId": {Type: graphql.ID},
+		"role":           {Type: graphql.NewNonNull(graphql.String)},
+		"createdAt":      {Type: graphql.NewNonNull(graphql.Time)},
+		"updatedAt":      {Type: graphql.NewNonNull(graphql.Time)},
+	}}
+	task := &graphql.Object{Name: "Task", Fields: map[string]*graphql.Field{
+		"id":             {Type: graphql.NewNonNull(graphql.ID)},
+		"title":          {Type: graphql.NewNonNull(graphql.String)},
+		"description":    {Type: graphql.NewNonNull(graphql.String)},
+		"completed":      {Type: graphql.NewNonNull(graphql.Boolean)},
+		"dueDate":        {Type: graphql.Time},
+		"rrule":          {Type: graphql.String},
+		"timezone":       {Type: graphql.String},
+		"seriesId":       {Type: graphql.ID},
+		"recurrenceId":   {Type: graphql.Time},
+		"position":       {Type: graphql.NewNonNull(graphql.String)},
+		"projectId":      {Type: graphql.ID},
+		"organizationId": {Type: graphql.ID},
+		"assigneeId":     {Type: graphql.ID},
+		"columnId":       {Type: graphql.ID},
+		"customFields":   {Type: graphql.JSON},
+		"tags":           {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+		"parentId":       {Type: graphql.ID},
+		"userId":         {Type: graphql.NewNonNull(graphql.ID)},
+		"createdAt":      {Type: graphql.NewNonNull(graphql.Time)},
+		"updatedAt":      {Type: graphql.NewNonNull(graphql.Time)},
+	}}
+	comment := &graphql.Object{Name: "Comment", Fields: map[string]*graphql.Field{
+		"id":          {Type: graphql.NewNonNull(graphql.ID)},
+		"taskId":      {Type: graphql.NewNonNull(graphql.ID)},
+		"userId":      {Type: graphql.NewNonNull(graphql.ID)},
+		"authorEmail": {Type: graphql.NewNonNull(graphql.String)},
+		"body":        {Type: graphql.NewNonNull(graphql.String)},
+		"edited":      {Type: graphql.NewNonNull(graphql.Boolean)},
+		"editedAt":    {Type: graphql.Time},
+		"mentions":    {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+		"createdAt":   {Type: graphql.NewNonNull(graphql.Time)},
+		"updatedAt":   {Type: graphql.NewNonNull(graphql.Time)},
+	}}
+	taskEvent := &graphql.Object{Name: "TaskChangeEvent", Fields: map[string]*graphql.Field{
+		// eventはtask.created・task.updated・task.deletedのいずれか
+		"event":      {Type: graphql.NewNonNull(graphql.String)},
+		"taskId":     {Type: graphql.NewNonNull(graphql.ID)},
+		"task":       {Type: task},
+		"actorId":    {Type: graphql.NewNonNull(graphql.ID)},
+		"occurredAt": {Type: graphql.NewNonNull(graphql.Time)},
+	}}
+	taskScope := &graphql.Enum{Name: "TaskScope", Values: []string{model.TaskScopeOwned, model.TaskScopeShared, model.TaskScopeAll}}
+
+	// タスクのプロジェクトは、見えるプロジェクトの一覧をLoaderで1回だけ取得して引きます。
+	task.Fields["project"] = &graphql.Field{Type: project, Batch: func(p graphql.BatchParams) ([]interface{}, error) {
+		out := make([]interface{}, len(p.Sources))
+		ids := []uint{}
+		for _, s := range p.Sources {
+			if t := taskSource(s); t.ProjectId != nil {
+				ids = append(ids, *t.ProjectId)
+			}
+		}
+		if len(ids) == 0 {
+			return out, nil
+		}
+		projects, err := graphqlLoadersFrom(p.Context).projects.LoadMany(p.Context, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, s := range p.Sources {
+			if taskSource(s).ProjectId != nil {
+				out[i], projects = projects[0], projects[1:]
+			}
+		}
+		return out, nil
+	}}
+	task.Fields["comments"] = &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(comment))),
+		Batch: func(p graphql.BatchParams) ([]interface{}, error) {
+			ids := make([]uint, len(p.Sources))
+			for i, s := range p.Sources {
+				ids[i] = taskSource(s).ID
+			}
+			comments, err := gc.cu.GetCommentsByTasks(p.Context, graphqlUserId(p.Context), ids)
+			if err != nil {
+				return nil, err
+			}
+			out := make([]interface{}, len(ids))
+			for i, id := range ids {
+				out[i] = append([]model.CommentResponse{}, comments[id]...)
+			}
+			return out, nil
+		},
+	}
+	// プロジェクトのタスクは、全てのプロジェクトのタスクをまとめて取得してからプロジェクトごとに分けます。
+	project.Fields["tasks"] = &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(task))),
+		Batch: func(p graphql.BatchParams) ([]interface{}, error) {
+			ids := make([]uint, len(p.Sources))
+			for i, s := range p.Sources {
+				ids[i] = s.(model.ProjectResponse).ID
+			}
+			tasks, err := gc.tu.GetAllTasks(p.Context, graphqlUserId(p.Context), model.TaskFilter{Scope: model.TaskScopeAll, ProjectIds: ids})
+			if err != nil {
+				return nil, err
+			}
+			byProject := map[uint][]model.TaskResponse{}
+			for _, t := range tasks {
+				byProject[*t.ProjectId] = append(byProject[*t.ProjectId], t)
+			}
+			out := make([]interface{}, len(ids))
+			for i, id := range ids {
+				out[i] = append([]model.TaskResponse{}, byProject[id]...)
+			}
+			return out, nil
+		},
+	}
+	comment.Fields["task"] = &graphql.Field{Type: task, Batch: func(p graphql.BatchParams) ([]interface{}, error) {
+		ids := make([]uint, len(p.Sources))
+		for i, s := range p.Sources {
+			ids[i] = s.(model.CommentResponse).TaskId
+		}
+		tasks, err := gc.tu.GetAllTasks(p.Context, graphqlUserId(p.Context), model.TaskFilter{Scope: model.TaskScopeAll, TaskIds: ids})
+		if err != nil {
+			return nil, err
+		}
+		byId := map[uint]model.TaskResponse{}
+		for _, t := range tasks {
+			byId[t.ID] = t
+		}
+		out := make([]interface{}, len(ids))
+		for i, id := range ids {
+			if t, ok := byId[id]; ok {
+				out[i] = t
+			}
+		}
+		return out, nil
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"me": {Type: graphql.NewNonNull(user), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return gc.uu.GetUserById(graphqlUserId(p.Context))
+		}},
+		// tasksはGET /tasksと同じ絞り込み(queryは検索クエリ)
+		"tasks": {
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(task))),
+			Args: map[string]*graphql.Argument{
+				"scope":      {Type: taskScope, Default: model.TaskScopeOwned},
+				"projectId":  {Type: graphql.ID},
+				"assigneeId": {Type: graphql.ID},
+				"query":      {Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				filter := model.TaskFilter{Scope: p.Args["scope"].(string)}
+				var err error
+				if filter.ProjectId, err = optionalGraphQLId(p.Args["projectId"]); err != nil {
+					return nil, err
+				}
+				if filter.AssigneeId, err = optionalGraphQLId(p.Args["assigneeId"]); err != nil {
+					return nil, err
+				}
+				filter.Query, _ = p.Args["query"].(string)
+				return gc.tu.GetAllTasks(p.Context, graphqlUserId(p.Context), filter)
+			},
+		},
+		"task": {Type: task, Args: map[string]*graphql.Argument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQLId(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				return hiddenAsNull(gc.tu.GetTaskById(p.Context, graphqlUserId(p.Context), id))
+			},
+		},
+		"projects": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(project))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return gc.pu.GetProjects(p.Context, graphqlUserId(p.Context))
+			},
+		},
+		"project": {Type: project, Args: map[string]*graphql.Argument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQLId(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				return hiddenAsNull(gc.pu.GetProjectById(p.Context, graphqlUserId(p.Context), id))
+			},
+		},
+		"comments": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(comment))),
+			Args: map[string]*graphql.Argument{"taskId": {Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQLId(p.Args["taskId"])
+				if err != nil {
+					return nil, err
+				}
+				return gc.cu.GetComments(p.Context, graphqlUserId(p.Context), id)
+			},
+		},
+	}}
+
+	createTaskInput := &graphql.InputObject{Name: "CreateTaskInput", Fields: map[string]*graphql.Argument{
+		"title":        {Type: graphql.NewNonNull(graphql.String)},
+		"description":  {Type: graphql.String},
+		"completed":    {Type: graphql.Boolean},
+		"dueDate":      {Type: graphql.Time},
+		"rrule":        {Type: graphql.String},
+		"timezone":     {Type: graphql.String},
+		"projectId":    {Type: graphql.ID},
+		"assigneeId":   {Type: graphql.ID},
+		"customFields": {Type: graphql.JSON},
+		"tags":         {Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		"parentId":     {Type: graphql.ID},
+	}}
+	// UpdateTaskInputは指定した項目だけを変更する(nullを指定した期限日とプロジェクトは外します)
+	updateTaskInput := &graphql.InputObject{Name: "UpdateTaskInput", Fields: map[string]*graphql.Argument{
+		"title":        {Type: graphql.String},
+		"description":  {Type: graphql.String},
+		"completed":    {Type: graphql.Boolean},
+		"dueDate":      {Type: graphql.Time},
+		"projectId":    {Type: graphql.ID},
+		"customFields": {Type: graphql.JSON},
+		"tags":         {Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+	}}
+	mutation := &graphql.Object{Name: "Mutation", Fields: map[string]*graphql.Field{
+		"createTask": {Type: graphql.NewNonNull(task),
+			Args: map[string]*graphql.Argument{"input": {Type: graphql.NewNonNull(createTaskInput)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				task := model.Task{UserId: graphqlUserId(p.Context)}
+				if err := applyTaskInput(&task, p.Args["input"].(map[string]interface{})); err != nil {
+					return nil, err
+				}
+				return gc.tu.CreateTask(p.Context, task)
+			},
+		},
+		"updateTask": {Type: graphql.NewNonNull(task),
+			Args: map[string]*graphql.Argument{
+				"id":    {Type: graphql.NewNonNull(graphql.ID)},
+				"input": {Type: graphql.NewNonNull(updateTaskInput)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQLId(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				userId := graphqlUserId(p.Context)
+				// PUT /tasks/:taskIdは全ての項目を置き換えるので、今のタスクに指定された項目だけを上書きして渡します。
+				current, err := gc.tu.GetTaskById(p.Context, userId, id)
+				if err != nil {
+					return nil, err
+				}
+				task := model.Task{
+					Title:        current.Title,
+					Description:  current.Description,
+					Completed:    current.Completed,
+					DueDate:      current.DueDate,
+					ProjectId:    current.ProjectId,
+					CustomFields: current.CustomFields,
+					Tags:         current.Tags,
+				}
+				if err := applyTaskInput(&task, p.Args["input"].(map[string]interface{})); err != nil {
+					return nil, err
+				}
+				return gc.tu.UpdateTask(p.Context, task, userId, id)
+			},
+		},
+		"deleteTask": {Type: graphql.NewNonNull(graphql.Boolean),
+			Args: map[string]*graphql.Argument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQLId(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				if err := gc.tu.DeleteTask(p.Context, graphqlUserId(p.Context), id); err != nil {
+					return nil, err
+				}
+				return true, nil
+			},
+		},
+		// assignTaskはassigneeIdをnullにすると担当者を外す
+		"assignTask": {Type: graphql.NewNonNull(task),
+			Args: map[string]*graphql.Argument{
+				"id":         {Type: graphql.NewNonNull(graphql.ID)},
+				"assigneeId": {Type: graphql.ID},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQLId(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				assigneeId, err := optionalGraphQLId(p.Args["assigneeId"])
+				if err != nil {
+					return nil, err
+				}
+				return gc.tu.AssignTask(p.Context, model.TaskAssignRequest{AssigneeId: assigneeId}, graphqlUserId(p.Context), id)
+			},
+		},
+		"createComment": {Type: graphql.NewNonNull(comment),
+			Args: map[string]*graphql.Argument{
+				"taskId": {Type: graphql.NewNonNull(graphql.ID)},
+				"body":   {Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				taskId, err := parseGraphQLId(p.Args["taskId"])
+				if err != nil {
+					return nil, err
+				}
+				comment := model.Comment{Body: p.Args["body"].(string)}
+				return gc.cu.CreateComment(p.Context, comment, graphqlUserId(p.Context), taskId)
+			},
+		},
+		"deleteComment": {Type: graphql.NewNonNull(graphql.Boolean),
+			Args: map[string]*graphql.Argument{
+				"taskId": {Type: graphql.NewNonNull(graphql.ID)},
+				"id":     {Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				taskId, err := parseGraphQLId(p.Args["taskId"])
+				if err != nil {
+					return nil, err
+				}
+				id, err := parseGraphQLId(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				if err := gc.cu.DeleteComment(p.Context, graphqlUserId(p.Context), taskId, id); err != nil {
+					return nil, err
+				}
+				return true, nil
+			},
+		},
+	}}
+
+	subscription := &graphql.Object{Name: "Subscription", Fields: map[string]*graphql.Field{
+		// taskChangedは/eventsと同じ、見られるタスクの変更(projectIdを指定した場合はそのプロジェクトのタスクだけ)
+		"taskChanged": {Type: graphql.NewNonNull(taskEvent),
+			Args: map[string]*graphql.Argument{"projectId": {Type: graphql.ID}},
+			Subscribe: func(p graphql.ResolveParams) (<-chan interface{}, error) {
+				projectId, err := optionalGraphQLId(p.Args["projectId"])
+				if err != nil {
+					return nil, err
+				}
+				events := gc.eu.Subscribe(p.Context, graphqlUserId(p.Context))
+				out := make(chan interface{})
+				go func() {
+					defer close(out)
+					for event := range events {
+						if projectId != nil && (event.ProjectId == nil || *event.ProjectId != *projectId) {
+							continue
+						}
+						select {
+						case out <- event:
+						case <-p.Context.Done():
+							return
+						}
+					}
+				}()
+				return out, nil
+			},
+		},
+	}}
+
+	return &graphql.Schema{
+		Query:         query,
+		Mutation:      mutation,
+		Subscription:  subscription,
+		MaxDepth:      graphqlMaxDepth,
+		MaxComplexity: graphqlMaxComplexity,
+	}
+}
+
+// applyTaskInputはCreateTaskInputとUpdateTaskInputの指定された項目をtaskに設定する
+func applyTaskInput(task *model.Task, input map[string]interface{}) error {
+	for name, v := range input {
+		var err error
+		switch name {
+		case "title":
+			task.Title, _ = v.(string)
+		case "description":
+			task.Description, _ = v.(string)
+		case "completed":
+			task.Completed, _ = v.(bool)
+		case "dueDate":
+			task.DueDate = nil
+			if t, ok := v.(time.Time); ok {
+				task.DueDate = &t
+			}
+		case "rrule":
+			task.RRule, _ = v.(string)
+		case "timezone":
+			task.Timezone, _ = v.(string)
+		case "projectId":
+			task.ProjectId, err = optionalGraphQLId(v)
+		case "assigneeId":
+			task.AssigneeId, err = optionalGraphQLId(v)
+		case "tags":
+			task.Tags = model.TaskTags{}
+			list, _ := v.([]interface{})
+			for _, tag := range list {
+				if s, ok := tag.(string); ok {
+					task.Tags = append(task.Tags, s)
+				}
+			}
+		case "parentId":
+			task.ParentId, err = optionalGraphQLId(v)
+		case "customFields":
+			if v == nil {
+				task.CustomFields = model.CustomFieldValues{}
+				continue
+			}
+			fields, ok := v.(map[string]interface{})
+			if !ok {
+				return errors.New("customFields must be an object")
+			}
+			task.CustomFields = model.CustomFieldValues(fields)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
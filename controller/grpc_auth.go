@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/tenant"
+	"go-rest-api/usecase"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+type IGRPCAuth interface {
+	// UnaryInterceptorとStreamInterceptorは、メタデータのauthorization(Bearer <JWT>)でユーザーを認証する
+	// x-organization-idのメタデータで組織を指定した場合は、ResolveTenantと同じくメンバーか確認して組織を切り替えます。
+	UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error)
+	StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error
+}
+
+type grpcAuth struct {
+	ou usecase.IOrganizationUsecase
+	// secretはログインでJWTに署名したのと同じキー
+	secret []byte
+}
+
+func NewGRPCAuth(ou usecase.IOrganizationUsecase, secret []byte) IGRPCAuth {
+	return &grpcAuth{ou, secret}
+}
+
+// grpcPublicServicesは認証しないサービス(ロードバランサーのヘルスチェックとgrpcurlなどのリフレクション)
+var grpcPublicServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+// grpcUserKeyは認証したユーザーのIDをサービスのメソッドに渡すためのcontextのキー
+type grpcUserKey struct{}
+
+func grpcUserId(ctx context.Context) uint {
+	userId, _ := ctx.Value(grpcUserKey{}).(uint)
+	return userId
+}
+
+func (ga *grpcAuth) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if ga.isPublic(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := ga.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (ga *grpcAuth) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if ga.isPublic(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := ga.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ss, ctx})
+}
+
+// authenticatedStreamは認証したユーザーと組織を設定したctxを返すストリーム
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (ga *grpcAuth) isPublic(fullMethod string) bool {
+	for _, prefix := range grpcPublicServices {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateはJWTを検証して、ユーザーのIDとリクエストの組織をctxに設定する
+func (ga *grpcAuth) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := ""
+	if values := md.Get("authorization"); len(values) > 0 {
+		auth = values[0]
+	}
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata must be a bearer token")
+	}
+	// echo-jwtと同じく、HS256で署名されたトークンだけを受け付けます。
+	token, err := jwt.Parse(strings.TrimPrefix(auth, "Bearer "), func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return ga.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	userId, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+	ctx = context.WithValue(ctx, grpcUserKey{}, uint(userId))
+
+	orgId := 0
+	if values := md.Get("x-organization-id"); len(values) > 0 && values[0] != "" {
+		if orgId, err = strconv.Atoi(values[0]); err != nil || orgId <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid organization id")
+		}
+		if err := ga.ou.CheckMember(uint(userId), uint(orgId)); err != nil {
+			// メンバーではない組織は、存在を知られないように見つからない場合と同じにします。
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, status.Error(codes.NotFound, "organization does not exist")
+			}
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return tenant.WithOrganization(ctx, uint(orgId)), nil
+}
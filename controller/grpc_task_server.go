@@ -0,0 +1,345 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/pb"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/taskquery"
+	"go-rest-api/usecase"
+	"log"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// taskServerはgRPCのTaskServiceをITaskUsecaseのメソッドに対応させる
+type taskServer struct {
+	pb.UnimplementedTaskServiceServer
+	tu usecase.ITaskUsecase
+	// WatchTasksで/eventsと同じタスクの変更を受け取るためのユースケース
+	eu usecase.IEventUsecase
+}
+
+func NewTaskServer(tu usecase.ITaskUsecase, eu usecase.IEventUsecase) pb.TaskServiceServer {
+	return &taskServer{tu: tu, eu: eu}
+}
+
+// grpcErrorはユースケースのエラーをgRPCのステータスにする
+func grpcError(err error) error {
+	var qerr *taskquery.Error
+	var verrs validation.Errors
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, repository.ErrObjectNotExist):
+		return status.Error(codes.NotFound, "object does not exist")
+	case errors.Is(err, permission.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &qerr):
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("query: %s at position %d", qerr.Msg, qerr.Pos))
+	case errors.As(err, &verrs), errors.Is(err, permission.ErrNotAssignable), errors.Is(err, usecase.ErrInvalidSyncToken),
+		errors.Is(err, usecase.ErrRRuleRequiresFutureScope):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	// 想定していないエラーはSQLなどの内部の情報を含むので、ログにだけ出して呼び出し元には返しません。
+	log.Println("grpc:", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func uintPtr(id *uint64) *uint {
+	if id == nil {
+		return nil
+	}
+	v := uint(*id)
+	return &v
+}
+
+func uint64Ptr(id *uint) *uint64 {
+	if id == nil {
+		return nil
+	}
+	v := uint64(*id)
+	return &v
+}
+
+func timestampPtr(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func timePtr(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func newPBTask(task model.TaskResponse) (*pb.Task, error) {
+	res := &pb.Task{
+		Id:             uint64(task.ID),
+		Title:          task.Title,
+		Description:    task.Description,
+		Completed:      task.Completed,
+		DueDate:        timestampPtr(task.DueDate),
+		Rrule:          task.RRule,
+		Timezone:       task.Timezone,
+		SeriesId:       uint64Ptr(task.SeriesId),
+		RecurrenceId:   timestampPtr(task.RecurrenceId),
+		Position:       task.Position,
+		ProjectId:      uint64Ptr(task.ProjectId),
+		OrganizationId: uint64Ptr(task.OrganizationId),
+		AssigneeId:     uint64Ptr(task.AssigneeId),
+		ColumnId:       uint64Ptr(task.ColumnId),
+		UserId:         uint64(task.UserId),
+		CreatedAt:      timestamppb.New(task.CreatedAt),
+		UpdatedAt:      timestamppb.New(task.UpdatedAt),
+		Tags:           task.Tags,
+		ParentId:       uint64Ptr(task.ParentId),
+	}
+	if len(task.CustomFields) > 0 {
+		fields, err := structpb.NewStruct(task.CustomFields)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		res.CustomFields = fields
+	}
+	return res, nil
+}
+
+func newPBTasks(tasks []model.TaskResponse) ([]*pb.Task, error) {
+	res := make([]*pb.Task, 0, len(tasks))
+	for _, t := range tasks {
+		task, err := newPBTask(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, task)
+	}
+	return res, nil
+}
+
+// customFieldValuesは独自の項目の値(Struct)をタスクの値にする(指定しない場合はnil)
+func customFieldValues(fields *structpb.Struct) model.CustomFieldValues {
+	if fields == nil {
+		return nil
+	}
+	return model.CustomFieldValues(fields.AsMap())
+}
+
+var taskScopes = map[pb.TaskScope]string{
+	pb.TaskScope_TASK_SCOPE_UNSPECIFIED: model.TaskScopeOwned,
+	pb.TaskScope_TASK_SCOPE_OWNED:       model.TaskScopeOwned,
+	pb.TaskScope_TASK_SCOPE_SHARED:      model.TaskScopeShared,
+	pb.TaskScope_TASK_SCOPE_ALL:         model.TaskScopeAll,
+}
+
+func (ts *taskServer) ListTasks(ctx context.Context, req *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
+	scope, ok := taskScopes[req.Scope]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "unknown scope")
+	}
+	filter := model.TaskFilter{
+		Scope:      scope,
+		ProjectId:  uintPtr(req.ProjectId),
+		AssigneeId: uintPtr(req.AssigneeId),
+		Query:      req.Query,
+	}
+	tasks, err := ts.tu.GetAllTasks(ctx, grpcUserId(ctx), filter)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	res, err := newPBTasks(tasks)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListTasksResponse{Tasks: res}, nil
+}
+
+func (ts *taskServer) GetTask(ctx context.Context, req *pb.GetTaskRequest) (*pb.Task, error) {
+	task, err := ts.tu.GetTaskById(ctx, grpcUserId(ctx), uint(req.Id))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newPBTask(task)
+}
+
+func (ts *taskServer) CreateTask(ctx context.Context, req *pb.CreateTaskRequest) (*pb.Task, error) {
+	task := model.Task{
+		Title:        req.Title,
+		Description:  req.Description,
+		Completed:    req.Completed,
+		DueDate:      timePtr(req.DueDate),
+		RRule:        req.Rrule,
+		Timezone:     req.Timezone,
+		ProjectId:    uintPtr(req.ProjectId),
+		AssigneeId:   uintPtr(req.AssigneeId),
+		CustomFields: customFieldValues(req.CustomFields),
+		Tags:         req.Tags,
+		ParentId:     uintPtr(req.ParentId),
+		UserId:       grpcUserId(ctx),
+	}
+	res, err := ts.tu.CreateTask(ctx, task)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newPBTask(res)
+}
+
+// updatableTaskFieldsはUpdateTaskのupdate_maskで指定できる項目
+var updatableTaskFields = []string{"title", "description", "completed", "due_date", "project_id", "custom_fields", "tags"}
+
+func (ts *taskServer) UpdateTask(ctx context.Context, req *pb.UpdateTaskRequest) (*pb.Task, error) {
+	if req.Task == nil {
+		return nil, status.Error(codes.InvalidArgument, "task is required")
+	}
+	paths := req.UpdateMask.GetPaths()
+	if len(paths) == 0 {
+		paths = updatableTaskFields
+	}
+	userId := grpcUserId(ctx)
+	// ユースケースのUpdateTaskは全ての項目を置き換えるので、今のタスクにupdate_maskの項目だけを上書きして渡します。
+	current, err := ts.tu.GetTaskById(ctx, userId, uint(req.Id))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	task := model.Task{
+		Title:        current.Title,
+		Description:  current.Description,
+		Completed:    current.Completed,
+		DueDate:      current.DueDate,
+		ProjectId:    current.ProjectId,
+		CustomFields: current.CustomFields,
+		Tags:         current.Tags,
+		RRule:        current.RRule,
+		Timezone:     current.Timezone,
+	}
+	for _, path := range paths {
+		switch path {
+		case "title":
+			task.Title = req.Task.Title
+		case "description":
+			task.Description = req.Task.Description
+		case "completed":
+			task.Completed = req.Task.Completed
+		case "due_date":
+			task.DueDate = timePtr(req.Task.DueDate)
+		case "project_id":
+			task.ProjectId = uintPtr(req.Task.ProjectId)
+		case "custom_fields":
+			task.CustomFields = customFieldValues(req.Task.CustomFields)
+			if task.CustomFields == nil {
+				task.CustomFields = model.CustomFieldValues{}
+			}
+		case "tags":
+			// 空のtagsはタグを全て外す指定です(nilのままだと今のタグを引き継ぐので、空のスライスにします)。
+			task.Tags = model.TaskTags{}
+			task.Tags = append(task.Tags, req.Task.Tags...)
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "field %q cannot be updated", path)
+		}
+	}
+	var res model.TaskResponse
+	if req.ThisAndFuture {
+		res, err = ts.tu.UpdateFutureTasks(ctx, task, userId, uint(req.Id))
+	} else {
+		res, err = ts.tu.UpdateTask(ctx, task, userId, uint(req.Id))
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newPBTask(res)
+}
+
+func (ts *taskServer) DeleteTask(ctx context.Context, req *pb.DeleteTaskRequest) (*emptypb.Empty, error) {
+	if err := ts.tu.DeleteTask(ctx, grpcUserId(ctx), uint(req.Id)); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (ts *taskServer) MoveTask(ctx context.Context, req *pb.MoveTaskRequest) (*pb.Task, error) {
+	move := model.TaskMoveRequest{Before: uintPtr(req.Before), After: uintPtr(req.After)}
+	res, err := ts.tu.MoveTask(ctx, move, grpcUserId(ctx), uint(req.Id))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newPBTask(res)
+}
+
+func (ts *taskServer) AssignTask(ctx context.Context, req *pb.AssignTaskRequest) (*pb.Task, error) {
+	assign := model.TaskAssignRequest{AssigneeId: uintPtr(req.AssigneeId)}
+	res, err := ts.tu.AssignTask(ctx, assign, grpcUserId(ctx), uint(req.Id))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newPBTask(res)
+}
+
+func (ts *taskServer) GetTaskChanges(ctx context.Context, req *pb.GetTaskChangesRequest) (*pb.GetTaskChangesResponse, error) {
+	filter := model.TaskFilter{Scope: model.TaskScopeOwned, ProjectId: uintPtr(req.ProjectId)}
+	changes, err := ts.tu.GetTaskChanges(ctx, grpcUserId(ctx), filter, uint(req.SyncToken))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	changed, err := newPBTasks(changes.Changed)
+	if err != nil {
+		return nil, err
+	}
+	deleted := make([]uint64, 0, len(changes.Deleted))
+	for _, id := range changes.Deleted {
+		deleted = append(deleted, uint64(id))
+	}
+	return &pb.GetTaskChangesResponse{SyncToken: uint64(changes.SyncToken), Changed: changed, DeletedIds: deleted}, nil
+}
+
+func (ts *taskServer) WatchTasks(req *pb.WatchTasksRequest, stream pb.TaskService_WatchTasksServer) error {
+	ctx := stream.Context()
+	projectId := uintPtr(req.ProjectId)
+	events := ts.eu.Subscribe(ctx, grpcUserId(ctx))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				// イベントを取りこぼした可能性があるので、ストリームを終わらせて呼び出し直してもらいます。
+				if ctx.Err() != nil {
+					return nil
+				}
+				return status.Error(codes.Unavailable, "task events were dropped; call WatchTasks again")
+			}
+			if projectId != nil && (event.ProjectId == nil || *event.ProjectId != *projectId) {
+				continue
+			}
+			res := &pb.TaskEvent{
+				Event:      event.Event,
+				TaskId:     uint64(event.TaskId),
+				ActorId:    uint64(event.ActorId),
+				OccurredAt: timestamppb.New(event.OccurredAt),
+			}
+			if event.Task != nil {
+				task, err := newPBTask(*event.Task)
+				if err != nil {
+					return err
+				}
+				res.Task = task
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+	}
+}
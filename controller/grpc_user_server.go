@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"context"
+	"go-rest-api/pb"
+	"go-rest-api/usecase"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// userServerはgRPCのUserServiceをIUserUsecaseのメソッドに対応させる
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	uu usecase.IUserUsecase
+}
+
+func NewUserServer(uu usecase.IUserUsecase) pb.UserServiceServer {
+	return &userServer{uu: uu}
+}
+
+func (us *userServer) GetCurrentUser(ctx context.Context, _ *emptypb.Empty) (*pb.User, error) {
+	user, err := us.uu.GetUserById(grpcUserId(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.User{Id: uint64(user.ID), Email: user.Email}, nil
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IImportController interface {
+	GetImports(c echo.Context) error
+	GetImportById(c echo.Context) error
+	CreateImport(c echo.Context) error
+}
+
+type importController struct {
+	iu usecase.IImportUsecase
+	// maxBytesはアップロードできるエクスポートファイルの最大サイズ
+	maxBytes int64
+}
+
+func NewImportController(iu usecase.IImportUsecase, maxBytes int64) IImportController {
+	return &importController{iu, maxBytes}
+}
+
+func (ic *importController) GetImports(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	importsRes, err := ic.iu.GetImports(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, importsRes)
+}
+
+func (ic *importController) GetImportById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	importId, _ := strconv.Atoi(c.Param("importId"))
+
+	importRes, err := ic.iu.GetImportById(c.Request().Context(), uint(userId.(float64)), uint(importId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, importRes)
+}
+
+// CreateImportはmultipart/form-dataのfileにエクスポートファイル、sourceにその種類(todoist、trello、microsoft_todo)を受け取る
+// インポートはバックグラウンドで実行するので、作成した待機中のジョブを返します。
+func (ic *importController) CreateImport(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	// multipartのヘッダーなどの分として1MBの余裕を持たせてリクエストボディーのサイズを制限します。
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, ic.maxBytes+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if fileHeader.Size > ic.maxBytes {
+		return c.JSON(http.StatusRequestEntityTooLarge, "file is too large")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	defer file.Close()
+
+	importRes, err := ic.iu.CreateImport(c.Request().Context(), uint(userId.(float64)), c.FormValue("source"), fileHeader.Filename, file, fileHeader.Size)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, importRes)
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-rest-api/mailparse"
+	"go-rest-api/model"
+	"go-rest-api/smtpd"
+	"go-rest-api/usecase"
+	"log"
+	"net/http"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// IMailInboxControllerは受信アドレスのエンドポイントと、SMTPのサーバーから呼ばれるメールの受け取り(smtpd.Backend)をまとめたもの
+type IMailInboxController interface {
+	GetInbox(c echo.Context) error
+	RegenerateInbox(c echo.Context) error
+	UpdateInbox(c echo.Context) error
+	DeleteInbox(c echo.Context) error
+	smtpd.Backend
+}
+
+type mailInboxController struct {
+	miu usecase.IMailInboxUsecase
+}
+
+func NewMailInboxController(miu usecase.IMailInboxUsecase) IMailInboxController {
+	return &mailInboxController{miu}
+}
+
+func (mic *mailInboxController) GetInbox(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	inboxRes, err := mic.miu.GetInbox(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, "mail inbox does not exist")
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, inboxRes)
+}
+
+func (mic *mailInboxController) RegenerateInbox(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	inboxRes, err := mic.miu.RegenerateInbox(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, inboxRes)
+}
+
+func (mic *mailInboxController) UpdateInbox(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	req := model.MailInboxRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	inboxRes, err := mic.miu.UpdateInbox(c.Request().Context(), uint(userId.(float64)), req)
+	if err != nil {
+		var validationErrors validation.Errors
+		if errors.As(err, &validationErrors) {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, inboxRes)
+}
+
+func (mic *mailInboxController) DeleteInbox(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	if err := mic.miu.DeleteInbox(c.Request().Context(), uint(userId.(float64))); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (mic *mailInboxController) Rcpt(ctx context.Context, from string, to string) error {
+	// エラーの通知のメール(送信者が空)はタスクにしません。
+	if from == "<>" {
+		return &smtpd.Error{Code: 550, Message: "5.7.1 Bounces are not accepted"}
+	}
+	if err := mic.miu.CheckRecipient(ctx, to); err != nil {
+		if errors.Is(err, usecase.ErrMailboxNotFound) {
+			return &smtpd.Error{Code: 550, Message: "5.1.1 Mailbox does not exist"}
+		}
+		return err
+	}
+	return nil
+}
+
+func (mic *mailInboxController) Data(ctx context.Context, from string, to []string, data []byte) error {
+	msg, err := mailparse.Parse(bytes.NewReader(data))
+	if err != nil {
+		return &smtpd.Error{Code: 554, Message: "5.6.0 Malformed message"}
+	}
+	mail := model.InboundMail{EnvelopeFrom: from, HeaderFrom: msg.From, Subject: msg.Subject, Text: msg.Text}
+	for _, a := range msg.Attachments {
+		mail.Attachments = append(mail.Attachments, model.InboundMailAttachment{FileName: a.FileName, Data: a.Data})
+	}
+	// 宛先ごとにタスクを作成して、1つでも作成できた場合は受け付けたことにします(同じ宛先が重複している場合は1回だけ)。
+	var firstErr error
+	delivered := false
+	seen := map[string]bool{}
+	for _, address := range to {
+		address = strings.ToLower(address)
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		if _, err := mic.miu.DeliverMail(ctx, address, mail); err != nil {
+			log.Printf("mail inbox: %s: %v", address, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delivered = true
+	}
+	if delivered || firstErr == nil {
+		return nil
+	}
+	var validationErrors validation.Errors
+	switch {
+	case errors.Is(firstErr, usecase.ErrSenderNotAllowed):
+		return &smtpd.Error{Code: 550, Message: "5.7.1 Sender is not allowed"}
+	case errors.Is(firstErr, usecase.ErrMailboxNotFound):
+		return &smtpd.Error{Code: 550, Message: "5.1.1 Mailbox does not exist"}
+	case errors.As(firstErr, &validationErrors):
+		return &smtpd.Error{Code: 554, Message: "5.6.0 " + firstErr.Error()}
+	}
+	return firstErr
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type INotificationController interface {
+	GetNotifications(c echo.Context) error
+	MarkAsRead(c echo.Context) error
+}
+
+type notificationController struct {
+	nu usecase.INotificationUsecase
+}
+
+func NewNotificationController(nu usecase.INotificationUsecase) INotificationController {
+	return &notificationController{nu}
+}
+
+func (nc *notificationController) GetNotifications(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	// クエリパラメーターでunread=trueが指定された場合は未読の通知だけを返す
+	unreadOnly := c.QueryParam("unread") == "true"
+	notificationsRes, err := nc.nu.GetNotifications(c.Request().Context(), uint(userId.(float64)), unreadOnly)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, notificationsRes)
+}
+
+func (nc *notificationController) MarkAsRead(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	notificationId, _ := strconv.Atoi(c.Param("notificationId"))
+
+	if err := nc.nu.MarkAsRead(c.Request().Context(), uint(userId.(float64)), uint(notificationId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
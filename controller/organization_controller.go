@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/tenant"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type IOrganizationController interface {
+	GetOrganizations(c echo.Context) error
+	GetOrganizationById(c echo.Context) error
+	CreateOrganization(c echo.Context) error
+	UpdateOrganization(c echo.Context) error
+	DeleteOrganization(c echo.Context) error
+	GetMembers(c echo.Context) error
+	UpdateMember(c echo.Context) error
+	DeleteMember(c echo.Context) error
+	GetInvitations(c echo.Context) error
+	CreateInvitation(c echo.Context) error
+	DeleteInvitation(c echo.Context) error
+	AcceptInvitation(c echo.Context) error
+	// ResolveTenantはリクエストの組織を決めて、リクエストのContextにテナントとして設定するミドルウェア
+	ResolveTenant(next echo.HandlerFunc) echo.HandlerFunc
+}
+
+type organizationController struct {
+	ou usecase.IOrganizationUsecase
+}
+
+func NewOrganizationController(ou usecase.IOrganizationUsecase) IOrganizationController {
+	return &organizationController{ou}
+}
+
+func (oc *organizationController) GetOrganizations(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	orgsRes, err := oc.ou.GetOrganizations(uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, orgsRes)
+}
+
+func (oc *organizationController) GetOrganizationById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+
+	orgRes, err := oc.ou.GetOrganizationById(uint(userId.(float64)), uint(orgId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, orgRes)
+}
+
+func (oc *organizationController) CreateOrganization(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	org := model.Organization{}
+	if err := c.Bind(&org); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	orgRes, err := oc.ou.CreateOrganization(org, uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, orgRes)
+}
+
+func (oc *organizationController) UpdateOrganization(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+
+	org := model.Organization{}
+	if err := c.Bind(&org); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	orgRes, err := oc.ou.UpdateOrganization(org, uint(userId.(float64)), uint(orgId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, orgRes)
+}
+
+func (oc *organizationController) DeleteOrganization(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+
+	err := oc.ou.DeleteOrganization(uint(userId.(float64)), uint(orgId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oc *organizationController) GetMembers(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+
+	membersRes, err := oc.ou.GetMembers(uint(userId.(float64)), uint(orgId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, membersRes)
+}
+
+func (oc *organizationController) UpdateMember(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+	memberId, _ := strconv.Atoi(c.Param("userId"))
+
+	req := model.MemberRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	memberRes, err := oc.ou.UpdateMember(req, uint(userId.(float64)), uint(orgId), uint(memberId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, memberRes)
+}
+
+func (oc *organizationController) DeleteMember(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+	memberId, _ := strconv.Atoi(c.Param("userId"))
+
+	err := oc.ou.DeleteMember(uint(userId.(float64)), uint(orgId), uint(memberId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oc *organizationController) GetInvitations(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+
+	invitationsRes, err := oc.ou.GetInvitations(uint(userId.(float64)), uint(orgId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, invitationsRes)
+}
+
+func (oc *organizationController) CreateInvitation(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+
+	// リクエストボディーのemailとroleで、招待する相手と権限を指定します。
+	req := model.InvitationRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	invitationRes, err := oc.ou.CreateInvitation(c.Request().Context(), req, uint(userId.(float64)), uint(orgId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, invitationRes)
+}
+
+func (oc *organizationController) DeleteInvitation(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	orgId, _ := strconv.Atoi(c.Param("orgId"))
+	invitationId, _ := strconv.Atoi(c.Param("invitationId"))
+
+	err := oc.ou.DeleteInvitation(uint(userId.(float64)), uint(orgId), uint(invitationId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oc *organizationController) AcceptInvitation(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	req := model.AcceptInvitationRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	orgRes, err := oc.ou.AcceptInvitation(req, uint(userId.(float64)))
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvitationInvalid) {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, orgRes)
+}
+
+func (oc *organizationController) ResolveTenant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		userId := claims["user_id"]
+
+		// 組織はパス(/orgs/:orgId/...)かX-Organization-Idのヘッダーで指定します(両方ある場合はパスを優先)。
+		// どちらも無い場合は、どの組織にも属さない個人のタスク・プロジェクトを扱います。
+		id := c.Param("orgId")
+		if id == "" {
+			id = c.Request().Header.Get("X-Organization-Id")
+		}
+		orgId := 0
+		if id != "" {
+			var err error
+			if orgId, err = strconv.Atoi(id); err != nil || orgId <= 0 {
+				return c.JSON(http.StatusBadRequest, "invalid organization id")
+			}
+			if err := oc.ou.CheckMember(uint(userId.(float64)), uint(orgId)); err != nil {
+				// メンバーではない組織は、存在を知られないように見つからない場合と同じにします。
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.JSON(http.StatusNotFound, "organization does not exist")
+				}
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			}
+		}
+		ctx := tenant.WithOrganization(c.Request().Context(), uint(orgId))
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IPersonalTokenController interface {
+	GetTokens(c echo.Context) error
+	CreateToken(c echo.Context) error
+	DeleteToken(c echo.Context) error
+	// AuthenticateはAuthorizationヘッダー(BasicまたはBearer)でユーザーを認証するミドルウェア
+	// クッキーのJWTと同じようにc.Get("user")にユーザーを設定するので、後のハンドラーとミドルウェアはそのまま使えます。
+	Authenticate(next echo.HandlerFunc) echo.HandlerFunc
+}
+
+type personalTokenController struct {
+	ptu usecase.IPersonalTokenUsecase
+}
+
+func NewPersonalTokenController(ptu usecase.IPersonalTokenUsecase) IPersonalTokenController {
+	return &personalTokenController{ptu}
+}
+
+func (ptc *personalTokenController) GetTokens(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	tokensRes, err := ptc.ptu.GetTokens(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, tokensRes)
+}
+
+func (ptc *personalTokenController) CreateToken(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	token := model.PersonalToken{}
+	if err := c.Bind(&token); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	token.UserId = uint(userId.(float64))
+	tokenRes, err := ptc.ptu.CreateToken(c.Request().Context(), token)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, tokenRes)
+}
+
+func (ptc *personalTokenController) DeleteToken(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	tokenId, _ := strconv.Atoi(c.Param("tokenId"))
+
+	if err := ptc.ptu.DeleteToken(c.Request().Context(), uint(userId.(float64)), uint(tokenId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ptc *personalTokenController) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		username, secret, ok := c.Request().BasicAuth()
+		if !ok {
+			if auth := c.Request().Header.Get(echo.HeaderAuthorization); strings.HasPrefix(auth, "Bearer ") {
+				username, secret, ok = "", strings.TrimPrefix(auth, "Bearer "), true
+			}
+		}
+		if !ok {
+			return unauthorized(c)
+		}
+		userId, err := ptc.ptu.Authenticate(c.Request().Context(), username, secret)
+		if err != nil {
+			return unauthorized(c)
+		}
+		// JWTのミドルウェアと同じ形(MapClaimsのuser_idはfloat64)でユーザーを設定します。
+		c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"user_id": float64(userId)}, Valid: true})
+		return next(c)
+	}
+}
+
+// unauthorizedはクライアントにBasic認証のダイアログを出させるためのレスポンス
+func unauthorized(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="go-rest-api", charset="UTF-8"`)
+	return c.JSON(http.StatusUnauthorized, usecase.ErrInvalidCredentials.Error())
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IProjectController interface {
+	GetProjects(c echo.Context) error
+	GetProjectById(c echo.Context) error
+	CreateProject(c echo.Context) error
+	UpdateProject(c echo.Context) error
+	DeleteProject(c echo.Context) error
+}
+
+type projectController struct {
+	pu usecase.IProjectUsecase
+}
+
+func NewProjectController(pu usecase.IProjectUsecase) IProjectController {
+	return &projectController{pu}
+}
+
+func (pc *projectController) GetProjects(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	projectsRes, err := pc.pu.GetProjects(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, projectsRes)
+}
+
+func (pc *projectController) GetProjectById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	projectRes, err := pc.pu.GetProjectById(c.Request().Context(), uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, projectRes)
+}
+
+func (pc *projectController) CreateProject(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	project := model.Project{}
+	if err := c.Bind(&project); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	project.UserId = uint(userId.(float64))
+	projectRes, err := pc.pu.CreateProject(c.Request().Context(), project)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, projectRes)
+}
+
+func (pc *projectController) UpdateProject(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	project := model.Project{}
+	if err := c.Bind(&project); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	projectRes, err := pc.pu.UpdateProject(c.Request().Context(), project, uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, projectRes)
+}
+
+func (pc *projectController) DeleteProject(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	err := pc.pu.DeleteProject(c.Request().Context(), uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IReminderController interface {
+	GetReminders(c echo.Context) error
+	CreateReminder(c echo.Context) error
+	DeleteReminder(c echo.Context) error
+}
+
+type reminderController struct {
+	ru usecase.IReminderUsecase
+}
+
+func NewReminderController(ru usecase.IReminderUsecase) IReminderController {
+	return &reminderController{ru}
+}
+
+func (rc *reminderController) GetReminders(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	remindersRes, err := rc.ru.GetReminders(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, remindersRes)
+}
+
+func (rc *reminderController) CreateReminder(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	// sent_atやattemptsなどを指定されないように、作成のリクエストの項目だけをバインドします。
+	req := model.ReminderRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	reminderRes, err := rc.ru.CreateReminder(c.Request().Context(), req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, reminderRes)
+}
+
+func (rc *reminderController) DeleteReminder(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	reminderId, _ := strconv.Atoi(c.Param("reminderId"))
+
+	err := rc.ru.DeleteReminder(c.Request().Context(), uint(userId.(float64)), uint(taskId), uint(reminderId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
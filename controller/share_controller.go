@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IShareController interface {
+	GetTaskShares(c echo.Context) error
+	ShareTask(c echo.Context) error
+	DeleteTaskShare(c echo.Context) error
+	GetProjectShares(c echo.Context) error
+	ShareProject(c echo.Context) error
+	DeleteProjectShare(c echo.Context) error
+}
+
+type shareController struct {
+	su usecase.IShareUsecase
+}
+
+func NewShareController(su usecase.IShareUsecase) IShareController {
+	return &shareController{su}
+}
+
+func (sc *shareController) GetTaskShares(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	sharesRes, err := sc.su.GetTaskShares(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, sharesRes)
+}
+
+func (sc *shareController) ShareTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	// リクエストボディーのemailとroleで、共有する相手と権限を指定します。
+	req := model.ShareRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	shareRes, err := sc.su.ShareTask(c.Request().Context(), req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, shareRes)
+}
+
+func (sc *shareController) DeleteTaskShare(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	shareId, _ := strconv.Atoi(c.Param("shareId"))
+
+	err := sc.su.DeleteTaskShare(c.Request().Context(), uint(userId.(float64)), uint(taskId), uint(shareId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (sc *shareController) GetProjectShares(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	sharesRes, err := sc.su.GetProjectShares(c.Request().Context(), uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, sharesRes)
+}
+
+func (sc *shareController) ShareProject(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	req := model.ShareRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	shareRes, err := sc.su.ShareProject(c.Request().Context(), req, uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, shareRes)
+}
+
+func (sc *shareController) DeleteProjectShare(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+	shareId, _ := strconv.Atoi(c.Param("shareId"))
+
+	err := sc.su.DeleteProjectShare(c.Request().Context(), uint(userId.(float64)), uint(projectId), uint(shareId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// headerSharePasswordはパスワード保護された公開リンクのパスワードを送るヘッダー
+const headerSharePassword = "X-Share-Password"
+
+type IShareLinkController interface {
+	GetShareLinks(c echo.Context) error
+	CreateShareLink(c echo.Context) error
+	RevokeShareLink(c echo.Context) error
+	GetSharedTask(c echo.Context) error
+}
+
+type shareLinkController struct {
+	lu usecase.IShareLinkUsecase
+}
+
+func NewShareLinkController(lu usecase.IShareLinkUsecase) IShareLinkController {
+	return &shareLinkController{lu}
+}
+
+func (lc *shareLinkController) GetShareLinks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	linksRes, err := lc.lu.GetShareLinks(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, linksRes)
+}
+
+func (lc *shareLinkController) CreateShareLink(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	// リクエストボディーのexpires_atとpasswordはどちらも省略できます。
+	req := model.ShareLinkRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	linkRes, err := lc.lu.CreateShareLink(c.Request().Context(), req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, linkRes)
+}
+
+func (lc *shareLinkController) RevokeShareLink(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	linkId, _ := strconv.Atoi(c.Param("linkId"))
+
+	err := lc.lu.RevokeShareLink(c.Request().Context(), uint(userId.(float64)), uint(taskId), uint(linkId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetSharedTaskはログインしていないユーザーが公開リンクからタスクを見るためのハンドラー
+// JWTのミドルウェアを通らないので、コンテキストからuserは取り出しません。
+func (lc *shareLinkController) GetSharedTask(c echo.Context) error {
+	taskRes, err := lc.lu.GetSharedTask(c.Request().Context(), c.Param("token"), c.Request().Header.Get(headerSharePassword))
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrShareLinkInvalid):
+			return c.JSON(http.StatusNotFound, err.Error())
+		case errors.Is(err, usecase.ErrShareLinkPassword):
+			return c.JSON(http.StatusUnauthorized, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, taskRes)
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ISyncController interface {
+	// GetChangesはクエリパラメーターのsince(同期トークン)の時点から変更のあったタスクを返す
+	// project_idを指定した場合はそのプロジェクトのタスク、指定しない場合は自分のタスクを同期します。
+	GetChanges(c echo.Context) error
+	// PushChangesはオフラインの間にクライアントで行った変更をまとめて受け取り、変更ごとの結果を返す
+	PushChanges(c echo.Context) error
+}
+
+type syncController struct {
+	su usecase.ISyncUsecase
+}
+
+func NewSyncController(su usecase.ISyncUsecase) ISyncController {
+	return &syncController{su}
+}
+
+func (sc *syncController) GetChanges(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	var projectId *uint
+	if id := c.QueryParam("project_id"); id != "" {
+		pid, err := strconv.Atoi(id)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		p := uint(pid)
+		projectId = &p
+	}
+	res, err := sc.su.GetChanges(c.Request().Context(), uint(userId.(float64)), projectId, c.QueryParam("since"))
+	if err != nil {
+		// 同期トークンが正しくない場合は、クライアントに全てを取得し直してもらいます。
+		if errors.Is(err, usecase.ErrInvalidSyncToken) {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (sc *syncController) PushChanges(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	req := model.SyncRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res, err := sc.su.PushChanges(c.Request().Context(), uint(userId.(float64)), req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -1,10 +1,15 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"go-rest-api/model"
+	"go-rest-api/taskquery"
 	"go-rest-api/usecase"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -18,6 +23,15 @@ type ITaskController interface {
 	CreateTask(c echo.Context) error
 	UpdateTask(c echo.Context) error
 	DeleteTask(c echo.Context) error
+	MoveTask(c echo.Context) error
+	GetTaskHistory(c echo.Context) error
+	RestoreTaskVersion(c echo.Context) error
+	AssignTask(c echo.Context) error
+	GetTaskAssignments(c echo.Context) error
+	// ExportTasksはタスクをjson・csv・icsの形式でエクスポートする(レスポンスは少しずつ書き出します)
+	ExportTasks(c echo.Context) error
+	// ImportTasksはjson・csv・icsのファイルからタスクをインポートする(dry_runで作成せずに結果だけを確認できます)
+	ImportTasks(c echo.Context) error
 }
 
 type taskController struct {
@@ -43,11 +57,21 @@ func (tc *taskController) GetAllTasks(c echo.Context) error {
 	// そしてclaimsの中にあるユーザーIDを取得してユuserIdという変数に代入するようにしています。
 	userId := claims["user_id"]
 
+	// クエリパラメーターから絞り込みの条件と並び順を読み込みます。
+	filter, err := parseTaskFilter(c, uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
 	// Contextから取得した値(userId)はany型になっていますので、
 	// いったんfloat64に型アサーションしてからuint型に型変換するようにしています。
 	// そして、タスクユースケースのGetAllTasksメソッドにuserIdを引数として渡すようにしています。
-	tasksRes, err := tc.tu.GetAllTasks(uint(userId.(float64)))
+	tasksRes, err := tc.tu.GetAllTasks(c.Request().Context(), uint(userId.(float64)), filter)
 	if err != nil {
+		// 検索クエリの誤りは、問題のある位置と一緒にBadRequestで返す
+		if res, ok := queryError(err); ok {
+			return c.JSON(http.StatusBadRequest, res)
+		}
 		// エラーが発生した場合は、コンテキスト.JSONでクライアントにInternalServerErrorのステータスとエラーメッセージを返す
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -55,6 +79,58 @@ func (tc *taskController) GetAllTasks(c echo.Context) error {
 	return c.JSON(http.StatusOK, tasksRes)
 }
 
+// parseTaskFilterはタスクの一覧とエクスポートに共通のクエリパラメーターから、絞り込みの条件と並び順を読み込む
+func parseTaskFilter(c echo.Context, userId uint) (model.TaskFilter, error) {
+	// クエリパラメーターのfilterで一覧の種類を指定します。
+	// owned(デフォルト)は自分のタスク、sharedは共有されたタスク、allは両方です。
+	filter := model.TaskFilter{Scope: c.QueryParam("filter")}
+	switch filter.Scope {
+	case "":
+		filter.Scope = model.TaskScopeOwned
+	case model.TaskScopeOwned, model.TaskScopeShared, model.TaskScopeAll:
+	default:
+		return model.TaskFilter{}, errors.New("filter must be owned, shared or all")
+	}
+	// project_idが指定された場合は、そのプロジェクトのタスクに絞り込む
+	if id := c.QueryParam("project_id"); id != "" {
+		projectId, err := strconv.Atoi(id)
+		if err != nil {
+			return model.TaskFilter{}, err
+		}
+		pid := uint(projectId)
+		filter.ProjectId = &pid
+	}
+	// assigneeが指定された場合は、担当者で絞り込む(meの場合はログインしているユーザー)
+	if assignee := c.QueryParam("assignee"); assignee != "" {
+		var aid uint
+		if assignee == "me" {
+			aid = userId
+		} else {
+			assigneeId, err := strconv.Atoi(assignee)
+			if err != nil {
+				return model.TaskFilter{}, errors.New("assignee must be me or a user id")
+			}
+			aid = uint(assigneeId)
+		}
+		filter.AssigneeId = &aid
+	}
+	// field.<項目のID>で独自の項目の値による絞り込み(.gte・.lteを付けると範囲の指定)
+	customFields, err := parseCustomFieldFilters(c.QueryParams())
+	if err != nil {
+		return model.TaskFilter{}, err
+	}
+	filter.CustomFields = customFields
+	// sortで並び順を指定します(先頭に-を付けると降順)。
+	sort, err := model.ParseTaskSort(c.QueryParam("sort"))
+	if err != nil {
+		return model.TaskFilter{}, err
+	}
+	filter.Sort = sort
+	// qで検索クエリを指定します(例: status:open priority>=2 tag:work due<7d "free text")。
+	filter.Query = c.QueryParam("q")
+	return filter, nil
+}
+
 func (tc *taskController) GetTaskById(c echo.Context) error {
 	// コンテキストからuserキーワードを使ってJWTtokenをデコードした内容を取得
 	user := c.Get("user").(*jwt.Token)
@@ -66,7 +142,7 @@ func (tc *taskController) GetTaskById(c echo.Context) error {
 	// こちらはstring型になっていますので、Atoiを使ってstring型からint型に変換します。
 	taskId, _ := strconv.Atoi(id)
 	// タスクユースケースのGetTaskByIdメソッドを呼び出して、第1引数にuserId第2引数にtaskIdをお渡していきます。
-	taskRes, err := tc.tu.GetTaskById(uint(userId.(float64)), uint(taskId))
+	taskRes, err := tc.tu.GetTaskById(c.Request().Context(), uint(userId.(float64)), uint(taskId))
 	if err != nil {
 		// エラーが発生した場合は、StatusInternalServerError、
 		// 成功した場合はStatusOKで取得したタスク(taskRes)をクライアントの方にJSONで返す
@@ -89,7 +165,7 @@ func (tc *taskController) CreateTask(c echo.Context) error {
 	// さらに、taskオブジェクトのUserIdのフィールドにコンテキストから取得したuserIdの値を格納します。
 	task.UserId = uint(userId.(float64))
 	// そして、そのtaskオブジェクトをタスクのCreateTaskに引数として渡していきます。
-	taskRes, err := tc.tu.CreateTask(task)
+	taskRes, err := tc.tu.CreateTask(c.Request().Context(), task)
 	if err != nil {
 		// 失敗した場合は、StatusInternalServerError、
 		return c.JSON(http.StatusInternalServerError, err.Error())
@@ -114,9 +190,20 @@ func (tc *taskController) UpdateTask(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 	// その後にタスクユースケースのUpdateTaskを第1引数をtask第2引数をuserId第3引数をtaskIdとして呼び出す
-	taskRes, err := tc.tu.UpdateTask(task, uint(userId.(float64)), uint(taskId))
+	// 繰り返しタスクでクエリパラメーターにscope=futureが指定された場合は、この回以降すべてを更新します。
+	var taskRes model.TaskResponse
+	var err error
+	if c.QueryParam("scope") == "future" {
+		taskRes, err = tc.tu.UpdateFutureTasks(c.Request().Context(), task, uint(userId.(float64)), uint(taskId))
+	} else {
+		taskRes, err = tc.tu.UpdateTask(c.Request().Context(), task, uint(userId.(float64)), uint(taskId))
+	}
 	if err != nil {
-		// エラーが発生した場合は、StatusInternalServerError
+		// scope=futureを指定せずにrruleを変えようとした場合はStatusBadRequest
+		if errors.Is(err, usecase.ErrRRuleRequiresFutureScope) {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		// それ以外のエラーが発生した場合は、StatusInternalServerError
 		// 成功した場合は、更新後のタスクの値をStatusOKでクライアントにJSONで返すようにしています。
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -132,10 +219,131 @@ func (tc *taskController) DeleteTask(c echo.Context) error {
 	taskId, _ := strconv.Atoi(id)
 
 	// そして、タスクユースケースのDeleteTaskを呼び出して、userIdとtaskIdを渡していきます。
-	err := tc.tu.DeleteTask(uint(userId.(float64)), uint(taskId))
+	err := tc.tu.DeleteTask(c.Request().Context(), uint(userId.(float64)), uint(taskId))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 	// 成功した場合は、コンテキストのNoContentでStatusNoContentをクライアントに返すようにしておきます。
 	return c.NoContent(http.StatusNoContent)
 }
+
+func (tc *taskController) MoveTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	// リクエストボディーのbefore、afterに移動先の前後のタスクのIDを指定します。
+	req := model.TaskMoveRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	taskRes, err := tc.tu.MoveTask(c.Request().Context(), req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+func (tc *taskController) GetTaskHistory(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	versionsRes, err := tc.tu.GetTaskHistory(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, versionsRes)
+}
+
+func (tc *taskController) RestoreTaskVersion(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	// 削除されたタスクの場合は同じIDで作り直されます。
+	taskRes, err := tc.tu.RestoreTaskVersion(c.Request().Context(), uint(userId.(float64)), uint(taskId), version)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+func (tc *taskController) AssignTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	// リクエストボディーのassignee_idで担当者を指定します(nullの場合は担当者を外す)。
+	req := model.TaskAssignRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	taskRes, err := tc.tu.AssignTask(c.Request().Context(), req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+func (tc *taskController) GetTaskAssignments(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	assignmentsRes, err := tc.tu.GetTaskAssignments(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, assignmentsRes)
+}
+
+// parseCustomFieldFiltersはfield.<項目のID>、field.<項目のID>.gte、field.<項目のID>.lteのクエリパラメーターを絞り込みの条件に変換する
+// 値が項目の種類に合っているかは、項目の定義を読み込んだ後にユースケースでチェックします。
+func parseCustomFieldFilters(params url.Values) ([]model.CustomFieldFilter, error) {
+	filters := []model.CustomFieldFilter{}
+	for name, values := range params {
+		if !strings.HasPrefix(name, "field.") {
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(name, "field."), ".")
+		fieldId, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil || len(parts) > 2 {
+			return nil, fmt.Errorf("%s is not a valid field filter", name)
+		}
+		op := model.CustomFieldOpEq
+		if len(parts) == 2 {
+			op = parts[1]
+			if op != model.CustomFieldOpGte && op != model.CustomFieldOpLte {
+				return nil, fmt.Errorf("%s: operator must be gte or lte", name)
+			}
+		}
+		for _, v := range values {
+			filters = append(filters, model.CustomFieldFilter{FieldId: uint(fieldId), Op: op, Value: v})
+		}
+	}
+	return filters, nil
+}
+
+// queryErrorは検索クエリのエラーを、メッセージとクエリの中の位置(1から始まる文字数)のレスポンスに変換する
+func queryError(err error) (map[string]interface{}, bool) {
+	var qerr *taskquery.Error
+	if !errors.As(err, &qerr) {
+		return nil, false
+	}
+	return map[string]interface{}{"message": qerr.Msg, "position": qerr.Pos}, true
+}
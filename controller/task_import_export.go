@@ -0,0 +1,471 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-rest-api/ical"
+	"go-rest-api/model"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// importMaxBytesはインポートするファイルの最大のサイズ、importMaxRowsは1回でインポートできる最大の件数
+const (
+	importMaxBytes = 10 << 20
+	importMaxRows  = 5000
+)
+
+// taskCSVHeaderはエクスポートするCSVの列(インポートではtitle・completed・due_date・project_id・custom_fieldsの列を読み込みます)
+var taskCSVHeader = []string{"id", "title", "completed", "due_date", "project_id", "assignee_id", "rrule", "timezone", "custom_fields", "created_at", "updated_at"}
+
+// taskExporterはエクスポートの形式ごとの書き出し方
+type taskExporter interface {
+	begin() error
+	write(tasks []model.TaskResponse) error
+	end() error
+}
+
+func (tc *taskController) ExportTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	// 絞り込みの条件はGET /tasksと同じクエリパラメーターで指定します(並び順はタスクのIDの順番で固定)。
+	filter, err := parseTaskFilter(c, uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = model.TaskFormatJSON
+	}
+	res := c.Response()
+	var exporter taskExporter
+	contentType := ""
+	switch format {
+	case model.TaskFormatJSON:
+		exporter = &jsonTaskExporter{w: res}
+		contentType = echo.MIMEApplicationJSONCharsetUTF8
+	case model.TaskFormatCSV:
+		exporter = &csvTaskExporter{w: csv.NewWriter(res)}
+		contentType = "text/csv; charset=utf-8"
+	case model.TaskFormatICS:
+		exporter = &icsTaskExporter{w: ical.NewWriter(res)}
+		contentType = "text/calendar; charset=utf-8"
+	default:
+		return c.JSON(http.StatusBadRequest, "format must be json, csv or ics")
+	}
+
+	// レスポンスのヘッダーは最初のタスクを読み込んだ時に書き出すので、検索クエリの誤りなどは通常のエラーのレスポンスで返せます。
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		res.Header().Set(echo.HeaderContentType, contentType)
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "tasks."+format))
+		res.WriteHeader(http.StatusOK)
+		return exporter.begin()
+	}
+	err = tc.tu.ExportTasks(c.Request().Context(), uint(userId.(float64)), filter, func(tasks []model.TaskResponse) error {
+		if err := start(); err != nil {
+			return err
+		}
+		if err := exporter.write(tasks); err != nil {
+			return err
+		}
+		// 読み込んだ分ずつクライアントに送ります。
+		res.Flush()
+		return nil
+	})
+	if err != nil {
+		if started {
+			// ヘッダーを送った後はステータスを変えられないので、途中で出力を打ち切ります。
+			return err
+		}
+		if errRes, ok := queryError(err); ok {
+			return c.JSON(http.StatusBadRequest, errRes)
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if err := start(); err != nil {
+		return err
+	}
+	return exporter.end()
+}
+
+type jsonTaskExporter struct {
+	w     io.Writer
+	count int
+}
+
+// JSONは配列の形で1件ずつ書き出します。
+func (e *jsonTaskExporter) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonTaskExporter) write(tasks []model.TaskResponse) error {
+	for _, t := range tasks {
+		b, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		sep := ",\n"
+		if e.count == 0 {
+			sep = "\n"
+		}
+		if _, err := io.WriteString(e.w, sep); err != nil {
+			return err
+		}
+		if _, err := e.w.Write(b); err != nil {
+			return err
+		}
+		e.count++
+	}
+	return nil
+}
+
+func (e *jsonTaskExporter) end() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+type csvTaskExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvTaskExporter) begin() error {
+	return e.w.Write(taskCSVHeader)
+}
+
+func (e *csvTaskExporter) write(tasks []model.TaskResponse) error {
+	for _, t := range tasks {
+		customFields := ""
+		if len(t.CustomFields) > 0 {
+			b, err := json.Marshal(t.CustomFields)
+			if err != nil {
+				return err
+			}
+			customFields = string(b)
+		}
+		if err := e.w.Write([]string{
+			strconv.FormatUint(uint64(t.ID), 10),
+			csvCell(t.Title),
+			strconv.FormatBool(t.Completed),
+			formatOptionalTime(t.DueDate),
+			formatOptionalId(t.ProjectId),
+			formatOptionalId(t.AssigneeId),
+			t.RRule,
+			t.Timezone,
+			customFields,
+			t.CreatedAt.UTC().Format(time.RFC3339),
+			t.UpdatedAt.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvTaskExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type icsTaskExporter struct {
+	w *ical.Writer
+}
+
+func (e *icsTaskExporter) begin() error {
+	if err := e.w.Begin("VCALENDAR"); err != nil {
+		return err
+	}
+	e.w.Property(ical.Property{Name: "VERSION", Value: "2.0"})
+	return e.w.Property(ical.Property{Name: "PRODID", Value: "-//go-rest-api//tasks//EN"})
+}
+
+func (e *icsTaskExporter) write(tasks []model.TaskResponse) error {
+	for _, t := range tasks {
+		if err := e.w.WriteComponent(taskToVTodo(t)); err != nil {
+			return err
+		}
+	}
+	return e.w.Flush()
+}
+
+func (e *icsTaskExporter) end() error {
+	if err := e.w.End("VCALENDAR"); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// taskToVTodoはタスクをiCalendarのVTODOにする
+func taskToVTodo(t model.TaskResponse) ical.Component {
+	status := "NEEDS-ACTION"
+	if t.Completed {
+		status = "COMPLETED"
+	}
+	todo := ical.Component{Name: "VTODO", Properties: []ical.Property{
+		{Name: "UID", Value: model.TaskUID(t.ID)},
+		{Name: "DTSTAMP", Value: ical.FormatDateTime(t.UpdatedAt)},
+		{Name: "CREATED", Value: ical.FormatDateTime(t.CreatedAt)},
+		{Name: "LAST-MODIFIED", Value: ical.FormatDateTime(t.UpdatedAt)},
+		{Name: "SUMMARY", Value: ical.EscapeText(t.Title)},
+		{Name: "STATUS", Value: status},
+	}}
+	if t.Description != "" {
+		todo.Properties = append(todo.Properties, ical.Property{Name: "DESCRIPTION", Value: ical.EscapeText(t.Description)})
+	}
+	if t.DueDate != nil {
+		todo.Properties = append(todo.Properties, ical.Property{Name: "DUE", Value: ical.FormatDateTime(*t.DueDate)})
+	}
+	if t.RRule != "" {
+		todo.Properties = append(todo.Properties, ical.Property{Name: "RRULE", Value: t.RRule})
+	}
+	return todo
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatOptionalId(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+func (tc *taskController) ImportTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	// ファイルはmultipart/form-dataのfile、またはリクエストのボディでそのまま受け取ります。
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, importMaxBytes)
+	body := io.Reader(c.Request().Body)
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		defer file.Close()
+		body = file
+		contentType = fileHeader.Header.Get(echo.HeaderContentType)
+	}
+	// formatを省略した場合は、ファイルのContent-Typeから形式を決めます。
+	format := c.QueryParam("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		switch mediaType {
+		case echo.MIMEApplicationJSON:
+			format = model.TaskFormatJSON
+		case "text/csv":
+			format = model.TaskFormatCSV
+		case "text/calendar":
+			format = model.TaskFormatICS
+		}
+	}
+	dryRun := false
+	if v := c.QueryParam("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "dry_run must be true or false")
+		}
+		dryRun = b
+	}
+
+	var rows []model.TaskImportRow
+	var err error
+	switch format {
+	case model.TaskFormatJSON:
+		rows, err = parseJSONImport(body)
+	case model.TaskFormatCSV:
+		rows, err = parseCSVImport(body)
+	case model.TaskFormatICS:
+		rows, err = parseICSImport(body)
+	default:
+		return c.JSON(http.StatusBadRequest, "format must be json, csv or ics")
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(rows) > importMaxRows {
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("too many tasks (max %d)", importMaxRows))
+	}
+	resultRes, err := tc.tu.ImportTasks(c.Request().Context(), uint(userId.(float64)), rows, dryRun)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if dryRun {
+		return c.JSON(http.StatusOK, resultRes)
+	}
+	return c.JSON(http.StatusCreated, resultRes)
+}
+
+// parseJSONImportはタスクの配列のJSONを読み込む
+// 配列の形が壊れている場合はファイル全体のエラー、1件の値の型が違う場合はその行だけのエラーにします。
+func parseJSONImport(r io.Reader) ([]model.TaskImportRow, error) {
+	dec := json.NewDecoder(r)
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return nil, errors.New("json must be an array of tasks")
+	}
+	rows := []model.TaskImportRow{}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("row %d: %w", len(rows)+1, err)
+		}
+		row := model.TaskImportRow{Row: len(rows) + 1}
+		if err := json.Unmarshal(raw, &row.Item); err != nil {
+			row.Error = err.Error()
+		}
+		rows = append(rows, row)
+		if len(rows) > importMaxRows {
+			break
+		}
+	}
+	return rows, nil
+}
+
+// parseCSVImportはヘッダーの行の列名で列を探してCSVを読み込む(行の番号はヘッダーを1行目として数えます)
+func parseCSVImport(r io.Reader) ([]model.TaskImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("csv must have a title column")
+	}
+	rows := []model.TaskImportRow{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cell := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		row := model.TaskImportRow{Row: line}
+		if err := parseCSVTask(cell, &row.Item); err != nil {
+			row.Error = err.Error()
+		}
+		rows = append(rows, row)
+		if len(rows) > importMaxRows {
+			break
+		}
+	}
+	return rows, nil
+}
+
+func parseCSVTask(cell func(name string) string, item *model.TaskImportItem) error {
+	// エクスポートでcsvCellが付けた先頭の'は取り除きます。
+	item.Title = cell("title")
+	if len(item.Title) > 1 && item.Title[0] == '\'' && strings.ContainsRune("=+-@", rune(item.Title[1])) {
+		item.Title = item.Title[1:]
+	}
+	if v := cell("completed"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("completed must be true or false")
+		}
+		item.Completed = b
+	}
+	if v := cell("due_date"); v != "" {
+		t, err := parseImportTime(v)
+		if err != nil {
+			return err
+		}
+		item.DueDate = &t
+	}
+	if v := cell("project_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return errors.New("project_id must be a number")
+		}
+		pid := uint(id)
+		item.ProjectId = &pid
+	}
+	if v := cell("custom_fields"); v != "" {
+		if err := json.Unmarshal([]byte(v), &item.CustomFields); err != nil {
+			return errors.New("custom_fields must be a json object")
+		}
+	}
+	return nil
+}
+
+// parseImportTimeはRFC 3339の日時か、YYYY-MM-DDの日付(UTCの0時)を解釈する
+func parseImportTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("due_date must be RFC 3339 or YYYY-MM-DD")
+}
+
+// parseICSImportはカレンダーの中のVTODOを1件ずつ読み込む(行の番号はVTODOの順番)
+// SUMMARYをタイトル、DUEを期限日、STATUS:COMPLETED(またはCOMPLETED)を完了にします。
+func parseICSImport(r io.Reader) ([]model.TaskImportRow, error) {
+	calendar, err := ical.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if calendar.Name != "VCALENDAR" {
+		return nil, errors.New("ics must be a VCALENDAR")
+	}
+	rows := []model.TaskImportRow{}
+	for _, todo := range calendar.Components {
+		if todo.Name != "VTODO" {
+			continue
+		}
+		row := model.TaskImportRow{Row: len(rows) + 1}
+		row.Item.Title = todo.Text("SUMMARY")
+		row.Item.Completed = strings.EqualFold(todo.Text("STATUS"), "COMPLETED") || todo.Get("COMPLETED") != nil
+		if due := todo.Get("DUE"); due != nil {
+			t, err := ical.ParseDateTime(*due)
+			if err != nil {
+				row.Error = err.Error()
+			} else {
+				row.Item.DueDate = &t
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ITemplateController interface {
+	GetTemplates(c echo.Context) error
+	GetTemplateById(c echo.Context) error
+	CreateTemplate(c echo.Context) error
+	UpdateTemplate(c echo.Context) error
+	DeleteTemplate(c echo.Context) error
+	InstantiateTemplate(c echo.Context) error
+	DuplicateTask(c echo.Context) error
+}
+
+type templateController struct {
+	tmu usecase.ITemplateUsecase
+}
+
+func NewTemplateController(tmu usecase.ITemplateUsecase) ITemplateController {
+	return &templateController{tmu}
+}
+
+func (tmc *templateController) GetTemplates(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	templatesRes, err := tmc.tmu.GetTemplates(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, templatesRes)
+}
+
+func (tmc *templateController) GetTemplateById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	templateId, _ := strconv.Atoi(c.Param("templateId"))
+
+	templateRes, err := tmc.tmu.GetTemplateById(c.Request().Context(), uint(userId.(float64)), uint(templateId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, templateRes)
+}
+
+func (tmc *templateController) CreateTemplate(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	template := model.Template{}
+	if err := c.Bind(&template); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	template.UserId = uint(userId.(float64))
+	templateRes, err := tmc.tmu.CreateTemplate(c.Request().Context(), template)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, templateRes)
+}
+
+func (tmc *templateController) UpdateTemplate(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	templateId, _ := strconv.Atoi(c.Param("templateId"))
+
+	template := model.Template{}
+	if err := c.Bind(&template); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	templateRes, err := tmc.tmu.UpdateTemplate(c.Request().Context(), template, uint(userId.(float64)), uint(templateId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, templateRes)
+}
+
+func (tmc *templateController) DeleteTemplate(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	templateId, _ := strconv.Atoi(c.Param("templateId"))
+
+	err := tmc.tmu.DeleteTemplate(c.Request().Context(), uint(userId.(float64)), uint(templateId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (tmc *templateController) InstantiateTemplate(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	templateId, _ := strconv.Atoi(c.Param("templateId"))
+
+	req := model.TemplateInstantiateRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	tasksRes, err := tmc.tmu.InstantiateTemplate(c.Request().Context(), req, uint(userId.(float64)), uint(templateId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, tasksRes)
+}
+
+func (tmc *templateController) DuplicateTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	req := model.TaskDuplicateRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	taskRes, err := tmc.tmu.DuplicateTask(c.Request().Context(), req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, taskRes)
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ITimeEntryController interface {
+	GetTimeEntries(c echo.Context) error
+	StartTimer(c echo.Context) error
+	StopTimer(c echo.Context) error
+	CreateTimeEntry(c echo.Context) error
+	UpdateTimeEntry(c echo.Context) error
+	DeleteTimeEntry(c echo.Context) error
+	GetTimeReport(c echo.Context) error
+}
+
+type timeEntryController struct {
+	teu usecase.ITimeEntryUsecase
+}
+
+func NewTimeEntryController(teu usecase.ITimeEntryUsecase) ITimeEntryController {
+	return &timeEntryController{teu}
+}
+
+// timerErrorはタイマーの操作のエラーをステータスコードに変換してレスポンスを返す
+func timerError(c echo.Context, err error) error {
+	if errors.Is(err, usecase.ErrTimerRunning) {
+		return c.JSON(http.StatusConflict, err.Error())
+	}
+	if errors.Is(err, usecase.ErrTimerNotRunning) {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusInternalServerError, err.Error())
+}
+
+func (tec *timeEntryController) GetTimeEntries(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	entriesRes, err := tec.teu.GetTimeEntries(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, entriesRes)
+}
+
+func (tec *timeEntryController) StartTimer(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	// リクエストボディーは省略できます(説明とタグを付けて開始する場合だけ指定)。
+	req := model.TimerRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	entryRes, err := tec.teu.StartTimer(c.Request().Context(), req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return timerError(c, err)
+	}
+	return c.JSON(http.StatusCreated, entryRes)
+}
+
+func (tec *timeEntryController) StopTimer(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	// 停止した作業時間を返すので、204ではなく200で返します。
+	entryRes, err := tec.teu.StopTimer(c.Request().Context(), uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return timerError(c, err)
+	}
+	return c.JSON(http.StatusOK, entryRes)
+}
+
+func (tec *timeEntryController) CreateTimeEntry(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	req := model.TimeEntryRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	entryRes, err := tec.teu.CreateTimeEntry(c.Request().Context(), req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, entryRes)
+}
+
+func (tec *timeEntryController) UpdateTimeEntry(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	entryId, _ := strconv.Atoi(c.Param("entryId"))
+
+	req := model.TimeEntryRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	entryRes, err := tec.teu.UpdateTimeEntry(c.Request().Context(), req, uint(userId.(float64)), uint(taskId), uint(entryId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, entryRes)
+}
+
+func (tec *timeEntryController) DeleteTimeEntry(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+	entryId, _ := strconv.Atoi(c.Param("entryId"))
+
+	err := tec.teu.DeleteTimeEntry(c.Request().Context(), uint(userId.(float64)), uint(taskId), uint(entryId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (tec *timeEntryController) GetTimeReport(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	// クエリパラメーターのfrom・to・group_by・timezone・formatでレポートの条件を指定します。
+	req := model.TimeReportRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	// formatを省略した場合は、AcceptヘッダーでCSVが指定されていればCSVで返します。
+	if req.Format == "" && strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/csv") {
+		req.Format = "csv"
+	}
+	reportRes, err := tec.teu.GetTimeReport(c.Request().Context(), req, uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if req.Format != "csv" {
+		return c.JSON(http.StatusOK, reportRes)
+	}
+	body, err := timeReportCSV(reportRes)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	filename := fmt.Sprintf("time-report-%s-%s-%s.csv", reportRes.GroupBy, reportRes.From, reportRes.To)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", body)
+}
+
+// timeReportCSVはレポートをCSVに変換する(時間は請求書に使いやすいように小数の時間も出力します)
+func timeReportCSV(report model.TimeReportResponse) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	records := [][]string{{report.GroupBy, "label", "seconds", "hours", "entries"}}
+	for _, row := range report.Rows {
+		records = append(records, []string{
+			csvCell(row.Key),
+			csvCell(row.Label),
+			strconv.FormatInt(row.Seconds, 10),
+			strconv.FormatFloat(float64(row.Seconds)/3600, 'f', 2, 64),
+			strconv.FormatInt(row.Entries, 10),
+		})
+	}
+	records = append(records, []string{"total", "", strconv.FormatInt(report.TotalSeconds, 10),
+		strconv.FormatFloat(float64(report.TotalSeconds)/3600, 'f', 2, 64), ""})
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvCellは表計算ソフトで開いた時に数式として解釈されないように、=・+・-・@で始まる値の先頭に'を付ける
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IViewController interface {
+	GetViews(c echo.Context) error
+	GetViewById(c echo.Context) error
+	CreateView(c echo.Context) error
+	UpdateView(c echo.Context) error
+	DeleteView(c echo.Context) error
+	GetViewTasks(c echo.Context) error
+}
+
+type viewController struct {
+	vu usecase.IViewUsecase
+}
+
+func NewViewController(vu usecase.IViewUsecase) IViewController {
+	return &viewController{vu}
+}
+
+func (vc *viewController) GetViews(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	viewsRes, err := vc.vu.GetViews(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, viewsRes)
+}
+
+func (vc *viewController) GetViewById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	viewId, _ := strconv.Atoi(c.Param("viewId"))
+
+	viewRes, err := vc.vu.GetViewById(c.Request().Context(), uint(userId.(float64)), uint(viewId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, viewRes)
+}
+
+func (vc *viewController) CreateView(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	view := model.View{}
+	if err := c.Bind(&view); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	view.UserId = uint(userId.(float64))
+	viewRes, err := vc.vu.CreateView(c.Request().Context(), view)
+	if err != nil {
+		if res, ok := queryError(err); ok {
+			return c.JSON(http.StatusBadRequest, res)
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, viewRes)
+}
+
+func (vc *viewController) UpdateView(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	viewId, _ := strconv.Atoi(c.Param("viewId"))
+
+	view := model.View{}
+	if err := c.Bind(&view); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	viewRes, err := vc.vu.UpdateView(c.Request().Context(), view, uint(userId.(float64)), uint(viewId))
+	if err != nil {
+		if res, ok := queryError(err); ok {
+			return c.JSON(http.StatusBadRequest, res)
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, viewRes)
+}
+
+func (vc *viewController) DeleteView(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	viewId, _ := strconv.Atoi(c.Param("viewId"))
+
+	err := vc.vu.DeleteView(c.Request().Context(), uint(userId.(float64)), uint(viewId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (vc *viewController) GetViewTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	viewId, _ := strconv.Atoi(c.Param("viewId"))
+
+	tasksRes, err := vc.vu.GetViewTasks(c.Request().Context(), uint(userId.(float64)), uint(viewId))
+	if err != nil {
+		if res, ok := queryError(err); ok {
+			return c.JSON(http.StatusBadRequest, res)
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, tasksRes)
+}
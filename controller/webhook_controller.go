@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IWebhookController interface {
+	GetWebhooks(c echo.Context) error
+	GetWebhookById(c echo.Context) error
+	CreateWebhook(c echo.Context) error
+	UpdateWebhook(c echo.Context) error
+	// RotateSecretは署名の鍵を作り直して、新しい鍵を返す
+	RotateSecret(c echo.Context) error
+	DeleteWebhook(c echo.Context) error
+	// GetDeliveriesは配信のログを返す
+	GetDeliveries(c echo.Context) error
+	// Redeliverは配信のログの1件をもう一度送信する(送信はワーカーが行うので、作成した待機中の配信を返します)
+	Redeliver(c echo.Context) error
+}
+
+type webhookController struct {
+	wu usecase.IWebhookUsecase
+}
+
+func NewWebhookController(wu usecase.IWebhookUsecase) IWebhookController {
+	return &webhookController{wu}
+}
+
+func (wc *webhookController) GetWebhooks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	webhooksRes, err := wc.wu.GetWebhooks(c.Request().Context(), uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, webhooksRes)
+}
+
+func (wc *webhookController) GetWebhookById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	webhookId, _ := strconv.Atoi(c.Param("webhookId"))
+
+	webhookRes, err := wc.wu.GetWebhookById(c.Request().Context(), uint(userId.(float64)), uint(webhookId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, webhookRes)
+}
+
+func (wc *webhookController) CreateWebhook(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	req := model.WebhookRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	webhookRes, err := wc.wu.CreateWebhook(c.Request().Context(), uint(userId.(float64)), req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, webhookRes)
+}
+
+func (wc *webhookController) UpdateWebhook(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	webhookId, _ := strconv.Atoi(c.Param("webhookId"))
+
+	req := model.WebhookRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	webhookRes, err := wc.wu.UpdateWebhook(c.Request().Context(), uint(userId.(float64)), uint(webhookId), req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, webhookRes)
+}
+
+func (wc *webhookController) RotateSecret(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	webhookId, _ := strconv.Atoi(c.Param("webhookId"))
+
+	webhookRes, err := wc.wu.RotateSecret(c.Request().Context(), uint(userId.(float64)), uint(webhookId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, webhookRes)
+}
+
+func (wc *webhookController) DeleteWebhook(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	webhookId, _ := strconv.Atoi(c.Param("webhookId"))
+
+	if err := wc.wu.DeleteWebhook(c.Request().Context(), uint(userId.(float64)), uint(webhookId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (wc *webhookController) GetDeliveries(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	webhookId, _ := strconv.Atoi(c.Param("webhookId"))
+
+	deliveriesRes, err := wc.wu.GetDeliveries(c.Request().Context(), uint(userId.(float64)), uint(webhookId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, deliveriesRes)
+}
+
+func (wc *webhookController) Redeliver(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	webhookId, _ := strconv.Atoi(c.Param("webhookId"))
+	deliveryId, _ := strconv.Atoi(c.Param("deliveryId"))
+
+	deliveryRes, err := wc.wu.Redeliver(c.Request().Context(), uint(userId.(float64)), uint(webhookId), uint(deliveryId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, deliveryRes)
+}
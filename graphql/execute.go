@@ -0,0 +1,437 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+type executor struct {
+	schema *Schema
+	doc    *Document
+	op     *Operation
+	root   *Object
+	vars   map[string]interface{}
+	ctx    context.Context
+	errors []*Error
+	// eventはサブスクリプションで、ルートのフィールドの値にするイベント
+	event interface{}
+}
+
+// resultはフィールドの値を完成させた結果
+// invalidはnullにならない位置がnullになったことを表し、nullにできる一番近い親までnullにします。
+// erroredはエラーでnullになったことを表します(エラーはもう記録しているので、nullにならない位置でも重ねて記録しません)。
+type result struct {
+	value   interface{}
+	invalid bool
+	errored bool
+}
+
+// objectResultはフィールドを選択した順番のまま、JSONにするオブジェクト
+type objectResult struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *objectResult) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *objectResult) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		b.Write(k)
+		b.WriteByte(':')
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// collectedFieldは同じレスポンスのキーにまとめたフィールド(別々に書かれた同じフィールドの選択は1つにまとめます)
+type collectedField struct {
+	key   string
+	name  string
+	nodes []*FieldNode
+}
+
+func (e *executor) execute(event interface{}) *Response {
+	e.event = event
+	results := e.executeObjects(e.root, []interface{}{nil}, e.op.SelectionSet, [][]interface{}{{}})
+	res := &Response{Errors: e.errors, executed: true}
+	if !results[0].invalid {
+		res.Data = results[0].value
+	}
+	return res
+}
+
+// collectFieldsは選択をフラグメントを展開してレスポンスのキーごとにまとめ、@skipと@includeを適用する
+func (e *executor) collectFields(obj *Object, sels []Selection) []*collectedField {
+	fields := []*collectedField{}
+	index := map[string]*collectedField{}
+	visited := map[string]bool{}
+	var collect func(sels []Selection)
+	collect = func(sels []Selection) {
+		for _, sel := range sels {
+			switch sel := sel.(type) {
+			case *FieldNode:
+				if !e.included(sel.Directives) {
+					continue
+				}
+				key := sel.ResponseKey()
+				if f, ok := index[key]; ok {
+					f.nodes = append(f.nodes, sel)
+					continue
+				}
+				f := &collectedField{key: key, name: sel.Name, nodes: []*FieldNode{sel}}
+				index[key] = f
+				fields = append(fields, f)
+			case *FragmentSpread:
+				if visited[sel.Name] || !e.included(sel.Directives) {
+					continue
+				}
+				visited[sel.Name] = true
+				if f, ok := e.doc.Fragments[sel.Name]; ok && f.TypeCondition == obj.Name {
+					collect(f.SelectionSet)
+				}
+			case *InlineFragment:
+				if !e.included(sel.Directives) || (sel.TypeCondition != "" && sel.TypeCondition != obj.Name) {
+					continue
+				}
+				collect(sel.SelectionSet)
+			}
+		}
+	}
+	collect(sels)
+	return fields
+}
+
+// includedは@skip(if: true)と@include(if: false)が付いていないかを確認する
+func (e *executor) included(directives []*Directive) bool {
+	for _, d := range directives {
+		if len(d.Arguments) == 0 {
+			continue
+		}
+		v, _, _ := valueFromAST(d.Arguments[0].Value, e.vars)
+		cond, _ := v.(bool)
+		if (d.Name == "skip" && cond) || (d.Name == "include" && !cond) {
+			return false
+		}
+	}
+	return true
+}
+
+// coerceArgumentsはフィールドの引数のリテラルと変数を、引数の定義に合わせて変換する
+func (e *executor) coerceArguments(def *Field, node *FieldNode) (map[string]interface{}, *Error) {
+	values := map[string]interface{}{}
+	for _, arg := range node.Arguments {
+		v, ok, err := valueFromAST(arg.Value, e.vars)
+		if err != nil {
+			return nil, errorAt(arg.Loc, "Argument %q has invalid value %s: %s", arg.Name, arg.Value, err)
+		}
+		if ok {
+			values[arg.Name] = v
+		}
+	}
+	args, err := coerceArgumentMap(def.Args, values, fmt.Sprintf("field %q", node.Name))
+	if err != nil {
+		return nil, errorAt(node.Loc, "%s", err)
+	}
+	return args, nil
+}
+
+func (e *executor) addError(err error, loc Location, path []interface{}) {
+	msg := err.Error()
+	e.errors = append(e.errors, &Error{Message: msg, Locations: []Location{loc}, Path: path})
+}
+
+// executeObjectsはsourcesの全てのオブジェクトについて、objの型として選択を実行する
+// 結果はsourcesと同じ順番で、フィールドごとにBatchかResolveで全てのオブジェクトの値をまとめて解決します。
+func (e *executor) executeObjects(obj *Object, sources []interface{}, sels []Selection, paths [][]interface{}) []result {
+	results := make([]result, len(sources))
+	objects := make([]*objectResult, len(sources))
+	for i := range objects {
+		objects[i] = &objectResult{values: map[string]interface{}{}}
+	}
+	if len(sources) == 0 {
+		return results
+	}
+	for _, field := range e.collectFields(obj, sels) {
+		fieldPaths := make([][]interface{}, len(sources))
+		for i := range paths {
+			fieldPaths[i] = appendPath(paths[i], field.key)
+		}
+		if field.name == "__typename" {
+			for i := range objects {
+				objects[i].set(field.key, obj.Name)
+			}
+			continue
+		}
+		def := obj.Fields[field.name]
+		values, failed := e.resolveField(obj, def, field, sources, fieldPaths)
+		completed := e.completeValues(def.Type, values, failed, field.nodes, fieldPaths)
+		for i, r := range completed {
+			r = settle(def.Type, r)
+			if r.invalid {
+				results[i].invalid = true
+			}
+			objects[i].set(field.key, r.value)
+		}
+	}
+	for i := range results {
+		if !results[i].invalid {
+			results[i].value = objects[i]
+		}
+	}
+	return results
+}
+
+// resolveFieldはsourcesの全てのオブジェクトについて、フィールドの値を解決する
+// failedはエラーになった(エラーは記録済みの)位置です。
+func (e *executor) resolveField(obj *Object, def *Field, field *collectedField, sources []interface{}, paths [][]interface{}) ([]interface{}, []bool) {
+	node := field.nodes[0]
+	values := make([]interface{}, len(sources))
+	failed := make([]bool, len(sources))
+	failAll := func(err error) ([]interface{}, []bool) {
+		for i := range sources {
+			e.addError(err, node.Loc, paths[i])
+			failed[i] = true
+		}
+		return values, failed
+	}
+	args, argErr := e.coerceArguments(def, node)
+	if argErr != nil {
+		return failAll(argErr)
+	}
+	switch {
+	case obj == e.root && e.op.Type == OperationSubscription:
+		for i := range sources {
+			values[i] = e.event
+		}
+	case def.Batch != nil:
+		batch, err := def.Batch(BatchParams{Context: e.ctx, Sources: sources, Args: args})
+		if err != nil {
+			return failAll(err)
+		}
+		if len(batch) != len(sources) {
+			return failAll(fmt.Errorf("batch resolver for %s.%s returned %d values for %d sources", obj.Name, field.name, len(batch), len(sources)))
+		}
+		copy(values, batch)
+	case def.Resolve != nil:
+		for i, source := range sources {
+			v, err := def.Resolve(ResolveParams{Context: e.ctx, Source: source, Args: args})
+			if err != nil {
+				e.addError(err, node.Loc, paths[i])
+				failed[i] = true
+				continue
+			}
+			values[i] = v
+		}
+	default:
+		for i, source := range sources {
+			values[i] = defaultResolve(source, field.name)
+		}
+	}
+	return values, failed
+}
+
+// settleはオブジェクトのフィールドとリストの要素の位置で、nullにできる型ならinvalidをnullにする
+func settle(t Type, r result) result {
+	if _, ok := t.(*NonNull); !ok && r.invalid {
+		return result{errored: true}
+	}
+	return r
+}
+
+// completeValuesはリゾルバーが返した値を型に合わせてレスポンスの値にする
+// オブジェクトとリストの要素は、全ての値についてまとめて次の階層の選択を実行します。
+func (e *executor) completeValues(t Type, values []interface{}, failed []bool, nodes []*FieldNode, paths [][]interface{}) []result {
+	results := make([]result, len(values))
+	if nn, ok := t.(*NonNull); ok {
+		inner := e.completeValues(nn.OfType, values, failed, nodes, paths)
+		for i, r := range inner {
+			if r.invalid {
+				results[i] = r
+				continue
+			}
+			if r.value == nil {
+				if !r.errored && !failed[i] {
+					e.addError(fmt.Errorf("Cannot return null for non-nullable field %s.", nodes[0].Name), nodes[0].Loc, paths[i])
+				}
+				results[i] = result{invalid: true, errored: true}
+				continue
+			}
+			results[i] = r
+		}
+		return results
+	}
+	// nullの値とエラーになった値以外を、次の階層にまとめて渡します。
+	present := []int{}
+	for i, v := range values {
+		if failed[i] {
+			results[i] = result{errored: true}
+			continue
+		}
+		if v = deref(v); v == nil {
+			continue
+		}
+		values[i] = v
+		present = append(present, i)
+	}
+	switch t := t.(type) {
+	case *Scalar:
+		for _, i := range present {
+			v, err := t.Serialize(values[i])
+			if err != nil {
+				e.addError(err, nodes[0].Loc, paths[i])
+				results[i] = result{errored: true}
+				continue
+			}
+			results[i] = result{value: v}
+		}
+	case *Enum:
+		for _, i := range present {
+			s, ok := values[i].(string)
+			if !ok || !t.has(s) {
+				e.addError(fmt.Errorf("Enum %q cannot represent value: %v", t.Name, values[i]), nodes[0].Loc, paths[i])
+				results[i] = result{errored: true}
+				continue
+			}
+			results[i] = result{value: s}
+		}
+	case *Object:
+		sources := make([]interface{}, len(present))
+		objPaths := make([][]interface{}, len(present))
+		for j, i := range present {
+			sources[j] = values[i]
+			objPaths[j] = paths[i]
+		}
+		sels := []Selection{}
+		for _, n := range nodes {
+			sels = append(sels, n.SelectionSet...)
+		}
+		for j, r := range e.executeObjects(t, sources, sels, objPaths) {
+			results[present[j]] = r
+		}
+	case *List:
+		// 全ての親のリストの要素を1つに並べて次の階層を実行し、親ごとのリストに戻します。
+		items := []interface{}{}
+		itemPaths := [][]interface{}{}
+		owners := []int{}
+		for _, i := range present {
+			rv := reflect.ValueOf(values[i])
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				e.addError(fmt.Errorf("Expected a list for field %s, got %T.", nodes[0].Name, values[i]), nodes[0].Loc, paths[i])
+				results[i] = result{errored: true}
+				continue
+			}
+			results[i] = result{value: make([]interface{}, 0, rv.Len())}
+			for k := 0; k < rv.Len(); k++ {
+				items = append(items, rv.Index(k).Interface())
+				itemPaths = append(itemPaths, appendPath(paths[i], k))
+				owners = append(owners, i)
+			}
+		}
+		completed := e.completeValues(t.OfType, items, make([]bool, len(items)), nodes, itemPaths)
+		for k, r := range completed {
+			owner := owners[k]
+			r = settle(t.OfType, r)
+			if r.invalid {
+				results[owner] = result{invalid: true, errored: true}
+				continue
+			}
+			if results[owner].invalid {
+				continue
+			}
+			results[owner].value = append(results[owner].value.([]interface{}), r.value)
+		}
+	}
+	return results
+}
+
+// derefはポインターを外した値を返す(nilのポインターはnil)
+func deref(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	// オブジェクトの構造体はリゾルバーに元の型のまま渡すので、ポインターを外すのはスカラーの値だけです。
+	if rv.Kind() == reflect.Struct && rv.Type().PkgPath() != "time" {
+		return v
+	}
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map) && rv.IsNil() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// defaultResolveは親のオブジェクトから、フィールドの名前の値を取り出す
+// mapの場合はフィールドの名前のキー、構造体の場合はフィールドの名前をスネークケースにしたjsonタグ(例: dueDate → due_date)の値です。
+func defaultResolve(source interface{}, name string) interface{} {
+	if m, ok := source.(map[string]interface{}); ok {
+		return m[name]
+	}
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	tag := snakeCase(name)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+		if jsonName == tag || (jsonName == "" && strings.EqualFold(f.Name, name)) {
+			return rv.Field(i).Interface()
+		}
+	}
+	return nil
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// appendPathはpathの末尾にkeyを足した新しいスライスを返す(他の位置のパスと配列を共有しないようにします)
+func appendPath(path []interface{}, key interface{}) []interface{} {
+	p := make([]interface{}, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, key)
+}
@@ -0,0 +1,533 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// テストのスキーマで使うデータ(リゾルバーを書かないフィールドはjsonタグから取り出します)
+type testTask struct {
+	ID        uint       `json:"id"`
+	Title     string     `json:"title"`
+	Done      bool       `json:"done"`
+	Priority  string     `json:"priority"`
+	DueDate   *time.Time `json:"due_date"`
+	ProjectId uint       `json:"project_id"`
+	Subtasks  []testTask `json:"subtasks"`
+}
+
+type testProject struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	OwnerId uint   `json:"owner_id"`
+}
+
+var (
+	testDue   = time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	testTasks = []testTask{
+		{ID: 1, Title: "Buy milk", Priority: "HIGH", DueDate: &testDue, ProjectId: 10, Subtasks: []testTask{{ID: 4, Title: "Skim", Priority: "LOW"}}},
+		{ID: 2, Title: "Write report", Done: true, Priority: "LOW", ProjectId: 20},
+		{ID: 3, Title: "Call mom", Priority: "NORMAL", ProjectId: 10},
+	}
+	testProjects = map[uint]*testProject{10: {ID: 10, Name: "Home", OwnerId: 100}, 20: {ID: 20, Name: "Work", OwnerId: 101}}
+	testUsers    = map[uint]string{100: "Ann", 101: "Bob"}
+)
+
+// testSchemaはテストのスキーマと、Batchのリゾルバーが呼ばれた回数
+type testSchema struct {
+	*Schema
+	projectBatches [][]uint
+	ownerBatches   int
+	events         chan interface{}
+}
+
+func newTestSchema() *testSchema {
+	ts := &testSchema{events: make(chan interface{})}
+	priority := &Enum{Name: "Priority", Values: []string{"LOW", "NORMAL", "HIGH"}}
+	user := &Object{Name: "User", Fields: map[string]*Field{
+		"name": {Type: NewNonNull(String)},
+	}}
+	project := &Object{Name: "Project", Fields: map[string]*Field{
+		"id":   {Type: NewNonNull(ID)},
+		"name": {Type: NewNonNull(String)},
+		"owner": {Type: user, Batch: func(p BatchParams) ([]interface{}, error) {
+			ts.ownerBatches++
+			out := make([]interface{}, len(p.Sources))
+			for i, s := range p.Sources {
+				out[i] = map[string]interface{}{"name": testUsers[s.(*testProject).OwnerId]}
+			}
+			return out, nil
+		}},
+	}}
+	task := &Object{Name: "Task"}
+	task.Fields = map[string]*Field{
+		"id":       {Type: NewNonNull(ID)},
+		"title":    {Type: NewNonNull(String)},
+		"done":     {Type: NewNonNull(Boolean)},
+		"priority": {Type: priority},
+		"dueDate":  {Type: Time},
+		"subtasks": {Type: NewList(NewNonNull(task))},
+		"project": {Type: project, Batch: func(p BatchParams) ([]interface{}, error) {
+			ids := []uint{}
+			out := make([]interface{}, len(p.Sources))
+			for i, s := range p.Sources {
+				id := s.(testTask).ProjectId
+				ids = append(ids, id)
+				if project, ok := testProjects[id]; ok {
+					out[i] = project
+				}
+			}
+			ts.projectBatches = append(ts.projectBatches, ids)
+			return out, nil
+		}},
+		// brokenはID 2のタスクでnullを返す(nullにならないフィールドのエラー)
+		"broken": {Type: NewNonNull(String), Resolve: func(p ResolveParams) (interface{}, error) {
+			if t := p.Source.(testTask); t.ID != 2 {
+				return t.Title, nil
+			}
+			return nil, nil
+		}},
+		"failing": {Type: String, Resolve: func(p ResolveParams) (interface{}, error) {
+			return nil, errors.New("something went wrong")
+		}},
+		// shortBatchは親の数と違う数の値を返す
+		"shortBatch": {Type: String, Batch: func(p BatchParams) ([]interface{}, error) {
+			return []interface{}{"x"}, nil
+		}},
+	}
+	filter := &InputObject{Name: "TaskFilter", Fields: map[string]*Argument{
+		"titleContains": {Type: String},
+		"priorities":    {Type: NewList(NewNonNull(priority))},
+	}}
+	ts.Schema = &Schema{
+		Query: &Object{Name: "Query", Fields: map[string]*Field{
+			"task": {
+				Type: task,
+				Args: map[string]*Argument{"id": {Type: NewNonNull(ID)}},
+				Resolve: func(p ResolveParams) (interface{}, error) {
+					for _, t := range testTasks {
+						if s, _ := ID.Serialize(t.ID); s == p.Args["id"] {
+							return t, nil
+						}
+					}
+					return nil, nil
+				},
+			},
+			"tasks": {
+				Type: NewNonNull(NewList(NewNonNull(task))),
+				Args: map[string]*Argument{
+					"done":   {Type: Boolean},
+					"first":  {Type: Int, Default: 10},
+					"filter": {Type: filter},
+				},
+				Resolve: func(p ResolveParams) (interface{}, error) {
+					out := []testTask{}
+					for _, t := range testTasks {
+						if done, ok := p.Args["done"].(bool); ok && t.Done != done {
+							continue
+						}
+						if f, ok := p.Args["filter"].(map[string]interface{}); ok {
+							if s, ok := f["titleContains"].(string); ok && !strings.Contains(t.Title, s) {
+								continue
+							}
+							if ps, ok := f["priorities"].([]interface{}); ok {
+								match := false
+								for _, p := range ps {
+									match = match || p == t.Priority
+								}
+								if !match {
+									continue
+								}
+							}
+						}
+						out = append(out, t)
+					}
+					if first := p.Args["first"].(int); len(out) > first {
+						out = out[:first]
+					}
+					return out, nil
+				},
+			},
+			"echo": {
+				Type:    JSON,
+				Args:    map[string]*Argument{"value": {Type: JSON}},
+				Resolve: func(p ResolveParams) (interface{}, error) { return p.Args["value"], nil },
+			},
+		}},
+		Subscription: &Object{Name: "Subscription", Fields: map[string]*Field{
+			"taskDone": {
+				Type: NewNonNull(task),
+				Subscribe: func(p ResolveParams) (<-chan interface{}, error) {
+					out := make(chan interface{})
+					go func() {
+						defer close(out)
+						for {
+							select {
+							case event, ok := <-ts.events:
+								if !ok {
+									return
+								}
+								select {
+								case out <- event:
+								case <-p.Context.Done():
+									return
+								}
+							case <-p.Context.Done():
+								return
+							}
+						}
+					}()
+					return out, nil
+				},
+			},
+			"failingSubscription": {
+				Type: String,
+				Subscribe: func(p ResolveParams) (<-chan interface{}, error) {
+					return nil, errors.New("not allowed")
+				},
+			},
+		}},
+		MaxDepth:      4,
+		MaxComplexity: 300,
+	}
+	return ts
+}
+
+func marshalResponse(t *testing.T, res *Response) string {
+	t.Helper()
+	b, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{"fields in selection order", `{ task(id: 1) { title id } }`,
+			nil, `{"data":{"task":{"title":"Buy milk","id":"1"}}}`},
+		{"alias and typename", `{ first: task(id: 1) { __typename name: title } second: task(id: "2") { title } }`,
+			nil, `{"data":{"first":{"__typename":"Task","name":"Buy milk"},"second":{"title":"Write report"}}}`},
+		{"not found", `{ task(id: 9) { id } }`, nil, `{"data":{"task":null}}`},
+		{"json tag, time and enum", `{ task(id: 1) { dueDate priority done } }`,
+			nil, `{"data":{"task":{"dueDate":"2024-03-10T09:00:00Z","priority":"HIGH","done":false}}}`},
+		{"nil pointer", `{ task(id: 2) { dueDate } }`, nil, `{"data":{"task":{"dueDate":null}}}`},
+		{"nested list", `{ tasks(first: 1) { subtasks { id title } } }`,
+			nil, `{"data":{"tasks":[{"subtasks":[{"id":"4","title":"Skim"}]}]}}`},
+		{"argument default", `{ tasks { id } }`, nil, `{"data":{"tasks":[{"id":"1"},{"id":"2"},{"id":"3"}]}}`},
+		{"variables", `query ($done: Boolean, $first: Int) { tasks(done: $done, first: $first) { id } }`,
+			map[string]interface{}{"done": false, "first": 1.0}, `{"data":{"tasks":[{"id":"1"}]}}`},
+		{"variable default", `query ($done: Boolean = true) { tasks(done: $done) { id } }`,
+			nil, `{"data":{"tasks":[{"id":"2"}]}}`},
+		{"missing optional variable uses argument default", `query ($first: Int) { tasks(first: $first) { id } }`,
+			nil, `{"data":{"tasks":[{"id":"1"},{"id":"2"},{"id":"3"}]}}`},
+		{"id variable as number", `query ($id: ID!) { task(id: $id) { title } }`,
+			map[string]interface{}{"id": 3.0}, `{"data":{"task":{"title":"Call mom"}}}`},
+		{"input object and enum list", `{ tasks(filter: {priorities: [LOW, NORMAL]}) { id } }`,
+			nil, `{"data":{"tasks":[{"id":"2"},{"id":"3"}]}}`},
+		{"input object variable", `query ($f: TaskFilter) { tasks(filter: $f) { id } }`,
+			map[string]interface{}{"f": map[string]interface{}{"titleContains": "mom", "priorities": "NORMAL"}},
+			`{"data":{"tasks":[{"id":"3"}]}}`},
+		{"json argument", `{ echo(value: {a: [1, "b", true, null, C]}) }`,
+			nil, `{"data":{"echo":{"a":[1,"b",true,null,"C"]}}}`},
+		{"named fragment", `{ task(id: 1) { ...Basic project { ...ProjectName } } }
+			fragment Basic on Task { id title }
+			fragment ProjectName on Project { name }`,
+			nil, `{"data":{"task":{"id":"1","title":"Buy milk","project":{"name":"Home"}}}}`},
+		{"fragment used twice", `{ task(id: 1) { ...Basic ...Basic } } fragment Basic on Task { id }`,
+			nil, `{"data":{"task":{"id":"1"}}}`},
+		{"inline fragment", `{ task(id: 1) { ... on Task { id } ... { title } } }`,
+			nil, `{"data":{"task":{"id":"1","title":"Buy milk"}}}`},
+		{"merged selections", `{ task(id: 1) { project { id } title project { name } } }`,
+			nil, `{"data":{"task":{"project":{"id":"10","name":"Home"},"title":"Buy milk"}}}`},
+		{"skip and include", `query ($yes: Boolean!) { task(id: 1) { id @skip(if: $yes) title @include(if: $yes) ...F @skip(if: true) } }
+			fragment F on Task { done }`,
+			map[string]interface{}{"yes": true}, `{"data":{"task":{"title":"Buy milk"}}}`},
+		{"skipped inline fragment", `{ task(id: 1) { id ... @include(if: false) { title } } }`,
+			nil, `{"data":{"task":{"id":"1"}}}`},
+		{"resolver error", `{ task(id: 1) { id failing } }`,
+			nil, `{"data":{"task":{"id":"1","failing":null}},"errors":[{"message":"something went wrong","locations":[{"line":1,"column":20}],"path":["task","failing"]}]}`},
+		{"null bubbles to nullable parent", `{ task(id: 2) { id broken } }`,
+			nil, `{"data":{"task":null},"errors":[{"message":"Cannot return null for non-nullable field broken.","locations":[{"line":1,"column":20}],"path":["task","broken"]}]}`},
+		{"null bubbles to data", `{ tasks { id broken } }`,
+			nil, `{"data":null,"errors":[{"message":"Cannot return null for non-nullable field broken.","locations":[{"line":1,"column":14}],"path":["tasks",1,"broken"]}]}`},
+		{"null in list", `{ a: task(id: 1) { id } tasks { broken } b: task(id: 3) { id } }`,
+			nil, `{"data":null,"errors":[{"message":"Cannot return null for non-nullable field broken.","locations":[{"line":1,"column":33}],"path":["tasks",1,"broken"]}]}`},
+		{"batch returns too few values", `{ tasks(done: false) { id shortBatch } }`,
+			nil, `{"data":{"tasks":[{"id":"1","shortBatch":null},{"id":"3","shortBatch":null}]},"errors":[` +
+				`{"message":"batch resolver for Task.shortBatch returned 1 values for 2 sources","locations":[{"line":1,"column":27}],"path":["tasks",0,"shortBatch"]},` +
+				`{"message":"batch resolver for Task.shortBatch returned 1 values for 2 sources","locations":[{"line":1,"column":27}],"path":["tasks",1,"shortBatch"]}]}`},
+		{"operation name", `query A { task(id: 1) { id } } query B { task(id: 2) { id } }`,
+			nil, `{"data":{"task":{"id":"2"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{Query: tt.query, Variables: tt.vars}
+			if tt.name == "operation name" {
+				req.OperationName = "B"
+			}
+			res := newTestSchema().Execute(context.Background(), req)
+			if !res.Executed() {
+				t.Errorf("Executed() = false")
+			}
+			if got := marshalResponse(t, res); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		msg   string
+		loc   *Location
+	}{
+		{"syntax error", "{ task(id: 1) { id }", nil, "Syntax Error: Unexpected <EOF>.", &Location{1, 21}},
+		{"unknown field", "{ task(id: 1) { id owner } }", nil, `Cannot query field "owner" on type "Task".`, &Location{1, 20}},
+		{"unknown argument", "{ tasks(limit: 1) { id } }", nil, `Unknown argument "limit" on field "Query.tasks".`, &Location{1, 9}},
+		{"missing required argument", "{ task { id } }", nil, `Field "task" argument "id" of type "ID!" is required, but it was not provided.`, &Location{1, 3}},
+		{"selection on scalar", "{ task(id: 1) { id { x } } }", nil, `Field "id" must not have a selection since type "ID!" has no subfields.`, &Location{1, 17}},
+		{"missing selection on object", "{ task(id: 1) }", nil, `Field "task" of type "Task" must have a selection of subfields.`, &Location{1, 3}},
+		{"typename with selection", "{ __typename { x } }", nil, `Field "__typename" must not have a selection since type "String!" has no subfields.`, &Location{1, 3}},
+		{"unknown fragment", "{ task(id: 1) { ...Missing } }", nil, `Unknown fragment "Missing".`, &Location{1, 17}},
+		{"fragment cycle", "{ task(id: 1) { ...A } } fragment A on Task { subtasks { ...A } }", nil, `Cannot spread fragment "A" within itself.`, &Location{1, 58}},
+		{"fragment type mismatch", "{ task(id: 1) { ...P } } fragment P on Project { name }", nil,
+			`Fragment cannot be spread here as objects of type "Task" can never be of type "Project".`, &Location{1, 17}},
+		{"inline fragment type mismatch", "{ task(id: 1) { ... on Project { name } } }", nil,
+			`Fragment cannot be spread here as objects of type "Task" can never be of type "Project".`, &Location{1, 17}},
+		{"unknown directive", "{ task(id: 1) { id @deprecated } }", nil, `Unknown directive "@deprecated".`, &Location{1, 20}},
+		{"directive without if", "{ task(id: 1) { id @skip } }", nil, `Directive "@skip" argument "if" of type "Boolean!" is required.`, &Location{1, 20}},
+		{"missing required variable", "query ($id: ID!) { task(id: $id) { id } }", nil, `Variable "$id" of required type "ID!" was not provided.`, &Location{1, 8}},
+		{"invalid variable", "query ($first: Int) { tasks(first: $first) { id } }", map[string]interface{}{"first": "ten"},
+			`Variable "$first" got invalid value "ten"; Int cannot represent non-integer value: "ten"`, &Location{1, 8}},
+		{"invalid enum variable", "query ($f: TaskFilter) { tasks(filter: $f) { id } }",
+			map[string]interface{}{"f": map[string]interface{}{"priorities": []interface{}{"URGENT"}}},
+			`Variable "$f" got invalid value {"priorities":["URGENT"]}; "priorities": In element #0: Value "URGENT" does not exist in "Priority" enum.`, &Location{1, 8}},
+		{"unknown input field", "query ($f: TaskFilter) { tasks(filter: $f) { id } }",
+			map[string]interface{}{"f": map[string]interface{}{"owner": "me"}},
+			`Variable "$f" got invalid value {"owner":"me"}; Field "owner" is not defined by type "TaskFilter".`, &Location{1, 8}},
+		{"unknown variable type", "query ($id: TaskId) { task(id: $id) { id } }", nil, `Unknown type "TaskId".`, &Location{1, 8}},
+		{"multiple operations", "query A { tasks { id } } query B { tasks { id } }", nil, "Must provide operation name if query contains multiple operations.", nil},
+		{"no mutation type", "mutation { tasks { id } }", nil, "Schema is not configured for mutations.", &Location{1, 1}},
+		{"subscription over http", "subscription { taskDone { id } }", nil, "Subscriptions are only available over WebSocket.", &Location{1, 1}},
+		{"too deep", "{ task(id: 1) { subtasks { subtasks { subtasks { id } } } } }", nil,
+			"Query is too deep: depth 5 exceeds the maximum of 4.", &Location{1, 1}},
+		{"too deep through fragment", "query Deep { task(id: 1) { ...S } } fragment S on Task { subtasks { subtasks { subtasks { id } } } }", nil,
+			"Query is too deep: depth 5 exceeds the maximum of 4.", &Location{1, 1}},
+		// リストのフィールドの下は10倍に数えます: 1 + (1 + (1 + 1 * 10) * 10) * 10 = 1111
+		{"too complex", "{ tasks { subtasks { subtasks { id } } } }", nil,
+			"Query is too complex: complexity 1111 exceeds the maximum of 300.", &Location{1, 1}},
+		{"too complex with aliases", "{ a: tasks { id title } b: tasks { id title } c: tasks { id title } d: tasks { id title } e: tasks { id title } f: tasks { id title } g: tasks { id title } h: tasks { id title } i: tasks { id title } j: tasks { id title } k: tasks { id title } l: tasks { id title } m: tasks { id title } n: tasks { id title } o: tasks { id title } }", nil,
+			"Query is too complex: complexity 315 exceeds the maximum of 300.", &Location{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := newTestSchema().Execute(context.Background(), Request{Query: tt.query, Variables: tt.vars})
+			if res.Executed() {
+				t.Errorf("Executed() = true, want false")
+			}
+			if len(res.Errors) != 1 {
+				t.Fatalf("got errors %s, want 1 error", marshalResponse(t, res))
+			}
+			err := res.Errors[0]
+			if err.Message != tt.msg {
+				t.Errorf("got message %q\nwant        %q", err.Message, tt.msg)
+			}
+			var want []Location
+			if tt.loc != nil {
+				want = []Location{*tt.loc}
+			}
+			if !reflect.DeepEqual(err.Locations, want) {
+				t.Errorf("got locations %v, want %v", err.Locations, want)
+			}
+			// 実行していない場合はdataを含めません。
+			if got := marshalResponse(t, res); strings.Contains(got, `"data"`) {
+				t.Errorf("response has data: %s", got)
+			}
+		})
+	}
+}
+
+func TestExecuteWithinLimits(t *testing.T) {
+	// 複雑さは1 + (1 + (1 + 1)) * 10 = 31で、深さも4なので上限の中です。
+	res := newTestSchema().Execute(context.Background(), Request{Query: "{ tasks { project { owner { name } } } }"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors: %s", marshalResponse(t, res))
+	}
+	// 上限が0の場合は制限しません。
+	ts := newTestSchema()
+	ts.MaxDepth, ts.MaxComplexity = 0, 0
+	res = ts.Execute(context.Background(), Request{Query: "{ tasks { subtasks { subtasks { subtasks { subtasks { id } } } } } }"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors without limits: %s", marshalResponse(t, res))
+	}
+}
+
+func TestBatchResolver(t *testing.T) {
+	ts := newTestSchema()
+	res := ts.Execute(context.Background(), Request{Query: `{
+		tasks { id project { name owner { name } } subtasks { project { name } } }
+		task(id: 2) { project { name } }
+	}`})
+	want := `{"data":{"tasks":[` +
+		`{"id":"1","project":{"name":"Home","owner":{"name":"Ann"}},"subtasks":[{"project":null}]},` +
+		`{"id":"2","project":{"name":"Work","owner":{"name":"Bob"}},"subtasks":null},` +
+		`{"id":"3","project":{"name":"Home","owner":{"name":"Ann"}},"subtasks":null}]` +
+		`,"task":{"project":{"name":"Work"}}}}`
+	if got := marshalResponse(t, res); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	// 同じ階層の親は1回のBatchでまとめて解決します(ルートのフィールドごと・階層ごとに1回)。
+	wantBatches := [][]uint{{10, 20, 10}, {0}, {20}}
+	if !reflect.DeepEqual(ts.projectBatches, wantBatches) {
+		t.Errorf("project batches = %v, want %v", ts.projectBatches, wantBatches)
+	}
+	if ts.ownerBatches != 1 {
+		t.Errorf("owner batches = %d, want 1", ts.ownerBatches)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	calls := [][]uint{}
+	loader := NewLoader(func(ctx context.Context, keys []uint) (map[uint]interface{}, error) {
+		calls = append(calls, keys)
+		values := map[uint]interface{}{}
+		for _, key := range keys {
+			if key != 3 {
+				values[key] = key * 10
+			}
+		}
+		return values, nil
+	})
+	ctx := context.Background()
+	got, err := loader.LoadMany(ctx, []uint{1, 2, 1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{uint(10), uint(20), uint(10), nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadMany = %v, want %v", got, want)
+	}
+	// 取得済みのID(見つからなかったIDも含む)は取得し直しません。
+	if got, _ = loader.LoadMany(ctx, []uint{3, 2, 4}); !reflect.DeepEqual(got, []interface{}{nil, uint(20), uint(40)}) {
+		t.Errorf("LoadMany = %v", got)
+	}
+	loader.Clear()
+	loader.LoadMany(ctx, []uint{1})
+	if want := [][]uint{{1, 2, 3}, {4}, {1}}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("fetch calls = %v, want %v", calls, want)
+	}
+
+	failing := NewLoader(func(ctx context.Context, keys []uint) (map[uint]interface{}, error) {
+		return nil, errors.New("db down")
+	})
+	if _, err := failing.LoadMany(ctx, []uint{1}); err == nil || err.Error() != "db down" {
+		t.Errorf("LoadMany error = %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ts := newTestSchema()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out, errRes := ts.Subscribe(ctx, Request{
+		Query:     `subscription ($withTitle: Boolean!) { taskDone { id title @include(if: $withTitle) project { name } broken } }`,
+		Variables: map[string]interface{}{"withTitle": true},
+	})
+	if errRes != nil {
+		t.Fatalf("Subscribe: %s", marshalResponse(t, errRes))
+	}
+	// イベントごとに選択を実行して、エラーはイベントごとに数え直します。
+	wants := []string{
+		`{"data":null,"errors":[{"message":"Cannot return null for non-nullable field broken.","locations":[{"line":1,"column":101}],"path":["taskDone","broken"]}]}`,
+		`{"data":{"taskDone":{"id":"1","title":"Buy milk","project":{"name":"Home"},"broken":"Buy milk"}}}`,
+	}
+	for i, event := range []testTask{testTasks[1], testTasks[0]} {
+		ts.events <- event
+		res := <-out
+		if got := marshalResponse(t, res); got != wants[i] {
+			t.Errorf("event %d:\n got  %s\n want %s", i, got, wants[i])
+		}
+	}
+	// イベントのチャンネルが閉じたら、結果のチャンネルも閉じます。
+	close(ts.events)
+	if _, ok := <-out; ok {
+		t.Error("response channel is not closed after the events channel was closed")
+	}
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	ts := newTestSchema()
+	ctx, cancel := context.WithCancel(context.Background())
+	out, errRes := ts.Subscribe(ctx, Request{Query: `subscription { taskDone { id } }`})
+	if errRes != nil {
+		t.Fatalf("Subscribe: %s", marshalResponse(t, errRes))
+	}
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Error("got a response after the context was canceled")
+		}
+	case <-time.After(time.Second):
+		t.Error("response channel is not closed after the context was canceled")
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"query", `{ tasks { id } }`, `{"errors":[{"message":"Operation is not a subscription.","locations":[{"line":1,"column":1}]}]}`},
+		{"two root fields", `subscription { taskDone { id } failingSubscription }`,
+			`{"errors":[{"message":"Subscription must select only one top level field.","locations":[{"line":1,"column":1}]}]}`},
+		{"subscribe error", `subscription { failingSubscription }`,
+			`{"errors":[{"message":"not allowed","locations":[{"line":1,"column":16}],"path":["failingSubscription"]}]}`},
+		{"validation error", `subscription { taskDone { owner } }`,
+			`{"errors":[{"message":"Cannot query field \"owner\" on type \"Task\".","locations":[{"line":1,"column":27}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, res := newTestSchema().Subscribe(context.Background(), Request{Query: tt.query})
+			if out != nil || res == nil {
+				t.Fatalf("Subscribe(%q) started a subscription", tt.query)
+			}
+			if got := marshalResponse(t, res); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperationType(t *testing.T) {
+	s := newTestSchema()
+	tests := []struct {
+		req  Request
+		want string
+	}{
+		{Request{Query: "{ tasks { id } }"}, OperationQuery},
+		{Request{Query: "subscription S { taskDone { id } }"}, OperationSubscription},
+		{Request{Query: "query A { a } mutation B { b }", OperationName: "B"}, OperationMutation},
+		{Request{Query: "query A { a }", OperationName: "C"}, ""},
+		{Request{Query: "{"}, ""},
+	}
+	for _, tt := range tests {
+		if got := s.OperationType(tt.req); got != tt.want {
+			t.Errorf("OperationType(%q, %q) = %q, want %q", tt.req.Query, tt.req.OperationName, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// graphqlは/graphqlのエンドポイントのための小さなGraphQLの実装
+// リクエストのドキュメントをパースして、Goのコードで定義したスキーマ(Object・Field)のリゾルバーで実行します。
+//
+// 同じ階層のフィールドは、親のオブジェクト全てについてまとめて解決します。
+// FieldにBatchを定義すると、一覧の全てのタスクのプロジェクトなどを1回の呼び出しで取得できるので、N+1のクエリになりません。
+// (Loaderは同じリクエストの中で、IDで取得した結果をキャッシュしてまとめて取得するためのものです。)
+//
+// 対応していないもの: インターフェース・ユニオン・イントロスペクション(__typename以外)・スキーマの定義言語
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Locationはエラーの箇所(1から始まる行と列)
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// ErrorはGraphQLのレスポンスのerrorsの1件
+// Pathはエラーになったフィールドのレスポンスの中の位置(フィールドのキーとリストの番号)です。
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func errorAt(loc Location, format string, args ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}}
+}
+
+// Requestは/graphqlで受け取るリクエスト
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Responseは実行の結果
+// パースや検証でエラーになった場合は実行していないので、dataを含めません。
+type Response struct {
+	Data   interface{}
+	Errors []*Error
+	// executedは実行したかどうか(実行した場合はdataがnullでも含めます)
+	executed bool
+}
+
+func (r *Response) MarshalJSON() ([]byte, error) {
+	res := map[string]interface{}{}
+	if r.executed {
+		res["data"] = r.Data
+	}
+	if len(r.Errors) > 0 {
+		res["errors"] = r.Errors
+	}
+	return json.Marshal(res)
+}
+
+// Executedは実行まで進んだかどうかを返す(falseの場合はパースか検証のエラーです)
+func (r *Response) Executed() bool {
+	return r.executed
+}
+
+func errorResponse(errs ...*Error) *Response {
+	return &Response{Errors: errs}
+}
+
+// TypeはスキーマのScalar・Enum・Object・InputObject・List・NonNullのいずれか
+type Type interface {
+	String() string
+}
+
+// ListはOfTypeのリスト
+type List struct {
+	OfType Type
+}
+
+// NonNullはnullにならないOfType
+type NonNull struct {
+	OfType Type
+}
+
+func (l *List) String() string    { return "[" + l.OfType.String() + "]" }
+func (n *NonNull) String() string { return n.OfType.String() + "!" }
+
+// NewListはOfTypeのリストの型を返す
+func NewList(t Type) Type {
+	return &List{t}
+}
+
+// NewNonNullはnullにならない型を返す
+func NewNonNull(t Type) Type {
+	return &NonNull{t}
+}
+
+// Objectはフィールドを持つ出力の型
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+// Fieldはオブジェクトのフィールドの定義
+// ResolveとBatchのどちらも無い場合は、親のオブジェクト(構造体かmap)からフィールドの名前の値を取り出します。
+type Field struct {
+	Type Type
+	Args map[string]*Argument
+	// Resolveは親のオブジェクト1つについてフィールドの値を返す
+	Resolve func(p ResolveParams) (interface{}, error)
+	// Batchは同じ階層の親のオブジェクト全てについて、同じ順番でフィールドの値を返す
+	Batch func(p BatchParams) ([]interface{}, error)
+	// Subscribeはサブスクリプションのルートのフィールドで、イベントを送るチャンネルを返す
+	// チャンネルはctxがキャンセルされたら閉じる必要があり、イベントごとにフィールドの値として選択を実行します。
+	Subscribe func(p ResolveParams) (<-chan interface{}, error)
+}
+
+// Argumentはフィールドの引数とInputObjectの項目の定義
+type Argument struct {
+	Type    Type
+	Default interface{}
+}
+
+// InputObjectは引数で受け取るオブジェクトの型(値はmap[string]interface{}になります)
+type InputObject struct {
+	Name   string
+	Fields map[string]*Argument
+}
+
+func (o *InputObject) String() string { return o.Name }
+
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+}
+
+type BatchParams struct {
+	Context context.Context
+	Sources []interface{}
+	Args    map[string]interface{}
+}
+
+// Schemaはルートの型と、リクエストの深さ・複雑さの上限
+type Schema struct {
+	Query        *Object
+	Mutation     *Object
+	Subscription *Object
+	// MaxDepthはフィールドの入れ子の最大の深さ(ルートのフィールドが1、0の場合は制限しない)
+	MaxDepth int
+	// MaxComplexityはフィールドの数の合計の上限(0の場合は制限しない)
+	// リストのフィールドの下のフィールドは、ListSizeの数だけ繰り返されるものとして数えます。
+	MaxComplexity int
+	// ListSizeは複雑さを計算する時のリストの要素の数の見積もり(0の場合は10)
+	ListSize int
+}
+
+func (s *Schema) rootType(opType string) *Object {
+	switch opType {
+	case OperationQuery:
+		return s.Query
+	case OperationMutation:
+		return s.Mutation
+	case OperationSubscription:
+		return s.Subscription
+	}
+	return nil
+}
+
+// prepareはリクエストをパースして、実行する操作を選び、検証して変数を変換する
+func (s *Schema) prepare(req Request) (*executor, *Response) {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		if gqlErr, ok := err.(*Error); ok {
+			return nil, errorResponse(gqlErr)
+		}
+		return nil, errorResponse(&Error{Message: err.Error()})
+	}
+	var op *Operation
+	for _, o := range doc.Operations {
+		if req.OperationName == "" || o.Name == req.OperationName {
+			if op != nil {
+				return nil, errorResponse(&Error{Message: "Must provide operation name if query contains multiple operations."})
+			}
+			op = o
+		}
+	}
+	if op == nil {
+		return nil, errorResponse(&Error{Message: fmt.Sprintf("Unknown operation named %q.", req.OperationName)})
+	}
+	root := s.rootType(op.Type)
+	if root == nil {
+		return nil, errorResponse(errorAt(op.Loc, "Schema is not configured for %ss.", op.Type))
+	}
+	vars, errs := s.coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return nil, errorResponse(errs...)
+	}
+	e := &executor{schema: s, doc: doc, op: op, root: root, vars: vars}
+	if errs := e.validate(); len(errs) > 0 {
+		return nil, errorResponse(errs...)
+	}
+	return e, nil
+}
+
+// OperationTypeはリクエストで実行する操作の種類を返す(パースできない場合は空文字)
+func (s *Schema) OperationType(req Request) string {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return ""
+	}
+	for _, o := range doc.Operations {
+		if req.OperationName == "" || o.Name == req.OperationName {
+			return o.Type
+		}
+	}
+	return ""
+}
+
+// Executeはクエリかミューテーションを実行する(サブスクリプションの場合はエラーを返します)
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	e, res := s.prepare(req)
+	if res != nil {
+		return res
+	}
+	if e.op.Type == OperationSubscription {
+		return errorResponse(errorAt(e.op.Loc, "Subscriptions are only available over WebSocket."))
+	}
+	e.ctx = ctx
+	return e.execute(nil)
+}
+
+// Subscribeはサブスクリプションを開始して、イベントごとの実行の結果を送るチャンネルを返す
+// 開始できない場合は2つ目の返り値にエラーのレスポンスを返します。
+// チャンネルはctxがキャンセルされるか、イベントのチャンネルが閉じたら閉じます。
+func (s *Schema) Subscribe(ctx context.Context, req Request) (<-chan *Response, *Response) {
+	e, res := s.prepare(req)
+	if res != nil {
+		return nil, res
+	}
+	if e.op.Type != OperationSubscription {
+		return nil, errorResponse(errorAt(e.op.Loc, "Operation is not a subscription."))
+	}
+	e.ctx = ctx
+	fields := e.collectFields(e.root, e.op.SelectionSet)
+	if len(fields) != 1 {
+		return nil, errorResponse(errorAt(e.op.Loc, "Subscription must select only one top level field."))
+	}
+	field := fields[0]
+	def := e.root.Fields[field.name]
+	if def == nil || def.Subscribe == nil {
+		return nil, errorResponse(errorAt(field.nodes[0].Loc, "Field %q is not a subscription.", field.name))
+	}
+	args, err := e.coerceArguments(def, field.nodes[0])
+	if err != nil {
+		return nil, errorResponse(err)
+	}
+	events, subErr := def.Subscribe(ResolveParams{Context: ctx, Args: args})
+	if subErr != nil {
+		return nil, errorResponse(&Error{Message: subErr.Error(), Locations: []Location{field.nodes[0].Loc}, Path: []interface{}{field.key}})
+	}
+	out := make(chan *Response)
+	go func() {
+		defer close(out)
+		for event := range events {
+			// イベントごとに新しい実行として、エラーを数え直します。
+			run := *e
+			run.errors = nil
+			select {
+			case out <- run.execute(event):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
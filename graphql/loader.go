@@ -0,0 +1,56 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// LoaderはIDで取得する値を、同じリクエストの中でキャッシュしてまとめて取得する
+// 取得していないIDだけをfetchに1回で渡し、見つからなかったIDはnilとしてキャッシュします。
+type Loader struct {
+	fetch func(ctx context.Context, keys []uint) (map[uint]interface{}, error)
+	mu    sync.Mutex
+	cache map[uint]interface{}
+}
+
+// NewLoaderはfetchで値を取得するLoaderを返す
+// fetchは見つかったIDの値をmapで返します(見つからなかったIDは含めません)。
+func NewLoader(fetch func(ctx context.Context, keys []uint) (map[uint]interface{}, error)) *Loader {
+	return &Loader{fetch: fetch, cache: map[uint]interface{}{}}
+}
+
+// LoadManyはkeysと同じ順番で値を返す(見つからなかったIDはnil)
+func (l *Loader) LoadMany(ctx context.Context, keys []uint) ([]interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	missing := []uint{}
+	seen := map[uint]bool{}
+	for _, key := range keys {
+		if _, ok := l.cache[key]; ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		missing = append(missing, key)
+	}
+	if len(missing) > 0 {
+		values, err := l.fetch(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range missing {
+			l.cache[key] = values[key]
+		}
+	}
+	out := make([]interface{}, len(keys))
+	for i, key := range keys {
+		out[i] = l.cache[key]
+	}
+	return out, nil
+}
+
+// Clearはキャッシュを捨てる(ミューテーションで値を変えた後に使います)
+func (l *Loader) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache = map[uint]interface{}{}
+}
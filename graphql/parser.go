@@ -0,0 +1,839 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parser.goはGraphQLのリクエストのドキュメント(クエリ・ミューテーション・サブスクリプションとフラグメント)をパースする
+// スキーマの定義言語(SDL)はパースせず、スキーマはGoのコードで定義します。
+//
+//	document     = { operation | fragment }
+//	operation    = selectionSet | ("query" | "mutation" | "subscription") [name] [variables] {directive} selectionSet
+//	variables    = "(" { "$" name ":" type ["=" value] } ")"
+//	selectionSet = "{" { field | "..." name {directive} | "..." ["on" name] {directive} selectionSet } "}"
+//	field        = [alias ":"] name [arguments] {directive} [selectionSet]
+//	fragment     = "fragment" name "on" name {directive} selectionSet
+
+// Documentはパースしたリクエストのドキュメント
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// 操作の種類
+const (
+	OperationQuery        = "query"
+	OperationMutation     = "mutation"
+	OperationSubscription = "subscription"
+)
+
+type Operation struct {
+	Type         string
+	Name         string
+	Variables    []*VariableDefinition
+	Directives   []*Directive
+	SelectionSet []Selection
+	Loc          Location
+}
+
+type VariableDefinition struct {
+	Name    string
+	Type    *TypeRef
+	Default *Value
+	Loc     Location
+}
+
+// TypeRefは変数の型(Elemがある場合はリスト)
+type TypeRef struct {
+	Name    string
+	Elem    *TypeRef
+	NonNull bool
+}
+
+func (t *TypeRef) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// SelectionはFieldNode・FragmentSpread・InlineFragmentのいずれか
+type Selection interface {
+	selection()
+}
+
+type FieldNode struct {
+	Alias        string
+	Name         string
+	Arguments    []*ArgumentNode
+	Directives   []*Directive
+	SelectionSet []Selection
+	Loc          Location
+}
+
+// ResponseKeyはレスポンスのキー(別名がある場合は別名)
+func (f *FieldNode) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+	Loc        Location
+}
+
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Loc           Location
+}
+
+func (*FieldNode) selection()      {}
+func (*FragmentSpread) selection() {}
+func (*InlineFragment) selection() {}
+
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Loc           Location
+}
+
+type ArgumentNode struct {
+	Name  string
+	Value *Value
+	Loc   Location
+}
+
+type Directive struct {
+	Name      string
+	Arguments []*ArgumentNode
+	Loc       Location
+}
+
+// 値の種類
+const (
+	KindVariable = "Variable"
+	KindInt      = "Int"
+	KindFloat    = "Float"
+	KindString   = "String"
+	KindBoolean  = "Boolean"
+	KindNull     = "Null"
+	KindEnum     = "Enum"
+	KindList     = "List"
+	KindObject   = "Object"
+)
+
+// Valueは引数や変数の既定値に書かれた値
+// Rawは変数の名前・数値・文字列(エスケープを戻した後)・真偽値・列挙値の名前で、
+// Listはリストの要素、Fieldsはオブジェクトの項目です。
+type Value struct {
+	Kind   string
+	Raw    string
+	List   []*Value
+	Fields []*ObjectField
+	Loc    Location
+}
+
+type ObjectField struct {
+	Name  string
+	Value *Value
+}
+
+// Parseはリクエストのドキュメントをパースする
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: lexer{src: source, line: 1, col: 1}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	doc := &Document{Fragments: map[string]*Fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.tok.is(tokPunct, "{"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.is(tokName, "query"), p.tok.is(tokName, "mutation"), p.tok.is(tokName, "subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.is(tokName, "fragment"):
+			f, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.Fragments[f.Name]; ok {
+				return nil, errorAt(f.Loc, "There can be only one fragment named %q.", f.Name)
+			}
+			doc.Fragments[f.Name] = f
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, errorAt(p.tok.loc, "The document must contain an operation.")
+	}
+	return doc, nil
+}
+
+// トークンの種類
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  int
+	value string
+	loc   Location
+}
+
+func (t token) is(kind int, value string) bool {
+	return t.kind == kind && t.value == value
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "<EOF>"
+	case tokString:
+		return strconv.Quote(t.value)
+	}
+	return t.value
+}
+
+type lexer struct {
+	src       string
+	pos       int
+	line, col int
+}
+
+// advanceはn文字分(バイト数)読み進めて、行と列を数える
+func (l *lexer) advance(n int) {
+	for i := 0; i < n; i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+			l.col = 1
+		} else if l.src[l.pos]&0xC0 != 0x80 {
+			// UTF-8の2バイト目以降は列に数えません。
+			l.col++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) read() (token, error) {
+	// 空白・改行・カンマ・コメントは読み飛ばします。
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.advance(1)
+		} else if strings.HasPrefix(l.src[l.pos:], "\uFEFF") {
+			// 先頭のBOMも空白として扱います。
+			l.advance(len("\uFEFF"))
+		} else if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+		} else {
+			break
+		}
+	}
+	loc := Location{Line: l.line, Column: l.col}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, loc: loc}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.advance(3)
+		return token{kind: tokPunct, value: "...", loc: loc}, nil
+	case strings.IndexByte("!$&()=:@[]{}|", c) >= 0:
+		l.advance(1)
+		return token{kind: tokPunct, value: string(c), loc: loc}, nil
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.advance(1)
+		}
+		return token{kind: tokName, value: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.readNumber(loc)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.readBlockString(loc)
+		}
+		return l.readString(loc)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, errorAt(loc, "Unexpected character %q.", r)
+}
+
+func (l *lexer) readNumber(loc Location) (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.advance(1)
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.advance(1)
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, errorAt(loc, "Invalid number.")
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokFloat
+		l.advance(1)
+		if digits() == 0 {
+			return token{}, errorAt(loc, "Invalid number.")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokFloat
+		l.advance(1)
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.advance(1)
+		}
+		if digits() == 0 {
+			return token{}, errorAt(loc, "Invalid number.")
+		}
+	}
+	// 数値の直後に名前が続く場合(例: 123abc)はエラーにします。
+	if l.pos < len(l.src) && (l.src[l.pos] == '_' || l.src[l.pos] == '.' || isLetter(l.src[l.pos])) {
+		return token{}, errorAt(loc, "Invalid number.")
+	}
+	return token{kind: kind, value: l.src[start:l.pos], loc: loc}, nil
+}
+
+func (l *lexer) readString(loc Location) (token, error) {
+	l.advance(1)
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' || l.src[l.pos] == '\r' {
+			return token{}, errorAt(loc, "Unterminated string.")
+		}
+		c := l.src[l.pos]
+		if c == '"' {
+			l.advance(1)
+			return token{kind: tokString, value: b.String(), loc: loc}, nil
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			l.advance(1)
+			continue
+		}
+		if l.pos+1 >= len(l.src) {
+			return token{}, errorAt(loc, "Unterminated string.")
+		}
+		esc := l.src[l.pos+1]
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if l.pos+6 > len(l.src) {
+				return token{}, errorAt(loc, "Invalid unicode escape.")
+			}
+			n, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+			if err != nil {
+				return token{}, errorAt(loc, "Invalid unicode escape.")
+			}
+			b.WriteRune(rune(n))
+			l.advance(6)
+			continue
+		default:
+			return token{}, errorAt(loc, "Invalid escape sequence \\%c.", esc)
+		}
+		l.advance(2)
+	}
+}
+
+// readBlockStringは"""で囲んだ複数行の文字列を読み込む(共通のインデントと前後の空行は取り除きます)
+func (l *lexer) readBlockString(loc Location) (token, error) {
+	l.advance(3)
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			return token{}, errorAt(loc, "Unterminated string.")
+		}
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			l.advance(3)
+			return token{kind: tokString, value: blockStringValue(b.String()), loc: loc}, nil
+		}
+		if strings.HasPrefix(l.src[l.pos:], `\"""`) {
+			b.WriteString(`"""`)
+			l.advance(4)
+			continue
+		}
+		b.WriteByte(l.src[l.pos])
+		l.advance(1)
+	}
+}
+
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type parser struct {
+	lexer lexer
+	tok   token
+}
+
+func (p *parser) next() error {
+	tok, err := p.lexer.read()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	return errorAt(p.tok.loc, "Syntax Error: Unexpected %s.", p.tok)
+}
+
+// expectは今のトークンがkindとvalueに一致することを確認して読み進める
+func (p *parser) expect(kind int, value string) error {
+	if !p.tok.is(kind, value) {
+		return errorAt(p.tok.loc, "Syntax Error: Expected %q, found %s.", value, p.tok)
+	}
+	return p.next()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.tok.kind != tokName {
+		return "", errorAt(p.tok.loc, "Syntax Error: Expected Name, found %s.", p.tok)
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	op := &Operation{Type: OperationQuery, Loc: p.tok.loc}
+	if p.tok.kind == tokName {
+		op.Type = p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokName {
+			op.Name = p.tok.value
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if p.tok.is(tokPunct, "(") {
+			vars, err := p.parseVariableDefinitions()
+			if err != nil {
+				return nil, err
+			}
+			op.Variables = vars
+		}
+		directives, err := p.parseDirectives(false)
+		if err != nil {
+			return nil, err
+		}
+		op.Directives = directives
+	}
+	sels, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.SelectionSet = sels
+	return op, nil
+}
+
+func (p *parser) parseVariableDefinitions() ([]*VariableDefinition, error) {
+	if err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	vars := []*VariableDefinition{}
+	for !p.tok.is(tokPunct, ")") {
+		v := &VariableDefinition{Loc: p.tok.loc}
+		if err := p.expect(tokPunct, "$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		v.Name = name
+		if err := p.expect(tokPunct, ":"); err != nil {
+			return nil, err
+		}
+		if v.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if p.tok.is(tokPunct, "=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if v.Default, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.parseDirectives(true); err != nil {
+			return nil, err
+		}
+		vars = append(vars, v)
+	}
+	return vars, p.next()
+}
+
+func (p *parser) parseType() (*TypeRef, error) {
+	t := &TypeRef{}
+	if p.tok.is(tokPunct, "[") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		t.Elem = elem
+		if err := p.expect(tokPunct, "]"); err != nil {
+			return nil, err
+		}
+	} else {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		t.Name = name
+	}
+	if p.tok.is(tokPunct, "!") {
+		t.NonNull = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) parseDirectives(constant bool) ([]*Directive, error) {
+	directives := []*Directive{}
+	for p.tok.is(tokPunct, "@") {
+		d := &Directive{Loc: p.tok.loc}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		d.Name = name
+		if p.tok.is(tokPunct, "(") {
+			if d.Arguments, err = p.parseArguments(constant); err != nil {
+				return nil, err
+			}
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+func (p *parser) parseArguments(constant bool) ([]*ArgumentNode, error) {
+	if err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	args := []*ArgumentNode{}
+	for !p.tok.is(tokPunct, ")") {
+		arg := &ArgumentNode{Loc: p.tok.loc}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		arg.Name = name
+		if err := p.expect(tokPunct, ":"); err != nil {
+			return nil, err
+		}
+		if arg.Value, err = p.parseValue(constant); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, p.next()
+}
+
+func (p *parser) parseSelectionSet() ([]Selection, error) {
+	if err := p.expect(tokPunct, "{"); err != nil {
+		return nil, err
+	}
+	sels := []Selection{}
+	for !p.tok.is(tokPunct, "}") {
+		if p.tok.kind == tokEOF {
+			return nil, p.unexpected()
+		}
+		sel, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, errorAt(p.tok.loc, "Syntax Error: Expected Name, found \"}\".")
+	}
+	return sels, p.next()
+}
+
+func (p *parser) parseSelection() (Selection, error) {
+	loc := p.tok.loc
+	if p.tok.is(tokPunct, "...") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		// "on"以外の名前の場合はフラグメントの展開
+		if p.tok.kind == tokName && p.tok.value != "on" {
+			spread := &FragmentSpread{Name: p.tok.value, Loc: loc}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			directives, err := p.parseDirectives(false)
+			if err != nil {
+				return nil, err
+			}
+			spread.Directives = directives
+			return spread, nil
+		}
+		inline := &InlineFragment{Loc: loc}
+		if p.tok.is(tokName, "on") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			inline.TypeCondition = name
+		}
+		directives, err := p.parseDirectives(false)
+		if err != nil {
+			return nil, err
+		}
+		inline.Directives = directives
+		if inline.SelectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+		return inline, nil
+	}
+	field := &FieldNode{Loc: loc}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	field.Name = name
+	if p.tok.is(tokPunct, ":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		field.Alias = name
+		if field.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.is(tokPunct, "(") {
+		if field.Arguments, err = p.parseArguments(false); err != nil {
+			return nil, err
+		}
+	}
+	if field.Directives, err = p.parseDirectives(false); err != nil {
+		return nil, err
+	}
+	if p.tok.is(tokPunct, "{") {
+		if field.SelectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) parseFragment() (*Fragment, error) {
+	f := &Fragment{Loc: p.tok.loc}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, errorAt(f.Loc, "Syntax Error: Unexpected Name \"on\".")
+	}
+	f.Name = name
+	if err := p.expect(tokName, "on"); err != nil {
+		return nil, err
+	}
+	if f.TypeCondition, err = p.expectName(); err != nil {
+		return nil, err
+	}
+	if f.Directives, err = p.parseDirectives(false); err != nil {
+		return nil, err
+	}
+	if f.SelectionSet, err = p.parseSelectionSet(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseValueは値をパースする(constantの場合は変数を使えません)
+func (p *parser) parseValue(constant bool) (*Value, error) {
+	v := &Value{Loc: p.tok.loc, Raw: p.tok.value}
+	switch {
+	case p.tok.is(tokPunct, "$") && !constant:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind = KindVariable
+		v.Raw = name
+		return v, nil
+	case p.tok.is(tokPunct, "["):
+		v.Kind = KindList
+		v.List = []*Value{}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.tok.is(tokPunct, "]") {
+			if p.tok.kind == tokEOF {
+				return nil, p.unexpected()
+			}
+			item, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			v.List = append(v.List, item)
+		}
+		return v, p.next()
+	case p.tok.is(tokPunct, "{"):
+		v.Kind = KindObject
+		v.Fields = []*ObjectField{}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.tok.is(tokPunct, "}") {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokPunct, ":"); err != nil {
+				return nil, err
+			}
+			value, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			v.Fields = append(v.Fields, &ObjectField{Name: name, Value: value})
+		}
+		return v, p.next()
+	case p.tok.kind == tokInt:
+		v.Kind = KindInt
+	case p.tok.kind == tokFloat:
+		v.Kind = KindFloat
+	case p.tok.kind == tokString:
+		v.Kind = KindString
+	case p.tok.is(tokName, "true"), p.tok.is(tokName, "false"):
+		v.Kind = KindBoolean
+	case p.tok.is(tokName, "null"):
+		v.Kind = KindNull
+	case p.tok.kind == tokName:
+		v.Kind = KindEnum
+	default:
+		return nil, p.unexpected()
+	}
+	return v, p.next()
+}
+
+// Stringは値をクエリに書く形で返す(エラーメッセージで使います)
+func (v *Value) String() string {
+	switch v.Kind {
+	case KindVariable:
+		return "$" + v.Raw
+	case KindString:
+		return strconv.Quote(v.Raw)
+	case KindList:
+		items := []string{}
+		for _, item := range v.List {
+			items = append(items, item.String())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case KindObject:
+		fields := []string{}
+		for _, f := range v.Fields {
+			fields = append(fields, fmt.Sprintf("%s: %s", f.Name, f.Value))
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case KindNull:
+		return "null"
+	}
+	return v.Raw
+}
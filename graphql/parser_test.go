@@ -0,0 +1,210 @@
+package graphql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// formatSelectionsは選択を比べやすい1行の文字列にする
+// 例: a:task(id: 1) @skip(if: $x) { id ...F ... on Task { title } }
+func formatSelections(sels []Selection) string {
+	parts := []string{}
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *FieldNode:
+			part := s.Name
+			if s.Alias != "" {
+				part = s.Alias + ":" + part
+			}
+			part += formatArguments(s.Arguments) + formatDirectives(s.Directives)
+			if s.SelectionSet != nil {
+				part += " " + formatSelections(s.SelectionSet)
+			}
+			parts = append(parts, part)
+		case *FragmentSpread:
+			parts = append(parts, "..."+s.Name+formatDirectives(s.Directives))
+		case *InlineFragment:
+			part := "..."
+			if s.TypeCondition != "" {
+				part += " on " + s.TypeCondition
+			}
+			parts = append(parts, part+formatDirectives(s.Directives)+" "+formatSelections(s.SelectionSet))
+		}
+	}
+	return "{ " + strings.Join(parts, " ") + " }"
+}
+
+func formatArguments(args []*ArgumentNode) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := []string{}
+	for _, arg := range args {
+		parts = append(parts, arg.Name+": "+arg.Value.String())
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func formatDirectives(directives []*Directive) string {
+	s := ""
+	for _, d := range directives {
+		s += " @" + d.Name + formatArguments(d.Arguments)
+	}
+	return s
+}
+
+func TestParseSelections(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"shorthand query", "{ tasks { id title } }", "{ tasks { id title } }"},
+		{"commas and comments", "{ a, b # comment\n c }", "{ a b c }"},
+		{"alias", "{ first: task(id: 1) { id } }", "{ first:task(id: 1) { id } }"},
+		{"arguments", `{ tasks(filter: {completed: false, tags: ["a", "b"]}, first: 10) { id } }`,
+			`{ tasks(filter: {completed: false, tags: ["a", "b"]}, first: 10) { id } }`},
+		{"variable argument", "query ($id: ID!) { task(id: $id) { id } }", "{ task(id: $id) { id } }"},
+		{"enum and null", "{ tasks(order: DUE_DATE, projectId: null) { id } }", "{ tasks(order: DUE_DATE, projectId: null) { id } }"},
+		{"numbers", "{ f(a: -1, b: 1.5, c: 2e3, d: 0) }", "{ f(a: -1, b: 1.5, c: 2e3, d: 0) }"},
+		{"string escapes", `{ f(s: "a\"b\\cé\n") }`, `{ f(s: "a\"b\\cé\n") }`},
+		{"block string", "{ f(s: \"\"\"\n    line 1\n      line 2\n  \"\"\") }", `{ f(s: "line 1\n  line 2") }`},
+		{"directives", "{ a @skip(if: true) b @include(if: $show) }", "{ a @skip(if: true) b @include(if: $show) }"},
+		{"fragment spread", "{ task(id: 1) { ...TaskFields @include(if: $x) } }", "{ task(id: 1) { ...TaskFields @include(if: $x) } }"},
+		{"inline fragment", "{ task(id: 1) { ... on Task { title } ... @skip(if: false) { id } } }",
+			"{ task(id: 1) { ... on Task { title } ... @skip(if: false) { id } } }"},
+		{"field named on", "{ on }", "{ on }"},
+		{"unicode in string", `{ f(s: "タスク") }`, `{ f(s: "タスク") }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if len(doc.Operations) != 1 {
+				t.Fatalf("Parse(%q): got %d operations, want 1", tt.input, len(doc.Operations))
+			}
+			if got := formatSelections(doc.Operations[0].SelectionSet); got != tt.want {
+				t.Errorf("Parse(%q):\n got  %s\n want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+	doc, err := Parse(`
+query Tasks($first: Int = 10, $ids: [ID!]!, $filter: TaskFilter) @cached {
+  tasks(first: $first) { ...TaskFields }
+}
+mutation Done { completeTask(id: 1) { id } }
+subscription { taskChanged { event } }
+fragment TaskFields on Task { id title }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Operations) != 3 {
+		t.Fatalf("got %d operations, want 3", len(doc.Operations))
+	}
+	types := []string{}
+	for _, op := range doc.Operations {
+		types = append(types, op.Type+" "+op.Name)
+	}
+	if got := strings.Join(types, ","); got != "query Tasks,mutation Done,subscription " {
+		t.Errorf("operations = %q", got)
+	}
+
+	query := doc.Operations[0]
+	vars := []string{}
+	for _, v := range query.Variables {
+		s := v.Name + ":" + v.Type.String()
+		if v.Default != nil {
+			s += "=" + v.Default.String()
+		}
+		vars = append(vars, s)
+	}
+	if got := strings.Join(vars, ","); got != "first:Int=10,ids:[ID!]!,filter:TaskFilter" {
+		t.Errorf("variables = %q", got)
+	}
+	if len(query.Directives) != 1 || query.Directives[0].Name != "cached" {
+		t.Errorf("directives = %v", query.Directives)
+	}
+	if query.Loc != (Location{Line: 2, Column: 1}) {
+		t.Errorf("query location = %v, want 2:1", query.Loc)
+	}
+
+	f := doc.Fragments["TaskFields"]
+	if f == nil {
+		t.Fatal("fragment TaskFields is missing")
+	}
+	if f.TypeCondition != "Task" || formatSelections(f.SelectionSet) != "{ id title }" {
+		t.Errorf("fragment = on %s %s", f.TypeCondition, formatSelections(f.SelectionSet))
+	}
+	if f.Loc != (Location{Line: 7, Column: 1}) {
+		t.Errorf("fragment location = %v, want 7:1", f.Loc)
+	}
+}
+
+func TestParseFieldLocations(t *testing.T) {
+	// 列はバイト数ではなく文字数で数えます。
+	doc, err := Parse("{\n  a(s: \"タスク\") b\n\tc\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Location{{2, 3}, {2, 15}, {3, 2}}
+	for i, sel := range doc.Operations[0].SelectionSet {
+		if loc := sel.(*FieldNode).Loc; loc != want[i] {
+			t.Errorf("field %d: got %v, want %v", i, loc, want[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		msg   string
+		loc   Location
+	}{
+		{"empty document", "", "The document must contain an operation.", Location{1, 1}},
+		{"only fragment", "fragment F on Task { id }", "The document must contain an operation.", Location{1, 26}},
+		{"unexpected character", "{ a ? }", `Unexpected character '?'.`, Location{1, 5}},
+		{"unexpected character after multibyte", `{ a(s: "あ") ? }`, `Unexpected character '?'.`, Location{1, 13}},
+		{"unterminated string", "{ a(s: \"abc) }", "Unterminated string.", Location{1, 8}},
+		{"newline in string", "{ a(s: \"ab\ncd\") }", "Unterminated string.", Location{1, 8}},
+		{"unterminated block string", `{ a(s: """abc) }`, "Unterminated string.", Location{1, 8}},
+		{"invalid escape", `{ a(s: "\q") }`, `Invalid escape sequence \q.`, Location{1, 8}},
+		{"invalid unicode escape", `{ a(s: "\u12") }`, "Invalid unicode escape.", Location{1, 8}},
+		{"invalid number", "{ a(n: 12abc) }", "Invalid number.", Location{1, 8}},
+		{"missing fraction", "{ a(n: 1.) }", "Invalid number.", Location{1, 8}},
+		{"lone minus", "{ a(n: -) }", "Invalid number.", Location{1, 8}},
+		{"empty selection set", "{ }", `Syntax Error: Expected Name, found "}".`, Location{1, 3}},
+		{"unclosed selection set", "{ a { b }", "Syntax Error: Unexpected <EOF>.", Location{1, 10}},
+		{"missing colon in argument", "{ a(id 1) }", `Syntax Error: Expected ":", found 1.`, Location{1, 8}},
+		{"variable in default value", "query ($a: Int = $b) { a }", "Syntax Error: Unexpected $.", Location{1, 18}},
+		{"missing variable type", "query ($a) { a }", `Syntax Error: Expected ":", found ).`, Location{1, 10}},
+		{"fragment named on", "fragment on on Task { id } { a }", `Syntax Error: Unexpected Name "on".`, Location{1, 1}},
+		{"fragment without type condition", "{ a } fragment F { id }", `Syntax Error: Expected "on", found {.`, Location{1, 18}},
+		{"duplicate fragment", "{ a } fragment F on T { id }\nfragment F on T { id }", `There can be only one fragment named "F".`, Location{2, 1}},
+		{"unknown keyword", "schema { a }", "Syntax Error: Unexpected schema.", Location{1, 1}},
+		{"unclosed list", "{ a(l: [1, 2) }", "Syntax Error: Unexpected ).", Location{1, 13}},
+		{"error on later line", "{\n  a\n  b(x: )\n}", "Syntax Error: Unexpected ).", Location{3, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var gqlErr *Error
+			if !errors.As(err, &gqlErr) {
+				t.Fatalf("Parse(%q): got %v, want *Error", tt.input, err)
+			}
+			if gqlErr.Message != tt.msg {
+				t.Errorf("Parse(%q): got message %q, want %q", tt.input, gqlErr.Message, tt.msg)
+			}
+			if len(gqlErr.Locations) != 1 || gqlErr.Locations[0] != tt.loc {
+				t.Errorf("Parse(%q): got locations %v, want %v", tt.input, gqlErr.Locations, tt.loc)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Scalarは値をそのままJSONで表す型
+type Scalar struct {
+	Name string
+	// Serializeはリゾルバーが返した値(ポインターは外してあります)をレスポンスのJSONの値にする
+	Serialize func(v interface{}) (interface{}, error)
+	// ParseValueは変数(JSONの値)と引数のリテラルの値を、リゾルバーに渡す値にする
+	// リテラルの整数はint64、小数はfloat64で渡し、変換済みの値を渡すこともあります。
+	ParseValue func(v interface{}) (interface{}, error)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// Enumは決まった値のどれかになる型(リゾルバーとの受け渡しは文字列です)
+type Enum struct {
+	Name   string
+	Values []string
+}
+
+func (e *Enum) String() string { return e.Name }
+
+func (e *Enum) has(v string) bool {
+	for _, value := range e.Values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// enumLiteralは引数に引用符なしで書かれた列挙値(文字列のリテラルと区別します)
+type enumLiteral string
+
+// 組み込みのスカラー型と、タスクの日時と独自の項目のためのTime・JSON
+var (
+	Int = &Scalar{
+		Name: "Int",
+		Serialize: func(v interface{}) (interface{}, error) {
+			n, ok := toInt64(v)
+			if !ok || n > math.MaxInt32 || n < math.MinInt32 {
+				return nil, fmt.Errorf("Int cannot represent value: %v", v)
+			}
+			return n, nil
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			n, ok := toInt64(v)
+			if !ok || n > math.MaxInt32 || n < math.MinInt32 {
+				return nil, fmt.Errorf("Int cannot represent non-integer value: %s", describe(v))
+			}
+			return int(n), nil
+		},
+	}
+	Float = &Scalar{
+		Name: "Float",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if f, ok := toFloat64(v); ok {
+				return f, nil
+			}
+			return nil, fmt.Errorf("Float cannot represent value: %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			if f, ok := toFloat64(v); ok {
+				return f, nil
+			}
+			return nil, fmt.Errorf("Float cannot represent non numeric value: %s", describe(v))
+		},
+	}
+	String = &Scalar{
+		Name: "String",
+		Serialize: func(v interface{}) (interface{}, error) {
+			switch s := v.(type) {
+			case string:
+				return s, nil
+			case fmt.Stringer:
+				return s.String(), nil
+			}
+			return nil, fmt.Errorf("String cannot represent value: %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent a non string value: %s", describe(v))
+		},
+	}
+	Boolean = &Scalar{
+		Name: "Boolean",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent value: %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent a non boolean value: %s", describe(v))
+		},
+	}
+	// IDはレスポンスでは文字列にして、引数では文字列と整数のどちらも受け取ります(リゾルバーには文字列で渡します)。
+	ID = &Scalar{
+		Name: "ID",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			if n, ok := toInt64(v); ok {
+				return strconv.FormatInt(n, 10), nil
+			}
+			return nil, fmt.Errorf("ID cannot represent value: %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			if n, ok := toInt64(v); ok {
+				return strconv.FormatInt(n, 10), nil
+			}
+			return nil, fmt.Errorf("ID cannot represent value: %s", describe(v))
+		},
+	}
+	// TimeはRFC 3339の日時の文字列(リゾルバーとはtime.Timeで受け渡します)
+	Time = &Scalar{
+		Name: "Time",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if t, ok := v.(time.Time); ok {
+				return t.Format(time.RFC3339Nano), nil
+			}
+			return nil, fmt.Errorf("Time cannot represent value: %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			switch t := v.(type) {
+			case time.Time:
+				return t, nil
+			case string:
+				parsed, err := time.Parse(time.RFC3339, t)
+				if err != nil {
+					return nil, fmt.Errorf("Time must be an RFC 3339 date-time: %s", describe(v))
+				}
+				return parsed, nil
+			}
+			return nil, fmt.Errorf("Time must be an RFC 3339 date-time: %s", describe(v))
+		},
+	}
+	// JSONは任意のJSONの値(独自の項目の値に使います)
+	JSON = &Scalar{
+		Name:      "JSON",
+		Serialize: func(v interface{}) (interface{}, error) { return v, nil },
+		ParseValue: func(v interface{}) (interface{}, error) {
+			if e, ok := v.(enumLiteral); ok {
+				return string(e), nil
+			}
+			return v, nil
+		},
+	}
+)
+
+// toInt64は整数の値(小数の部分が無いfloat64も含む)をint64にする
+func toInt64(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	if n, ok := toInt64(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// describeはエラーメッセージに入れる値の表現
+func describe(v interface{}) string {
+	if e, ok := v.(enumLiteral); ok {
+		return string(e)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// coerceInputは変数と引数の値を、引数の型に合わせて変換する
+func coerceInput(t Type, v interface{}) (interface{}, error) {
+	if nn, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("Expected non-nullable type %q not to be null.", t)
+		}
+		return coerceInput(nn.OfType, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *List:
+		// リストの型に1つの値を渡した場合は、要素が1つのリストとして扱います。
+		items, ok := v.([]interface{})
+		if !ok {
+			item, err := coerceInput(t.OfType, v)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerceInput(t.OfType, item)
+			if err != nil {
+				return nil, fmt.Errorf("In element #%d: %s", i, err)
+			}
+			out[i] = c
+		}
+		return out, nil
+	case *InputObject:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Expected type %q to be an object.", t.Name)
+		}
+		for name := range fields {
+			if _, ok := t.Fields[name]; !ok {
+				return nil, fmt.Errorf("Field %q is not defined by type %q.", name, t.Name)
+			}
+		}
+		return coerceArgumentMap(t.Fields, fields, fmt.Sprintf("type %q", t.Name))
+	case *Scalar:
+		if _, ok := v.(enumLiteral); ok && t != JSON {
+			return nil, fmt.Errorf("%s cannot represent value: %s", t.Name, describe(v))
+		}
+		return t.ParseValue(v)
+	case *Enum:
+		var s string
+		switch e := v.(type) {
+		case enumLiteral:
+			s = string(e)
+		case string:
+			s = e
+		default:
+			return nil, fmt.Errorf("Enum %q cannot represent value: %s", t.Name, describe(v))
+		}
+		if !t.has(s) {
+			return nil, fmt.Errorf("Value %q does not exist in %q enum.", s, t.Name)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("Type %q is not an input type.", t)
+}
+
+// coerceArgumentMapは引数(InputObjectの項目)の定義に合わせて値を変換する
+// 値が無い項目は既定値を使い、既定値も無くnullにならない項目の場合はエラーにします。
+func coerceArgumentMap(defs map[string]*Argument, values map[string]interface{}, owner string) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	for name, def := range defs {
+		v, ok := values[name]
+		if !ok {
+			if def.Default != nil {
+				out[name] = def.Default
+			} else if _, required := def.Type.(*NonNull); required {
+				return nil, fmt.Errorf("Field %q of required type %q was not provided for %s.", name, def.Type, owner)
+			}
+			continue
+		}
+		c, err := coerceInput(def.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %s", name, err)
+		}
+		out[name] = c
+	}
+	return out, nil
+}
+
+// valueFromASTは引数のリテラルを、変数を置き換えたGoの値にする
+// 指定されていない変数を使っている場合は、2つ目の返り値をfalseにします(引数を指定していないのと同じ扱い)。
+func valueFromAST(v *Value, vars map[string]interface{}) (interface{}, bool, error) {
+	switch v.Kind {
+	case KindVariable:
+		value, ok := vars[v.Raw]
+		return value, ok, nil
+	case KindInt:
+		n, err := strconv.ParseInt(v.Raw, 10, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("Int cannot represent value: %s", v.Raw)
+		}
+		return n, true, nil
+	case KindFloat:
+		f, err := strconv.ParseFloat(v.Raw, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("Float cannot represent value: %s", v.Raw)
+		}
+		return f, true, nil
+	case KindString:
+		return v.Raw, true, nil
+	case KindBoolean:
+		return v.Raw == "true", true, nil
+	case KindNull:
+		return nil, true, nil
+	case KindEnum:
+		return enumLiteral(v.Raw), true, nil
+	case KindList:
+		items := make([]interface{}, 0, len(v.List))
+		for _, item := range v.List {
+			value, ok, err := valueFromAST(item, vars)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				value = nil
+			}
+			items = append(items, value)
+		}
+		return items, true, nil
+	case KindObject:
+		fields := map[string]interface{}{}
+		for _, f := range v.Fields {
+			value, ok, err := valueFromAST(f.Value, vars)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				fields[f.Name] = value
+			}
+		}
+		return fields, true, nil
+	}
+	return nil, false, fmt.Errorf("unknown value kind %s", v.Kind)
+}
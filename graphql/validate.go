@@ -0,0 +1,301 @@
+package graphql
+
+// defaultListSizeは複雑さを計算する時の、リストの要素の数の既定の見積もり
+const defaultListSize = 10
+
+// maxValidationSelectionsは検証するフィールドの数の上限
+// フラグメントを何重にも展開して、検証だけで時間がかかるクエリを止めます。
+const maxValidationSelections = 10000
+
+// inputTypeは変数の型の名前から、スキーマの引数で使われている入力の型を探す
+func (s *Schema) inputType(ref *TypeRef) (Type, bool) {
+	var t Type
+	if ref.Elem != nil {
+		elem, ok := s.inputType(ref.Elem)
+		if !ok {
+			return nil, false
+		}
+		t = &List{elem}
+	} else {
+		switch ref.Name {
+		case Int.Name:
+			t = Int
+		case Float.Name:
+			t = Float
+		case String.Name:
+			t = String
+		case Boolean.Name:
+			t = Boolean
+		case ID.Name:
+			t = ID
+		default:
+			named, ok := s.namedInputTypes()[ref.Name]
+			if !ok {
+				return nil, false
+			}
+			t = named
+		}
+	}
+	if ref.NonNull {
+		t = &NonNull{t}
+	}
+	return t, true
+}
+
+// namedInputTypesはルートの型から辿れる引数の型(スカラー・列挙型・InputObject)を名前で返す
+func (s *Schema) namedInputTypes() map[string]Type {
+	types := map[string]Type{}
+	seen := map[*Object]bool{}
+	var addInput func(t Type)
+	addInput = func(t Type) {
+		switch t := t.(type) {
+		case *NonNull:
+			addInput(t.OfType)
+		case *List:
+			addInput(t.OfType)
+		case *Scalar:
+			types[t.Name] = t
+		case *Enum:
+			types[t.Name] = t
+		case *InputObject:
+			if _, ok := types[t.Name]; ok {
+				return
+			}
+			types[t.Name] = t
+			for _, f := range t.Fields {
+				addInput(f.Type)
+			}
+		}
+	}
+	var walk func(t Type)
+	walk = func(t Type) {
+		switch t := t.(type) {
+		case *NonNull:
+			walk(t.OfType)
+		case *List:
+			walk(t.OfType)
+		case *Object:
+			if seen[t] {
+				return
+			}
+			seen[t] = true
+			for _, f := range t.Fields {
+				for _, arg := range f.Args {
+					addInput(arg.Type)
+				}
+				walk(f.Type)
+			}
+		}
+	}
+	for _, root := range []*Object{s.Query, s.Mutation, s.Subscription} {
+		if root != nil {
+			walk(root)
+		}
+	}
+	return types
+}
+
+// coerceVariablesはリクエストの変数を、操作で宣言した型に合わせて変換する
+// 値が無い変数は既定値を使い、既定値も無い場合はmapに含めません。
+func (s *Schema) coerceVariables(op *Operation, values map[string]interface{}) (map[string]interface{}, []*Error) {
+	vars := map[string]interface{}{}
+	errs := []*Error{}
+	for _, def := range op.Variables {
+		t, ok := s.inputType(def.Type)
+		if !ok {
+			errs = append(errs, errorAt(def.Loc, "Unknown type %q.", def.Type))
+			continue
+		}
+		value, provided := values[def.Name]
+		if !provided && def.Default != nil {
+			v, _, err := valueFromAST(def.Default, nil)
+			if err != nil {
+				errs = append(errs, errorAt(def.Loc, "Variable \"$%s\" has an invalid default value: %s", def.Name, err))
+				continue
+			}
+			value, provided = v, true
+		}
+		if !provided {
+			if _, required := t.(*NonNull); required {
+				errs = append(errs, errorAt(def.Loc, "Variable \"$%s\" of required type %q was not provided.", def.Name, def.Type))
+			}
+			continue
+		}
+		c, err := coerceInput(t, value)
+		if err != nil {
+			errs = append(errs, errorAt(def.Loc, "Variable \"$%s\" got invalid value %s; %s", def.Name, describe(value), err))
+			continue
+		}
+		vars[def.Name] = c
+	}
+	return vars, errs
+}
+
+// validatorはクエリのフィールドがスキーマにあるかと、深さ・複雑さの上限を確認する
+type validator struct {
+	e          *executor
+	errs       []*Error
+	spreading  map[string]bool
+	selections int
+	maxDepth   int
+}
+
+func (e *executor) validate() []*Error {
+	v := &validator{e: e, spreading: map[string]bool{}}
+	listSize := e.schema.ListSize
+	if listSize <= 0 {
+		listSize = defaultListSize
+	}
+	complexity := v.selectionSet(e.root, e.op.SelectionSet, 1, listSize)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	if e.schema.MaxDepth > 0 && v.maxDepth > e.schema.MaxDepth {
+		return []*Error{errorAt(e.op.Loc, "Query is too deep: depth %d exceeds the maximum of %d.", v.maxDepth, e.schema.MaxDepth)}
+	}
+	if e.schema.MaxComplexity > 0 && complexity > e.schema.MaxComplexity {
+		return []*Error{errorAt(e.op.Loc, "Query is too complex: complexity %d exceeds the maximum of %d.", complexity, e.schema.MaxComplexity)}
+	}
+	return nil
+}
+
+// selectionSetはobjの選択を検証して、選択の複雑さ(フィールドの数の見積もり)を返す
+func (v *validator) selectionSet(obj *Object, sels []Selection, depth int, listSize int) int {
+	complexity := 0
+	for _, sel := range sels {
+		v.selections++
+		if v.selections > maxValidationSelections {
+			if v.selections == maxValidationSelections+1 {
+				v.errs = append(v.errs, &Error{Message: "Query has too many selections."})
+			}
+			return complexity
+		}
+		// 上限を超えた時点で残りを数える必要は無いので、複雑さの上限の2倍を超えたら打ち切ります。
+		if v.e.schema.MaxComplexity > 0 && complexity > 2*v.e.schema.MaxComplexity {
+			return complexity
+		}
+		switch sel := sel.(type) {
+		case *FieldNode:
+			v.directives(sel.Directives)
+			complexity += v.field(obj, sel, depth, listSize)
+		case *FragmentSpread:
+			v.directives(sel.Directives)
+			f, ok := v.e.doc.Fragments[sel.Name]
+			if !ok {
+				v.errs = append(v.errs, errorAt(sel.Loc, "Unknown fragment %q.", sel.Name))
+				continue
+			}
+			if v.spreading[sel.Name] {
+				v.errs = append(v.errs, errorAt(sel.Loc, "Cannot spread fragment %q within itself.", sel.Name))
+				continue
+			}
+			if !v.typeCondition(obj, f.TypeCondition, sel.Loc) {
+				continue
+			}
+			v.spreading[sel.Name] = true
+			complexity += v.selectionSet(obj, f.SelectionSet, depth, listSize)
+			delete(v.spreading, sel.Name)
+		case *InlineFragment:
+			v.directives(sel.Directives)
+			if sel.TypeCondition != "" && !v.typeCondition(obj, sel.TypeCondition, sel.Loc) {
+				continue
+			}
+			complexity += v.selectionSet(obj, sel.SelectionSet, depth, listSize)
+		}
+	}
+	return complexity
+}
+
+func (v *validator) field(obj *Object, f *FieldNode, depth int, listSize int) int {
+	if depth > v.maxDepth {
+		v.maxDepth = depth
+	}
+	if v.e.schema.MaxDepth > 0 && depth > v.e.schema.MaxDepth {
+		return 1
+	}
+	if f.Name == "__typename" {
+		if f.SelectionSet != nil {
+			v.errs = append(v.errs, errorAt(f.Loc, "Field \"__typename\" must not have a selection since type \"String!\" has no subfields."))
+		}
+		return 1
+	}
+	def, ok := obj.Fields[f.Name]
+	if !ok {
+		v.errs = append(v.errs, errorAt(f.Loc, "Cannot query field %q on type %q.", f.Name, obj.Name))
+		return 1
+	}
+	for _, arg := range f.Arguments {
+		if _, ok := def.Args[arg.Name]; !ok {
+			v.errs = append(v.errs, errorAt(arg.Loc, "Unknown argument %q on field \"%s.%s\".", arg.Name, obj.Name, f.Name))
+		}
+	}
+	for name, arg := range def.Args {
+		if _, required := arg.Type.(*NonNull); !required || arg.Default != nil {
+			continue
+		}
+		provided := false
+		for _, a := range f.Arguments {
+			if a.Name == name {
+				provided = true
+			}
+		}
+		if !provided {
+			v.errs = append(v.errs, errorAt(f.Loc, "Field %q argument %q of type %q is required, but it was not provided.", f.Name, name, arg.Type))
+		}
+	}
+	named, isList := namedType(def.Type)
+	child, isObject := named.(*Object)
+	if !isObject {
+		if f.SelectionSet != nil {
+			v.errs = append(v.errs, errorAt(f.Loc, "Field %q must not have a selection since type %q has no subfields.", f.Name, def.Type))
+		}
+		return 1
+	}
+	if f.SelectionSet == nil {
+		v.errs = append(v.errs, errorAt(f.Loc, "Field %q of type %q must have a selection of subfields.", f.Name, def.Type))
+		return 1
+	}
+	complexity := v.selectionSet(child, f.SelectionSet, depth+1, listSize)
+	if isList {
+		complexity *= listSize
+	}
+	return 1 + complexity
+}
+
+// typeConditionはフラグメントの型の条件がobjに一致するかを確認する(インターフェースとユニオンが無いので型の名前で比べます)
+func (v *validator) typeCondition(obj *Object, name string, loc Location) bool {
+	if name != obj.Name {
+		v.errs = append(v.errs, errorAt(loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", obj.Name, name))
+		return false
+	}
+	return true
+}
+
+// directivesは@skipと@include以外のディレクティブをエラーにする
+func (v *validator) directives(directives []*Directive) {
+	for _, d := range directives {
+		if d.Name != "skip" && d.Name != "include" {
+			v.errs = append(v.errs, errorAt(d.Loc, "Unknown directive \"@%s\".", d.Name))
+			continue
+		}
+		if len(d.Arguments) != 1 || d.Arguments[0].Name != "if" {
+			v.errs = append(v.errs, errorAt(d.Loc, "Directive \"@%s\" argument \"if\" of type \"Boolean!\" is required.", d.Name))
+		}
+	}
+}
+
+// namedTypeはNonNullとListを外した型と、リストかどうかを返す
+func namedType(t Type) (Type, bool) {
+	isList := false
+	for {
+		switch w := t.(type) {
+		case *NonNull:
+			t = w.OfType
+		case *List:
+			t = w.OfType
+			isList = true
+		default:
+			return t, isList
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icalはiCalendar(RFC 5545)の読み書きをする小さなパッケージ
+// タスクのエクスポート・インポートで、VCALENDARの中のVTODOを扱うために使います。
+// 値の意味(SUMMARYやDUEをタスクのどの項目にするか)は呼び出し側で決めます。
+
+// Propertyはコンテンツ行の1行(例: DUE;VALUE=DATE:20240101)
+// Valueはエスケープされたままの値で、テキストの値はUnescapeTextで元に戻します。
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// ComponentはBEGIN:〜END:で囲まれた要素(VCALENDAR・VTODOなど)
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []Component
+}
+
+// Getはnameのプロパティを返す(無い場合はnil)
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Textはnameのプロパティのテキストの値を返す(無い場合は空文字)
+func (c *Component) Text(name string) string {
+	if p := c.Get(name); p != nil {
+		return UnescapeText(p.Value)
+	}
+	return ""
+}
+
+// maxLineOctetsは折り返す前の1行の最大のバイト数(改行を除く)
+const maxLineOctets = 75
+
+// DateTimeLayoutとDateLayoutはUTCの日時と日付の値の形式
+const (
+	DateTimeLayout = "20060102T150405Z"
+	DateLayout     = "20060102"
+)
+
+// Writerはコンテンツ行を折り返してCRLFで書き出す
+// 行ごとに書き出すので、大きなカレンダーも全体をメモリに作らずに出力できます。
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Beginはnameの要素を開始する(BEGIN:name)
+func (w *Writer) Begin(name string) error {
+	return w.line("BEGIN:" + name)
+}
+
+// Endはnameの要素を終了する(END:name)
+func (w *Writer) End(name string) error {
+	return w.line("END:" + name)
+}
+
+// Propertyはプロパティを1行書き出す(p.Valueはエスケープ済みの値)
+func (w *Writer) Property(p Property) error {
+	var b strings.Builder
+	b.WriteString(p.Name)
+	for _, k := range sortedKeys(p.Params) {
+		b.WriteString(";" + k + "=" + quoteParam(p.Params[k]))
+	}
+	b.WriteString(":" + p.Value)
+	return w.line(b.String())
+}
+
+// WriteComponentはcを子の要素も含めて書き出す
+func (w *Writer) WriteComponent(c Component) error {
+	if err := w.Begin(c.Name); err != nil {
+		return err
+	}
+	for _, p := range c.Properties {
+		if err := w.Property(p); err != nil {
+			return err
+		}
+	}
+	for _, child := range c.Components {
+		if err := w.WriteComponent(child); err != nil {
+			return err
+		}
+	}
+	return w.End(c.Name)
+}
+
+// Flushはバッファに溜まっている行を書き出す
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// lineは75バイトを超える行を、UTF-8の文字の途中で切らないように折り返して書き出す
+func (w *Writer) line(s string) error {
+	if w.err != nil {
+		return w.err
+	}
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// 2行目以降は先頭の空白の分だけ短くします。
+		limit = maxLineOctets - 1
+	}
+	w.write(s + "\r\n")
+	return w.err
+}
+
+func (w *Writer) write(s string) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.WriteString(s)
+}
+
+// Parseはrのカレンダーを読み込んで、一番外側の要素(通常はVCALENDAR)を返す
+func Parse(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var stack []*Component
+	var root *Component
+	for _, l := range lines {
+		if strings.TrimSpace(l.text) == "" {
+			continue
+		}
+		p, err := parseLine(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.number, err)
+		}
+		switch p.Name {
+		case "BEGIN":
+			if root != nil && len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected BEGIN:%s after END:%s", l.number, p.Value, root.Name)
+			}
+			stack = append(stack, &Component{Name: strings.ToUpper(p.Value)})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", l.number, p.Value)
+			}
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				root = c
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, *c)
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: property %s outside of a component", l.number, p.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no calendar component")
+	}
+	return root, nil
+}
+
+type contentLine struct {
+	text   string
+	number int
+}
+
+// unfoldは折り返された行(次の行が空白かタブで始まる)を1行に戻す
+func unfold(r io.Reader) ([]contentLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	lines := []contentLine{}
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, contentLine{text, number})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseLineは1行を名前・パラメーター・値に分ける(引用符で囲んだパラメーターの値の中の:と;は区切りにしません)
+func parseLine(s string) (Property, error) {
+	p := Property{Params: map[string]string{}}
+	i := strings.IndexAny(s, ";:")
+	if i <= 0 {
+		return Property{}, fmt.Errorf("invalid content line")
+	}
+	p.Name = strings.ToUpper(s[:i])
+	rest := s[i:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return Property{}, fmt.Errorf("invalid parameter in %s", p.Name)
+		}
+		key := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return Property{}, fmt.Errorf("unterminated parameter value in %s", p.Name)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			j := strings.IndexAny(rest, ";:")
+			if j < 0 {
+				return Property{}, fmt.Errorf("missing value for %s", p.Name)
+			}
+			value = rest[:j]
+			rest = rest[j:]
+		}
+		p.Params[key] = value
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return Property{}, fmt.Errorf("missing value for %s", p.Name)
+	}
+	p.Value = rest[1:]
+	return p, nil
+}
+
+// EscapeTextはテキストの値の\・;・,・改行をエスケープする
+func EscapeText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// UnescapeTextはEscapeTextでエスケープした値を元に戻す
+func UnescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// FormatDateTimeは日時をUTCの日時の値(例: 20240101T090000Z)にする
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(DateTimeLayout)
+}
+
+// ParseDateTimeは日時か日付のプロパティの値を解釈する
+// 日付だけの値(VALUE=DATE)はUTCの0時、TZIDの無いローカルの日時(floating)はUTCとして扱います。
+func ParseDateTime(p Property) (time.Time, error) {
+	loc := time.UTC
+	if tzid := p.Params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unknown TZID %s", tzid)
+		}
+		loc = l
+	}
+	if t, err := time.Parse(DateTimeLayout, p.Value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", p.Value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(DateLayout, p.Value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s", p.Value)
+}
+
+// quoteParamは:・;・,を含むパラメーターの値を引用符で囲む
+func quoteParam(v string) string {
+	if strings.ContainsAny(v, ":;,") {
+		return `"` + strings.ReplaceAll(v, `"`, "") + `"`
+	}
+	return v
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,157 @@
+package importer
+
+import (
+	"fmt"
+	"go-rest-api/model"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// importerは他のツール(Todoist・Trello・Microsoft To Do)のエクスポートファイルを読み込んで、
+// ツールに依存しない形(Project・Task)にするパッケージ
+// 読み込んだ結果をプロジェクト・独自の項目・タスクとして作成するのはインポートのユースケースです。
+// ラベルはタスクのタグ、チェックリストの項目はサブタスクにします。
+
+// Projectは作成するプロジェクトと、その中のタスク
+type Project struct {
+	Name string
+	// ListFieldはタスクのリスト(Trelloのリスト・Todoistのセクション)を入れるselectの項目の名前、Listsはその選択肢
+	ListField string
+	Lists     []string
+	Tasks     []Task
+}
+
+// Taskは作成するタスク
+type Task struct {
+	// Rowはエラーを報告する時の、ファイルの中の位置(CSVの行、JSONのカード・タスクの順番)
+	Row         int
+	Title       string
+	Description string
+	Completed   bool
+	DueDate     *time.Time
+	// Labelsはタスクのタグにするラベル(Todoistの@ラベル・Trelloのラベル・To Doのカテゴリ)
+	Labels    []string
+	List      string
+	Checklist []ChecklistItem
+	// Warningsは読み込めずに無視したり、切り詰めたりした値(タスクは作成します)
+	Warnings []string
+}
+
+// ChecklistItemはサブタスクにするチェックリストの1項目(Todoistのサブタスク・Trelloのチェックリスト・To Doのステップ)
+type ChecklistItem struct {
+	Title   string
+	Checked bool
+}
+
+// maxOptionRunesとmaxOptionsは独自の項目の選択肢の制限(validatorのCustomFieldValidateと同じ)
+// maxProjectNameRunesはプロジェクトの名前、maxTagsとmaxTagRunesはタスクのタグの制限(validatorと同じ)
+const (
+	maxOptionRunes      = 50
+	maxOptions          = 100
+	maxProjectNameRunes = 100
+	maxTags             = 20
+	maxTagRunes         = 50
+)
+
+// Parseはsourceの種類のエクスポートファイルを読み込む
+// fileNameはファイル名にプロジェクトの名前が入っている形式(TodoistのCSV)で使います。
+func Parse(source string, r io.Reader, fileName string) ([]Project, error) {
+	var projects []Project
+	var err error
+	switch source {
+	case model.ImportSourceTodoist:
+		projects, err = parseTodoist(r, fileName)
+	case model.ImportSourceTrello:
+		projects, err = parseTrello(r)
+	case model.ImportSourceMicrosoftTodo:
+		projects, err = parseMicrosoftTodo(r)
+	default:
+		return nil, fmt.Errorf("unknown source %s", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range projects {
+		normalize(&projects[i])
+	}
+	return projects, nil
+}
+
+// normalizeはタスクのタイトル・説明・ラベルをタスクの制限に、リストの名前を独自の項目の選択肢の制限に合わせる
+// 長すぎる値は切り詰めて、多すぎるラベルと選択肢は先に出てきたものだけを使い、それぞれタスクの警告にします。
+func normalize(p *Project) {
+	p.Name = truncate(strings.TrimSpace(p.Name), maxProjectNameRunes)
+	if p.Name == "" {
+		p.Name = "Imported"
+	}
+	for i := range p.Tasks {
+		t := &p.Tasks[i]
+		t.Title = truncateTitle(t.Title, "title", &t.Warnings)
+		if utf8.RuneCountInString(t.Description) > model.MaxTaskDescriptionRunes {
+			t.Description = truncate(t.Description, model.MaxTaskDescriptionRunes)
+			t.Warnings = append(t.Warnings, fmt.Sprintf("description was truncated to %d characters", model.MaxTaskDescriptionRunes))
+		}
+		labels := []string{}
+		seen := map[string]bool{}
+		for _, l := range t.Labels {
+			l = truncate(strings.TrimSpace(l), maxTagRunes)
+			if l == "" || seen[l] {
+				continue
+			}
+			seen[l] = true
+			if len(labels) >= maxTags {
+				t.Warnings = append(t.Warnings, fmt.Sprintf("label %q was skipped (too many labels)", l))
+				continue
+			}
+			labels = append(labels, l)
+		}
+		t.Labels = labels
+		checklist := []ChecklistItem{}
+		for _, item := range t.Checklist {
+			item.Title = truncateTitle(item.Title, "checklist item", &t.Warnings)
+			if item.Title == "" {
+				continue
+			}
+			checklist = append(checklist, item)
+		}
+		t.Checklist = checklist
+		t.List = truncate(strings.TrimSpace(t.List), maxOptionRunes)
+	}
+	lists := []string{}
+	seen := map[string]bool{}
+	for _, l := range p.Lists {
+		l = truncate(strings.TrimSpace(l), maxOptionRunes)
+		if l == "" || seen[l] || len(lists) >= maxOptions {
+			continue
+		}
+		seen[l] = true
+		lists = append(lists, l)
+	}
+	p.Lists = lists
+	for i := range p.Tasks {
+		t := &p.Tasks[i]
+		if t.List != "" && !seen[t.List] {
+			t.Warnings = append(t.Warnings, fmt.Sprintf("%s %q was skipped", strings.ToLower(p.ListField), t.List))
+			t.List = ""
+		}
+	}
+}
+
+// truncateTitleはタイトルをタスクのタイトルの最大の文字数に切り詰めて、切り詰めた場合はwarningsに追加する
+func truncateTitle(title string, name string, warnings *[]string) string {
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) <= model.MaxTaskTitleRunes {
+		return title
+	}
+	*warnings = append(*warnings, fmt.Sprintf("%s %q was truncated to %d characters", name, title, model.MaxTaskTitleRunes))
+	return truncate(title, model.MaxTaskTitleRunes)
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
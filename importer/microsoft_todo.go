@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Microsoft To DoのJSONは、Microsoft GraphのtodoTaskListの配列に、それぞれのリストのtasksを含めた形です。
+// {"value": [...]}・{"lists": [...]}・リストの配列のどれでも読み込めます。
+// 1つのリストが1つのプロジェクトで、カテゴリはタグ、本文(body)は説明、ステップ(checklistItems)はサブタスクにします。
+
+type microsoftTodoList struct {
+	DisplayName string `json:"displayName"`
+	Tasks       []struct {
+		Title  string `json:"title"`
+		Status string `json:"status"`
+		Body   *struct {
+			Content     string `json:"content"`
+			ContentType string `json:"contentType"`
+		} `json:"body"`
+		DueDateTime *struct {
+			DateTime string `json:"dateTime"`
+			TimeZone string `json:"timeZone"`
+		} `json:"dueDateTime"`
+		Categories     []string `json:"categories"`
+		ChecklistItems []struct {
+			DisplayName string `json:"displayName"`
+			IsChecked   bool   `json:"isChecked"`
+		} `json:"checklistItems"`
+	} `json:"tasks"`
+}
+
+// microsoftTodoDateLayoutはdueDateTime.dateTimeの形式(秒の小数は7桁)
+const microsoftTodoDateLayout = "2006-01-02T15:04:05.9999999"
+
+func parseMicrosoftTodo(r io.Reader) ([]Project, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("microsoft to do json: %w", err)
+	}
+	lists := []microsoftTodoList{}
+	if err := json.Unmarshal(raw, &lists); err != nil {
+		wrapper := struct {
+			Value []microsoftTodoList `json:"value"`
+			Lists []microsoftTodoList `json:"lists"`
+		}{}
+		if err := json.Unmarshal(raw, &wrapper); err != nil {
+			return nil, fmt.Errorf("microsoft to do json: %w", err)
+		}
+		lists = append(wrapper.Value, wrapper.Lists...)
+	}
+	if len(lists) == 0 {
+		return nil, errors.New("microsoft to do json has no lists")
+	}
+	projects := []Project{}
+	for _, l := range lists {
+		project := Project{Name: l.DisplayName, Tasks: []Task{}}
+		for i, t := range l.Tasks {
+			task := Task{Row: i + 1, Title: t.Title, Completed: t.Status == "completed", Labels: t.Categories}
+			// 説明はテキストなので、HTMLの本文は読み込みません。
+			if t.Body != nil && strings.TrimSpace(t.Body.Content) != "" {
+				if strings.EqualFold(t.Body.ContentType, "html") {
+					task.Warnings = append(task.Warnings, "html body was skipped")
+				} else {
+					task.Description = t.Body.Content
+				}
+			}
+			if t.DueDateTime != nil && t.DueDateTime.DateTime != "" {
+				if due, err := parseMicrosoftTodoDate(t.DueDateTime.DateTime, t.DueDateTime.TimeZone); err == nil {
+					task.DueDate = &due
+				} else {
+					task.Warnings = append(task.Warnings, fmt.Sprintf("due date %q was skipped", t.DueDateTime.DateTime))
+				}
+			}
+			for _, item := range t.ChecklistItems {
+				task.Checklist = append(task.Checklist, ChecklistItem{Title: item.DisplayName, Checked: item.IsChecked})
+			}
+			project.Tasks = append(project.Tasks, task)
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+// parseMicrosoftTodoDateはtimeZoneのローカルの日時を解釈する(タイムゾーンが分からない場合はUTC)
+func parseMicrosoftTodoDate(value string, timeZone string) (time.Time, error) {
+	loc := time.UTC
+	if timeZone != "" {
+		if l, err := time.LoadLocation(timeZone); err == nil {
+			loc = l
+		}
+	}
+	return time.ParseInLocation(microsoftTodoDateLayout, value, loc)
+}
@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TodoistのCSVは1つのファイルが1つのプロジェクトで、ファイル名がプロジェクトの名前です。
+// 列はTYPE・CONTENT・DESCRIPTION・PRIORITY・INDENT・DATEなどで、TYPEがsectionの行はセクション、taskの行はタスクです。
+// CONTENTの中の@ラベルはタグ、INDENTが2以上のタスクは直前の親のタスクのサブタスクにします。
+// 完了したタスクはエクスポートに含まれないので、全て未完了のタスクになります。
+
+// todoistDateLayoutsはDATEの列で解釈できる日付の形式(「every monday」のような繰り返しの指定は警告にします)
+var todoistDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05Z",
+	"Jan 2 2006",
+	"Jan 2 2006 15:04",
+	"2 Jan 2006",
+	"2 Jan 2006 15:04",
+}
+
+func parseTodoist(r io.Reader, fileName string) ([]Project, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["TYPE"]; !ok {
+		return nil, errors.New("todoist csv must have a TYPE column")
+	}
+	if _, ok := columns["CONTENT"]; !ok {
+		return nil, errors.New("todoist csv must have a CONTENT column")
+	}
+	project := Project{
+		Name:      strings.TrimSuffix(path.Base(strings.ReplaceAll(fileName, `\`, "/")), path.Ext(fileName)),
+		ListField: "Section",
+		Tasks:     []Task{},
+	}
+	section := ""
+	// parentは直前のINDENTが1のタスク(サブタスクを追加する先)
+	parent := -1
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cell := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		switch strings.ToLower(cell("TYPE")) {
+		case "section":
+			section = cell("CONTENT")
+			project.Lists = append(project.Lists, section)
+			parent = -1
+		case "task":
+			title, labels := splitTodoistLabels(cell("CONTENT"))
+			indent, _ := strconv.Atoi(cell("INDENT"))
+			if indent > 1 && parent >= 0 {
+				p := &project.Tasks[parent]
+				p.Checklist = append(p.Checklist, ChecklistItem{Title: title})
+				continue
+			}
+			task := Task{Row: line, Title: title, Description: cell("DESCRIPTION"), Labels: labels, List: section}
+			if date := cell("DATE"); date != "" {
+				if due, ok := parseTodoistDate(date); ok {
+					task.DueDate = &due
+				} else {
+					task.Warnings = append(task.Warnings, fmt.Sprintf("due date %q was skipped", date))
+				}
+			}
+			project.Tasks = append(project.Tasks, task)
+			parent = len(project.Tasks) - 1
+		}
+	}
+	return []Project{project}, nil
+}
+
+// splitTodoistLabelsはCONTENTから@ラベルを取り出して、残りをタイトルにする
+func splitTodoistLabels(content string) (string, []string) {
+	words := []string{}
+	labels := []string{}
+	for _, w := range strings.Fields(content) {
+		if len(w) > 1 && strings.HasPrefix(w, "@") {
+			labels = append(labels, w[1:])
+			continue
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " "), labels
+}
+
+func parseTodoistDate(value string) (time.Time, bool) {
+	for _, layout := range todoistDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,91 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TrelloのボードのJSONは1つのボードが1つのプロジェクトです。
+// リストはselectの項目(List)、ラベルはタグ、カードの説明(desc)は説明、カードのチェックリストはサブタスクにします。
+// アーカイブされたカードとリストはインポートしません。
+
+type trelloBoard struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		Id     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Labels []trelloLabel `json:"labels"`
+	Cards  []struct {
+		Id          string     `json:"id"`
+		Name        string     `json:"name"`
+		Desc        string     `json:"desc"`
+		Closed      bool       `json:"closed"`
+		IdList      string     `json:"idList"`
+		Due         *time.Time `json:"due"`
+		DueComplete bool       `json:"dueComplete"`
+		IdLabels    []string   `json:"idLabels"`
+	} `json:"cards"`
+	Checklists []struct {
+		IdCard     string `json:"idCard"`
+		CheckItems []struct {
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Pos   float64 `json:"pos"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+type trelloLabel struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func parseTrello(r io.Reader) ([]Project, error) {
+	board := trelloBoard{}
+	if err := json.NewDecoder(r).Decode(&board); err != nil {
+		return nil, fmt.Errorf("trello json: %w", err)
+	}
+	project := Project{Name: board.Name, ListField: "List", Tasks: []Task{}}
+	lists := map[string]string{}
+	for _, l := range board.Lists {
+		if l.Closed {
+			continue
+		}
+		lists[l.Id] = l.Name
+		project.Lists = append(project.Lists, l.Name)
+	}
+	// 名前の無いラベルは色の名前をラベルにします。
+	labels := map[string]string{}
+	for _, l := range board.Labels {
+		name := l.Name
+		if name == "" {
+			name = l.Color
+		}
+		labels[l.Id] = name
+	}
+	checklists := map[string][]ChecklistItem{}
+	for _, c := range board.Checklists {
+		for _, item := range c.CheckItems {
+			checklists[c.IdCard] = append(checklists[c.IdCard], ChecklistItem{Title: item.Name, Checked: item.State == "complete"})
+		}
+	}
+	for i, c := range board.Cards {
+		list, ok := lists[c.IdList]
+		if c.Closed || !ok {
+			continue
+		}
+		task := Task{Row: i + 1, Title: c.Name, Description: c.Desc, Completed: c.DueComplete, DueDate: c.Due, List: list, Checklist: checklists[c.Id]}
+		for _, id := range c.IdLabels {
+			if name, ok := labels[id]; ok && name != "" {
+				task.Labels = append(task.Labels, name)
+			}
+		}
+		project.Tasks = append(project.Tasks, task)
+	}
+	return []Project{project}, nil
+}
@@ -0,0 +1,214 @@
+// mailparseは受け取ったメール(RFC 5322・MIME)から、タスクにする件名・本文・添付ファイルを取り出すパッケージ
+// 本文はtext/plainを優先し、HTMLだけのメールはタグを取り除いたテキストにします。
+// ISO-2022-JPなどUTF-8以外の文字コードは、golang.org/x/textでUTF-8に変換します。
+package mailparse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// maxPartsは1通のメールで読み込むMIMEのパートの最大数(入れ子を含む)
+const maxParts = 100
+
+// maxDepthはmultipartの入れ子の最大の深さ
+const maxDepth = 10
+
+// Messageは読み込んだメール
+type Message struct {
+	// Fromはヘッダーの送信者のメールアドレス(小文字)
+	From    string
+	Subject string
+	// Textは本文のテキスト(text/plainが無い場合はHTMLから作ります)
+	Text        string
+	Attachments []Attachment
+}
+
+// Attachmentは添付ファイル(インラインの画像なども含みます)
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// wordDecoderは件名とファイル名の=?charset?B?...?=の形式を、UTF-8に変換する
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %s", charset)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// Parseはメールを読み込む
+func Parse(r io.Reader) (Message, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{}
+	if from, err := m.Header.AddressList("From"); err == nil && len(from) > 0 {
+		msg.From = strings.ToLower(from[0].Address)
+	}
+	msg.Subject = decodeHeader(m.Header.Get("Subject"))
+	p := &parser{}
+	if err := p.part(m.Header, m.Body, 0); err != nil {
+		return Message{}, err
+	}
+	msg.Text = p.text
+	if msg.Text == "" {
+		msg.Text = htmlToText(p.html)
+	}
+	msg.Text = strings.TrimSpace(strings.ReplaceAll(msg.Text, "\r\n", "\n"))
+	msg.Attachments = p.attachments
+	return msg, nil
+}
+
+func headerGet(h map[string][]string, key string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, key) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+func decodeHeader(s string) string {
+	decoded, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(decoded)
+}
+
+type parser struct {
+	text        string
+	html        string
+	attachments []Attachment
+	parts       int
+}
+
+// partはMIMEのパートを1つ読み込む(multipartの場合は中のパートを順番に読み込みます)
+func (p *parser) part(header map[string][]string, body io.Reader, depth int) error {
+	p.parts++
+	if p.parts > maxParts || depth > maxDepth {
+		return errors.New("too many MIME parts")
+	}
+	mediaType, params, err := mime.ParseMediaType(headerGet(header, "Content-Type"))
+	if err != nil {
+		// Content-Typeが無い・壊れている場合はtext/plainとして扱います(RFC 2045)。
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.part(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	data, err := io.ReadAll(decodeTransfer(headerGet(header, "Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+	disposition, dispositionParams, _ := mime.ParseMediaType(headerGet(header, "Content-Disposition"))
+	fileName := dispositionParams["filename"]
+	if fileName == "" {
+		fileName = params["name"]
+	}
+	fileName = decodeHeader(fileName)
+	// 本文は最初のtext/plain・text/htmlのパートで、添付ファイルとして付けられたものは添付ファイルにします。
+	if disposition != "attachment" && fileName == "" {
+		switch mediaType {
+		case "text/plain":
+			if p.text == "" {
+				p.text = decodeCharset(params["charset"], data)
+			}
+			return nil
+		case "text/html":
+			if p.html == "" {
+				p.html = decodeCharset(params["charset"], data)
+			}
+			return nil
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if fileName == "" {
+		fileName = "attachment"
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			fileName += exts[0]
+		}
+	}
+	p.attachments = append(p.attachments, Attachment{FileName: fileName, ContentType: mediaType, Data: data})
+	return nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// 行末の改行はbase64のデコーダーが読み飛ばします。
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// decodeCharsetは本文をUTF-8にする(変換できない文字コードの場合はそのまま返します)
+func decodeCharset(charset string, data []byte) string {
+	if charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "us-ascii") {
+		return string(bytes.ToValidUTF8(data, []byte("�")))
+	}
+	r, err := charsetReader(charset, bytes.NewReader(data))
+	if err != nil {
+		return string(bytes.ToValidUTF8(data, []byte("�")))
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return string(bytes.ToValidUTF8(data, []byte("�")))
+	}
+	return string(decoded)
+}
+
+var (
+	htmlDropPattern  = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlBreakPattern = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6])[^>]*>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines       = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToTextはHTMLの本文からタグを取り除いて、段落ごとに改行したテキストにする
+func htmlToText(s string) string {
+	s = htmlDropPattern.ReplaceAllString(s, "")
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	return blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
@@ -1,48 +1,235 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"go-rest-api/controller"
 	"go-rest-api/db"
+	"go-rest-api/notifier"
+	"go-rest-api/permission"
+	"go-rest-api/pubsub"
 	"go-rest-api/repository"
 	"go-rest-api/router"
+	"go-rest-api/scheduler"
+	"go-rest-api/smtpd"
+	"go-rest-api/storage"
+	"go-rest-api/tenant"
 	"go-rest-api/usecase"
 	"go-rest-api/validator"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	// バックグラウンドの処理(スケジューラー・ワーカー・SMTPのサーバーなど)は全てこのctxで起動して、
+	// SIGINT・SIGTERMを受け取った時にキャンセルして止めます。
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	// データベースをインスタンス化
 	// データベースパッケージの中で作っておいたNewDBを実行して
 	// 作成されたインスタンスをdbという変数に格納
 	db := db.NewDB()
+	// tasksとprojectsとtime_entriesとboardsとviewsとtemplatesとimport_jobsとwebhooksのテーブルへのクエリを、リクエストの組織(テナント)で自動的に絞り込むようにします。
+	// タスクに付くコメント・添付ファイル・リマインダー・共有・公開リンク・通知・変更履歴のテーブルも、タスクと同じ組織で絞り込みます。
+	if err := tenant.Register(db, "tasks", "projects", "time_entries", "boards", "views", "templates", "import_jobs", "webhooks",
+		"comments", "attachments", "reminders", "shares", "share_links", "notifications", "task_versions"); err != nil {
+		log.Fatalln(err)
+	}
 	// NewUserValidatorとNewTaskValidatorでコンストラクターを実行して構造体のインスタンスを作成します。
 	// インスタンス(userValidator、taskValidator)をusecaseのコンストラクターに渡していきます。
 	userValidator := validator.NewUserValidator()
 	taskValidator := validator.NewTaskValidator()
+	reminderValidator := validator.NewReminderValidator()
+	commentValidator := validator.NewCommentValidator()
+	projectValidator := validator.NewProjectValidator()
+	shareValidator := validator.NewShareValidator()
+	shareLinkValidator := validator.NewShareLinkValidator()
+	organizationValidator := validator.NewOrganizationValidator()
+	timeEntryValidator := validator.NewTimeEntryValidator()
+	boardValidator := validator.NewBoardValidator()
+	customFieldValidator := validator.NewCustomFieldValidator()
+	viewValidator := validator.NewViewValidator()
+	templateValidator := validator.NewTemplateValidator()
+	personalTokenValidator := validator.NewPersonalTokenValidator()
+	mailInboxValidator := validator.NewMailInboxValidator()
+	webhookValidator := validator.NewWebhookValidator()
+	syncValidator := validator.NewSyncValidator()
 	// レポジトリで作っておいたコンストラクターを起動
 	// repositoryパッケージの中で作っておいたNewUserRepositoryコンストラクターを起動
 	// 外側でインスタンス化してるデーターベース(db)を引数として注入
 	userRepository := repository.NewUserRepository(db)
 	// taskRepositoryのコンストラクターを起動
 	taskRepository := repository.NewTaskRepository(db)
+	// 繰り返しタスクのシリーズを保存するリポジトリ
+	taskSeriesRepository := repository.NewTaskSeriesRepository(db)
+	// リマインダーのリポジトリ
+	reminderRepository := repository.NewReminderRepository(db)
+	// タスクの変更履歴のリポジトリ
+	taskVersionRepository := repository.NewTaskVersionRepository(db)
+	// タスクの担当者の変更履歴のリポジトリ
+	taskAssignmentRepository := repository.NewTaskAssignmentRepository(db)
+	// コメントと通知のリポジトリ
+	commentRepository := repository.NewCommentRepository(db)
+	notificationRepository := repository.NewNotificationRepository(db)
+	// 添付ファイルのリポジトリ
+	attachmentRepository := repository.NewAttachmentRepository(db)
+	// プロジェクトと共有のリポジトリ
+	projectRepository := repository.NewProjectRepository(db)
+	shareRepository := repository.NewShareRepository(db)
+	shareLinkRepository := repository.NewShareLinkRepository(db)
+	// 組織とメンバー・招待のリポジトリ
+	organizationRepository := repository.NewOrganizationRepository(db)
+	// 作業時間のリポジトリ
+	timeEntryRepository := repository.NewTimeEntryRepository(db)
+	// カンバンボードと列のリポジトリ
+	boardRepository := repository.NewBoardRepository(db)
+	// プロジェクトの独自の項目のリポジトリ
+	customFieldRepository := repository.NewCustomFieldRepository(db)
+	// 保存したビューのリポジトリ
+	viewRepository := repository.NewViewRepository(db)
+	// タスクのテンプレートのリポジトリ
+	templateRepository := repository.NewTemplateRepository(db)
+	importJobRepository := repository.NewImportJobRepository(db)
+	// 個人用のアクセストークンと、CalDAVのリソースの名前のリポジトリ
+	personalTokenRepository := repository.NewPersonalTokenRepository(db)
+	calDAVResourceRepository := repository.NewCalDAVResourceRepository(db)
+	// 期限日のフィードのリポジトリ
+	calendarFeedRepository := repository.NewCalendarFeedRepository(db)
+	// メールからタスクを作成するための受信アドレスのリポジトリ
+	mailInboxRepository := repository.NewMailInboxRepository(db)
+	// タスクのイベントを通知するWebhookと、アウトボックス・配信のログのリポジトリ
+	webhookRepository := repository.NewWebhookRepository(db)
+	// ユースケースで複数のリポジトリへの書き込みを1つのトランザクションにまとめるためのトランザクション
+	transaction := repository.NewTransaction(db)
+	// タスクとプロジェクトのアクセス権を判定するサービス
+	permissionService := permission.NewPermissionService(db)
+	// タスクの変更を/eventsに接続しているクライアントに配るハブ
+	// 複数のインスタンスで動かしても全てのクライアントに届くように、デフォルトではPostgreSQLのLISTEN/NOTIFYを経由します。
+	// EVENTS_BACKENDがmemoryの場合は、1つのプロセスの中だけで配ります。
+	var eventHub pubsub.IHub
+	if os.Getenv("EVENTS_BACKEND") == "memory" {
+		eventHub = pubsub.NewHub()
+	} else {
+		postgresHub := pubsub.NewPostgresHub(db, "task_events")
+		go postgresHub.Start(ctx)
+		eventHub = postgresHub
+	}
+	// 添付ファイルの中身の保存先
+	// STORAGE_DRIVERがs3の場合はS3互換のストレージ、それ以外の場合はローカルのディレクトリに保存します。
+	var blobStore storage.BlobStore
+	if os.Getenv("STORAGE_DRIVER") == "s3" {
+		blobStore = storage.NewS3Store(storage.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	} else {
+		localStore, err := storage.NewLocalStore(os.Getenv("STORAGE_DIR"))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		blobStore = localStore
+	}
+	attachmentConfig := usecase.AttachmentConfig{
+		MaxBytes:     10 << 20,
+		AllowedTypes: []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf"},
+	}
+	if types := os.Getenv("ATTACHMENT_ALLOWED_TYPES"); types != "" {
+		attachmentConfig.AllowedTypes = strings.Split(types, ",")
+	}
+	if maxBytes, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64); err == nil {
+		attachmentConfig.MaxBytes = maxBytes
+	}
 	// usecaseのコンストラクターも起動
 	// usecaseのパッケージで作っておいたNewUserUsecaseコンストラクターを起動
 	// 引数として外側でインスタンス化しておいたuserRepositoryを引数として注入
 	userUsecase := usecase.NewUserUsecase(userRepository, userValidator)
 	// taskUsecaseのコンストラクターのNewTaskUsecaseも起動
-	taskUsecase := usecase.NewTaskUsecase(taskRepository, taskValidator)
+	taskUsecase := usecase.NewTaskUsecase(taskRepository, taskSeriesRepository, reminderRepository, taskVersionRepository,
+		taskAssignmentRepository, notificationRepository, customFieldRepository, permissionService, taskValidator, eventHub, transaction)
+	reminderUsecase := usecase.NewReminderUsecase(reminderRepository, taskRepository, permissionService, userRepository, reminderValidator)
+	commentUsecase := usecase.NewCommentUsecase(commentRepository, notificationRepository, permissionService, userRepository, commentValidator)
+	notificationUsecase := usecase.NewNotificationUsecase(notificationRepository)
+	attachmentUsecase := usecase.NewAttachmentUsecase(attachmentRepository, permissionService, blobStore, attachmentConfig)
+	projectUsecase := usecase.NewProjectUsecase(projectRepository, permissionService, projectValidator)
+	shareUsecase := usecase.NewShareUsecase(shareRepository, userRepository, notificationRepository, permissionService, shareValidator)
+	shareLinkUsecase := usecase.NewShareLinkUsecase(shareLinkRepository, taskRepository, permissionService, shareLinkValidator)
+	organizationUsecase := usecase.NewOrganizationUsecase(organizationRepository, userRepository, notificationRepository, organizationValidator)
+	timeEntryUsecase := usecase.NewTimeEntryUsecase(timeEntryRepository, permissionService, timeEntryValidator)
+	boardUsecase := usecase.NewBoardUsecase(boardRepository, taskRepository, permissionService, boardValidator)
+	customFieldUsecase := usecase.NewCustomFieldUsecase(customFieldRepository, permissionService, customFieldValidator)
+	viewUsecase := usecase.NewViewUsecase(viewRepository, taskUsecase, viewValidator)
+	templateUsecase := usecase.NewTemplateUsecase(templateRepository, taskRepository, taskUsecase, attachmentRepository, blobStore,
+		permissionService, templateValidator, transaction)
+	importUsecase := usecase.NewImportUsecase(importJobRepository, blobStore, projectUsecase, customFieldUsecase, taskUsecase)
+	personalTokenUsecase := usecase.NewPersonalTokenUsecase(personalTokenRepository, userRepository, personalTokenValidator)
+	calDAVUsecase := usecase.NewCalDAVUsecase(taskUsecase, projectUsecase, calDAVResourceRepository, taskVersionRepository)
+	calendarFeedUsecase := usecase.NewCalendarFeedUsecase(calendarFeedRepository, taskUsecase)
+	// 受信アドレスのドメインはINBOUND_MAIL_DOMAINで設定します(MXレコードでこのサーバーを指すドメイン)。
+	inboundMailDomain := os.Getenv("INBOUND_MAIL_DOMAIN")
+	if inboundMailDomain == "" {
+		inboundMailDomain = "localhost"
+	}
+	mailInboxUsecase := usecase.NewMailInboxUsecase(mailInboxRepository, userRepository, taskUsecase, attachmentUsecase,
+		mailInboxValidator, inboundMailDomain)
+	eventUsecase := usecase.NewEventUsecase(eventHub, permissionService)
+	webhookUsecase := usecase.NewWebhookUsecase(webhookRepository, permissionService, notifier.NewWebhookSender(), webhookValidator)
+	syncUsecase := usecase.NewSyncUsecase(taskUsecase, taskRepository, taskVersionRepository, syncValidator)
 	// controllerのコンストラクターも起動
 	// controllerパッケージの中で作っておいたNewUserControllerコンストラクターを起動
 	// 外側でインスタンス化してるuserUsecaseのインスタンスを引数として注入
 	userController := controller.NewUserController(userUsecase)
 	// NewTaskControllerを使ってtaskControllerのコンストラクターも起動
 	taskController := controller.NewTaskController(taskUsecase)
+	reminderController := controller.NewReminderController(reminderUsecase)
+	commentController := controller.NewCommentController(commentUsecase)
+	notificationController := controller.NewNotificationController(notificationUsecase)
+	attachmentController := controller.NewAttachmentController(attachmentUsecase, attachmentConfig.MaxBytes)
+	projectController := controller.NewProjectController(projectUsecase)
+	shareController := controller.NewShareController(shareUsecase)
+	shareLinkController := controller.NewShareLinkController(shareLinkUsecase)
+	organizationController := controller.NewOrganizationController(organizationUsecase)
+	timeEntryController := controller.NewTimeEntryController(timeEntryUsecase)
+	boardController := controller.NewBoardController(boardUsecase)
+	customFieldController := controller.NewCustomFieldController(customFieldUsecase)
+	viewController := controller.NewViewController(viewUsecase)
+	templateController := controller.NewTemplateController(templateUsecase)
+	importMaxBytes := int64(20 << 20)
+	if maxBytes, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_BYTES"), 10, 64); err == nil {
+		importMaxBytes = maxBytes
+	}
+	importController := controller.NewImportController(importUsecase, importMaxBytes)
+	personalTokenController := controller.NewPersonalTokenController(personalTokenUsecase)
+	calDAVController := controller.NewCalDAVController(calDAVUsecase)
+	calendarFeedController := controller.NewCalendarFeedController(calendarFeedUsecase)
+	mailInboxController := controller.NewMailInboxController(mailInboxUsecase)
+	webhookController := controller.NewWebhookController(webhookUsecase)
+	// WebSocketの接続はCORSと同じOriginからだけ受け付けます。
+	eventController := controller.NewEventController(eventUsecase, []string{"http://localhost:3000", os.Getenv("FE_URL")})
+	syncController := controller.NewSyncController(syncUsecase)
+	graphqlController := controller.NewGraphQLController(userUsecase, taskUsecase, projectUsecase, commentUsecase, eventUsecase,
+		[]string{"http://localhost:3000", os.Getenv("FE_URL")})
 	// routerパッケージの中に作っておいたNewRouter関数を呼び出す
 	// 外側でインスタンス化してるuserControllerを引数として注入
 	// taskControllerをNewRouterの第2引数に追加
-	e := router.NewRouter(userController, taskController)
+	e := router.NewRouter(userController, taskController, reminderController, commentController, notificationController, attachmentController,
+		projectController, shareController, shareLinkController, organizationController, timeEntryController,
+		boardController, customFieldController, viewController, templateController, importController,
+		personalTokenController, calDAVController, calendarFeedController, mailInboxController,
+		webhookController, eventController, syncController, graphqlController)
 	// echoのインスタンス(e)を使ってサーバーを起動
 	// e.Startでサーバーを起動し、port番号を8080番にして、
 	// エラーが発生した場合は、e.Loggerの機能を使ってログ情報出力した後にプログラムを強制終了
@@ -52,8 +239,120 @@ func main() {
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowCredentials: true,
 		AllowMethods:     []string{echo.GET, echo.POST, echo.PUT, echo.DELETE},
-		AllowHeaders:     []string{"X-Requested-With", "Content-Type", "X-CSRF-Token"},
+		AllowHeaders:     []string{"X-Requested-With", "Content-Type", "X-CSRF-Token", "X-Share-Password", "X-Organization-Id"},
 	}))
 
-	e.Logger.Fatal(e.Start(":8080"))
+	// リマインダーのスケジューラーをバックグラウンドで起動
+	// リマインダーのChannel(log / email / webhook)ごとに通知方法を切り替えるnotifierを作成して渡します。
+	reminderNotifier := notifier.NewChannelNotifier(map[string]notifier.INotifier{
+		"log": notifier.NewLogNotifier(),
+		"email": notifier.NewEmailNotifier(notifier.EmailConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			User:     os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PW"),
+			From:     os.Getenv("SMTP_FROM"),
+		}),
+		"webhook": notifier.NewWebhookNotifier(os.Getenv("REMINDER_WEBHOOK_URL")),
+	})
+	reminderInterval, err := time.ParseDuration(os.Getenv("REMINDER_INTERVAL"))
+	if err != nil {
+		reminderInterval = 30 * time.Second
+	}
+	reminderScheduler := scheduler.NewReminderScheduler(reminderRepository, reminderNotifier, reminderInterval)
+	go reminderScheduler.Start(ctx)
+	// 削除した添付ファイルの中身を、BlobStoreからバックグラウンドで削除
+	blobSweepInterval, err := time.ParseDuration(os.Getenv("BLOB_SWEEP_INTERVAL"))
+	if err != nil {
+		blobSweepInterval = time.Minute
+	}
+	blobSweeper := scheduler.NewBlobSweeper(attachmentRepository, blobStore, blobSweepInterval)
+	go blobSweeper.Start(ctx)
+	// タスクの順位の振り直しもバックグラウンドで定期的に実行
+	rebalanceInterval, err := time.ParseDuration(os.Getenv("POSITION_REBALANCE_INTERVAL"))
+	if err != nil {
+		rebalanceInterval = 10 * time.Minute
+	}
+	positionRebalancer := scheduler.NewPositionRebalancer(taskRepository, rebalanceInterval)
+	go positionRebalancer.Start(ctx)
+	// 他のツールからのインポートのジョブも、バックグラウンドのワーカーで実行
+	importInterval, err := time.ParseDuration(os.Getenv("IMPORT_INTERVAL"))
+	if err != nil {
+		importInterval = 5 * time.Second
+	}
+	importWorker := scheduler.NewImportWorker(importJobRepository, importUsecase, importInterval)
+	go importWorker.Start(ctx)
+	// タスクのイベントのアウトボックスをWebhookに振り分けて、失敗した配信のリトライも含めて送信するワーカー
+	webhookInterval, err := time.ParseDuration(os.Getenv("WEBHOOK_INTERVAL"))
+	if err != nil {
+		webhookInterval = 5 * time.Second
+	}
+	webhookDispatcher := scheduler.NewWebhookDispatcher(webhookUsecase, webhookInterval)
+	go webhookDispatcher.Start(ctx)
+	// INBOUND_SMTP_ADDR(例: :2525)を設定した場合だけ、メールを受け取ってタスクを作成するSMTPのサーバーを起動
+	if addr := os.Getenv("INBOUND_SMTP_ADDR"); addr != "" {
+		inboundMaxBytes := int64(20 << 20)
+		if maxBytes, err := strconv.ParseInt(os.Getenv("INBOUND_MAIL_MAX_BYTES"), 10, 64); err == nil {
+			inboundMaxBytes = maxBytes
+		}
+		smtpServer := &smtpd.Server{
+			Addr:          addr,
+			Domain:        inboundMailDomain,
+			MaxBytes:      inboundMaxBytes,
+			MaxRecipients: 50,
+			Timeout:       5 * time.Minute,
+			Backend:       mailInboxController,
+		}
+		go func() {
+			if err := smtpServer.ListenAndServe(ctx); err != nil {
+				log.Println("smtpd:", err)
+			}
+		}()
+	}
+
+	// GRPC_ADDR(例: :9090)を設定した場合だけ、社内のサービス向けのgRPCのサーバーをRESTのAPIとは別のポートで起動
+	var grpcServer *grpc.Server
+	if addr := os.Getenv("GRPC_ADDR"); addr != "" {
+		grpcListener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		grpcServer = router.NewGRPCServer(controller.NewGRPCAuth(organizationUsecase, []byte(os.Getenv("SECRET"))),
+			controller.NewTaskServer(taskUsecase, eventUsecase), controller.NewUserServer(userUsecase))
+		go func() {
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				log.Println("grpc:", err)
+			}
+		}()
+	}
+
+	go func() {
+		if err := e.Start(":8080"); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+	// 処理中のリクエストが終わるのを待ってからサーバーを止めます。
+	// /eventsやWatchTasksのような接続し続けるリクエストは終わらないので、shutdownTimeoutを過ぎたら接続を切ります。
+	const shutdownTimeout = 10 * time.Second
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	grpcStopped := make(chan struct{})
+	go func() {
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		close(grpcStopped)
+	}()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Println("shutdown:", err)
+		e.Close()
+	}
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+	}
 }
@@ -10,5 +10,27 @@ func main() {
 	dbConn := db.NewDB()
 	defer fmt.Println("Successfully Migrated")
 	defer db.CloseDB(dbConn)
-	dbConn.AutoMigrate(&model.User{}, &model.Task{})
+	dbConn.AutoMigrate(&model.User{}, &model.Organization{}, &model.Membership{}, &model.Invitation{}, &model.Project{}, &model.CustomField{}, &model.Board{}, &model.BoardColumn{}, &model.TaskSeries{}, &model.Task{}, &model.Share{}, &model.Reminder{},
+		&model.Comment{}, &model.CommentRevision{}, &model.Mention{}, &model.Notification{}, &model.Attachment{}, &model.BlobDeletion{}, &model.TaskVersion{},
+		&model.ShareLink{}, &model.TaskAssignment{}, &model.TimeEntry{}, &model.View{}, &model.Template{}, &model.ImportJob{},
+		&model.PersonalToken{}, &model.CalDAVResource{}, &model.CalendarFeed{}, &model.MailInbox{},
+		&model.Webhook{}, &model.TaskOutboxEvent{}, &model.WebhookDelivery{})
+	// タスクに付くテーブルにorganization_idを追加する前に作成された行には、タスク(プロジェクト)の組織を設定します。
+	// 何度実行しても同じ結果になるように、まだ設定されていない行だけを更新します。
+	backfills := []string{
+		`UPDATE comments SET organization_id = tasks.organization_id FROM tasks WHERE tasks.id = comments.task_id AND comments.organization_id IS NULL`,
+		`UPDATE attachments SET organization_id = tasks.organization_id FROM tasks WHERE tasks.id = attachments.task_id AND attachments.organization_id IS NULL`,
+		`UPDATE reminders SET organization_id = tasks.organization_id FROM tasks WHERE tasks.id = reminders.task_id AND reminders.organization_id IS NULL`,
+		`UPDATE shares SET organization_id = tasks.organization_id FROM tasks WHERE tasks.id = shares.task_id AND shares.organization_id IS NULL`,
+		`UPDATE shares SET organization_id = projects.organization_id FROM projects WHERE projects.id = shares.project_id AND shares.organization_id IS NULL`,
+		`UPDATE share_links SET organization_id = tasks.organization_id FROM tasks WHERE tasks.id = share_links.task_id AND share_links.organization_id IS NULL`,
+		`UPDATE notifications SET organization_id = tasks.organization_id FROM tasks WHERE tasks.id = notifications.task_id AND notifications.organization_id IS NULL`,
+		// 変更履歴は削除されたタスクの分も残っているので、スナップショットの組織を使います。
+		`UPDATE task_versions SET organization_id = (snapshot->>'organization_id')::bigint WHERE organization_id IS NULL AND snapshot->>'organization_id' IS NOT NULL`,
+	}
+	for _, sql := range backfills {
+		if err := dbConn.Exec(sql).Error; err != nil {
+			fmt.Println(err)
+		}
+	}
 }
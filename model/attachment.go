@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// Attachmentはタスクに添付されたファイル
+// ファイルの中身はBlobStore(storageパッケージ)にStorageKeyで保存し、テーブルにはメタデータだけを保存します。
+type Attachment struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	FileName     string    `json:"file_name" gorm:"not null"`
+	ContentType  string    `json:"content_type" gorm:"not null"`
+	Size         int64     `json:"size" gorm:"not null"`
+	SHA256       string    `json:"sha256" gorm:"column:sha256;not null;index"`
+	StorageKey   string    `json:"-" gorm:"not null"`
+	ThumbnailKey string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	Task         Task      `json:"task" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId       uint      `json:"task_id" gorm:"not null;index"`
+	User         User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId       uint      `json:"user_id" gorm:"not null"`
+	// OrganizationIdは添付先のタスクの組織(個人のタスクの場合はnil)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+}
+
+// BlobDeletionは削除した添付ファイルの、BlobStoreに残っている中身のキー
+// 添付ファイルの行を削除するのと同じトランザクションで作成して、BlobSweeperがBlobStoreから削除できたら消します。
+// BlobStoreの削除に失敗しても、ここに残るので中身が削除されないまま忘れられることはありません。
+type BlobDeletion struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	StorageKey string    `json:"storage_key" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type AttachmentResponse struct {
+	ID           uint      `json:"id"`
+	TaskId       uint      `json:"task_id"`
+	UserId       uint      `json:"user_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+	CreatedAt    time.Time `json:"created_at"`
+}
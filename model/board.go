@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+// Boardはタスクを列(BoardColumn)に並べて表示するカンバンボード
+// プロジェクトを指定したボードは、プロジェクトに対する権限でアクセスでき、そのプロジェクトのタスクだけを並べられます。
+type Board struct {
+	ID        uint     `json:"id" gorm:"primaryKey"`
+	Name      string   `json:"name" gorm:"not null"`
+	ProjectId *uint    `json:"project_id" gorm:"index"`
+	Project   *Project `json:"-" gorm:"foreignKey:ProjectId; constraint:OnDelete:CASCADE"`
+	// OrganizationIdはボードが属する組織(tenantパッケージがリクエストの組織を設定します)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	User           User          `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint          `json:"user_id" gorm:"not null;index"`
+}
+
+// BoardColumnはボードの列
+// Positionはボードの中の列の順番(rankパッケージで計算)で、WipLimitは列に置けるタスクの数の上限(nullの場合は上限無し)です。
+type BoardColumn struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Position  string    `json:"position" gorm:"not null;default:''"`
+	WipLimit  *int      `json:"wip_limit"`
+	BoardId   uint      `json:"board_id" gorm:"not null;index"`
+	Board     *Board    `json:"-" gorm:"foreignKey:BoardId; constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BoardColumnMoveRequestは列の並び替えのリクエスト(タスクの並び替えと同じく、Beforeの直前かAfterの直後に移動)
+type BoardColumnMoveRequest struct {
+	Before *uint `json:"before"`
+	After  *uint `json:"after"`
+}
+
+// BoardTaskMoveRequestはタスクをボードの列に移動するリクエスト
+// ColumnIdの列の、Beforeで指定したタスクの直前かAfterで指定したタスクの直後に移動します(どちらも省略した場合は列の末尾)。
+type BoardTaskMoveRequest struct {
+	ColumnId uint  `json:"column_id"`
+	Before   *uint `json:"before"`
+	After    *uint `json:"after"`
+}
+
+type BoardColumnResponse struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Position string `json:"position"`
+	WipLimit *int   `json:"wip_limit"`
+	// TaskCountは列に置かれているタスクの数(WIPの上限と比べる数で、見えないタスクも含みます)
+	TaskCount int64          `json:"task_count"`
+	Tasks     []TaskResponse `json:"tasks"`
+}
+
+type BoardResponse struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	ProjectId      *uint  `json:"project_id,omitempty"`
+	OrganizationId *uint  `json:"organization_id,omitempty"`
+	UserId         uint   `json:"user_id"`
+	// Roleはログインしているユーザーのこのボードに対する権限(owner、editor、viewer)
+	Role string `json:"role"`
+	// Columnsはボードの詳細を取得した時だけ、列とその中のタスクを順番に並べて返します。
+	Columns   []BoardColumnResponse `json:"columns,omitempty"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// CalDAVResourceはCalDAVのクライアントが作成したタスクの、クライアントが決めたリソースの名前とUID
+// サーバーが作成したタスクはこの行が無く、名前はtask-<ID>.ics、UIDはTaskUIDになります。
+// 削除したタスクの名前も同期で「削除された」と返すために、tasksテーブルへの外部キー制約は付けずに残します。
+type CalDAVResource struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null;uniqueIndex"`
+	Uid       string    `json:"uid" gorm:"not null"`
+	TaskId    uint      `json:"task_id" gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CalDAVTasksCalendarは自分が作成したタスクのカレンダーの名前
+// プロジェクトのカレンダーの名前はproject-<プロジェクトのID>です。
+const CalDAVTasksCalendar = "tasks"
+
+// CalDAVCalendarはCalDAVのカレンダー(VTODOのコレクション)
+type CalDAVCalendar struct {
+	Name        string
+	DisplayName string
+	ProjectId   *uint
+	// SyncTokenはカレンダーの今の同期トークン(タスクの変更履歴の最新のID)
+	SyncToken uint
+}
+
+// CalDAVObjectはカレンダーの中の1つのVTODO(タスク)
+type CalDAVObject struct {
+	Name string
+	Uid  string
+	ETag string
+	Task TaskResponse
+}
+
+// CalDAVChangesは同期トークンの時点から変更のあったVTODO
+// Deletedは削除されたか、カレンダーに当てはまらなくなったVTODOの名前です。
+type CalDAVChanges struct {
+	SyncToken uint
+	Changed   []CalDAVObject
+	Deleted   []string
+}
+
+// TaskUIDはサーバーが作成したタスクのiCalendarのUID(エクスポートとCalDAVで同じ値を使います)
+func TaskUID(taskId uint) string {
+	return fmt.Sprintf("task-%d@go-rest-api", taskId)
+}
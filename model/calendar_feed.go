@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// CalendarFeedはカレンダーのアプリから購読する、期限日のあるタスクのiCalendarのフィード
+// フィードのURLに含める秘密のトークンは、SHA-256のハッシュだけを保存します(1人に1つで、作り直すと古いURLは使えなくなります)。
+type CalendarFeed struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	TokenHash      string     `json:"-" gorm:"not null;uniqueIndex"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	User           User       `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint       `json:"user_id" gorm:"not null;uniqueIndex"`
+}
+
+// CalendarFeedFilterはフィードのURLのクエリパラメーターで指定する絞り込みの条件
+type CalendarFeedFilter struct {
+	ProjectId *uint
+	// Tagはタスクのタグ(検索クエリのtag:と同じ)
+	Tag string
+	// IncludeCompletedがtrueの場合は、完了したタスクも含める
+	IncludeCompleted bool
+}
+
+type CalendarFeedResponse struct {
+	// TokenとPathはトークンを作り直した時だけ返します。
+	Token          string     `json:"token,omitempty"`
+	Path           string     `json:"path,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+type Comment struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Body string `json:"body" gorm:"not null"`
+	// EditedAtはコメントが最後に編集された日時(編集されていない場合はnil)
+	EditedAt  *time.Time        `json:"edited_at"`
+	Mentions  []Mention         `json:"mentions" gorm:"foreignKey:CommentId; constraint:OnDelete:CASCADE"`
+	Revisions []CommentRevision `json:"-" gorm:"foreignKey:CommentId; constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Task      Task              `json:"task" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId    uint              `json:"task_id" gorm:"not null;index"`
+	User      User              `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint              `json:"user_id" gorm:"not null"`
+	// OrganizationIdはコメントを付けたタスクの組織(個人のタスクの場合はnil)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+}
+
+// CommentRevisionはコメントが編集される前の本文を保存しておくための履歴
+type CommentRevision struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Body      string    `json:"body" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	CommentId uint      `json:"comment_id" gorm:"not null;index"`
+}
+
+// Mentionはコメントの本文の中で@メールアドレスの形でメンションされたユーザー
+type Mention struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	CommentId uint      `json:"comment_id" gorm:"not null;uniqueIndex:idx_mention_comment_user"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_mention_comment_user"`
+}
+
+type CommentResponse struct {
+	ID          uint       `json:"id"`
+	TaskId      uint       `json:"task_id"`
+	UserId      uint       `json:"user_id"`
+	AuthorEmail string     `json:"author_email"`
+	Body        string     `json:"body"`
+	Edited      bool       `json:"edited"`
+	EditedAt    *time.Time `json:"edited_at"`
+	Mentions    []string   `json:"mentions"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type CommentRevisionResponse struct {
+	ID        uint      `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CustomFieldはプロジェクトごとに定義するタスクの独自の項目(ストーリーポイント、顧客、重要度など)
+// 値はタスクのCustomFieldsに、項目のIDをキーにして保存します。
+type CustomField struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"not null;uniqueIndex:idx_custom_field_name"`
+	// Typeは値の種類(text、number、date、select、multi_select)で、作成した後は変更できません。
+	Type string `json:"type" gorm:"not null"`
+	// Optionsはselectとmulti_selectで選べる値の一覧
+	Options   []string  `json:"options" gorm:"serializer:json;type:jsonb;not null;default:'[]'"`
+	Required  bool      `json:"required" gorm:"not null;default:false"`
+	ProjectId uint      `json:"project_id" gorm:"not null;uniqueIndex:idx_custom_field_name"`
+	Project   *Project  `json:"-" gorm:"foreignKey:ProjectId; constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 独自の項目の値の種類
+const (
+	CustomFieldText        = "text"
+	CustomFieldNumber      = "number"
+	CustomFieldDate        = "date"
+	CustomFieldSelect      = "select"
+	CustomFieldMultiSelect = "multi_select"
+)
+
+// CustomFieldDateLayoutはdateの項目の値の形式
+const CustomFieldDateLayout = "2006-01-02"
+
+type CustomFieldResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Options   []string  `json:"options"`
+	Required  bool      `json:"required"`
+	ProjectId uint      `json:"project_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CustomFieldValuesはタスクの独自の項目の値(項目のIDの文字列 → 値)
+// textとselectは文字列、numberは数値、dateはYYYY-MM-DDの文字列、multi_selectは文字列の配列で保存します。
+// mapのままUpdatesに渡せるように、jsonbとの変換はValueとScanで行います。
+type CustomFieldValues map[string]interface{}
+
+func (v CustomFieldValues) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (v *CustomFieldValues) Scan(src interface{}) error {
+	var b []byte
+	switch s := src.(type) {
+	case nil:
+		*v = CustomFieldValues{}
+		return nil
+	case []byte:
+		b = s
+	case string:
+		b = []byte(s)
+	default:
+		return fmt.Errorf("unsupported type for custom fields: %T", src)
+	}
+	values := CustomFieldValues{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+	*v = values
+	return nil
+}
+
+// CustomFieldFilterはタスクの一覧を独自の項目の値で絞り込む条件
+// Opはeq(一致、multi_selectの場合は値を含む)、gte(以上)、lte(以下)のいずれかで、gteとlteはnumberとdateの項目だけで使えます。
+type CustomFieldFilter struct {
+	FieldId uint
+	Op      string
+	Value   string
+	// Typeは項目の定義から設定する値の種類
+	Type string
+}
+
+// 独自の項目の絞り込みの条件の種類
+const (
+	CustomFieldOpEq  = "eq"
+	CustomFieldOpGte = "gte"
+	CustomFieldOpLte = "lte"
+)
@@ -0,0 +1,82 @@
+package model
+
+import "time"
+
+// 他のツールのエクスポートファイルの種類
+const (
+	// ImportSourceTodoistはTodoistのプロジェクトのCSV(テンプレートのエクスポート)
+	ImportSourceTodoist = "todoist"
+	// ImportSourceTrelloはTrelloのボードのJSON
+	ImportSourceTrello = "trello"
+	// ImportSourceMicrosoftTodoはMicrosoft To DoのリストのJSON(Microsoft Graphのtodo/listsとtasksの形)
+	ImportSourceMicrosoftTodo = "microsoft_todo"
+)
+
+// インポートのジョブの状態
+const (
+	ImportJobPending   = "pending"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// ImportJobは他のツールのエクスポートファイルからのインポートを、バックグラウンドで実行するジョブ
+// アップロードしたファイルはBlobStoreにStorageKeyで保存しておき、ジョブが終わったら削除します。
+type ImportJob struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	Source     string `json:"source" gorm:"not null"`
+	FileName   string `json:"file_name" gorm:"not null"`
+	StorageKey string `json:"-" gorm:"not null"`
+	Status     string `json:"status" gorm:"not null;default:'pending';index"`
+	// Totalはファイルの中のタスクの数、Processedはそのうち処理が終わった数
+	Total      int `json:"total" gorm:"not null;default:0"`
+	Processed  int `json:"processed" gorm:"not null;default:0"`
+	Created    int `json:"created" gorm:"not null;default:0"`
+	Duplicates int `json:"duplicates" gorm:"not null;default:0"`
+	Failed     int `json:"failed" gorm:"not null;default:0"`
+	// Errorsは作成できなかったタスクと、読み込めなかった値の一覧(最大MaxImportJobErrors件)
+	Errors []ImportJobError `json:"errors" gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	// ProjectIdsはインポートで作成したプロジェクト
+	ProjectIds []uint `json:"project_ids" gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	// Errorはジョブ全体が失敗した理由(ファイルを読み込めなかった場合など)
+	Error string `json:"error" gorm:"not null;default:''"`
+	// OrganizationIdはジョブが属する組織で、タスクとプロジェクトもこの組織に作成します。
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	StartedAt      *time.Time    `json:"started_at"`
+	FinishedAt     *time.Time    `json:"finished_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	User           User          `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint          `json:"user_id" gorm:"not null;index"`
+}
+
+// ImportJobErrorはインポートで作成できなかったタスク(または読み込めなかった値)
+type ImportJobError struct {
+	Project string `json:"project"`
+	Row     int    `json:"row"`
+	Title   string `json:"title"`
+	Error   string `json:"error"`
+}
+
+// MaxImportJobErrorsはジョブに記録するエラーの最大の件数
+const MaxImportJobErrors = 1000
+
+type ImportJobResponse struct {
+	ID         uint             `json:"id"`
+	Source     string           `json:"source"`
+	FileName   string           `json:"file_name"`
+	Status     string           `json:"status"`
+	Total      int              `json:"total"`
+	Processed  int              `json:"processed"`
+	Created    int              `json:"created"`
+	Duplicates int              `json:"duplicates"`
+	Failed     int              `json:"failed"`
+	Errors     []ImportJobError `json:"errors"`
+	ProjectIds []uint           `json:"project_ids"`
+	Error      string           `json:"error,omitempty"`
+	StartedAt  *time.Time       `json:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// MailInboxはメールを転送してタスクを作成するための、ユーザーごとの秘密の受信アドレス
+// アドレスはユーザーがメールの転送の設定に使うので、トークンはハッシュにせずに保存して何度でも表示できるようにします。
+type MailInbox struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// Tokenは受信アドレスの@より前の部分
+	Token string `json:"-" gorm:"not null;uniqueIndex"`
+	// AllowedSendersはタスクを作成できる送信者のメールアドレス、または"@example.com"の形のドメイン
+	// 空の場合は、ユーザー自身のメールアドレスからのメールだけを受け付けます。
+	AllowedSenders []string  `json:"allowed_senders" gorm:"serializer:json;type:jsonb;not null;default:'[]'"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	User           User      `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint      `json:"user_id" gorm:"not null;uniqueIndex"`
+}
+
+// MaxAllowedSendersは受信アドレスに登録できる送信者の最大数
+const MaxAllowedSenders = 100
+
+// MailInboxRequestは受信アドレスの送信者の変更のリクエスト
+type MailInboxRequest struct {
+	AllowedSenders []string `json:"allowed_senders"`
+}
+
+type MailInboxResponse struct {
+	Address        string    `json:"address"`
+	AllowedSenders []string  `json:"allowed_senders"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// InboundMailは受け取ったメールから取り出した、タスクにする内容
+type InboundMail struct {
+	// EnvelopeFromはSMTPのMAIL FROMの送信者、HeaderFromはメールのFromヘッダーの送信者
+	EnvelopeFrom string
+	HeaderFrom   string
+	Subject      string
+	Text         string
+	Attachments  []InboundMailAttachment
+}
+
+type InboundMailAttachment struct {
+	FileName string
+	Data     []byte
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// Notificationはユーザーへのお知らせ(アプリ内の通知一覧に表示するエントリー)
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	Kind      string     `json:"kind" gorm:"not null"`
+	Message   string     `json:"message" gorm:"not null"`
+	TaskId    *uint      `json:"task_id"`
+	CommentId *uint      `json:"comment_id"`
+	ActorId   *uint      `json:"actor_id"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	User      User       `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint       `json:"user_id" gorm:"not null;index"`
+	// OrganizationIdは通知の元になったタスクの組織(個人のタスクの通知と、組織への招待の通知はnil)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+}
+
+// 通知の種類
+const (
+	NotificationMention = "mention"
+	NotificationShare   = "share"
+	// NotificationInvitationは組織への招待のお知らせ
+	NotificationInvitation = "invitation"
+	// NotificationAssignmentはタスクの担当者が変わったお知らせ
+	NotificationAssignment = "assignment"
+)
+
+type NotificationResponse struct {
+	ID        uint       `json:"id"`
+	Kind      string     `json:"kind"`
+	Message   string     `json:"message"`
+	TaskId    *uint      `json:"task_id"`
+	CommentId *uint      `json:"comment_id"`
+	ActorId   *uint      `json:"actor_id"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,94 @@
+package model
+
+import "time"
+
+// Organizationは複数のユーザーでタスクとプロジェクトを管理する組織(テナント)
+// 組織のタスク・プロジェクトは、その組織のメンバー以外からは見えません。
+type Organization struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Membershipはユーザーが組織に所属していることと、組織の中での権限を表す
+type Membership struct {
+	ID             uint         `json:"id" gorm:"primaryKey"`
+	Role           string       `json:"role" gorm:"not null"`
+	Organization   Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	OrganizationId uint         `json:"organization_id" gorm:"not null;uniqueIndex:idx_membership_org_user"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	User           User         `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint         `json:"user_id" gorm:"not null;uniqueIndex:idx_membership_org_user;index"`
+}
+
+// 組織の中での権限の種類
+// ownerは組織の削除まで、adminはメンバーの管理と組織の全てのタスク・プロジェクトの操作ができます。
+// memberは自分のタスクと、共有されたタスク・プロジェクトだけを扱えます。
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Invitationは組織への招待
+// 招待のトークンはハッシュ(SHA-256)だけを保存し、トークン自体は作成した時のレスポンスでだけ返します。
+type Invitation struct {
+	ID             uint         `json:"id" gorm:"primaryKey"`
+	Email          string       `json:"email" gorm:"not null"`
+	Role           string       `json:"role" gorm:"not null"`
+	TokenHash      string       `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt      time.Time    `json:"expires_at" gorm:"not null"`
+	AcceptedAt     *time.Time   `json:"accepted_at"`
+	Organization   Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	OrganizationId uint         `json:"organization_id" gorm:"not null;index"`
+	CreatedAt      time.Time    `json:"created_at"`
+	// Userは招待したユーザー
+	User   User `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId uint `json:"user_id" gorm:"not null"`
+}
+
+type OrganizationResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	// Roleはログインしているユーザーのこの組織での権限(owner、admin、member)
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MemberRequestはメンバーの権限を変更するリクエスト
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+type MemberResponse struct {
+	UserId    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InvitationRequestは招待のリクエスト(招待する相手はメールアドレスで指定します)
+type InvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AcceptInvitationRequestは招待を受け入れるリクエスト
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+type InvitationResponse struct {
+	ID    uint   `json:"id"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	// Tokenは招待を作成した時のレスポンスでだけ返します。
+	Token          string     `json:"token,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	OrganizationId uint       `json:"organization_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// PersonalTokenはCalDAVのクライアントなど、クッキーでログインできないアプリから使う個人用のアクセストークン
+// データベースにはトークンのSHA-256のハッシュだけを保存するので、トークン自体は作成した時にしか分かりません。
+type PersonalToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"not null"`
+	TokenHash  string     `json:"-" gorm:"not null;uniqueIndex"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	User       User       `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId     uint       `json:"user_id" gorm:"not null;index"`
+}
+
+type PersonalTokenResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	// Tokenは作成した時だけ返します(一覧では空になります)。
+	Token      string     `json:"token,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// Projectは複数のタスクをまとめるプロジェクト(リスト)
+// プロジェクトを共有すると、その中のタスクもまとめて共有されます。
+type Project struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"not null"`
+	// OrganizationIdはプロジェクトが属する組織(未設定の場合は個人のプロジェクト)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	User           User          `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint          `json:"user_id" gorm:"not null;index"`
+}
+
+type ProjectResponse struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	UserId uint   `json:"user_id"`
+	// OrganizationIdはプロジェクトが属する組織(個人のプロジェクトの場合は省略)
+	OrganizationId *uint `json:"organization_id,omitempty"`
+	// Roleはログインしているユーザーのこのプロジェクトに対する権限(owner、editor、viewer)
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
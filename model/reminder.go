@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// Reminderはタスクのリマインダー
+// RemindAt(絶対時刻)かOffsetMinutes(期限日の何分前か)のどちらか一方を指定します。
+type Reminder struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	RemindAt      *time.Time `json:"remind_at"`
+	OffsetMinutes *int       `json:"offset_minutes"`
+	// FireAtは実際に通知する時刻で、期限日が未設定のオフセット指定のリマインダーはnilになります。
+	FireAt *time.Time `json:"fire_at" gorm:"index"`
+	// ClaimedAtはスケジューラーが配信のために確保した日時(配信中の目印で、結果を記録するとnilに戻ります)
+	ClaimedAt *time.Time `json:"-"`
+	// Channelは通知方法(log / email / webhook)、Targetは送信先のメールアドレスやURL
+	Channel   string     `json:"channel" gorm:"not null;default:log"`
+	Target    string     `json:"target"`
+	SentAt    *time.Time `json:"sent_at"`
+	FailedAt  *time.Time `json:"failed_at"`
+	Attempts  int        `json:"attempts" gorm:"not null;default:0"`
+	LastError string     `json:"last_error"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Task      Task       `json:"task" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId    uint       `json:"task_id" gorm:"not null"`
+	User      User       `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint       `json:"user_id" gorm:"not null"`
+	// OrganizationIdはリマインダーを設定したタスクの組織(個人のタスクの場合はnil)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+}
+
+// ReminderRequestはリマインダーの作成のリクエスト
+// 通知時刻(fire_at)や送信の結果(sent_at・attempts)はサーバーで設定するので受け取りません。
+type ReminderRequest struct {
+	RemindAt      *time.Time `json:"remind_at"`
+	OffsetMinutes *int       `json:"offset_minutes"`
+	Channel       string     `json:"channel"`
+	Target        string     `json:"target"`
+}
+
+type ReminderResponse struct {
+	ID            uint       `json:"id"`
+	TaskId        uint       `json:"task_id"`
+	RemindAt      *time.Time `json:"remind_at"`
+	OffsetMinutes *int       `json:"offset_minutes"`
+	FireAt        *time.Time `json:"fire_at"`
+	Channel       string     `json:"channel"`
+	Target        string     `json:"target"`
+	SentAt        *time.Time `json:"sent_at"`
+	FailedAt      *time.Time `json:"failed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// Shareはタスクまたはプロジェクトを他のユーザーと共有する設定
+// TaskIdとProjectIdのどちらか一方だけを設定します。
+type Share struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Role      string    `json:"role" gorm:"not null"`
+	TaskId    *uint     `json:"task_id" gorm:"uniqueIndex:idx_share_task_user"`
+	Task      *Task     `json:"-" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	ProjectId *uint     `json:"project_id" gorm:"uniqueIndex:idx_share_project_user"`
+	Project   *Project  `json:"-" gorm:"foreignKey:ProjectId; constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Userは共有された相手のユーザー
+	User   User `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId uint `json:"user_id" gorm:"not null;uniqueIndex:idx_share_task_user;uniqueIndex:idx_share_project_user"`
+	// OrganizationIdは共有したタスク・プロジェクトの組織(個人のものの場合はnil)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+}
+
+// 権限の種類
+// ownerはタスク・プロジェクトの作成者の権限で、共有の設定には使えません。
+// assigneeはタスクの担当者の権限で、閲覧と完了状態の更新ができます(共有の設定には使えません)。
+const (
+	RoleViewer   = "viewer"
+	RoleAssignee = "assignee"
+	RoleEditor   = "editor"
+	RoleOwner    = "owner"
+)
+
+// ShareRequestは共有のリクエスト(共有する相手はメールアドレスで指定します)
+type ShareRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type ShareResponse struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	TaskId    *uint     `json:"task_id,omitempty"`
+	ProjectId *uint     `json:"project_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// ShareLinkはアカウントを持っていない人にタスクを見せるための公開リンク
+// リンクのトークンはIDと有効期限を署名したJWTで、データベースにはトークン自体は保存しません。
+type ShareLink struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// PasswordHashはパスワード保護する場合のbcryptのハッシュ(保護しない場合は空文字)
+	PasswordHash string     `json:"-"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ViewCount    int64      `json:"view_count" gorm:"not null;default:0"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Task         Task       `json:"task" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId       uint       `json:"task_id" gorm:"not null;index"`
+	// Userはリンクを作成したユーザー
+	User   User `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId uint `json:"user_id" gorm:"not null"`
+	// OrganizationIdはリンクのタスクの組織(個人のタスクの場合はnil)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+}
+
+// ShareLinkRequestは公開リンクの作成のリクエスト
+// ExpiresAtを省略した場合は7日後に期限切れになります。
+type ShareLinkRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	Password  string     `json:"password"`
+}
+
+type ShareLinkResponse struct {
+	ID uint `json:"id"`
+	// Tokenは作成した時だけ返します(一覧では空になります)。
+	Token        string     `json:"token,omitempty"`
+	TaskId       uint       `json:"task_id"`
+	HasPassword  bool       `json:"has_password"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ViewCount    int64      `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// PublicTaskResponseは公開リンクからログインしていない人に返すタスク
+// 作成者・担当者・プロジェクト・組織などの内部の情報を誤って返さないように、返す項目だけを持つ型にしています。
+type PublicTaskResponse struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date"`
+}
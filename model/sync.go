@@ -0,0 +1,169 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SyncServerNodeはAPI(Webの画面やCalDAVなど)からの変更を記録する時のノードのID
+// オフラインで変更するクライアントは、POST /syncのclient_idで自分のノードのIDを名乗ります。
+const SyncServerNode = "server"
+
+// 同期するタスクの項目(項目ごとに最後に書いた変更を残す、last-writer-winsの単位)
+// 担当者・順番・ボードの列は専用のエンドポイントで変更するので、同期の対象にしません。
+const (
+	SyncFieldTitle        = "title"
+	SyncFieldDescription  = "description"
+	SyncFieldCompleted    = "completed"
+	SyncFieldDueDate      = "due_date"
+	SyncFieldProjectId    = "project_id"
+	SyncFieldCustomFields = "custom_fields"
+	SyncFieldTags         = "tags"
+)
+
+var SyncFields = []string{SyncFieldTitle, SyncFieldDescription, SyncFieldCompleted, SyncFieldDueDate, SyncFieldProjectId, SyncFieldCustomFields, SyncFieldTags}
+
+// VersionVectorはタスクのバージョンベクター(ノードのID → そのノードがタスクを変更した回数)
+// クライアントは取得した時点のベクターを変更と一緒に送り、サーバーはそれを見て変更が並行しているかを判定します。
+// mapのままUpdatesに渡せるように、jsonbとの変換はValueとScanで行います。
+type VersionVector map[string]uint64
+
+func (v VersionVector) Value() (driver.Value, error) {
+	return jsonbValue(v)
+}
+
+func (v *VersionVector) Scan(src interface{}) error {
+	vector := VersionVector{}
+	if err := scanJSONB(src, &vector); err != nil {
+		return err
+	}
+	*v = vector
+	return nil
+}
+
+// FieldClockは項目を最後に変更したノードと、その時のノードの回数(Counter)・変更した日時(At)
+type FieldClock struct {
+	Node    string    `json:"node"`
+	Counter uint64    `json:"counter"`
+	At      time.Time `json:"at"`
+}
+
+// FieldClocksは項目の名前(SyncFields) → 項目を最後に変更したFieldClock
+type FieldClocks map[string]FieldClock
+
+func (c FieldClocks) Value() (driver.Value, error) {
+	return jsonbValue(c)
+}
+
+func (c *FieldClocks) Scan(src interface{}) error {
+	clocks := FieldClocks{}
+	if err := scanJSONB(src, &clocks); err != nil {
+		return err
+	}
+	*c = clocks
+	return nil
+}
+
+// SyncOriginはPOST /syncで受け取った変更の、クライアントのノードのIDとクライアントで変更した日時
+// タスクのリポジトリは、これが設定されていない変更をSyncServerNodeの今の日時の変更として記録します。
+type SyncOrigin struct {
+	Node string
+	At   time.Time
+}
+
+// 同期で受け取る変更の種類
+const (
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+)
+
+// SyncRequestはPOST /syncで受け取る、クライアントがオフラインの間に行った変更のまとめ
+// Changesは変更を行った順番に並べて、その順番で適用します。
+type SyncRequest struct {
+	ClientId string       `json:"client_id"`
+	Changes  []SyncChange `json:"changes"`
+}
+
+// MaxSyncChangesは1回のPOST /syncで受け取る変更の最大の数
+const MaxSyncChanges = 500
+
+// SyncChangeはクライアントの1つの変更
+// createではClientRef(クライアントが付けた仮のID)、updateとdeleteではTaskIdでタスクを指定します。
+// BaseVectorはクライアントが最後に取得した時点のタスクのバージョンベクター、ModifiedAtはクライアントで変更した日時です。
+// Fieldsには変更した項目(SyncFields)だけを入れます。
+type SyncChange struct {
+	Op         string                     `json:"op"`
+	TaskId     uint                       `json:"task_id"`
+	ClientRef  string                     `json:"client_ref"`
+	BaseVector VersionVector              `json:"base_vector"`
+	ModifiedAt time.Time                  `json:"modified_at"`
+	Fields     map[string]json.RawMessage `json:"fields"`
+}
+
+// 変更ごとの適用の結果
+const (
+	// SyncStatusAppliedは変更の全ての項目を適用した
+	SyncStatusApplied = "applied"
+	// SyncStatusMergedは並行した変更があり、一部の項目はサーバーの値を残した(Conflictsがサーバーの値を残した項目)
+	SyncStatusMerged = "merged"
+	// SyncStatusRejectedは並行した変更の方が新しいので、変更を適用しなかった
+	SyncStatusRejected = "rejected"
+	// SyncStatusFailedは権限やバリデーションのエラーで適用できなかった(Errorが理由)
+	SyncStatusFailed = "failed"
+)
+
+// SyncChangeResultはPOST /syncで受け取った変更ごとの結果
+// Taskは適用した後のサーバーのタスクで、クライアントはこれで自分のタスクを置き換えます(削除した場合は省略)。
+type SyncChangeResult struct {
+	Index     int           `json:"index"`
+	Op        string        `json:"op"`
+	TaskId    uint          `json:"task_id,omitempty"`
+	ClientRef string        `json:"client_ref,omitempty"`
+	Status    string        `json:"status"`
+	Conflicts []string      `json:"conflicts,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Task      *TaskResponse `json:"task,omitempty"`
+}
+
+type SyncPushResponse struct {
+	Results []SyncChangeResult `json:"results"`
+}
+
+// SyncPullResponseはGET /syncで返す、同期トークンの時点から変更のあったタスク
+// Createdはトークンの時点の後に作成されたタスク、Updatedはそれ以外の変更されたタスク、
+// Deletedは削除されたか、同期の範囲(自分のタスク・プロジェクト)から外れたタスクのID(tombstone)です。
+type SyncPullResponse struct {
+	SyncToken string         `json:"sync_token"`
+	Created   []TaskResponse `json:"created"`
+	Updated   []TaskResponse `json:"updated"`
+	Deleted   []uint         `json:"deleted"`
+}
+
+// jsonbValueはmapをjsonbの値にする(nilの場合は空のオブジェクト)
+func jsonbValue(v interface{}) (driver.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "null" {
+		return "{}", nil
+	}
+	return string(b), nil
+}
+
+// scanJSONBはjsonbの値をdestに読み込む
+func scanJSONB(src interface{}, dest interface{}) error {
+	switch s := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(s, dest)
+	case string:
+		return json.Unmarshal([]byte(s), dest)
+	default:
+		return fmt.Errorf("unsupported type for jsonb: %T", src)
+	}
+}
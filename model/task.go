@@ -1,19 +1,260 @@
 package model
 
-import "time"
+import (
+	"database/sql/driver"
+	"fmt"
+	"go-rest-api/taskquery"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Task struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Title     string    `json:"title" gorm:"not null"`
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Title string `json:"title" gorm:"not null"`
+	// Descriptionはタスクの説明(メールから作成したタスクの場合はメールの本文)
+	Description string     `json:"description" gorm:"not null;default:''"`
+	Completed   bool       `json:"completed" gorm:"not null;default:false"`
+	DueDate     *time.Time `json:"due_date"`
+	// 繰り返しタスクの場合は、生成元のシリーズ(TaskSeries)と
+	// シリーズの中で何回目の発生か(RFC 5545のRECURRENCE-ID)を保持します。
+	SeriesId     *uint       `json:"series_id"`
+	Series       *TaskSeries `json:"-" gorm:"foreignKey:SeriesId; constraint:OnDelete:SET NULL"`
+	RecurrenceId *time.Time  `json:"recurrence_id"`
+	// ProjectIdはタスクが属するプロジェクト(未設定の場合はどのプロジェクトにも属さない)
+	ProjectId *uint    `json:"project_id" gorm:"index"`
+	Project   *Project `json:"-" gorm:"foreignKey:ProjectId; constraint:OnDelete:SET NULL"`
+	// OrganizationIdはタスクが属する組織(未設定の場合は個人のタスク)
+	// tenantパッケージがリクエストの組織を自動的に設定するので、リクエストでは受け取りません。
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	// AssigneeIdはタスクの担当者(未設定の場合は担当者無し)
+	// 担当者はタスクの作成時と担当者の変更のエンドポイントで設定し、タスクの更新のリクエストでは変更しません。
+	AssigneeId *uint `json:"assignee_id" gorm:"index"`
+	Assignee   *User `json:"-" gorm:"foreignKey:AssigneeId; constraint:OnDelete:SET NULL"`
+	// ParentIdはサブタスクの場合の親のタスク(サブタスクの下にはサブタスクを作れません)
+	// 担当者と同じように作成時だけ設定し、タスクの更新のリクエストでは変更しません。
+	ParentId *uint `json:"parent_id" gorm:"index"`
+	Parent   *Task `json:"-" gorm:"foreignKey:ParentId; constraint:OnDelete:SET NULL"`
+	// Positionはユーザーが並び替えた順番を表す順位の文字列(rankパッケージで計算)
+	Position string `json:"position" gorm:"not null;default:'';index"`
+	// ColumnIdはタスクが置かれているボードの列、ColumnPositionは列の中の順番(rankパッケージで計算)
+	// WIPの上限を守るために、ボードのタスクの移動のエンドポイントでだけ変更します。
+	ColumnId       *uint        `json:"column_id" gorm:"index"`
+	Column         *BoardColumn `json:"-" gorm:"foreignKey:ColumnId; constraint:OnDelete:SET NULL"`
+	ColumnPosition string       `json:"column_position" gorm:"not null;default:''"`
+	// CustomFieldsはプロジェクトで定義した独自の項目の値(項目のIDの文字列 → 値)
+	// 絞り込みで@>の検索にGINのインデックスを使えるようにします。
+	CustomFields CustomFieldValues `json:"custom_fields" gorm:"type:jsonb;not null;default:'{}';index:,type:gin"`
+	// Tagsはタスクに付けたタグ(更新のリクエストで省略した場合は今のタグを引き継ぎます)
+	// 検索クエリのtag:で@>の検索にGINのインデックスを使えるようにします。
+	Tags TaskTags `json:"tags" gorm:"type:jsonb;not null;default:'[]';index:,type:gin"`
+	// SyncVectorはオフラインの同期のためのバージョンベクター、FieldClocksは項目ごとに最後に変更したノードと日時
+	// どちらもタスクのリポジトリが作成・更新の時に書き換えるので、リクエストでは受け取りません。
+	SyncVector  VersionVector `json:"-" gorm:"type:jsonb;not null;default:'{}'"`
+	FieldClocks FieldClocks   `json:"-" gorm:"type:jsonb;not null;default:'{}'"`
+	// SyncOriginはPOST /syncで受け取った変更の場合だけ設定します(保存しません)。
+	SyncOrigin *SyncOrigin `json:"-" gorm:"-"`
+	// RRuleとTimezoneはリクエストで受け取るだけで、tasksテーブルには保存せずシリーズ側に保存します。
+	RRule     string    `json:"rrule" gorm:"-"`
+	Timezone  string    `json:"timezone" gorm:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
-	UserId    uint      `json:"user_id" gorm:"not null"`
+	// DeletedAtはタスクを削除した日時(論理削除)
+	// GET /syncで削除されたタスク(tombstone)を返せるように、削除しても行は残します。
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	User      User           `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint           `json:"user_id" gorm:"not null"`
+}
+
+// TaskTagsはタスクのタグの一覧
+// スライスのままUpdatesに渡せるように、jsonbとの変換はValueとScanで行います。
+type TaskTags []string
+
+func (t TaskTags) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	return jsonbValue([]string(t))
 }
 
+func (t *TaskTags) Scan(src interface{}) error {
+	tags := TaskTags{}
+	if err := scanJSONB(src, &tags); err != nil {
+		return err
+	}
+	*t = tags
+	return nil
+}
+
+// MaxTaskTitleRunesはタスクのタイトルの最大の文字数
+const MaxTaskTitleRunes = 10
+
+// MaxTaskDescriptionRunesはタスクの説明の最大の文字数
+const MaxTaskDescriptionRunes = 10000
+
 type TaskResponse struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Title     string    `json:"title" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Title        string     `json:"title" gorm:"not null"`
+	Description  string     `json:"description,omitempty"`
+	Completed    bool       `json:"completed"`
+	DueDate      *time.Time `json:"due_date"`
+	RRule        string     `json:"rrule,omitempty"`
+	Timezone     string     `json:"timezone,omitempty"`
+	SeriesId     *uint      `json:"series_id,omitempty"`
+	RecurrenceId *time.Time `json:"recurrence_id,omitempty"`
+	Position     string     `json:"position"`
+	ProjectId    *uint      `json:"project_id,omitempty"`
+	// OrganizationIdはタスクが属する組織(個人のタスクの場合は省略)
+	OrganizationId *uint  `json:"organization_id,omitempty"`
+	AssigneeId     *uint  `json:"assignee_id,omitempty"`
+	ParentId       *uint  `json:"parent_id,omitempty"`
+	ColumnId       *uint  `json:"column_id,omitempty"`
+	ColumnPosition string `json:"column_position,omitempty"`
+	// CustomFieldsは独自の項目の値(値が無い場合は省略)
+	CustomFields CustomFieldValues `json:"custom_fields,omitempty"`
+	Tags         TaskTags          `json:"tags"`
+	// VersionVectorはオフラインのクライアントがPOST /syncの変更と一緒に送り返すバージョンベクター
+	VersionVector VersionVector `json:"version_vector,omitempty"`
+	UserId        uint          `json:"user_id,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// TaskMoveRequestはタスクの並び替えのリクエスト
+// Beforeで指定したタスクの直前、Afterで指定したタスクの直後に移動します(どちらか一方だけでも可)。
+type TaskMoveRequest struct {
+	Before *uint `json:"before"`
+	After  *uint `json:"after"`
+}
+
+// タスクの一覧の絞り込みの種類
+const (
+	// TaskScopeOwnedは自分が作成したタスク(デフォルト)
+	TaskScopeOwned = "owned"
+	// TaskScopeSharedは他のユーザーから共有されたタスク("shared with me")
+	TaskScopeShared = "shared"
+	// TaskScopeAllは自分のタスクと共有されたタスクの両方
+	TaskScopeAll = "all"
+)
+
+// TaskFilterはタスクの一覧を取得する時の絞り込みの条件
+type TaskFilter struct {
+	Scope     string
+	ProjectId *uint
+	// AssigneeIdが指定された場合は、そのユーザーが担当者のタスクに絞り込む
+	AssigneeId *uint
+	// CustomFieldsは独自の項目の値での絞り込み(全ての条件に当てはまるタスクに絞り込む)
+	CustomFields []CustomFieldFilter
+	// Sortは並び順(未指定の場合はユーザーが並び替えた順番)
+	Sort TaskSort
+	// Queryは検索クエリ(例: status:open priority>=2 due<7d)での絞り込み
+	// ユースケースでパースして、SQLの条件に変換するための情報と一緒にParsedQueryに設定します。
+	Query       string
+	ParsedQuery *TaskQuery
+	// TaskIdsが指定された場合は、そのIDのタスクに絞り込む(同期で変更のあったタスクを取得するのに使います)
+	TaskIds []uint
+	// ProjectIdsが指定された場合は、それらのプロジェクトのタスクに絞り込む(/graphqlでプロジェクトのタスクをまとめて取得するのに使います)
+	ProjectIds []uint
+}
+
+// TaskChangesは同期トークンの時点から変更のあったタスク
+// Changedは今も絞り込みの条件に当てはまるタスク、Deletedは削除されたか条件に当てはまらなくなったタスクのIDです。
+type TaskChanges struct {
+	SyncToken uint
+	Changed   []TaskResponse
+	Deleted   []uint
+}
+
+// 検索クエリで使えるタスクの項目のキー(それ以外のキーは独自の項目の名前として扱います)
+// tagはタスクのタグを見るので、tagという名前の独自の項目はfield.<項目のID>で指定します。
+const (
+	TaskQueryStatus   = "status"
+	TaskQueryTitle    = "title"
+	TaskQueryDue      = "due"
+	TaskQueryCreated  = "created"
+	TaskQueryProject  = "project"
+	TaskQueryAssignee = "assignee"
+	TaskQueryTag      = "tag"
+)
+
+// TaskQueryはパースした検索クエリと、クエリをSQLの条件に変換するのに必要な情報
+type TaskQuery struct {
+	Node taskquery.Node
+	// UserIdはassignee:meのユーザー、Nowはdue<7dのような相対的な日時の基準
+	UserId uint
+	Now    time.Time
+	// Fieldsはクエリのキーに使われた名前(またはfield.<ID>)の、見えるプロジェクトの独自の項目の定義
+	// 同じ名前の項目が複数のプロジェクトにある場合は、どれかの項目の条件を満たすタスクに絞り込みます。
+	Fields []CustomField
+}
+
+// 並び順に指定できるタスクの項目
+const (
+	TaskSortPosition  = "position"
+	TaskSortCreatedAt = "created_at"
+	TaskSortDueDate   = "due_date"
+	TaskSortTitle     = "title"
+	// TaskSortCustomFieldはFieldIdの独自の項目の値の順番
+	TaskSortCustomField = "field"
+)
+
+// TaskSortはタスクの一覧の並び順
+// 値が無いタスクは、昇順・降順のどちらでも末尾に並べます。
+type TaskSort struct {
+	Key       string
+	FieldId   uint
+	FieldType string
+	Desc      bool
+}
+
+// ParseTaskSortはsortのクエリパラメーター(position、created_at、due_date、title、field.<項目のID>)を並び順に変換する
+// 先頭に-を付けると降順になります。
+func ParseTaskSort(value string) (TaskSort, error) {
+	sort := TaskSort{Key: TaskSortPosition}
+	if value == "" {
+		return sort, nil
+	}
+	if strings.HasPrefix(value, "-") {
+		sort.Desc = true
+		value = strings.TrimPrefix(value, "-")
+	}
+	switch value {
+	case TaskSortPosition, TaskSortCreatedAt, TaskSortDueDate, TaskSortTitle:
+		sort.Key = value
+	default:
+		fieldId, err := strconv.ParseUint(strings.TrimPrefix(value, "field."), 10, 64)
+		if !strings.HasPrefix(value, "field.") || err != nil {
+			return TaskSort{}, fmt.Errorf("sort must be position, created_at, due_date, title or field.<id>")
+		}
+		sort.Key = TaskSortCustomField
+		sort.FieldId = uint(fieldId)
+	}
+	return sort, nil
+}
+
+// TaskAssignRequestは担当者を変更するリクエスト(assignee_idがnullの場合は担当者を外す)
+type TaskAssignRequest struct {
+	AssigneeId *uint `json:"assignee_id"`
+}
+
+// TaskAssignmentはタスクの担当者の変更履歴
+type TaskAssignment struct {
+	ID     uint  `json:"id" gorm:"primaryKey"`
+	TaskId uint  `json:"task_id" gorm:"not null;index"`
+	Task   *Task `json:"-" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	// AssigneeIdは変更後の担当者、PreviousAssigneeIdは変更前の担当者(担当者無しの場合はnull)
+	AssigneeId         *uint     `json:"assignee_id"`
+	PreviousAssigneeId *uint     `json:"previous_assignee_id"`
+	ActorId            uint      `json:"actor_id" gorm:"not null"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+type TaskAssignmentResponse struct {
+	AssigneeId         *uint     `json:"assignee_id"`
+	PreviousAssigneeId *uint     `json:"previous_assignee_id"`
+	ActorId            uint      `json:"actor_id"`
+	CreatedAt          time.Time `json:"created_at"`
 }
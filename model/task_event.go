@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// TaskChangeEventはタスクの作成・更新・削除を、/eventsに接続しているクライアントにリアルタイムで送るイベント
+// EventはWebhookと同じtask.created・task.updated・task.deletedで、変更履歴を戻した場合もtask.updatedにします。
+// (削除されたタスクを戻した場合もtask.updatedなので、クライアントは知らないタスクのtask.updatedを追加として扱います。)
+type TaskChangeEvent struct {
+	Event  string `json:"event"`
+	TaskId uint   `json:"task_id"`
+	// Taskは変更した後のタスク(削除の場合は削除する前のタスク)
+	// PostgreSQLのNOTIFYで送れる大きさを超える場合は省略するので、その場合はクライアントがタスクを取得し直します。
+	Task *TaskResponse `json:"task,omitempty"`
+	// UserId・AssigneeId・ProjectId・OrganizationIdは変更の時点のタスクの値で、どのユーザーに送るかの判定に使います。
+	UserId         uint      `json:"user_id"`
+	AssigneeId     *uint     `json:"assignee_id,omitempty"`
+	ProjectId      *uint     `json:"project_id,omitempty"`
+	OrganizationId *uint     `json:"organization_id,omitempty"`
+	ActorId        uint      `json:"actor_id"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
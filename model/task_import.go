@@ -0,0 +1,67 @@
+package model
+
+import "time"
+
+// タスクのエクスポート・インポートの形式
+const (
+	TaskFormatJSON = "json"
+	TaskFormatCSV  = "csv"
+	TaskFormatICS  = "ics"
+)
+
+// TaskImportItemはインポートする1つのタスクで、エクスポートしたJSONの1件と同じ形です。
+// IDや作成者・順位などのエクスポートにしか無い項目は、インポートでは読み込みません。
+// 繰り返し(rrule)・担当者・ボードの列はインポートしないので、必要な場合は作成した後に設定します。
+type TaskImportItem struct {
+	Title        string            `json:"title"`
+	Description  string            `json:"description"`
+	Completed    bool              `json:"completed"`
+	DueDate      *time.Time        `json:"due_date"`
+	ProjectId    *uint             `json:"project_id"`
+	CustomFields CustomFieldValues `json:"custom_fields"`
+	Tags         TaskTags          `json:"tags"`
+	// ParentIdは他のツールのチェックリストをサブタスクにする時の親のタスク
+	// エクスポートしたJSONのparent_idは元のタスクのIDなので、ファイルからは読み込みません。
+	ParentId *uint `json:"-"`
+}
+
+// TaskImportRowはインポートするファイルの1行(1件)
+// Errorはファイルの読み込みの時点で見つかった誤りで、Errorがある行は作成しません。
+type TaskImportRow struct {
+	Row   int
+	Item  TaskImportItem
+	Error string
+}
+
+// インポートの各行の結果
+const (
+	// TaskImportCreatedは作成したタスク
+	TaskImportCreated = "created"
+	// TaskImportValidはdry_runで、作成できることを確認したタスク
+	TaskImportValid = "valid"
+	// TaskImportDuplicateは同じタスクが既にある(またはファイルの前の行にある)ので作成しなかったタスク
+	TaskImportDuplicate = "duplicate"
+	// TaskImportErrorは誤りがあって作成しなかったタスク
+	TaskImportError = "error"
+)
+
+// TaskImportRowResultはインポートの1行ごとの結果
+type TaskImportRowResult struct {
+	Row    int    `json:"row"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	TaskId uint   `json:"task_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TaskImportResultはインポートの結果
+// DryRunの場合は何も作成せずに、作成できるかどうかだけを各行の結果で返します。
+type TaskImportResult struct {
+	DryRun     bool                  `json:"dry_run"`
+	Total      int                   `json:"total"`
+	Created    int                   `json:"created"`
+	Valid      int                   `json:"valid"`
+	Duplicates int                   `json:"duplicates"`
+	Errors     int                   `json:"errors"`
+	Rows       []TaskImportRowResult `json:"rows"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// TaskSeriesは繰り返しタスクのテンプレート
+// 完了したタスクの次の回は、このシリーズのRRuleとTimezoneから計算して生成します。
+type TaskSeries struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Title    string `json:"title" gorm:"not null"`
+	RRule    string `json:"rrule" gorm:"column:rrule;not null"`
+	Timezone string `json:"timezone" gorm:"not null"`
+	// DTStartはシリーズの最初の発生日時(RRuleの展開の起点)
+	DTStart   time.Time `json:"dtstart" gorm:"column:dtstart;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null"`
+}
@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+// TaskVersionはタスクの変更履歴の1件
+// タスクが削除された後も履歴から復元できるように、tasksテーブルへの外部キー制約は付けません。
+type TaskVersion struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	TaskId  uint   `json:"task_id" gorm:"not null;uniqueIndex:idx_task_version"`
+	Version int    `json:"version" gorm:"not null;uniqueIndex:idx_task_version"`
+	Action  string `json:"action" gorm:"not null"`
+	// Changesはフィールドごとの変更前後の値(map[string]FieldChange)、
+	// Snapshotは変更後(削除の場合は削除前)のタスクの内容(TaskSnapshot)をJSONで保存します。
+	Changes   string    `json:"changes" gorm:"type:jsonb;not null"`
+	Snapshot  string    `json:"snapshot" gorm:"type:jsonb;not null"`
+	ActorId   uint      `json:"actor_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	// OrganizationIdは履歴を記録した時のタスクの組織(個人のタスクの場合はnil)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+}
+
+// 履歴の操作の種類
+const (
+	TaskActionCreate  = "create"
+	TaskActionUpdate  = "update"
+	TaskActionDelete  = "delete"
+	TaskActionRestore = "restore"
+)
+
+// TaskSnapshotは履歴に保存するタスクのフィールド
+type TaskSnapshot struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date"`
+	Position    string     `json:"position"`
+	ProjectId   *uint      `json:"project_id"`
+	AssigneeId  *uint      `json:"assignee_id"`
+	// CustomFieldsは独自の項目の値
+	CustomFields CustomFieldValues `json:"custom_fields"`
+	Tags         TaskTags          `json:"tags"`
+	// OrganizationIdは削除されたタスクの履歴を、別の組織から見たり復元したりできないようにするために保存します。
+	OrganizationId *uint      `json:"organization_id"`
+	SeriesId       *uint      `json:"series_id"`
+	RecurrenceId   *time.Time `json:"recurrence_id"`
+	UserId         uint       `json:"user_id"`
+}
+
+// TaskVersionFilterは変更のあったタスクを探す時の、履歴のスナップショットの条件
+// OrganizationIdがnilの場合は、個人のタスクの履歴に絞り込みます。
+type TaskVersionFilter struct {
+	UserId         *uint
+	ProjectId      *uint
+	OrganizationId *uint
+}
+
+// FieldChangeは1つのフィールドの変更前後の値
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+type TaskVersionResponse struct {
+	Version   int                    `json:"version"`
+	Action    string                 `json:"action"`
+	Changes   map[string]FieldChange `json:"changes"`
+	ActorId   uint                   `json:"actor_id"`
+	CreatedAt time.Time              `json:"created_at"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// Templateは同じ組み合わせのタスクをまとめて作成するためのテンプレート
+// Itemsの1つ1つが作成するタスクで、タイトルと文字列の項目の値には{{date}}のようなプレースホルダーを書けます。
+// テンプレートは作成したユーザーだけが使えます。
+type Template struct {
+	ID    uint           `json:"id" gorm:"primaryKey"`
+	Name  string         `json:"name" gorm:"not null"`
+	Items []TemplateItem `json:"items" gorm:"type:jsonb;not null;serializer:json"`
+	// ProjectIdはタスクを作成するプロジェクト(nullの場合はプロジェクトに属さないタスク)
+	ProjectId *uint    `json:"project_id" gorm:"index"`
+	Project   *Project `json:"-" gorm:"foreignKey:ProjectId; constraint:OnDelete:SET NULL"`
+	// OrganizationIdはテンプレートが属する組織(tenantパッケージがリクエストの組織を設定します)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	User           User          `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint          `json:"user_id" gorm:"not null;index"`
+}
+
+// TemplateItemはテンプレートから作成する1つのタスク
+type TemplateItem struct {
+	Title string `json:"title"`
+	// DueInDaysは期限日を、インスタンス化する日付(date)の何日後にするか(nullの場合は期限日なし)
+	DueInDays    *int              `json:"due_in_days"`
+	CustomFields CustomFieldValues `json:"custom_fields"`
+}
+
+type TemplateResponse struct {
+	ID        uint           `json:"id"`
+	Name      string         `json:"name"`
+	Items     []TemplateItem `json:"items"`
+	ProjectId *uint          `json:"project_id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// TemplateInstantiateRequestはテンプレートからタスクを作成する時のリクエスト
+type TemplateInstantiateRequest struct {
+	// Dateは{{date}}と期限日の基準にする日付(YYYY-MM-DD、省略した場合は今日)
+	Date string `json:"date"`
+	// Variablesは{{date}}以外のプレースホルダーに入れる値(例: {"name": "Alice"}で{{name}}を置き換えます)
+	Variables map[string]string `json:"variables"`
+}
+
+// TaskDuplicateRequestはタスクを複製する時のリクエスト
+type TaskDuplicateRequest struct {
+	// Titleは複製したタスクのタイトル(省略した場合は元のタスクと同じ)
+	Title               *string `json:"title"`
+	IncludeCustomFields bool    `json:"include_custom_fields"`
+	IncludeTags         bool    `json:"include_tags"`
+	IncludeAttachments  bool    `json:"include_attachments"`
+	// IncludeSubtasksがtrueの場合は、サブタスクも複製したタスクのサブタスクとして複製します。
+	// サブタスクのタグ・独自の項目の値・添付ファイルも、親のタスクと同じ指定でコピーします。
+	IncludeSubtasks bool `json:"include_subtasks"`
+}
+
+// TemplateDateLayoutはTemplateInstantiateRequest.Dateと{{date}}の形式
+const TemplateDateLayout = "2006-01-02"
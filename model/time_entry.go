@@ -0,0 +1,96 @@
+package model
+
+import "time"
+
+// TimeEntryはタスクにかかった作業時間の記録
+// タイマーで記録する場合は、開始した時にEndedAtがnullのエントリーを作り、停止した時にEndedAtを設定します。
+// 1人のユーザーが同時に動かせるタイマーは1つだけなので、EndedAtがnullのエントリーはユーザーごとに1件までです。
+type TimeEntry struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Description string     `json:"description" gorm:"not null;default:''"`
+	StartedAt   time.Time  `json:"started_at" gorm:"not null;index"`
+	EndedAt     *time.Time `json:"ended_at"`
+	// Tagsは集計に使うタグ(billable、meetingなど)をJSONの配列で保存します。
+	Tags      []string  `json:"tags" gorm:"serializer:json;type:jsonb;not null;default:'[]'"`
+	Task      *Task     `json:"-" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId    uint      `json:"task_id" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// OrganizationIdはタスクと同じように、tenantパッケージがリクエストの組織を設定します。
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	User           User          `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint          `json:"user_id" gorm:"not null;index;uniqueIndex:idx_time_entry_running,where:ended_at IS NULL"`
+}
+
+// TimeEntryRequestは作業時間を手動で追加・編集するリクエスト
+type TimeEntryRequest struct {
+	Description string     `json:"description"`
+	StartedAt   *time.Time `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at"`
+	Tags        []string   `json:"tags"`
+}
+
+// TimerRequestはタイマーを開始するリクエスト(省略可)
+type TimerRequest struct {
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
+type TimeEntryResponse struct {
+	ID          uint       `json:"id"`
+	Description string     `json:"description"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at"`
+	// Secondsは作業時間の秒数(タイマーが動いている場合は現在までの秒数)
+	Seconds   int64     `json:"seconds"`
+	Tags      []string  `json:"tags"`
+	TaskId    uint      `json:"task_id"`
+	UserId    uint      `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 作業時間のレポートの集計の単位
+const (
+	TimeGroupByDay     = "day"
+	TimeGroupByTask    = "task"
+	TimeGroupByProject = "project"
+	TimeGroupByTag     = "tag"
+)
+
+// TimeReportRequestは作業時間のレポートのクエリパラメーター
+// fromとtoはYYYY-MM-DDの日付で、toの日も含めて集計します。timezoneは日付の区切りに使うタイムゾーン(省略時はUTC)です。
+type TimeReportRequest struct {
+	From     string `query:"from"`
+	To       string `query:"to"`
+	GroupBy  string `query:"group_by"`
+	Timezone string `query:"timezone"`
+	Format   string `query:"format"`
+}
+
+// TimeReportFilterは作業時間のレポートの条件
+// FromからToの直前までに開始した作業時間を集計します。日ごとの集計ではTimezoneの日付を使います。
+type TimeReportFilter struct {
+	From     time.Time
+	To       time.Time
+	GroupBy  string
+	Timezone string
+}
+
+// TimeReportRowはレポートの1行(集計の単位ごとの合計)
+// Keyは日付(YYYY-MM-DD)、タスクID、プロジェクトID、タグ名のいずれかで、Labelは表示用の名前です。
+type TimeReportRow struct {
+	Key     string `json:"key"`
+	Label   string `json:"label"`
+	Seconds int64  `json:"seconds"`
+	Entries int64  `json:"entries"`
+}
+
+type TimeReportResponse struct {
+	From         string          `json:"from"`
+	To           string          `json:"to"`
+	GroupBy      string          `json:"group_by"`
+	TotalSeconds int64           `json:"total_seconds"`
+	Rows         []TimeReportRow `json:"rows"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Viewは名前を付けて保存したタスクの一覧の条件(保存したビュー)
+// Queryは検索クエリ(taskqueryパッケージ)、Sortは並び順(GET /tasksのsortと同じ形式)で、ビューは作成したユーザーだけが使えます。
+type View struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Name  string `json:"name" gorm:"not null"`
+	Query string `json:"query" gorm:"not null;default:''"`
+	Sort  string `json:"sort" gorm:"not null;default:''"`
+	// Scopeは対象にするタスクの一覧(owned・shared・all、未指定の場合はall)
+	Scope string `json:"scope" gorm:"not null;default:'all'"`
+	// OrganizationIdはビューが属する組織(tenantパッケージがリクエストの組織を設定します)
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	User           User          `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint          `json:"user_id" gorm:"not null;index"`
+}
+
+type ViewResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	Sort      string    `json:"sort"`
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
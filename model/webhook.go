@@ -0,0 +1,145 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhookで通知するタスクのイベントの種類
+const (
+	TaskEventCreated = "task.created"
+	TaskEventUpdated = "task.updated"
+	TaskEventDeleted = "task.deleted"
+	// TaskEventCompletedは未完了のタスクが完了になった時のイベント(task.updatedも同時に通知します)
+	TaskEventCompleted = "task.completed"
+)
+
+// TaskEventsはWebhookで選べるイベントの一覧
+var TaskEvents = []string{TaskEventCreated, TaskEventUpdated, TaskEventDeleted, TaskEventCompleted}
+
+// Webhookの配信の状態
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhookはタスクのイベントを通知する外部のサービスのURL
+// 配信するリクエストの本文には、Secretを鍵にしたHMAC-SHA256の署名をヘッダーで付けます。
+// 署名の検証にSecret自体が必要なので、ハッシュではなくそのまま保存します。
+type Webhook struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	URL    string `json:"url" gorm:"not null"`
+	Secret string `json:"-" gorm:"not null"`
+	// Eventsは通知するイベントの種類(TaskEventsのいずれか)
+	Events []string `json:"events" gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	Active bool     `json:"active" gorm:"not null;default:true"`
+	// OrganizationIdはWebhookが属する組織で、この組織のタスクのイベントだけを通知します(未設定の場合は個人のタスク)。
+	OrganizationId *uint         `json:"organization_id" gorm:"index"`
+	Organization   *Organization `json:"-" gorm:"foreignKey:OrganizationId; constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	User           User          `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId         uint          `json:"user_id" gorm:"not null;index"`
+}
+
+// WebhookRequestはWebhookの登録・更新のリクエスト
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Activeは省略した場合、登録では有効、更新では変更しません。
+	Active *bool `json:"active"`
+}
+
+type WebhookResponse struct {
+	ID     uint     `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	// Secretは署名の鍵で、登録した時と作り直した時だけ返します(一覧では空になります)。
+	Secret         string    `json:"secret,omitempty"`
+	OrganizationId *uint     `json:"organization_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// TaskOutboxEventはtaskRepositoryでタスクを変更したのと同じトランザクションで書き込む、タスクのイベント(トランザクショナルアウトボックス)
+// タスクの変更がロールバックされた場合はイベントも残らず、コミットされた変更のイベントは必ず配信の対象になります。
+// Webhookへの振り分け(WebhookDeliveryの作成)が終わったらProcessedAtを設定します。
+type TaskOutboxEvent struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	Event  string `json:"event" gorm:"not null"`
+	TaskId uint   `json:"task_id" gorm:"not null"`
+	// UserId・AssigneeId・ProjectId・OrganizationIdはイベントの時点のタスクの値で、
+	// 削除されたタスクでも、Webhookの登録者がタスクを見られるかを判定するために保持します。
+	UserId         uint  `json:"user_id" gorm:"not null"`
+	AssigneeId     *uint `json:"assignee_id"`
+	ProjectId      *uint `json:"project_id"`
+	OrganizationId *uint `json:"organization_id"`
+	// Taskはイベントの時点のタスク(TaskResponseのJSON)
+	// 配信する本文(TaskEventPayload)は、Webhookへの振り分けの時にイベントのIDなどと合わせて作ります。
+	Task        string     `json:"task" gorm:"type:jsonb;not null"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ProcessedAt *time.Time `json:"processed_at" gorm:"index"`
+}
+
+// TaskEventPayloadはWebhookで配信するリクエストの本文
+type TaskEventPayload struct {
+	// IDはイベントのID(再配信でも変わらないので、受け取る側で重複の判定に使えます)
+	ID         uint         `json:"id"`
+	Event      string       `json:"event"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	Task       TaskResponse `json:"task"`
+}
+
+// WebhookDeliveryはWebhookへの1回分の配信(配信のログ)
+// 失敗した場合はNextAttemptAtを指数的に後ろにずらしてリトライし、MaxWebhookAttempts回失敗したら諦めます。
+type WebhookDelivery struct {
+	ID        uint    `json:"id" gorm:"primaryKey"`
+	Webhook   Webhook `json:"-" gorm:"foreignKey:WebhookId; constraint:OnDelete:CASCADE"`
+	WebhookId uint    `json:"webhook_id" gorm:"not null;index"`
+	EventId   uint    `json:"event_id" gorm:"not null"`
+	Event     string  `json:"event" gorm:"not null"`
+	// Payloadは送信する本文(TaskEventPayloadのJSON)で、署名もこの文字列に対して計算します。
+	Payload  string `json:"payload" gorm:"type:jsonb;not null"`
+	Status   string `json:"status" gorm:"not null;default:'pending'"`
+	Attempts int    `json:"attempts" gorm:"not null;default:0"`
+	// NextAttemptAtは次に送信する時刻(成功・失敗が確定した配信はnil)
+	NextAttemptAt *time.Time `json:"next_attempt_at" gorm:"index"`
+	// ClaimedAtはワーカーが送信のために確保した日時(送信中の目印で、結果を記録するとnilに戻ります)
+	ClaimedAt *time.Time `json:"-"`
+	// LastStatusCode・LastResponse・LastErrorは最後に送信した時の応答(LastResponseは先頭の一部だけ)
+	LastStatusCode int        `json:"last_status_code" gorm:"not null;default:0"`
+	LastResponse   string     `json:"last_response" gorm:"not null;default:''"`
+	LastError      string     `json:"last_error" gorm:"not null;default:''"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// MaxWebhookAttemptsは配信に失敗した時にリトライする最大回数
+const MaxWebhookAttempts = 8
+
+// WebhookDeliveryResultは1回の送信の結果
+type WebhookDeliveryResult struct {
+	StatusCode int
+	Response   string
+	Err        error
+}
+
+// WebhookDeliveryResponseは配信のログの1件
+type WebhookDeliveryResponse struct {
+	ID             uint            `json:"id"`
+	WebhookId      uint            `json:"webhook_id"`
+	EventId        uint            `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code"`
+	LastResponse   string          `json:"last_response"`
+	LastError      string          `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
@@ -0,0 +1,101 @@
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddressはユーザーが指定したURLの接続先が、サーバーの内部のネットワークのアドレスだった場合のエラー
+var ErrForbiddenAddress = errors.New("destination address is not allowed")
+
+// blockedNetworksはnet.IPのメソッドでは判定できない、インターネット上ではないアドレスの範囲
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",      // このネットワーク
+	"100.64.0.0/10",  // キャリアグレードNAT
+	"192.0.0.0/24",   // IETFプロトコル割り当て
+	"198.18.0.0/15",  // ベンチマーク
+	"240.0.0.0/4",    // 予約済み
+	"64:ff9b::/96",   // NAT64(中にIPv4のアドレスを埋め込めます)
+	"64:ff9b:1::/48", // ローカルのNAT64
+	"2002::/16",      // 6to4(中にIPv4のアドレスを埋め込めます)
+	"fec0::/10",      // サイトローカル(廃止)
+	"100::/64",       // 破棄専用
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// IsPublicIPはipがインターネット上のアドレスか判定する
+// ループバック・プライベート・リンクローカル・マルチキャストなど、サーバーの内部に届くアドレスはfalseです。
+func IsPublicIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range blockedNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckHostはURLのホスト名が、名前を解決しなくても内部のネットワークと分かるものでないか確認する
+// バリデーションで早めにエラーを返すためのもので、DNSで内部のアドレスに解決される名前は接続する時(Control)に弾きます。
+func CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil && !IsPublicIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Controlはnet.DialerのControlに設定して、名前を解決した後の実際の接続先が内部のアドレスの場合は接続しない
+// リダイレクトやDNSの応答の差し替え(DNSリバインディング)の後の接続も、全てここを通ります。
+func Control(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// NewHTTPClientはユーザーが指定したURLに送信するためのhttp.Clientを返す
+// 接続先が内部のアドレスの場合は送信しません。環境変数のプロキシを経由すると接続先を確認できないので、プロキシは使いません。
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second, Control: Control}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
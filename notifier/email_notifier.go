@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/model"
+	"mime"
+	"net/smtp"
+	"strings"
+)
+
+// EmailConfigはSMTPサーバーの接続情報
+type EmailConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+type emailNotifier struct {
+	config EmailConfig
+}
+
+// NewEmailNotifierはSMTPでリマインダーをメール送信するINotifierを返す
+func NewEmailNotifier(config EmailConfig) INotifier {
+	return &emailNotifier{config}
+}
+
+func (en *emailNotifier) Notify(ctx context.Context, reminder model.Reminder) error {
+	if en.config.Host == "" {
+		return fmt.Errorf("smtp host is not configured")
+	}
+	// リマインダーはユーザー自身のメールアドレスにだけ送ります(他人のアドレスに送る踏み台にならないように)。
+	// 作成の時にも確認していますが、それより前に作られたリマインダーもここで弾きます。
+	to := reminder.User.Email
+	if reminder.Target != "" && !strings.EqualFold(reminder.Target, to) {
+		return fmt.Errorf("email reminders can only be sent to the user's own address")
+	}
+	text := message(reminder)
+	// 件名にはユーザーが付けたタスクのタイトルが入るので、改行でヘッダーを追加されないように改行を空白にして、
+	// 日本語も送れるようにRFC 2047の形式でエンコードします。
+	subject := mime.QEncoding.Encode("utf-8", strings.NewReplacer("\r", " ", "\n", " ").Replace(text))
+	body := strings.Join([]string{
+		"From: " + en.config.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		text,
+	}, "\r\n")
+	var auth smtp.Auth
+	if en.config.User != "" {
+		auth = smtp.PlainAuth("", en.config.User, en.config.Password, en.config.Host)
+	}
+	return smtp.SendMail(en.config.Host+":"+en.config.Port, auth, en.config.From, []string{to}, []byte(body))
+}
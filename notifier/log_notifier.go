@@ -0,0 +1,19 @@
+package notifier
+
+import (
+	"context"
+	"go-rest-api/model"
+	"log"
+)
+
+type logNotifier struct{}
+
+// NewLogNotifierは標準のログにリマインダーを出力するだけのINotifierを返す
+func NewLogNotifier() INotifier {
+	return &logNotifier{}
+}
+
+func (ln *logNotifier) Notify(ctx context.Context, reminder model.Reminder) error {
+	log.Printf("[reminder] user=%s task=%d %s", reminder.User.Email, reminder.TaskId, message(reminder))
+	return nil
+}
@@ -0,0 +1,40 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/model"
+)
+
+// INotifierはリマインダーを配信するためのインターフェース
+// 引数で受け取るリマインダーには、TaskとUserが読み込まれている前提です。
+type INotifier interface {
+	Notify(ctx context.Context, reminder model.Reminder) error
+}
+
+// channelNotifierはリマインダーのChannel(log / email / webhook)に応じて配信先を切り替える
+type channelNotifier struct {
+	notifiers map[string]INotifier
+}
+
+// NewChannelNotifierはチャンネル名と通知方法の対応を受け取り、それらを束ねたINotifierを返す
+func NewChannelNotifier(notifiers map[string]INotifier) INotifier {
+	return &channelNotifier{notifiers}
+}
+
+func (cn *channelNotifier) Notify(ctx context.Context, reminder model.Reminder) error {
+	n, ok := cn.notifiers[reminder.Channel]
+	if !ok {
+		return fmt.Errorf("unknown channel %s", reminder.Channel)
+	}
+	return n.Notify(ctx, reminder)
+}
+
+// messageは通知の本文を作成する
+func message(reminder model.Reminder) string {
+	msg := fmt.Sprintf("Reminder: %s", reminder.Task.Title)
+	if reminder.Task.DueDate != nil {
+		msg += fmt.Sprintf(" (due %s)", reminder.Task.DueDate.Format("2006-01-02 15:04 MST"))
+	}
+	return msg
+}
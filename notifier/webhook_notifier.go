@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/netguard"
+	"net/http"
+	"time"
+)
+
+type webhookNotifier struct {
+	// clientはユーザーが指定したURLに送信するクライアントで、サーバーの内部のアドレスには接続しません。
+	client *http.Client
+	// defaultClientはサーバーの設定のdefaultURLに送信するクライアント(社内のサービスも指定できます)
+	defaultClient *http.Client
+	defaultURL    string
+}
+
+// NewWebhookNotifierはリマインダーをJSONでPOSTするINotifierを返す
+// リマインダーにTargetが無い場合はdefaultURLに送信します。
+func NewWebhookNotifier(defaultURL string) INotifier {
+	return &webhookNotifier{netguard.NewHTTPClient(10 * time.Second), &http.Client{Timeout: 10 * time.Second}, defaultURL}
+}
+
+func (wn *webhookNotifier) Notify(ctx context.Context, reminder model.Reminder) error {
+	url, client := reminder.Target, wn.client
+	if url == "" {
+		url, client = wn.defaultURL, wn.defaultClient
+	}
+	if url == "" {
+		return fmt.Errorf("webhook url is not configured")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"reminder_id": reminder.ID,
+		"task_id":     reminder.TaskId,
+		"title":       reminder.Task.Title,
+		"due_date":    reminder.Task.DueDate,
+		"fire_at":     reminder.FireAt,
+		"message":     message(reminder),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
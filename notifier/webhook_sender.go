@@ -0,0 +1,78 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/netguard"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxWebhookResponseBytesは配信のログに残す応答の本文の最大のバイト数
+const maxWebhookResponseBytes = 2048
+
+// IWebhookSenderはタスクのイベントを、登録されたWebhookのURLに署名を付けて送信する
+type IWebhookSender interface {
+	Send(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) model.WebhookDeliveryResult
+}
+
+type webhookSender struct {
+	client *http.Client
+}
+
+func NewWebhookSender() IWebhookSender {
+	// WebhookのURLはユーザーが登録するので、サーバーの内部のネットワークには接続しないクライアントで送信します。
+	// 内部のアドレスには接続自体をしないので、配信のログに内部のサーバーの応答が残ることもありません。
+	// 3xxの応答は失敗として記録するので、リダイレクトも追いかけません。
+	client := netguard.NewHTTPClient(10 * time.Second)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &webhookSender{client}
+}
+
+// SignWebhookは送信する本文の署名を作る
+// 署名は"<X-Webhook-Timestamp>.<本文>"をWebhookのSecretを鍵にしたHMAC-SHA256で計算した16進数で、
+// 受け取る側は同じ計算をして、X-Webhook-Signatureの"sha256="より後と一致するかを確認します。
+// タイムスタンプも署名に含めるので、古いリクエストの再送(リプレイ)を受け取る側で弾けます。
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (ws *webhookSender) Send(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) model.WebhookDeliveryResult {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return model.WebhookDeliveryResult{Err: err}
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-rest-api-webhook")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", SignWebhook(webhook.Secret, timestamp, body))
+	res, err := ws.client.Do(req)
+	if err != nil {
+		return model.WebhookDeliveryResult{Err: err}
+	}
+	defer res.Body.Close()
+	// 応答の本文は配信のログに残す先頭の部分だけを読み込みます(PostgreSQLのtextに保存できないNULは取り除きます)。
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxWebhookResponseBytes))
+	data = bytes.ReplaceAll(bytes.ToValidUTF8(data, []byte("�")), []byte{0}, nil)
+	result := model.WebhookDeliveryResult{StatusCode: res.StatusCode, Response: string(data)}
+	if res.StatusCode >= 300 {
+		result.Err = fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return result
+}
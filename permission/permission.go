@@ -0,0 +1,336 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+
+	"gorm.io/gorm"
+)
+
+// ErrForbiddenはタスク・プロジェクトは見えるが、操作に必要な権限が無い場合のエラー
+// 見えないタスク・プロジェクトの場合は、存在を知られないようにgorm.ErrRecordNotFoundを返します。
+var ErrForbidden = errors.New("permission denied")
+
+// ErrNotAssignableはタスクの担当者にできないユーザーを指定した場合のエラー
+var ErrNotAssignable = errors.New("assignee must be a member of the organization or the project")
+
+// IPermissionServiceはタスクとプロジェクトのアクセス権を判定するサービス
+// 作成者(owner)と、共有(Share)で付与されたeditor・viewerの権限を扱います。
+// タスクの担当者には、閲覧と完了状態の更新ができるassigneeの権限があります。
+// 組織のタスク・プロジェクトの場合は、組織のownerとadminにも作成者と同じ権限があります。
+// タスク・プロジェクトの取得はctxのテナント(組織)で絞り込まれるので、他の組織のものは見えません。
+// ctxがトランザクションの中の場合は、同じトランザクションでまだコミットしていない変更も含めて判定します。
+type IPermissionService interface {
+	// OwnedTasksは自分が作成したタスクに絞り込むスコープ
+	OwnedTasks(userId uint) func(db *gorm.DB) *gorm.DB
+	// VisibleTasksは自分が閲覧できる全てのタスクに絞り込むスコープ
+	VisibleTasks(userId uint) func(db *gorm.DB) *gorm.DB
+	// SharedTasksは他のユーザーから共有されたタスクに絞り込むスコープ
+	SharedTasks(userId uint) func(db *gorm.DB) *gorm.DB
+	// VisibleProjectsは自分が閲覧できるプロジェクトに絞り込むスコープ
+	VisibleProjects(userId uint) func(db *gorm.DB) *gorm.DB
+	// TaskRoleはタスクに対するユーザーの権限を返す
+	TaskRole(ctx context.Context, userId uint, taskId uint) (string, error)
+	CanViewTask(ctx context.Context, userId uint, taskId uint) error
+	CanEditTask(ctx context.Context, userId uint, taskId uint) error
+	// CanUpdateTaskStatusはタスクの完了状態を更新できるか(担当者とeditor以上)
+	CanUpdateTaskStatus(ctx context.Context, userId uint, taskId uint) error
+	// CanDeleteTaskはタスクの削除と共有の設定ができるか(作成者かプロジェクトの所有者のみ)
+	CanDeleteTask(ctx context.Context, userId uint, taskId uint) error
+	// ProjectRoleはプロジェクトに対するユーザーの権限を返す
+	ProjectRole(ctx context.Context, userId uint, projectId uint) (string, error)
+	CanViewProject(ctx context.Context, userId uint, projectId uint) error
+	CanEditProject(ctx context.Context, userId uint, projectId uint) error
+	// CanManageProjectはプロジェクトの変更・削除と共有の設定ができるか(所有者のみ)
+	CanManageProject(ctx context.Context, userId uint, projectId uint) error
+	// VisibleBoardsは自分が閲覧できるボードに絞り込むスコープ
+	VisibleBoards(userId uint) func(db *gorm.DB) *gorm.DB
+	// BoardRoleはボードに対するユーザーの権限を返す
+	// プロジェクトのボードはプロジェクトに対する権限、それ以外のボードは作成者(と組織のowner・admin)だけが扱えます。
+	BoardRole(ctx context.Context, userId uint, boardId uint) (string, error)
+	CanViewBoard(ctx context.Context, userId uint, boardId uint) error
+	// CanEditBoardはボードの列の編集と、タスクの列の移動ができるか(editor以上)
+	CanEditBoard(ctx context.Context, userId uint, boardId uint) error
+	// CanManageBoardはボードの変更・削除ができるか(所有者のみ)
+	CanManageBoard(ctx context.Context, userId uint, boardId uint) error
+	// MemberOfTenantはユーザーがctxの組織のメンバーか確認する(個人のタスクの場合は誰でも可)
+	MemberOfTenant(ctx context.Context, userId uint) error
+	// CanBeAssignedはユーザー(assigneeId)をタスクの担当者にできるか確認する
+	// タスクの作成者本人か、タスクが属する組織のメンバーか、タスクが属するプロジェクトを見られるユーザーだけが担当者になれます。
+	CanBeAssigned(ctx context.Context, assigneeId uint, task model.Task) error
+}
+
+type permissionService struct {
+	db *gorm.DB
+}
+
+func NewPermissionService(db *gorm.DB) IPermissionService {
+	return &permissionService{db}
+}
+
+// 権限の強さの順番
+var roleRank = map[string]int{
+	model.RoleViewer:   1,
+	model.RoleAssignee: 2,
+	model.RoleEditor:   3,
+	model.RoleOwner:    4,
+}
+
+// higherRoleは2つの権限のうち強い方を返す
+func higherRole(a string, b string) string {
+	if roleRank[b] > roleRank[a] {
+		return b
+	}
+	return a
+}
+
+// visibleProjectIdsは自分が所有しているか共有されているプロジェクトのIDの副問い合わせ
+const visibleProjectIds = `SELECT id FROM projects WHERE user_id = @user
+	UNION SELECT project_id FROM shares WHERE user_id = @user AND project_id IS NOT NULL`
+
+// managedOrganizationIdsは自分がownerかadminの組織のIDの副問い合わせ
+// これらの組織のタスク・プロジェクトは、作成者でなくても全て見えます。
+const managedOrganizationIds = `SELECT organization_id FROM memberships WHERE user_id = @user AND role IN ('owner', 'admin')`
+
+func (ps *permissionService) OwnedTasks(userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tasks.user_id = ?", userId)
+	}
+}
+
+func (ps *permissionService) VisibleTasks(userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`tasks.user_id = @user
+			OR tasks.assignee_id = @user
+			OR tasks.id IN (SELECT task_id FROM shares WHERE user_id = @user AND task_id IS NOT NULL)
+			OR tasks.project_id IN (`+visibleProjectIds+`)
+			OR tasks.organization_id IN (`+managedOrganizationIds+`)`, map[string]interface{}{"user": userId})
+	}
+}
+
+func (ps *permissionService) SharedTasks(userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(ps.VisibleTasks(userId)).Where("tasks.user_id <> ?", userId)
+	}
+}
+
+func (ps *permissionService) VisibleProjects(userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("projects.id IN ("+visibleProjectIds+") OR projects.organization_id IN ("+managedOrganizationIds+")",
+			map[string]interface{}{"user": userId})
+	}
+}
+
+func (ps *permissionService) VisibleBoards(userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("boards.user_id = @user OR boards.project_id IN ("+visibleProjectIds+") OR boards.organization_id IN ("+managedOrganizationIds+")",
+			map[string]interface{}{"user": userId})
+	}
+}
+
+func (ps *permissionService) TaskRole(ctx context.Context, userId uint, taskId uint) (string, error) {
+	task := model.Task{}
+	if err := repository.Conn(ctx, ps.db).Select("id", "user_id", "project_id", "organization_id", "assignee_id").First(&task, taskId).Error; err != nil {
+		return "", err
+	}
+	if task.UserId == userId {
+		return model.RoleOwner, nil
+	}
+	if manager, err := ps.managesOrganization(ctx, userId, task.OrganizationId); err != nil || manager {
+		return model.RoleOwner, err
+	}
+	role := ""
+	// 担当者は共有されていなくても、タスクの閲覧と完了状態の更新ができます。
+	if task.AssigneeId != nil && *task.AssigneeId == userId {
+		role = model.RoleAssignee
+	}
+	// プロジェクトに対する権限は、その中のタスクにも引き継がれます。
+	if task.ProjectId != nil {
+		projectRole, err := ps.ProjectRole(ctx, userId, *task.ProjectId)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+		role = higherRole(role, projectRole)
+	}
+	shares := []model.Share{}
+	if err := repository.Conn(ctx, ps.db).Where("task_id=? AND user_id=?", taskId, userId).Find(&shares).Error; err != nil {
+		return "", err
+	}
+	for _, s := range shares {
+		role = higherRole(role, s.Role)
+	}
+	if role == "" {
+		return "", gorm.ErrRecordNotFound
+	}
+	return role, nil
+}
+
+func (ps *permissionService) ProjectRole(ctx context.Context, userId uint, projectId uint) (string, error) {
+	project := model.Project{}
+	if err := repository.Conn(ctx, ps.db).Select("id", "user_id", "organization_id").First(&project, projectId).Error; err != nil {
+		return "", err
+	}
+	if project.UserId == userId {
+		return model.RoleOwner, nil
+	}
+	if manager, err := ps.managesOrganization(ctx, userId, project.OrganizationId); err != nil || manager {
+		return model.RoleOwner, err
+	}
+	shares := []model.Share{}
+	if err := repository.Conn(ctx, ps.db).Where("project_id=? AND user_id=?", projectId, userId).Find(&shares).Error; err != nil {
+		return "", err
+	}
+	role := ""
+	for _, s := range shares {
+		role = higherRole(role, s.Role)
+	}
+	if role == "" {
+		return "", gorm.ErrRecordNotFound
+	}
+	return role, nil
+}
+
+func (ps *permissionService) BoardRole(ctx context.Context, userId uint, boardId uint) (string, error) {
+	board := model.Board{}
+	if err := repository.Conn(ctx, ps.db).Select("id", "user_id", "project_id", "organization_id").First(&board, boardId).Error; err != nil {
+		return "", err
+	}
+	if board.UserId == userId {
+		return model.RoleOwner, nil
+	}
+	if manager, err := ps.managesOrganization(ctx, userId, board.OrganizationId); err != nil || manager {
+		return model.RoleOwner, err
+	}
+	if board.ProjectId == nil {
+		return "", gorm.ErrRecordNotFound
+	}
+	return ps.ProjectRole(ctx, userId, *board.ProjectId)
+}
+
+// requireは権限がrequired以上あるか確認する
+func require(role string, err error, required string) error {
+	if err != nil {
+		return err
+	}
+	if roleRank[role] < roleRank[required] {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (ps *permissionService) CanViewTask(ctx context.Context, userId uint, taskId uint) error {
+	role, err := ps.TaskRole(ctx, userId, taskId)
+	return require(role, err, model.RoleViewer)
+}
+
+func (ps *permissionService) CanEditTask(ctx context.Context, userId uint, taskId uint) error {
+	role, err := ps.TaskRole(ctx, userId, taskId)
+	return require(role, err, model.RoleEditor)
+}
+
+func (ps *permissionService) CanUpdateTaskStatus(ctx context.Context, userId uint, taskId uint) error {
+	role, err := ps.TaskRole(ctx, userId, taskId)
+	return require(role, err, model.RoleAssignee)
+}
+
+func (ps *permissionService) CanDeleteTask(ctx context.Context, userId uint, taskId uint) error {
+	role, err := ps.TaskRole(ctx, userId, taskId)
+	return require(role, err, model.RoleOwner)
+}
+
+func (ps *permissionService) CanViewProject(ctx context.Context, userId uint, projectId uint) error {
+	role, err := ps.ProjectRole(ctx, userId, projectId)
+	return require(role, err, model.RoleViewer)
+}
+
+func (ps *permissionService) CanEditProject(ctx context.Context, userId uint, projectId uint) error {
+	role, err := ps.ProjectRole(ctx, userId, projectId)
+	return require(role, err, model.RoleEditor)
+}
+
+func (ps *permissionService) CanManageProject(ctx context.Context, userId uint, projectId uint) error {
+	role, err := ps.ProjectRole(ctx, userId, projectId)
+	return require(role, err, model.RoleOwner)
+}
+
+func (ps *permissionService) CanViewBoard(ctx context.Context, userId uint, boardId uint) error {
+	role, err := ps.BoardRole(ctx, userId, boardId)
+	return require(role, err, model.RoleViewer)
+}
+
+func (ps *permissionService) CanEditBoard(ctx context.Context, userId uint, boardId uint) error {
+	role, err := ps.BoardRole(ctx, userId, boardId)
+	return require(role, err, model.RoleEditor)
+}
+
+func (ps *permissionService) CanManageBoard(ctx context.Context, userId uint, boardId uint) error {
+	role, err := ps.BoardRole(ctx, userId, boardId)
+	return require(role, err, model.RoleOwner)
+}
+
+// managesOrganizationはorgIdの組織でユーザーがownerかadminか確認する(個人のタスク・プロジェクトの場合はfalse)
+func (ps *permissionService) managesOrganization(ctx context.Context, userId uint, orgId *uint) (bool, error) {
+	if orgId == nil {
+		return false, nil
+	}
+	role, err := ps.organizationRole(ctx, userId, *orgId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return role == model.OrgRoleOwner || role == model.OrgRoleAdmin, nil
+}
+
+// organizationRoleは組織でのユーザーの権限を返す(メンバーではない場合はgorm.ErrRecordNotFound)
+func (ps *permissionService) organizationRole(ctx context.Context, userId uint, orgId uint) (string, error) {
+	membership := model.Membership{}
+	if err := repository.Conn(ctx, ps.db).Where("organization_id=? AND user_id=?", orgId, userId).First(&membership).Error; err != nil {
+		return "", err
+	}
+	return membership.Role, nil
+}
+
+func (ps *permissionService) MemberOfTenant(ctx context.Context, userId uint) error {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrNoTenant
+	}
+	if t.System || t.OrganizationId == 0 {
+		return nil
+	}
+	_, err := ps.organizationRole(ctx, userId, t.OrganizationId)
+	return err
+}
+
+func (ps *permissionService) CanBeAssigned(ctx context.Context, assigneeId uint, task model.Task) error {
+	if assigneeId == task.UserId {
+		return nil
+	}
+	// 組織のタスクは、組織のメンバーなら誰でも担当者にできます。
+	t, ok := tenant.FromContext(ctx)
+	if ok && t.OrganizationId != 0 {
+		_, err := ps.organizationRole(ctx, assigneeId, t.OrganizationId)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	// プロジェクトのタスクは、プロジェクトの所有者と共有されているユーザーを担当者にできます。
+	if task.ProjectId != nil {
+		_, err := ps.ProjectRole(ctx, assigneeId, *task.ProjectId)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	return ErrNotAssignable
+}
@@ -0,0 +1,123 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-rest-api/model"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// maxNotifyBytesはNOTIFYで送るイベントのJSONの最大のバイト数(PostgreSQLの上限の8000バイトより少し小さくします)
+const maxNotifyBytes = 7900
+
+// IPostgresHubはPostgreSQLのLISTEN/NOTIFYを経由して、全てのインスタンスにイベントを配るIHub
+type IPostgresHub interface {
+	IHub
+	// Startはctxがキャンセルされるまで、他のインスタンス(と自分)がNOTIFYしたイベントをLISTENで受け取って配る
+	// 接続が切れた場合はつなぎ直し、その間のイベントは取りこぼすので購読者のチャンネルを閉じます。
+	Start(ctx context.Context)
+}
+
+type postgresHub struct {
+	*hub
+	db      *gorm.DB
+	channel string
+}
+
+// NewPostgresHubはPostgreSQLのchannelにNOTIFYしてイベントを配るIPostgresHubを返す
+// 自分がPublishしたイベントもLISTENで受け取ってから配るので、Startを実行しておく必要があります。
+func NewPostgresHub(db *gorm.DB, channel string) IPostgresHub {
+	return &postgresHub{newHub(), db, channel}
+}
+
+func (ph *postgresHub) Publish(event model.TaskChangeEvent) {
+	payload, err := json.Marshal(event)
+	if err == nil && len(payload) > maxNotifyBytes {
+		// 説明の長いタスクなどで大きすぎる場合は、タスクの内容を省いてクライアントに取得し直してもらいます。
+		event.Task = nil
+		payload, err = json.Marshal(event)
+	}
+	if err != nil {
+		log.Println("pubsub:", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// NOTIFYはトランザクションの中では、コミットした時に送られます。
+	if err := ph.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", ph.channel, string(payload)).Error; err != nil {
+		log.Println("pubsub:", err)
+	}
+}
+
+func (ph *postgresHub) Start(ctx context.Context) {
+	backoff := time.Second
+	for {
+		listening := false
+		err := ph.listen(ctx, func() { listening = true })
+		if ctx.Err() != nil {
+			ph.closeAll()
+			return
+		}
+		log.Println("pubsub:", err)
+		ph.closeAll()
+		if listening {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// listenはコネクションプールから1つのコネクションを借りてLISTENし、エラーになるまでイベントを受け取る
+func (ph *postgresHub) listen(ctx context.Context, listening func()) error {
+	sqlDB, err := ph.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("LISTEN requires the pgx driver")
+		}
+		pc := c.Conn()
+		if _, err := pc.Exec(ctx, "LISTEN "+pgx.Identifier{ph.channel}.Sanitize()); err != nil {
+			return err
+		}
+		// プールに返したコネクションで、他のクエリがイベントを受け取り続けないようにします。
+		defer func() {
+			if !pc.IsClosed() {
+				pc.Exec(context.Background(), "UNLISTEN *")
+			}
+		}()
+		listening()
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			event := model.TaskChangeEvent{}
+			if err := json.Unmarshal([]byte(n.Payload), &event); err != nil {
+				log.Println("pubsub:", err)
+				continue
+			}
+			ph.hub.Publish(event)
+		}
+	})
+}
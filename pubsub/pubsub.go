@@ -0,0 +1,74 @@
+// pubsubはタスクの変更のイベントを、/eventsに接続しているクライアントに届けるためのパッケージ
+// taskUsecaseがPublishしたイベントを、Subscribeしている全ての接続に配ります。
+// NewHubは1つのプロセスの中だけで配り、NewPostgresHubはPostgreSQLのLISTEN/NOTIFYを経由して全てのインスタンスに配ります。
+package pubsub
+
+import (
+	"go-rest-api/model"
+	"sync"
+)
+
+// subscriberBufferは1つの購読者が受け取らずに溜められるイベントの数
+const subscriberBuffer = 64
+
+type IHub interface {
+	// Publishはイベントを配る(購読者が受け取るのを待たずに返ります)
+	Publish(event model.TaskChangeEvent)
+	// Subscribeはイベントを受け取るチャンネルと、購読をやめる関数を返す
+	// 受け取りが遅れてイベントを溜めきれなくなった購読者と、イベントを取りこぼした可能性がある場合の購読者のチャンネルは閉じます。
+	// チャンネルが閉じたらクライアントは接続し直して、タスクを取得し直します。
+	Subscribe() (<-chan model.TaskChangeEvent, func())
+}
+
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[chan model.TaskChangeEvent]struct{}
+}
+
+// NewHubは1つのプロセスの中だけでイベントを配るIHubを返す
+func NewHub() IHub {
+	return newHub()
+}
+
+func newHub() *hub {
+	return &hub{subscribers: map[chan model.TaskChangeEvent]struct{}{}}
+}
+
+func (h *hub) Publish(event model.TaskChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// 1つの遅い接続のために他の接続への配信を止めないように、溜めきれない購読者は外します。
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *hub) Subscribe() (<-chan model.TaskChangeEvent, func()) {
+	ch := make(chan model.TaskChangeEvent, subscriberBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// closeAllは全ての購読者のチャンネルを閉じる(イベントを取りこぼした可能性がある場合に使います)
+func (h *hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
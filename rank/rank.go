@@ -0,0 +1,108 @@
+package rank
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// digitsは順位を表す文字列に使う文字(0-9a-z)
+// ASCIIの順番とデータベースの照合順序が一致するように、大文字は使いません。
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// Betweenはbeforeとafterの間に並ぶ順位の文字列を返す
+// beforeが空文字の場合は先頭、afterが空文字の場合は末尾を表します。
+// 順位は小数点以下の桁だけを持つ36進数とみなして中間の値を求めるので、
+// 並び替えの時に他の行の順位を書き換える必要がありません。
+func Between(before, after string) (string, error) {
+	if err := check(before); err != nil {
+		return "", err
+	}
+	if err := check(after); err != nil {
+		return "", err
+	}
+	if after != "" && before >= after {
+		return "", fmt.Errorf("rank %q is not before %q", before, after)
+	}
+	return midpoint(before, after), nil
+}
+
+func check(key string) error {
+	for _, r := range key {
+		if !strings.ContainsRune(digits, r) {
+			return fmt.Errorf("invalid rank %q", key)
+		}
+	}
+	// 末尾が0の順位はその直前に挿入できなくなるので使いません。
+	if strings.HasSuffix(key, "0") {
+		return fmt.Errorf("invalid rank %q", key)
+	}
+	return nil
+}
+
+// midpointはaとbの中間の文字列を返す(bが空文字の場合は上限なし)
+func midpoint(a, b string) string {
+	if b != "" {
+		// 共通の接頭辞はそのまま使い、残りの部分の中間を求める
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(digits, a[0])
+	}
+	digitB := len(digits)
+	if b != "" {
+		digitB = strings.IndexByte(digits, b[0])
+	}
+	if digitB-digitA > 1 {
+		return string(digits[(digitA+digitB+1)/2])
+	}
+	// 先頭の桁が隣り合っている場合は、桁を増やして間に入れる
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[digitA]) + midpoint(rest, "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return digits[0]
+}
+
+// Spreadはn件分の順位を等間隔で作成して返す(順位の文字列が長くなりすぎた時の振り直しに使う)
+func Spread(n int) []string {
+	base := big.NewInt(int64(len(digits)))
+	// n+1個の区間に分けられるだけの桁数を求める
+	width := 1
+	space := new(big.Int).Set(base)
+	limit := big.NewInt(int64(n + 1))
+	for space.Cmp(limit) <= 0 {
+		space.Mul(space, base)
+		width++
+	}
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		v := new(big.Int).Mul(space, big.NewInt(int64(i+1)))
+		v.Div(v, limit)
+		s := v.Text(len(digits))
+		s = strings.Repeat("0", width-len(s)) + s
+		keys[i] = strings.TrimRight(s, "0")
+	}
+	return keys
+}
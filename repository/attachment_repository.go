@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IAttachmentRepository interface {
+	// GetAttachmentsByTaskでタスクの添付ファイルの一覧を取得
+	GetAttachmentsByTask(ctx context.Context, attachments *[]model.Attachment, taskId uint) error
+	// GetAttachmentByIdで引数で渡すattachmentIdに一致する添付ファイルを取得
+	GetAttachmentById(ctx context.Context, attachment *model.Attachment, taskId uint, attachmentId uint) error
+	// CreateAttachmentで添付ファイルのメタデータを新規作成
+	CreateAttachment(ctx context.Context, attachment *model.Attachment) error
+	// DeleteAttachmentで添付ファイルのメタデータを削除して、中身とサムネイルのキーをBlobDeletionに登録
+	DeleteAttachment(ctx context.Context, taskId uint, attachmentId uint) error
+	// GetBlobDeletionsでBlobStoreから削除する中身のキーを古い順に最大limit件取得
+	GetBlobDeletions(ctx context.Context, deletions *[]model.BlobDeletion, limit int) error
+	// DeleteBlobDeletionでBlobStoreから削除できた中身のキーを削除
+	DeleteBlobDeletion(ctx context.Context, deletionId uint) error
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) IAttachmentRepository {
+	return &attachmentRepository{db}
+}
+
+func (ar *attachmentRepository) GetAttachmentsByTask(ctx context.Context, attachments *[]model.Attachment, taskId uint) error {
+	if err := conn(ctx, ar.db).Where("task_id=?", taskId).Order("created_at").Find(attachments).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *attachmentRepository) GetAttachmentById(ctx context.Context, attachment *model.Attachment, taskId uint, attachmentId uint) error {
+	if err := conn(ctx, ar.db).Where("task_id=?", taskId).First(attachment, attachmentId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *attachmentRepository) CreateAttachment(ctx context.Context, attachment *model.Attachment) error {
+	if err := conn(ctx, ar.db).Create(attachment).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *attachmentRepository) DeleteAttachment(ctx context.Context, taskId uint, attachmentId uint) error {
+	return conn(ctx, ar.db).Transaction(func(tx *gorm.DB) error {
+		deleted := model.Attachment{}
+		result := tx.Clauses(clause.Returning{}).Where("id=? AND task_id=?", attachmentId, taskId).Delete(&deleted)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return ErrObjectNotExist
+		}
+		return enqueueBlobDeletions(tx, []model.Attachment{deleted})
+	})
+}
+
+// enqueueBlobDeletionsは削除した添付ファイルの中身とサムネイルのキーを、BlobSweeperが削除するように登録する
+func enqueueBlobDeletions(tx *gorm.DB, attachments []model.Attachment) error {
+	deletions := []model.BlobDeletion{}
+	for _, a := range attachments {
+		for _, key := range []string{a.StorageKey, a.ThumbnailKey} {
+			if key != "" {
+				deletions = append(deletions, model.BlobDeletion{StorageKey: key})
+			}
+		}
+	}
+	if len(deletions) == 0 {
+		return nil
+	}
+	return tx.Create(&deletions).Error
+}
+
+func (ar *attachmentRepository) GetBlobDeletions(ctx context.Context, deletions *[]model.BlobDeletion, limit int) error {
+	if err := conn(ctx, ar.db).Order("id").Limit(limit).Find(deletions).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *attachmentRepository) DeleteBlobDeletion(ctx context.Context, deletionId uint) error {
+	return conn(ctx, ar.db).Delete(&model.BlobDeletion{}, deletionId).Error
+}
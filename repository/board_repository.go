@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/rank"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// boardsテーブルへのクエリはtenantパッケージがctxのテナントで絞り込みます。
+// board_columnsはボードを通してアクセスするので、列の操作では必ずboardIdも条件に含めます。
+type IBoardRepository interface {
+	// GetBoardsでアクセス権のスコープ(visible)に当てはまるボードの一覧を取得
+	GetBoards(ctx context.Context, boards *[]model.Board, visible func(db *gorm.DB) *gorm.DB) error
+	GetBoardById(ctx context.Context, board *model.Board, boardId uint) error
+	CreateBoard(ctx context.Context, board *model.Board) error
+	UpdateBoard(ctx context.Context, board *model.Board, boardId uint) error
+	DeleteBoard(ctx context.Context, boardId uint) error
+	// GetColumnsでボードの列を順番に取得
+	GetColumns(ctx context.Context, columns *[]model.BoardColumn, boardId uint) error
+	// CreateColumnでボードの末尾に列を追加
+	CreateColumn(ctx context.Context, column *model.BoardColumn) error
+	// UpdateColumnで列の名前とWIPの上限を更新
+	UpdateColumn(ctx context.Context, column *model.BoardColumn, boardId uint, columnId uint) error
+	DeleteColumn(ctx context.Context, boardId uint, columnId uint) error
+	// MoveColumnで列をbeforeIdの列の直前かafterIdの列の直後に移動
+	MoveColumn(ctx context.Context, column *model.BoardColumn, boardId uint, columnId uint, beforeId *uint, afterId *uint) error
+	// GetColumnTasksでcolumnIdsの列に置かれているタスクのうち、visibleに当てはまるタスクを列の中の順番で取得
+	GetColumnTasks(ctx context.Context, tasks *[]model.Task, columnIds []uint, visible func(db *gorm.DB) *gorm.DB) error
+	// CountColumnTasksでcolumnIdsの列ごとに置かれているタスクの数を数える
+	CountColumnTasks(ctx context.Context, columnIds []uint) (map[uint]int64, error)
+	// MoveTaskToColumnでタスクをcolumnIdの列のbeforeIdの直前かafterIdの直後に移動(どちらも無い場合は末尾)
+	// 列をロックしてからadmitに列と移動前の列のタスクの数を渡すので、admitでWIPの上限を確認します(同じ列の中の並び替えでは呼びません)。
+	// 列のロックから移動までを1つのトランザクションで実行するので、同時に移動してもWIPの上限を超えることはありません。
+	MoveTaskToColumn(ctx context.Context, task *model.Task, boardId uint, columnId uint, beforeId *uint, afterId *uint,
+		admit func(column model.BoardColumn, count int64) error) error
+	// RemoveTaskFromBoardでタスクをボードの列から外す
+	RemoveTaskFromBoard(ctx context.Context, boardId uint, taskId uint) error
+}
+
+type boardRepository struct {
+	db *gorm.DB
+}
+
+func NewBoardRepository(db *gorm.DB) IBoardRepository {
+	return &boardRepository{db}
+}
+
+// columnPositionOrderとtaskColumnPositionOrderは、順位の文字列をバイト順で比較するためのORDER BY句
+const (
+	columnPositionOrder     = `board_columns.position COLLATE "C"`
+	taskColumnPositionOrder = `tasks.column_position COLLATE "C"`
+)
+
+// positionBetweenはlistの中でbeforeIdの直前かafterIdの直後(どちらも無い場合は末尾)になる順位を求める
+// listは並び替える一覧に絞り込んだクエリを毎回新しく返す関数で、excludeIdは移動する行自身のIDです。
+// 片方だけ指定された場合は、もう片方はその行の隣に並んでいる行の順位を使います。
+func positionBetween(list func() *gorm.DB, column string, excludeId uint, beforeId *uint, afterId *uint) (string, error) {
+	order := column + ` COLLATE "C"`
+	pluck := func(query *gorm.DB) (string, bool, error) {
+		positions := []string{}
+		if err := query.Limit(1).Pluck(column, &positions).Error; err != nil {
+			return "", false, err
+		}
+		if len(positions) == 0 {
+			return "", false, nil
+		}
+		return positions[0], true, nil
+	}
+	anchor := func(id uint) (string, error) {
+		position, found, err := pluck(list().Where("id=?", id))
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", fmt.Errorf("anchor %d is not in the same list", id)
+		}
+		return position, nil
+	}
+	lower, upper := "", ""
+	var err error
+	if afterId != nil {
+		if lower, err = anchor(*afterId); err != nil {
+			return "", err
+		}
+		if beforeId == nil {
+			if upper, _, err = pluck(list().Where("id<>? AND "+order+" > ?", excludeId, lower).Order(order)); err != nil {
+				return "", err
+			}
+		}
+	}
+	if beforeId != nil {
+		if upper, err = anchor(*beforeId); err != nil {
+			return "", err
+		}
+		if afterId == nil {
+			if lower, _, err = pluck(list().Where("id<>? AND "+order+" < ?", excludeId, upper).Order(order + " DESC")); err != nil {
+				return "", err
+			}
+		}
+	}
+	if afterId == nil && beforeId == nil {
+		if lower, _, err = pluck(list().Where("id<>?", excludeId).Order(order + " DESC")); err != nil {
+			return "", err
+		}
+	}
+	return rank.Between(lower, upper)
+}
+
+func (br *boardRepository) GetBoards(ctx context.Context, boards *[]model.Board, visible func(db *gorm.DB) *gorm.DB) error {
+	if err := br.db.WithContext(ctx).Scopes(visible).Order("boards.created_at").Find(boards).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (br *boardRepository) GetBoardById(ctx context.Context, board *model.Board, boardId uint) error {
+	if err := br.db.WithContext(ctx).First(board, boardId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (br *boardRepository) CreateBoard(ctx context.Context, board *model.Board) error {
+	if err := br.db.WithContext(ctx).Omit("Project").Create(board).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (br *boardRepository) UpdateBoard(ctx context.Context, board *model.Board, boardId uint) error {
+	result := br.db.WithContext(ctx).Model(board).Clauses(clause.Returning{}).Where("id=?", boardId).Update("name", board.Name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (br *boardRepository) DeleteBoard(ctx context.Context, boardId uint) error {
+	result := br.db.WithContext(ctx).Where("id=?", boardId).Delete(&model.Board{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (br *boardRepository) GetColumns(ctx context.Context, columns *[]model.BoardColumn, boardId uint) error {
+	if err := br.db.WithContext(ctx).Where("board_id=?", boardId).Order(columnPositionOrder).Order("id").Find(columns).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// lockBoardは列の追加と並び替えが同時に実行されても順位が重複しないように、ボードの行をロックする
+func lockBoard(tx *gorm.DB, boardId uint) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&model.Board{}, boardId).Error
+}
+
+func (br *boardRepository) CreateColumn(ctx context.Context, column *model.BoardColumn) error {
+	return br.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockBoard(tx, column.BoardId); err != nil {
+			return err
+		}
+		list := func() *gorm.DB { return tx.Model(&model.BoardColumn{}).Where("board_id=?", column.BoardId) }
+		position, err := positionBetween(list, "board_columns.position", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		column.Position = position
+		return tx.Omit("Board").Create(column).Error
+	})
+}
+
+func (br *boardRepository) UpdateColumn(ctx context.Context, column *model.BoardColumn, boardId uint, columnId uint) error {
+	// WIPの上限を外す(null)場合も更新されるように、mapでUpdatesに渡します。
+	result := br.db.WithContext(ctx).Model(column).Clauses(clause.Returning{}).Where("id=? AND board_id=?", columnId, boardId).
+		Updates(map[string]interface{}{
+			"name":      column.Name,
+			"wip_limit": column.WipLimit,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (br *boardRepository) DeleteColumn(ctx context.Context, boardId uint, columnId uint) error {
+	// 列に置かれていたタスクは、外部キー制約(ON DELETE SET NULL)でどの列にも置かれていない状態になります。
+	result := br.db.WithContext(ctx).Where("id=? AND board_id=?", columnId, boardId).Delete(&model.BoardColumn{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (br *boardRepository) MoveColumn(ctx context.Context, column *model.BoardColumn, boardId uint, columnId uint, beforeId *uint, afterId *uint) error {
+	return br.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockBoard(tx, boardId); err != nil {
+			return err
+		}
+		list := func() *gorm.DB { return tx.Model(&model.BoardColumn{}).Where("board_id=?", boardId) }
+		position, err := positionBetween(list, "board_columns.position", columnId, beforeId, afterId)
+		if err != nil {
+			return err
+		}
+		result := tx.Model(column).Clauses(clause.Returning{}).Where("id=? AND board_id=?", columnId, boardId).Update("position", position)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return ErrObjectNotExist
+		}
+		return nil
+	})
+}
+
+func (br *boardRepository) GetColumnTasks(ctx context.Context, tasks *[]model.Task, columnIds []uint, visible func(db *gorm.DB) *gorm.DB) error {
+	// 全ての列のタスクを1回のクエリでまとめて取得します。
+	if err := br.db.WithContext(ctx).Preload("Series").Scopes(visible).Where("tasks.column_id IN ?", columnIds).
+		Order(taskColumnPositionOrder).Order("tasks.created_at").Find(tasks).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (br *boardRepository) CountColumnTasks(ctx context.Context, columnIds []uint) (map[uint]int64, error) {
+	rows := []struct {
+		ColumnId uint
+		Count    int64
+	}{}
+	if err := br.db.WithContext(ctx).Model(&model.Task{}).Select("column_id, count(*) AS count").
+		Where("column_id IN ?", columnIds).Group("column_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := map[uint]int64{}
+	for _, r := range rows {
+		counts[r.ColumnId] = r.Count
+	}
+	return counts, nil
+}
+
+func (br *boardRepository) MoveTaskToColumn(ctx context.Context, task *model.Task, boardId uint, columnId uint, beforeId *uint, afterId *uint,
+	admit func(column model.BoardColumn, count int64) error) error {
+	return br.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SELECT ... FOR UPDATEで移動先の列をロックして、同じ列への移動を1つずつ処理します。
+		column := model.BoardColumn{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=? AND board_id=?", columnId, boardId).First(&column).Error; err != nil {
+			return err
+		}
+		if task.ColumnId == nil || *task.ColumnId != columnId {
+			var count int64
+			if err := tx.Model(&model.Task{}).Where("column_id=? AND id<>?", columnId, task.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if err := admit(column, count); err != nil {
+				return err
+			}
+		}
+		list := func() *gorm.DB { return tx.Model(&model.Task{}).Where("column_id=?", columnId) }
+		position, err := positionBetween(list, "tasks.column_position", task.ID, beforeId, afterId)
+		if err != nil {
+			return err
+		}
+		result := tx.Model(task).Clauses(clause.Returning{}).Where("id=?", task.ID).
+			Updates(map[string]interface{}{"column_id": columnId, "column_position": position})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return ErrObjectNotExist
+		}
+		return nil
+	})
+}
+
+func (br *boardRepository) RemoveTaskFromBoard(ctx context.Context, boardId uint, taskId uint) error {
+	result := br.db.WithContext(ctx).Model(&model.Task{}).
+		Where("id=? AND column_id IN (?)", taskId, br.db.Model(&model.BoardColumn{}).Select("id").Where("board_id=?", boardId)).
+		Updates(map[string]interface{}{"column_id": nil, "column_position": ""})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
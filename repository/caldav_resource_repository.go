@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ICalDAVResourceRepository interface {
+	// GetResourceByNameでリソースの名前からCalDAVのリソースを取得
+	GetResourceByName(ctx context.Context, resource *model.CalDAVResource, name string) error
+	// GetResourcesByTaskIdsでタスクのIDのCalDAVのリソースを取得(クライアントが作成したタスクの分だけ返します)
+	GetResourcesByTaskIds(ctx context.Context, resources *[]model.CalDAVResource, taskIds []uint) error
+	// SaveResourceでリソースを保存する(同じ名前のリソースがある場合は、タスクとUIDを置き換えます)
+	SaveResource(ctx context.Context, resource *model.CalDAVResource) error
+}
+
+type calDAVResourceRepository struct {
+	db *gorm.DB
+}
+
+func NewCalDAVResourceRepository(db *gorm.DB) ICalDAVResourceRepository {
+	return &calDAVResourceRepository{db}
+}
+
+func (crr *calDAVResourceRepository) GetResourceByName(ctx context.Context, resource *model.CalDAVResource, name string) error {
+	if err := crr.db.WithContext(ctx).Where("name=?", name).First(resource).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (crr *calDAVResourceRepository) GetResourcesByTaskIds(ctx context.Context, resources *[]model.CalDAVResource, taskIds []uint) error {
+	if len(taskIds) == 0 {
+		return nil
+	}
+	if err := crr.db.WithContext(ctx).Where("task_id IN ?", taskIds).Find(resources).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (crr *calDAVResourceRepository) SaveResource(ctx context.Context, resource *model.CalDAVResource) error {
+	// 削除したタスクの名前でクライアントがもう一度作成した場合は、新しいタスクに付け替えます。
+	if err := crr.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"uid", "task_id", "updated_at"}),
+	}).Create(resource).Error; err != nil {
+		return err
+	}
+	return nil
+}
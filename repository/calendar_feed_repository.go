@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ICalendarFeedRepository interface {
+	GetFeedByUserId(ctx context.Context, feed *model.CalendarFeed, userId uint) error
+	// GetFeedByHashでトークンのハッシュからフィードを取得して、最後にアクセスされた日時を更新
+	GetFeedByHash(ctx context.Context, feed *model.CalendarFeed, hash string) error
+	// SaveFeedはユーザーのフィードを作成する(既にある場合はトークンを置き換える)
+	SaveFeed(ctx context.Context, feed *model.CalendarFeed) error
+	DeleteFeed(ctx context.Context, userId uint) error
+}
+
+type calendarFeedRepository struct {
+	db *gorm.DB
+}
+
+func NewCalendarFeedRepository(db *gorm.DB) ICalendarFeedRepository {
+	return &calendarFeedRepository{db}
+}
+
+func (cfr *calendarFeedRepository) GetFeedByUserId(ctx context.Context, feed *model.CalendarFeed, userId uint) error {
+	if err := cfr.db.WithContext(ctx).Where("user_id=?", userId).First(feed).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cfr *calendarFeedRepository) GetFeedByHash(ctx context.Context, feed *model.CalendarFeed, hash string) error {
+	if err := cfr.db.WithContext(ctx).Where("token_hash=?", hash).First(feed).Error; err != nil {
+		return err
+	}
+	// カレンダーのアプリは定期的にフィードを読み込むので、更新は1分に1回までにします。
+	now := time.Now()
+	if feed.LastAccessedAt == nil || now.Sub(*feed.LastAccessedAt) > time.Minute {
+		feed.LastAccessedAt = &now
+		if err := cfr.db.WithContext(ctx).Model(feed).UpdateColumn("last_accessed_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfr *calendarFeedRepository) SaveFeed(ctx context.Context, feed *model.CalendarFeed) error {
+	if err := cfr.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "last_accessed_at", "updated_at"}),
+	}, clause.Returning{}).Create(feed).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cfr *calendarFeedRepository) DeleteFeed(ctx context.Context, userId uint) error {
+	result := cfr.db.WithContext(ctx).Where("user_id=?", userId).Delete(&model.CalendarFeed{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ICommentRepository interface {
+	// GetCommentsByTaskでタスクに付いているコメントの一覧を古い順に取得
+	GetCommentsByTask(ctx context.Context, comments *[]model.Comment, taskId uint) error
+	// GetCommentsByTasksで複数のタスクのコメントをまとめて古い順に取得(visibleでユーザーが見られるタスクのコメントに絞り込む)
+	GetCommentsByTasks(ctx context.Context, comments *[]model.Comment, taskIds []uint, visible func(db *gorm.DB) *gorm.DB) error
+	// GetCommentByIdで引数で渡すcommentIdに一致するコメントを取得
+	GetCommentById(ctx context.Context, comment *model.Comment, taskId uint, commentId uint) error
+	// CreateCommentでコメントとメンション(comment.Mentions)を新規作成
+	CreateComment(ctx context.Context, comment *model.Comment) error
+	// UpdateCommentで本文を更新し、更新前の本文を履歴として保存、新しいメンションを追加
+	UpdateComment(ctx context.Context, comment *model.Comment, userId uint, previousBody string, newMentions []model.Mention) error
+	// DeleteCommentでコメントを削除(作成者のコメントだけ削除できる)
+	DeleteComment(ctx context.Context, userId uint, taskId uint, commentId uint) error
+	// GetRevisionsでコメントの編集履歴を新しい順に取得
+	GetRevisions(ctx context.Context, revisions *[]model.CommentRevision, commentId uint) error
+}
+
+type commentRepository struct {
+	db *gorm.DB
+}
+
+func NewCommentRepository(db *gorm.DB) ICommentRepository {
+	return &commentRepository{db}
+}
+
+func (cr *commentRepository) GetCommentsByTask(ctx context.Context, comments *[]model.Comment, taskId uint) error {
+	if err := conn(ctx, cr.db).Joins("User").Preload("Mentions.User").Where("task_id=?", taskId).Order("created_at").Find(comments).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *commentRepository) GetCommentsByTasks(ctx context.Context, comments *[]model.Comment, taskIds []uint, visible func(db *gorm.DB) *gorm.DB) error {
+	// commentsはテナントで絞り込まれますが、結合するtasksは絞り込まれないので、結合の条件でコメントと同じ組織のタスクに限定します。
+	if err := conn(ctx, cr.db).Joins("User").Joins("JOIN tasks ON tasks.id = comments.task_id AND tasks.organization_id IS NOT DISTINCT FROM comments.organization_id").Scopes(visible).
+		Preload("Mentions.User").Where("comments.task_id IN ?", taskIds).Order("comments.created_at").Find(comments).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *commentRepository) GetCommentById(ctx context.Context, comment *model.Comment, taskId uint, commentId uint) error {
+	if err := conn(ctx, cr.db).Joins("User").Preload("Mentions.User").Where("task_id=?", taskId).First(comment, commentId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *commentRepository) CreateComment(ctx context.Context, comment *model.Comment) error {
+	// comment.Mentionsに入っているメンションも一緒に作成されます。
+	if err := conn(ctx, cr.db).Create(comment).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *commentRepository) UpdateComment(ctx context.Context, comment *model.Comment, userId uint, previousBody string, newMentions []model.Mention) error {
+	// 本文の更新と履歴・メンションの保存は1つのトランザクションで行います。
+	return conn(ctx, cr.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.Comment{}).Where("id=? AND user_id=?", comment.ID, userId).
+			Updates(map[string]interface{}{"body": comment.Body, "edited_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return ErrObjectNotExist
+		}
+		comment.EditedAt = &now
+		revision := model.CommentRevision{Body: previousBody, CommentId: comment.ID}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		if len(newMentions) > 0 {
+			if err := tx.Create(&newMentions).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (cr *commentRepository) DeleteComment(ctx context.Context, userId uint, taskId uint, commentId uint) error {
+	result := conn(ctx, cr.db).Where("id=? AND task_id=? AND user_id=?", commentId, taskId, userId).Delete(&model.Comment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (cr *commentRepository) GetRevisions(ctx context.Context, revisions *[]model.CommentRevision, commentId uint) error {
+	if err := conn(ctx, cr.db).Where("comment_id=?", commentId).Order("created_at DESC").Find(revisions).Error; err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// custom_fieldsはプロジェクトを通してアクセスするので、項目の操作では必ずprojectIdも条件に含めます。
+// 項目の削除・選択肢の変更でタスクの値を消す時は、tasksテーブルへのクエリがctxのテナントで絞り込まれます。
+type ICustomFieldRepository interface {
+	// GetFieldsByProjectでプロジェクトの独自の項目の定義を作成順に取得
+	GetFieldsByProject(ctx context.Context, fields *[]model.CustomField, projectId uint) error
+	// GetFieldByIdで引数で渡すfieldIdの項目の定義を取得(一覧の絞り込み・並び替えに使います)
+	GetFieldById(ctx context.Context, field *model.CustomField, fieldId uint) error
+	// GetFieldsByKeysで見えるプロジェクト(visibleProjects)の中から、名前(大文字・小文字を区別しない)かIDが一致する項目の定義を取得
+	// 検索クエリのキーを独自の項目に解決するために使います。
+	GetFieldsByKeys(ctx context.Context, fields *[]model.CustomField, names []string, ids []uint, visibleProjects func(db *gorm.DB) *gorm.DB) error
+	CreateField(ctx context.Context, field *model.CustomField) error
+	// UpdateFieldで項目の名前・選択肢・必須かどうかを更新
+	// 選択肢から外した値は、プロジェクトのタスクの値からも取り除きます。
+	UpdateField(ctx context.Context, field *model.CustomField, projectId uint, fieldId uint) error
+	// DeleteFieldで項目を削除して、プロジェクトのタスクからその項目の値も取り除く
+	DeleteField(ctx context.Context, projectId uint, fieldId uint) error
+}
+
+type customFieldRepository struct {
+	db *gorm.DB
+}
+
+func NewCustomFieldRepository(db *gorm.DB) ICustomFieldRepository {
+	return &customFieldRepository{db}
+}
+
+func (cfr *customFieldRepository) GetFieldsByProject(ctx context.Context, fields *[]model.CustomField, projectId uint) error {
+	if err := cfr.db.WithContext(ctx).Where("project_id=?", projectId).Order("created_at").Order("id").Find(fields).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cfr *customFieldRepository) GetFieldById(ctx context.Context, field *model.CustomField, fieldId uint) error {
+	if err := cfr.db.WithContext(ctx).First(field, fieldId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cfr *customFieldRepository) GetFieldsByKeys(ctx context.Context, fields *[]model.CustomField, names []string, ids []uint, visibleProjects func(db *gorm.DB) *gorm.DB) error {
+	if len(names) == 0 && len(ids) == 0 {
+		return nil
+	}
+	projects := cfr.db.WithContext(ctx).Model(&model.Project{}).Select("projects.id").Scopes(visibleProjects)
+	if err := cfr.db.WithContext(ctx).Where("custom_fields.project_id IN (?)", projects).
+		Where("LOWER(custom_fields.name) IN ? OR custom_fields.id IN ?", names, ids).
+		Order("custom_fields.id").Find(fields).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cfr *customFieldRepository) CreateField(ctx context.Context, field *model.CustomField) error {
+	if err := cfr.db.WithContext(ctx).Omit("Project").Create(field).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cfr *customFieldRepository) UpdateField(ctx context.Context, field *model.CustomField, projectId uint, fieldId uint) error {
+	return cfr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Optionsをjsonbに変換するために、mapではなく構造体で更新するカラムを指定します。
+		result := tx.Model(field).Clauses(clause.Returning{}).Where("id=? AND project_id=?", fieldId, projectId).
+			Select("name", "options", "required").Updates(field)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return ErrObjectNotExist
+		}
+		key := strconv.FormatUint(uint64(fieldId), 10)
+		tasks := tx.Model(&model.Task{}).Where("tasks.project_id=? AND tasks.custom_fields -> ?::text IS NOT NULL", projectId, key)
+		switch field.Type {
+		case model.CustomFieldSelect:
+			// 選択肢に無くなった値は、値が無い状態にします。
+			return tasks.Where("NOT (tasks.custom_fields ->> ?::text IN ?)", key, field.Options).
+				UpdateColumn("custom_fields", gorm.Expr("tasks.custom_fields - ?::text", key)).Error
+		case model.CustomFieldMultiSelect:
+			// 選択肢に無くなった値だけを、配列から取り除きます。
+			return tasks.UpdateColumn("custom_fields", gorm.Expr(`jsonb_set(tasks.custom_fields, ARRAY[?::text],
+				COALESCE((SELECT jsonb_agg(v) FROM jsonb_array_elements_text(tasks.custom_fields -> ?::text) AS v WHERE v IN ?), '[]'::jsonb))`,
+				key, key, field.Options)).Error
+		}
+		return nil
+	})
+}
+
+func (cfr *customFieldRepository) DeleteField(ctx context.Context, projectId uint, fieldId uint) error {
+	return cfr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id=? AND project_id=?", fieldId, projectId).Delete(&model.CustomField{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return ErrObjectNotExist
+		}
+		key := strconv.FormatUint(uint64(fieldId), 10)
+		return tx.Model(&model.Task{}).Where("tasks.project_id=? AND tasks.custom_fields -> ?::text IS NOT NULL", projectId, key).
+			UpdateColumn("custom_fields", gorm.Expr("tasks.custom_fields - ?::text", key)).Error
+	})
+}
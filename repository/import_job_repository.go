@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// import_jobsテーブルへのクエリはtenantパッケージがctxのテナントで絞り込みます。
+// ジョブはアップロードしたユーザーだけのものなので、APIからの操作ではuserIdも条件に含めます。
+type IImportJobRepository interface {
+	GetJobs(ctx context.Context, jobs *[]model.ImportJob, userId uint) error
+	GetJobById(ctx context.Context, job *model.ImportJob, userId uint, jobId uint) error
+	CreateJob(ctx context.Context, job *model.ImportJob) error
+	// UpdateJobProgressでジョブの状態と進捗(件数・エラー・作成したプロジェクト)を更新
+	UpdateJobProgress(ctx context.Context, job *model.ImportJob) error
+	// ClaimImportJobで待機中のジョブを1件確保して実行中にする(確保できるジョブが無い場合はfalse)
+	ClaimImportJob(ctx context.Context, now time.Time) (model.ImportJob, bool, error)
+	// FailStaleJobsで、staleBeforeより後に進捗が更新されていない実行中のジョブを失敗にする
+	// 実行中にサーバーが停止したジョブで、途中まで作成したタスクがあるので最初からやり直すことはしません。
+	// 失敗にしたジョブを返すので、アップロードしたファイルの削除に使います。
+	FailStaleJobs(ctx context.Context, staleBefore time.Time, now time.Time) ([]model.ImportJob, error)
+}
+
+type importJobRepository struct {
+	db *gorm.DB
+}
+
+func NewImportJobRepository(db *gorm.DB) IImportJobRepository {
+	return &importJobRepository{db}
+}
+
+func (ijr *importJobRepository) GetJobs(ctx context.Context, jobs *[]model.ImportJob, userId uint) error {
+	if err := ijr.db.WithContext(ctx).Where("user_id=?", userId).Order("created_at DESC").Find(jobs).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ijr *importJobRepository) GetJobById(ctx context.Context, job *model.ImportJob, userId uint, jobId uint) error {
+	if err := ijr.db.WithContext(ctx).Where("user_id=?", userId).First(job, jobId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ijr *importJobRepository) CreateJob(ctx context.Context, job *model.ImportJob) error {
+	if err := ijr.db.WithContext(ctx).Create(job).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ijr *importJobRepository) UpdateJobProgress(ctx context.Context, job *model.ImportJob) error {
+	// Errorsとproject_idsをjsonbに変換するために、mapではなく構造体で更新するカラムを指定します。
+	if err := ijr.db.WithContext(ctx).Model(job).
+		Select("status", "total", "processed", "created", "duplicates", "failed", "errors", "project_ids", "error", "finished_at", "updated_at").
+		Updates(job).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ijr *importJobRepository) ClaimImportJob(ctx context.Context, now time.Time) (model.ImportJob, bool, error) {
+	job := model.ImportJob{}
+	claimed := false
+	err := ijr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		jobs := []model.ImportJob{}
+		// 複数のインスタンスでワーカーを動かしても同じジョブを二重に実行しないように、
+		// SELECT ... FOR UPDATE SKIP LOCKEDで他のインスタンスが確保中の行は飛ばします。
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}, Options: "SKIP LOCKED"}).
+			Where("status=?", model.ImportJobPending).Order("id").Limit(1).Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+		job = jobs[0]
+		job.Status = model.ImportJobRunning
+		job.StartedAt = &now
+		if err := tx.Model(&job).Updates(map[string]interface{}{"status": job.Status, "started_at": now}).Error; err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	})
+	if err != nil {
+		return model.ImportJob{}, false, err
+	}
+	return job, claimed, nil
+}
+
+func (ijr *importJobRepository) FailStaleJobs(ctx context.Context, staleBefore time.Time, now time.Time) ([]model.ImportJob, error) {
+	jobs := []model.ImportJob{}
+	if err := ijr.db.WithContext(ctx).Model(&jobs).Clauses(clause.Returning{}).
+		Where("status=? AND updated_at<?", model.ImportJobRunning, staleBefore).
+		Updates(map[string]interface{}{"status": model.ImportJobFailed, "error": "import was interrupted", "finished_at": now}).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IMailInboxRepository interface {
+	GetInboxByUserId(ctx context.Context, inbox *model.MailInbox, userId uint) error
+	GetInboxByToken(ctx context.Context, inbox *model.MailInbox, token string) error
+	// SaveInboxはユーザーの受信アドレスを作成する(既にある場合はアドレスを置き換えて、送信者はそのままにします)
+	SaveInbox(ctx context.Context, inbox *model.MailInbox) error
+	UpdateAllowedSenders(ctx context.Context, inbox *model.MailInbox, userId uint) error
+	DeleteInbox(ctx context.Context, userId uint) error
+}
+
+type mailInboxRepository struct {
+	db *gorm.DB
+}
+
+func NewMailInboxRepository(db *gorm.DB) IMailInboxRepository {
+	return &mailInboxRepository{db}
+}
+
+func (mir *mailInboxRepository) GetInboxByUserId(ctx context.Context, inbox *model.MailInbox, userId uint) error {
+	if err := mir.db.WithContext(ctx).Where("user_id=?", userId).First(inbox).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mir *mailInboxRepository) GetInboxByToken(ctx context.Context, inbox *model.MailInbox, token string) error {
+	if err := mir.db.WithContext(ctx).Where("token=?", token).First(inbox).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mir *mailInboxRepository) SaveInbox(ctx context.Context, inbox *model.MailInbox) error {
+	if err := mir.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token", "updated_at"}),
+	}, clause.Returning{}).Create(inbox).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mir *mailInboxRepository) UpdateAllowedSenders(ctx context.Context, inbox *model.MailInbox, userId uint) error {
+	result := mir.db.WithContext(ctx).Model(inbox).Clauses(clause.Returning{}).Where("user_id=?", userId).
+		Select("allowed_senders", "updated_at").Updates(inbox)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (mir *mailInboxRepository) DeleteInbox(ctx context.Context, userId uint) error {
+	result := mir.db.WithContext(ctx).Where("user_id=?", userId).Delete(&model.MailInbox{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
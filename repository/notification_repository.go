@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type INotificationRepository interface {
+	// GetNotificationsでユーザーへの通知を新しい順に取得(unreadOnlyがtrueの場合は未読のみ)
+	GetNotifications(ctx context.Context, notifications *[]model.Notification, userId uint, unreadOnly bool) error
+	// CreateNotificationsで通知をまとめて作成
+	CreateNotifications(ctx context.Context, notifications []model.Notification) error
+	// MarkAsReadで通知を既読にする
+	MarkAsRead(ctx context.Context, userId uint, notificationId uint) error
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) INotificationRepository {
+	return &notificationRepository{db}
+}
+
+func (nr *notificationRepository) GetNotifications(ctx context.Context, notifications *[]model.Notification, userId uint, unreadOnly bool) error {
+	query := conn(ctx, nr.db).Where("user_id=?", userId)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	if err := query.Order("created_at DESC").Find(notifications).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (nr *notificationRepository) CreateNotifications(ctx context.Context, notifications []model.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	if err := conn(ctx, nr.db).Create(&notifications).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (nr *notificationRepository) MarkAsRead(ctx context.Context, userId uint, notificationId uint) error {
+	result := conn(ctx, nr.db).Model(&model.Notification{}).Where("id=? AND user_id=?", notificationId, userId).Update("read_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"fmt"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IOrganizationRepository interface {
+	// GetMembershipsByUserでユーザーが所属している組織の一覧を、組織の情報と一緒に取得
+	GetMembershipsByUser(memberships *[]model.Membership, userId uint) error
+	// GetMembershipで組織でのユーザーの所属を取得(メンバーではない場合はgorm.ErrRecordNotFound)
+	GetMembership(membership *model.Membership, orgId uint, userId uint) error
+	// GetOrganizationByIdで引数で渡すorgIdの組織を取得
+	GetOrganizationById(org *model.Organization, orgId uint) error
+	// CreateOrganizationで組織を作成し、作成したユーザーをownerとして追加
+	CreateOrganization(org *model.Organization, userId uint) error
+	// UpdateOrganizationで組織の名前を更新
+	UpdateOrganization(org *model.Organization, orgId uint) error
+	// DeleteOrganizationで組織を削除(組織のタスク・プロジェクトも一緒に削除されます)
+	DeleteOrganization(orgId uint) error
+	// GetMembersで組織のメンバーの一覧を取得
+	GetMembers(memberships *[]model.Membership, orgId uint) error
+	// CountOwnersで組織のownerの人数を取得
+	CountOwners(orgId uint) (int64, error)
+	// UpdateMemberRoleでメンバーの権限を変更
+	UpdateMemberRole(orgId uint, userId uint, role string) error
+	// DeleteMemberでメンバーを組織から外す
+	DeleteMember(orgId uint, userId uint) error
+	// GetInvitationsで組織のまだ受け入れられていない招待の一覧を取得
+	GetInvitations(invitations *[]model.Invitation, orgId uint) error
+	// GetInvitationByTokenHashでトークンのハッシュに一致する招待を取得
+	GetInvitationByTokenHash(invitation *model.Invitation, tokenHash string) error
+	// CreateInvitationで招待の新規作成
+	CreateInvitation(invitation *model.Invitation) error
+	// DeleteInvitationで招待の取り消し
+	DeleteInvitation(orgId uint, invitationId uint) error
+	// AcceptInvitationで招待を受け入れ済みにして、ユーザーをメンバーとして追加
+	AcceptInvitation(invitation *model.Invitation, userId uint) error
+}
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) IOrganizationRepository {
+	return &organizationRepository{db}
+}
+
+func (or *organizationRepository) GetMembershipsByUser(memberships *[]model.Membership, userId uint) error {
+	if err := or.db.Joins("Organization").Where("memberships.user_id=?", userId).Order("memberships.created_at").Find(memberships).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (or *organizationRepository) GetMembership(membership *model.Membership, orgId uint, userId uint) error {
+	if err := or.db.Where("organization_id=? AND user_id=?", orgId, userId).First(membership).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (or *organizationRepository) GetOrganizationById(org *model.Organization, orgId uint) error {
+	if err := or.db.First(org, orgId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (or *organizationRepository) CreateOrganization(org *model.Organization, userId uint) error {
+	// 組織とownerのメンバーは必ず一緒に作成されるように、トランザクションの中で作成します。
+	return or.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		membership := model.Membership{Role: model.OrgRoleOwner, OrganizationId: org.ID, UserId: userId}
+		return tx.Omit("Organization", "User").Create(&membership).Error
+	})
+}
+
+func (or *organizationRepository) UpdateOrganization(org *model.Organization, orgId uint) error {
+	result := or.db.Model(org).Clauses(clause.Returning{}).Where("id=?", orgId).Update("name", org.Name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (or *organizationRepository) DeleteOrganization(orgId uint) error {
+	result := or.db.Where("id=?", orgId).Delete(&model.Organization{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (or *organizationRepository) GetMembers(memberships *[]model.Membership, orgId uint) error {
+	if err := or.db.Joins("User").Where("memberships.organization_id=?", orgId).Order("memberships.created_at").Find(memberships).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (or *organizationRepository) CountOwners(orgId uint) (int64, error) {
+	var count int64
+	if err := or.db.Model(&model.Membership{}).Where("organization_id=? AND role=?", orgId, model.OrgRoleOwner).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (or *organizationRepository) UpdateMemberRole(orgId uint, userId uint, role string) error {
+	result := or.db.Model(&model.Membership{}).Where("organization_id=? AND user_id=?", orgId, userId).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (or *organizationRepository) DeleteMember(orgId uint, userId uint) error {
+	result := or.db.Where("organization_id=? AND user_id=?", orgId, userId).Delete(&model.Membership{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (or *organizationRepository) GetInvitations(invitations *[]model.Invitation, orgId uint) error {
+	if err := or.db.Where("organization_id=? AND accepted_at IS NULL", orgId).Order("created_at DESC").Find(invitations).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (or *organizationRepository) GetInvitationByTokenHash(invitation *model.Invitation, tokenHash string) error {
+	if err := or.db.Where("token_hash=?", tokenHash).First(invitation).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (or *organizationRepository) CreateInvitation(invitation *model.Invitation) error {
+	if err := or.db.Omit("Organization", "User").Create(invitation).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (or *organizationRepository) DeleteInvitation(orgId uint, invitationId uint) error {
+	result := or.db.Where("id=? AND organization_id=?", invitationId, orgId).Delete(&model.Invitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (or *organizationRepository) AcceptInvitation(invitation *model.Invitation, userId uint) error {
+	return or.db.Transaction(func(tx *gorm.DB) error {
+		// 同じ招待が同時に2回受け入れられないように、まだ受け入れられていない場合だけ更新します。
+		now := time.Now()
+		result := tx.Model(&model.Invitation{}).Where("id=? AND accepted_at IS NULL", invitation.ID).Update("accepted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return fmt.Errorf("invitation was already accepted")
+		}
+		invitation.AcceptedAt = &now
+		// 既にメンバーの場合は、権限を変えずにそのままにします。
+		membership := model.Membership{Role: invitation.Role, OrganizationId: invitation.OrganizationId, UserId: userId}
+		return tx.Omit("Organization", "User").Clauses(clause.OnConflict{DoNothing: true}).Create(&membership).Error
+	})
+}
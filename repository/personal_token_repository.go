@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IPersonalTokenRepository interface {
+	GetTokens(ctx context.Context, tokens *[]model.PersonalToken, userId uint) error
+	CreateToken(ctx context.Context, token *model.PersonalToken) error
+	DeleteToken(ctx context.Context, userId uint, tokenId uint) error
+	// GetTokenByHashでトークンのハッシュからトークンを取得して、最後に使った日時を更新
+	GetTokenByHash(ctx context.Context, token *model.PersonalToken, hash string) error
+}
+
+type personalTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPersonalTokenRepository(db *gorm.DB) IPersonalTokenRepository {
+	return &personalTokenRepository{db}
+}
+
+func (ptr *personalTokenRepository) GetTokens(ctx context.Context, tokens *[]model.PersonalToken, userId uint) error {
+	if err := ptr.db.WithContext(ctx).Where("user_id=?", userId).Order("created_at").Find(tokens).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ptr *personalTokenRepository) CreateToken(ctx context.Context, token *model.PersonalToken) error {
+	if err := ptr.db.WithContext(ctx).Create(token).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ptr *personalTokenRepository) DeleteToken(ctx context.Context, userId uint, tokenId uint) error {
+	result := ptr.db.WithContext(ctx).Where("id=? AND user_id=?", tokenId, userId).Delete(&model.PersonalToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (ptr *personalTokenRepository) GetTokenByHash(ctx context.Context, token *model.PersonalToken, hash string) error {
+	if err := ptr.db.WithContext(ctx).Where("token_hash=?", hash).First(token).Error; err != nil {
+		return err
+	}
+	// CalDAVのクライアントはリクエストのたびに認証するので、更新は1分に1回までにします。
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+		token.LastUsedAt = &now
+		if err := ptr.db.WithContext(ctx).Model(token).UpdateColumn("last_used_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// projectsテーブルへのクエリもtasksテーブルと同じように、tenantパッケージがctxのテナントで絞り込みます。
+type IProjectRepository interface {
+	// GetProjectsでアクセス権のスコープ(visible)に当てはまるプロジェクトの一覧を取得
+	GetProjects(ctx context.Context, projects *[]model.Project, visible func(db *gorm.DB) *gorm.DB) error
+	// GetProjectByIdで引数で渡すprojectIdのプロジェクトを取得
+	GetProjectById(ctx context.Context, project *model.Project, projectId uint) error
+	// CreateProjectでプロジェクトの新規作成
+	CreateProject(ctx context.Context, project *model.Project) error
+	// UpdateProjectでプロジェクトの名前を更新
+	UpdateProject(ctx context.Context, project *model.Project, projectId uint) error
+	// DeleteProjectでプロジェクトの削除(中のタスクはプロジェクト無しのタスクとして残ります)
+	DeleteProject(ctx context.Context, projectId uint) error
+}
+
+type projectRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectRepository(db *gorm.DB) IProjectRepository {
+	return &projectRepository{db}
+}
+
+func (pr *projectRepository) GetProjects(ctx context.Context, projects *[]model.Project, visible func(db *gorm.DB) *gorm.DB) error {
+	if err := pr.db.WithContext(ctx).Scopes(visible).Order("projects.created_at").Find(projects).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pr *projectRepository) GetProjectById(ctx context.Context, project *model.Project, projectId uint) error {
+	if err := pr.db.WithContext(ctx).First(project, projectId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pr *projectRepository) CreateProject(ctx context.Context, project *model.Project) error {
+	if err := pr.db.WithContext(ctx).Create(project).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pr *projectRepository) UpdateProject(ctx context.Context, project *model.Project, projectId uint) error {
+	result := pr.db.WithContext(ctx).Model(project).Clauses(clause.Returning{}).Where("id=?", projectId).Update("name", project.Name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (pr *projectRepository) DeleteProject(ctx context.Context, projectId uint) error {
+	result := pr.db.WithContext(ctx).Where("id=?", projectId).Delete(&model.Project{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
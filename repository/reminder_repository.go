@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IReminderRepository interface {
+	// GetRemindersByTaskでタスクに設定されているリマインダーの一覧を取得
+	GetRemindersByTask(ctx context.Context, reminders *[]model.Reminder, userId uint, taskId uint) error
+	// CreateReminderでリマインダーの新規作成
+	CreateReminder(ctx context.Context, reminder *model.Reminder) error
+	// DeleteReminderで引数で渡すreminderIdのリマインダーの削除
+	DeleteReminder(ctx context.Context, userId uint, taskId uint, reminderId uint) error
+	// RescheduleOffsetRemindersでタスクの期限日が変わった時に、未送信のオフセット指定のリマインダーの通知時刻を再計算
+	RescheduleOffsetReminders(ctx context.Context, taskId uint, dueDate *time.Time) error
+	// CopyOffsetRemindersで繰り返しタスクの次の回に、オフセット指定のリマインダーを引き継ぐ
+	CopyOffsetReminders(ctx context.Context, fromTaskId uint, to model.Task) error
+	// ClaimDueRemindersで通知時刻を過ぎた未送信のリマインダーを最大limit件、配信中として確保してremindersに書き込む
+	// 確保はすぐにコミットするので、配信はトランザクションの外で行い、結果をRecordReminderResultで記録します。
+	ClaimDueReminders(ctx context.Context, now time.Time, limit int, reminders *[]model.Reminder) error
+	// RecordReminderResultで確保したリマインダーの配信の結果を記録(deliverErrがnilの場合は送信済みにする)
+	RecordReminderResult(ctx context.Context, reminder model.Reminder, now time.Time, deliverErr error) error
+}
+
+type reminderRepository struct {
+	db *gorm.DB
+}
+
+// maxReminderAttemptsは通知に失敗した時にリトライする最大回数
+const maxReminderAttempts = 5
+
+// reminderClaimTimeoutは確保したリマインダーの結果が記録されないまま、他のインスタンスが確保し直せるようになるまでの時間
+// 配信の途中でプロセスが止まった場合のためのもので、1回の確保分(50件 × 通知のタイムアウトの10秒)の配信が終わる時間より長くします。
+const reminderClaimTimeout = 15 * time.Minute
+
+func NewReminderRepository(db *gorm.DB) IReminderRepository {
+	return &reminderRepository{db}
+}
+
+func (rr *reminderRepository) GetRemindersByTask(ctx context.Context, reminders *[]model.Reminder, userId uint, taskId uint) error {
+	if err := conn(ctx, rr.db).Where("user_id=? AND task_id=?", userId, taskId).Order("created_at").Find(reminders).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rr *reminderRepository) CreateReminder(ctx context.Context, reminder *model.Reminder) error {
+	if err := conn(ctx, rr.db).Create(reminder).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rr *reminderRepository) DeleteReminder(ctx context.Context, userId uint, taskId uint, reminderId uint) error {
+	result := conn(ctx, rr.db).Where("id=? AND user_id=? AND task_id=?", reminderId, userId, taskId).Delete(&model.Reminder{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (rr *reminderRepository) RescheduleOffsetReminders(ctx context.Context, taskId uint, dueDate *time.Time) error {
+	query := conn(ctx, rr.db).Model(&model.Reminder{}).Where("task_id=? AND offset_minutes IS NOT NULL AND sent_at IS NULL AND failed_at IS NULL", taskId)
+	// 期限日が無くなった場合は通知時刻もクリアする
+	if dueDate == nil {
+		return query.Update("fire_at", nil).Error
+	}
+	// 通知時刻 = 期限日 - オフセット(分)をSQLの中で計算します。
+	return query.Update("fire_at", gorm.Expr("?::timestamptz - make_interval(mins => offset_minutes)", *dueDate)).Error
+}
+
+func (rr *reminderRepository) CopyOffsetReminders(ctx context.Context, fromTaskId uint, to model.Task) error {
+	reminders := []model.Reminder{}
+	if err := conn(ctx, rr.db).Where("task_id=? AND offset_minutes IS NOT NULL", fromTaskId).Find(&reminders).Error; err != nil {
+		return err
+	}
+	for _, r := range reminders {
+		copied := model.Reminder{
+			OffsetMinutes: r.OffsetMinutes,
+			Channel:       r.Channel,
+			Target:        r.Target,
+			TaskId:        to.ID,
+			UserId:        r.UserId,
+		}
+		if to.DueDate != nil {
+			fireAt := to.DueDate.Add(-time.Duration(*r.OffsetMinutes) * time.Minute)
+			copied.FireAt = &fireAt
+		}
+		if err := conn(ctx, rr.db).Create(&copied).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rr *reminderRepository) ClaimDueReminders(ctx context.Context, now time.Time, limit int, reminders *[]model.Reminder) error {
+	return conn(ctx, rr.db).Transaction(func(tx *gorm.DB) error {
+		// SELECT ... FOR UPDATE OF reminders SKIP LOCKEDで、他のインスタンスが確保中の行は飛ばして取得します。
+		// 確保した行にはclaimed_atを設定してすぐにコミットするので、HTTPやSMTPで送信している間は行をロックしません。
+		if err := tx.Joins("Task").Joins("User").
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}, Options: "SKIP LOCKED"}).
+			Where("reminders.fire_at<=? AND reminders.sent_at IS NULL AND reminders.failed_at IS NULL", now).
+			Where("reminders.claimed_at IS NULL OR reminders.claimed_at<?", now.Add(-reminderClaimTimeout)).
+			// 論理削除したタスクはJOINの条件で外れるので、タスクが削除されたリマインダーは復元されるまで通知しません。
+			Where(`"Task".id IS NOT NULL`).
+			Order("reminders.fire_at").Limit(limit).Find(reminders).Error; err != nil {
+			return err
+		}
+		if len(*reminders) == 0 {
+			return nil
+		}
+		// 結果を記録する時にclaimed_atが一致するかで確保し直されていないか確認するので、PostgreSQLの精度(マイクロ秒)に揃えておきます。
+		claimedAt := now.Truncate(time.Microsecond)
+		ids := []uint{}
+		for i := range *reminders {
+			r := &(*reminders)[i]
+			r.ClaimedAt = &claimedAt
+			r.Attempts++
+			ids = append(ids, r.ID)
+		}
+		return tx.Model(&model.Reminder{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"claimed_at": claimedAt, "attempts": gorm.Expr("attempts + 1")}).Error
+	})
+}
+
+func (rr *reminderRepository) RecordReminderResult(ctx context.Context, reminder model.Reminder, now time.Time, deliverErr error) error {
+	values := map[string]interface{}{"claimed_at": nil}
+	if deliverErr != nil {
+		values["last_error"] = deliverErr.Error()
+		if reminder.Attempts >= maxReminderAttempts {
+			values["failed_at"] = now
+		} else {
+			// 失敗した場合は、試行回数に応じて次の通知時刻を後ろにずらしてリトライ
+			values["fire_at"] = now.Add(time.Duration(1<<(reminder.Attempts-1)) * time.Minute)
+		}
+	} else {
+		values["sent_at"] = now
+		values["last_error"] = ""
+	}
+	// 確保してから時間がかかりすぎて他のインスタンスが確保し直した場合は、そちらの結果を上書きしないようにします。
+	return conn(ctx, rr.db).Model(&model.Reminder{}).
+		Where("id=? AND claimed_at=?", reminder.ID, reminder.ClaimedAt).Updates(values).Error
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IShareLinkRepository interface {
+	// GetShareLinksByTaskでタスクの公開リンクの一覧を取得
+	GetShareLinksByTask(ctx context.Context, links *[]model.ShareLink, taskId uint) error
+	// GetShareLinkByIdで引数で渡すlinkIdの公開リンクを取得
+	GetShareLinkById(ctx context.Context, link *model.ShareLink, linkId uint) error
+	// CreateShareLinkで公開リンクの新規作成
+	CreateShareLink(ctx context.Context, link *model.ShareLink) error
+	// RevokeShareLinkで公開リンクを無効にする
+	RevokeShareLink(ctx context.Context, taskId uint, linkId uint) error
+	// IncrementViewCountで公開リンクの閲覧数を1つ増やす
+	IncrementViewCount(ctx context.Context, linkId uint) error
+}
+
+type shareLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewShareLinkRepository(db *gorm.DB) IShareLinkRepository {
+	return &shareLinkRepository{db}
+}
+
+func (lr *shareLinkRepository) GetShareLinksByTask(ctx context.Context, links *[]model.ShareLink, taskId uint) error {
+	if err := conn(ctx, lr.db).Where("task_id=?", taskId).Order("created_at DESC").Find(links).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (lr *shareLinkRepository) GetShareLinkById(ctx context.Context, link *model.ShareLink, linkId uint) error {
+	if err := conn(ctx, lr.db).First(link, linkId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (lr *shareLinkRepository) CreateShareLink(ctx context.Context, link *model.ShareLink) error {
+	if err := conn(ctx, lr.db).Create(link).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (lr *shareLinkRepository) RevokeShareLink(ctx context.Context, taskId uint, linkId uint) error {
+	result := conn(ctx, lr.db).Model(&model.ShareLink{}).Where("id=? AND task_id=? AND revoked_at IS NULL", linkId, taskId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (lr *shareLinkRepository) IncrementViewCount(ctx context.Context, linkId uint) error {
+	// 同時に開かれても数え漏れが無いように、SQLの中で加算します。
+	return conn(ctx, lr.db).Model(&model.ShareLink{}).Where("id=?", linkId).
+		UpdateColumns(map[string]interface{}{
+			"view_count":     gorm.Expr("view_count + 1"),
+			"last_viewed_at": time.Now(),
+		}).Error
+}
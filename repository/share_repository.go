@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IShareRepository interface {
+	// GetSharesByTaskでタスクの共有先の一覧を取得
+	GetSharesByTask(ctx context.Context, shares *[]model.Share, taskId uint) error
+	// GetSharesByProjectでプロジェクトの共有先の一覧を取得
+	GetSharesByProject(ctx context.Context, shares *[]model.Share, projectId uint) error
+	// GetShareByIdで引数で渡すshareIdの共有を取得
+	GetShareById(ctx context.Context, share *model.Share, shareId uint) error
+	// SaveShareで共有を作成(同じ相手に共有済みの場合は権限を更新)
+	SaveShare(ctx context.Context, share *model.Share) error
+	// DeleteShareで共有の解除
+	DeleteShare(ctx context.Context, shareId uint) error
+}
+
+type shareRepository struct {
+	db *gorm.DB
+}
+
+func NewShareRepository(db *gorm.DB) IShareRepository {
+	return &shareRepository{db}
+}
+
+func (sr *shareRepository) GetSharesByTask(ctx context.Context, shares *[]model.Share, taskId uint) error {
+	if err := conn(ctx, sr.db).Joins("User").Where("shares.task_id=?", taskId).Order("shares.created_at").Find(shares).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (sr *shareRepository) GetSharesByProject(ctx context.Context, shares *[]model.Share, projectId uint) error {
+	if err := conn(ctx, sr.db).Joins("User").Where("shares.project_id=?", projectId).Order("shares.created_at").Find(shares).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (sr *shareRepository) GetShareById(ctx context.Context, share *model.Share, shareId uint) error {
+	if err := conn(ctx, sr.db).Joins("User").First(share, shareId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (sr *shareRepository) SaveShare(ctx context.Context, share *model.Share) error {
+	// 共有先のユーザーごとにユニークインデックスがあるので、既に共有済みの場合は権限だけを更新します。
+	columns := []clause.Column{{Name: "task_id"}, {Name: "user_id"}}
+	if share.ProjectId != nil {
+		columns = []clause.Column{{Name: "project_id"}, {Name: "user_id"}}
+	}
+	if err := conn(ctx, sr.db).Omit("User", "Task", "Project").Clauses(clause.OnConflict{
+		Columns:   columns,
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}, clause.Returning{}).Create(share).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (sr *shareRepository) DeleteShare(ctx context.Context, shareId uint) error {
+	result := conn(ctx, sr.db).Where("id=?", shareId).Delete(&model.Share{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
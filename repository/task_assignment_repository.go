@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+)
+
+type ITaskAssignmentRepository interface {
+	// GetAssignmentsでタスクの担当者の変更履歴を新しい順に取得
+	GetAssignments(assignments *[]model.TaskAssignment, taskId uint) error
+	// CreateAssignmentで担当者の変更を履歴に記録
+	CreateAssignment(assignment *model.TaskAssignment) error
+}
+
+type taskAssignmentRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskAssignmentRepository(db *gorm.DB) ITaskAssignmentRepository {
+	return &taskAssignmentRepository{db}
+}
+
+func (ar *taskAssignmentRepository) GetAssignments(assignments *[]model.TaskAssignment, taskId uint) error {
+	if err := ar.db.Where("task_id=?", taskId).Order("created_at DESC").Order("id DESC").Find(assignments).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *taskAssignmentRepository) CreateAssignment(assignment *model.TaskAssignment) error {
+	if err := ar.db.Omit("Task").Create(assignment).Error; err != nil {
+		return err
+	}
+	return nil
+}
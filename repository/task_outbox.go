@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"encoding/json"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+)
+
+// writeTaskEventsはタスクの変更と同じトランザクション(tx)で、Webhookで配信するイベントをアウトボックスに書き込む
+// イベントのタスクは変更した後の値(削除の場合は削除する前の値)で、作成者(User)などの関連は含めません。
+func writeTaskEvents(tx *gorm.DB, task model.Task, events ...string) error {
+	tags := task.Tags
+	if tags == nil {
+		tags = model.TaskTags{}
+	}
+	data, err := json.Marshal(model.TaskResponse{
+		ID:             task.ID,
+		Title:          task.Title,
+		Description:    task.Description,
+		Completed:      task.Completed,
+		DueDate:        task.DueDate,
+		SeriesId:       task.SeriesId,
+		RecurrenceId:   task.RecurrenceId,
+		Position:       task.Position,
+		ProjectId:      task.ProjectId,
+		OrganizationId: task.OrganizationId,
+		AssigneeId:     task.AssigneeId,
+		ParentId:       task.ParentId,
+		ColumnId:       task.ColumnId,
+		ColumnPosition: task.ColumnPosition,
+		CustomFields:   task.CustomFields,
+		Tags:           tags,
+		UserId:         task.UserId,
+		CreatedAt:      task.CreatedAt,
+		UpdatedAt:      task.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	outbox := []model.TaskOutboxEvent{}
+	for _, event := range events {
+		outbox = append(outbox, model.TaskOutboxEvent{
+			Event:          event,
+			TaskId:         task.ID,
+			UserId:         task.UserId,
+			AssigneeId:     task.AssigneeId,
+			ProjectId:      task.ProjectId,
+			OrganizationId: task.OrganizationId,
+			Task:           string(data),
+		})
+	}
+	return tx.Create(&outbox).Error
+}
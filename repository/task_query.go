@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/taskquery"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// compileTaskQueryは検索クエリの構文木を、タスクの一覧を絞り込むSQLの条件に変換する
+// 値は全てプレースホルダーで渡すので、クエリの文字列がそのままSQLに入ることはありません。
+// キーや値がタスクの項目に合わない場合は、問題のある位置を指すtaskquery.Errorを返します。
+func compileTaskQuery(q model.TaskQuery) (clause.Expr, error) {
+	c := &taskQueryCompiler{q: q, now: q.Now.UTC()}
+	return c.compile(q.Node)
+}
+
+type taskQueryCompiler struct {
+	q   model.TaskQuery
+	now time.Time
+}
+
+func (c *taskQueryCompiler) compile(node taskquery.Node) (clause.Expr, error) {
+	switch n := node.(type) {
+	case taskquery.And:
+		return c.join(n.Nodes, " AND ")
+	case taskquery.Or:
+		return c.join(n.Nodes, " OR ")
+	case taskquery.Not:
+		expr, err := c.compile(n.Node)
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		return not(expr), nil
+	case taskquery.Text:
+		return gorm.Expr("tasks.title ILIKE ?", likePattern(n.Value)), nil
+	case taskquery.Term:
+		return c.term(n)
+	}
+	return clause.Expr{}, fmt.Errorf("unsupported query node %T", node)
+}
+
+func (c *taskQueryCompiler) join(nodes []taskquery.Node, sep string) (clause.Expr, error) {
+	parts := []string{}
+	vars := []interface{}{}
+	for _, n := range nodes {
+		expr, err := c.compile(n)
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		parts = append(parts, "("+expr.SQL+")")
+		vars = append(vars, expr.Vars...)
+	}
+	return clause.Expr{SQL: strings.Join(parts, sep), Vars: vars}, nil
+}
+
+// notは条件の否定(値が無くてNULLになる場合も「条件を満たさない」として含めます)
+func not(expr clause.Expr) clause.Expr {
+	return clause.Expr{SQL: "NOT COALESCE((" + expr.SQL + "), false)", Vars: expr.Vars}
+}
+
+// likePatternはILIKEの部分一致のパターンを作る(%と_は文字としてそのまま検索します)
+func likePattern(value string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value) + "%"
+}
+
+func (c *taskQueryCompiler) term(t taskquery.Term) (clause.Expr, error) {
+	switch strings.ToLower(t.Key) {
+	case model.TaskQueryStatus:
+		return c.status(t)
+	case model.TaskQueryTitle:
+		return c.title(t)
+	case model.TaskQueryDue:
+		return c.timeTerm(t, "tasks.due_date")
+	case model.TaskQueryCreated:
+		return c.timeTerm(t, "tasks.created_at")
+	case model.TaskQueryProject:
+		return c.reference(t, "tasks.project_id", func(value string) clause.Expr {
+			return gorm.Expr("tasks.project_id IN (SELECT id FROM projects WHERE LOWER(projects.name) = LOWER(?))", value)
+		})
+	case model.TaskQueryAssignee:
+		return c.reference(t, "tasks.assignee_id", nil)
+	case model.TaskQueryTag:
+		return c.tag(t)
+	}
+	return c.customField(t)
+}
+
+// equalityは:・=・!=だけが使える条件を、!=の場合は否定にして返す
+func equality(t taskquery.Term, expr clause.Expr) (clause.Expr, error) {
+	switch t.Op {
+	case taskquery.OpEq, taskquery.OpEqual:
+		return expr, nil
+	case taskquery.OpNe:
+		return not(expr), nil
+	}
+	return clause.Expr{}, taskquery.Errorf(t.KeyPos, "%s does not support %s", t.Key, t.Op)
+}
+
+func (c *taskQueryCompiler) status(t taskquery.Term) (clause.Expr, error) {
+	var completed bool
+	switch strings.ToLower(t.Value) {
+	case "open":
+		completed = false
+	case "done", "completed":
+		completed = true
+	default:
+		return clause.Expr{}, taskquery.Errorf(t.ValuePos, "status must be open or done")
+	}
+	return equality(t, gorm.Expr("tasks.completed = ?", completed))
+}
+
+func (c *taskQueryCompiler) title(t taskquery.Term) (clause.Expr, error) {
+	// title:は部分一致、title=は完全一致
+	if t.Op == taskquery.OpEqual {
+		return gorm.Expr("tasks.title = ?", t.Value), nil
+	}
+	return equality(t, gorm.Expr("tasks.title ILIKE ?", likePattern(t.Value)))
+}
+
+// tagはタグの条件を作る(tag:noneはタグが無いタスク)
+// タグの配列を含むかを@>で調べて、tasks.tagsのGINのインデックスを使えるようにします。
+func (c *taskQueryCompiler) tag(t taskquery.Term) (clause.Expr, error) {
+	if isNone(t) {
+		return equality(t, gorm.Expr("tasks.tags = '[]'::jsonb"))
+	}
+	b, err := json.Marshal([]string{t.Value})
+	if err != nil {
+		return clause.Expr{}, err
+	}
+	return equality(t, gorm.Expr("tasks.tags @> ?::jsonb", string(b)))
+}
+
+// isNoneは値が無いことを表すnone(引用符で囲んでいない場合だけ)
+func isNone(t taskquery.Term) bool {
+	return !t.Quoted && strings.EqualFold(t.Value, "none")
+}
+
+// referenceはproject・assigneeのようなIDで参照する項目の条件を作る
+// 値はID、none(未設定)、assigneeの場合はme(ログインしているユーザー)、projectの場合はプロジェクトの名前です。
+func (c *taskQueryCompiler) reference(t taskquery.Term, column string, byName func(value string) clause.Expr) (clause.Expr, error) {
+	if isNone(t) {
+		return equality(t, gorm.Expr(column+" IS NULL"))
+	}
+	if column == "tasks.assignee_id" && strings.EqualFold(t.Value, "me") && !t.Quoted {
+		return equality(t, gorm.Expr(column+" = ?", c.q.UserId))
+	}
+	if id, err := strconv.ParseUint(t.Value, 10, 64); err == nil {
+		return equality(t, gorm.Expr(column+" = ?", id))
+	}
+	if byName == nil {
+		return clause.Expr{}, taskquery.Errorf(t.ValuePos, "%s must be me, none or a user id", t.Key)
+	}
+	return equality(t, byName(t.Value))
+}
+
+var relativePattern = regexp.MustCompile(`^([+-]?\d+)([hdwm])$`)
+
+// timeRangeは日時の値を[start, end)の範囲に変換する
+// 日付(YYYY-MM-DD)とtoday・tomorrow・yesterdayはその日の1日、7d・-3d・2w・12h・1mは今からの相対的な時刻(start=end)です。
+func (c *taskQueryCompiler) timeRange(t taskquery.Term) (time.Time, time.Time, bool, error) {
+	today := time.Date(c.now.Year(), c.now.Month(), c.now.Day(), 0, 0, 0, 0, time.UTC)
+	switch strings.ToLower(t.Value) {
+	case "today":
+		return today, today.AddDate(0, 0, 1), false, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), today.AddDate(0, 0, 2), false, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, false, nil
+	}
+	if m := relativePattern.FindStringSubmatch(strings.ToLower(t.Value)); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, time.Time{}, false, taskquery.Errorf(t.ValuePos, "invalid relative time %s", t.Value)
+		}
+		at := c.now
+		switch m[2] {
+		case "h":
+			at = at.Add(time.Duration(n) * time.Hour)
+		case "d":
+			at = at.AddDate(0, 0, n)
+		case "w":
+			at = at.AddDate(0, 0, 7*n)
+		case "m":
+			at = at.AddDate(0, n, 0)
+		}
+		return at, at, true, nil
+	}
+	day, err := time.Parse(model.CustomFieldDateLayout, t.Value)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, taskquery.Errorf(t.ValuePos,
+			"%s must be YYYY-MM-DD, today, tomorrow, yesterday, none or relative time like 7d", t.Key)
+	}
+	return day, day.AddDate(0, 0, 1), false, nil
+}
+
+// timeTermはdue・createdの条件を作る
+// due:7dのように相対的な時刻を:で指定した場合は今からその時刻までの間(due:-3dは3日前から今まで)、
+// due<7dのように比べる場合はその時刻になります。
+func (c *taskQueryCompiler) timeTerm(t taskquery.Term, column string) (clause.Expr, error) {
+	if isNone(t) {
+		return equality(t, gorm.Expr(column+" IS NULL"))
+	}
+	start, end, relative, err := c.timeRange(t)
+	if err != nil {
+		return clause.Expr{}, err
+	}
+	if relative && (t.Op == taskquery.OpEq || t.Op == taskquery.OpEqual || t.Op == taskquery.OpNe) {
+		if start.After(c.now) {
+			start, end = c.now, start
+		} else {
+			end = c.now
+		}
+	}
+	switch t.Op {
+	case taskquery.OpLt:
+		return gorm.Expr(column+" < ?", start), nil
+	case taskquery.OpLte:
+		return gorm.Expr(column+" < ?", end), nil
+	case taskquery.OpGt:
+		return gorm.Expr(column+" >= ?", end), nil
+	case taskquery.OpGte:
+		return gorm.Expr(column+" >= ?", start), nil
+	}
+	return equality(t, gorm.Expr(column+" >= ? AND "+column+" < ?", start, end))
+}
+
+// customFieldはキーを独自の項目の名前(またはfield.<項目のID>)として条件を作る
+// 同じ名前の項目が複数のプロジェクトにある場合は、どれかの項目の条件を満たすタスクに絞り込みます。
+func (c *taskQueryCompiler) customField(t taskquery.Term) (clause.Expr, error) {
+	fields := []model.CustomField{}
+	for _, f := range c.q.Fields {
+		if strings.EqualFold(f.Name, t.Key) || t.Key == "field."+strconv.FormatUint(uint64(f.ID), 10) {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return clause.Expr{}, taskquery.Errorf(t.KeyPos, "unknown key %s", t.Key)
+	}
+	parts := []string{}
+	vars := []interface{}{}
+	for _, f := range fields {
+		expr, err := c.customFieldCondition(t, f)
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		parts = append(parts, "("+expr.SQL+")")
+		vars = append(vars, expr.Vars...)
+	}
+	return clause.Expr{SQL: strings.Join(parts, " OR "), Vars: vars}, nil
+}
+
+func (c *taskQueryCompiler) customFieldCondition(t taskquery.Term, f model.CustomField) (clause.Expr, error) {
+	key := strconv.FormatUint(uint64(f.ID), 10)
+	if isNone(t) {
+		return equality(t, gorm.Expr("tasks.custom_fields -> ?::text IS NULL", key))
+	}
+	switch f.Type {
+	case model.CustomFieldNumber:
+		value, err := strconv.ParseFloat(t.Value, 64)
+		if err != nil {
+			return clause.Expr{}, taskquery.Errorf(t.ValuePos, "%s must be a number", t.Key)
+		}
+		return compare(t, "(tasks.custom_fields ->> ?::text)::numeric", key, value,
+			gorm.Expr("tasks.custom_fields @> jsonb_build_object(?::text, ?::numeric)", key, value))
+	case model.CustomFieldDate:
+		// dateの項目は日単位で比べるので、相対的な指定もその日付として扱います。
+		day, _, _, err := c.timeRange(t)
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		value := day.Format(model.CustomFieldDateLayout)
+		return compare(t, "(tasks.custom_fields ->> ?::text)::date", key, value,
+			gorm.Expr("(tasks.custom_fields ->> ?::text)::date = ?::date", key, value))
+	case model.CustomFieldText:
+		// text:は部分一致、text=は完全一致
+		if t.Op == taskquery.OpEq {
+			return gorm.Expr("tasks.custom_fields ->> ?::text ILIKE ?", key, likePattern(t.Value)), nil
+		}
+		return equality(t, gorm.Expr("tasks.custom_fields @> jsonb_build_object(?::text, ?::text)", key, t.Value))
+	case model.CustomFieldSelect:
+		return equality(t, gorm.Expr("tasks.custom_fields @> jsonb_build_object(?::text, ?::text)", key, t.Value))
+	case model.CustomFieldMultiSelect:
+		// multi_selectは選んだ値の中に含まれているかどうか
+		return equality(t, gorm.Expr("tasks.custom_fields @> jsonb_build_object(?::text, jsonb_build_array(?::text))", key, t.Value))
+	}
+	return clause.Expr{}, taskquery.Errorf(t.KeyPos, "unsupported field type %s", f.Type)
+}
+
+// compareは大小を比べられる項目(numberとdate)の条件を作る(:・=・!=の場合はeqの条件を使います)
+func compare(t taskquery.Term, column string, key string, value interface{}, eq clause.Expr) (clause.Expr, error) {
+	switch t.Op {
+	case taskquery.OpGt, taskquery.OpGte, taskquery.OpLt, taskquery.OpLte:
+		cast := "::numeric"
+		if strings.HasSuffix(column, "::date") {
+			cast = "::date"
+		}
+		return gorm.Expr(column+" "+t.Op+" ?"+cast, key, value), nil
+	}
+	return equality(t, eq)
+}
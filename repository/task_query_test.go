@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/taskquery"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// テストの基準の日時(2024-03-10 15:00 UTC)と、クエリで使う独自の項目
+var (
+	testQueryNow    = time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	testQueryFields = []model.CustomField{
+		{ID: 3, Name: "Points", Type: model.CustomFieldNumber},
+		{ID: 4, Name: "Stage", Type: model.CustomFieldSelect},
+		{ID: 6, Name: "Notes", Type: model.CustomFieldText},
+		{ID: 7, Name: "Labels", Type: model.CustomFieldMultiSelect},
+		{ID: 8, Name: "Start", Type: model.CustomFieldDate},
+		// 別のプロジェクトの同じ名前の項目
+		{ID: 9, Name: "points", Type: model.CustomFieldNumber},
+	}
+)
+
+func utcDay(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func compileTestQuery(t *testing.T, input string) (string, []interface{}, error) {
+	t.Helper()
+	node, err := taskquery.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", input, err)
+	}
+	expr, err := compileTaskQuery(model.TaskQuery{Node: node, UserId: 7, Now: testQueryNow, Fields: testQueryFields})
+	return expr.SQL, expr.Vars, err
+}
+
+func TestCompileTaskQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		sql   string
+		vars  []interface{}
+	}{
+		{"status open", "status:open", "tasks.completed = ?", []interface{}{false}},
+		{"status done", "status:done", "tasks.completed = ?", []interface{}{true}},
+		{"status not equal", "status!=open", "NOT COALESCE((tasks.completed = ?), false)", []interface{}{false}},
+		{"negation", "-status:open", "NOT COALESCE((tasks.completed = ?), false)", []interface{}{false}},
+		{"free text", "milk", "tasks.title ILIKE ?", []interface{}{"%milk%"}},
+		{"free text escapes like", `50%_off\`, "tasks.title ILIKE ?", []interface{}{`%50\%\_off\\%`}},
+		{"title contains", "title:milk", "tasks.title ILIKE ?", []interface{}{"%milk%"}},
+		{"title equals", `title="buy milk"`, "tasks.title = ?", []interface{}{"buy milk"}},
+		{"and", "status:open milk", "(tasks.completed = ?) AND (tasks.title ILIKE ?)", []interface{}{false, "%milk%"}},
+		{"precedence", "a OR b c", "(tasks.title ILIKE ?) OR ((tasks.title ILIKE ?) AND (tasks.title ILIKE ?))",
+			[]interface{}{"%a%", "%b%", "%c%"}},
+		{"parentheses", "(a OR b) c", "((tasks.title ILIKE ?) OR (tasks.title ILIKE ?)) AND (tasks.title ILIKE ?)",
+			[]interface{}{"%a%", "%b%", "%c%"}},
+		{"tag", "tag:work", "tasks.tags @> ?::jsonb", []interface{}{`["work"]`}},
+		{"tag quoted", `tag:"on hold"`, "tasks.tags @> ?::jsonb", []interface{}{`["on hold"]`}},
+		{"tag none", "tag:none", "tasks.tags = '[]'::jsonb", nil},
+		{"tag quoted none", `tag:"none"`, "tasks.tags @> ?::jsonb", []interface{}{`["none"]`}},
+		{"tag not", "tag!=work", "NOT COALESCE((tasks.tags @> ?::jsonb), false)", []interface{}{`["work"]`}},
+		{"project none", "project:none", "tasks.project_id IS NULL", nil},
+		{"project id", "project:5", "tasks.project_id = ?", []interface{}{uint64(5)}},
+		{"project name", "project:Home",
+			"tasks.project_id IN (SELECT id FROM projects WHERE LOWER(projects.name) = LOWER(?))", []interface{}{"Home"}},
+		{"assignee me", "assignee:me", "tasks.assignee_id = ?", []interface{}{uint(7)}},
+		{"due today", "due:today", "tasks.due_date >= ? AND tasks.due_date < ?", []interface{}{utcDay(2024, 3, 10), utcDay(2024, 3, 11)}},
+		{"due none", "due:none", "tasks.due_date IS NULL", nil},
+		{"due before relative", "due<7d", "tasks.due_date < ?", []interface{}{testQueryNow.AddDate(0, 0, 7)}},
+		{"due within relative", "due:7d", "tasks.due_date >= ? AND tasks.due_date < ?",
+			[]interface{}{testQueryNow, testQueryNow.AddDate(0, 0, 7)}},
+		{"due within past", "due:-3d", "tasks.due_date >= ? AND tasks.due_date < ?",
+			[]interface{}{testQueryNow.AddDate(0, 0, -3), testQueryNow}},
+		{"due after date", "due>2024-03-01", "tasks.due_date >= ?", []interface{}{utcDay(2024, 3, 2)}},
+		{"due until date", "due<=2024-03-01", "tasks.due_date < ?", []interface{}{utcDay(2024, 3, 2)}},
+		{"created since yesterday", "created>=yesterday", "tasks.created_at >= ?", []interface{}{utcDay(2024, 3, 9)}},
+		{"number field in two projects", "Points>=2",
+			"((tasks.custom_fields ->> ?::text)::numeric >= ?::numeric) OR ((tasks.custom_fields ->> ?::text)::numeric >= ?::numeric)",
+			[]interface{}{"3", 2.0, "9", 2.0}},
+		{"select field", "Stage:Doing", "(tasks.custom_fields @> jsonb_build_object(?::text, ?::text))", []interface{}{"4", "Doing"}},
+		{"field by id", "field.4:Doing", "(tasks.custom_fields @> jsonb_build_object(?::text, ?::text))", []interface{}{"4", "Doing"}},
+		{"field none", "Stage:none", "(tasks.custom_fields -> ?::text IS NULL)", []interface{}{"4"}},
+		{"text field contains", "Notes:abc", "(tasks.custom_fields ->> ?::text ILIKE ?)", []interface{}{"6", "%abc%"}},
+		{"multi select field", "Labels:x",
+			"(tasks.custom_fields @> jsonb_build_object(?::text, jsonb_build_array(?::text)))", []interface{}{"7", "x"}},
+		{"date field", "Start<today", "((tasks.custom_fields ->> ?::text)::date < ?::date)", []interface{}{"8", "2024-03-10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, vars, err := compileTestQuery(t, tt.input)
+			if err != nil {
+				t.Fatalf("compile(%q): %v", tt.input, err)
+			}
+			if sql != tt.sql {
+				t.Errorf("compile(%q) sql:\n got  %s\n want %s", tt.input, sql, tt.sql)
+			}
+			if len(vars) != 0 || len(tt.vars) != 0 {
+				if !reflect.DeepEqual(vars, tt.vars) {
+					t.Errorf("compile(%q) vars: got %#v, want %#v", tt.input, vars, tt.vars)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileTaskQueryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   int
+		msg   string
+	}{
+		{"unknown status", "status:maybe", 8, "status must be open or done"},
+		{"status comparison", "status>open", 1, "status does not support >"},
+		{"tag comparison", "milk tag>work", 6, "tag does not support >"},
+		{"unknown key", "a foo:bar", 3, "unknown key foo"},
+		{"invalid date", "a due:soon", 7, "due must be YYYY-MM-DD, today, tomorrow, yesterday, none or relative time like 7d"},
+		{"invalid assignee", "assignee:bob", 10, "assignee must be me, none or a user id"},
+		// 引用符で囲んだmeはログインしているユーザーではなく、文字列のmeです。
+		{"quoted me", `assignee:"me"`, 10, "assignee must be me, none or a user id"},
+		{"invalid number", "(a OR Points:x)", 14, "Points must be a number"},
+		{"error inside negation", "-status:maybe", 9, "status must be open or done"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := compileTestQuery(t, tt.input)
+			var qerr *taskquery.Error
+			if !errors.As(err, &qerr) {
+				t.Fatalf("compile(%q): got %v, want *taskquery.Error", tt.input, err)
+			}
+			if qerr.Pos != tt.pos || qerr.Msg != tt.msg {
+				t.Errorf("compile(%q): got position %d %q, want position %d %q", tt.input, qerr.Pos, qerr.Msg, tt.pos, tt.msg)
+			}
+		})
+	}
+}
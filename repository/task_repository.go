@@ -1,29 +1,83 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"go-rest-api/model"
+	"go-rest-api/rank"
+	"strconv"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// ErrObjectNotExistは更新・削除する行が見つからなかった(RowsAffectedが0だった)場合のエラー
+// 各リポジトリで共通に使うので、呼び出し元はerrors.Isで判定します。
+var ErrObjectNotExist = errors.New("object does not exist")
+
+// ITaskRepositoryのメソッドは全て第1引数でctxを受け取ります。
+// tasksテーブルへのクエリはtenantパッケージがctxのテナント(組織)で自動的に絞り込むので、ここでは組織の条件を書きません。
+// タスクの作成・更新・削除と担当者の変更では、Webhookで配信するイベントを同じトランザクションでアウトボックス(task_outbox_events)に書き込みます。
 type ITaskRepository interface {
-	// GetAllTasksはログインしているユーザー自身が作成したタスクの一覧を取得するメソッド
+	// GetAllTasksはログインしているユーザーが閲覧できるタスクの一覧を取得するメソッド
 	// タスクの一覧を配列に格納するために第1引数としてモデルタスクのスライス([]model.Task)のポインタを渡す
-	// 第2引数はログインしてるユーザーのuserIdを渡す
+	// 第2引数は絞り込みの条件、第3引数はpermissionパッケージで作るアクセス権のスコープを渡す
 	// 返り値はerrorインターフェース型
-	GetAllTasks(tasks *[]model.Task, userId uint) error
+	GetAllTasks(ctx context.Context, tasks *[]model.Task, filter model.TaskFilter, visible func(db *gorm.DB) *gorm.DB) error
+	// ExportTasksでGetAllTasksと同じ条件のタスクを、IDの順番にbatchSize件ずつ取得してfnに渡す
+	// 全てのタスクを一度にメモリに読み込まないので、タスクの多いアカウントのエクスポートに使います。
+	ExportTasks(ctx context.Context, filter model.TaskFilter, visible func(db *gorm.DB) *gorm.DB, batchSize int, fn func(tasks []model.Task) error) error
+	// ExistsTaskでユーザーが作成したタスクの中に、タイトル・期限日・プロジェクト・親のタスクが同じタスクがあるか確認(インポートの重複の判定)
+	ExistsTask(ctx context.Context, userId uint, title string, dueDate *time.Time, projectId *uint, parentId *uint) (bool, error)
 	// GetTaskByIdは引数で渡すtaskIdに一致するタスクを取得するメソッド
-	GetTaskById(task *model.Task, userId uint, taskId uint) error
+	// アクセス権の確認はpermissionパッケージで事前に行います。
+	GetTaskById(ctx context.Context, task *model.Task, taskId uint) error
 	// CreateTaskでタスクの新規作成
-	CreateTask(task *model.Task) error
+	CreateTask(ctx context.Context, task *model.Task) error
+	// CreateTasksで複数のタスクを1つのトランザクションで新規作成(1つでも失敗した場合はどのタスクも作成しません)
+	CreateTasks(ctx context.Context, tasks []model.Task) error
 	// UpdateTaskで引数で渡すtaskIdのタスクの内容の更新
-	UpdateTask(task *model.Task, userId uint, taskId uint) error
+	UpdateTask(ctx context.Context, task *model.Task, taskId uint) error
 	// DeleteTaskで引数で渡すtaskIdのタスクのオブジェクトの削除
-	DeleteTask(userId uint, taskId uint) error
+	// タスクは論理削除(deleted_at)にして、作業時間・添付ファイル・コメントなどのタスクに紐付くデータは残します(復元すると元に戻ります)。
+	DeleteTask(ctx context.Context, taskId uint) error
+	// RestoreTaskで削除したタスクを同じIDでtaskの内容に戻す(論理削除する前に削除したタスクの場合は作り直します)
+	RestoreTask(ctx context.Context, task *model.Task) error
+	// GetDeletedTaskIdsでtaskIdsのうち、削除されたタスクのIDを取得
+	GetDeletedTaskIds(ctx context.Context, ids *[]uint, taskIds []uint) error
+	// GetSubtasksで引数で渡すparentIdのタスクのサブタスクを、一覧の順番で取得
+	GetSubtasks(ctx context.Context, tasks *[]model.Task, parentId uint) error
+	// ExistsOccurrenceで繰り返しタスクの指定した回が既に生成されているか確認
+	ExistsOccurrence(ctx context.Context, seriesId uint, recurrenceId time.Time) (bool, error)
+	// SetTaskSeriesでタスクが属するシリーズと発生日時を付け替える
+	SetTaskSeries(ctx context.Context, taskId uint, seriesId *uint, recurrenceId *time.Time) error
+	// MoveFutureOccurrencesでシリーズの中のfrom以降の回(excludeTaskIdを除く)を別のシリーズに移し、タイトルを更新
+	// movedには移す前の状態のタスクが書き込まれます。
+	MoveFutureOccurrences(ctx context.Context, moved *[]model.Task, seriesId uint, from time.Time, excludeTaskId uint, newSeriesId *uint, title string) error
+	// GetLastPositionでユーザーのタスクの中で一番最後の順位を取得(タスクが無い場合は空文字)
+	// 同時に作成したタスクが同じ順位にならないように、ユーザーの順位をトランザクションが終わるまでロックします。
+	// 必ずトランザクションの中で呼び出して、同じトランザクションでタスクを作成してください。
+	// 順位はタスクの作成者ごとの並び順なので、共有されたタスクを並び替える場合も作成者のuserIdを渡します。
+	GetLastPosition(ctx context.Context, userId uint) (string, error)
+	// GetAdjacentPositionでpositionの直後(nextがfalseの場合は直前)に並んでいるタスクの順位を取得
+	GetAdjacentPosition(ctx context.Context, userId uint, excludeTaskId uint, position string, next bool) (string, error)
+	// UpdateAssigneeでタスクの担当者だけを更新(assigneeIdがnilの場合は担当者を外す)
+	UpdateAssignee(ctx context.Context, taskId uint, assigneeId *uint) error
+	// LockPositionsでユーザーのタスクの順位をトランザクションが終わるまでロックする
+	// 前後のタスクの順位から新しい順位を求める場合は、同じトランザクションで先に呼び出してください。
+	LockPositions(ctx context.Context, userId uint) error
+	// UpdatePositionでタスクの順位だけを更新
+	UpdatePosition(ctx context.Context, taskId uint, position string) error
+	// RebalancePositionsで順位の文字列がmaxLengthより長くなったユーザーのタスクの順位を振り直す
+	RebalancePositions(ctx context.Context, maxLength int) (int, error)
 }
 
+// positionOrderは順位の文字列をバイト順で比較するためのORDER BY句
+// データベースの照合順序に左右されないようにCOLLATE "C"を指定します。
+const positionOrder = `tasks.position COLLATE "C"`
+
 // taskRepositoryという構造体を定義
 type taskRepository struct {
 	//フィールドとしてDBを作っておきます。
@@ -40,10 +94,23 @@ func NewTaskRepository(db *gorm.DB) ITaskRepository {
 // GetAllTasksの実装
 // taskRepositoryをpointerレシーバーとして受け取る形でGetAllTasksというメソッドを定義
 // 引数と返り値の型は、interfaceの型と一緒にする必要がある
-func (tr *taskRepository) GetAllTasks(tasks *[]model.Task, userId uint) error {
-	// タスクの一覧の中でユーザーIDのフィールド(user_id)が引数で渡されたユーザーID(userId)に一致するタスクの一覧を取得
-	// Order("created_at")でタスクの作成日時が一番新しいものが末尾に来る順番でデータを取得する
-	if err := tr.db.Joins("User").Where("user_id=?", userId).Order("created_at").Find(tasks).Error; err != nil {
+func (tr *taskRepository) GetAllTasks(ctx context.Context, tasks *[]model.Task, filter model.TaskFilter, visible func(db *gorm.DB) *gorm.DB) error {
+	// タスクの一覧の中でアクセス権のスコープ(visible)に当てはまるタスクの一覧を取得
+	query, err := tr.filterTasks(conn(ctx, tr.db).Joins("User").Preload("Series").Scopes(visible), filter)
+	if err != nil {
+		return err
+	}
+	// 並び順が指定された場合は、その順番で並べてから同じ値のタスクをユーザーが並び替えた順番で並べます。
+	if filter.Sort.Key != "" && filter.Sort.Key != model.TaskSortPosition {
+		query = query.Order(taskSortOrder(filter.Sort))
+	}
+	// Order(positionOrder)でユーザーが並び替えた順番、同じ順位の場合はタスクの作成日時が一番新しいものが末尾に来る順番でデータを取得する
+	if filter.Sort.Key == model.TaskSortPosition && filter.Sort.Desc {
+		query = query.Order(positionOrder + " DESC")
+	} else {
+		query = query.Order(positionOrder)
+	}
+	if err := query.Order("tasks.created_at").Find(tasks).Error; err != nil {
 		// エラーが発生した場合はエラーを返し、
 		return err
 	}
@@ -51,56 +118,472 @@ func (tr *taskRepository) GetAllTasks(tasks *[]model.Task, userId uint) error {
 	return nil
 }
 
-// タスクの一覧の中でユーザーIDの値(user_id)が引数で受け取るユーザーID(userId)に一致するタスクの一覧を抽出
-// さらに、その中でタスクの主キーが引数で受け取ったタスクID(taskId)に一致するtaskを取得
-// そして、取得したタスクオブジェクト(task)を引数で受け取っていたポインタアドレスが指し示す先(*model.Task)のメモリー領域に書き込む
-func (tr *taskRepository) GetTaskById(task *model.Task, userId uint, taskId uint) error {
-	if err := tr.db.Joins("User").Where("user_id=?", userId).First(task, taskId).Error; err != nil {
+func (tr *taskRepository) ExportTasks(ctx context.Context, filter model.TaskFilter, visible func(db *gorm.DB) *gorm.DB, batchSize int, fn func(tasks []model.Task) error) error {
+	query, err := tr.filterTasks(conn(ctx, tr.db).Preload("Series").Scopes(visible), filter)
+	if err != nil {
 		return err
 	}
-	return nil
+	// FindInBatchesはIDの順番で、前のバッチの最後のIDより大きいタスクを取得していきます。
+	tasks := []model.Task{}
+	return query.FindInBatches(&tasks, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(tasks)
+	}).Error
+}
+
+// filterTasksはタスクの一覧の絞り込みの条件(並び順以外)をqueryに追加する
+func (tr *taskRepository) filterTasks(query *gorm.DB, filter model.TaskFilter) (*gorm.DB, error) {
+	if filter.TaskIds != nil {
+		query = query.Where("tasks.id IN ?", filter.TaskIds)
+	}
+	if filter.ProjectIds != nil {
+		query = query.Where("tasks.project_id IN ?", filter.ProjectIds)
+	}
+	if filter.ProjectId != nil {
+		query = query.Where("tasks.project_id=?", *filter.ProjectId)
+	}
+	if filter.AssigneeId != nil {
+		query = query.Where("tasks.assignee_id=?", *filter.AssigneeId)
+	}
+	for _, f := range filter.CustomFields {
+		query = query.Where(customFieldCondition(f))
+	}
+	// 検索クエリは構文木からプレースホルダーを使った条件に変換します。
+	if filter.ParsedQuery != nil && filter.ParsedQuery.Node != nil {
+		expr, err := compileTaskQuery(*filter.ParsedQuery)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(expr)
+	}
+	return query, nil
+}
+
+// customFieldConditionは独自の項目の値での絞り込みの条件を作る
+// 一致の条件はGINインデックスを使えるように、jsonbの包含(@>)で比べます。
+func customFieldCondition(f model.CustomFieldFilter) clause.Expr {
+	key := strconv.FormatUint(uint64(f.FieldId), 10)
+	switch f.Op {
+	case model.CustomFieldOpGte, model.CustomFieldOpLte:
+		op := ">="
+		if f.Op == model.CustomFieldOpLte {
+			op = "<="
+		}
+		if f.Type == model.CustomFieldDate {
+			return gorm.Expr("(tasks.custom_fields ->> ?::text)::date "+op+" ?::date", key, f.Value)
+		}
+		return gorm.Expr("(tasks.custom_fields ->> ?::text)::numeric "+op+" ?::numeric", key, f.Value)
+	}
+	switch f.Type {
+	case model.CustomFieldNumber:
+		return gorm.Expr("tasks.custom_fields @> jsonb_build_object(?::text, ?::numeric)", key, f.Value)
+	case model.CustomFieldMultiSelect:
+		// multi_selectは値の配列にその選択肢が含まれているタスクに絞り込みます。
+		return gorm.Expr("tasks.custom_fields @> jsonb_build_object(?::text, jsonb_build_array(?::text))", key, f.Value)
+	}
+	return gorm.Expr("tasks.custom_fields @> jsonb_build_object(?::text, ?::text)", key, f.Value)
 }
 
-func (tr *taskRepository) CreateTask(task *model.Task) error {
-	// tr.db.Createでtaskのポインタを引数で渡す
-	if err := tr.db.Create(task).Error; err != nil {
+// taskSortOrderは並び順のORDER BY句を作る(値が無いタスクは昇順・降順のどちらでも末尾に並べます)
+// 後に続くOrderとまとめられるように文字列で返すので、項目のIDは数値を文字列にしてから埋め込みます。
+func taskSortOrder(sort model.TaskSort) string {
+	direction := "ASC"
+	if sort.Desc {
+		direction = "DESC"
+	}
+	column := "tasks.created_at"
+	switch sort.Key {
+	case model.TaskSortDueDate:
+		column = "tasks.due_date"
+	case model.TaskSortTitle:
+		column = "tasks.title"
+	case model.TaskSortCustomField:
+		value := fmt.Sprintf("(tasks.custom_fields ->> '%d')", sort.FieldId)
+		switch sort.FieldType {
+		case model.CustomFieldNumber:
+			column = value + "::numeric"
+		case model.CustomFieldDate:
+			column = value + "::date"
+		default:
+			column = value
+		}
+	}
+	return column + " " + direction + " NULLS LAST"
+}
+
+// タスクの主キーが引数で受け取ったタスクID(taskId)に一致するtaskを取得
+// そして、取得したタスクオブジェクト(task)を引数で受け取っていたポインタアドレスが指し示す先(*model.Task)のメモリー領域に書き込む
+func (tr *taskRepository) GetTaskById(ctx context.Context, task *model.Task, taskId uint) error {
+	if err := conn(ctx, tr.db).Joins("User").Preload("Series").First(task, taskId).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (tr *taskRepository) UpdateTask(task *model.Task, userId uint, taskId uint) error {
-	// tr.db.Modelでtaskオブジェクトのポインターを渡す
+func (tr *taskRepository) CreateTask(ctx context.Context, task *model.Task) error {
+	// tx.Createでtaskのポインタを引数で渡す
+	// Webhookで配信するイベントも同じトランザクションでアウトボックスに書き込みます。
+	// 同期のために、全ての項目を作成したノードの変更として記録しておきます。
+	stampSyncClocks(nil, task)
+	return conn(ctx, tr.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		return writeTaskEvents(tx, *task, model.TaskEventCreated)
+	})
+}
+
+func (tr *taskRepository) CreateTasks(ctx context.Context, tasks []model.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	// スライスの要素のIDは、作成したタスクのIDで書き換わります。
+	for i := range tasks {
+		stampSyncClocks(nil, &tasks[i])
+	}
+	return conn(ctx, tr.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tasks).Error; err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := writeTaskEvents(tx, task, model.TaskEventCreated); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (tr *taskRepository) UpdateTask(ctx context.Context, task *model.Task, taskId uint) error {
+	// tx.Modelでtaskオブジェクトのポインターを渡す
 	// そして、Clauses(clause.Returning{})のキーワードをつけると
 	// 更新した後のタスクのオブジェクトをこのタスクのポインタが指し示す先(*model.Task)に書き込んでくれるようになります。
 	// そして、Whereでタスクの主キーであるID(id)が引数で受け取れるタスクID(taskId)に一致する
-	// かつユーザーIDが引数で受け取るユーザーID(user_id)に一致するタスクに対してUpdateの処理をかけていきます。
-	// そして、ここではtitleの値(title)を引き継いで受け取れるタスクオブジェクトのタイトルの値(task.Title)で更新するようにしています。
-	result := tr.db.Model(task).Clauses(clause.Returning{}).Where("id=? AND user_id=?", taskId, userId).Update("title", task.Title)
-	// 処理の返り値をresultという変数に代入して、result.Errorでエラーを取得
+	// タスクに対してUpdateの処理をかけていきます。
+	// そして、ここではtitle、description、completed、due_date、project_id、custom_fields、tagsの値を引数で受け取れるタスクオブジェクトの値で更新するようにしています。
+	// completedがfalseの場合も更新されるように、構造体ではなくmapでUpdatesに渡します。
+	// 未完了から完了になった時はtask.completedのイベントも書き込み、同期のために値が変わった項目を記録するので、
+	// 更新する前の値を行をロックして取得しておきます。
+	return conn(ctx, tr.db).Transaction(func(tx *gorm.DB) error {
+		before := model.Task{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "title", "description", "completed", "due_date", "project_id", "custom_fields", "tags", "sync_vector", "field_clocks").
+			Where("id=?", taskId).Take(&before).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrObjectNotExist
+			}
+			return err
+		}
+		stampSyncClocks(&before, task)
+		result := tx.Model(task).Clauses(clause.Returning{}).Where("id=?", taskId).
+			Updates(map[string]interface{}{
+				"title":       task.Title,
+				"description": task.Description,
+				"completed":   task.Completed,
+				"due_date":    task.DueDate,
+				"project_id":  task.ProjectId,
+				// 独自の項目の値はjsonbに変換できるCustomFieldValues型のまま渡します。
+				"custom_fields": task.CustomFields,
+				"tags":          task.Tags,
+				"sync_vector":   task.SyncVector,
+				"field_clocks":  task.FieldClocks,
+			})
+		// 処理の返り値をresultという変数に代入して、result.Errorでエラーを取得
+		if result.Error != nil {
+			// エラーが発生した場合は、エラーをリターンで返す
+			return result.Error
+		}
+		// 実際に更新されたレコードの数を取得することができ、
+		// その数が1より小さい0の場合は更新が行なわれなかったことを意味してる
+		if result.RowsAffected < 1 {
+			// その場合はfmt.Errorfでobject does not existとエラーメッセージを付けてエラーをリターンで返す
+			return ErrObjectNotExist
+		}
+		events := []string{model.TaskEventUpdated}
+		if !before.Completed && task.Completed {
+			events = append(events, model.TaskEventCompleted)
+		}
+		return writeTaskEvents(tx, *task, events...)
+	})
+}
+
+func (tr *taskRepository) DeleteTask(ctx context.Context, taskId uint) error {
+	return conn(ctx, tr.db).Transaction(func(tx *gorm.DB) error {
+		// tx.Whereで引数で渡されたタスクID(taskId)に一致するタスクを論理削除(deleted_atを設定)
+		// Clauses(clause.Returning{})で削除したタスクを取得して、task.deletedのイベントの内容にします。
+		deleted := model.Task{}
+		result := tx.Clauses(clause.Returning{}).Where("id=?", taskId).Delete(&deleted)
+		if result.Error != nil {
+			// エラーが発生した場合は、エラーをリターンで返す
+			return result.Error
+		}
+		// RowsAffectedが0の場合もエラーを返す
+		if result.RowsAffected < 1 {
+			return ErrObjectNotExist
+		}
+		// サブタスクは外部キー制約のON DELETE SET NULLと同じように、親の無いタスクにします。
+		if err := tx.Model(&model.Task{}).Where("parent_id=?", taskId).Update("parent_id", nil).Error; err != nil {
+			return err
+		}
+		return writeTaskEvents(tx, deleted, model.TaskEventDeleted)
+	})
+}
+
+func (tr *taskRepository) RestoreTask(ctx context.Context, task *model.Task) error {
+	return conn(ctx, tr.db).Transaction(func(tx *gorm.DB) error {
+		// 論理削除したタスクの行をロックして、同期の時計を削除した時点から進めます。
+		before := model.Task{}
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "title", "description", "completed", "due_date", "project_id", "custom_fields", "tags", "sync_vector", "field_clocks").
+			Where("id=? AND deleted_at IS NOT NULL", task.ID).Take(&before).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 論理削除する前に削除したタスクは行が残っていないので、同じIDで作り直します。
+			stampSyncClocks(nil, task)
+			if err := tx.Create(task).Error; err != nil {
+				return err
+			}
+			return writeTaskEvents(tx, *task, model.TaskEventCreated)
+		}
+		if err != nil {
+			return err
+		}
+		stampSyncClocks(&before, task)
+		if err := tx.Unscoped().Model(task).Clauses(clause.Returning{}).Where("id=?", task.ID).
+			Updates(map[string]interface{}{
+				"title":           task.Title,
+				"description":     task.Description,
+				"completed":       task.Completed,
+				"due_date":        task.DueDate,
+				"project_id":      task.ProjectId,
+				"custom_fields":   task.CustomFields,
+				"tags":            task.Tags,
+				"series_id":       task.SeriesId,
+				"recurrence_id":   task.RecurrenceId,
+				"position":        task.Position,
+				"assignee_id":     nil,
+				"column_id":       nil,
+				"column_position": "",
+				"parent_id":       nil,
+				"user_id":         task.UserId,
+				"sync_vector":     task.SyncVector,
+				"field_clocks":    task.FieldClocks,
+				"deleted_at":      nil,
+			}).Error; err != nil {
+			return err
+		}
+		return writeTaskEvents(tx, *task, model.TaskEventCreated)
+	})
+}
+
+func (tr *taskRepository) GetDeletedTaskIds(ctx context.Context, ids *[]uint, taskIds []uint) error {
+	if len(taskIds) == 0 {
+		return nil
+	}
+	return conn(ctx, tr.db).Unscoped().Model(&model.Task{}).
+		Where("id IN ? AND deleted_at IS NOT NULL", taskIds).Order("id").Pluck("id", ids).Error
+}
+
+func (tr *taskRepository) ExistsTask(ctx context.Context, userId uint, title string, dueDate *time.Time, projectId *uint, parentId *uint) (bool, error) {
+	query := conn(ctx, tr.db).Model(&model.Task{}).Where("user_id=? AND title=?", userId, title)
+	if dueDate != nil {
+		query = query.Where("due_date=?", *dueDate)
+	} else {
+		query = query.Where("due_date IS NULL")
+	}
+	if projectId != nil {
+		query = query.Where("project_id=?", *projectId)
+	} else {
+		query = query.Where("project_id IS NULL")
+	}
+	if parentId != nil {
+		query = query.Where("parent_id=?", *parentId)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (tr *taskRepository) GetSubtasks(ctx context.Context, tasks *[]model.Task, parentId uint) error {
+	if err := conn(ctx, tr.db).Where("parent_id=?", parentId).Order("position").Find(tasks).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tr *taskRepository) ExistsOccurrence(ctx context.Context, seriesId uint, recurrenceId time.Time) (bool, error) {
+	var count int64
+	if err := conn(ctx, tr.db).Model(&model.Task{}).Where("series_id=? AND recurrence_id=?", seriesId, recurrenceId).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (tr *taskRepository) SetTaskSeries(ctx context.Context, taskId uint, seriesId *uint, recurrenceId *time.Time) error {
+	result := conn(ctx, tr.db).Model(&model.Task{}).Where("id=?", taskId).
+		Updates(map[string]interface{}{
+			"series_id":     seriesId,
+			"recurrence_id": recurrenceId,
+		})
 	if result.Error != nil {
-		// エラーが発生した場合は、エラーをリターンで返す
 		return result.Error
 	}
-	// 実際に更新されたレコードの数を取得することができ、
-	// その数が1より小さい0の場合は更新が行なわれなかったことを意味してる
 	if result.RowsAffected < 1 {
-		// その場合はfmt.Errorfでobject does not existとエラーメッセージを付けてエラーをリターンで返す
-		return fmt.Errorf("object does not exist")
+		return ErrObjectNotExist
 	}
 	return nil
 }
 
-func (tr *taskRepository) DeleteTask(userId uint, taskId uint) error {
-	// tr.db.Whereで引数で渡されたタスクID(taskId)とユーザーID(userId)に一致するタスクをDELETE
-	result := tr.db.Where("id=? AND user_id=?", taskId, userId).Delete(&model.Task{})
+func (tr *taskRepository) MoveFutureOccurrences(ctx context.Context, moved *[]model.Task, seriesId uint, from time.Time, excludeTaskId uint, newSeriesId *uint, title string) error {
+	return conn(ctx, tr.db).Transaction(func(tx *gorm.DB) error {
+		// 変更履歴を残せるように、移す前の状態をロックして取得しておきます。
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("series_id=? AND recurrence_id>=? AND id<>?", seriesId, from, excludeTaskId).
+			Find(moved).Error; err != nil {
+			return err
+		}
+		// 未来の回が1件も無いこともあるので、RowsAffectedはチェックしない
+		if len(*moved) == 0 {
+			return nil
+		}
+		ids := []uint{}
+		for _, t := range *moved {
+			ids = append(ids, t.ID)
+		}
+		values := map[string]interface{}{"series_id": newSeriesId, "title": title}
+		if newSeriesId == nil {
+			// 繰り返しをやめる場合は発生日時もクリアする
+			values["recurrence_id"] = nil
+		}
+		if err := tx.Model(&model.Task{}).Where("id IN ?", ids).Updates(values).Error; err != nil {
+			return err
+		}
+		// タイトルが変わった回は、同期のためにタイトルの変更を記録します。
+		for _, t := range *moved {
+			if t.Title == title {
+				continue
+			}
+			after := t
+			after.Title = title
+			stampSyncClocks(&t, &after)
+			if err := tx.Model(&model.Task{}).Where("id=?", t.ID).
+				Updates(map[string]interface{}{"sync_vector": after.SyncVector, "field_clocks": after.FieldClocks}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// positionLockClassはユーザーのタスクの順位のアドバイザリーロックのキーの1つ目の値
+// 2つ目の値にユーザーのIDを使います。
+const positionLockClass = 1
+
+// lockPositionsはuserIdのユーザーのタスクの順位を、txのトランザクションが終わるまでロックする
+// 新しい行の追加は行ロックでは防げないので、アドバイザリーロックを使います。
+func lockPositions(tx *gorm.DB, userId uint) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", positionLockClass, int32(userId)).Error
+}
+
+func (tr *taskRepository) LockPositions(ctx context.Context, userId uint) error {
+	return lockPositions(conn(ctx, tr.db), userId)
+}
+
+func (tr *taskRepository) GetLastPosition(ctx context.Context, userId uint) (string, error) {
+	db := conn(ctx, tr.db)
+	if err := lockPositions(db, userId); err != nil {
+		return "", err
+	}
+	tasks := []model.Task{}
+	if err := db.Where("user_id=?", userId).Order(positionOrder + " DESC").Limit(1).Find(&tasks).Error; err != nil {
+		return "", err
+	}
+	if len(tasks) == 0 {
+		return "", nil
+	}
+	return tasks[0].Position, nil
+}
+
+func (tr *taskRepository) GetAdjacentPosition(ctx context.Context, userId uint, excludeTaskId uint, position string, next bool) (string, error) {
+	query := conn(ctx, tr.db).Where("user_id=? AND id<>?", userId, excludeTaskId)
+	if next {
+		query = query.Where(positionOrder+" > ?", position).Order(positionOrder)
+	} else {
+		query = query.Where(positionOrder+" < ?", position).Order(positionOrder + " DESC")
+	}
+	tasks := []model.Task{}
+	if err := query.Limit(1).Find(&tasks).Error; err != nil {
+		return "", err
+	}
+	if len(tasks) == 0 {
+		return "", nil
+	}
+	return tasks[0].Position, nil
+}
+
+func (tr *taskRepository) UpdateAssignee(ctx context.Context, taskId uint, assigneeId *uint) error {
+	// 担当者の変更もタスクの更新として、task.updatedのイベントを書き込みます。
+	return conn(ctx, tr.db).Transaction(func(tx *gorm.DB) error {
+		task := model.Task{}
+		result := tx.Model(&task).Clauses(clause.Returning{}).Where("id=?", taskId).Update("assignee_id", assigneeId)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return ErrObjectNotExist
+		}
+		return writeTaskEvents(tx, task, model.TaskEventUpdated)
+	})
+}
+
+func (tr *taskRepository) UpdatePosition(ctx context.Context, taskId uint, position string) error {
+	result := conn(ctx, tr.db).Model(&model.Task{}).Where("id=?", taskId).Update("position", position)
 	if result.Error != nil {
-		// エラーが発生した場合は、エラーをリターンで返す
 		return result.Error
 	}
-	// RowsAffectedが0の場合もエラーを返す
 	if result.RowsAffected < 1 {
-		return fmt.Errorf("object does not exist")
+		return ErrObjectNotExist
 	}
 	return nil
 }
+
+func (tr *taskRepository) RebalancePositions(ctx context.Context, maxLength int) (int, error) {
+	// 順位が長くなりすぎたタスク、順位が未設定のタスク、順位が重複しているタスクを持つユーザーが対象
+	userIds := []uint{}
+	if err := conn(ctx, tr.db).Model(&model.Task{}).
+		Where("length(position)>? OR position=''", maxLength).
+		Or("(user_id, position) IN (?)", conn(ctx, tr.db).Model(&model.Task{}).Select("user_id, position").Group("user_id, position").Having("count(*)>1")).
+		Distinct().Pluck("user_id", &userIds).Error; err != nil {
+		return 0, err
+	}
+	for _, userId := range userIds {
+		err := conn(ctx, tr.db).Transaction(func(tx *gorm.DB) error {
+			// タスクの作成と同時に実行されても順位が重ならないように、ユーザーの順位のロックも取得します。
+			if err := lockPositions(tx, userId); err != nil {
+				return err
+			}
+			tasks := []model.Task{}
+			// 並び替えと同時に実行されても順番が崩れないように、対象のタスクをロックしてから振り直す
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id=?", userId).
+				Order(positionOrder).Order("created_at").Find(&tasks).Error; err != nil {
+				return err
+			}
+			for i, position := range rank.Spread(len(tasks)) {
+				if tasks[i].Position == position {
+					continue
+				}
+				// 振り直しはタスクの内容の変更ではないので、UpdateColumnでupdated_atは更新しない
+				if err := tx.Model(&model.Task{}).Where("id=?", tasks[i].ID).UpdateColumn("position", position).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(userIds), nil
+}
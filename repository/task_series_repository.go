@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ITaskSeriesRepositoryのメソッドは、ユースケースのトランザクションの中で実行できるように第1引数でctxを受け取ります。
+type ITaskSeriesRepository interface {
+	// GetSeriesByIdで引数で渡すseriesIdのシリーズを取得
+	GetSeriesById(ctx context.Context, series *model.TaskSeries, userId uint, seriesId uint) error
+	// CreateSeriesで繰り返しタスクのシリーズを新規作成
+	CreateSeries(ctx context.Context, series *model.TaskSeries) error
+	// UpdateSeriesでシリーズのタイトル、RRule、タイムゾーン、開始日時を更新
+	UpdateSeries(ctx context.Context, series *model.TaskSeries, userId uint, seriesId uint) error
+}
+
+type taskSeriesRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskSeriesRepository(db *gorm.DB) ITaskSeriesRepository {
+	return &taskSeriesRepository{db}
+}
+
+func (sr *taskSeriesRepository) GetSeriesById(ctx context.Context, series *model.TaskSeries, userId uint, seriesId uint) error {
+	if err := conn(ctx, sr.db).Where("id=? AND user_id=?", seriesId, userId).First(series).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (sr *taskSeriesRepository) CreateSeries(ctx context.Context, series *model.TaskSeries) error {
+	if err := conn(ctx, sr.db).Create(series).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (sr *taskSeriesRepository) UpdateSeries(ctx context.Context, series *model.TaskSeries, userId uint, seriesId uint) error {
+	result := conn(ctx, sr.db).Model(series).Clauses(clause.Returning{}).Where("id=? AND user_id=?", seriesId, userId).
+		Updates(map[string]interface{}{
+			"title":    series.Title,
+			"rrule":    series.RRule,
+			"timezone": series.Timezone,
+			"dtstart":  series.DTStart,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
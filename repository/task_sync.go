@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"go-rest-api/model"
+	"reflect"
+	"time"
+)
+
+// stampSyncClocksはタスクの変更をバージョンベクターと項目ごとの時計(FieldClocks)に記録する
+// beforeは変更する前のタスク(作成の場合はnil)で、afterのSyncVectorとFieldClocksを書き換えます。
+// 値が変わった項目だけを記録して、どの項目も変わっていない場合はベクターも進めません。
+// afterにSyncOriginが無い場合は、SyncServerNodeの今の日時の変更として記録します。
+func stampSyncClocks(before *model.Task, after *model.Task) {
+	origin := model.SyncOrigin{Node: model.SyncServerNode, At: time.Now()}
+	if after.SyncOrigin != nil {
+		origin = *after.SyncOrigin
+	}
+	vector := model.VersionVector{}
+	clocks := model.FieldClocks{}
+	if before != nil {
+		for k, v := range before.SyncVector {
+			vector[k] = v
+		}
+		for k, v := range before.FieldClocks {
+			clocks[k] = v
+		}
+	}
+	changed := []string{}
+	for _, field := range model.SyncFields {
+		if before == nil || !syncFieldEqual(field, before, after) {
+			changed = append(changed, field)
+		}
+	}
+	if len(changed) > 0 {
+		vector[origin.Node]++
+		for _, field := range changed {
+			clocks[field] = model.FieldClock{Node: origin.Node, Counter: vector[origin.Node], At: origin.At}
+		}
+	}
+	after.SyncVector = vector
+	after.FieldClocks = clocks
+}
+
+// syncFieldEqualはタスクの同期する項目の値が同じかを確認する
+func syncFieldEqual(field string, a *model.Task, b *model.Task) bool {
+	switch field {
+	case model.SyncFieldTitle:
+		return a.Title == b.Title
+	case model.SyncFieldDescription:
+		return a.Description == b.Description
+	case model.SyncFieldCompleted:
+		return a.Completed == b.Completed
+	case model.SyncFieldDueDate:
+		if a.DueDate == nil || b.DueDate == nil {
+			return a.DueDate == nil && b.DueDate == nil
+		}
+		return a.DueDate.Equal(*b.DueDate)
+	case model.SyncFieldProjectId:
+		if a.ProjectId == nil || b.ProjectId == nil {
+			return a.ProjectId == nil && b.ProjectId == nil
+		}
+		return *a.ProjectId == *b.ProjectId
+	case model.SyncFieldCustomFields:
+		// 空の値とnilは同じとして扱います。
+		if len(a.CustomFields) == 0 || len(b.CustomFields) == 0 {
+			return len(a.CustomFields) == len(b.CustomFields)
+		}
+		return reflect.DeepEqual(a.CustomFields, b.CustomFields)
+	case model.SyncFieldTags:
+		if len(a.Tags) == 0 || len(b.Tags) == 0 {
+			return len(a.Tags) == len(b.Tags)
+		}
+		return reflect.DeepEqual(a.Tags, b.Tags)
+	}
+	return true
+}
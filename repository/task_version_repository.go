@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ITaskVersionRepository interface {
+	// GetVersionsでタスクの変更履歴を新しい順に取得
+	GetVersions(ctx context.Context, versions *[]model.TaskVersion, taskId uint) error
+	// GetVersionで指定したバージョンの履歴を取得
+	GetVersion(ctx context.Context, version *model.TaskVersion, taskId uint, number int) error
+	// CreateVersionで次のバージョン番号を振って履歴を作成
+	// タスクの変更と同じトランザクションの中で呼び出してください。
+	CreateVersion(ctx context.Context, version *model.TaskVersion) error
+	// GetLatestVersionIdで最新の履歴のIDを取得(履歴が無い場合は0)
+	GetLatestVersionId(ctx context.Context, latest *uint) error
+	// GetChangedTaskIdsでIDがsinceより大きくuntil以下の履歴があるタスクのIDを、filterのスナップショットの条件で取得
+	GetChangedTaskIds(ctx context.Context, taskIds *[]uint, since uint, until uint, filter model.TaskVersionFilter) error
+	// GetCreatedTaskIdsでcandidatesのタスクのうち、IDがsinceより大きくuntil以下の作成の履歴があるタスクのIDを取得
+	GetCreatedTaskIds(ctx context.Context, taskIds *[]uint, since uint, until uint, candidates []uint) error
+}
+
+type taskVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskVersionRepository(db *gorm.DB) ITaskVersionRepository {
+	return &taskVersionRepository{db}
+}
+
+func (vr *taskVersionRepository) GetVersions(ctx context.Context, versions *[]model.TaskVersion, taskId uint) error {
+	if err := conn(ctx, vr.db).Where("task_id=?", taskId).Order("version DESC").Find(versions).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vr *taskVersionRepository) GetVersion(ctx context.Context, version *model.TaskVersion, taskId uint, number int) error {
+	if err := conn(ctx, vr.db).Where("task_id=? AND version=?", taskId, number).First(version).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vr *taskVersionRepository) CreateVersion(ctx context.Context, version *model.TaskVersion) error {
+	// バージョン番号はタスクごとの連番にする
+	// 同時に書き込まれた番号が重ならないように、タスクの行をFOR UPDATEでロックしてから最新の番号を求めます。
+	// (タスクの変更と同じトランザクションの場合は既にロックしています。削除した後はDELETEのロックが残っています。)
+	return conn(ctx, vr.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			Where("id=?", version.TaskId).Find(&[]model.Task{}).Error; err != nil {
+			return err
+		}
+		var latest int
+		if err := tx.Model(&model.TaskVersion{}).Where("task_id=?", version.TaskId).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		version.Version = latest + 1
+		return tx.Create(version).Error
+	})
+}
+
+func (vr *taskVersionRepository) GetLatestVersionId(ctx context.Context, latest *uint) error {
+	return conn(ctx, vr.db).Model(&model.TaskVersion{}).Select("COALESCE(MAX(id), 0)").Scan(latest).Error
+}
+
+func (vr *taskVersionRepository) GetChangedTaskIds(ctx context.Context, taskIds *[]uint, since uint, until uint, filter model.TaskVersionFilter) error {
+	query := conn(ctx, vr.db).Model(&model.TaskVersion{}).Where("id>? AND id<=?", since, until)
+	if filter.OrganizationId == nil {
+		query = query.Where("snapshot->>'organization_id' IS NULL")
+	} else {
+		query = query.Where("(snapshot->>'organization_id')::bigint=?", *filter.OrganizationId)
+	}
+	if filter.UserId != nil {
+		query = query.Where("(snapshot->>'user_id')::bigint=?", *filter.UserId)
+	}
+	if filter.ProjectId != nil {
+		// プロジェクトから別のプロジェクトに移動したタスクも、移動前のプロジェクトから消えたことが分かるように含めます。
+		query = query.Where("(snapshot->>'project_id')::bigint=? OR (changes->'project_id'->>'old')::bigint=?", *filter.ProjectId, *filter.ProjectId)
+	}
+	return query.Distinct().Pluck("task_id", taskIds).Error
+}
+
+func (vr *taskVersionRepository) GetCreatedTaskIds(ctx context.Context, taskIds *[]uint, since uint, until uint, candidates []uint) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	return conn(ctx, vr.db).Model(&model.TaskVersion{}).
+		Where("id>? AND id<=? AND action=? AND task_id IN ?", since, until, model.TaskActionCreate, candidates).
+		Distinct().Pluck("task_id", taskIds).Error
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// templatesテーブルへのクエリはtenantパッケージがctxのテナントで絞り込みます。
+// テンプレートは作成したユーザーだけのものなので、全ての操作でuserIdも条件に含めます。
+type ITemplateRepository interface {
+	GetTemplates(ctx context.Context, templates *[]model.Template, userId uint) error
+	GetTemplateById(ctx context.Context, template *model.Template, userId uint, templateId uint) error
+	CreateTemplate(ctx context.Context, template *model.Template) error
+	UpdateTemplate(ctx context.Context, template *model.Template, userId uint, templateId uint) error
+	DeleteTemplate(ctx context.Context, userId uint, templateId uint) error
+}
+
+type templateRepository struct {
+	db *gorm.DB
+}
+
+func NewTemplateRepository(db *gorm.DB) ITemplateRepository {
+	return &templateRepository{db}
+}
+
+func (tmr *templateRepository) GetTemplates(ctx context.Context, templates *[]model.Template, userId uint) error {
+	if err := tmr.db.WithContext(ctx).Where("user_id=?", userId).Order("created_at").Find(templates).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tmr *templateRepository) GetTemplateById(ctx context.Context, template *model.Template, userId uint, templateId uint) error {
+	if err := tmr.db.WithContext(ctx).Where("user_id=?", userId).First(template, templateId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tmr *templateRepository) CreateTemplate(ctx context.Context, template *model.Template) error {
+	if err := tmr.db.WithContext(ctx).Omit("Project").Create(template).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tmr *templateRepository) UpdateTemplate(ctx context.Context, template *model.Template, userId uint, templateId uint) error {
+	// Itemsをjsonbに変換するために、mapではなく構造体で更新するカラムを指定します。
+	result := tmr.db.WithContext(ctx).Model(template).Clauses(clause.Returning{}).Where("id=? AND user_id=?", templateId, userId).
+		Select("name", "items", "project_id").Updates(template)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (tmr *templateRepository) DeleteTemplate(ctx context.Context, userId uint, templateId uint) error {
+	result := tmr.db.WithContext(ctx).Where("id=? AND user_id=?", templateId, userId).Delete(&model.Template{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ITimeEntryRepository interface {
+	// GetTimeEntriesByTaskでタスクの作業時間の一覧を開始時刻の順に取得
+	GetTimeEntriesByTask(ctx context.Context, entries *[]model.TimeEntry, taskId uint) error
+	// GetTimeEntryByIdで引数で渡すentryIdの、ユーザー本人の作業時間を取得
+	GetTimeEntryById(ctx context.Context, entry *model.TimeEntry, userId uint, taskId uint, entryId uint) error
+	// GetRunningTimeEntryでユーザーの動いているタイマー(終了時刻が無い作業時間)を取得
+	GetRunningTimeEntry(ctx context.Context, entry *model.TimeEntry, userId uint) error
+	// CreateTimeEntryで作業時間の新規作成(タイマーの開始も含む)
+	CreateTimeEntry(ctx context.Context, entry *model.TimeEntry) error
+	// StopTimeEntryでタスクに対して動いているユーザーのタイマーを、endedAtの時刻で停止
+	StopTimeEntry(ctx context.Context, entry *model.TimeEntry, userId uint, taskId uint, endedAt time.Time) error
+	// UpdateTimeEntryで作業時間の説明・タグ・開始時刻・終了時刻を更新
+	UpdateTimeEntry(ctx context.Context, entry *model.TimeEntry, userId uint, taskId uint, entryId uint) error
+	// DeleteTimeEntryで引数で渡すentryIdの作業時間の削除
+	DeleteTimeEntry(ctx context.Context, userId uint, taskId uint, entryId uint) error
+	// GetTimeReportでユーザーの作業時間をfilterの単位ごとに集計
+	GetTimeReport(ctx context.Context, rows *[]model.TimeReportRow, userId uint, filter model.TimeReportFilter) error
+}
+
+type timeEntryRepository struct {
+	db *gorm.DB
+}
+
+func NewTimeEntryRepository(db *gorm.DB) ITimeEntryRepository {
+	return &timeEntryRepository{db}
+}
+
+func (ter *timeEntryRepository) GetTimeEntriesByTask(ctx context.Context, entries *[]model.TimeEntry, taskId uint) error {
+	if err := ter.db.WithContext(ctx).Where("task_id=?", taskId).Order("started_at").Order("id").Find(entries).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ter *timeEntryRepository) GetTimeEntryById(ctx context.Context, entry *model.TimeEntry, userId uint, taskId uint, entryId uint) error {
+	if err := ter.db.WithContext(ctx).Where("id=? AND user_id=? AND task_id=?", entryId, userId, taskId).First(entry).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ter *timeEntryRepository) GetRunningTimeEntry(ctx context.Context, entry *model.TimeEntry, userId uint) error {
+	if err := ter.db.WithContext(ctx).Where("user_id=? AND ended_at IS NULL", userId).First(entry).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ter *timeEntryRepository) CreateTimeEntry(ctx context.Context, entry *model.TimeEntry) error {
+	if err := ter.db.WithContext(ctx).Omit("Task").Create(entry).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ter *timeEntryRepository) StopTimeEntry(ctx context.Context, entry *model.TimeEntry, userId uint, taskId uint, endedAt time.Time) error {
+	result := ter.db.WithContext(ctx).Model(entry).Clauses(clause.Returning{}).
+		Where("user_id=? AND task_id=? AND ended_at IS NULL", userId, taskId).Update("ended_at", endedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (ter *timeEntryRepository) UpdateTimeEntry(ctx context.Context, entry *model.TimeEntry, userId uint, taskId uint, entryId uint) error {
+	// Tagsをjsonbに変換するために、mapではなく構造体で更新するカラムを指定します。
+	result := ter.db.WithContext(ctx).Model(entry).Clauses(clause.Returning{}).
+		Where("id=? AND user_id=? AND task_id=?", entryId, userId, taskId).
+		Select("description", "tags", "started_at", "ended_at").Updates(entry)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (ter *timeEntryRepository) DeleteTimeEntry(ctx context.Context, userId uint, taskId uint, entryId uint) error {
+	result := ter.db.WithContext(ctx).Where("id=? AND user_id=? AND task_id=?", entryId, userId, taskId).Delete(&model.TimeEntry{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (ter *timeEntryRepository) GetTimeReport(ctx context.Context, rows *[]model.TimeReportRow, userId uint, filter model.TimeReportFilter) error {
+	// 動いているタイマーは現在までの時間で集計します。
+	const seconds = "COALESCE(SUM(EXTRACT(EPOCH FROM COALESCE(time_entries.ended_at, now()) - time_entries.started_at)), 0)::bigint AS seconds, COUNT(*) AS entries"
+	query := ter.db.WithContext(ctx).Model(&model.TimeEntry{}).
+		Where("time_entries.user_id=? AND time_entries.started_at>=? AND time_entries.started_at<?", userId, filter.From, filter.To)
+	switch filter.GroupBy {
+	case model.TimeGroupByDay:
+		query = query.Select("to_char(time_entries.started_at AT TIME ZONE ?, 'YYYY-MM-DD') AS key, "+
+			"to_char(time_entries.started_at AT TIME ZONE ?, 'YYYY-MM-DD') AS label, "+seconds, filter.Timezone, filter.Timezone).
+			Group("key, label").Order("key")
+	case model.TimeGroupByTask:
+		query = query.Joins("JOIN tasks ON tasks.id = time_entries.task_id").
+			Select("tasks.id::text AS key, tasks.title AS label, " + seconds).
+			Group("tasks.id, tasks.title").Order("seconds DESC").Order("key")
+	case model.TimeGroupByProject:
+		// プロジェクトに属さないタスクの作業時間は、keyとlabelが空の行にまとめます。
+		query = query.Joins("JOIN tasks ON tasks.id = time_entries.task_id").
+			Joins("LEFT JOIN projects ON projects.id = tasks.project_id").
+			Select("COALESCE(projects.id::text, '') AS key, COALESCE(projects.name, '') AS label, " + seconds).
+			Group("projects.id, projects.name").Order("seconds DESC").Order("key")
+	case model.TimeGroupByTag:
+		// 複数のタグが付いた作業時間はタグごとに数え、タグが無い作業時間はkeyとlabelが空の行にまとめます。
+		query = query.Joins("LEFT JOIN LATERAL jsonb_array_elements_text(time_entries.tags) AS tag(name) ON true").
+			Select("COALESCE(tag.name, '') AS key, COALESCE(tag.name, '') AS label, " + seconds).
+			Group("tag.name").Order("seconds DESC").Order("key")
+	default:
+		return fmt.Errorf("unknown group_by: %s", filter.GroupBy)
+	}
+	if err := query.Scan(rows).Error; err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// ITransactionはユースケースの中で、複数のリポジトリの呼び出しを1つのトランザクションにまとめる
+// Doに渡したfnが受け取るctxをリポジトリのメソッドに渡すと、そのトランザクションの中で実行されます。
+type ITransaction interface {
+	// Doはfnをトランザクションの中で実行して、fnがエラーを返した場合はロールバックする
+	// 既にトランザクションの中の場合は、セーブポイントを作ってその中で実行します。
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transaction struct {
+	db *gorm.DB
+}
+
+func NewTransaction(db *gorm.DB) ITransaction {
+	return &transaction{db}
+}
+
+type txKey struct{}
+
+// txStateはctxに設定する実行中のトランザクション
+type txState struct {
+	tx *gorm.DB
+	// afterCommitはコミットした後に実行する処理(ロールバックした場合は実行しません)
+	afterCommit []func()
+}
+
+func (t *transaction) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, nested := ctx.Value(txKey{}).(*txState)
+	state := &txState{}
+	run := func(tx *gorm.DB) error {
+		state.tx = tx
+		return fn(context.WithValue(ctx, txKey{}, state))
+	}
+	if nested {
+		// セーブポイントまでロールバックした場合に、その中で登録した処理が実行されないように、成功した時だけ親に引き継ぎます。
+		if err := parent.tx.Transaction(run); err != nil {
+			return err
+		}
+		parent.afterCommit = append(parent.afterCommit, state.afterCommit...)
+		return nil
+	}
+	if err := t.db.WithContext(ctx).Transaction(run); err != nil {
+		return err
+	}
+	for _, f := range state.afterCommit {
+		f()
+	}
+	return nil
+}
+
+// AfterCommitはctxのトランザクションがコミットされた後にfを実行する
+// トランザクションの外で呼び出した場合は、すぐに実行します。
+// 変更を/eventsのクライアントに送る処理など、ロールバックした変更を外に知らせたくない処理に使います。
+func AfterCommit(ctx context.Context, f func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, f)
+		return
+	}
+	f()
+}
+
+// connはctxにトランザクションがあればそのトランザクションを、無ければdbをctx付きで返す
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// Connはconnを他のパッケージ(権限の確認など)から使うためのもので、ctxのトランザクションに参加したクエリにします。
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return conn(ctx, db)
+}
@@ -16,6 +16,10 @@ type IUserRepository interface {
 	GetUserByEmail(user *model.User, email string) error
 	// CreateUserも、ユーザーオブジェクトのポインタを引数で受け取り、返り値の型はerrorインターフェース型
 	CreateUser(user *model.User) error
+	// GetUsersByEmailsは、引数で渡したメールアドレスの一覧に一致するユーザーをまとめて取得する
+	GetUsersByEmails(users *[]model.User, emails []string) error
+	// GetUserByIdは、引数で渡したユーザーID(userId)のユーザーを取得する
+	GetUserById(user *model.User, userId uint) error
 }
 
 // userRepository構造体の定義
@@ -58,3 +62,18 @@ func (ur *userRepository) CreateUser(user *model.User) error {
 	// 成功した場合はNILを返す
 	return nil
 }
+
+func (ur *userRepository) GetUsersByEmails(users *[]model.User, emails []string) error {
+	// 登録時のメールアドレスの大文字・小文字に関係なく一致させます(emailsは小文字で渡してください)。
+	if err := ur.db.Where("LOWER(email) IN ?", emails).Find(users).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ur *userRepository) GetUserById(user *model.User, userId uint) error {
+	if err := ur.db.First(user, userId).Error; err != nil {
+		return err
+	}
+	return nil
+}
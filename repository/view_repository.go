@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"go-rest-api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// viewsテーブルへのクエリはtenantパッケージがctxのテナントで絞り込みます。
+// ビューは作成したユーザーだけのものなので、全ての操作でuserIdも条件に含めます。
+type IViewRepository interface {
+	GetViews(ctx context.Context, views *[]model.View, userId uint) error
+	GetViewById(ctx context.Context, view *model.View, userId uint, viewId uint) error
+	CreateView(ctx context.Context, view *model.View) error
+	UpdateView(ctx context.Context, view *model.View, userId uint, viewId uint) error
+	DeleteView(ctx context.Context, userId uint, viewId uint) error
+}
+
+type viewRepository struct {
+	db *gorm.DB
+}
+
+func NewViewRepository(db *gorm.DB) IViewRepository {
+	return &viewRepository{db}
+}
+
+func (vr *viewRepository) GetViews(ctx context.Context, views *[]model.View, userId uint) error {
+	if err := vr.db.WithContext(ctx).Where("user_id=?", userId).Order("created_at").Find(views).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vr *viewRepository) GetViewById(ctx context.Context, view *model.View, userId uint, viewId uint) error {
+	if err := vr.db.WithContext(ctx).Where("user_id=?", userId).First(view, viewId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vr *viewRepository) CreateView(ctx context.Context, view *model.View) error {
+	if err := vr.db.WithContext(ctx).Create(view).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vr *viewRepository) UpdateView(ctx context.Context, view *model.View, userId uint, viewId uint) error {
+	result := vr.db.WithContext(ctx).Model(view).Clauses(clause.Returning{}).Where("id=? AND user_id=?", viewId, userId).
+		Updates(map[string]interface{}{
+			"name":  view.Name,
+			"query": view.Query,
+			"sort":  view.Sort,
+			"scope": view.Scope,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (vr *viewRepository) DeleteView(ctx context.Context, userId uint, viewId uint) error {
+	result := vr.db.WithContext(ctx).Where("id=? AND user_id=?", viewId, userId).Delete(&model.View{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-rest-api/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// webhooksテーブルへのクエリはtenantパッケージがctxのテナントで絞り込みます。
+// Webhookは登録したユーザーだけのものなので、APIからの操作ではuserIdも条件に含めます。
+// アウトボックスの振り分けと配信はワーカーから全ての組織を対象に行うので、テナントで絞り込まないctxで呼び出します。
+type IWebhookRepository interface {
+	GetWebhooks(ctx context.Context, webhooks *[]model.Webhook, userId uint) error
+	GetWebhookById(ctx context.Context, webhook *model.Webhook, userId uint, webhookId uint) error
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) error
+	// UpdateWebhookでURL・イベントの種類・有効かどうか・署名の鍵を更新
+	UpdateWebhook(ctx context.Context, webhook *model.Webhook, userId uint, webhookId uint) error
+	DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error
+	// GetDeliveriesでWebhookの配信のログを新しい順に最大limit件取得
+	GetDeliveries(ctx context.Context, deliveries *[]model.WebhookDelivery, webhookId uint, limit int) error
+	GetDeliveryById(ctx context.Context, delivery *model.WebhookDelivery, webhookId uint, deliveryId uint) error
+	CreateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	// DispatchTaskEventsでアウトボックスの未処理のイベントを最大limit件確保して、イベントごとに通知の対象になりうるWebhookとrouteに渡す
+	// routeが返したWebhookに配信(WebhookDelivery)を作成して、イベントを処理済みにします。返り値は処理したイベントの件数
+	DispatchTaskEvents(ctx context.Context, now time.Time, limit int, route func(event model.TaskOutboxEvent, webhooks []model.Webhook) ([]model.Webhook, error)) (int, error)
+	// ClaimDueDeliveriesで送信時刻を過ぎた配信を最大limit件、送信中として確保してdeliveriesに書き込む
+	// 確保はすぐにコミットするので、送信はトランザクションの外で行い、結果をRecordDeliveryResultで記録します。
+	ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, deliveries *[]model.WebhookDelivery) error
+	// RecordDeliveryResultで確保した配信の送信の結果を記録
+	RecordDeliveryResult(ctx context.Context, delivery model.WebhookDelivery, now time.Time, result model.WebhookDeliveryResult) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// webhookClaimTimeoutは確保した配信の結果が記録されないまま、他のインスタンスが確保し直せるようになるまでの時間
+// 1回の確保分(10件 × 送信のタイムアウトの10秒)の送信が終わる時間より長くします。
+const webhookClaimTimeout = 15 * time.Minute
+
+func NewWebhookRepository(db *gorm.DB) IWebhookRepository {
+	return &webhookRepository{db}
+}
+
+func (wr *webhookRepository) GetWebhooks(ctx context.Context, webhooks *[]model.Webhook, userId uint) error {
+	if err := wr.db.WithContext(ctx).Where("user_id=?", userId).Order("created_at").Find(webhooks).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *webhookRepository) GetWebhookById(ctx context.Context, webhook *model.Webhook, userId uint, webhookId uint) error {
+	if err := wr.db.WithContext(ctx).Where("user_id=?", userId).First(webhook, webhookId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *webhookRepository) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	if err := wr.db.WithContext(ctx).Create(webhook).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *webhookRepository) UpdateWebhook(ctx context.Context, webhook *model.Webhook, userId uint, webhookId uint) error {
+	// activeがfalseの場合も更新されるように、Selectで更新するカラムを指定します。
+	result := wr.db.WithContext(ctx).Model(webhook).Clauses(clause.Returning{}).Where("id=? AND user_id=?", webhookId, userId).
+		Select("url", "events", "active", "secret", "updated_at").Updates(webhook)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (wr *webhookRepository) DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error {
+	result := wr.db.WithContext(ctx).Where("id=? AND user_id=?", webhookId, userId).Delete(&model.Webhook{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return ErrObjectNotExist
+	}
+	return nil
+}
+
+func (wr *webhookRepository) GetDeliveries(ctx context.Context, deliveries *[]model.WebhookDelivery, webhookId uint, limit int) error {
+	if err := wr.db.WithContext(ctx).Where("webhook_id=?", webhookId).Order("id DESC").Limit(limit).Find(deliveries).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *webhookRepository) GetDeliveryById(ctx context.Context, delivery *model.WebhookDelivery, webhookId uint, deliveryId uint) error {
+	if err := wr.db.WithContext(ctx).Where("webhook_id=?", webhookId).First(delivery, deliveryId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *webhookRepository) CreateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	if err := wr.db.WithContext(ctx).Create(delivery).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *webhookRepository) DispatchTaskEvents(ctx context.Context, now time.Time, limit int, route func(event model.TaskOutboxEvent, webhooks []model.Webhook) ([]model.Webhook, error)) (int, error) {
+	processed := 0
+	err := wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		events := []model.TaskOutboxEvent{}
+		// 複数のインスタンスでワーカーを動かしても同じイベントを二重に振り分けないように、
+		// SELECT ... FOR UPDATE SKIP LOCKEDで他のインスタンスが確保中の行は飛ばします。
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}, Options: "SKIP LOCKED"}).
+			Where("processed_at IS NULL").Order("id").Limit(limit).Find(&events).Error; err != nil {
+			return err
+		}
+		for _, e := range events {
+			// イベントのタスクと同じ組織の、イベントの種類を選んでいる有効なWebhookが対象
+			query := tx.Where("active AND events @> ?::jsonb", fmt.Sprintf("[%q]", e.Event))
+			if e.OrganizationId == nil {
+				query = query.Where("organization_id IS NULL")
+			} else {
+				query = query.Where("organization_id=?", *e.OrganizationId)
+			}
+			candidates := []model.Webhook{}
+			if err := query.Order("id").Find(&candidates).Error; err != nil {
+				return err
+			}
+			if len(candidates) > 0 {
+				webhooks, err := route(e, candidates)
+				if err != nil {
+					return err
+				}
+				if err := createDeliveries(tx, e, webhooks, now); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&model.TaskOutboxEvent{}).Where("id=?", e.ID).Update("processed_at", now).Error; err != nil {
+				return err
+			}
+			processed++
+		}
+		return nil
+	})
+	return processed, err
+}
+
+// createDeliveriesはイベントの本文を作って、Webhookごとに配信を作成する
+func createDeliveries(tx *gorm.DB, e model.TaskOutboxEvent, webhooks []model.Webhook, now time.Time) error {
+	if len(webhooks) == 0 {
+		return nil
+	}
+	task := model.TaskResponse{}
+	if err := json.Unmarshal([]byte(e.Task), &task); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(model.TaskEventPayload{ID: e.ID, Event: e.Event, OccurredAt: e.CreatedAt, Task: task})
+	if err != nil {
+		return err
+	}
+	deliveries := []model.WebhookDelivery{}
+	for _, w := range webhooks {
+		deliveries = append(deliveries, model.WebhookDelivery{
+			WebhookId:     w.ID,
+			EventId:       e.ID,
+			Event:         e.Event,
+			Payload:       string(payload),
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+		})
+	}
+	return tx.Create(&deliveries).Error
+}
+
+func (wr *webhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, deliveries *[]model.WebhookDelivery) error {
+	return wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// ClaimDueRemindersと同じく、SKIP LOCKEDで他のインスタンスが確保中の行を飛ばして、claimed_atを設定したらすぐにコミットします。
+		// 無効にしたWebhookの配信は、有効に戻すまで送信しません。
+		if err := tx.Joins("Webhook").
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}, Options: "SKIP LOCKED"}).
+			Where("webhook_deliveries.status=? AND webhook_deliveries.next_attempt_at<=? AND \"Webhook\".active", model.WebhookDeliveryPending, now).
+			Where("webhook_deliveries.claimed_at IS NULL OR webhook_deliveries.claimed_at<?", now.Add(-webhookClaimTimeout)).
+			Order("webhook_deliveries.next_attempt_at").Limit(limit).Find(deliveries).Error; err != nil {
+			return err
+		}
+		if len(*deliveries) == 0 {
+			return nil
+		}
+		claimedAt := now.Truncate(time.Microsecond)
+		ids := []uint{}
+		for i := range *deliveries {
+			d := &(*deliveries)[i]
+			d.ClaimedAt = &claimedAt
+			d.Attempts++
+			ids = append(ids, d.ID)
+		}
+		return tx.Model(&model.WebhookDelivery{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"claimed_at": claimedAt, "attempts": gorm.Expr("attempts + 1")}).Error
+	})
+}
+
+func (wr *webhookRepository) RecordDeliveryResult(ctx context.Context, delivery model.WebhookDelivery, now time.Time, result model.WebhookDeliveryResult) error {
+	values := map[string]interface{}{
+		"claimed_at":       nil,
+		"last_status_code": result.StatusCode,
+		"last_response":    result.Response,
+		"last_error":       "",
+	}
+	if result.Err != nil {
+		values["last_error"] = result.Err.Error()
+		if delivery.Attempts >= model.MaxWebhookAttempts {
+			values["status"] = model.WebhookDeliveryFailed
+			values["next_attempt_at"] = nil
+		} else {
+			// 失敗した場合は、30秒・1分・2分…と試行回数に応じて次の送信時刻を後ろにずらしてリトライ
+			values["next_attempt_at"] = now.Add(time.Duration(1<<(delivery.Attempts-1)) * 30 * time.Second)
+		}
+	} else {
+		values["status"] = model.WebhookDeliverySucceeded
+		values["next_attempt_at"] = nil
+		values["delivered_at"] = now
+	}
+	// 確保し直された配信の場合は、後から確保した方の結果を上書きしないようにします。
+	return wr.db.WithContext(ctx).Model(&model.WebhookDelivery{}).
+		Where("id=? AND claimed_at=?", delivery.ID, delivery.ClaimedAt).Updates(values).Error
+}
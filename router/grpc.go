@@ -0,0 +1,29 @@
+package router
+
+import (
+	"go-rest-api/controller"
+	"go-rest-api/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// NewGRPCServerは社内のサービス向けのgRPCのサーバーを作成する
+// TaskServiceとUserServiceの他に、ヘルスチェック(grpc.health.v1)とリフレクションのサービスを登録します。
+func NewGRPCServer(ga controller.IGRPCAuth, ts pb.TaskServiceServer, us pb.UserServiceServer) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(ga.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(ga.StreamInterceptor),
+	)
+	pb.RegisterTaskServiceServer(s, ts)
+	pb.RegisterUserServiceServer(s, us)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(pb.TaskService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(pb.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+	reflection.Register(s)
+	return s
+}
@@ -4,6 +4,7 @@ import (
 	"go-rest-api/controller"
 	"net/http"
 	"os"
+	"strings"
 
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -14,7 +15,32 @@ import (
 // 引数でユーザーコントローラー(uc)を受け取れるようにしておきます。
 // routerの中でタスクコントローラーを使用できるようにするために、
 // 引数のところにタスクコントローラーを追加しておきます。
-func NewRouter(uc controller.IUserController, tc controller.ITaskController) *echo.Echo {
+// リマインダーのエンドポイントのために、リマインダーコントローラーも受け取ります。
+// コメントと通知のエンドポイントのために、コメントコントローラーと通知コントローラーも受け取ります。
+// 添付ファイルのエンドポイントのために、添付ファイルコントローラーも受け取ります。
+// プロジェクトと共有のエンドポイントのために、プロジェクトコントローラーと共有コントローラーも受け取ります。
+// 公開リンクのエンドポイントのために、公開リンクコントローラーも受け取ります。
+// 組織のエンドポイントと、リクエストの組織(テナント)を決めるミドルウェアのために、組織コントローラーも受け取ります。
+// 作業時間のタイマーとレポートのエンドポイントのために、作業時間コントローラーも受け取ります。
+// カンバンボードのエンドポイントのために、ボードコントローラーも受け取ります。
+// プロジェクトの独自の項目のエンドポイントのために、独自の項目のコントローラーも受け取ります。
+// 保存したビューのエンドポイントのために、ビューコントローラーも受け取ります。
+// タスクのテンプレートと複製のエンドポイントのために、テンプレートコントローラーも受け取ります。
+// 他のツールからのインポートのエンドポイントのために、インポートコントローラーも受け取ります。
+// 個人用のアクセストークンとCalDAVのエンドポイントのために、アクセストークンとCalDAVのコントローラーも受け取ります。
+// 期限日のiCalendarのフィードのエンドポイントのために、フィードのコントローラーも受け取ります。
+// メールからタスクを作成する受信アドレスのエンドポイントのために、受信アドレスのコントローラーも受け取ります。
+// タスクのイベントを通知するWebhookのエンドポイントのために、Webhookのコントローラーも受け取ります。
+// タスクの変更をリアルタイムで送るエンドポイントのために、イベントのコントローラーも受け取ります。
+// オフラインで使えるクライアントの差分の同期のエンドポイントのために、同期のコントローラーも受け取ります。
+func NewRouter(uc controller.IUserController, tc controller.ITaskController, rc controller.IReminderController,
+	cc controller.ICommentController, nc controller.INotificationController, ac controller.IAttachmentController,
+	pc controller.IProjectController, sc controller.IShareController, lc controller.IShareLinkController,
+	oc controller.IOrganizationController, tec controller.ITimeEntryController, bc controller.IBoardController,
+	cfc controller.ICustomFieldController, vc controller.IViewController, tmc controller.ITemplateController,
+	ic controller.IImportController, ptc controller.IPersonalTokenController, cdc controller.ICalDAVController,
+	fc controller.ICalendarFeedController, mic controller.IMailInboxController, wc controller.IWebhookController,
+	ec controller.IEventController, syc controller.ISyncController, gqc controller.IGraphQLController) *echo.Echo {
 	// echo.Newでエコーのインスタンスを作成
 	e := echo.New()
 	// e.Useで、CORSのmiddlewareを追加しまして、新ORIGINSのところにアクセスをですね。
@@ -29,8 +55,8 @@ func NewRouter(uc controller.IUserController, tc controller.ITaskController) *ec
 		// クッキーの送受信を可能にするために、AllowCredentialsをtrueに設定
 		AllowOrigins: []string{"http://localhost:3000", os.Getenv("FE_URL")},
 		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept,
-			echo.HeaderAccessControlAllowHeaders, echo.HeaderXCSRFToken},
-		AllowMethods:     []string{"GET", "PUT", "POST", "DELETE"},
+			echo.HeaderAccessControlAllowHeaders, echo.HeaderXCSRFToken, "X-Share-Password", "X-Organization-Id"},
+		AllowMethods:     []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", echo.PROPFIND, echo.REPORT},
 		AllowCredentials: true,
 	}))
 	// e.Useで、CSRFのmiddlewareを設定しています。
@@ -44,6 +70,11 @@ func NewRouter(uc controller.IUserController, tc controller.ITaskController) *ec
 		CookieDomain:   os.Getenv("API_DOMAIN"),
 		CookieHTTPOnly: true,
 		CookieSameSite: http.SameSiteNoneMode,
+		// CalDAVのクライアントはクッキーを使わずにBasic認証かアクセストークンで認証するので、CSRFの確認をしません。
+		Skipper: func(c echo.Context) bool {
+			path := c.Request().URL.Path
+			return strings.HasPrefix(path, "/caldav") || strings.HasPrefix(path, "/.well-known/caldav")
+		},
 		//CookieSameSite: http.SameSiteDefaultMode,
 		//CookieMaxAge:   60,
 	}))
@@ -60,31 +91,263 @@ func NewRouter(uc controller.IUserController, tc controller.ITaskController) *ec
 	// e.GETでCSRFのエンドポイント(/csrf)にリクエストがあった際は
 	//ユーザーコントローラー(uc)のCsrfTokenのメソッドを呼び出すようにしておきます。
 	e.GET("/csrf", uc.CsrfToken)
-	// ECHOインスタンスのeに対して新しくグループを作っていきます。
-	//タスク関係のエンドポイントをグループ化して、こちらをtという変数に格納しておきます。
-	t := e.Group("/tasks")
-	// タスクのグループに対して、JWTのミドルウェアを適用するようにしておきます。
+	// 公開リンクはログインしていない人も開けるように、JWTのミドルウェアを適用しない/tasksの外側に置きます。
+	e.GET("/shared/:token", lc.GetSharedTask)
+	// 期限日のフィード(/feeds/<トークン>.ics)は、カレンダーのアプリからURLのトークンだけで読み込めるように/tasksの外側に置きます。
+	// 組織のタスクのフィードは/feeds/orgs/:orgId/<トークン>.icsです。
+	e.GET("/feeds/:token", fc.GetFeedCalendar, fc.Authenticate, oc.ResolveTenant)
+	e.GET("/feeds/orgs/:orgId/:token", fc.GetFeedCalendar, fc.Authenticate, oc.ResolveTenant)
+	// タスク関係のエンドポイントには、JWTのミドルウェアを適用するようにしておきます。
 	// Useキーワードを使うことで、エンドポイントにミドルウェアを追加することができます。
 	// ここではECHOのJWTというミドルウェアを適用し、SigningKeyのところにJWTを生成した時と同じSECRETキーを指定します。
 	// TokenLookupのところでは、クライアントから送られてくるJWTtokenがどこに格納されてるかのを指定する必要があります。
 	// cookieの中にtokenという名前でJWTtokenを格納するように実装してるので、
 	// TokenLookupのところでcookie:tokenを指定します。
-	t.Use(echojwt.WithConfig(echojwt.Config{
+	// タスク以外のグループでも同じ設定を使うので、ミドルウェアをjwtMiddlewareという変数に格納しておきます。
+	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
 		SigningKey:  []byte(os.Getenv("SECRET")),
 		TokenLookup: "cookie:token",
-	}))
-	// タスク関係のエンドポイントを追加
-	// GetAllTasksのエンドポイントにリクエストがあった際は、
-	// タスクコントローラーのGetAllTasksを呼び出すようにしています。
-	// パラメーター付きでリクエストがあった際は、タスクコントローラーのGetTaskById
-	// POSTメソッドのリクエストの場合はCreateTask、
-	// PUTメソッドの場合はUpdateTask
-	// DELETEの場合はタスクコントローラーのDeleteTaskを呼び出すようにしておきます。
-	t.GET("", tc.GetAllTasks)
-	t.GET("/:taskId", tc.GetTaskById)
-	t.POST("", tc.CreateTask)
-	t.PUT("/:taskId", tc.UpdateTask)
-	t.DELETE("/:taskId", tc.DeleteTask)
+	})
+	// タスクとプロジェクトとボードのエンドポイントは、/tasksと/projectsと/boardsの他に
+	// 組織を指定する/orgs/:orgId/tasksと/orgs/:orgId/projectsと/orgs/:orgId/boardsにも追加します。
+	// ResolveTenantのミドルウェアでリクエストの組織を決めて、リポジトリのクエリをその組織のデータに絞り込みます。
+	taskRoutes := func(t *echo.Group) {
+		// タスク関係のエンドポイントを追加
+		// GetAllTasksのエンドポイントにリクエストがあった際は、
+		// タスクコントローラーのGetAllTasksを呼び出すようにしています。
+		// パラメーター付きでリクエストがあった際は、タスクコントローラーのGetTaskById
+		// POSTメソッドのリクエストの場合はCreateTask、
+		// PUTメソッドの場合はUpdateTask
+		// DELETEの場合はタスクコントローラーのDeleteTaskを呼び出すようにしておきます。
+		t.GET("", tc.GetAllTasks)
+		// タスクのエクスポートとインポート(/:taskIdより先に登録しておきます)
+		t.GET("/export", tc.ExportTasks)
+		t.POST("/import", tc.ImportTasks)
+		t.GET("/:taskId", tc.GetTaskById)
+		t.POST("", tc.CreateTask)
+		t.PUT("/:taskId", tc.UpdateTask)
+		t.DELETE("/:taskId", tc.DeleteTask)
+		// タスクの並び替え
+		t.POST("/:taskId/move", tc.MoveTask)
+		// タスクの複製(独自の項目の値と添付ファイルも含めるかを指定できます)
+		t.POST("/:taskId/duplicate", tmc.DuplicateTask)
+		// タスクの変更履歴と、以前のバージョンへの復元
+		t.GET("/:taskId/history", tc.GetTaskHistory)
+		t.POST("/:taskId/history/:version/restore", tc.RestoreTaskVersion)
+		// タスクの担当者の変更と、担当者の変更履歴
+		t.PUT("/:taskId/assignee", tc.AssignTask)
+		t.GET("/:taskId/assignments", tc.GetTaskAssignments)
+		// タスクごとのコメントのエンドポイント
+		t.GET("/:taskId/comments", cc.GetComments)
+		t.POST("/:taskId/comments", cc.CreateComment)
+		t.PUT("/:taskId/comments/:commentId", cc.UpdateComment)
+		t.DELETE("/:taskId/comments/:commentId", cc.DeleteComment)
+		t.GET("/:taskId/comments/:commentId/revisions", cc.GetCommentRevisions)
+		// タスクごとの添付ファイルのエンドポイント
+		t.GET("/:taskId/attachments", ac.GetAttachments)
+		t.POST("/:taskId/attachments", ac.UploadAttachment)
+		t.GET("/:taskId/attachments/:attachmentId", ac.DownloadAttachment)
+		t.GET("/:taskId/attachments/:attachmentId/thumbnail", ac.DownloadThumbnail)
+		t.DELETE("/:taskId/attachments/:attachmentId", ac.DeleteAttachment)
+		// タスクの共有のエンドポイント
+		t.GET("/:taskId/shares", sc.GetTaskShares)
+		t.POST("/:taskId/shares", sc.ShareTask)
+		t.DELETE("/:taskId/shares/:shareId", sc.DeleteTaskShare)
+		// 公開リンクの作成と無効化
+		t.GET("/:taskId/share-links", lc.GetShareLinks)
+		t.POST("/:taskId/share-links", lc.CreateShareLink)
+		t.DELETE("/:taskId/share-links/:linkId", lc.RevokeShareLink)
+		// タスクごとのリマインダーのエンドポイント
+		t.GET("/:taskId/reminders", rc.GetReminders)
+		t.POST("/:taskId/reminders", rc.CreateReminder)
+		t.DELETE("/:taskId/reminders/:reminderId", rc.DeleteReminder)
+		// タスクのタイマーの開始(POST)と停止(DELETE)
+		t.POST("/:taskId/timer", tec.StartTimer)
+		t.DELETE("/:taskId/timer", tec.StopTimer)
+		// 作業時間の一覧と、手動での追加・編集・削除
+		t.GET("/:taskId/time-entries", tec.GetTimeEntries)
+		t.POST("/:taskId/time-entries", tec.CreateTimeEntry)
+		t.PUT("/:taskId/time-entries/:entryId", tec.UpdateTimeEntry)
+		t.DELETE("/:taskId/time-entries/:entryId", tec.DeleteTimeEntry)
+	}
+	projectRoutes := func(p *echo.Group) {
+		p.GET("", pc.GetProjects)
+		p.GET("/:projectId", pc.GetProjectById)
+		p.POST("", pc.CreateProject)
+		p.PUT("/:projectId", pc.UpdateProject)
+		p.DELETE("/:projectId", pc.DeleteProject)
+		// プロジェクトの共有のエンドポイント
+		p.GET("/:projectId/shares", sc.GetProjectShares)
+		p.POST("/:projectId/shares", sc.ShareProject)
+		p.DELETE("/:projectId/shares/:shareId", sc.DeleteProjectShare)
+		// プロジェクトの独自の項目の定義のエンドポイント
+		p.GET("/:projectId/fields", cfc.GetFields)
+		p.POST("/:projectId/fields", cfc.CreateField)
+		p.PUT("/:projectId/fields/:fieldId", cfc.UpdateField)
+		p.DELETE("/:projectId/fields/:fieldId", cfc.DeleteField)
+	}
+	boardRoutes := func(b *echo.Group) {
+		b.GET("", bc.GetBoards)
+		// ボードの詳細は、列とその中のタスクをまとめて返します。
+		b.GET("/:boardId", bc.GetBoardById)
+		b.POST("", bc.CreateBoard)
+		b.PUT("/:boardId", bc.UpdateBoard)
+		b.DELETE("/:boardId", bc.DeleteBoard)
+		// ボードの列の追加・変更・削除と並び替え
+		b.POST("/:boardId/columns", bc.CreateColumn)
+		b.PUT("/:boardId/columns/:columnId", bc.UpdateColumn)
+		b.DELETE("/:boardId/columns/:columnId", bc.DeleteColumn)
+		b.POST("/:boardId/columns/:columnId/move", bc.MoveColumn)
+		// タスクの列の移動(WIPの上限を確認して1回の呼び出しで移動)と、ボードから外す
+		b.PUT("/:boardId/tasks/:taskId", bc.MoveTask)
+		b.DELETE("/:boardId/tasks/:taskId", bc.RemoveTask)
+	}
+	viewRoutes := func(v *echo.Group) {
+		v.GET("", vc.GetViews)
+		v.GET("/:viewId", vc.GetViewById)
+		v.POST("", vc.CreateView)
+		v.PUT("/:viewId", vc.UpdateView)
+		v.DELETE("/:viewId", vc.DeleteView)
+		// ビューの検索クエリと並び順でタスクの一覧を取得
+		v.GET("/:viewId/tasks", vc.GetViewTasks)
+	}
+	templateRoutes := func(tm *echo.Group) {
+		tm.GET("", tmc.GetTemplates)
+		tm.GET("/:templateId", tmc.GetTemplateById)
+		tm.POST("", tmc.CreateTemplate)
+		tm.PUT("/:templateId", tmc.UpdateTemplate)
+		tm.DELETE("/:templateId", tmc.DeleteTemplate)
+		// テンプレートのタスクを1つのトランザクションでまとめて作成
+		tm.POST("/:templateId/instantiate", tmc.InstantiateTemplate)
+	}
+	// 他のツール(Todoist・Trello・Microsoft To Do)のエクスポートファイルのインポートは、バックグラウンドのジョブで実行します。
+	importRoutes := func(im *echo.Group) {
+		im.GET("", ic.GetImports)
+		im.GET("/:importId", ic.GetImportById)
+		im.POST("", ic.CreateImport)
+	}
+	// タスクのイベント(task.created・task.updated・task.deleted・task.completed)を通知するWebhookと、その配信のログ
+	webhookRoutes := func(w *echo.Group) {
+		w.GET("", wc.GetWebhooks)
+		w.POST("", wc.CreateWebhook)
+		w.GET("/:webhookId", wc.GetWebhookById)
+		w.PUT("/:webhookId", wc.UpdateWebhook)
+		w.DELETE("/:webhookId", wc.DeleteWebhook)
+		w.POST("/:webhookId/secret", wc.RotateSecret)
+		w.GET("/:webhookId/deliveries", wc.GetDeliveries)
+		w.POST("/:webhookId/deliveries/:deliveryId/redeliver", wc.Redeliver)
+	}
+	// ECHOインスタンスのeに対して新しくグループを作っていきます。
+	// タスク関係のエンドポイントをグループ化して、JWTとテナントのミドルウェアを適用します。
+	taskRoutes(e.Group("/tasks", jwtMiddleware, oc.ResolveTenant))
+	// プロジェクトのエンドポイントもJWTのミドルウェアを適用したグループにまとめます。
+	projectRoutes(e.Group("/projects", jwtMiddleware, oc.ResolveTenant))
+	boardRoutes(e.Group("/boards", jwtMiddleware, oc.ResolveTenant))
+	viewRoutes(e.Group("/views", jwtMiddleware, oc.ResolveTenant))
+	templateRoutes(e.Group("/templates", jwtMiddleware, oc.ResolveTenant))
+	importRoutes(e.Group("/imports", jwtMiddleware, oc.ResolveTenant))
+	webhookRoutes(e.Group("/webhooks", jwtMiddleware, oc.ResolveTenant))
+	// タスクの変更のリアルタイムの通知(Server-Sent EventsとWebSocket)
+	// GET /tasksのポーリングの代わりに、見られるタスクの作成・更新・削除を接続している間ずっと送ります。
+	e.GET("/events", ec.Stream, jwtMiddleware, oc.ResolveTenant)
+	e.GET("/events/ws", ec.WebSocket, jwtMiddleware, oc.ResolveTenant)
+	// オフラインで使えるクライアントの差分の同期
+	// GETで同期トークンの時点からの変更を取得し、POSTでオフラインの間の変更をまとめて送ります。
+	e.GET("/sync", syc.GetChanges, jwtMiddleware, oc.ResolveTenant)
+	e.POST("/sync", syc.PushChanges, jwtMiddleware, oc.ResolveTenant)
+	// GraphQLのエンドポイント
+	// POSTでクエリとミューテーションを実行し、GETのWebSocket(graphql-transport-ws)でサブスクリプションを受け取ります。
+	e.POST("/graphql", gqc.Query, jwtMiddleware, oc.ResolveTenant)
+	e.GET("/graphql", gqc.WebSocket, jwtMiddleware, oc.ResolveTenant)
+	// 作業時間のレポートも組織ごとに集計するので、テナントのミドルウェアを適用します。
+	e.GET("/reports/time", tec.GetTimeReport, jwtMiddleware, oc.ResolveTenant)
+	// 組織のエンドポイント
+	o := e.Group("/orgs")
+	o.Use(jwtMiddleware)
+	o.GET("", oc.GetOrganizations)
+	o.POST("", oc.CreateOrganization)
+	o.GET("/:orgId", oc.GetOrganizationById)
+	o.PUT("/:orgId", oc.UpdateOrganization)
+	o.DELETE("/:orgId", oc.DeleteOrganization)
+	// 組織のメンバーの一覧と、権限の変更・組織から外す
+	o.GET("/:orgId/members", oc.GetMembers)
+	o.PUT("/:orgId/members/:userId", oc.UpdateMember)
+	o.DELETE("/:orgId/members/:userId", oc.DeleteMember)
+	// 組織への招待
+	o.GET("/:orgId/invitations", oc.GetInvitations)
+	o.POST("/:orgId/invitations", oc.CreateInvitation)
+	o.DELETE("/:orgId/invitations/:invitationId", oc.DeleteInvitation)
+	// パスで組織を指定するタスクとプロジェクトのエンドポイント
+	taskRoutes(o.Group("/:orgId/tasks", oc.ResolveTenant))
+	projectRoutes(o.Group("/:orgId/projects", oc.ResolveTenant))
+	boardRoutes(o.Group("/:orgId/boards", oc.ResolveTenant))
+	viewRoutes(o.Group("/:orgId/views", oc.ResolveTenant))
+	templateRoutes(o.Group("/:orgId/templates", oc.ResolveTenant))
+	importRoutes(o.Group("/:orgId/imports", oc.ResolveTenant))
+	webhookRoutes(o.Group("/:orgId/webhooks", oc.ResolveTenant))
+	o.GET("/:orgId/events", ec.Stream, oc.ResolveTenant)
+	o.GET("/:orgId/events/ws", ec.WebSocket, oc.ResolveTenant)
+	o.GET("/:orgId/sync", syc.GetChanges, oc.ResolveTenant)
+	o.POST("/:orgId/sync", syc.PushChanges, oc.ResolveTenant)
+	o.POST("/:orgId/graphql", gqc.Query, oc.ResolveTenant)
+	o.GET("/:orgId/graphql", gqc.WebSocket, oc.ResolveTenant)
+	o.GET("/:orgId/reports/time", tec.GetTimeReport, oc.ResolveTenant)
+	// 招待の受け入れはトークンで招待を探すので、組織のIDをパスに含めません。
+	i := e.Group("/invitations")
+	i.Use(jwtMiddleware)
+	i.POST("/accept", oc.AcceptInvitation)
+	// 通知のエンドポイントもJWTのミドルウェアを適用したグループにまとめます。
+	// 通知は元になったタスクの組織ごとに分かれているので、テナントのミドルウェアも適用します。
+	notificationRoutes := func(n *echo.Group) {
+		n.GET("", nc.GetNotifications)
+		n.PUT("/:notificationId/read", nc.MarkAsRead)
+	}
+	notificationRoutes(e.Group("/notifications", jwtMiddleware, oc.ResolveTenant))
+	notificationRoutes(o.Group("/:orgId/notifications", oc.ResolveTenant))
+	// フィードのトークンの確認・作り直し(POST)・無効化(DELETE)
+	f := e.Group("/feed")
+	f.Use(jwtMiddleware)
+	f.GET("", fc.GetFeed)
+	f.POST("", fc.RegenerateFeed)
+	f.DELETE("", fc.DeleteFeed)
+	// メールからタスクを作成する受信アドレスの確認・作り直し(POST)・送信者の変更(PUT)・無効化(DELETE)
+	mi := e.Group("/mail-inbox")
+	mi.Use(jwtMiddleware)
+	mi.GET("", mic.GetInbox)
+	mi.POST("", mic.RegenerateInbox)
+	mi.PUT("", mic.UpdateInbox)
+	mi.DELETE("", mic.DeleteInbox)
+	// 個人用のアクセストークン(CalDAVなどのクライアントのパスワードの代わりに使います)
+	pt := e.Group("/tokens")
+	pt.Use(jwtMiddleware)
+	pt.GET("", ptc.GetTokens)
+	pt.POST("", ptc.CreateToken)
+	pt.DELETE("/:tokenId", ptc.DeleteToken)
+	// CalDAVのエンドポイントは、クッキーのJWTではなくBasic認証かアクセストークンで認証します。
+	// コレクションのURLは末尾のスラッシュの有無のどちらでも受け付けます。
+	calDAVRoutes := func(d *echo.Group) {
+		collection := func(path string, propfind echo.HandlerFunc) {
+			for _, p := range []string{path, path + "/"} {
+				d.OPTIONS(p, cdc.Options)
+				d.Add(echo.PROPFIND, p, propfind)
+			}
+		}
+		collection("", cdc.PropfindRoot)
+		collection("/principal", cdc.PropfindPrincipal)
+		collection("/calendars", cdc.PropfindHome)
+		collection("/calendars/:calendar", cdc.PropfindCalendar)
+		d.Add(echo.REPORT, "/calendars/:calendar", cdc.Report)
+		d.Add(echo.REPORT, "/calendars/:calendar/", cdc.Report)
+		d.OPTIONS("/calendars/:calendar/:object", cdc.Options)
+		d.Add(echo.PROPFIND, "/calendars/:calendar/:object", cdc.PropfindObject)
+		d.GET("/calendars/:calendar/:object", cdc.GetObject)
+		d.PUT("/calendars/:calendar/:object", cdc.PutObject)
+		d.DELETE("/calendars/:calendar/:object", cdc.DeleteObject)
+	}
+	calDAVRoutes(e.Group("/caldav", ptc.Authenticate, oc.ResolveTenant))
+	calDAVRoutes(e.Group("/caldav/orgs/:orgId", ptc.Authenticate, oc.ResolveTenant))
+	// クライアントがCalDAVのURLを探すための/.well-known/caldav(RFC 6764)
+	e.Any("/.well-known/caldav", cdc.WellKnown)
 	//NewRouter関数の返り値としてechoインスタンス(e)を返す
 	return e
 }
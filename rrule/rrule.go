@@ -0,0 +1,649 @@
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencyは繰り返しの単位(FREQ)を表す
+type Frequency int
+
+const (
+	Yearly Frequency = iota
+	Monthly
+	Weekly
+	Daily
+)
+
+var frequencyNames = map[string]Frequency{
+	"YEARLY":  Yearly,
+	"MONTHLY": Monthly,
+	"WEEKLY":  Weekly,
+	"DAILY":   Daily,
+}
+
+func (f Frequency) String() string {
+	for k, v := range frequencyNames {
+		if v == f {
+			return k
+		}
+	}
+	return ""
+}
+
+// WeekdayはBYDAYの要素で、Nが0以外の場合は「第N週の曜日」(-1は最終)を表す
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func weekdayName(d time.Weekday) string {
+	for k, v := range weekdayNames {
+		if v == d {
+			return k
+		}
+	}
+	return ""
+}
+
+// RRuleはRFC 5545のRRULEをパースした結果
+// 日単位以上の頻度(DAILY/WEEKLY/MONTHLY/YEARLY)のみをサポートしています。
+type RRule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByMonth    []int
+	ByMonthDay []int
+	ByYearDay  []int
+	ByDay      []Weekday
+	ByHour     []int
+	ByMinute   []int
+	BySecond   []int
+	BySetPos   []int
+	Wkst       time.Weekday
+	// untilFloatingはUNTILがタイムゾーン無し(ローカル時刻)で指定されたかどうか
+	untilFloating bool
+}
+
+// maxEmptyPeriodsは1件も発生しない期間が続いた時に展開を打ち切るための上限
+// (例えば FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30 は永遠に発生しない)
+const maxEmptyPeriods = 1000
+
+// Parseは"FREQ=WEEKLY;BYDAY=MO,WE"のような文字列をパースする
+// 先頭の"RRULE:"は省略可能です。
+func Parse(s string) (*RRule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("rrule is empty")
+	}
+	r := &RRule{Interval: 1, Wkst: time.Monday}
+	hasFreq := false
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid rrule part %q", part)
+		}
+		key, value := kv[0], kv[1]
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate rrule part %s", key)
+		}
+		seen[key] = true
+		var err error
+		switch key {
+		case "FREQ":
+			f, ok := frequencyNames[value]
+			if !ok {
+				return nil, fmt.Errorf("unsupported FREQ %s", value)
+			}
+			r.Freq = f
+			hasFreq = true
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("INTERVAL must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("COUNT must be positive")
+			}
+		case "UNTIL":
+			r.Until, r.untilFloating, err = parseUntil(value)
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(value, 1, 12, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value, 1, 31, true)
+		case "BYYEARDAY":
+			r.ByYearDay, err = parseInts(value, 1, 366, true)
+		case "BYHOUR":
+			r.ByHour, err = parseInts(value, 0, 23, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseInts(value, 0, 59, false)
+		case "BYSECOND":
+			r.BySecond, err = parseInts(value, 0, 59, false)
+		case "BYSETPOS":
+			r.BySetPos, err = parseInts(value, 1, 366, true)
+		case "BYDAY":
+			r.ByDay, err = parseWeekdays(value)
+		case "WKST":
+			d, ok := weekdayNames[value]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %s", value)
+			}
+			r.Wkst = d
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if !hasFreq {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL must not be used together")
+	}
+	if len(r.ByYearDay) > 0 && r.Freq != Yearly {
+		return nil, fmt.Errorf("BYYEARDAY is only allowed with FREQ=YEARLY")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == Weekly {
+		return nil, fmt.Errorf("BYMONTHDAY is not allowed with FREQ=WEEKLY")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, fmt.Errorf("BYDAY ordinal is only allowed with FREQ=MONTHLY or YEARLY")
+		}
+	}
+	return r, nil
+}
+
+func parseUntil(v string) (time.Time, bool, error) {
+	layouts := []struct {
+		layout   string
+		floating bool
+	}{
+		{"20060102T150405Z", false},
+		{"20060102T150405", true},
+		{"20060102", true},
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l.layout, v); err == nil {
+			return t, l.floating, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %s", v)
+}
+
+func parseInts(v string, min, max int, allowNegative bool) ([]int, error) {
+	var res []int
+	for _, s := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", s)
+		}
+		abs := n
+		if abs < 0 {
+			if !allowNegative {
+				return nil, fmt.Errorf("%d out of range", n)
+			}
+			abs = -abs
+		}
+		if abs < min || abs > max {
+			return nil, fmt.Errorf("%d out of range", n)
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func parseWeekdays(v string) ([]Weekday, error) {
+	var res []Weekday
+	for _, s := range strings.Split(v, ",") {
+		if len(s) < 2 {
+			return nil, fmt.Errorf("invalid weekday %s", s)
+		}
+		day, ok := weekdayNames[s[len(s)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %s", s)
+		}
+		wd := Weekday{Day: day}
+		if prefix := s[:len(s)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n > 53 || n < -53 {
+				return nil, fmt.Errorf("invalid weekday %s", s)
+			}
+			wd.N = n
+		}
+		res = append(res, wd)
+	}
+	return res, nil
+}
+
+// StringはRRULEを正規化した文字列で返す(先頭の"RRULE:"は付けない)
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.untilFloating {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	ints := func(name string, v []int) {
+		if len(v) == 0 {
+			return
+		}
+		s := make([]string, len(v))
+		for i, n := range v {
+			s[i] = strconv.Itoa(n)
+		}
+		parts = append(parts, name+"="+strings.Join(s, ","))
+	}
+	ints("BYMONTH", r.ByMonth)
+	ints("BYMONTHDAY", r.ByMonthDay)
+	ints("BYYEARDAY", r.ByYearDay)
+	if len(r.ByDay) > 0 {
+		s := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			s[i] = weekdayName(wd.Day)
+			if wd.N != 0 {
+				s[i] = strconv.Itoa(wd.N) + s[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(s, ","))
+	}
+	ints("BYHOUR", r.ByHour)
+	ints("BYMINUTE", r.ByMinute)
+	ints("BYSECOND", r.BySecond)
+	ints("BYSETPOS", r.BySetPos)
+	if r.Wkst != time.Monday {
+		parts = append(parts, "WKST="+weekdayName(r.Wkst))
+	}
+	return strings.Join(parts, ";")
+}
+
+// WithUntilはUNTILを差し替えたコピーを返す("この回以降"の編集で元のシリーズを打ち切る時に使用)
+func (r *RRule) WithUntil(until time.Time) *RRule {
+	c := *r
+	c.Count = 0
+	c.Until = until.UTC()
+	c.untilFloating = false
+	return &c
+}
+
+// Allはdtstartから始まる発生日時を最大limit件返す
+func (r *RRule) All(dtstart time.Time, limit int) []time.Time {
+	var res []time.Time
+	r.iterate(dtstart, func(t time.Time) bool {
+		res = append(res, t)
+		return len(res) < limit
+	})
+	return res
+}
+
+// Afterはtより後(inclusiveがtrueの場合はt以降)の最初の発生日時を返す
+func (r *RRule) After(dtstart, t time.Time, inclusive bool) (time.Time, bool) {
+	var found time.Time
+	ok := false
+	r.iterate(dtstart, func(o time.Time) bool {
+		if o.After(t) || (inclusive && o.Equal(t)) {
+			found, ok = o, true
+			return false
+		}
+		return true
+	})
+	return found, ok
+}
+
+// Betweenはfrom以上to未満の発生日時を返す
+func (r *RRule) Between(dtstart, from, to time.Time) []time.Time {
+	var res []time.Time
+	r.iterate(dtstart, func(o time.Time) bool {
+		if !o.Before(to) {
+			return false
+		}
+		if !o.Before(from) {
+			res = append(res, o)
+		}
+		return true
+	})
+	return res
+}
+
+// iterateは発生日時を時系列順にfnへ渡す。fnがfalseを返すと終了する
+// 日付の計算は全てdtstartのタイムゾーンでの壁時計時刻で行い、
+// 最後にlocalTimeで実際の時刻に変換することで夏時間の切り替えをまたいでも同じ時刻を保つ
+func (r *RRule) iterate(dtstart time.Time, fn func(time.Time) bool) {
+	loc := dtstart.Location()
+	until := r.Until
+	if !until.IsZero() && r.untilFloating {
+		until = localTime(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), loc)
+	}
+	emitted := 0
+	emit := func(t time.Time) bool {
+		if !until.IsZero() && t.After(until) {
+			return false
+		}
+		emitted++
+		if !fn(t) {
+			return false
+		}
+		return r.Count == 0 || emitted < r.Count
+	}
+	// DTSTARTは常に最初の発生として数える(RFC 5545 3.8.5.3)
+	if !emit(dtstart) {
+		return
+	}
+	start := civil(dtstart)
+	empty := 0
+	for k := 0; empty < maxEmptyPeriods; k++ {
+		set := r.expandPeriod(start, dtstart, k, loc)
+		if len(set) == 0 {
+			empty++
+			continue
+		}
+		empty = 0
+		for _, t := range set {
+			if !t.After(dtstart) {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
+
+// dateはタイムゾーンに依存しない暦日
+type date struct {
+	y int
+	m time.Month
+	d int
+}
+
+func civil(t time.Time) date {
+	return date{t.Year(), t.Month(), t.Day()}
+}
+
+// addDaysはUTCで計算することで夏時間の影響を受けずに暦日を進める
+func (d date) addDays(n int) date {
+	t := time.Date(d.y, d.m, d.d+n, 0, 0, 0, 0, time.UTC)
+	return date{t.Year(), t.Month(), t.Day()}
+}
+
+func (d date) weekday() time.Weekday {
+	return time.Date(d.y, d.m, d.d, 0, 0, 0, 0, time.UTC).Weekday()
+}
+
+func daysIn(y int, m time.Month) int {
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// expandPeriodはk番目の期間(年/月/週/日)に含まれる発生日時を昇順で返す
+func (r *RRule) expandPeriod(start date, clock time.Time, k int, loc *time.Location) []time.Time {
+	var days []date
+	switch r.Freq {
+	case Yearly:
+		days = r.yearDays(start, start.y+k*r.Interval)
+	case Monthly:
+		first := time.Date(start.y, start.m+time.Month(k*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		days = r.monthDays(start, first.Year(), first.Month())
+	case Weekly:
+		// WKSTを週の始まりとして、dtstartを含む週からk*INTERVAL週後の週を求める
+		offset := (int(start.weekday()) - int(r.Wkst) + 7) % 7
+		weekStart := start.addDays(-offset + 7*k*r.Interval)
+		for i := 0; i < 7; i++ {
+			d := weekStart.addDays(i)
+			if r.matchWeekday(d, start) && r.matchMonth(d) {
+				days = append(days, d)
+			}
+		}
+	case Daily:
+		d := start.addDays(k * r.Interval)
+		if r.matchMonth(d) && r.matchMonthDay(d) && r.matchWeekday(d, date{}) {
+			days = append(days, d)
+		}
+	}
+	if len(days) == 0 {
+		return nil
+	}
+	// BYHOUR/BYMINUTE/BYSECONDが無い場合はdtstartの時刻を使う
+	var res []time.Time
+	for _, d := range days {
+		for _, h := range orDefault(r.ByHour, clock.Hour()) {
+			for _, mi := range orDefault(r.ByMinute, clock.Minute()) {
+				for _, s := range orDefault(r.BySecond, clock.Second()) {
+					res = append(res, localTime(d.y, d.m, d.d, h, mi, s, loc))
+				}
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Before(res[j]) })
+	res = dedupe(res)
+	if len(r.BySetPos) > 0 {
+		res = applySetPos(res, r.BySetPos)
+	}
+	return res
+}
+
+func (r *RRule) yearDays(start date, y int) []date {
+	var days []date
+	switch {
+	case len(r.ByYearDay) > 0:
+		total := 365
+		if daysIn(y, time.February) == 29 {
+			total = 366
+		}
+		for _, n := range r.ByYearDay {
+			if n < 0 {
+				n = total + n + 1
+			}
+			if n < 1 || n > total {
+				continue
+			}
+			d := date{y, time.January, 1}.addDays(n - 1)
+			if r.matchMonth(d) && r.matchMonthDay(d) && r.matchWeekday(d, date{}) {
+				days = append(days, d)
+			}
+		}
+	case len(r.ByDay) > 0 && len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0:
+		// BYMONTHが無い場合のBYDAYの序数は年単位で数える
+		days = expandWeekdays(date{y, time.January, 1}, date{y, time.December, 31}, r.ByDay)
+	case len(r.ByMonth) > 0 || len(r.ByMonthDay) > 0 || len(r.ByDay) > 0:
+		months := r.ByMonth
+		if len(months) == 0 {
+			for m := 1; m <= 12; m++ {
+				months = append(months, m)
+			}
+		}
+		for _, m := range months {
+			days = append(days, r.monthDays(start, y, time.Month(m))...)
+		}
+	default:
+		if start.d <= daysIn(y, start.m) {
+			days = append(days, date{y, start.m, start.d})
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].before(days[j]) })
+	return days
+}
+
+func (r *RRule) monthDays(start date, y int, m time.Month) []date {
+	if !r.matchMonth(date{y, m, 1}) {
+		return nil
+	}
+	n := daysIn(y, m)
+	var days []date
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for i := 1; i <= n; i++ {
+			d := date{y, m, i}
+			if r.matchMonthDay(d) && r.matchWeekdayIn(d, date{y, m, 1}, date{y, m, n}) {
+				days = append(days, d)
+			}
+		}
+	case len(r.ByDay) > 0:
+		days = expandWeekdays(date{y, m, 1}, date{y, m, n}, r.ByDay)
+	default:
+		if start.d <= n {
+			days = append(days, date{y, m, start.d})
+		}
+	}
+	return days
+}
+
+// expandWeekdaysはfirstからlastまでの範囲でBYDAYに一致する日を返す
+// 序数付きの場合は範囲内で何番目の曜日かで絞り込む
+func expandWeekdays(first, last date, byDay []Weekday) []date {
+	byWeekday := map[time.Weekday][]date{}
+	for d := first; !last.before(d); d = d.addDays(1) {
+		byWeekday[d.weekday()] = append(byWeekday[d.weekday()], d)
+	}
+	seen := map[date]bool{}
+	var days []date
+	for _, wd := range byDay {
+		list := byWeekday[wd.Day]
+		if wd.N == 0 {
+			for _, d := range list {
+				if !seen[d] {
+					seen[d] = true
+					days = append(days, d)
+				}
+			}
+			continue
+		}
+		i := wd.N - 1
+		if wd.N < 0 {
+			i = len(list) + wd.N
+		}
+		if i >= 0 && i < len(list) && !seen[list[i]] {
+			seen[list[i]] = true
+			days = append(days, list[i])
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].before(days[j]) })
+	return days
+}
+
+func (d date) before(o date) bool {
+	if d.y != o.y {
+		return d.y < o.y
+	}
+	if d.m != o.m {
+		return d.m < o.m
+	}
+	return d.d < o.d
+}
+
+func (r *RRule) matchMonth(d date) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == d.m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonthDay(d date) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	n := daysIn(d.y, d.m)
+	for _, md := range r.ByMonthDay {
+		if md == d.d || (md < 0 && n+md+1 == d.d) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekdayはWEEKLYとDAILYで使う曜日の判定
+// WEEKLYでBYDAYが無い場合はdtstartの曜日を使う
+func (r *RRule) matchWeekday(d date, start date) bool {
+	if len(r.ByDay) == 0 {
+		if r.Freq == Weekly {
+			return d.weekday() == start.weekday()
+		}
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == d.weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekdayInはBYMONTHDAYとBYDAYが両方指定された場合の絞り込み
+func (r *RRule) matchWeekdayIn(d, first, last date) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, x := range expandWeekdays(first, last, r.ByDay) {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+func applySetPos(set []time.Time, pos []int) []time.Time {
+	var res []time.Time
+	for _, p := range pos {
+		i := p - 1
+		if p < 0 {
+			i = len(set) + p
+		}
+		if i >= 0 && i < len(set) {
+			res = append(res, set[i])
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Before(res[j]) })
+	return dedupe(res)
+}
+
+func dedupe(ts []time.Time) []time.Time {
+	res := ts[:0]
+	for i, t := range ts {
+		if i == 0 || !t.Equal(ts[i-1]) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+func orDefault(v []int, def int) []int {
+	if len(v) > 0 {
+		return v
+	}
+	return []int{def}
+}
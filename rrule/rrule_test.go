@@ -0,0 +1,189 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func mustParse(t *testing.T, s string) *RRule {
+	t.Helper()
+	r, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return r
+}
+
+func formatAll(times []time.Time) []string {
+	res := make([]string, len(times))
+	for i, t := range times {
+		res[i] = t.Format(time.RFC3339)
+	}
+	return res
+}
+
+func assertTimes(t *testing.T, got []time.Time, want []string) {
+	t.Helper()
+	g := formatAll(got)
+	if len(g) != len(want) {
+		t.Fatalf("got %d occurrences %v, want %d %v", len(g), g, len(want), want)
+	}
+	for i := range want {
+		if g[i] != want[i] {
+			t.Errorf("occurrence %d: got %s, want %s", i, g[i], want[i])
+		}
+	}
+}
+
+func TestDSTTransitions(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	berlin := mustLoad(t, "Europe/Berlin")
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		limit   int
+		want    []string
+	}{
+		{
+			// 春の切り替えで存在しない02:30は、切り替え前のオフセットで解釈して03:30になる
+			name:    "spring forward gap",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2024, 3, 9, 2, 30, 0, 0, newYork),
+			limit:   3,
+			want:    []string{"2024-03-09T02:30:00-05:00", "2024-03-10T03:30:00-04:00", "2024-03-11T02:30:00-04:00"},
+		},
+		{
+			// 秋の切り替えで重複する01:30は、最初に現れる方(夏時間)を使う
+			name:    "fall back overlap",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2024, 11, 2, 1, 30, 0, 0, newYork),
+			limit:   3,
+			want:    []string{"2024-11-02T01:30:00-04:00", "2024-11-03T01:30:00-04:00", "2024-11-04T01:30:00-05:00"},
+		},
+		{
+			name:    "weekly keeps wall clock across transition",
+			rule:    "FREQ=WEEKLY",
+			dtstart: time.Date(2024, 3, 24, 9, 0, 0, 0, berlin),
+			limit:   2,
+			want:    []string{"2024-03-24T09:00:00+01:00", "2024-03-31T09:00:00+02:00"},
+		},
+		{
+			name:    "BYHOUR inside spring forward gap",
+			rule:    "FREQ=DAILY;BYHOUR=2,4;BYMINUTE=0",
+			dtstart: time.Date(2024, 3, 31, 0, 0, 0, 0, berlin),
+			limit:   3,
+			want:    []string{"2024-03-31T00:00:00+01:00", "2024-03-31T03:00:00+02:00", "2024-03-31T04:00:00+02:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertTimes(t, mustParse(t, tt.rule).All(tt.dtstart, tt.limit), tt.want)
+		})
+	}
+}
+
+func TestUntilAndCount(t *testing.T) {
+	tokyo := mustLoad(t, "Asia/Tokyo")
+	newYork := mustLoad(t, "America/New_York")
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		want    []string
+	}{
+		{
+			name:    "COUNT includes dtstart",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, tokyo),
+			want:    []string{"2024-01-01T09:00:00+09:00", "2024-01-02T09:00:00+09:00", "2024-01-03T09:00:00+09:00"},
+		},
+		{
+			name:    "UTC UNTIL is inclusive",
+			rule:    "FREQ=DAILY;UNTIL=20240103T000000Z",
+			dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, tokyo),
+			want:    []string{"2024-01-01T09:00:00+09:00", "2024-01-02T09:00:00+09:00", "2024-01-03T09:00:00+09:00"},
+		},
+		{
+			name:    "UTC UNTIL before the wall clock time excludes the day",
+			rule:    "FREQ=DAILY;UNTIL=20240102T235959Z",
+			dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, tokyo),
+			want:    []string{"2024-01-01T09:00:00+09:00", "2024-01-02T09:00:00+09:00"},
+		},
+		{
+			// タイムゾーン無しのUNTILはdtstartのタイムゾーンの時刻として扱う
+			name:    "floating UNTIL uses dtstart location",
+			rule:    "FREQ=DAILY;UNTIL=20240311T023000",
+			dtstart: time.Date(2024, 3, 9, 2, 30, 0, 0, newYork),
+			want:    []string{"2024-03-09T02:30:00-05:00", "2024-03-10T03:30:00-04:00", "2024-03-11T02:30:00-04:00"},
+		},
+		{
+			name:    "date only UNTIL",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,FR;UNTIL=20240108",
+			dtstart: time.Date(2024, 1, 1, 0, 0, 0, 0, tokyo),
+			want:    []string{"2024-01-01T00:00:00+09:00", "2024-01-05T00:00:00+09:00", "2024-01-08T00:00:00+09:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertTimes(t, mustParse(t, tt.rule).All(tt.dtstart, 100), tt.want)
+		})
+	}
+	if _, err := Parse("FREQ=DAILY;COUNT=3;UNTIL=20240103T000000Z"); err == nil {
+		t.Error("COUNT and UNTIL together should be rejected")
+	}
+}
+
+func TestSetPosAndNegativeMonthDay(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		limit   int
+		want    []string
+	}{
+		{
+			name:    "last day of month",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC),
+			limit:   4,
+			want:    []string{"2024-01-31T10:00:00Z", "2024-02-29T10:00:00Z", "2024-03-31T10:00:00Z", "2024-04-30T10:00:00Z"},
+		},
+		{
+			name:    "last weekday of month",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			dtstart: time.Date(2024, 3, 29, 10, 0, 0, 0, time.UTC),
+			limit:   3,
+			want:    []string{"2024-03-29T10:00:00Z", "2024-04-30T10:00:00Z", "2024-05-31T10:00:00Z"},
+		},
+		{
+			name:    "first and second to last weekend day",
+			rule:    "FREQ=MONTHLY;BYDAY=SA,SU;BYSETPOS=1,-2",
+			dtstart: time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC),
+			limit:   4,
+			want:    []string{"2024-06-01T08:00:00Z", "2024-06-29T08:00:00Z", "2024-07-06T08:00:00Z", "2024-07-27T08:00:00Z"},
+		},
+		{
+			name:    "31st skips short months",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=31",
+			dtstart: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			limit:   3,
+			want:    []string{"2024-01-31T00:00:00Z", "2024-03-31T00:00:00Z", "2024-05-31T00:00:00Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertTimes(t, mustParse(t, tt.rule).All(tt.dtstart, tt.limit), tt.want)
+		})
+	}
+}
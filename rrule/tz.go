@@ -0,0 +1,28 @@
+package rrule
+
+import "time"
+
+// localTimeは壁時計の時刻をlocのタイムゾーンでの実際の時刻に変換する
+// time.Dateは夏時間の切り替えで存在しない時刻・重複する時刻の扱いが保証されていないので、
+// RFC 5545 3.3.5の規定に合わせて以下のように解決します。
+//   - 存在しない時刻(春の切り替えの空白): 切り替え前のUTCオフセットで解釈する(02:30 → 03:30)
+//   - 重複する時刻(秋の切り替えの重複): 最初に現れる方(切り替え前のオフセット)を使う
+func localTime(y int, m time.Month, d, h, mi, s int, loc *time.Location) time.Time {
+	naive := time.Date(y, m, d, h, mi, s, 0, time.UTC)
+	// 前後1日のオフセットを候補にする(1日の中で2回以上切り替わるタイムゾーンは無い前提)
+	_, before := naive.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := naive.Add(24 * time.Hour).In(loc).Zone()
+	var found time.Time
+	for _, off := range []int{before, after} {
+		t := naive.Add(-time.Duration(off) * time.Second).In(loc)
+		if t.Year() == y && t.Month() == m && t.Day() == d && t.Hour() == h && t.Minute() == mi && t.Second() == s {
+			if found.IsZero() || t.Before(found) {
+				found = t
+			}
+		}
+	}
+	if !found.IsZero() {
+		return found
+	}
+	return naive.Add(-time.Duration(before) * time.Second).In(loc)
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"context"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/storage"
+	"log"
+	"time"
+)
+
+type IBlobSweeper interface {
+	// Startはctxがキャンセルされるまで定期的に、削除した添付ファイルの中身をBlobStoreから削除する
+	Start(ctx context.Context)
+}
+
+type blobSweeper struct {
+	ar       repository.IAttachmentRepository
+	bs       storage.BlobStore
+	interval time.Duration
+}
+
+// blobSweepBatchSizeは1回に取得する削除待ちのキーの件数
+const blobSweepBatchSize = 100
+
+func NewBlobSweeper(ar repository.IAttachmentRepository, bs storage.BlobStore, interval time.Duration) IBlobSweeper {
+	return &blobSweeper{ar, bs, interval}
+}
+
+func (bw *blobSweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(bw.interval)
+	defer ticker.Stop()
+	for {
+		bw.runOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnceは削除待ちのキーが無くなるまで、BlobStoreから削除してキーを消すことを繰り返す
+// BlobStoreのDeleteは存在しないキーでもエラーにならないので、複数のインスタンスが同じキーを削除しても問題ありません。
+// 削除に失敗したキーは残して、次の実行でもう一度削除します。
+func (bw *blobSweeper) runOnce(ctx context.Context) {
+	for ctx.Err() == nil {
+		deletions := []model.BlobDeletion{}
+		if err := bw.ar.GetBlobDeletions(ctx, &deletions, blobSweepBatchSize); err != nil {
+			log.Println("blob sweeper:", err)
+			return
+		}
+		failed := 0
+		for _, d := range deletions {
+			if err := bw.bs.Delete(ctx, d.StorageKey); err != nil {
+				log.Println("blob sweeper:", err)
+				failed++
+				continue
+			}
+			if err := bw.ar.DeleteBlobDeletion(ctx, d.ID); err != nil {
+				log.Println("blob sweeper:", err)
+				return
+			}
+		}
+		if len(deletions) < blobSweepBatchSize || failed > 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+	"go-rest-api/usecase"
+	"log"
+	"time"
+)
+
+type IImportWorker interface {
+	// Startはctxがキャンセルされるまで定期的に待機中のインポートのジョブを実行する
+	Start(ctx context.Context)
+}
+
+type importWorker struct {
+	ijr      repository.IImportJobRepository
+	iu       usecase.IImportUsecase
+	interval time.Duration
+}
+
+// staleImportTimeoutより長く進捗が更新されていない実行中のジョブは、実行中にサーバーが停止したものとして失敗にします。
+// 進捗はチャンク(100件)ごとに更新するので、通常はこの時間を超えることはありません。
+const staleImportTimeout = 10 * time.Minute
+
+func NewImportWorker(ijr repository.IImportJobRepository, iu usecase.IImportUsecase, interval time.Duration) IImportWorker {
+	return &importWorker{ijr, iu, interval}
+}
+
+func (iw *importWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(iw.interval)
+	defer ticker.Stop()
+	// ジョブは全ての組織のものが対象なので、テナントで絞り込まないctxで確保します。
+	systemCtx := tenant.WithSystem(ctx)
+	for {
+		if err := iw.iu.FailStaleImports(systemCtx, time.Now().Add(-staleImportTimeout)); err != nil {
+			log.Println("import worker:", err)
+		}
+		iw.runOnce(systemCtx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnceは確保できるジョブが無くなるまで、1件ずつジョブを実行する
+func (iw *importWorker) runOnce(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, ok, err := iw.ijr.ClaimImportJob(ctx, time.Now())
+		if err != nil {
+			log.Println("import worker:", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		if err := iw.iu.RunImport(ctx, job); err != nil {
+			log.Printf("import worker: job %d: %v", job.ID, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+	"log"
+	"time"
+)
+
+type IPositionRebalancer interface {
+	// Startはctxがキャンセルされるまで定期的にタスクの順位を振り直す
+	Start(ctx context.Context)
+}
+
+type positionRebalancer struct {
+	tr       repository.ITaskRepository
+	interval time.Duration
+}
+
+// maxPositionLengthを超える長さの順位を持つユーザーのタスクを振り直す
+// 同じ場所への挿入を繰り返すと順位の文字列が少しずつ長くなるためです。
+const maxPositionLength = 16
+
+func NewPositionRebalancer(tr repository.ITaskRepository, interval time.Duration) IPositionRebalancer {
+	return &positionRebalancer{tr, interval}
+}
+
+func (pr *positionRebalancer) Start(ctx context.Context) {
+	ticker := time.NewTicker(pr.interval)
+	defer ticker.Stop()
+	// 振り直しは全ての組織のタスクが対象なので、テナントで絞り込まないctxで実行します。
+	systemCtx := tenant.WithSystem(ctx)
+	for {
+		if n, err := pr.tr.RebalancePositions(systemCtx, maxPositionLength); err != nil {
+			log.Println("position rebalancer:", err)
+		} else if n > 0 {
+			log.Printf("position rebalancer: rebalanced tasks of %d users", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"context"
+	"go-rest-api/model"
+	"go-rest-api/notifier"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+	"log"
+	"time"
+)
+
+type IReminderScheduler interface {
+	// Startはctxがキャンセルされるまで定期的に通知時刻を過ぎたリマインダーを配信する
+	Start(ctx context.Context)
+}
+
+type reminderScheduler struct {
+	rr       repository.IReminderRepository
+	n        notifier.INotifier
+	interval time.Duration
+}
+
+// batchSizeは1回に確保するリマインダーの件数
+const batchSize = 50
+
+func NewReminderScheduler(rr repository.IReminderRepository, n notifier.INotifier, interval time.Duration) IReminderScheduler {
+	return &reminderScheduler{rr, n, interval}
+}
+
+func (rs *reminderScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+	// リマインダーは全ての組織のタスクのものが対象なので、テナントで絞り込まないctxで処理します。
+	systemCtx := tenant.WithSystem(ctx)
+	for {
+		rs.runOnce(systemCtx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnceは確保できるリマインダーが無くなるまでバッチ単位で配信を繰り返す
+// 確保はすぐにコミットして、配信はトランザクションの外で1件ずつ行い、結果を記録します。
+func (rs *reminderScheduler) runOnce(ctx context.Context) {
+	for ctx.Err() == nil {
+		reminders := []model.Reminder{}
+		if err := rs.rr.ClaimDueReminders(ctx, time.Now(), batchSize, &reminders); err != nil {
+			log.Println("reminder scheduler:", err)
+			return
+		}
+		for _, reminder := range reminders {
+			err := rs.n.Notify(ctx, reminder)
+			if err := rs.rr.RecordReminderResult(ctx, reminder, time.Now(), err); err != nil {
+				log.Println("reminder scheduler:", err)
+			}
+		}
+		if len(reminders) < batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"go-rest-api/tenant"
+	"go-rest-api/usecase"
+	"log"
+	"time"
+)
+
+type IWebhookDispatcher interface {
+	// Startはctxがキャンセルされるまで定期的にアウトボックスのイベントをWebhookに振り分けて、配信を送信する
+	Start(ctx context.Context)
+}
+
+type webhookDispatcher struct {
+	wu       usecase.IWebhookUsecase
+	interval time.Duration
+}
+
+// webhookBatchSizeは1回のトランザクションで確保するイベント・配信の件数
+// 確保した配信は順番に送信するので、確保し直せるようになるまでの時間の間に送り終わる件数にします。
+const webhookBatchSize = 10
+
+func NewWebhookDispatcher(wu usecase.IWebhookUsecase, interval time.Duration) IWebhookDispatcher {
+	return &webhookDispatcher{wu, interval}
+}
+
+func (wd *webhookDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(wd.interval)
+	defer ticker.Stop()
+	// イベントとWebhookは全ての組織のものが対象なので、テナントで絞り込まないctxで処理します。
+	systemCtx := tenant.WithSystem(ctx)
+	for {
+		wd.runOnce(systemCtx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnceは振り分けるイベントが無くなるまで振り分けてから、送信できる配信が無くなるまで送信を繰り返す
+func (wd *webhookDispatcher) runOnce(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := wd.wu.DispatchTaskEvents(ctx, webhookBatchSize)
+		if err != nil {
+			log.Println("webhook dispatcher:", err)
+			break
+		}
+		if n < webhookBatchSize {
+			break
+		}
+	}
+	for ctx.Err() == nil {
+		n, err := wd.wu.SendDueDeliveries(ctx, webhookBatchSize)
+		if err != nil {
+			log.Println("webhook dispatcher:", err)
+			return
+		}
+		if n < webhookBatchSize {
+			return
+		}
+	}
+}
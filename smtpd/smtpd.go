@@ -0,0 +1,243 @@
+// smtpdはメールを受け取るだけの小さなSMTPのサーバー(RFC 5321)
+// 受け取ったメールの宛先の確認と処理はBackendに任せて、ここではSMTPのやり取りだけを行います。
+// 他のメールサーバーからの配送を受け取るためのものなので、認証(AUTH)と中継はしません。
+package smtpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backendは受け取ったメールを処理する
+type Backend interface {
+	// Rcptは宛先(RCPT TO)を受け付けるかを確認する
+	Rcpt(ctx context.Context, from string, to string) error
+	// Dataは受け付けた宛先へのメール(ヘッダーと本文)を処理する
+	Data(ctx context.Context, from string, to []string, data []byte) error
+}
+
+// ErrorはSMTPの応答コードを指定したエラー(5xxは恒久的なエラー、4xxは一時的なエラー)
+// Backendがこれ以外のエラーを返した場合は、451の一時的なエラーとして応答します。
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Serverの設定
+type Server struct {
+	Addr string
+	// DomainはEHLOの応答で名乗るホスト名
+	Domain string
+	// MaxBytesは受け取るメールの最大サイズ(EHLOのSIZEで送信元にも伝えます)
+	MaxBytes int64
+	// MaxRecipientsは1通のメールで受け付ける宛先の最大数
+	MaxRecipients int
+	// Timeoutはコマンドを待つ時間
+	Timeout time.Duration
+	Backend Backend
+}
+
+// ListenAndServeはctxがキャンセルされるまでメールを受け付ける
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go s.serve(ctx, conn)
+	}
+}
+
+// sessionは1つの接続の中の、今受け付けているメールの状態
+type session struct {
+	helo string
+	from string
+	to   []string
+}
+
+func (s *session) reset() {
+	s.from = ""
+	s.to = nil
+}
+
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(code int, msg string) error {
+		conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+		return tp.PrintfLine("%d %s", code, msg)
+	}
+	if err := reply(220, s.Domain+" ESMTP ready"); err != nil {
+		return
+	}
+	sess := &session{}
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.Timeout))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			sess.helo = arg
+			sess.reset()
+			err = reply(250, s.Domain)
+		case "EHLO":
+			sess.helo = arg
+			sess.reset()
+			conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+			err = tp.PrintfLine("250-%s\r\n250-8BITMIME\r\n250-PIPELINING\r\n250 SIZE %d", s.Domain, s.MaxBytes)
+		case "MAIL":
+			err = s.mail(sess, arg, reply)
+		case "RCPT":
+			err = s.rcpt(ctx, sess, arg, reply)
+		case "DATA":
+			err = s.data(ctx, sess, tp, conn, reply)
+		case "RSET":
+			sess.reset()
+			err = reply(250, "2.0.0 OK")
+		case "NOOP":
+			err = reply(250, "2.0.0 OK")
+		case "VRFY":
+			err = reply(252, "2.5.0 Cannot VRFY user")
+		case "QUIT":
+			reply(221, "2.0.0 Bye")
+			return
+		default:
+			err = reply(502, "5.5.2 Command not recognized")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parsePathは"FROM:<a@example.com> SIZE=100"のような引数から、アドレスとパラメーターを取り出す
+func parsePath(arg string, prefix string) (string, map[string]string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", nil, false
+	}
+	end := strings.Index(rest, ">")
+	if end < 0 {
+		return "", nil, false
+	}
+	params := map[string]string{}
+	for _, p := range strings.Fields(rest[end+1:]) {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = v
+	}
+	return rest[1:end], params, true
+}
+
+func (s *Server) mail(sess *session, arg string, reply func(int, string) error) error {
+	if sess.helo == "" {
+		return reply(503, "5.5.1 Send HELO/EHLO first")
+	}
+	if sess.from != "" {
+		return reply(503, "5.5.1 Sender already specified")
+	}
+	from, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		return reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+	}
+	// 送信元がSIZEでサイズを伝えてきた場合は、本文を受け取る前に断ります。
+	if size, err := strconv.ParseInt(params["SIZE"], 10, 64); err == nil && size > s.MaxBytes {
+		return reply(552, "5.3.4 Message too big")
+	}
+	// 空の送信元(<>)はエラーの通知のメールで、"<>"としてBackendに渡します。
+	sess.from = from
+	if from == "" {
+		sess.from = "<>"
+	}
+	return reply(250, "2.1.0 OK")
+}
+
+func (s *Server) rcpt(ctx context.Context, sess *session, arg string, reply func(int, string) error) error {
+	if sess.from == "" {
+		return reply(503, "5.5.1 Need MAIL command")
+	}
+	if len(sess.to) >= s.MaxRecipients {
+		return reply(452, "4.5.3 Too many recipients")
+	}
+	to, _, ok := parsePath(arg, "TO:")
+	if !ok || to == "" {
+		return reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+	}
+	if err := s.Backend.Rcpt(ctx, sess.from, to); err != nil {
+		return replyError(reply, err)
+	}
+	sess.to = append(sess.to, to)
+	return reply(250, "2.1.5 OK")
+}
+
+func (s *Server) data(ctx context.Context, sess *session, tp *textproto.Conn, conn net.Conn, reply func(int, string) error) error {
+	if len(sess.to) == 0 {
+		return reply(503, "5.5.1 Need RCPT command")
+	}
+	if err := reply(354, "Start mail input; end with <CRLF>.<CRLF>"); err != nil {
+		return err
+	}
+	// 本文は最大サイズまで読み込み、超えた分は読み捨ててから552で断ります(途中で切ると送信元が再送を繰り返すため)。
+	conn.SetReadDeadline(time.Now().Add(10 * s.Timeout))
+	r := tp.DotReader()
+	data, err := io.ReadAll(io.LimitReader(r, s.MaxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > s.MaxBytes {
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return err
+		}
+		sess.reset()
+		return reply(552, "5.3.4 Message too big")
+	}
+	from, to := sess.from, sess.to
+	sess.reset()
+	if err := s.Backend.Data(ctx, from, to, data); err != nil {
+		return replyError(reply, err)
+	}
+	return reply(250, "2.0.0 OK: queued")
+}
+
+// replyErrorはBackendのエラーを応答にする
+func replyError(reply func(int, string) error, err error) error {
+	var smtpErr *Error
+	if errors.As(err, &smtpErr) {
+		return reply(smtpErr.Code, smtpErr.Message)
+	}
+	log.Println("smtpd:", err)
+	return reply(451, "4.3.0 Temporary failure, try again later")
+}
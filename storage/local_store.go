@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type localStore struct {
+	root string
+}
+
+// NewLocalStoreはrootディレクトリの下にファイルを保存するBlobStoreを返す
+func NewLocalStore(root string) (BlobStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &localStore{root}, nil
+}
+
+// pathはキーをファイルのパスに変換する
+// キーに".."が含まれていてもrootの外に出られないようにチェックします。
+func (ls *localStore) path(key string) (string, error) {
+	p := filepath.Join(ls.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, filepath.Clean(ls.root)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return p, nil
+}
+
+func (ls *localStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	// 書き込み途中のファイルが読まれないように、一時ファイルに書いてからリネームします。
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+type localBlob struct {
+	*os.File
+	size int64
+}
+
+func (lb *localBlob) Size() int64 {
+	return lb.size
+}
+
+func (ls *localStore) Open(ctx context.Context, key string) (Blob, error) {
+	p, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &localBlob{f, info.Size()}, nil
+}
+
+func (ls *localStore) Delete(ctx context.Context, key string) error {
+	p, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
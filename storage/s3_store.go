@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3ConfigはS3互換ストレージの接続情報
+// MinIOなどのローカルの代替サーバーでも動くように、パス形式(endpoint/bucket/key)でアクセスします。
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+type s3Store struct {
+	config S3Config
+	client *http.Client
+}
+
+// unsignedPayloadはアップロードする内容をメモリに溜めずに送るための署名の指定
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// NewS3StoreはS3互換のストレージにファイルを保存するBlobStoreを返す
+// AWS SDKは使わずに、署名バージョン4(SigV4)の署名を自前で行います。
+func NewS3Store(config S3Config) BlobStore {
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	return &s3Store{config, &http.Client{Timeout: 5 * time.Minute}}
+}
+
+func (ss *s3Store) objectURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return ss.config.Endpoint + "/" + url.PathEscape(ss.config.Bucket) + "/" + strings.Join(segments, "/")
+}
+
+func (ss *s3Store) newRequest(ctx context.Context, method string, key string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, method, ss.objectURL(key), body)
+}
+
+func (ss *s3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := ss.newRequest(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	ss.sign(req, unsignedPayload, time.Now())
+	res, err := ss.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return checkResponse(res)
+}
+
+func (ss *s3Store) Open(ctx context.Context, key string) (Blob, error) {
+	// サイズを知るために最初にHEADリクエストを送ります。
+	req, err := ss.newRequest(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	ss.sign(req, emptyHash, time.Now())
+	res, err := ss.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return nil, err
+	}
+	return &s3Blob{ctx: ctx, store: ss, key: key, size: res.ContentLength}, nil
+}
+
+func (ss *s3Store) Delete(ctx context.Context, key string) error {
+	req, err := ss.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	ss.sign(req, emptyHash, time.Now())
+	res, err := ss.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func checkResponse(res *http.Response) error {
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("s3: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// s3BlobはSeekした位置からRangeリクエストで読み出すBlob
+// Readが呼ばれた時に初めてGETリクエストを送り、Seekで位置が変わったら接続を張り直します。
+type s3Blob struct {
+	ctx    context.Context
+	store  *s3Store
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (sb *s3Blob) Size() int64 {
+	return sb.size
+}
+
+func (sb *s3Blob) Read(p []byte) (int, error) {
+	if sb.offset >= sb.size {
+		return 0, io.EOF
+	}
+	if sb.body == nil {
+		req, err := sb.store.newRequest(sb.ctx, http.MethodGet, sb.key, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(sb.offset, 10)+"-")
+		sb.store.sign(req, emptyHash, time.Now())
+		res, err := sb.store.client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		if err := checkResponse(res); err != nil {
+			res.Body.Close()
+			return 0, err
+		}
+		sb.body = res.Body
+	}
+	n, err := sb.body.Read(p)
+	sb.offset += int64(n)
+	return n, err
+}
+
+func (sb *s3Blob) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = sb.offset + offset
+	case io.SeekEnd:
+		abs = sb.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+	if abs != sb.offset && sb.body != nil {
+		sb.body.Close()
+		sb.body = nil
+	}
+	sb.offset = abs
+	return abs, nil
+}
+
+func (sb *s3Blob) Close() error {
+	if sb.body != nil {
+		return sb.body.Close()
+	}
+	return nil
+}
+
+// emptyHashは空のリクエストボディーのSHA-256
+var emptyHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+// signはリクエストにAWS署名バージョン4のAuthorizationヘッダーを付ける
+func (ss *s3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	// 署名に含めるヘッダー(hostとx-amz-*)を名前順に並べる
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + ss.config.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+ss.config.SecretKey), day)
+	key = hmacSHA256(key, ss.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+ss.config.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFoundは指定したキーのファイルが存在しない場合のエラー
+var ErrNotFound = errors.New("blob not found")
+
+// Blobはストレージから読み出すファイル
+// Seekできるので、http.ServeContentでRangeリクエストにそのまま応答できます。
+type Blob interface {
+	io.ReadSeeker
+	io.Closer
+	Size() int64
+}
+
+// BlobStoreは添付ファイルの保存先を抽象化したインターフェース
+// ローカルのファイルシステム(NewLocalStore)とS3互換のストレージ(NewS3Store)の実装があります。
+type BlobStore interface {
+	// Putはrの内容をkeyで保存する(sizeはrのバイト数)
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Openはkeyのファイルを読み出す。存在しない場合はErrNotFoundを返す
+	Open(ctx context.Context, key string) (Blob, error)
+	// Deleteはkeyのファイルを削除する。存在しない場合もエラーにはしない
+	Delete(ctx context.Context, key string) error
+}
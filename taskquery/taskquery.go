@@ -0,0 +1,339 @@
+package taskquery
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// taskqueryはタスクの一覧を絞り込むための小さな検索クエリの言語
+// 例: status:open priority>=2 tag:work due<7d "free text"
+//
+//	query   = or
+//	or      = and { "OR" and }
+//	and     = unary { ["AND"] unary }      (空白で区切った条件は全て満たすタスク)
+//	unary   = ( "-" | "NOT" ) unary | primary
+//	primary = "(" or ")" | term
+//	term    = key op value | word | "quoted text"
+//	op      = ":" | "=" | "!=" | ">" | ">=" | "<" | "<="
+//
+// keyとopの無い単語と引用符で囲んだ文字列はタイトルの部分一致(自由なテキスト)として扱います。
+// どのキーがタスクのどの項目になるかは、パースした結果をSQLに変換する側(taskRepository)で決めます。
+
+// Errorはクエリの解釈に失敗したエラーで、Posは問題のある箇所の位置(1から始まる文字数)
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("query error at position %d: %s", e.Pos, e.Msg)
+}
+
+// Errorfは位置posを指すクエリのエラーを作る
+func Errorf(pos int, format string, args ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Nodeはパースしたクエリの構文木の要素(And・Or・Not・Term・Text)
+type Node interface {
+	node()
+}
+
+// Andは全ての条件を満たす
+type And struct {
+	Nodes []Node
+}
+
+// Orはどれかの条件を満たす
+type Or struct {
+	Nodes []Node
+}
+
+// Notは条件を満たさない
+type Not struct {
+	Node Node
+}
+
+// Termはkey op valueの形の条件(例: priority>=2)
+type Term struct {
+	Key   string
+	Op    string
+	Value string
+	// Quotedは値が引用符で囲まれていたかどうか(引用符で囲んだnoneは「値が無い」ではなく文字列のnone)
+	Quoted bool
+	// KeyPosとValuePosはエラーの位置を示すための、キーと値の位置
+	KeyPos   int
+	ValuePos int
+}
+
+// Textはタイトルの部分一致で絞り込む自由なテキスト
+type Text struct {
+	Value string
+	Pos   int
+}
+
+func (And) node()  {}
+func (Or) node()   {}
+func (Not) node()  {}
+func (Term) node() {}
+func (Text) node() {}
+
+// 条件の演算子
+const (
+	OpEq    = ":"
+	OpEqual = "="
+	OpNe    = "!="
+	OpGt    = ">"
+	OpGte   = ">="
+	OpLt    = "<"
+	OpLte   = "<="
+)
+
+const (
+	tokenWord = iota
+	tokenQuoted
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenMinus
+	tokenEOF
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+	// spaceは直前に空白があったかどうか(key:valueの間に空白を入れられないようにするため)
+	space bool
+}
+
+// tokenizeはクエリを字句に分割する
+func tokenize(input string) ([]token, error) {
+	runes := []rune(input)
+	tokens := []token{}
+	space := true
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			i++
+			continue
+		case r == '"':
+			// 引用符の中では\"と\\でエスケープできます。
+			var b strings.Builder
+			j := i + 1
+			closed := false
+			for j < len(runes) {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					b.WriteRune(runes[j+1])
+					j += 2
+					continue
+				}
+				if runes[j] == '"' {
+					closed = true
+					break
+				}
+				b.WriteRune(runes[j])
+				j++
+			}
+			if !closed {
+				return nil, Errorf(pos, "unterminated quoted string")
+			}
+			tokens = append(tokens, token{tokenQuoted, b.String(), pos, space})
+			i = j + 1
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", pos, space})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", pos, space})
+			i++
+		case r == '-' && space:
+			// 単語の先頭の-は否定(値の中の-はそのまま値の一部)
+			tokens = append(tokens, token{tokenMinus, "-", pos, space})
+			i++
+		case strings.ContainsRune(":=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != ':' && r != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, Errorf(pos, "unexpected character %q", r)
+			}
+			tokens = append(tokens, token{tokenOp, op, pos, space})
+			i += len([]rune(op))
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune(`"():=!<>`, runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[i:j]), pos, space})
+			i = j
+		}
+		space = false
+	}
+	tokens = append(tokens, token{tokenEOF, "", len(runes) + 1, space})
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+// keywordは大文字のAND・OR・NOTを演算子として扱う(小文字の場合は普通の単語)
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && t.value == word && !p.isTermKey()
+}
+
+// isTermKeyは今の単語の直後に空白を挟まずに演算子が続くかどうか
+func (p *parser) isTermKey() bool {
+	next := p.tokens[p.i+1]
+	return next.kind == tokenOp && !next.space
+}
+
+// Parseはクエリをパースして構文木を返す(空のクエリの場合はnil)
+func Parse(input string) (Node, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, nil
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, Errorf(t.pos, "unexpected %q", t.value)
+	}
+	return node, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	nodes := []Node{}
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		if !p.keyword("OR") {
+			break
+		}
+		p.next()
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return Or{Nodes: nodes}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	nodes := []Node{}
+	for {
+		if p.keyword("AND") {
+			p.next()
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		t := p.peek()
+		if t.kind == tokenEOF || t.kind == tokenRParen || p.keyword("OR") {
+			break
+		}
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return And{Nodes: nodes}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().kind == tokenMinus || p.keyword("NOT") {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenLParen:
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, Errorf(closing.pos, "missing closing parenthesis for position %d", t.pos)
+		}
+		return node, nil
+	case tokenQuoted:
+		p.next()
+		return Text{Value: t.value, Pos: t.pos}, nil
+	case tokenWord:
+		if !p.isTermKey() {
+			p.next()
+			return Text{Value: t.value, Pos: t.pos}, nil
+		}
+		p.next()
+		op := p.next()
+		value := p.next()
+		if value.space || (value.kind != tokenWord && value.kind != tokenQuoted) {
+			return nil, Errorf(value.pos, "value is required after %s%s", t.value, op.value)
+		}
+		return Term{Key: t.value, Op: op.value, Value: value.value, Quoted: value.kind == tokenQuoted, KeyPos: t.pos, ValuePos: value.pos}, nil
+	case tokenEOF:
+		return nil, Errorf(t.pos, "unexpected end of query")
+	}
+	return nil, Errorf(t.pos, "unexpected %q", t.value)
+}
+
+// Termsは構文木の中の全ての条件(Term)を順番に返す
+func Terms(node Node) []Term {
+	terms := []Term{}
+	var walk func(Node)
+	walk = func(n Node) {
+		switch v := n.(type) {
+		case And:
+			for _, c := range v.Nodes {
+				walk(c)
+			}
+		case Or:
+			for _, c := range v.Nodes {
+				walk(c)
+			}
+		case Not:
+			walk(v.Node)
+		case Term:
+			terms = append(terms, v)
+		}
+	}
+	walk(node)
+	return terms
+}
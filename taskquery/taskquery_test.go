@@ -0,0 +1,155 @@
+package taskquery
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// formatは構文木を比べやすいS式の文字列にする
+// 例: (or (and status:open "a b") (not tag:work))
+func format(node Node) string {
+	switch n := node.(type) {
+	case nil:
+		return "<nil>"
+	case And:
+		return formatList("and", n.Nodes)
+	case Or:
+		return formatList("or", n.Nodes)
+	case Not:
+		return "(not " + format(n.Node) + ")"
+	case Term:
+		value := n.Value
+		if n.Quoted {
+			value = strconv.Quote(value)
+		}
+		return n.Key + n.Op + value
+	case Text:
+		return strconv.Quote(n.Value)
+	}
+	return "?"
+}
+
+func formatList(op string, nodes []Node) string {
+	parts := []string{op}
+	for _, n := range nodes {
+		parts = append(parts, format(n))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", "<nil>"},
+		{"only spaces", "   ", "<nil>"},
+		{"free text", "milk", `"milk"`},
+		{"term", "status:open", "status:open"},
+		{"operators", "a=1 b!=2 c>3 d>=4 e<5 f<=6", "(and a=1 b!=2 c>3 d>=4 e<5 f<=6)"},
+		{"implicit and", "status:open priority>=2 due<7d", "(and status:open priority>=2 due<7d)"},
+		{"explicit and", "a AND b", `(and "a" "b")`},
+		{"and binds tighter than or", "a b OR c", `(or (and "a" "b") "c")`},
+		{"or then and", "a OR b c", `(or "a" (and "b" "c"))`},
+		{"chained or", "a OR b OR c", `(or "a" "b" "c")`},
+		{"parentheses", "(a OR b) c", `(and (or "a" "b") "c")`},
+		{"nested parentheses", "((a))", `"a"`},
+		{"minus", "-tag:work", "(not tag:work)"},
+		{"not keyword", "NOT status:done", "(not status:done)"},
+		{"double negation", "- -a", `(not (not "a"))`},
+		{"not binds tighter than and", "-a b", `(and (not "a") "b")`},
+		{"negated group", "-(a OR b)", `(not (or "a" "b"))`},
+		{"lowercase keywords are words", "a or b and c not d", `(and "a" "or" "b" "and" "c" "not" "d")`},
+		{"keyword as key", "OR:x", "OR:x"},
+		{"minus inside value", "due<-3d", "due<-3d"},
+		{"minus inside word", "e-mail", `"e-mail"`},
+		{"quoted text", `"buy milk"`, `"buy milk"`},
+		{"quoted value", `title:"buy milk"`, `title:"buy milk"`},
+		{"quoted none", `tag:"none"`, `tag:"none"`},
+		{"unquoted none", "tag:none", "tag:none"},
+		{"escaped quote", `"say \"hi\""`, `"say \"hi\""`},
+		{"escaped backslash", `"a\\b"`, `"a\\b"`},
+		{"unicode", "タグ:仕事 買い物", `(and タグ:仕事 "買い物")`},
+		{"custom field id", "field.12>=3", "field.12>=3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if got := format(node); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	node, err := Parse(`  status:open title:"x y"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	terms := Terms(node)
+	if len(terms) != 2 {
+		t.Fatalf("got %d terms, want 2", len(terms))
+	}
+	want := []struct{ keyPos, valuePos int }{{3, 10}, {15, 21}}
+	for i, w := range want {
+		if terms[i].KeyPos != w.keyPos || terms[i].ValuePos != w.valuePos {
+			t.Errorf("term %d: got key %d value %d, want key %d value %d", i, terms[i].KeyPos, terms[i].ValuePos, w.keyPos, w.valuePos)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   int
+		msg   string
+	}{
+		{"unterminated quote", `a "abc`, 3, "unterminated quoted string"},
+		{"bang without equals", "a !b", 3, `unexpected character '!'`},
+		{"missing value", "status:", 8, "value is required after status:"},
+		{"space before value", "status: open", 9, "value is required after status:"},
+		{"operator as value", "due<<3", 5, "value is required after due<"},
+		{"missing closing parenthesis", "(a", 3, "missing closing parenthesis for position 1"},
+		{"unexpected closing parenthesis", "a)", 2, `unexpected ")"`},
+		{"dangling or", "a OR", 5, "unexpected end of query"},
+		{"dangling minus", "a -", 4, "unexpected end of query"},
+		{"empty group", "()", 2, `unexpected ")"`},
+		{"leading operator", ":a", 1, `unexpected ":"`},
+		// 位置はバイト数ではなく文字数で数えます。
+		{"position counts runes", `タスク "x`, 5, "unterminated quoted string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var qerr *Error
+			if !errors.As(err, &qerr) {
+				t.Fatalf("Parse(%q): got %v, want *Error", tt.input, err)
+			}
+			if qerr.Pos != tt.pos || qerr.Msg != tt.msg {
+				t.Errorf("Parse(%q): got position %d %q, want position %d %q", tt.input, qerr.Pos, qerr.Msg, tt.pos, tt.msg)
+			}
+		})
+	}
+}
+
+func TestTerms(t *testing.T) {
+	node, err := Parse(`a:1 (b:2 OR -c:3) "text"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for _, term := range Terms(node) {
+		keys = append(keys, term.Key)
+	}
+	if got := strings.Join(keys, ","); got != "a,b,c" {
+		t.Errorf("Terms = %s, want a,b,c", got)
+	}
+}
@@ -0,0 +1,123 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNoTenantはテナントごとに分けるテーブルに、テナントを指定せずにアクセスした場合のエラー
+var ErrNoTenant = errors.New("tenant is not set")
+
+type contextKey struct{}
+
+// Tenantはリクエストの中で有効になっているテナント
+// OrganizationIdが0の場合は、どの組織にも属さない個人のタスク(organization_idがNULL)を扱います。
+type Tenant struct {
+	OrganizationId uint
+	// Systemはスケジューラーなど、全てのテナントを横断して処理するバックグラウンドの処理
+	System bool
+}
+
+// WithOrganizationはctxに有効な組織を設定する(orgIdが0の場合は個人のタスク)
+func WithOrganization(ctx context.Context, orgId uint) context.Context {
+	return context.WithValue(ctx, contextKey{}, Tenant{OrganizationId: orgId})
+}
+
+// WithSystemはctxをテナントで絞り込まないバックグラウンドの処理用にする
+func WithSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, Tenant{System: true})
+}
+
+// FromContextはctxに設定されているテナントを返す
+func FromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(contextKey{}).(Tenant)
+	return t, ok
+}
+
+// OrganizationIdOrNilはテナントの組織のIDを、organization_idのカラムに保存する値で返す(個人のタスクの場合はnil)
+func (t Tenant) OrganizationIdOrNil() *uint {
+	if t.OrganizationId == 0 {
+		return nil
+	}
+	id := t.OrganizationId
+	return &id
+}
+
+// Registerはtablesに指定したテーブルへのクエリを、ctxのテナントで自動的に絞り込むコールバックを登録する
+// 対象のテーブルにはorganization_idのカラムが必要です。
+// SELECT・UPDATE・DELETEにはorganization_idの条件を追加し、INSERTではorganization_idを設定します。
+// ctxにテナントが設定されていない場合はErrNoTenantのエラーにするので、絞り込み忘れで他の組織のデータが見えることはありません。
+func Register(db *gorm.DB, tables ...string) error {
+	scoped := map[string]bool{}
+	for _, t := range tables {
+		scoped[t] = true
+	}
+	scope := func(db *gorm.DB) {
+		if db.Statement.Schema == nil || !scoped[db.Statement.Table] {
+			return
+		}
+		t, ok := FromContext(db.Statement.Context)
+		if !ok {
+			db.AddError(ErrNoTenant)
+			return
+		}
+		if t.System {
+			return
+		}
+		column := clause.Column{Table: clause.CurrentTable, Name: "organization_id"}
+		var expr clause.Expression = clause.Eq{Column: column, Value: t.OrganizationId}
+		if t.OrganizationId == 0 {
+			expr = clause.Eq{Column: column, Value: nil}
+		}
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{expr}})
+	}
+	assign := func(db *gorm.DB) {
+		if db.Statement.Schema == nil || !scoped[db.Statement.Table] {
+			return
+		}
+		t, ok := FromContext(db.Statement.Context)
+		if !ok {
+			db.AddError(ErrNoTenant)
+			return
+		}
+		if t.System {
+			return
+		}
+		field := db.Statement.Schema.LookUpField("organization_id")
+		if field == nil {
+			return
+		}
+		value := t.OrganizationIdOrNil()
+		rv := db.Statement.ReflectValue
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				if err := field.Set(db.Statement.Context, rv.Index(i), value); err != nil {
+					db.AddError(err)
+				}
+			}
+		case reflect.Struct:
+			if err := field.Set(db.Statement.Context, rv, value); err != nil {
+				db.AddError(err)
+			}
+		}
+	}
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:query", scope); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenant:row", scope); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:update", scope); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:delete", scope); err != nil {
+		return err
+	}
+	return callbacks.Create().Before("gorm:create").Register("tenant:create", assign)
+}
@@ -0,0 +1,87 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	// 対応する画像形式のデコーダーを登録しておきます。
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// maxPixelsはデコードする画像の最大の画素数(巨大な画像でメモリを使い果たさないための制限)
+const maxPixels = 40_000_000
+
+// Generateは画像を縦横maxSize以内に縮小したPNGを作成する
+// 外部のライブラリを使わずに、縮小先の1画素に対応する元画像の範囲の平均色を求める方法で縮小します。
+func Generate(r io.ReadSeeker, maxSize int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, image.ErrFormat
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	dst := resize(src, maxSize)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func resize(src image.Image, maxSize int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSize && h <= maxSize {
+		return src
+	}
+	// 縦横比を保ったまま長い方の辺をmaxSizeに合わせる
+	dw, dh := maxSize, h*maxSize/w
+	if h > w {
+		dw, dh = w*maxSize/h, maxSize
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := b.Min.Y + y*h/dh
+		y1 := b.Min.Y + (y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0 := b.Min.X + x*w/dw
+			x1 := b.Min.X + (x+1)*w/dw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			// RGBAは乗算済みアルファの値なので、NRGBAに戻してから書き込みます。
+			c := color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)}
+			dst.Set(x, y, color.NRGBAModel.Convert(c))
+		}
+	}
+	return dst
+}
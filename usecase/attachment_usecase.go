@@ -0,0 +1,270 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/storage"
+	"go-rest-api/thumbnail"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrAttachmentTooLargeはアップロードされたファイルがAttachmentConfig.MaxBytesより大きい場合のエラー
+var ErrAttachmentTooLarge = errors.New("file is too large")
+
+// ErrAttachmentTypeNotAllowedはファイルの内容から判定したMIMEタイプがAttachmentConfig.AllowedTypesに無い場合のエラー
+var ErrAttachmentTypeNotAllowed = errors.New("file type is not allowed")
+
+// ErrAttachmentNotFoundは添付ファイル(またはそのサムネイル)が存在しない場合のエラー
+var ErrAttachmentNotFound = errors.New("attachment does not exist")
+
+type IAttachmentUsecase interface {
+	GetAttachments(ctx context.Context, userId uint, taskId uint) ([]model.AttachmentResponse, error)
+	UploadAttachment(ctx context.Context, userId uint, taskId uint, fileName string, file io.ReadSeeker, size int64) (model.AttachmentResponse, error)
+	// OpenAttachmentは添付ファイル(thumbnailがtrueの場合はサムネイル)の中身を読み出す
+	// 返り値のBlobは呼び出し側でCloseする必要があります。
+	OpenAttachment(ctx context.Context, userId uint, taskId uint, attachmentId uint, thumbnail bool) (model.Attachment, storage.Blob, error)
+	DeleteAttachment(ctx context.Context, userId uint, taskId uint, attachmentId uint) error
+}
+
+// AttachmentConfigは添付ファイルのサイズとMIMEタイプの制限
+type AttachmentConfig struct {
+	MaxBytes     int64
+	AllowedTypes []string
+}
+
+type attachmentUsecase struct {
+	ar     repository.IAttachmentRepository
+	ps     permission.IPermissionService
+	bs     storage.BlobStore
+	config AttachmentConfig
+}
+
+// thumbnailSizeはサムネイルの長い方の辺のピクセル数
+const thumbnailSize = 256
+
+func NewAttachmentUsecase(ar repository.IAttachmentRepository, ps permission.IPermissionService, bs storage.BlobStore, config AttachmentConfig) IAttachmentUsecase {
+	return &attachmentUsecase{ar, ps, bs, config}
+}
+
+func newAttachmentResponse(attachment model.Attachment) model.AttachmentResponse {
+	return model.AttachmentResponse{
+		ID:           attachment.ID,
+		TaskId:       attachment.TaskId,
+		UserId:       attachment.UserId,
+		FileName:     attachment.FileName,
+		ContentType:  attachment.ContentType,
+		Size:         attachment.Size,
+		SHA256:       attachment.SHA256,
+		HasThumbnail: attachment.ThumbnailKey != "",
+		CreatedAt:    attachment.CreatedAt,
+	}
+}
+
+// checkTaskは添付ファイルを読み出すタスクにユーザーがアクセスできるか確認する
+// アップロードと削除にはeditor以上の権限(CanEditTask)が必要です。
+func (au *attachmentUsecase) checkTask(ctx context.Context, userId uint, taskId uint) error {
+	return au.ps.CanViewTask(ctx, userId, taskId)
+}
+
+func (au *attachmentUsecase) GetAttachments(ctx context.Context, userId uint, taskId uint) ([]model.AttachmentResponse, error) {
+	if err := au.checkTask(ctx, userId, taskId); err != nil {
+		return nil, err
+	}
+	attachments := []model.Attachment{}
+	if err := au.ar.GetAttachmentsByTask(ctx, &attachments, taskId); err != nil {
+		return nil, err
+	}
+	resAttachments := []model.AttachmentResponse{}
+	for _, v := range attachments {
+		resAttachments = append(resAttachments, newAttachmentResponse(v))
+	}
+	return resAttachments, nil
+}
+
+func (au *attachmentUsecase) UploadAttachment(ctx context.Context, userId uint, taskId uint, fileName string, file io.ReadSeeker, size int64) (model.AttachmentResponse, error) {
+	if err := au.ps.CanEditTask(ctx, userId, taskId); err != nil {
+		return model.AttachmentResponse{}, err
+	}
+	if size > au.config.MaxBytes {
+		return model.AttachmentResponse{}, fmt.Errorf("%w (max %d bytes)", ErrAttachmentTooLarge, au.config.MaxBytes)
+	}
+	// クライアントが送ってくるContent-Typeは信用せずに、ファイルの先頭の内容からMIMEタイプを判定します。
+	contentType, err := detectContentType(file)
+	if err != nil {
+		return model.AttachmentResponse{}, err
+	}
+	if !au.allowed(contentType) {
+		return model.AttachmentResponse{}, fmt.Errorf("%w: %s", ErrAttachmentTypeNotAllowed, contentType)
+	}
+
+	key, err := newStorageKey(taskId)
+	if err != nil {
+		return model.AttachmentResponse{}, err
+	}
+	// 保存しながらSHA-256のハッシュを計算する
+	hasher := sha256.New()
+	if err := au.bs.Put(ctx, key, io.TeeReader(file, hasher), size, contentType); err != nil {
+		return model.AttachmentResponse{}, err
+	}
+	attachment := model.Attachment{
+		FileName:    sanitizeFileName(fileName),
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hex.EncodeToString(hasher.Sum(nil)),
+		StorageKey:  key,
+		TaskId:      taskId,
+		UserId:      userId,
+	}
+	// 画像の場合はサムネイルも作成する(作成に失敗してもアップロード自体は成功にします)
+	if strings.HasPrefix(contentType, "image/") {
+		if _, err := file.Seek(0, io.SeekStart); err == nil {
+			if thumb, err := thumbnail.Generate(file, thumbnailSize); err == nil {
+				thumbKey := key + ".thumb.png"
+				if err := au.bs.Put(ctx, thumbKey, bytes.NewReader(thumb), int64(len(thumb)), "image/png"); err == nil {
+					attachment.ThumbnailKey = thumbKey
+				}
+			}
+		}
+	}
+	if err := au.ar.CreateAttachment(ctx, &attachment); err != nil {
+		// メタデータの保存に失敗した場合は、保存したファイルも削除しておきます。
+		deleteAttachmentBlobs(au.bs, attachment)
+		return model.AttachmentResponse{}, err
+	}
+	return newAttachmentResponse(attachment), nil
+}
+
+func (au *attachmentUsecase) OpenAttachment(ctx context.Context, userId uint, taskId uint, attachmentId uint, thumbnail bool) (model.Attachment, storage.Blob, error) {
+	if err := au.checkTask(ctx, userId, taskId); err != nil {
+		return model.Attachment{}, nil, err
+	}
+	attachment := model.Attachment{}
+	if err := au.getAttachment(ctx, &attachment, taskId, attachmentId); err != nil {
+		return model.Attachment{}, nil, err
+	}
+	key := attachment.StorageKey
+	if thumbnail {
+		if attachment.ThumbnailKey == "" {
+			return model.Attachment{}, nil, ErrAttachmentNotFound
+		}
+		key = attachment.ThumbnailKey
+	}
+	blob, err := au.bs.Open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return model.Attachment{}, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return model.Attachment{}, nil, err
+	}
+	return attachment, blob, nil
+}
+
+func (au *attachmentUsecase) DeleteAttachment(ctx context.Context, userId uint, taskId uint, attachmentId uint) error {
+	if err := au.ps.CanEditTask(ctx, userId, taskId); err != nil {
+		return err
+	}
+	attachment := model.Attachment{}
+	if err := au.getAttachment(ctx, &attachment, taskId, attachmentId); err != nil {
+		return err
+	}
+	// 中身とサムネイルは、行の削除と同じトランザクションで登録したキーをBlobSweeperが削除します。
+	return au.ar.DeleteAttachment(ctx, taskId, attachmentId)
+}
+
+// getAttachmentはタスクの添付ファイルを取得する(存在しない場合はErrAttachmentNotFound)
+func (au *attachmentUsecase) getAttachment(ctx context.Context, attachment *model.Attachment, taskId uint, attachmentId uint) error {
+	err := au.ar.GetAttachmentById(ctx, attachment, taskId, attachmentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAttachmentNotFound
+	}
+	return err
+}
+
+// deleteAttachmentBlobsは添付ファイルの中身とサムネイルをBlobStoreから削除する
+// 行を作成する前に保存した中身を、作成に失敗した時に片付けるのに使います。
+func deleteAttachmentBlobs(bs storage.BlobStore, attachment model.Attachment) error {
+	ctx := context.Background()
+	if attachment.ThumbnailKey != "" {
+		if err := bs.Delete(ctx, attachment.ThumbnailKey); err != nil {
+			return err
+		}
+	}
+	if attachment.StorageKey == "" {
+		return nil
+	}
+	return bs.Delete(ctx, attachment.StorageKey)
+}
+
+func (au *attachmentUsecase) allowed(contentType string) bool {
+	for _, t := range au.config.AllowedTypes {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// detectContentTypeはファイルの先頭512バイトからMIMEタイプを判定し、読み込み位置を先頭に戻す
+func detectContentType(file io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return "", err
+	}
+	return mediaType, nil
+}
+
+// newStorageKeyは推測されにくいランダムなキーを作成する
+func newStorageKey(taskId uint) (string, error) {
+	name, err := randomHex()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tasks/%d/%s", taskId, name), nil
+}
+
+// randomHexはストレージのキーに使うランダムな16バイトを16進数の文字列で返す
+func randomHex() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sanitizeFileNameはパスの区切り文字や制御文字をファイル名から取り除く
+func sanitizeFileName(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" {
+		return "file"
+	}
+	return name
+}
@@ -0,0 +1,272 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+)
+
+// ErrWipLimitExceededは移動先の列のタスクの数がWIPの上限に達している場合のエラー
+var ErrWipLimitExceeded = errors.New("wip limit of the column is reached")
+
+type IBoardUsecase interface {
+	GetBoards(ctx context.Context, userId uint) ([]model.BoardResponse, error)
+	// GetBoardByIdはボードの列と、列ごとのタスクを順番に並べて返す
+	GetBoardById(ctx context.Context, userId uint, boardId uint) (model.BoardResponse, error)
+	CreateBoard(ctx context.Context, board model.Board) (model.BoardResponse, error)
+	UpdateBoard(ctx context.Context, board model.Board, userId uint, boardId uint) (model.BoardResponse, error)
+	DeleteBoard(ctx context.Context, userId uint, boardId uint) error
+	CreateColumn(ctx context.Context, column model.BoardColumn, userId uint, boardId uint) (model.BoardColumnResponse, error)
+	UpdateColumn(ctx context.Context, column model.BoardColumn, userId uint, boardId uint, columnId uint) (model.BoardColumnResponse, error)
+	DeleteColumn(ctx context.Context, userId uint, boardId uint, columnId uint) error
+	MoveColumn(ctx context.Context, req model.BoardColumnMoveRequest, userId uint, boardId uint, columnId uint) (model.BoardColumnResponse, error)
+	// MoveTaskはタスクをボードの列に移動する(WIPの上限を超える場合はErrWipLimitExceeded)
+	MoveTask(ctx context.Context, req model.BoardTaskMoveRequest, userId uint, boardId uint, taskId uint) (model.TaskResponse, error)
+	// RemoveTaskはタスクをボードの列から外す
+	RemoveTask(ctx context.Context, userId uint, boardId uint, taskId uint) error
+}
+
+type boardUsecase struct {
+	br repository.IBoardRepository
+	// 列に移動するタスクを読むためにタスクのリポジトリも使います。
+	tr repository.ITaskRepository
+	ps permission.IPermissionService
+	bv validator.IBoardValidator
+}
+
+func NewBoardUsecase(br repository.IBoardRepository, tr repository.ITaskRepository, ps permission.IPermissionService, bv validator.IBoardValidator) IBoardUsecase {
+	return &boardUsecase{br, tr, ps, bv}
+}
+
+func newBoardResponse(board model.Board, role string) model.BoardResponse {
+	return model.BoardResponse{
+		ID:             board.ID,
+		Name:           board.Name,
+		ProjectId:      board.ProjectId,
+		OrganizationId: board.OrganizationId,
+		UserId:         board.UserId,
+		Role:           role,
+		CreatedAt:      board.CreatedAt,
+		UpdatedAt:      board.UpdatedAt,
+	}
+}
+
+func newBoardColumnResponse(column model.BoardColumn) model.BoardColumnResponse {
+	return model.BoardColumnResponse{
+		ID:       column.ID,
+		Name:     column.Name,
+		Position: column.Position,
+		WipLimit: column.WipLimit,
+		Tasks:    []model.TaskResponse{},
+	}
+}
+
+func (bu *boardUsecase) GetBoards(ctx context.Context, userId uint) ([]model.BoardResponse, error) {
+	boards := []model.Board{}
+	if err := bu.br.GetBoards(ctx, &boards, bu.ps.VisibleBoards(userId)); err != nil {
+		return nil, err
+	}
+	resBoards := []model.BoardResponse{}
+	for _, v := range boards {
+		role, err := bu.ps.BoardRole(ctx, userId, v.ID)
+		if err != nil {
+			return nil, err
+		}
+		resBoards = append(resBoards, newBoardResponse(v, role))
+	}
+	return resBoards, nil
+}
+
+func (bu *boardUsecase) GetBoardById(ctx context.Context, userId uint, boardId uint) (model.BoardResponse, error) {
+	role, err := bu.ps.BoardRole(ctx, userId, boardId)
+	if err != nil {
+		return model.BoardResponse{}, err
+	}
+	board := model.Board{}
+	if err := bu.br.GetBoardById(ctx, &board, boardId); err != nil {
+		return model.BoardResponse{}, err
+	}
+	resBoard := newBoardResponse(board, role)
+	resBoard.Columns = []model.BoardColumnResponse{}
+	columns := []model.BoardColumn{}
+	if err := bu.br.GetColumns(ctx, &columns, boardId); err != nil {
+		return model.BoardResponse{}, err
+	}
+	if len(columns) == 0 {
+		return resBoard, nil
+	}
+	// 列の数に関わらず、全ての列のタスクとタスクの数はそれぞれ1回のクエリでまとめて取得します。
+	columnIds := []uint{}
+	for _, v := range columns {
+		columnIds = append(columnIds, v.ID)
+	}
+	tasks := []model.Task{}
+	if err := bu.br.GetColumnTasks(ctx, &tasks, columnIds, bu.ps.VisibleTasks(userId)); err != nil {
+		return model.BoardResponse{}, err
+	}
+	counts, err := bu.br.CountColumnTasks(ctx, columnIds)
+	if err != nil {
+		return model.BoardResponse{}, err
+	}
+	index := map[uint]int{}
+	for i, v := range columns {
+		index[v.ID] = i
+		resColumn := newBoardColumnResponse(v)
+		resColumn.TaskCount = counts[v.ID]
+		resBoard.Columns = append(resBoard.Columns, resColumn)
+	}
+	for _, v := range tasks {
+		i := index[*v.ColumnId]
+		resBoard.Columns[i].Tasks = append(resBoard.Columns[i].Tasks, newTaskResponse(v))
+	}
+	return resBoard, nil
+}
+
+func (bu *boardUsecase) CreateBoard(ctx context.Context, board model.Board) (model.BoardResponse, error) {
+	if err := bu.bv.BoardValidate(board); err != nil {
+		return model.BoardResponse{}, err
+	}
+	// プロジェクトのボードを作るにはプロジェクトのeditor以上の権限が必要
+	if board.ProjectId != nil {
+		if err := bu.ps.CanEditProject(ctx, board.UserId, *board.ProjectId); err != nil {
+			return model.BoardResponse{}, err
+		}
+	}
+	if err := bu.br.CreateBoard(ctx, &board); err != nil {
+		return model.BoardResponse{}, err
+	}
+	return newBoardResponse(board, model.RoleOwner), nil
+}
+
+func (bu *boardUsecase) UpdateBoard(ctx context.Context, board model.Board, userId uint, boardId uint) (model.BoardResponse, error) {
+	if err := bu.bv.BoardValidate(board); err != nil {
+		return model.BoardResponse{}, err
+	}
+	if err := bu.ps.CanManageBoard(ctx, userId, boardId); err != nil {
+		return model.BoardResponse{}, err
+	}
+	if err := bu.br.UpdateBoard(ctx, &board, boardId); err != nil {
+		return model.BoardResponse{}, err
+	}
+	return newBoardResponse(board, model.RoleOwner), nil
+}
+
+func (bu *boardUsecase) DeleteBoard(ctx context.Context, userId uint, boardId uint) error {
+	if err := bu.ps.CanManageBoard(ctx, userId, boardId); err != nil {
+		return err
+	}
+	if err := bu.br.DeleteBoard(ctx, boardId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bu *boardUsecase) CreateColumn(ctx context.Context, column model.BoardColumn, userId uint, boardId uint) (model.BoardColumnResponse, error) {
+	if err := bu.bv.BoardColumnValidate(column); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	if err := bu.ps.CanEditBoard(ctx, userId, boardId); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	column.BoardId = boardId
+	if err := bu.br.CreateColumn(ctx, &column); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	return newBoardColumnResponse(column), nil
+}
+
+func (bu *boardUsecase) UpdateColumn(ctx context.Context, column model.BoardColumn, userId uint, boardId uint, columnId uint) (model.BoardColumnResponse, error) {
+	if err := bu.bv.BoardColumnValidate(column); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	if err := bu.ps.CanEditBoard(ctx, userId, boardId); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	// WIPの上限を今のタスクの数より小さくすることもできます(新しいタスクを移動できなくなるだけ)。
+	if err := bu.br.UpdateColumn(ctx, &column, boardId, columnId); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	return newBoardColumnResponse(column), nil
+}
+
+func (bu *boardUsecase) DeleteColumn(ctx context.Context, userId uint, boardId uint, columnId uint) error {
+	if err := bu.ps.CanEditBoard(ctx, userId, boardId); err != nil {
+		return err
+	}
+	if err := bu.br.DeleteColumn(ctx, boardId, columnId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bu *boardUsecase) MoveColumn(ctx context.Context, req model.BoardColumnMoveRequest, userId uint, boardId uint, columnId uint) (model.BoardColumnResponse, error) {
+	if req.Before == nil && req.After == nil {
+		return model.BoardColumnResponse{}, fmt.Errorf("before or after is required")
+	}
+	if err := bu.ps.CanEditBoard(ctx, userId, boardId); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	column := model.BoardColumn{}
+	if err := bu.br.MoveColumn(ctx, &column, boardId, columnId, req.Before, req.After); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	return newBoardColumnResponse(column), nil
+}
+
+// getBoardTaskはボードの列に置くタスクを取得する
+// ボードを見られて、タスクの状態を更新できる(担当者かeditor以上)ユーザーだけがタスクを列の間で動かせます。
+func (bu *boardUsecase) getBoardTask(ctx context.Context, userId uint, boardId uint, taskId uint) (model.Task, error) {
+	if err := bu.ps.CanViewBoard(ctx, userId, boardId); err != nil {
+		return model.Task{}, err
+	}
+	if err := bu.ps.CanUpdateTaskStatus(ctx, userId, taskId); err != nil {
+		return model.Task{}, err
+	}
+	board := model.Board{}
+	if err := bu.br.GetBoardById(ctx, &board, boardId); err != nil {
+		return model.Task{}, err
+	}
+	task := model.Task{}
+	if err := bu.tr.GetTaskById(ctx, &task, taskId); err != nil {
+		return model.Task{}, err
+	}
+	// プロジェクトのボードには、そのプロジェクトのタスクだけを置けます。
+	if board.ProjectId != nil && (task.ProjectId == nil || *task.ProjectId != *board.ProjectId) {
+		return model.Task{}, fmt.Errorf("task does not belong to the project of the board")
+	}
+	return task, nil
+}
+
+func (bu *boardUsecase) MoveTask(ctx context.Context, req model.BoardTaskMoveRequest, userId uint, boardId uint, taskId uint) (model.TaskResponse, error) {
+	if req.ColumnId == 0 {
+		return model.TaskResponse{}, fmt.Errorf("column_id is required")
+	}
+	task, err := bu.getBoardTask(ctx, userId, boardId, taskId)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	admit := func(column model.BoardColumn, count int64) error {
+		if column.WipLimit != nil && count >= int64(*column.WipLimit) {
+			return ErrWipLimitExceeded
+		}
+		return nil
+	}
+	if err := bu.br.MoveTaskToColumn(ctx, &task, boardId, req.ColumnId, req.Before, req.After, admit); err != nil {
+		return model.TaskResponse{}, err
+	}
+	return newTaskResponse(task), nil
+}
+
+func (bu *boardUsecase) RemoveTask(ctx context.Context, userId uint, boardId uint, taskId uint) error {
+	if _, err := bu.getBoardTask(ctx, userId, boardId, taskId); err != nil {
+		return err
+	}
+	if err := bu.br.RemoveTaskFromBoard(ctx, boardId, taskId); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// CalDAVの条件付きリクエスト(If-Match・If-None-Match)と、リソースの名前のエラー
+var (
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrResourceConflict   = errors.New("resource name is used by another task")
+)
+
+// ICalDAVUsecaseはタスクをCalDAVのカレンダー(VTODOのコレクション)として扱う
+// タスクの取得・作成・更新・削除と権限の確認は全てITaskUsecaseに任せて、
+// ここではカレンダーとリソースの名前・UID・ETagの対応だけを扱います。
+type ICalDAVUsecase interface {
+	// GetCalendarsは自分のタスクのカレンダーと、見えるプロジェクトのカレンダーを返す
+	GetCalendars(ctx context.Context, userId uint) ([]model.CalDAVCalendar, error)
+	GetCalendar(ctx context.Context, userId uint, name string) (model.CalDAVCalendar, error)
+	// GetObjectsはカレンダーの中のVTODOを返す(namesがnilの場合は全て、指定した場合は見つかったものだけ)
+	GetObjects(ctx context.Context, userId uint, calendar string, names []string) ([]model.CalDAVObject, error)
+	// GetChangesは同期トークンの時点から変更のあったVTODOを返す(sinceが0の場合は全て)
+	GetChanges(ctx context.Context, userId uint, calendar string, since uint) (model.CalDAVChanges, error)
+	// PutObjectはVTODOからタスクを作成・更新する(作成した場合はtrueを返します)
+	// ifMatchとifNoneMatchはリクエストのヘッダーの値で、条件に合わない場合はErrPreconditionFailedになります。
+	PutObject(ctx context.Context, userId uint, calendar string, name string, uid string, task model.Task, ifMatch string, ifNoneMatch string) (model.CalDAVObject, bool, error)
+	DeleteObject(ctx context.Context, userId uint, calendar string, name string, ifMatch string) error
+}
+
+type calDAVUsecase struct {
+	tu  ITaskUsecase
+	pu  IProjectUsecase
+	crr repository.ICalDAVResourceRepository
+	// カレンダーの同期トークン(getctag)には、タスクの変更履歴の最新のIDを使います。
+	tvr repository.ITaskVersionRepository
+}
+
+func NewCalDAVUsecase(tu ITaskUsecase, pu IProjectUsecase, crr repository.ICalDAVResourceRepository, tvr repository.ITaskVersionRepository) ICalDAVUsecase {
+	return &calDAVUsecase{tu, pu, crr, tvr}
+}
+
+// calendarFilterはカレンダーの名前を、タスクの一覧の絞り込みの条件に変換する
+func calendarFilter(name string) (model.TaskFilter, error) {
+	if name == model.CalDAVTasksCalendar {
+		return model.TaskFilter{Scope: model.TaskScopeOwned}, nil
+	}
+	if id, err := strconv.ParseUint(strings.TrimPrefix(name, "project-"), 10, 64); err == nil && strings.HasPrefix(name, "project-") {
+		projectId := uint(id)
+		return model.TaskFilter{ProjectId: &projectId}, nil
+	}
+	return model.TaskFilter{}, repository.ErrObjectNotExist
+}
+
+// calDAVETagはタスクのETag(更新日時が変わるとETagも変わります)
+func calDAVETag(task model.TaskResponse) string {
+	return fmt.Sprintf(`"%d-%d"`, task.ID, task.UpdatedAt.UnixNano())
+}
+
+// defaultObjectNameはサーバーが作成したタスクのリソースの名前
+func defaultObjectName(taskId uint) string {
+	return fmt.Sprintf("task-%d.ics", taskId)
+}
+
+func (cu *calDAVUsecase) GetCalendars(ctx context.Context, userId uint) ([]model.CalDAVCalendar, error) {
+	var latest uint
+	if err := cu.tvr.GetLatestVersionId(ctx, &latest); err != nil {
+		return nil, err
+	}
+	calendars := []model.CalDAVCalendar{{Name: model.CalDAVTasksCalendar, DisplayName: "Tasks", SyncToken: latest}}
+	projects, err := cu.pu.GetProjects(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		projectId := p.ID
+		calendars = append(calendars, model.CalDAVCalendar{
+			Name:        fmt.Sprintf("project-%d", p.ID),
+			DisplayName: p.Name,
+			ProjectId:   &projectId,
+			SyncToken:   latest,
+		})
+	}
+	return calendars, nil
+}
+
+func (cu *calDAVUsecase) GetCalendar(ctx context.Context, userId uint, name string) (model.CalDAVCalendar, error) {
+	filter, err := calendarFilter(name)
+	if err != nil {
+		return model.CalDAVCalendar{}, err
+	}
+	calendar := model.CalDAVCalendar{Name: name, DisplayName: "Tasks"}
+	if filter.ProjectId != nil {
+		// プロジェクトの閲覧権限はGetProjectByIdで確認します。
+		project, err := cu.pu.GetProjectById(ctx, userId, *filter.ProjectId)
+		if err != nil {
+			return model.CalDAVCalendar{}, err
+		}
+		calendar.DisplayName = project.Name
+		calendar.ProjectId = filter.ProjectId
+	}
+	// 同期トークンは全てのタスクで共通なので、他のカレンダーの変更でも変わります(クライアントは同期で差分が無いことを確認します)。
+	if err := cu.tvr.GetLatestVersionId(ctx, &calendar.SyncToken); err != nil {
+		return model.CalDAVCalendar{}, err
+	}
+	return calendar, nil
+}
+
+// newObjectsはタスクにリソースの名前とUIDを付けてVTODOにする
+func (cu *calDAVUsecase) newObjects(ctx context.Context, tasks []model.TaskResponse) ([]model.CalDAVObject, error) {
+	resources, err := cu.getResources(ctx, taskIds(tasks))
+	if err != nil {
+		return nil, err
+	}
+	objects := []model.CalDAVObject{}
+	for _, t := range tasks {
+		object := model.CalDAVObject{Name: defaultObjectName(t.ID), Uid: model.TaskUID(t.ID), ETag: calDAVETag(t), Task: t}
+		if r, ok := resources[t.ID]; ok {
+			object.Name = r.Name
+			object.Uid = r.Uid
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+func (cu *calDAVUsecase) getResources(ctx context.Context, ids []uint) (map[uint]model.CalDAVResource, error) {
+	resources := []model.CalDAVResource{}
+	if err := cu.crr.GetResourcesByTaskIds(ctx, &resources, ids); err != nil {
+		return nil, err
+	}
+	byTask := map[uint]model.CalDAVResource{}
+	for _, r := range resources {
+		byTask[r.TaskId] = r
+	}
+	return byTask, nil
+}
+
+func taskIds(tasks []model.TaskResponse) []uint {
+	ids := []uint{}
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+// resolveNameはリソースの名前をタスクのIDにする(見つからない場合は0)
+func (cu *calDAVUsecase) resolveName(ctx context.Context, name string) (uint, error) {
+	resource := model.CalDAVResource{}
+	err := cu.crr.GetResourceByName(ctx, &resource, name)
+	if err == nil {
+		return resource.TaskId, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	var id uint
+	if _, err := fmt.Sscanf(name, "task-%d.ics", &id); err == nil && defaultObjectName(id) == name {
+		return id, nil
+	}
+	return 0, nil
+}
+
+func (cu *calDAVUsecase) GetObjects(ctx context.Context, userId uint, calendar string, names []string) ([]model.CalDAVObject, error) {
+	filter, err := calendarFilter(calendar)
+	if err != nil {
+		return nil, err
+	}
+	if names != nil {
+		filter.TaskIds = []uint{}
+		for _, name := range names {
+			id, err := cu.resolveName(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if id != 0 {
+				filter.TaskIds = append(filter.TaskIds, id)
+			}
+		}
+	}
+	tasks, err := cu.tu.GetAllTasks(ctx, userId, filter)
+	if err != nil {
+		return nil, err
+	}
+	return cu.newObjects(ctx, tasks)
+}
+
+func (cu *calDAVUsecase) GetChanges(ctx context.Context, userId uint, calendar string, since uint) (model.CalDAVChanges, error) {
+	filter, err := calendarFilter(calendar)
+	if err != nil {
+		return model.CalDAVChanges{}, err
+	}
+	taskChanges, err := cu.tu.GetTaskChanges(ctx, userId, filter, since)
+	if err != nil {
+		return model.CalDAVChanges{}, err
+	}
+	changed, err := cu.newObjects(ctx, taskChanges.Changed)
+	if err != nil {
+		return model.CalDAVChanges{}, err
+	}
+	changes := model.CalDAVChanges{SyncToken: taskChanges.SyncToken, Changed: changed, Deleted: []string{}}
+	resources, err := cu.getResources(ctx, taskChanges.Deleted)
+	if err != nil {
+		return model.CalDAVChanges{}, err
+	}
+	for _, id := range taskChanges.Deleted {
+		name := defaultObjectName(id)
+		if r, ok := resources[id]; ok {
+			name = r.Name
+		}
+		changes.Deleted = append(changes.Deleted, name)
+	}
+	return changes, nil
+}
+
+// getObjectはカレンダーの中の名前のVTODOを返す(カレンダーに無い場合はnil)
+func (cu *calDAVUsecase) getObject(ctx context.Context, userId uint, calendar string, name string) (*model.CalDAVObject, error) {
+	objects, err := cu.GetObjects(ctx, userId, calendar, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+	return &objects[0], nil
+}
+
+// checkPreconditionはIf-MatchとIf-None-Matchの条件を確認する(currentはリソースが無い場合はnil)
+func checkPrecondition(current *model.CalDAVObject, ifMatch string, ifNoneMatch string) error {
+	if current == nil {
+		if ifMatch != "" {
+			return ErrPreconditionFailed
+		}
+		return nil
+	}
+	if ifNoneMatch == "*" || (ifNoneMatch != "" && ifNoneMatch == current.ETag) {
+		return ErrPreconditionFailed
+	}
+	if ifMatch != "" && ifMatch != "*" && ifMatch != current.ETag {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+func (cu *calDAVUsecase) PutObject(ctx context.Context, userId uint, calendar string, name string, uid string, task model.Task, ifMatch string, ifNoneMatch string) (model.CalDAVObject, bool, error) {
+	filter, err := calendarFilter(calendar)
+	if err != nil {
+		return model.CalDAVObject{}, false, err
+	}
+	current, err := cu.getObject(ctx, userId, calendar, name)
+	if err != nil {
+		return model.CalDAVObject{}, false, err
+	}
+	if err := checkPrecondition(current, ifMatch, ifNoneMatch); err != nil {
+		return model.CalDAVObject{}, false, err
+	}
+	if current != nil {
+		// VTODOに無いプロジェクトと独自の項目の値は、今のタスクの値を引き継ぎます。
+		task.ProjectId = current.Task.ProjectId
+		task.CustomFields = nil
+		updated, err := cu.tu.UpdateTask(ctx, task, userId, current.Task.ID)
+		if err != nil {
+			return model.CalDAVObject{}, false, err
+		}
+		objects, err := cu.newObjects(ctx, []model.TaskResponse{updated})
+		if err != nil {
+			return model.CalDAVObject{}, false, err
+		}
+		return objects[0], false, nil
+	}
+	// 他のカレンダーのタスクの名前(またはサーバーが作成したタスクの名前)で作成することはできません。
+	if id, err := cu.resolveName(ctx, name); err != nil {
+		return model.CalDAVObject{}, false, err
+	} else if id != 0 {
+		if _, err := cu.tu.GetTaskById(ctx, userId, id); err == nil || name == defaultObjectName(id) {
+			return model.CalDAVObject{}, false, ErrResourceConflict
+		}
+	}
+	task.UserId = userId
+	task.ProjectId = filter.ProjectId
+	created, err := cu.tu.CreateTask(ctx, task)
+	if err != nil {
+		return model.CalDAVObject{}, false, err
+	}
+	resource := model.CalDAVResource{Name: name, Uid: uid, TaskId: created.ID}
+	if err := cu.crr.SaveResource(ctx, &resource); err != nil {
+		return model.CalDAVObject{}, false, err
+	}
+	return model.CalDAVObject{Name: name, Uid: uid, ETag: calDAVETag(created), Task: created}, true, nil
+}
+
+func (cu *calDAVUsecase) DeleteObject(ctx context.Context, userId uint, calendar string, name string, ifMatch string) error {
+	current, err := cu.getObject(ctx, userId, calendar, name)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return repository.ErrObjectNotExist
+	}
+	if err := checkPrecondition(current, ifMatch, ""); err != nil {
+		return err
+	}
+	// リソースの名前は、同期で削除されたことを返すために残しておきます。
+	return cu.tu.DeleteTask(ctx, userId, current.Task.ID)
+}
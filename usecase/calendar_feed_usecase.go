@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"strings"
+)
+
+type ICalendarFeedUsecase interface {
+	GetFeed(ctx context.Context, userId uint) (model.CalendarFeedResponse, error)
+	// RegenerateFeedはフィードのトークンを作り直す(初めての場合は作成します)
+	// 古いトークンのURLはすぐに使えなくなり、新しいトークンはこのレスポンスでしか返しません。
+	RegenerateFeed(ctx context.Context, userId uint) (model.CalendarFeedResponse, error)
+	DeleteFeed(ctx context.Context, userId uint) error
+	// Authenticateはフィードのトークンから、フィードの持ち主のユーザーを返す
+	Authenticate(ctx context.Context, token string) (uint, error)
+	// GetFeedTasksはフィードに載せる期限日のあるタスクを、少しずつ読み込んでfnに渡す
+	GetFeedTasks(ctx context.Context, userId uint, filter model.CalendarFeedFilter, fn func(tasks []model.TaskResponse) error) error
+}
+
+type calendarFeedUsecase struct {
+	cfr repository.ICalendarFeedRepository
+	tu  ITaskUsecase
+}
+
+func NewCalendarFeedUsecase(cfr repository.ICalendarFeedRepository, tu ITaskUsecase) ICalendarFeedUsecase {
+	return &calendarFeedUsecase{cfr, tu}
+}
+
+func newCalendarFeedResponse(feed model.CalendarFeed) model.CalendarFeedResponse {
+	return model.CalendarFeedResponse{
+		LastAccessedAt: feed.LastAccessedAt,
+		CreatedAt:      feed.CreatedAt,
+		UpdatedAt:      feed.UpdatedAt,
+	}
+}
+
+func (cfu *calendarFeedUsecase) GetFeed(ctx context.Context, userId uint) (model.CalendarFeedResponse, error) {
+	feed := model.CalendarFeed{}
+	if err := cfu.cfr.GetFeedByUserId(ctx, &feed, userId); err != nil {
+		return model.CalendarFeedResponse{}, err
+	}
+	return newCalendarFeedResponse(feed), nil
+}
+
+func (cfu *calendarFeedUsecase) RegenerateFeed(ctx context.Context, userId uint) (model.CalendarFeedResponse, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return model.CalendarFeedResponse{}, err
+	}
+	token := hex.EncodeToString(b)
+	// トークンのハッシュはアクセストークンと同じ方法で作ります。
+	feed := model.CalendarFeed{TokenHash: hashPersonalToken(token), UserId: userId}
+	if err := cfu.cfr.SaveFeed(ctx, &feed); err != nil {
+		return model.CalendarFeedResponse{}, err
+	}
+	res := newCalendarFeedResponse(feed)
+	res.Token = token
+	res.Path = "/feeds/" + token + ".ics"
+	return res, nil
+}
+
+func (cfu *calendarFeedUsecase) DeleteFeed(ctx context.Context, userId uint) error {
+	return cfu.cfr.DeleteFeed(ctx, userId)
+}
+
+func (cfu *calendarFeedUsecase) Authenticate(ctx context.Context, token string) (uint, error) {
+	feed := model.CalendarFeed{}
+	if err := cfu.cfr.GetFeedByHash(ctx, &feed, hashPersonalToken(token)); err != nil {
+		return 0, ErrInvalidCredentials
+	}
+	return feed.UserId, nil
+}
+
+// feedQueryEscaperは検索クエリの引用符の中に値を入れるためのエスケープ
+var feedQueryEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func (cfu *calendarFeedUsecase) GetFeedTasks(ctx context.Context, userId uint, filter model.CalendarFeedFilter, fn func(tasks []model.TaskResponse) error) error {
+	// 期限日とタグの条件は検索クエリにして、タスクの一覧と同じ方法で絞り込みます。
+	terms := []string{"-due:none"}
+	if !filter.IncludeCompleted {
+		terms = append(terms, "status:open")
+	}
+	if filter.Tag != "" {
+		terms = append(terms, `tag:"`+feedQueryEscaper.Replace(filter.Tag)+`"`)
+	}
+	return cfu.tu.ExportTasks(ctx, userId, model.TaskFilter{
+		Scope:     model.TaskScopeAll,
+		ProjectId: filter.ProjectId,
+		Query:     strings.Join(terms, " "),
+	}, fn)
+}
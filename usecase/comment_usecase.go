@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type ICommentUsecase interface {
+	GetComments(ctx context.Context, userId uint, taskId uint) ([]model.CommentResponse, error)
+	// GetCommentsByTasksは複数のタスクのコメントをタスクのIDごとに返す(見られないタスクのコメントは含めません)
+	GetCommentsByTasks(ctx context.Context, userId uint, taskIds []uint) (map[uint][]model.CommentResponse, error)
+	CreateComment(ctx context.Context, comment model.Comment, userId uint, taskId uint) (model.CommentResponse, error)
+	UpdateComment(ctx context.Context, comment model.Comment, userId uint, taskId uint, commentId uint) (model.CommentResponse, error)
+	DeleteComment(ctx context.Context, userId uint, taskId uint, commentId uint) error
+	GetCommentRevisions(ctx context.Context, userId uint, taskId uint, commentId uint) ([]model.CommentRevisionResponse, error)
+}
+
+type commentUsecase struct {
+	cr repository.ICommentRepository
+	nr repository.INotificationRepository
+	// コメントするタスクへのアクセス権の確認と、メンションされたユーザーの検索に使います。
+	ps permission.IPermissionService
+	ur repository.IUserRepository
+	cv validator.ICommentValidator
+}
+
+func NewCommentUsecase(cr repository.ICommentRepository, nr repository.INotificationRepository, ps permission.IPermissionService,
+	ur repository.IUserRepository, cv validator.ICommentValidator) ICommentUsecase {
+	return &commentUsecase{cr, nr, ps, ur, cv}
+}
+
+// mentionPatternは本文の中の"@alice@example.com"のようなメンションに一致する
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// parseMentionsは本文からメンションされたメールアドレスを重複なしで取り出す
+func parseMentions(body string) []string {
+	seen := map[string]bool{}
+	emails := []string{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(strings.TrimRight(m[1], "."))
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+func newCommentResponse(comment model.Comment) model.CommentResponse {
+	mentions := []string{}
+	for _, m := range comment.Mentions {
+		mentions = append(mentions, m.User.Email)
+	}
+	return model.CommentResponse{
+		ID:          comment.ID,
+		TaskId:      comment.TaskId,
+		UserId:      comment.UserId,
+		AuthorEmail: comment.User.Email,
+		Body:        comment.Body,
+		Edited:      comment.EditedAt != nil,
+		EditedAt:    comment.EditedAt,
+		Mentions:    mentions,
+		CreatedAt:   comment.CreatedAt,
+		UpdatedAt:   comment.UpdatedAt,
+	}
+}
+
+// checkTaskはコメントを読み書きするタスクにユーザーがアクセスできるか確認する
+// 共有されたタスクにはviewerの権限でもコメントできます。
+func (cu *commentUsecase) checkTask(ctx context.Context, userId uint, taskId uint) error {
+	return cu.ps.CanViewTask(ctx, userId, taskId)
+}
+
+func (cu *commentUsecase) GetComments(ctx context.Context, userId uint, taskId uint) ([]model.CommentResponse, error) {
+	if err := cu.checkTask(ctx, userId, taskId); err != nil {
+		return nil, err
+	}
+	comments := []model.Comment{}
+	if err := cu.cr.GetCommentsByTask(ctx, &comments, taskId); err != nil {
+		return nil, err
+	}
+	resComments := []model.CommentResponse{}
+	for _, v := range comments {
+		resComments = append(resComments, newCommentResponse(v))
+	}
+	return resComments, nil
+}
+
+func (cu *commentUsecase) GetCommentsByTasks(ctx context.Context, userId uint, taskIds []uint) (map[uint][]model.CommentResponse, error) {
+	resComments := map[uint][]model.CommentResponse{}
+	if len(taskIds) == 0 {
+		return resComments, nil
+	}
+	// タスクごとにアクセス権を確認する代わりに、見られるタスクのコメントだけを1回のクエリで取得します。
+	comments := []model.Comment{}
+	if err := cu.cr.GetCommentsByTasks(ctx, &comments, taskIds, cu.ps.VisibleTasks(userId)); err != nil {
+		return nil, err
+	}
+	for _, v := range comments {
+		resComments[v.TaskId] = append(resComments[v.TaskId], newCommentResponse(v))
+	}
+	return resComments, nil
+}
+
+func (cu *commentUsecase) CreateComment(ctx context.Context, comment model.Comment, userId uint, taskId uint) (model.CommentResponse, error) {
+	if err := cu.cv.CommentValidate(comment); err != nil {
+		return model.CommentResponse{}, err
+	}
+	if err := cu.checkTask(ctx, userId, taskId); err != nil {
+		return model.CommentResponse{}, err
+	}
+	mentioned, err := cu.mentionedUsers(ctx, comment.Body, userId, taskId)
+	if err != nil {
+		return model.CommentResponse{}, err
+	}
+	newComment := model.Comment{Body: comment.Body, TaskId: taskId, UserId: userId}
+	for _, u := range mentioned {
+		newComment.Mentions = append(newComment.Mentions, model.Mention{UserId: u.ID})
+	}
+	if err := cu.cr.CreateComment(ctx, &newComment); err != nil {
+		return model.CommentResponse{}, err
+	}
+	if err := cu.notifyMentions(ctx, newComment, mentioned, userId); err != nil {
+		return model.CommentResponse{}, err
+	}
+	// レスポンスに作成者のメールアドレスを含めるために作成したコメントを取得し直す
+	created := model.Comment{}
+	if err := cu.cr.GetCommentById(ctx, &created, taskId, newComment.ID); err != nil {
+		return model.CommentResponse{}, err
+	}
+	return newCommentResponse(created), nil
+}
+
+func (cu *commentUsecase) UpdateComment(ctx context.Context, comment model.Comment, userId uint, taskId uint, commentId uint) (model.CommentResponse, error) {
+	if err := cu.cv.CommentValidate(comment); err != nil {
+		return model.CommentResponse{}, err
+	}
+	if err := cu.checkTask(ctx, userId, taskId); err != nil {
+		return model.CommentResponse{}, err
+	}
+	current := model.Comment{}
+	if err := cu.cr.GetCommentById(ctx, &current, taskId, commentId); err != nil {
+		return model.CommentResponse{}, err
+	}
+	// コメントを編集できるのは作成者だけ
+	if current.UserId != userId {
+		return model.CommentResponse{}, fmt.Errorf("only the author can edit this comment")
+	}
+	mentioned, err := cu.mentionedUsers(ctx, comment.Body, userId, taskId)
+	if err != nil {
+		return model.CommentResponse{}, err
+	}
+	// 編集で新しくメンションされたユーザーにだけ通知する
+	already := map[uint]bool{}
+	for _, m := range current.Mentions {
+		already[m.UserId] = true
+	}
+	newMentions := []model.Mention{}
+	newlyMentioned := []model.User{}
+	for _, u := range mentioned {
+		if !already[u.ID] {
+			newMentions = append(newMentions, model.Mention{CommentId: commentId, UserId: u.ID})
+			newlyMentioned = append(newlyMentioned, u)
+		}
+	}
+	previousBody := current.Body
+	current.Body = comment.Body
+	if err := cu.cr.UpdateComment(ctx, &current, userId, previousBody, newMentions); err != nil {
+		return model.CommentResponse{}, err
+	}
+	if err := cu.notifyMentions(ctx, current, newlyMentioned, userId); err != nil {
+		return model.CommentResponse{}, err
+	}
+	updated := model.Comment{}
+	if err := cu.cr.GetCommentById(ctx, &updated, taskId, commentId); err != nil {
+		return model.CommentResponse{}, err
+	}
+	return newCommentResponse(updated), nil
+}
+
+func (cu *commentUsecase) DeleteComment(ctx context.Context, userId uint, taskId uint, commentId uint) error {
+	if err := cu.checkTask(ctx, userId, taskId); err != nil {
+		return err
+	}
+	// リポジトリの方でuser_idも条件にしているので、作成者以外は削除できません。
+	if err := cu.cr.DeleteComment(ctx, userId, taskId, commentId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cu *commentUsecase) GetCommentRevisions(ctx context.Context, userId uint, taskId uint, commentId uint) ([]model.CommentRevisionResponse, error) {
+	if err := cu.checkTask(ctx, userId, taskId); err != nil {
+		return nil, err
+	}
+	comment := model.Comment{}
+	if err := cu.cr.GetCommentById(ctx, &comment, taskId, commentId); err != nil {
+		return nil, err
+	}
+	revisions := []model.CommentRevision{}
+	if err := cu.cr.GetRevisions(ctx, &revisions, comment.ID); err != nil {
+		return nil, err
+	}
+	resRevisions := []model.CommentRevisionResponse{}
+	for _, v := range revisions {
+		resRevisions = append(resRevisions, model.CommentRevisionResponse{ID: v.ID, Body: v.Body, CreatedAt: v.CreatedAt})
+	}
+	return resRevisions, nil
+}
+
+// mentionedUsersは本文でメンションされているユーザーを取得する
+// 存在しないメールアドレスと自分自身、タスクを閲覧できないユーザーは除きます。
+func (cu *commentUsecase) mentionedUsers(ctx context.Context, body string, authorId uint, taskId uint) ([]model.User, error) {
+	emails := parseMentions(body)
+	if len(emails) == 0 {
+		return nil, nil
+	}
+	users := []model.User{}
+	if err := cu.ur.GetUsersByEmails(&users, emails); err != nil {
+		return nil, err
+	}
+	res := []model.User{}
+	for _, u := range users {
+		if u.ID == authorId {
+			continue
+		}
+		// 閲覧できないユーザーへのメンションはエラーにせず、メンションも通知もしません。
+		if err := cu.ps.CanViewTask(ctx, u.ID, taskId); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, permission.ErrForbidden) {
+				continue
+			}
+			return nil, err
+		}
+		res = append(res, u)
+	}
+	return res, nil
+}
+
+// notifyMentionsはメンションされたユーザーへの通知を作成する
+func (cu *commentUsecase) notifyMentions(ctx context.Context, comment model.Comment, users []model.User, actorId uint) error {
+	notifications := []model.Notification{}
+	for _, u := range users {
+		notifications = append(notifications, model.Notification{
+			Kind:      model.NotificationMention,
+			Message:   fmt.Sprintf("You were mentioned in a comment on task #%d", comment.TaskId),
+			TaskId:    &comment.TaskId,
+			CommentId: &comment.ID,
+			ActorId:   &actorId,
+			UserId:    u.ID,
+		})
+	}
+	return cu.nr.CreateNotifications(ctx, notifications)
+}
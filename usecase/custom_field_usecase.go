@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"context"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+
+	"gorm.io/gorm"
+)
+
+type ICustomFieldUsecase interface {
+	GetFields(ctx context.Context, userId uint, projectId uint) ([]model.CustomFieldResponse, error)
+	CreateField(ctx context.Context, field model.CustomField, userId uint, projectId uint) (model.CustomFieldResponse, error)
+	// UpdateFieldは項目の名前・選択肢・必須かどうかを更新する(値の種類は変更できません)
+	UpdateField(ctx context.Context, field model.CustomField, userId uint, projectId uint, fieldId uint) (model.CustomFieldResponse, error)
+	// DeleteFieldは項目を削除して、プロジェクトのタスクからその項目の値も取り除く
+	DeleteField(ctx context.Context, userId uint, projectId uint, fieldId uint) error
+}
+
+type customFieldUsecase struct {
+	cfr repository.ICustomFieldRepository
+	// 項目を見るにはプロジェクトのviewer以上、項目の定義を変更するにはプロジェクトの所有者の権限が必要
+	ps  permission.IPermissionService
+	cfv validator.ICustomFieldValidator
+}
+
+func NewCustomFieldUsecase(cfr repository.ICustomFieldRepository, ps permission.IPermissionService, cfv validator.ICustomFieldValidator) ICustomFieldUsecase {
+	return &customFieldUsecase{cfr, ps, cfv}
+}
+
+func newCustomFieldResponse(field model.CustomField) model.CustomFieldResponse {
+	options := field.Options
+	if options == nil {
+		options = []string{}
+	}
+	return model.CustomFieldResponse{
+		ID:        field.ID,
+		Name:      field.Name,
+		Type:      field.Type,
+		Options:   options,
+		Required:  field.Required,
+		ProjectId: field.ProjectId,
+		CreatedAt: field.CreatedAt,
+		UpdatedAt: field.UpdatedAt,
+	}
+}
+
+func (cfu *customFieldUsecase) GetFields(ctx context.Context, userId uint, projectId uint) ([]model.CustomFieldResponse, error) {
+	if err := cfu.ps.CanViewProject(ctx, userId, projectId); err != nil {
+		return nil, err
+	}
+	fields := []model.CustomField{}
+	if err := cfu.cfr.GetFieldsByProject(ctx, &fields, projectId); err != nil {
+		return nil, err
+	}
+	resFields := []model.CustomFieldResponse{}
+	for _, v := range fields {
+		resFields = append(resFields, newCustomFieldResponse(v))
+	}
+	return resFields, nil
+}
+
+func (cfu *customFieldUsecase) CreateField(ctx context.Context, field model.CustomField, userId uint, projectId uint) (model.CustomFieldResponse, error) {
+	if err := cfu.cfv.CustomFieldValidate(field); err != nil {
+		return model.CustomFieldResponse{}, err
+	}
+	if err := cfu.ps.CanManageProject(ctx, userId, projectId); err != nil {
+		return model.CustomFieldResponse{}, err
+	}
+	// 既存のタスクには値が無いので、必須の項目を追加した場合は次にタスクを更新する時から値が必要になります。
+	field.ProjectId = projectId
+	if field.Options == nil {
+		field.Options = []string{}
+	}
+	if err := cfu.cfr.CreateField(ctx, &field); err != nil {
+		return model.CustomFieldResponse{}, err
+	}
+	return newCustomFieldResponse(field), nil
+}
+
+func (cfu *customFieldUsecase) UpdateField(ctx context.Context, field model.CustomField, userId uint, projectId uint, fieldId uint) (model.CustomFieldResponse, error) {
+	if err := cfu.ps.CanManageProject(ctx, userId, projectId); err != nil {
+		return model.CustomFieldResponse{}, err
+	}
+	current := model.CustomField{}
+	if err := cfu.cfr.GetFieldById(ctx, &current, fieldId); err != nil {
+		return model.CustomFieldResponse{}, err
+	}
+	if current.ProjectId != projectId {
+		return model.CustomFieldResponse{}, gorm.ErrRecordNotFound
+	}
+	// 保存済みの値と食い違わないように、値の種類は作成した時のままにします。
+	field.Type = current.Type
+	if err := cfu.cfv.CustomFieldValidate(field); err != nil {
+		return model.CustomFieldResponse{}, err
+	}
+	if field.Options == nil {
+		field.Options = []string{}
+	}
+	if err := cfu.cfr.UpdateField(ctx, &field, projectId, fieldId); err != nil {
+		return model.CustomFieldResponse{}, err
+	}
+	return newCustomFieldResponse(field), nil
+}
+
+func (cfu *customFieldUsecase) DeleteField(ctx context.Context, userId uint, projectId uint, fieldId uint) error {
+	if err := cfu.ps.CanManageProject(ctx, userId, projectId); err != nil {
+		return err
+	}
+	if err := cfu.cfr.DeleteField(ctx, projectId, fieldId); err != nil {
+		return err
+	}
+	return nil
+}
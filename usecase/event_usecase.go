@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/pubsub"
+	"go-rest-api/tenant"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IEventUsecase interface {
+	// Subscribeはctxの組織のタスクのうち、ユーザーが見られるタスクの変更を受け取るチャンネルを返す
+	// ctxがキャンセルされるか、ハブが購読者のチャンネルを閉じた(イベントを取りこぼした)場合はチャンネルを閉じます。
+	Subscribe(ctx context.Context, userId uint) <-chan model.TaskChangeEvent
+}
+
+type eventUsecase struct {
+	h  pubsub.IHub
+	ps permission.IPermissionService
+}
+
+func NewEventUsecase(h pubsub.IHub, ps permission.IPermissionService) IEventUsecase {
+	return &eventUsecase{h, ps}
+}
+
+// taskChangeEventsは変更履歴の操作と、クライアントに送るイベントの対応
+var taskChangeEvents = map[string]string{
+	model.TaskActionCreate:  model.TaskEventCreated,
+	model.TaskActionUpdate:  model.TaskEventUpdated,
+	model.TaskActionDelete:  model.TaskEventDeleted,
+	model.TaskActionRestore: model.TaskEventUpdated,
+}
+
+// publishChangeはタスクの変更をハブに送る(taskは変更した後のタスクで、削除の場合は削除する前のタスク)
+func (tu *taskUsecase) publishChange(action string, task *model.Task, actorId uint) {
+	res := newTaskResponse(*task)
+	tu.h.Publish(model.TaskChangeEvent{
+		Event:          taskChangeEvents[action],
+		TaskId:         task.ID,
+		Task:           &res,
+		UserId:         task.UserId,
+		AssigneeId:     task.AssigneeId,
+		ProjectId:      task.ProjectId,
+		OrganizationId: task.OrganizationId,
+		ActorId:        actorId,
+		OccurredAt:     time.Now(),
+	})
+}
+
+func (eu *eventUsecase) Subscribe(ctx context.Context, userId uint) <-chan model.TaskChangeEvent {
+	events, unsubscribe := eu.h.Subscribe()
+	out := make(chan model.TaskChangeEvent)
+	t, _ := tenant.FromContext(ctx)
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				// 他の組織(個人のタスクの場合はどの組織のタスクも)のイベントは送りません。
+				if !sameId(event.OrganizationId, t.OrganizationIdOrNil()) {
+					continue
+				}
+				visible, err := canSeeTaskChange(ctx, eu.ps, userId, event)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Println("events:", err)
+					}
+					continue
+				}
+				if !visible {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// canSeeTaskChangeはユーザーがタスクの変更を見られるかを確認する
+// タスクの作成者・担当者と、タスクのプロジェクトを見られるユーザーは変更の時点の値で判定して、
+// それ以外(共有されたタスクや組織のowner・admin)は今のタスクに対する権限で判定します(削除されたタスクは判定できないので対象外)。
+func canSeeTaskChange(ctx context.Context, ps permission.IPermissionService, userId uint, event model.TaskChangeEvent) (bool, error) {
+	if event.UserId == userId || (event.AssigneeId != nil && *event.AssigneeId == userId) {
+		return true, nil
+	}
+	if event.ProjectId != nil {
+		if ok, err := allowed(ps.CanViewProject(ctx, userId, *event.ProjectId)); ok || err != nil {
+			return ok, err
+		}
+	}
+	if event.Event == model.TaskEventDeleted {
+		return false, nil
+	}
+	return allowed(ps.CanViewTask(ctx, userId, event.TaskId))
+}
+
+// allowedはpermissionパッケージの確認の結果を、見られるかどうかにする
+// 見られない場合のエラー(gorm.ErrRecordNotFound・permission.ErrForbidden)以外はエラーとして返します。
+func allowed(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, permission.ErrForbidden) {
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,310 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/importer"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/storage"
+	"go-rest-api/tenant"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
+type IImportUsecase interface {
+	GetImports(ctx context.Context, userId uint) ([]model.ImportJobResponse, error)
+	GetImportById(ctx context.Context, userId uint, jobId uint) (model.ImportJobResponse, error)
+	// CreateImportはアップロードしたファイルを保存して、待機中のインポートのジョブを作成する
+	// インポート自体はワーカー(RunImport)がバックグラウンドで実行します。
+	CreateImport(ctx context.Context, userId uint, source string, fileName string, file io.Reader, size int64) (model.ImportJobResponse, error)
+	// RunImportは確保したジョブのファイルを読み込んで、プロジェクト・独自の項目・タスクを作成する
+	// 進捗はチャンクごとにジョブに保存するので、GET /imports/:importIdで途中経過を確認できます。
+	RunImport(ctx context.Context, job model.ImportJob) error
+	// FailStaleImportsはstaleBeforeより後に進捗が無い実行中のジョブを失敗にして、ファイルを削除する
+	FailStaleImports(ctx context.Context, staleBefore time.Time) error
+}
+
+type importUsecase struct {
+	ijr repository.IImportJobRepository
+	bs  storage.BlobStore
+	// プロジェクト・独自の項目・タスクは、それぞれのユースケースでバリデーションと権限の確認をしてから作成します。
+	pu  IProjectUsecase
+	cfu ICustomFieldUsecase
+	tu  ITaskUsecase
+}
+
+// importChunkSizeは1回のトランザクションで作成するタスクの件数(チャンクごとに進捗を保存します)
+const importChunkSize = 100
+
+func NewImportUsecase(ijr repository.IImportJobRepository, bs storage.BlobStore, pu IProjectUsecase, cfu ICustomFieldUsecase, tu ITaskUsecase) IImportUsecase {
+	return &importUsecase{ijr, bs, pu, cfu, tu}
+}
+
+func newImportJobResponse(job model.ImportJob) model.ImportJobResponse {
+	errors := job.Errors
+	if errors == nil {
+		errors = []model.ImportJobError{}
+	}
+	projectIds := job.ProjectIds
+	if projectIds == nil {
+		projectIds = []uint{}
+	}
+	return model.ImportJobResponse{
+		ID:         job.ID,
+		Source:     job.Source,
+		FileName:   job.FileName,
+		Status:     job.Status,
+		Total:      job.Total,
+		Processed:  job.Processed,
+		Created:    job.Created,
+		Duplicates: job.Duplicates,
+		Failed:     job.Failed,
+		Errors:     errors,
+		ProjectIds: projectIds,
+		Error:      job.Error,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+	}
+}
+
+func (iu *importUsecase) GetImports(ctx context.Context, userId uint) ([]model.ImportJobResponse, error) {
+	jobs := []model.ImportJob{}
+	if err := iu.ijr.GetJobs(ctx, &jobs, userId); err != nil {
+		return nil, err
+	}
+	resJobs := []model.ImportJobResponse{}
+	for _, v := range jobs {
+		resJobs = append(resJobs, newImportJobResponse(v))
+	}
+	return resJobs, nil
+}
+
+func (iu *importUsecase) GetImportById(ctx context.Context, userId uint, jobId uint) (model.ImportJobResponse, error) {
+	job := model.ImportJob{}
+	if err := iu.ijr.GetJobById(ctx, &job, userId, jobId); err != nil {
+		return model.ImportJobResponse{}, err
+	}
+	return newImportJobResponse(job), nil
+}
+
+func (iu *importUsecase) CreateImport(ctx context.Context, userId uint, source string, fileName string, file io.Reader, size int64) (model.ImportJobResponse, error) {
+	switch source {
+	case model.ImportSourceTodoist, model.ImportSourceTrello, model.ImportSourceMicrosoftTodo:
+	default:
+		return model.ImportJobResponse{}, fmt.Errorf("source must be one of %s, %s, %s",
+			model.ImportSourceTodoist, model.ImportSourceTrello, model.ImportSourceMicrosoftTodo)
+	}
+	name, err := randomHex()
+	if err != nil {
+		return model.ImportJobResponse{}, err
+	}
+	key := fmt.Sprintf("imports/%d/%s", userId, name)
+	if err := iu.bs.Put(ctx, key, file, size, "application/octet-stream"); err != nil {
+		return model.ImportJobResponse{}, err
+	}
+	job := model.ImportJob{
+		Source:     source,
+		FileName:   sanitizeFileName(fileName),
+		StorageKey: key,
+		Status:     model.ImportJobPending,
+		Errors:     []model.ImportJobError{},
+		ProjectIds: []uint{},
+		UserId:     userId,
+	}
+	if err := iu.ijr.CreateJob(ctx, &job); err != nil {
+		// ジョブを作成できなかった場合は、保存したファイルも削除します。
+		if delErr := iu.bs.Delete(ctx, key); delErr != nil {
+			log.Println("import:", delErr)
+		}
+		return model.ImportJobResponse{}, err
+	}
+	return newImportJobResponse(job), nil
+}
+
+func (iu *importUsecase) RunImport(ctx context.Context, job model.ImportJob) error {
+	// ワーカーはテナントで絞り込まないctxでジョブを確保するので、ここからはジョブの組織のテナントで処理します。
+	orgId := uint(0)
+	if job.OrganizationId != nil {
+		orgId = *job.OrganizationId
+	}
+	ctx = tenant.WithOrganization(ctx, orgId)
+	if job.Errors == nil {
+		job.Errors = []model.ImportJobError{}
+	}
+	if job.ProjectIds == nil {
+		job.ProjectIds = []uint{}
+	}
+	runErr := iu.runImport(ctx, &job)
+	job.Status = model.ImportJobCompleted
+	if runErr != nil {
+		job.Status = model.ImportJobFailed
+		job.Error = runErr.Error()
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+	if err := iu.ijr.UpdateJobProgress(ctx, &job); err != nil {
+		return err
+	}
+	// 終わったジョブのファイルはもう使わないので削除します。
+	if err := iu.bs.Delete(ctx, job.StorageKey); err != nil {
+		log.Println("import:", err)
+	}
+	return nil
+}
+
+func (iu *importUsecase) runImport(ctx context.Context, job *model.ImportJob) error {
+	blob, err := iu.bs.Open(ctx, job.StorageKey)
+	if err != nil {
+		return err
+	}
+	projects, err := importer.Parse(job.Source, blob, job.FileName)
+	blob.Close()
+	if err != nil {
+		return err
+	}
+	// チェックリストの項目はサブタスクとして作成するので、件数に含めます。
+	for _, p := range projects {
+		for _, t := range p.Tasks {
+			job.Total += 1 + len(t.Checklist)
+		}
+	}
+	if err := iu.ijr.UpdateJobProgress(ctx, job); err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if err := iu.importProject(ctx, job, p); err != nil {
+			return fmt.Errorf("project %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// importProjectは1つのプロジェクトと、その独自の項目・タスクを作成する
+func (iu *importUsecase) importProject(ctx context.Context, job *model.ImportJob, p importer.Project) error {
+	project, err := iu.pu.CreateProject(ctx, model.Project{Name: p.Name, UserId: job.UserId})
+	if err != nil {
+		return err
+	}
+	job.ProjectIds = append(job.ProjectIds, project.ID)
+	// リストは使われている場合だけ、selectの独自の項目を作成します。
+	listKey := ""
+	if len(p.Lists) > 0 {
+		field, err := iu.cfu.CreateField(ctx, model.CustomField{Name: p.ListField, Type: model.CustomFieldSelect, Options: p.Lists}, job.UserId, project.ID)
+		if err != nil {
+			return fmt.Errorf("custom field %s: %w", p.ListField, err)
+		}
+		listKey = strconv.FormatUint(uint64(field.ID), 10)
+	}
+	projectId := project.ID
+	rows := []model.TaskImportRow{}
+	for _, t := range p.Tasks {
+		for _, w := range t.Warnings {
+			addImportJobError(job, model.ImportJobError{Project: p.Name, Row: t.Row, Title: t.Title, Error: w})
+		}
+		values := model.CustomFieldValues{}
+		if listKey != "" && t.List != "" {
+			values[listKey] = t.List
+		}
+		rows = append(rows, model.TaskImportRow{
+			Row: t.Row,
+			Item: model.TaskImportItem{
+				Title:        t.Title,
+				Description:  t.Description,
+				Completed:    t.Completed,
+				DueDate:      t.DueDate,
+				ProjectId:    &projectId,
+				CustomFields: values,
+				Tags:         t.Labels,
+			},
+		})
+	}
+	for start := 0; start < len(rows); start += importChunkSize {
+		end := start + importChunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		result, err := iu.importRows(ctx, job, p.Name, rows[start:end])
+		if err != nil {
+			return err
+		}
+		// チェックリストの項目は、作成できた親のタスクのサブタスクとして作成します。
+		// 親のタスクが重複・誤りで作成されなかった場合は、サブタスクも親と同じ結果として数えます。
+		subtasks := []model.TaskImportRow{}
+		for i, r := range result.Rows {
+			t := p.Tasks[start+i]
+			switch r.Status {
+			case model.TaskImportCreated:
+				parentId := r.TaskId
+				for _, item := range t.Checklist {
+					subtasks = append(subtasks, model.TaskImportRow{
+						Row: t.Row,
+						Item: model.TaskImportItem{
+							Title:     item.Title,
+							Completed: item.Checked,
+							ProjectId: &projectId,
+							ParentId:  &parentId,
+						},
+					})
+				}
+			case model.TaskImportDuplicate:
+				job.Processed += len(t.Checklist)
+				job.Duplicates += len(t.Checklist)
+			default:
+				job.Processed += len(t.Checklist)
+				job.Failed += len(t.Checklist)
+			}
+		}
+		if len(subtasks) > 0 {
+			if _, err := iu.importRows(ctx, job, p.Name, subtasks); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// importRowsはタスクの行をまとめて作成して、結果をジョブの進捗に加えて保存する
+func (iu *importUsecase) importRows(ctx context.Context, job *model.ImportJob, projectName string, rows []model.TaskImportRow) (model.TaskImportResult, error) {
+	result, err := iu.tu.ImportTasks(ctx, job.UserId, rows, false)
+	if err != nil {
+		return model.TaskImportResult{}, err
+	}
+	job.Processed += len(rows)
+	job.Created += result.Created
+	job.Duplicates += result.Duplicates
+	job.Failed += result.Errors
+	for _, r := range result.Rows {
+		if r.Status == model.TaskImportError {
+			addImportJobError(job, model.ImportJobError{Project: projectName, Row: r.Row, Title: r.Title, Error: r.Error})
+		}
+	}
+	if err := iu.ijr.UpdateJobProgress(ctx, job); err != nil {
+		return model.TaskImportResult{}, err
+	}
+	return result, nil
+}
+
+// addImportJobErrorはジョブにエラーを追加する(MaxImportJobErrors件を超えた分は記録しません)
+func addImportJobError(job *model.ImportJob, e model.ImportJobError) {
+	if len(job.Errors) < model.MaxImportJobErrors {
+		job.Errors = append(job.Errors, e)
+	}
+}
+
+func (iu *importUsecase) FailStaleImports(ctx context.Context, staleBefore time.Time) error {
+	jobs, err := iu.ijr.FailStaleJobs(ctx, staleBefore, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err := iu.bs.Delete(ctx, job.StorageKey); err != nil {
+			log.Println("import:", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+	"go-rest-api/validator"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrMailboxNotFoundは受信アドレスが存在しない場合のエラー
+var ErrMailboxNotFound = errors.New("mailbox does not exist")
+
+// ErrSenderNotAllowedはメールの送信者が、受信アドレスに登録された送信者ではない場合のエラー
+var ErrSenderNotAllowed = errors.New("sender is not allowed")
+
+type IMailInboxUsecase interface {
+	GetInbox(ctx context.Context, userId uint) (model.MailInboxResponse, error)
+	// RegenerateInboxは受信アドレスを作り直す(初めての場合は作成します)
+	// 古いアドレスに届いたメールはタスクにならなくなります。登録した送信者はそのまま引き継ぎます。
+	RegenerateInbox(ctx context.Context, userId uint) (model.MailInboxResponse, error)
+	UpdateInbox(ctx context.Context, userId uint, req model.MailInboxRequest) (model.MailInboxResponse, error)
+	DeleteInbox(ctx context.Context, userId uint) error
+	// CheckRecipientはSMTPの宛先が、存在する受信アドレスかを確認する
+	CheckRecipient(ctx context.Context, address string) error
+	// DeliverMailは受信アドレスに届いたメールから、受信アドレスのユーザーの個人のタスクを作成する
+	// 件名はタイトル、本文は説明、添付ファイルはタスクの添付ファイルにします。
+	DeliverMail(ctx context.Context, address string, mail model.InboundMail) (model.TaskResponse, error)
+}
+
+type mailInboxUsecase struct {
+	mir repository.IMailInboxRepository
+	ur  repository.IUserRepository
+	tu  ITaskUsecase
+	au  IAttachmentUsecase
+	miv validator.IMailInboxValidator
+	// domainは受信アドレスの@より後の部分
+	domain string
+}
+
+func NewMailInboxUsecase(mir repository.IMailInboxRepository, ur repository.IUserRepository, tu ITaskUsecase, au IAttachmentUsecase,
+	miv validator.IMailInboxValidator, domain string) IMailInboxUsecase {
+	return &mailInboxUsecase{mir, ur, tu, au, miv, strings.ToLower(domain)}
+}
+
+func (miu *mailInboxUsecase) newMailInboxResponse(inbox model.MailInbox) model.MailInboxResponse {
+	allowedSenders := inbox.AllowedSenders
+	if allowedSenders == nil {
+		allowedSenders = []string{}
+	}
+	return model.MailInboxResponse{
+		Address:        inbox.Token + "@" + miu.domain,
+		AllowedSenders: allowedSenders,
+		CreatedAt:      inbox.CreatedAt,
+		UpdatedAt:      inbox.UpdatedAt,
+	}
+}
+
+func (miu *mailInboxUsecase) GetInbox(ctx context.Context, userId uint) (model.MailInboxResponse, error) {
+	inbox := model.MailInbox{}
+	if err := miu.mir.GetInboxByUserId(ctx, &inbox, userId); err != nil {
+		return model.MailInboxResponse{}, err
+	}
+	return miu.newMailInboxResponse(inbox), nil
+}
+
+func (miu *mailInboxUsecase) RegenerateInbox(ctx context.Context, userId uint) (model.MailInboxResponse, error) {
+	// メールアドレスの@より前は大文字と小文字を区別しないメールサーバーもあるので、小文字の16進数にします。
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return model.MailInboxResponse{}, err
+	}
+	inbox := model.MailInbox{Token: hex.EncodeToString(b), AllowedSenders: []string{}, UserId: userId}
+	if err := miu.mir.SaveInbox(ctx, &inbox); err != nil {
+		return model.MailInboxResponse{}, err
+	}
+	return miu.newMailInboxResponse(inbox), nil
+}
+
+func (miu *mailInboxUsecase) UpdateInbox(ctx context.Context, userId uint, req model.MailInboxRequest) (model.MailInboxResponse, error) {
+	if err := miu.miv.MailInboxValidate(req); err != nil {
+		return model.MailInboxResponse{}, err
+	}
+	senders := []string{}
+	for _, s := range req.AllowedSenders {
+		senders = append(senders, strings.ToLower(strings.TrimSpace(s)))
+	}
+	inbox := model.MailInbox{AllowedSenders: senders}
+	if err := miu.mir.UpdateAllowedSenders(ctx, &inbox, userId); err != nil {
+		return model.MailInboxResponse{}, err
+	}
+	return miu.newMailInboxResponse(inbox), nil
+}
+
+func (miu *mailInboxUsecase) DeleteInbox(ctx context.Context, userId uint) error {
+	return miu.mir.DeleteInbox(ctx, userId)
+}
+
+// findInboxは宛先のメールアドレスから受信アドレスを探す
+func (miu *mailInboxUsecase) findInbox(ctx context.Context, address string) (model.MailInbox, error) {
+	local, domain, ok := strings.Cut(strings.ToLower(address), "@")
+	if !ok || domain != miu.domain || local == "" {
+		return model.MailInbox{}, ErrMailboxNotFound
+	}
+	inbox := model.MailInbox{}
+	if err := miu.mir.GetInboxByToken(ctx, &inbox, local); err != nil {
+		return model.MailInbox{}, ErrMailboxNotFound
+	}
+	return inbox, nil
+}
+
+func (miu *mailInboxUsecase) CheckRecipient(ctx context.Context, address string) error {
+	_, err := miu.findInbox(ctx, address)
+	return err
+}
+
+// allowedはメールの送信者(SMTPの送信者かFromヘッダーのどちらか)が、登録された送信者に含まれているかを確認する
+// 転送の設定によってどちらが転送したユーザーのアドレスになるかが違うので、どちらかが一致すれば受け付けます。
+func (miu *mailInboxUsecase) allowed(inbox model.MailInbox, mail model.InboundMail) (bool, error) {
+	allowedSenders := inbox.AllowedSenders
+	if len(allowedSenders) == 0 {
+		user := model.User{}
+		if err := miu.ur.GetUserById(&user, inbox.UserId); err != nil {
+			return false, err
+		}
+		allowedSenders = []string{strings.ToLower(user.Email)}
+	}
+	for _, sender := range []string{mail.EnvelopeFrom, mail.HeaderFrom} {
+		sender = strings.ToLower(sender)
+		if !strings.Contains(sender, "@") {
+			continue
+		}
+		for _, a := range allowedSenders {
+			if sender == a || (strings.HasPrefix(a, "@") && strings.HasSuffix(sender, a)) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// forwardPrefixは転送・返信のメールの件名に付く接頭辞(タイトルからは取り除きます)
+var forwardPrefix = regexp.MustCompile(`^(?i)((fwd?|fw|re|転送|返信)\s*[:：]\s*)+`)
+
+// maxTaskTitleRunesはタスクのタイトルの最大の文字数(taskValidatorの制限)
+const maxTaskTitleRunes = 10
+
+// mailTaskTitleは件名からタスクのタイトルを作る
+// タイトルの文字数の制限を超える分は切り詰めて、切り詰めた場合はtruncatedをtrueにします。
+func mailTaskTitle(subject string) (string, bool) {
+	title := strings.Join(strings.Fields(forwardPrefix.ReplaceAllString(strings.TrimSpace(subject), "")), " ")
+	if title == "" {
+		return "No subject", false
+	}
+	if utf8.RuneCountInString(title) <= maxTaskTitleRunes {
+		return title, false
+	}
+	return strings.TrimSpace(string([]rune(title)[:maxTaskTitleRunes])), true
+}
+
+func (miu *mailInboxUsecase) DeliverMail(ctx context.Context, address string, mail model.InboundMail) (model.TaskResponse, error) {
+	inbox, err := miu.findInbox(ctx, address)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	ok, err := miu.allowed(inbox, mail)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	if !ok {
+		return model.TaskResponse{}, ErrSenderNotAllowed
+	}
+
+	title, truncated := mailTaskTitle(mail.Subject)
+	description := mail.Text
+	// タイトルで切り詰めた件名は、説明の先頭に残します。
+	if truncated {
+		description = strings.TrimSpace(mail.Subject + "\n\n" + description)
+	}
+	if utf8.RuneCountInString(description) > model.MaxTaskDescriptionRunes {
+		description = string([]rune(description)[:model.MaxTaskDescriptionRunes])
+	}
+	// メールから作成するタスクは、どの組織にも属さない個人のタスクにします。
+	ctx = tenant.WithOrganization(ctx, 0)
+	taskRes, err := miu.tu.CreateTask(ctx, model.Task{Title: title, Description: description, UserId: inbox.UserId})
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	// 添付ファイルは添付ファイルの制限(サイズ・種類)に合うものだけを付けて、合わないものはタスクを作ったまま読み飛ばします。
+	for _, a := range mail.Attachments {
+		if _, err := miu.au.UploadAttachment(ctx, inbox.UserId, taskRes.ID, a.FileName, bytes.NewReader(a.Data), int64(len(a.Data))); err != nil {
+			log.Printf("mail inbox: task %d: attachment %q: %v", taskRes.ID, a.FileName, err)
+		}
+	}
+	return taskRes, nil
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+)
+
+type INotificationUsecase interface {
+	GetNotifications(ctx context.Context, userId uint, unreadOnly bool) ([]model.NotificationResponse, error)
+	MarkAsRead(ctx context.Context, userId uint, notificationId uint) error
+}
+
+type notificationUsecase struct {
+	nr repository.INotificationRepository
+}
+
+func NewNotificationUsecase(nr repository.INotificationRepository) INotificationUsecase {
+	return &notificationUsecase{nr}
+}
+
+func (nu *notificationUsecase) GetNotifications(ctx context.Context, userId uint, unreadOnly bool) ([]model.NotificationResponse, error) {
+	notifications := []model.Notification{}
+	if err := nu.nr.GetNotifications(ctx, &notifications, userId, unreadOnly); err != nil {
+		return nil, err
+	}
+	resNotifications := []model.NotificationResponse{}
+	for _, v := range notifications {
+		resNotifications = append(resNotifications, model.NotificationResponse{
+			ID:        v.ID,
+			Kind:      v.Kind,
+			Message:   v.Message,
+			TaskId:    v.TaskId,
+			CommentId: v.CommentId,
+			ActorId:   v.ActorId,
+			ReadAt:    v.ReadAt,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+	return resNotifications, nil
+}
+
+func (nu *notificationUsecase) MarkAsRead(ctx context.Context, userId uint, notificationId uint) error {
+	if err := nu.nr.MarkAsRead(ctx, userId, notificationId); err != nil {
+		return err
+	}
+	return nil
+}
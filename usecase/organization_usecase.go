@@ -0,0 +1,364 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+	"go-rest-api/validator"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvitationInvalidは招待のトークンが存在しない・期限切れ・受け入れ済みの場合のエラー
+var ErrInvitationInvalid = errors.New("invitation is invalid or expired")
+
+// invitationLifetimeは招待の有効期間
+const invitationLifetime = 7 * 24 * time.Hour
+
+type IOrganizationUsecase interface {
+	GetOrganizations(userId uint) ([]model.OrganizationResponse, error)
+	GetOrganizationById(userId uint, orgId uint) (model.OrganizationResponse, error)
+	// CreateOrganizationは組織を作成し、作成したユーザーをownerにする
+	CreateOrganization(org model.Organization, userId uint) (model.OrganizationResponse, error)
+	UpdateOrganization(org model.Organization, userId uint, orgId uint) (model.OrganizationResponse, error)
+	DeleteOrganization(userId uint, orgId uint) error
+	GetMembers(userId uint, orgId uint) ([]model.MemberResponse, error)
+	UpdateMember(req model.MemberRequest, userId uint, orgId uint, memberId uint) (model.MemberResponse, error)
+	// DeleteMemberはメンバーを組織から外す(自分を指定した場合は組織から抜ける)
+	DeleteMember(userId uint, orgId uint, memberId uint) error
+	GetInvitations(userId uint, orgId uint) ([]model.InvitationResponse, error)
+	// CreateInvitationは招待を作成する(トークンはこのレスポンスでだけ返します)
+	CreateInvitation(ctx context.Context, req model.InvitationRequest, userId uint, orgId uint) (model.InvitationResponse, error)
+	DeleteInvitation(userId uint, orgId uint, invitationId uint) error
+	// AcceptInvitationは招待のトークンを使って、ログインしているユーザーを組織のメンバーにする
+	AcceptInvitation(req model.AcceptInvitationRequest, userId uint) (model.OrganizationResponse, error)
+	// CheckMemberはリクエストの組織を切り替える前に、ユーザーが組織のメンバーか確認する
+	CheckMember(userId uint, orgId uint) error
+}
+
+type organizationUsecase struct {
+	or repository.IOrganizationRepository
+	// 招待されたユーザーのメールアドレスを確認して、招待されたことを通知します。
+	ur repository.IUserRepository
+	nr repository.INotificationRepository
+	ov validator.IOrganizationValidator
+}
+
+func NewOrganizationUsecase(or repository.IOrganizationRepository, ur repository.IUserRepository, nr repository.INotificationRepository,
+	ov validator.IOrganizationValidator) IOrganizationUsecase {
+	return &organizationUsecase{or, ur, nr, ov}
+}
+
+func newOrganizationResponse(org model.Organization, role string) model.OrganizationResponse {
+	return model.OrganizationResponse{
+		ID:        org.ID,
+		Name:      org.Name,
+		Role:      role,
+		CreatedAt: org.CreatedAt,
+		UpdatedAt: org.UpdatedAt,
+	}
+}
+
+func newMemberResponse(membership model.Membership) model.MemberResponse {
+	return model.MemberResponse{
+		UserId:    membership.UserId,
+		Email:     membership.User.Email,
+		Role:      membership.Role,
+		CreatedAt: membership.CreatedAt,
+	}
+}
+
+func newInvitationResponse(invitation model.Invitation) model.InvitationResponse {
+	return model.InvitationResponse{
+		ID:             invitation.ID,
+		Email:          invitation.Email,
+		Role:           invitation.Role,
+		ExpiresAt:      invitation.ExpiresAt,
+		AcceptedAt:     invitation.AcceptedAt,
+		OrganizationId: invitation.OrganizationId,
+		CreatedAt:      invitation.CreatedAt,
+	}
+}
+
+// hashInvitationTokenは招待のトークンをデータベースに保存するハッシュに変換する
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requireRoleはユーザーが組織のメンバーで、rolesのどれかの権限を持っているか確認する
+// メンバーではない場合は、組織の存在を知られないようにgorm.ErrRecordNotFoundを返します。
+func (ou *organizationUsecase) requireRole(userId uint, orgId uint, roles ...string) (model.Membership, error) {
+	membership := model.Membership{}
+	if err := ou.or.GetMembership(&membership, orgId, userId); err != nil {
+		return model.Membership{}, err
+	}
+	if len(roles) == 0 {
+		return membership, nil
+	}
+	for _, r := range roles {
+		if membership.Role == r {
+			return membership, nil
+		}
+	}
+	return model.Membership{}, permission.ErrForbidden
+}
+
+// keepOwnerは組織のownerがいなくならないか確認する
+func (ou *organizationUsecase) keepOwner(orgId uint) error {
+	count, err := ou.or.CountOwners(orgId)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return fmt.Errorf("organization must have at least one owner")
+	}
+	return nil
+}
+
+func (ou *organizationUsecase) GetOrganizations(userId uint) ([]model.OrganizationResponse, error) {
+	memberships := []model.Membership{}
+	if err := ou.or.GetMembershipsByUser(&memberships, userId); err != nil {
+		return nil, err
+	}
+	resOrgs := []model.OrganizationResponse{}
+	for _, v := range memberships {
+		resOrgs = append(resOrgs, newOrganizationResponse(v.Organization, v.Role))
+	}
+	return resOrgs, nil
+}
+
+func (ou *organizationUsecase) GetOrganizationById(userId uint, orgId uint) (model.OrganizationResponse, error) {
+	membership, err := ou.requireRole(userId, orgId)
+	if err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	org := model.Organization{}
+	if err := ou.or.GetOrganizationById(&org, orgId); err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	return newOrganizationResponse(org, membership.Role), nil
+}
+
+func (ou *organizationUsecase) CreateOrganization(org model.Organization, userId uint) (model.OrganizationResponse, error) {
+	if err := ou.ov.OrganizationValidate(org); err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	if err := ou.or.CreateOrganization(&org, userId); err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	return newOrganizationResponse(org, model.OrgRoleOwner), nil
+}
+
+func (ou *organizationUsecase) UpdateOrganization(org model.Organization, userId uint, orgId uint) (model.OrganizationResponse, error) {
+	if err := ou.ov.OrganizationValidate(org); err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	membership, err := ou.requireRole(userId, orgId, model.OrgRoleOwner, model.OrgRoleAdmin)
+	if err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	if err := ou.or.UpdateOrganization(&org, orgId); err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	return newOrganizationResponse(org, membership.Role), nil
+}
+
+func (ou *organizationUsecase) DeleteOrganization(userId uint, orgId uint) error {
+	// 組織の削除は、組織のタスク・プロジェクトも全て消えるのでownerだけができます。
+	if _, err := ou.requireRole(userId, orgId, model.OrgRoleOwner); err != nil {
+		return err
+	}
+	return ou.or.DeleteOrganization(orgId)
+}
+
+func (ou *organizationUsecase) GetMembers(userId uint, orgId uint) ([]model.MemberResponse, error) {
+	if _, err := ou.requireRole(userId, orgId); err != nil {
+		return nil, err
+	}
+	memberships := []model.Membership{}
+	if err := ou.or.GetMembers(&memberships, orgId); err != nil {
+		return nil, err
+	}
+	resMembers := []model.MemberResponse{}
+	for _, v := range memberships {
+		resMembers = append(resMembers, newMemberResponse(v))
+	}
+	return resMembers, nil
+}
+
+func (ou *organizationUsecase) UpdateMember(req model.MemberRequest, userId uint, orgId uint, memberId uint) (model.MemberResponse, error) {
+	if err := ou.ov.MemberValidate(req); err != nil {
+		return model.MemberResponse{}, err
+	}
+	actor, err := ou.requireRole(userId, orgId, model.OrgRoleOwner, model.OrgRoleAdmin)
+	if err != nil {
+		return model.MemberResponse{}, err
+	}
+	target := model.Membership{}
+	if err := ou.or.GetMembership(&target, orgId, memberId); err != nil {
+		return model.MemberResponse{}, err
+	}
+	// ownerの権限を付けたり外したりできるのはownerだけ
+	if (target.Role == model.OrgRoleOwner || req.Role == model.OrgRoleOwner) && actor.Role != model.OrgRoleOwner {
+		return model.MemberResponse{}, permission.ErrForbidden
+	}
+	if target.Role == model.OrgRoleOwner && req.Role != model.OrgRoleOwner {
+		if err := ou.keepOwner(orgId); err != nil {
+			return model.MemberResponse{}, err
+		}
+	}
+	if err := ou.or.UpdateMemberRole(orgId, memberId, req.Role); err != nil {
+		return model.MemberResponse{}, err
+	}
+	if err := ou.ur.GetUserById(&target.User, memberId); err != nil {
+		return model.MemberResponse{}, err
+	}
+	target.Role = req.Role
+	return newMemberResponse(target), nil
+}
+
+func (ou *organizationUsecase) DeleteMember(userId uint, orgId uint, memberId uint) error {
+	actor, err := ou.requireRole(userId, orgId)
+	if err != nil {
+		return err
+	}
+	target := model.Membership{}
+	if err := ou.or.GetMembership(&target, orgId, memberId); err != nil {
+		return err
+	}
+	// 自分が抜ける場合以外は、adminかownerの権限が必要(ownerを外せるのはownerだけ)
+	if memberId != userId {
+		if actor.Role != model.OrgRoleOwner && actor.Role != model.OrgRoleAdmin {
+			return permission.ErrForbidden
+		}
+		if target.Role == model.OrgRoleOwner && actor.Role != model.OrgRoleOwner {
+			return permission.ErrForbidden
+		}
+	}
+	if target.Role == model.OrgRoleOwner {
+		if err := ou.keepOwner(orgId); err != nil {
+			return err
+		}
+	}
+	// 外したメンバーが作成したタスクは組織に残り、ownerとadminが引き続き扱えます。
+	return ou.or.DeleteMember(orgId, memberId)
+}
+
+func (ou *organizationUsecase) GetInvitations(userId uint, orgId uint) ([]model.InvitationResponse, error) {
+	if _, err := ou.requireRole(userId, orgId, model.OrgRoleOwner, model.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	invitations := []model.Invitation{}
+	if err := ou.or.GetInvitations(&invitations, orgId); err != nil {
+		return nil, err
+	}
+	resInvitations := []model.InvitationResponse{}
+	for _, v := range invitations {
+		resInvitations = append(resInvitations, newInvitationResponse(v))
+	}
+	return resInvitations, nil
+}
+
+func (ou *organizationUsecase) CreateInvitation(ctx context.Context, req model.InvitationRequest, userId uint, orgId uint) (model.InvitationResponse, error) {
+	if err := ou.ov.InvitationValidate(req); err != nil {
+		return model.InvitationResponse{}, err
+	}
+	if _, err := ou.requireRole(userId, orgId, model.OrgRoleOwner, model.OrgRoleAdmin); err != nil {
+		return model.InvitationResponse{}, err
+	}
+	org := model.Organization{}
+	if err := ou.or.GetOrganizationById(&org, orgId); err != nil {
+		return model.InvitationResponse{}, err
+	}
+	// 既に登録しているユーザーの場合は、メンバーかどうかを確認してアプリ内でも通知します。
+	invitee := model.User{}
+	err := ou.ur.GetUserByEmail(&invitee, req.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.InvitationResponse{}, err
+	}
+	if err == nil {
+		if err := ou.or.GetMembership(&model.Membership{}, orgId, invitee.ID); err == nil {
+			return model.InvitationResponse{}, fmt.Errorf("user %s is already a member", req.Email)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.InvitationResponse{}, err
+		}
+	}
+
+	// トークンは推測されにくいランダムな値にして、データベースにはハッシュだけを保存します。
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return model.InvitationResponse{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	invitation := model.Invitation{
+		Email:          req.Email,
+		Role:           req.Role,
+		TokenHash:      hashInvitationToken(token),
+		ExpiresAt:      time.Now().Add(invitationLifetime),
+		OrganizationId: orgId,
+		UserId:         userId,
+	}
+	if err := ou.or.CreateInvitation(&invitation); err != nil {
+		return model.InvitationResponse{}, err
+	}
+	if invitee.ID != 0 {
+		notification := model.Notification{
+			Kind:    model.NotificationInvitation,
+			Message: fmt.Sprintf("You were invited to %s as %s", org.Name, req.Role),
+			ActorId: &userId,
+			UserId:  invitee.ID,
+		}
+		// 招待された人はまだ組織のメンバーではないので、組織ではなく個人の通知として作成します。
+		if err := ou.nr.CreateNotifications(tenant.WithOrganization(ctx, 0), []model.Notification{notification}); err != nil {
+			return model.InvitationResponse{}, err
+		}
+	}
+	res := newInvitationResponse(invitation)
+	res.Token = token
+	return res, nil
+}
+
+func (ou *organizationUsecase) DeleteInvitation(userId uint, orgId uint, invitationId uint) error {
+	if _, err := ou.requireRole(userId, orgId, model.OrgRoleOwner, model.OrgRoleAdmin); err != nil {
+		return err
+	}
+	return ou.or.DeleteInvitation(orgId, invitationId)
+}
+
+func (ou *organizationUsecase) AcceptInvitation(req model.AcceptInvitationRequest, userId uint) (model.OrganizationResponse, error) {
+	invitation := model.Invitation{}
+	if err := ou.or.GetInvitationByTokenHash(&invitation, hashInvitationToken(req.Token)); err != nil {
+		return model.OrganizationResponse{}, ErrInvitationInvalid
+	}
+	if invitation.AcceptedAt != nil || !invitation.ExpiresAt.After(time.Now()) {
+		return model.OrganizationResponse{}, ErrInvitationInvalid
+	}
+	// 招待のトークンが他の人に渡っても使えないように、招待したメールアドレスのユーザーだけが受け入れられます。
+	user := model.User{}
+	if err := ou.ur.GetUserById(&user, userId); err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return model.OrganizationResponse{}, ErrInvitationInvalid
+	}
+	if err := ou.or.AcceptInvitation(&invitation, userId); err != nil {
+		return model.OrganizationResponse{}, err
+	}
+	return ou.GetOrganizationById(userId, invitation.OrganizationId)
+}
+
+func (ou *organizationUsecase) CheckMember(userId uint, orgId uint) error {
+	_, err := ou.requireRole(userId, orgId)
+	return err
+}
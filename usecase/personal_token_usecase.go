@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentialsはBasic認証・トークンの認証に失敗した場合のエラー
+// ユーザーが存在しないのか、パスワード・トークンが違うのかは区別せずに返します。
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+type IPersonalTokenUsecase interface {
+	GetTokens(ctx context.Context, userId uint) ([]model.PersonalTokenResponse, error)
+	// CreateTokenはトークンを作成する(トークン自体はこのレスポンスでしか返しません)
+	CreateToken(ctx context.Context, token model.PersonalToken) (model.PersonalTokenResponse, error)
+	DeleteToken(ctx context.Context, userId uint, tokenId uint) error
+	// Authenticateはユーザー名(メールアドレス)と、アカウントのパスワードまたは個人用のアクセストークンでユーザーを認証する
+	// Bearerのトークンの場合はユーザー名を空にします。
+	Authenticate(ctx context.Context, username string, secret string) (uint, error)
+}
+
+type personalTokenUsecase struct {
+	ptr repository.IPersonalTokenRepository
+	ur  repository.IUserRepository
+	ptv validator.IPersonalTokenValidator
+}
+
+// personalTokenPrefixはアクセストークンの先頭に付ける文字列で、パスワードと区別するのに使います。
+const personalTokenPrefix = "pat_"
+
+func NewPersonalTokenUsecase(ptr repository.IPersonalTokenRepository, ur repository.IUserRepository, ptv validator.IPersonalTokenValidator) IPersonalTokenUsecase {
+	return &personalTokenUsecase{ptr, ur, ptv}
+}
+
+func newPersonalTokenResponse(token model.PersonalToken) model.PersonalTokenResponse {
+	return model.PersonalTokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// hashPersonalTokenはデータベースに保存するトークンのハッシュ
+// トークンは十分な長さのランダムな文字列なので、bcryptではなくSHA-256で検索できるようにします。
+func hashPersonalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (ptu *personalTokenUsecase) GetTokens(ctx context.Context, userId uint) ([]model.PersonalTokenResponse, error) {
+	tokens := []model.PersonalToken{}
+	if err := ptu.ptr.GetTokens(ctx, &tokens, userId); err != nil {
+		return nil, err
+	}
+	resTokens := []model.PersonalTokenResponse{}
+	for _, v := range tokens {
+		resTokens = append(resTokens, newPersonalTokenResponse(v))
+	}
+	return resTokens, nil
+}
+
+func (ptu *personalTokenUsecase) CreateToken(ctx context.Context, token model.PersonalToken) (model.PersonalTokenResponse, error) {
+	if err := ptu.ptv.PersonalTokenValidate(token); err != nil {
+		return model.PersonalTokenResponse{}, err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return model.PersonalTokenResponse{}, err
+	}
+	secret := personalTokenPrefix + hex.EncodeToString(b)
+	token.TokenHash = hashPersonalToken(secret)
+	if err := ptu.ptr.CreateToken(ctx, &token); err != nil {
+		return model.PersonalTokenResponse{}, err
+	}
+	res := newPersonalTokenResponse(token)
+	res.Token = secret
+	return res, nil
+}
+
+func (ptu *personalTokenUsecase) DeleteToken(ctx context.Context, userId uint, tokenId uint) error {
+	return ptu.ptr.DeleteToken(ctx, userId, tokenId)
+}
+
+func (ptu *personalTokenUsecase) Authenticate(ctx context.Context, username string, secret string) (uint, error) {
+	if strings.HasPrefix(secret, personalTokenPrefix) {
+		token := model.PersonalToken{}
+		if err := ptu.ptr.GetTokenByHash(ctx, &token, hashPersonalToken(secret)); err != nil {
+			return 0, ErrInvalidCredentials
+		}
+		// Basic認証でユーザー名も送られてきた場合は、トークンの持ち主のメールアドレスと一致するか確認します。
+		if username != "" {
+			user := model.User{}
+			if err := ptu.ur.GetUserById(&user, token.UserId); err != nil || !strings.EqualFold(user.Email, username) {
+				return 0, ErrInvalidCredentials
+			}
+		}
+		return token.UserId, nil
+	}
+	if username == "" {
+		return 0, ErrInvalidCredentials
+	}
+	user := model.User{}
+	if err := ptu.ur.GetUserByEmail(&user, username); err != nil {
+		return 0, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(secret)); err != nil {
+		return 0, ErrInvalidCredentials
+	}
+	return user.ID, nil
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+)
+
+type IProjectUsecase interface {
+	GetProjects(ctx context.Context, userId uint) ([]model.ProjectResponse, error)
+	GetProjectById(ctx context.Context, userId uint, projectId uint) (model.ProjectResponse, error)
+	CreateProject(ctx context.Context, project model.Project) (model.ProjectResponse, error)
+	UpdateProject(ctx context.Context, project model.Project, userId uint, projectId uint) (model.ProjectResponse, error)
+	DeleteProject(ctx context.Context, userId uint, projectId uint) error
+}
+
+type projectUsecase struct {
+	pr repository.IProjectRepository
+	ps permission.IPermissionService
+	pv validator.IProjectValidator
+}
+
+func NewProjectUsecase(pr repository.IProjectRepository, ps permission.IPermissionService, pv validator.IProjectValidator) IProjectUsecase {
+	return &projectUsecase{pr, ps, pv}
+}
+
+func newProjectResponse(project model.Project, role string) model.ProjectResponse {
+	return model.ProjectResponse{
+		ID:             project.ID,
+		Name:           project.Name,
+		UserId:         project.UserId,
+		OrganizationId: project.OrganizationId,
+		Role:           role,
+		CreatedAt:      project.CreatedAt,
+		UpdatedAt:      project.UpdatedAt,
+	}
+}
+
+func (pu *projectUsecase) GetProjects(ctx context.Context, userId uint) ([]model.ProjectResponse, error) {
+	// 自分のプロジェクトと共有されたプロジェクトの両方を返す
+	projects := []model.Project{}
+	if err := pu.pr.GetProjects(ctx, &projects, pu.ps.VisibleProjects(userId)); err != nil {
+		return nil, err
+	}
+	resProjects := []model.ProjectResponse{}
+	for _, v := range projects {
+		role, err := pu.ps.ProjectRole(ctx, userId, v.ID)
+		if err != nil {
+			return nil, err
+		}
+		resProjects = append(resProjects, newProjectResponse(v, role))
+	}
+	return resProjects, nil
+}
+
+func (pu *projectUsecase) GetProjectById(ctx context.Context, userId uint, projectId uint) (model.ProjectResponse, error) {
+	role, err := pu.ps.ProjectRole(ctx, userId, projectId)
+	if err != nil {
+		return model.ProjectResponse{}, err
+	}
+	project := model.Project{}
+	if err := pu.pr.GetProjectById(ctx, &project, projectId); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	return newProjectResponse(project, role), nil
+}
+
+func (pu *projectUsecase) CreateProject(ctx context.Context, project model.Project) (model.ProjectResponse, error) {
+	if err := pu.pv.ProjectValidate(project); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	if err := pu.pr.CreateProject(ctx, &project); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	return newProjectResponse(project, model.RoleOwner), nil
+}
+
+func (pu *projectUsecase) UpdateProject(ctx context.Context, project model.Project, userId uint, projectId uint) (model.ProjectResponse, error) {
+	if err := pu.pv.ProjectValidate(project); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	// プロジェクトの名前を変えられるのは所有者だけ
+	if err := pu.ps.CanManageProject(ctx, userId, projectId); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	if err := pu.pr.UpdateProject(ctx, &project, projectId); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	return newProjectResponse(project, model.RoleOwner), nil
+}
+
+func (pu *projectUsecase) DeleteProject(ctx context.Context, userId uint, projectId uint) error {
+	if err := pu.ps.CanManageProject(ctx, userId, projectId); err != nil {
+		return err
+	}
+	if err := pu.pr.DeleteProject(ctx, projectId); err != nil {
+		return err
+	}
+	return nil
+}
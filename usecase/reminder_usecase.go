@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IReminderUsecase interface {
+	GetReminders(ctx context.Context, userId uint, taskId uint) ([]model.ReminderResponse, error)
+	CreateReminder(ctx context.Context, req model.ReminderRequest, userId uint, taskId uint) (model.ReminderResponse, error)
+	DeleteReminder(ctx context.Context, userId uint, taskId uint, reminderId uint) error
+}
+
+type reminderUsecase struct {
+	rr repository.IReminderRepository
+	// リマインダーを設定するタスクの期限日を読むためにタスクのリポジトリも使います。
+	tr repository.ITaskRepository
+	// リマインダーは自分専用なので、共有されたタスクにもviewerの権限で設定できます。
+	ps permission.IPermissionService
+	// メールのリマインダーの送信先が、ユーザー自身のメールアドレスか確認するために使います。
+	ur repository.IUserRepository
+	rv validator.IReminderValidator
+}
+
+func NewReminderUsecase(rr repository.IReminderRepository, tr repository.ITaskRepository, ps permission.IPermissionService,
+	ur repository.IUserRepository, rv validator.IReminderValidator) IReminderUsecase {
+	return &reminderUsecase{rr, tr, ps, ur, rv}
+}
+
+func newReminderResponse(reminder model.Reminder) model.ReminderResponse {
+	return model.ReminderResponse{
+		ID:            reminder.ID,
+		TaskId:        reminder.TaskId,
+		RemindAt:      reminder.RemindAt,
+		OffsetMinutes: reminder.OffsetMinutes,
+		FireAt:        reminder.FireAt,
+		Channel:       reminder.Channel,
+		Target:        reminder.Target,
+		SentAt:        reminder.SentAt,
+		FailedAt:      reminder.FailedAt,
+		CreatedAt:     reminder.CreatedAt,
+	}
+}
+
+func (ru *reminderUsecase) GetReminders(ctx context.Context, userId uint, taskId uint) ([]model.ReminderResponse, error) {
+	// 他の組織のタスクのリマインダーは見えないように、リクエストの組織でタスクを確認します。
+	if err := ru.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return nil, err
+	}
+	reminders := []model.Reminder{}
+	if err := ru.rr.GetRemindersByTask(ctx, &reminders, userId, taskId); err != nil {
+		return nil, err
+	}
+	resReminders := []model.ReminderResponse{}
+	for _, v := range reminders {
+		resReminders = append(resReminders, newReminderResponse(v))
+	}
+	return resReminders, nil
+}
+
+func (ru *reminderUsecase) CreateReminder(ctx context.Context, req model.ReminderRequest, userId uint, taskId uint) (model.ReminderResponse, error) {
+	if err := ru.rv.ReminderValidate(req); err != nil {
+		return model.ReminderResponse{}, err
+	}
+	if err := ru.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return model.ReminderResponse{}, err
+	}
+	// メールは他人のアドレスに送れないように、ユーザー自身のメールアドレス(ログインに使うアドレス)だけを送信先にできます。
+	if req.Channel == "email" && req.Target != "" {
+		user := model.User{}
+		if err := ru.ur.GetUserById(&user, userId); err != nil {
+			return model.ReminderResponse{}, err
+		}
+		if !strings.EqualFold(req.Target, user.Email) {
+			return model.ReminderResponse{}, validation.Errors{"target": errors.New("must be your own email address")}
+		}
+	}
+	reminder := model.Reminder{
+		RemindAt:      req.RemindAt,
+		OffsetMinutes: req.OffsetMinutes,
+		Channel:       req.Channel,
+		Target:        req.Target,
+	}
+	task := model.Task{}
+	if err := ru.tr.GetTaskById(ctx, &task, taskId); err != nil {
+		return model.ReminderResponse{}, err
+	}
+	reminder.TaskId = task.ID
+	reminder.UserId = userId
+	// 通知時刻を計算しておきます。オフセット指定で期限日が無い場合は、期限日が設定されるまで通知しません。
+	if reminder.RemindAt != nil {
+		reminder.FireAt = reminder.RemindAt
+	} else if task.DueDate != nil {
+		fireAt := task.DueDate.Add(-time.Duration(*reminder.OffsetMinutes) * time.Minute)
+		reminder.FireAt = &fireAt
+	}
+	if err := ru.rr.CreateReminder(ctx, &reminder); err != nil {
+		return model.ReminderResponse{}, err
+	}
+	return newReminderResponse(reminder), nil
+}
+
+func (ru *reminderUsecase) DeleteReminder(ctx context.Context, userId uint, taskId uint, reminderId uint) error {
+	if err := ru.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return err
+	}
+	if err := ru.rr.DeleteReminder(ctx, userId, taskId, reminderId); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+	"go-rest-api/validator"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 公開リンクを開いた時のエラー
+// 無効なリンクの理由(存在しない・期限切れ・無効化済み)は区別せずに返します。
+var (
+	ErrShareLinkInvalid  = errors.New("share link is invalid or expired")
+	ErrShareLinkPassword = errors.New("share link password is required or incorrect")
+)
+
+type IShareLinkUsecase interface {
+	GetShareLinks(ctx context.Context, userId uint, taskId uint) ([]model.ShareLinkResponse, error)
+	CreateShareLink(ctx context.Context, req model.ShareLinkRequest, userId uint, taskId uint) (model.ShareLinkResponse, error)
+	RevokeShareLink(ctx context.Context, userId uint, taskId uint, linkId uint) error
+	// GetSharedTaskは公開リンクのトークンからタスクを取得する(ログインしていないユーザー向け)
+	GetSharedTask(ctx context.Context, token string, password string) (model.PublicTaskResponse, error)
+}
+
+type shareLinkUsecase struct {
+	lr repository.IShareLinkRepository
+	tr repository.ITaskRepository
+	ps permission.IPermissionService
+	lv validator.IShareLinkValidator
+}
+
+// defaultShareLinkLifetimeは有効期限を指定しなかった場合の公開リンクの有効期間
+const defaultShareLinkLifetime = 7 * 24 * time.Hour
+
+func NewShareLinkUsecase(lr repository.IShareLinkRepository, tr repository.ITaskRepository, ps permission.IPermissionService,
+	lv validator.IShareLinkValidator) IShareLinkUsecase {
+	return &shareLinkUsecase{lr, tr, ps, lv}
+}
+
+// shareLinkKeyは公開リンクのトークンを署名する鍵
+// ログイン用のJWTと取り違えられないように、SECRETから別の鍵を作って使います。
+func shareLinkKey() []byte {
+	return []byte("share-link:" + os.Getenv("SECRET"))
+}
+
+func newShareLinkResponse(link model.ShareLink) model.ShareLinkResponse {
+	return model.ShareLinkResponse{
+		ID:           link.ID,
+		TaskId:       link.TaskId,
+		HasPassword:  link.PasswordHash != "",
+		ExpiresAt:    link.ExpiresAt,
+		RevokedAt:    link.RevokedAt,
+		ViewCount:    link.ViewCount,
+		LastViewedAt: link.LastViewedAt,
+		CreatedAt:    link.CreatedAt,
+	}
+}
+
+func (lu *shareLinkUsecase) GetShareLinks(ctx context.Context, userId uint, taskId uint) ([]model.ShareLinkResponse, error) {
+	// 公開リンクを扱えるのは、共有の設定ができるタスクの作成者とプロジェクトの所有者だけ
+	if err := lu.ps.CanDeleteTask(ctx, userId, taskId); err != nil {
+		return nil, err
+	}
+	links := []model.ShareLink{}
+	if err := lu.lr.GetShareLinksByTask(ctx, &links, taskId); err != nil {
+		return nil, err
+	}
+	resLinks := []model.ShareLinkResponse{}
+	for _, v := range links {
+		resLinks = append(resLinks, newShareLinkResponse(v))
+	}
+	return resLinks, nil
+}
+
+func (lu *shareLinkUsecase) CreateShareLink(ctx context.Context, req model.ShareLinkRequest, userId uint, taskId uint) (model.ShareLinkResponse, error) {
+	if err := lu.lv.ShareLinkValidate(req); err != nil {
+		return model.ShareLinkResponse{}, err
+	}
+	if err := lu.ps.CanDeleteTask(ctx, userId, taskId); err != nil {
+		return model.ShareLinkResponse{}, err
+	}
+	link := model.ShareLink{
+		ExpiresAt: time.Now().Add(defaultShareLinkLifetime),
+		TaskId:    taskId,
+		UserId:    userId,
+	}
+	if req.ExpiresAt != nil {
+		link.ExpiresAt = *req.ExpiresAt
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
+		if err != nil {
+			return model.ShareLinkResponse{}, err
+		}
+		link.PasswordHash = string(hash)
+	}
+	if err := lu.lr.CreateShareLink(ctx, &link); err != nil {
+		return model.ShareLinkResponse{}, err
+	}
+	// リンクのIDと有効期限をペイロードにしたJWTをトークンにします。
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"share_link_id": link.ID,
+		"exp":           link.ExpiresAt.Unix(),
+	})
+	tokenString, err := token.SignedString(shareLinkKey())
+	if err != nil {
+		return model.ShareLinkResponse{}, err
+	}
+	res := newShareLinkResponse(link)
+	res.Token = tokenString
+	return res, nil
+}
+
+func (lu *shareLinkUsecase) RevokeShareLink(ctx context.Context, userId uint, taskId uint, linkId uint) error {
+	if err := lu.ps.CanDeleteTask(ctx, userId, taskId); err != nil {
+		return err
+	}
+	return lu.lr.RevokeShareLink(ctx, taskId, linkId)
+}
+
+func (lu *shareLinkUsecase) GetSharedTask(ctx context.Context, tokenString string, password string) (model.PublicTaskResponse, error) {
+	// 署名と有効期限(exp)はjwt.Parseの中で検証されます。
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return shareLinkKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return model.PublicTaskResponse{}, ErrShareLinkInvalid
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return model.PublicTaskResponse{}, ErrShareLinkInvalid
+	}
+	linkId, ok := claims["share_link_id"].(float64)
+	if !ok {
+		return model.PublicTaskResponse{}, ErrShareLinkInvalid
+	}
+	// 公開リンクはログインしていない人が開くので、リンクとタスクはテナントで絞り込まずに取得します。
+	// (トークンの署名を確認した後なので、リンクのIDは改ざんされていません。)
+	ctx = tenant.WithSystem(ctx)
+	link := model.ShareLink{}
+	if err := lu.lr.GetShareLinkById(ctx, &link, uint(linkId)); err != nil {
+		return model.PublicTaskResponse{}, ErrShareLinkInvalid
+	}
+	// 無効化されたリンクと、有効期限を過ぎたリンクは開けません。
+	if link.RevokedAt != nil || !link.ExpiresAt.After(time.Now()) {
+		return model.PublicTaskResponse{}, ErrShareLinkInvalid
+	}
+	if link.PasswordHash != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+			return model.PublicTaskResponse{}, ErrShareLinkPassword
+		}
+	}
+	task := model.Task{}
+	if err := lu.tr.GetTaskById(ctx, &task, link.TaskId); err != nil {
+		return model.PublicTaskResponse{}, ErrShareLinkInvalid
+	}
+	if err := lu.lr.IncrementViewCount(ctx, link.ID); err != nil {
+		return model.PublicTaskResponse{}, err
+	}
+	// 公開リンクではタイトル・説明・完了状態・期限日だけを返します。
+	return model.PublicTaskResponse{
+		Title:       task.Title,
+		Description: task.Description,
+		Completed:   task.Completed,
+		DueDate:     task.DueDate,
+	}, nil
+}
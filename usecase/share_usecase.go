@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+
+	"gorm.io/gorm"
+)
+
+type IShareUsecase interface {
+	GetTaskShares(ctx context.Context, userId uint, taskId uint) ([]model.ShareResponse, error)
+	ShareTask(ctx context.Context, req model.ShareRequest, userId uint, taskId uint) (model.ShareResponse, error)
+	DeleteTaskShare(ctx context.Context, userId uint, taskId uint, shareId uint) error
+	GetProjectShares(ctx context.Context, userId uint, projectId uint) ([]model.ShareResponse, error)
+	ShareProject(ctx context.Context, req model.ShareRequest, userId uint, projectId uint) (model.ShareResponse, error)
+	DeleteProjectShare(ctx context.Context, userId uint, projectId uint, shareId uint) error
+}
+
+type shareUsecase struct {
+	sr repository.IShareRepository
+	// 共有する相手をメールアドレスから探して、共有されたことを通知します。
+	ur repository.IUserRepository
+	nr repository.INotificationRepository
+	ps permission.IPermissionService
+	sv validator.IShareValidator
+}
+
+func NewShareUsecase(sr repository.IShareRepository, ur repository.IUserRepository, nr repository.INotificationRepository,
+	ps permission.IPermissionService, sv validator.IShareValidator) IShareUsecase {
+	return &shareUsecase{sr, ur, nr, ps, sv}
+}
+
+func newShareResponse(share model.Share) model.ShareResponse {
+	return model.ShareResponse{
+		ID:        share.ID,
+		Email:     share.User.Email,
+		Role:      share.Role,
+		TaskId:    share.TaskId,
+		ProjectId: share.ProjectId,
+		CreatedAt: share.CreatedAt,
+	}
+}
+
+func newShareResponses(shares []model.Share) []model.ShareResponse {
+	resShares := []model.ShareResponse{}
+	for _, v := range shares {
+		resShares = append(resShares, newShareResponse(v))
+	}
+	return resShares
+}
+
+func (su *shareUsecase) GetTaskShares(ctx context.Context, userId uint, taskId uint) ([]model.ShareResponse, error) {
+	// 共有先の一覧には他のユーザーのメールアドレスが含まれるので、確認できるのはeditor以上の権限があるユーザーだけです。
+	if err := su.ps.CanEditTask(ctx, userId, taskId); err != nil {
+		return nil, err
+	}
+	shares := []model.Share{}
+	if err := su.sr.GetSharesByTask(ctx, &shares, taskId); err != nil {
+		return nil, err
+	}
+	return newShareResponses(shares), nil
+}
+
+func (su *shareUsecase) ShareTask(ctx context.Context, req model.ShareRequest, userId uint, taskId uint) (model.ShareResponse, error) {
+	if err := su.sv.ShareValidate(req); err != nil {
+		return model.ShareResponse{}, err
+	}
+	// 共有の設定ができるのはタスクの作成者とプロジェクトの所有者だけ
+	if err := su.ps.CanDeleteTask(ctx, userId, taskId); err != nil {
+		return model.ShareResponse{}, err
+	}
+	share := model.Share{Role: req.Role, TaskId: &taskId}
+	message := fmt.Sprintf("Task #%d was shared with you as %s", taskId, req.Role)
+	if err := su.saveShare(ctx, &share, req.Email, userId, message); err != nil {
+		return model.ShareResponse{}, err
+	}
+	return newShareResponse(share), nil
+}
+
+func (su *shareUsecase) DeleteTaskShare(ctx context.Context, userId uint, taskId uint, shareId uint) error {
+	share := model.Share{}
+	if err := su.sr.GetShareById(ctx, &share, shareId); err != nil {
+		return err
+	}
+	if share.TaskId == nil || *share.TaskId != taskId {
+		return repository.ErrObjectNotExist
+	}
+	// 共有された本人は自分で共有を外すことができます。
+	if share.UserId != userId {
+		if err := su.ps.CanDeleteTask(ctx, userId, taskId); err != nil {
+			return err
+		}
+	}
+	return su.sr.DeleteShare(ctx, shareId)
+}
+
+func (su *shareUsecase) GetProjectShares(ctx context.Context, userId uint, projectId uint) ([]model.ShareResponse, error) {
+	// タスクの共有先と同じく、editor以上の権限が必要です。
+	if err := su.ps.CanEditProject(ctx, userId, projectId); err != nil {
+		return nil, err
+	}
+	shares := []model.Share{}
+	if err := su.sr.GetSharesByProject(ctx, &shares, projectId); err != nil {
+		return nil, err
+	}
+	return newShareResponses(shares), nil
+}
+
+func (su *shareUsecase) ShareProject(ctx context.Context, req model.ShareRequest, userId uint, projectId uint) (model.ShareResponse, error) {
+	if err := su.sv.ShareValidate(req); err != nil {
+		return model.ShareResponse{}, err
+	}
+	if err := su.ps.CanManageProject(ctx, userId, projectId); err != nil {
+		return model.ShareResponse{}, err
+	}
+	share := model.Share{Role: req.Role, ProjectId: &projectId}
+	message := fmt.Sprintf("Project #%d was shared with you as %s", projectId, req.Role)
+	if err := su.saveShare(ctx, &share, req.Email, userId, message); err != nil {
+		return model.ShareResponse{}, err
+	}
+	return newShareResponse(share), nil
+}
+
+func (su *shareUsecase) DeleteProjectShare(ctx context.Context, userId uint, projectId uint, shareId uint) error {
+	share := model.Share{}
+	if err := su.sr.GetShareById(ctx, &share, shareId); err != nil {
+		return err
+	}
+	if share.ProjectId == nil || *share.ProjectId != projectId {
+		return repository.ErrObjectNotExist
+	}
+	if share.UserId != userId {
+		if err := su.ps.CanManageProject(ctx, userId, projectId); err != nil {
+			return err
+		}
+	}
+	return su.sr.DeleteShare(ctx, shareId)
+}
+
+// saveShareは共有する相手をメールアドレスから探して共有を保存し、相手に通知する
+func (su *shareUsecase) saveShare(ctx context.Context, share *model.Share, email string, actorId uint, message string) error {
+	recipient := model.User{}
+	if err := su.ur.GetUserByEmail(&recipient, email); err != nil {
+		return fmt.Errorf("user %s does not exist", email)
+	}
+	if recipient.ID == actorId {
+		return fmt.Errorf("cannot share with yourself")
+	}
+	// 組織のタスク・プロジェクトは、その組織のメンバーにしか共有できません。
+	if err := su.ps.MemberOfTenant(ctx, recipient.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user %s is not a member of the organization", email)
+		}
+		return err
+	}
+	share.UserId = recipient.ID
+	if err := su.sr.SaveShare(ctx, share); err != nil {
+		return err
+	}
+	share.User = recipient
+	notification := model.Notification{
+		Kind:    model.NotificationShare,
+		Message: message,
+		TaskId:  share.TaskId,
+		ActorId: &actorId,
+		UserId:  recipient.ID,
+	}
+	return su.nr.CreateNotifications(ctx, []model.Notification{notification})
+}
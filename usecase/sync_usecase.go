@@ -0,0 +1,279 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ISyncUsecaseはオフラインで使えるクライアントとの差分の同期を扱う
+// 同期の範囲は自分のタスク(projectIdがnilの場合)か1つのプロジェクトのタスクで、
+// タスクの取得・作成・更新・削除と権限の確認は全てITaskUsecaseに任せます。
+type ISyncUsecase interface {
+	// GetChangesは同期トークン(since)の時点から作成・更新されたタスクと、削除されたタスクのIDを返す
+	// sinceが空の場合は全てのタスクをCreatedで返します。
+	GetChanges(ctx context.Context, userId uint, projectId *uint, since string) (model.SyncPullResponse, error)
+	// PushChangesはクライアントの変更を順番に適用して、変更ごとの結果を返す
+	// 並行してサーバーで変更された項目は、項目ごとに変更した日時が新しい方を残します(last-writer-wins)。
+	PushChanges(ctx context.Context, userId uint, req model.SyncRequest) (model.SyncPushResponse, error)
+}
+
+type syncUsecase struct {
+	tu ITaskUsecase
+	// 項目ごとの時計(FieldClocks)はレスポンスに含めないので、権限を確認した後にリポジトリから読み込みます。
+	tr repository.ITaskRepository
+	// 作成されたタスクと更新されたタスクを分けるために、変更履歴を使います。
+	tvr repository.ITaskVersionRepository
+	sv  validator.ISyncValidator
+}
+
+func NewSyncUsecase(tu ITaskUsecase, tr repository.ITaskRepository, tvr repository.ITaskVersionRepository, sv validator.ISyncValidator) ISyncUsecase {
+	return &syncUsecase{tu, tr, tvr, sv}
+}
+
+func (su *syncUsecase) GetChanges(ctx context.Context, userId uint, projectId *uint, since string) (model.SyncPullResponse, error) {
+	// 同期トークンはCalDAVと同じタスクの変更履歴のIDです。
+	var sinceId uint
+	if since != "" {
+		id, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			return model.SyncPullResponse{}, ErrInvalidSyncToken
+		}
+		sinceId = uint(id)
+	}
+	filter := model.TaskFilter{Scope: model.TaskScopeOwned, ProjectId: projectId}
+	changes, err := su.tu.GetTaskChanges(ctx, userId, filter, sinceId)
+	if err != nil {
+		return model.SyncPullResponse{}, err
+	}
+	created := map[uint]bool{}
+	if sinceId > 0 {
+		ids := []uint{}
+		for _, t := range changes.Changed {
+			ids = append(ids, t.ID)
+		}
+		createdIds := []uint{}
+		if err := su.tvr.GetCreatedTaskIds(ctx, &createdIds, sinceId, changes.SyncToken, ids); err != nil {
+			return model.SyncPullResponse{}, err
+		}
+		for _, id := range createdIds {
+			created[id] = true
+		}
+	}
+	res := model.SyncPullResponse{
+		SyncToken: strconv.FormatUint(uint64(changes.SyncToken), 10),
+		Created:   []model.TaskResponse{},
+		Updated:   []model.TaskResponse{},
+		// 削除されたタスクは論理削除で行が残るので、それをtombstoneとして返します。
+		Deleted: changes.Deleted,
+	}
+	for _, t := range changes.Changed {
+		if sinceId == 0 || created[t.ID] {
+			res.Created = append(res.Created, t)
+		} else {
+			res.Updated = append(res.Updated, t)
+		}
+	}
+	return res, nil
+}
+
+func (su *syncUsecase) PushChanges(ctx context.Context, userId uint, req model.SyncRequest) (model.SyncPushResponse, error) {
+	if err := su.sv.SyncValidate(req); err != nil {
+		return model.SyncPushResponse{}, err
+	}
+	res := model.SyncPushResponse{Results: []model.SyncChangeResult{}}
+	now := time.Now()
+	for i, change := range req.Changes {
+		origin := model.SyncOrigin{Node: req.ClientId, At: change.ModifiedAt}
+		// 時計が進んでいるクライアントの変更が、後から行われた変更に勝ち続けないようにします。
+		if origin.At.After(now) {
+			origin.At = now
+		}
+		result := model.SyncChangeResult{Index: i, Op: change.Op, TaskId: change.TaskId, ClientRef: change.ClientRef}
+		var err error
+		switch change.Op {
+		case model.SyncOpCreate:
+			err = su.applyCreate(ctx, userId, change, origin, &result)
+		case model.SyncOpUpdate:
+			err = su.applyUpdate(ctx, userId, change, origin, &result)
+		case model.SyncOpDelete:
+			err = su.applyDelete(ctx, userId, change, origin, &result)
+		}
+		if err != nil {
+			// リクエストが切れた場合は、残りの変更も適用できないので中断します。
+			if ctx.Err() != nil {
+				return model.SyncPushResponse{}, ctx.Err()
+			}
+			// 1つの変更の失敗で他の変更を止めないように、結果に理由を入れて次の変更に進みます。
+			result.Status = model.SyncStatusFailed
+			result.Error = err.Error()
+			result.Task = nil
+		}
+		res.Results = append(res.Results, result)
+	}
+	return res, nil
+}
+
+func (su *syncUsecase) applyCreate(ctx context.Context, userId uint, change model.SyncChange, origin model.SyncOrigin, result *model.SyncChangeResult) error {
+	incoming, err := decodeSyncFields(change.Fields)
+	if err != nil {
+		return err
+	}
+	task := model.Task{UserId: userId, SyncOrigin: &origin}
+	for name := range change.Fields {
+		copySyncField(name, &task, &incoming)
+	}
+	created, err := su.tu.CreateTask(ctx, task)
+	if err != nil {
+		return err
+	}
+	result.TaskId = created.ID
+	result.Status = model.SyncStatusApplied
+	result.Task = &created
+	return nil
+}
+
+func (su *syncUsecase) applyUpdate(ctx context.Context, userId uint, change model.SyncChange, origin model.SyncOrigin, result *model.SyncChangeResult) error {
+	incoming, err := decodeSyncFields(change.Fields)
+	if err != nil {
+		return err
+	}
+	current, res, err := su.getSyncTask(ctx, userId, change.TaskId)
+	if err != nil {
+		return err
+	}
+	// 変更しない項目は今の値のままにして、タスクの更新と同じように全ての項目を渡します。
+	merged := model.Task{
+		Title:        current.Title,
+		Description:  current.Description,
+		Completed:    current.Completed,
+		DueDate:      current.DueDate,
+		ProjectId:    current.ProjectId,
+		CustomFields: current.CustomFields,
+		Tags:         current.Tags,
+		SyncOrigin:   &origin,
+	}
+	applied := 0
+	for _, name := range model.SyncFields {
+		if _, ok := change.Fields[name]; !ok {
+			continue
+		}
+		clock, ok := current.FieldClocks[name]
+		if ok && !clientWins(clock, change.BaseVector, origin) {
+			result.Conflicts = append(result.Conflicts, name)
+			continue
+		}
+		copySyncField(name, &merged, &incoming)
+		applied++
+	}
+	if applied == 0 {
+		result.Status = model.SyncStatusRejected
+		result.Task = &res
+		return nil
+	}
+	updated, err := su.tu.UpdateTask(ctx, merged, userId, change.TaskId)
+	if err != nil {
+		return err
+	}
+	result.Status = model.SyncStatusApplied
+	if len(result.Conflicts) > 0 {
+		result.Status = model.SyncStatusMerged
+	}
+	result.Task = &updated
+	return nil
+}
+
+func (su *syncUsecase) applyDelete(ctx context.Context, userId uint, change model.SyncChange, origin model.SyncOrigin, result *model.SyncChangeResult) error {
+	current, res, err := su.getSyncTask(ctx, userId, change.TaskId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 既に削除されているタスクは、削除できたものとして扱います。
+		result.Status = model.SyncStatusApplied
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// クライアントが知らない、削除より新しい変更がサーバーにある場合は、その変更を残して削除しません。
+	for _, name := range model.SyncFields {
+		if clock, ok := current.FieldClocks[name]; ok && !clientWins(clock, change.BaseVector, origin) {
+			result.Conflicts = append(result.Conflicts, name)
+		}
+	}
+	if len(result.Conflicts) > 0 {
+		result.Status = model.SyncStatusRejected
+		result.Task = &res
+		return nil
+	}
+	if err := su.tu.DeleteTask(ctx, userId, change.TaskId); err != nil {
+		return err
+	}
+	result.Status = model.SyncStatusApplied
+	return nil
+}
+
+// getSyncTaskはユーザーが見られるかを確認してから、項目ごとの時計を含むタスクとレスポンス用のタスクを取得する
+func (su *syncUsecase) getSyncTask(ctx context.Context, userId uint, taskId uint) (model.Task, model.TaskResponse, error) {
+	res, err := su.tu.GetTaskById(ctx, userId, taskId)
+	if err != nil {
+		return model.Task{}, model.TaskResponse{}, err
+	}
+	current := model.Task{}
+	if err := su.tr.GetTaskById(ctx, &current, taskId); err != nil {
+		return model.Task{}, model.TaskResponse{}, err
+	}
+	return current, res, nil
+}
+
+// clientWinsはクライアントの項目の変更を、サーバーの今の値(clockが最後の変更)より優先するかを判定する
+func clientWins(clock model.FieldClock, base model.VersionVector, origin model.SyncOrigin) bool {
+	// 最後の変更をクライアントが取得済み(バージョンベクターに含まれる)か、クライアント自身の変更の場合は、
+	// 並行した変更は無いので、クライアントの変更の方が後です。
+	if clock.Counter <= base[clock.Node] || clock.Node == origin.Node {
+		return true
+	}
+	// 並行した変更は、変更した日時が新しい方を残します(同じ日時の場合はノードのIDで決めます)。
+	if !origin.At.Equal(clock.At) {
+		return origin.At.After(clock.At)
+	}
+	return origin.Node > clock.Node
+}
+
+// decodeSyncFieldsは変更した項目の値を、タスクのJSONと同じ形式で読み込む
+func decodeSyncFields(fields map[string]json.RawMessage) (model.Task, error) {
+	task := model.Task{}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := json.Unmarshal(b, &task); err != nil {
+		return model.Task{}, err
+	}
+	return task, nil
+}
+
+// copySyncFieldはタスクの同期する項目の値を、srcからdstにコピーする
+func copySyncField(name string, dst *model.Task, src *model.Task) {
+	switch name {
+	case model.SyncFieldTitle:
+		dst.Title = src.Title
+	case model.SyncFieldDescription:
+		dst.Description = src.Description
+	case model.SyncFieldCompleted:
+		dst.Completed = src.Completed
+	case model.SyncFieldDueDate:
+		dst.DueDate = src.DueDate
+	case model.SyncFieldProjectId:
+		dst.ProjectId = src.ProjectId
+	case model.SyncFieldCustomFields:
+		dst.CustomFields = src.CustomFields
+	case model.SyncFieldTags:
+		dst.Tags = src.Tags
+	}
+}
@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/model"
+)
+
+// assignはタスクの担当者をassigneeIdに変更して、変更履歴の記録と通知を行う
+// 担当者にできるユーザーかどうかは、呼び出し元で確認しておきます。
+func (tu *taskUsecase) assign(ctx context.Context, task *model.Task, assigneeId *uint, actorId uint) error {
+	previousId := task.AssigneeId
+	if sameId(previousId, assigneeId) {
+		return nil
+	}
+	before := *task
+	err := tu.tx.Do(ctx, func(ctx context.Context) error {
+		if err := tu.tr.UpdateAssignee(ctx, task.ID, assigneeId); err != nil {
+			return err
+		}
+		task.AssigneeId = assigneeId
+		return tu.recordVersion(ctx, model.TaskActionUpdate, &before, task, actorId)
+	})
+	if err != nil {
+		return err
+	}
+	assignment := model.TaskAssignment{
+		TaskId:             task.ID,
+		AssigneeId:         assigneeId,
+		PreviousAssigneeId: previousId,
+		ActorId:            actorId,
+	}
+	if err := tu.tar.CreateAssignment(&assignment); err != nil {
+		return err
+	}
+	return tu.notifyAssignment(ctx, *task, previousId, actorId)
+}
+
+// notifyAssignmentは担当者が変わったことを、タスクの作成者と新旧の担当者に通知する(変更した本人には通知しません)
+func (tu *taskUsecase) notifyAssignment(ctx context.Context, task model.Task, previousId *uint, actorId uint) error {
+	notifications := []model.Notification{}
+	notified := map[uint]bool{actorId: true}
+	add := func(userId uint, message string) {
+		if notified[userId] {
+			return
+		}
+		notified[userId] = true
+		notifications = append(notifications, model.Notification{
+			Kind:    model.NotificationAssignment,
+			Message: message,
+			TaskId:  &task.ID,
+			ActorId: &actorId,
+			UserId:  userId,
+		})
+	}
+	if task.AssigneeId != nil {
+		add(*task.AssigneeId, fmt.Sprintf("Task #%d was assigned to you", task.ID))
+	}
+	if previousId != nil {
+		add(*previousId, fmt.Sprintf("You were unassigned from task #%d", task.ID))
+	}
+	add(task.UserId, fmt.Sprintf("Assignee of task #%d was changed", task.ID))
+	return tu.nr.CreateNotifications(ctx, notifications)
+}
+
+func (tu *taskUsecase) AssignTask(ctx context.Context, req model.TaskAssignRequest, userId uint, taskId uint) (model.TaskResponse, error) {
+	// 担当者を変更できるのはeditor以上の権限があるユーザー
+	if err := tu.ps.CanEditTask(ctx, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	task := model.Task{}
+	if err := tu.tr.GetTaskById(ctx, &task, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if req.AssigneeId != nil {
+		if err := tu.ps.CanBeAssigned(ctx, *req.AssigneeId, task); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	if err := tu.assign(ctx, &task, req.AssigneeId, userId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	return newTaskResponse(task), nil
+}
+
+func (tu *taskUsecase) GetTaskAssignments(ctx context.Context, userId uint, taskId uint) ([]model.TaskAssignmentResponse, error) {
+	if err := tu.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return nil, err
+	}
+	assignments := []model.TaskAssignment{}
+	if err := tu.tar.GetAssignments(&assignments, taskId); err != nil {
+		return nil, err
+	}
+	resAssignments := []model.TaskAssignmentResponse{}
+	for _, v := range assignments {
+		resAssignments = append(resAssignments, model.TaskAssignmentResponse{
+			AssigneeId:         v.AssigneeId,
+			PreviousAssigneeId: v.PreviousAssigneeId,
+			ActorId:            v.ActorId,
+			CreatedAt:          v.CreatedAt,
+		})
+	}
+	return resAssignments, nil
+}
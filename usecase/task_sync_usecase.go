@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/tenant"
+)
+
+// ErrInvalidSyncTokenは同期トークンがこのサーバーで発行したものではない場合のエラー
+var ErrInvalidSyncToken = errors.New("sync token is invalid")
+
+// GetTaskChangesは同期トークンの時点から変更のあったタスクを返す
+// 同期トークンはタスクの変更履歴(task_versions)のIDで、返すトークンはその時点の最新の履歴のIDです。
+// 履歴のスナップショットで候補のタスクを探してから、今のタスクを絞り込みの条件で取得し直すので、
+// 削除されたタスク(deleted_atが設定されたタスク)と、条件に当てはまらなくなったタスク(プロジェクトの移動)はDeletedになります。
+func (tu *taskUsecase) GetTaskChanges(ctx context.Context, userId uint, filter model.TaskFilter, since uint) (model.TaskChanges, error) {
+	var latest uint
+	if err := tu.tvr.GetLatestVersionId(ctx, &latest); err != nil {
+		return model.TaskChanges{}, err
+	}
+	if since > latest {
+		return model.TaskChanges{}, ErrInvalidSyncToken
+	}
+	versionFilter := model.TaskVersionFilter{}
+	if t, ok := tenant.FromContext(ctx); ok {
+		versionFilter.OrganizationId = t.OrganizationIdOrNil()
+	}
+	if filter.ProjectId != nil {
+		versionFilter.ProjectId = filter.ProjectId
+	} else if filter.Scope == "" || filter.Scope == model.TaskScopeOwned {
+		versionFilter.UserId = &userId
+	} else {
+		// 共有されたタスクは共有の設定の変更を履歴から追えないので、同期には対応しません。
+		return model.TaskChanges{}, errors.New("sync is only available for owned tasks or a project")
+	}
+	changes := model.TaskChanges{SyncToken: latest, Deleted: []uint{}}
+	if since > 0 {
+		taskIds := []uint{}
+		if err := tu.tvr.GetChangedTaskIds(ctx, &taskIds, since, latest, versionFilter); err != nil {
+			return model.TaskChanges{}, err
+		}
+		filter.TaskIds = taskIds
+	}
+	tasks, err := tu.GetAllTasks(ctx, userId, filter)
+	if err != nil {
+		return model.TaskChanges{}, err
+	}
+	changes.Changed = tasks
+	current := map[uint]bool{}
+	for _, t := range tasks {
+		current[t.ID] = true
+	}
+	if since == 0 {
+		return changes, nil
+	}
+	// 削除されたタスク(tombstone)は、論理削除したタスクのdeleted_atから取得します。
+	deleted := []uint{}
+	if err := tu.tr.GetDeletedTaskIds(ctx, &deleted, filter.TaskIds); err != nil {
+		return model.TaskChanges{}, err
+	}
+	tombstones := map[uint]bool{}
+	for _, id := range deleted {
+		tombstones[id] = true
+	}
+	changes.Deleted = append(changes.Deleted, deleted...)
+	// 削除されていなくても、絞り込みの条件に当てはまらなくなったタスク(プロジェクトの移動など)は削除されたものとして返します。
+	for _, id := range filter.TaskIds {
+		if !current[id] && !tombstones[id] {
+			changes.Deleted = append(changes.Deleted, id)
+		}
+	}
+	return changes, nil
+}
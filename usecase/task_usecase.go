@@ -1,44 +1,146 @@
 package usecase
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/pubsub"
+	"go-rest-api/rank"
 	"go-rest-api/repository"
+	"go-rest-api/rrule"
+	"go-rest-api/taskquery"
 	"go-rest-api/validator"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
+// ErrRRuleRequiresFutureScopeは「この回のみ」の更新で繰り返しのルールを変えようとした場合のエラー
+// ルールを変える場合は、scope=future(この回以降すべて)で更新します。
+var ErrRRuleRequiresFutureScope = errors.New("rrule can only be changed with scope=future")
+
 type ITaskUsecase interface {
-	GetAllTasks(userId uint) ([]model.TaskResponse, error)
-	GetTaskById(userId uint, taskId uint) (model.TaskResponse, error)
-	CreateTask(task model.Task) (model.TaskResponse, error)
-	UpdateTask(task model.Task, userId uint, taskId uint) (model.TaskResponse, error)
-	DeleteTask(userId uint, taskId uint) error
+	GetAllTasks(ctx context.Context, userId uint, filter model.TaskFilter) ([]model.TaskResponse, error)
+	GetTaskById(ctx context.Context, userId uint, taskId uint) (model.TaskResponse, error)
+	CreateTask(ctx context.Context, task model.Task) (model.TaskResponse, error)
+	// CreateTasksは複数のタスクを1つのトランザクションでまとめて作成する(テンプレートと複製で使います)
+	// 繰り返し・担当者・ボードの列は設定せず、タスクはユーザーの一覧の末尾に渡した順番で追加します。
+	CreateTasks(ctx context.Context, tasks []model.Task, userId uint) ([]model.TaskResponse, error)
+	// ExportTasksはGetAllTasksと同じ条件のタスクを、少しずつ読み込んでfnに渡す(並び順はタスクのIDの順番)
+	ExportTasks(ctx context.Context, userId uint, filter model.TaskFilter, fn func(tasks []model.TaskResponse) error) error
+	// ImportTasksはインポートするファイルの行からタスクを作成して、行ごとの結果を返す
+	// 誤りのある行と重複している行は作成せずに、それ以外の行を1つのトランザクションで作成します(dryRunの場合は作成しません)。
+	ImportTasks(ctx context.Context, userId uint, rows []model.TaskImportRow, dryRun bool) (model.TaskImportResult, error)
+	UpdateTask(ctx context.Context, task model.Task, userId uint, taskId uint) (model.TaskResponse, error)
+	// UpdateFutureTasksは繰り返しタスクの「この回以降すべて」を更新する
+	// (UpdateTaskは「この回のみ」の更新)
+	UpdateFutureTasks(ctx context.Context, task model.Task, userId uint, taskId uint) (model.TaskResponse, error)
+	DeleteTask(ctx context.Context, userId uint, taskId uint) error
+	// MoveTaskはタスクを指定したタスクの前後に移動する(更新するのは移動したタスクの1行だけ)
+	MoveTask(ctx context.Context, req model.TaskMoveRequest, userId uint, taskId uint) (model.TaskResponse, error)
+	// GetTaskHistoryはタスクの変更履歴を新しい順に返す
+	GetTaskHistory(ctx context.Context, userId uint, taskId uint) ([]model.TaskVersionResponse, error)
+	// RestoreTaskVersionはタスクを指定したバージョンの内容に戻す(削除されたタスクも復元できる)
+	RestoreTaskVersion(ctx context.Context, userId uint, taskId uint, version int) (model.TaskResponse, error)
+	// AssignTaskはタスクの担当者を変更する(assignee_idがnullの場合は担当者を外す)
+	AssignTask(ctx context.Context, req model.TaskAssignRequest, userId uint, taskId uint) (model.TaskResponse, error)
+	// GetTaskAssignmentsはタスクの担当者の変更履歴を新しい順に返す
+	GetTaskAssignments(ctx context.Context, userId uint, taskId uint) ([]model.TaskAssignmentResponse, error)
+	// GetTaskChangesは同期トークン(since)の時点から変更のあったタスクと、新しい同期トークンを返す
+	// sinceが0の場合は条件に当てはまる全てのタスクを返します(自分のタスクかプロジェクトの絞り込みだけに対応)。
+	GetTaskChanges(ctx context.Context, userId uint, filter model.TaskFilter, since uint) (model.TaskChanges, error)
 }
 
 type taskUsecase struct {
 	// taskUsecase構造体はtrというフィールド名で、repositoryパッケージ内のITaskRepositoryインターフェースの値を格納
 	tr repository.ITaskRepository
+	// 繰り返しタスクのシリーズを保存するためのリポジトリ
+	tsr repository.ITaskSeriesRepository
+	// 期限日の変更をリマインダーの通知時刻に反映するためのリポジトリ
+	rr repository.IReminderRepository
+	// タスクの変更履歴を保存するためのリポジトリ
+	tvr repository.ITaskVersionRepository
+	// 担当者の変更履歴を保存して、担当者が変わったことを通知するためのリポジトリ
+	tar repository.ITaskAssignmentRepository
+	nr  repository.INotificationRepository
+	// プロジェクトの独自の項目の定義を読み込んで、タスクの値をチェックするためのリポジトリ
+	cfr repository.ICustomFieldRepository
+	// 共有されたタスクも含めてアクセス権を判定するサービス
+	ps permission.IPermissionService
 	// taskUsecase構造体のフィールドにITaskValidatorのtvというフィールドを追加
 	tv validator.ITaskValidator
+	// タスクの変更を/eventsに接続しているクライアントに送るためのハブ
+	h pubsub.IHub
+	// 繰り返しのシリーズの分割のように、複数のリポジトリへの書き込みを1つのトランザクションにまとめるために使います。
+	tx repository.ITransaction
 }
 
 // NewTaskUsecaseのコンストラクターは引数で外側でインスタンス化されるタスクリポジトリー(tr)を受け取り、
 // その値を使ってtaskUsecase構造体の実体を生成
 // NewTaskUsecaseのコンストラクターに外側でインスタンス化されるITaskValidatorを注入できるように
 // するために引数のところにtv validator.ITaskValidatorを追加します。
-func NewTaskUsecase(tr repository.ITaskRepository, tv validator.ITaskValidator) ITaskUsecase {
+func NewTaskUsecase(tr repository.ITaskRepository, tsr repository.ITaskSeriesRepository, rr repository.IReminderRepository,
+	tvr repository.ITaskVersionRepository, tar repository.ITaskAssignmentRepository, nr repository.INotificationRepository,
+	cfr repository.ICustomFieldRepository, ps permission.IPermissionService, tv validator.ITaskValidator, h pubsub.IHub,
+	tx repository.ITransaction) ITaskUsecase {
 	// &でアドレスを取得してリターンで返す
 	// そしてタスクユースケースをインスタンス化するフィールドのところにtvを追加
-	return &taskUsecase{tr, tv}
+	return &taskUsecase{tr, tsr, rr, tvr, tar, nr, cfr, ps, tv, h, tx}
+}
+
+// newTaskResponseはTask構造体からクライアントへのレスポンス用のTaskResponse構造体を作成する
+// 繰り返しタスクの場合は、シリーズ(task.Series)が読み込まれていればRRuleとTimezoneも含めます。
+func newTaskResponse(task model.Task) model.TaskResponse {
+	res := model.TaskResponse{
+		ID:             task.ID,
+		Title:          task.Title,
+		Description:    task.Description,
+		Completed:      task.Completed,
+		DueDate:        task.DueDate,
+		SeriesId:       task.SeriesId,
+		RecurrenceId:   task.RecurrenceId,
+		Position:       task.Position,
+		ProjectId:      task.ProjectId,
+		UserId:         task.UserId,
+		OrganizationId: task.OrganizationId,
+		AssigneeId:     task.AssigneeId,
+		ParentId:       task.ParentId,
+		ColumnId:       task.ColumnId,
+		ColumnPosition: task.ColumnPosition,
+		CustomFields:   task.CustomFields,
+		Tags:           task.Tags,
+		VersionVector:  task.SyncVector,
+		CreatedAt:      task.CreatedAt,
+		UpdatedAt:      task.UpdatedAt,
+	}
+	// タグが無い場合もnullではなく空の配列を返します。
+	if res.Tags == nil {
+		res.Tags = model.TaskTags{}
+	}
+	if task.Series != nil {
+		res.RRule = task.Series.RRule
+		res.Timezone = task.Series.Timezone
+	}
+	return res
 }
 
-// GetAllTasksは、引数でユーザーID(userId)を受け取り、
+// GetAllTasksは、引数でユーザーID(userId)と絞り込みの条件(filter)を受け取り、
 // 返り値の1つ目の型として、modelパッケージで定義したTaskResponse構造体の配列の型を指定
 // そして、2つ目の返り値の型はerrorインターフェース型
-func (tu *taskUsecase) GetAllTasks(userId uint) ([]model.TaskResponse, error) {
+func (tu *taskUsecase) GetAllTasks(ctx context.Context, userId uint, filter model.TaskFilter) ([]model.TaskResponse, error) {
+	visible, err := tu.resolveTaskFilter(ctx, userId, &filter)
+	if err != nil {
+		return nil, err
+	}
 	// 取得するタスク一覧を格納するためのTask構造体のスライスを定義
 	tasks := []model.Task{}
-	//taskリポジトリのGetAllTasksを呼び出しtasksのアドレスとuserIdを引数で渡す
-	if err := tu.tr.GetAllTasks(&tasks, userId); err != nil {
+	//taskリポジトリのGetAllTasksを呼び出しtasksのアドレスと絞り込みの条件を引数で渡す
+	if err := tu.tr.GetAllTasks(ctx, &tasks, filter, visible); err != nil {
 		// エラーが返ってきた場合は、1つ目の返り値としてnilスライス、2つ目の返り値としてエラーを返す
 		return nil, err
 	}
@@ -46,12 +148,7 @@ func (tu *taskUsecase) GetAllTasks(userId uint) ([]model.TaskResponse, error) {
 	resTasks := []model.TaskResponse{}
 	// for rangeでタtasksからはタスクを一つ一つ取り出し、タTaskResponse構造体を新しく作る
 	for _, v := range tasks {
-		t := model.TaskResponse{
-			ID:        v.ID,
-			Title:     v.Title,
-			CreatedAt: v.CreatedAt,
-			UpdatedAt: v.UpdatedAt,
-		}
+		t := newTaskResponse(v)
 		// 作成した新しい構造体をresTasksのスライスにappendで追加
 		resTasks = append(resTasks, t)
 	}
@@ -59,81 +156,852 @@ func (tu *taskUsecase) GetAllTasks(userId uint) ([]model.TaskResponse, error) {
 	return resTasks, nil
 }
 
-func (tu *taskUsecase) GetTaskById(userId uint, taskId uint) (model.TaskResponse, error) {
+// resolveTaskFilterは絞り込みの条件に合わせてアクセス権のスコープを選び、独自の項目と検索クエリの条件を解決する
+// (タスクの一覧とエクスポートで同じ条件を使います)
+func (tu *taskUsecase) resolveTaskFilter(ctx context.Context, userId uint, filter *model.TaskFilter) (func(db *gorm.DB) *gorm.DB, error) {
+	// 絞り込みの種類に合わせて、アクセス権のスコープを選ぶ
+	visible := tu.ps.OwnedTasks(userId)
+	switch filter.Scope {
+	case model.TaskScopeShared:
+		visible = tu.ps.SharedTasks(userId)
+	case model.TaskScopeAll:
+		visible = tu.ps.VisibleTasks(userId)
+	}
+	if filter.ProjectId != nil {
+		// プロジェクトで絞り込む場合は、プロジェクトの中の見えるタスクを全て返す
+		if err := tu.ps.CanViewProject(ctx, userId, *filter.ProjectId); err != nil {
+			return nil, err
+		}
+		visible = tu.ps.VisibleTasks(userId)
+	}
+	if filter.ProjectIds != nil {
+		// 複数のプロジェクトで絞り込む場合は、見えるプロジェクトのIDを渡すので、アクセス権の確認はタスクのスコープだけで行います。
+		visible = tu.ps.VisibleTasks(userId)
+	}
+	if filter.AssigneeId != nil {
+		// 担当者で絞り込む場合は、他のユーザーが作成したタスクも含めて見えるタスクを全て対象にする
+		visible = tu.ps.VisibleTasks(userId)
+	}
+	// 独自の項目で絞り込み・並び替えをする場合は、項目の定義から値の種類を決めます。
+	if err := tu.resolveCustomFieldFilter(ctx, userId, filter); err != nil {
+		return nil, err
+	}
+	if err := tu.parseTaskQuery(ctx, userId, filter); err != nil {
+		return nil, err
+	}
+	return visible, nil
+}
+
+// exportBatchSizeはエクスポートでデータベースから1回に読み込むタスクの数
+const exportBatchSize = 500
+
+func (tu *taskUsecase) ExportTasks(ctx context.Context, userId uint, filter model.TaskFilter, fn func(tasks []model.TaskResponse) error) error {
+	visible, err := tu.resolveTaskFilter(ctx, userId, &filter)
+	if err != nil {
+		return err
+	}
+	return tu.tr.ExportTasks(ctx, filter, visible, exportBatchSize, func(tasks []model.Task) error {
+		resTasks := make([]model.TaskResponse, 0, len(tasks))
+		for _, v := range tasks {
+			resTasks = append(resTasks, newTaskResponse(v))
+		}
+		return fn(resTasks)
+	})
+}
+
+func (tu *taskUsecase) ImportTasks(ctx context.Context, userId uint, rows []model.TaskImportRow, dryRun bool) (model.TaskImportResult, error) {
+	result := model.TaskImportResult{DryRun: dryRun, Total: len(rows), Rows: []model.TaskImportRowResult{}}
+	tasks := []model.Task{}
+	// indexesは作成するタスク(tasks)の、result.Rowsの中の位置
+	indexes := []int{}
+	// seenはファイルの中で同じタスクが2回以上出てくる場合に、2回目以降を重複にするためのキー
+	seen := map[string]bool{}
+	for _, row := range rows {
+		res := model.TaskImportRowResult{Row: row.Row, Title: row.Item.Title, Status: model.TaskImportError, Error: row.Error}
+		if row.Error == "" {
+			task := model.Task{
+				Title:        row.Item.Title,
+				Description:  row.Item.Description,
+				Completed:    row.Item.Completed,
+				DueDate:      row.Item.DueDate,
+				ProjectId:    row.Item.ProjectId,
+				CustomFields: row.Item.CustomFields,
+				Tags:         row.Item.Tags,
+				ParentId:     row.Item.ParentId,
+			}
+			duplicate, err := tu.isDuplicate(ctx, userId, task, seen)
+			if err != nil {
+				return model.TaskImportResult{}, err
+			}
+			if duplicate {
+				res.Status = model.TaskImportDuplicate
+			} else if err := tu.prepareTask(ctx, &task, userId); err != nil {
+				// 権限とバリデーションはタスクの作成と同じルールで確認します。
+				res.Error = err.Error()
+			} else {
+				res.Status = model.TaskImportValid
+				tasks = append(tasks, task)
+				indexes = append(indexes, len(result.Rows))
+			}
+		}
+		result.Rows = append(result.Rows, res)
+	}
+	if !dryRun && len(tasks) > 0 {
+		// 誤りの無い行は、1つのトランザクションでまとめて作成します。
+		if err := tu.insertTasks(ctx, tasks, userId); err != nil {
+			return model.TaskImportResult{}, err
+		}
+		for i, task := range tasks {
+			result.Rows[indexes[i]].Status = model.TaskImportCreated
+			result.Rows[indexes[i]].TaskId = task.ID
+		}
+	}
+	for _, v := range result.Rows {
+		switch v.Status {
+		case model.TaskImportCreated:
+			result.Created++
+		case model.TaskImportValid:
+			result.Valid++
+		case model.TaskImportDuplicate:
+			result.Duplicates++
+		case model.TaskImportError:
+			result.Errors++
+		}
+	}
+	return result, nil
+}
+
+// isDuplicateはタイトル・期限日・プロジェクト・親のタスクが同じタスクが、既にあるかファイルの前の行にあるかを判定する
+func (tu *taskUsecase) isDuplicate(ctx context.Context, userId uint, task model.Task, seen map[string]bool) (bool, error) {
+	key := task.Title + "\x00"
+	if task.DueDate != nil {
+		key += task.DueDate.UTC().Format(time.RFC3339Nano)
+	}
+	key += "\x00"
+	if task.ProjectId != nil {
+		key += strconv.FormatUint(uint64(*task.ProjectId), 10)
+	}
+	key += "\x00"
+	if task.ParentId != nil {
+		key += strconv.FormatUint(uint64(*task.ParentId), 10)
+	}
+	if seen[key] {
+		return true, nil
+	}
+	seen[key] = true
+	return tu.tr.ExistsTask(ctx, userId, task.Title, task.DueDate, task.ProjectId, task.ParentId)
+}
+
+// resolveCustomFieldFilterは絞り込みと並び替えに使う独自の項目の定義を読み込んで、値の種類を設定する
+// 項目のプロジェクトを見られないユーザーは、その項目で絞り込み・並び替えはできません。
+func (tu *taskUsecase) resolveCustomFieldFilter(ctx context.Context, userId uint, filter *model.TaskFilter) error {
+	fieldType := func(fieldId uint) (string, error) {
+		field := model.CustomField{}
+		if err := tu.cfr.GetFieldById(ctx, &field, fieldId); err != nil {
+			return "", err
+		}
+		if err := tu.ps.CanViewProject(ctx, userId, field.ProjectId); err != nil {
+			return "", err
+		}
+		return field.Type, nil
+	}
+	for i := range filter.CustomFields {
+		t, err := fieldType(filter.CustomFields[i].FieldId)
+		if err != nil {
+			return err
+		}
+		filter.CustomFields[i].Type = t
+	}
+	if filter.Sort.Key == model.TaskSortCustomField {
+		t, err := fieldType(filter.Sort.FieldId)
+		if err != nil {
+			return err
+		}
+		filter.Sort.FieldType = t
+	}
+	return tu.tv.TaskFilterValidate(*filter)
+}
+
+// parseTaskQueryは検索クエリをパースして、キーに使われた独自の項目の定義と一緒にfilter.ParsedQueryに設定する
+// 項目の名前は、ユーザーが見られるプロジェクトの項目の中から探します。
+func (tu *taskUsecase) parseTaskQuery(ctx context.Context, userId uint, filter *model.TaskFilter) error {
+	if filter.Query == "" {
+		return nil
+	}
+	node, err := taskquery.Parse(filter.Query)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	ids := []uint{}
+	for _, t := range taskquery.Terms(node) {
+		key := strings.ToLower(t.Key)
+		switch key {
+		case model.TaskQueryStatus, model.TaskQueryTitle, model.TaskQueryDue, model.TaskQueryCreated, model.TaskQueryProject, model.TaskQueryAssignee,
+			model.TaskQueryTag:
+			continue
+		}
+		if id, err := strconv.ParseUint(strings.TrimPrefix(key, "field."), 10, 64); err == nil && strings.HasPrefix(key, "field.") {
+			ids = append(ids, uint(id))
+			continue
+		}
+		names = append(names, key)
+	}
+	fields := []model.CustomField{}
+	if err := tu.cfr.GetFieldsByKeys(ctx, &fields, names, ids, tu.ps.VisibleProjects(userId)); err != nil {
+		return err
+	}
+	filter.ParsedQuery = &model.TaskQuery{Node: node, UserId: userId, Now: time.Now(), Fields: fields}
+	return nil
+}
+
+func (tu *taskUsecase) GetTaskById(ctx context.Context, userId uint, taskId uint) (model.TaskResponse, error) {
 	// 取得するTaskを格納するための構造体をまずは作成し
 	task := model.Task{}
+	// 共有されたタスクも読めるように、アクセス権はpermissionサービスで確認します。
+	if err := tu.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
 	// tu.tr.GetTaskByIdで、この空の構造体のポインタ(&task)を第1引数として渡していきます。
-	// そして、userIdとtaskIdを引数で渡していきます。
-	if err := tu.tr.GetTaskById(&task, userId, taskId); err != nil {
+	// そして、taskIdを引数で渡していきます。
+	if err := tu.tr.GetTaskById(ctx, &task, taskId); err != nil {
 		// エラーが発生した場合は、TaskResponse構造体を0値でインスタンス化したものとerrをreturnで返す
 		return model.TaskResponse{}, err
 	}
 	// 成功した場合は、第1引数で渡したポインタ(&task)が指し示す先の値が取得したタスクの値で書き換えられますので、
 	// ID,Title,CreatedAt,UpdatedAtの値を取り出して
 	// 新しくタスクレスポンス構造体の実体(model.TaskResponse)を作成してreturnで返す
-	resTask := model.TaskResponse{
-		ID:        task.ID,
-		Title:     task.Title,
-		CreatedAt: task.CreatedAt,
-		UpdatedAt: task.UpdatedAt,
-	}
+	resTask := newTaskResponse(task)
 	// 作成した構造体とnilをreturnで返す
 	return resTask, nil
 }
 
 // タスクリポジトリのCreateTaskを呼び出す前にタスクのバリデーションを実行
-func (tu *taskUsecase) CreateTask(task model.Task) (model.TaskResponse, error) {
-	// tu.tv.TaskValidateで引数としてバリデーションを行いたいtaskのオブジェクトを渡します。
-	if err := tu.tv.TaskValidate(task); err != nil {
+func (tu *taskUsecase) CreateTask(ctx context.Context, task model.Task) (model.TaskResponse, error) {
+	// プロジェクトにタスクを追加するにはeditor以上の権限が必要
+	if task.ProjectId != nil {
+		if err := tu.ps.CanEditProject(ctx, task.UserId, *task.ProjectId); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	// プロジェクトの独自の項目の定義を読み込んでから、バリデーションを行います。
+	if err := tu.validateTask(ctx, &task, nil); err != nil {
 		// そして、バリデーションに失敗した場合は、returnでエラーを返す
 		return model.TaskResponse{}, err
 	}
-	// taskリポジトリ内のCreateTaskを呼び出し、引数としてtaskオブジェクトのアドレスを渡す
-	if err := tu.tr.CreateTask(&task); err != nil {
+	if err := tu.checkParent(ctx, task.UserId, task.ParentId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	// ボードの列にはWIPの上限を確認してから置くので、作成時には受け取りません。
+	task.ColumnId = nil
+	task.ColumnPosition = ""
+	// 担当者は作成した後に、担当者の変更と同じように履歴を残して通知します。
+	assigneeId := task.AssigneeId
+	task.AssigneeId = nil
+	if assigneeId != nil {
+		if err := tu.ps.CanBeAssigned(ctx, *assigneeId, task); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	// シリーズ・タスク・変更履歴は1つのトランザクションで作成して、履歴の無いタスクが残らないようにします。
+	err := tu.tx.Do(ctx, func(ctx context.Context) error {
+		// rruleが指定されている場合は、先にシリーズを作成してタスクをその最初の回にする
+		if task.RRule != "" {
+			series, err := tu.createSeries(ctx, task, task.UserId)
+			if err != nil {
+				return err
+			}
+			task.SeriesId = &series.ID
+			task.Series = &series
+			task.RecurrenceId = &series.DTStart
+		}
+		// 新しいタスクは一覧の末尾に追加する
+		// taskリポジトリ内のCreateTaskを呼び出し、引数としてtaskオブジェクトのアドレスを渡す
+		if err := tu.createAtEnd(ctx, &task); err != nil {
+			return err
+		}
+		// 作成したタスクを変更履歴の最初のバージョンとして記録
+		if err := tu.recordVersion(ctx, model.TaskActionCreate, nil, &task, task.UserId); err != nil {
+			return err
+		}
+		if assigneeId != nil {
+			return tu.assign(ctx, &task, assigneeId, task.UserId)
+		}
+		return nil
+	})
+	if err != nil {
 		// CreateTaskでエラーが発生した場合は、TaskResponse構造体の0値の実体とerrをreturnで返す
 		return model.TaskResponse{}, err
 	}
 	// 成功した場合は、引数で渡したアドレスが指し示す先の値が新規作成したタスクの値で書き換わっていますので
 	// ID,Title,CreatedAt,UpdatedAtの値を取り出して
 	// 新しくタスクレスポンス構造体の実体(model.TaskResponse)を作成してreturnで返す
-	resTask := model.TaskResponse{
-		ID:        task.ID,
-		Title:     task.Title,
-		CreatedAt: task.CreatedAt,
-		UpdatedAt: task.UpdatedAt,
-	}
+	resTask := newTaskResponse(task)
 	// 成功した場合は、第2引数のエラーはnilを返す
 	return resTask, nil
 }
 
-func (tu *taskUsecase) UpdateTask(task model.Task, userId uint, taskId uint) (model.TaskResponse, error) {
-	// tu.tv.TaskValidateでバリデーションを掛けたいtaskオブジェクトを引数で渡しておきます。
-	if err := tu.tv.TaskValidate(task); err != nil {
+func (tu *taskUsecase) CreateTasks(ctx context.Context, tasks []model.Task, userId uint) ([]model.TaskResponse, error) {
+	for i := range tasks {
+		// どのタスクのエラーか分かるように、何番目のタスクかをエラーに含めます。
+		if err := tu.prepareTask(ctx, &tasks[i], userId); err != nil {
+			return nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+	}
+	if err := tu.insertTasks(ctx, tasks, userId); err != nil {
+		return nil, err
+	}
+	resTasks := []model.TaskResponse{}
+	for _, v := range tasks {
+		resTasks = append(resTasks, newTaskResponse(v))
+	}
+	return resTasks, nil
+}
+
+// prepareTaskはまとめて作成するタスクの権限とバリデーションを確認して、作成時に設定しない項目を空にする
+func (tu *taskUsecase) prepareTask(ctx context.Context, task *model.Task, userId uint) error {
+	task.UserId = userId
+	if task.ProjectId != nil {
+		if err := tu.ps.CanEditProject(ctx, userId, *task.ProjectId); err != nil {
+			return err
+		}
+	}
+	if err := tu.validateTask(ctx, task, nil); err != nil {
+		return err
+	}
+	if err := tu.checkParent(ctx, userId, task.ParentId); err != nil {
+		return err
+	}
+	task.ColumnId = nil
+	task.ColumnPosition = ""
+	task.AssigneeId = nil
+	task.SeriesId = nil
+	task.Series = nil
+	task.RecurrenceId = nil
+	task.RRule = ""
+	return nil
+}
+
+// insertTasksはprepareTaskで確認したタスクを、ユーザーの一覧の末尾に渡した順番で並ぶように1つのトランザクションで作成する
+func (tu *taskUsecase) insertTasks(ctx context.Context, tasks []model.Task, userId uint) error {
+	// 末尾の順位のロックは作成するまで持ち続ける必要があるので、順位の取得・作成・変更履歴の記録を同じトランザクションで行います。
+	return tu.tx.Do(ctx, func(ctx context.Context) error {
+		last, err := tu.tr.GetLastPosition(ctx, userId)
+		if err != nil {
+			return err
+		}
+		for i := range tasks {
+			position, err := rank.Between(last, "")
+			if err != nil {
+				return err
+			}
+			tasks[i].Position = position
+			last = position
+		}
+		if err := tu.tr.CreateTasks(ctx, tasks); err != nil {
+			return err
+		}
+		// 作成したタスクを変更履歴の最初のバージョンとして記録
+		for i := range tasks {
+			if err := tu.recordVersion(ctx, model.TaskActionCreate, nil, &tasks[i], userId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (tu *taskUsecase) UpdateTask(ctx context.Context, task model.Task, userId uint, taskId uint) (model.TaskResponse, error) {
+	return tu.updateTask(ctx, task, userId, taskId, model.TaskActionUpdate)
+}
+
+// updateTaskはUpdateTaskの処理の本体で、変更履歴にはactionの操作として記録する
+func (tu *taskUsecase) updateTask(ctx context.Context, task model.Task, userId uint, taskId uint, action string) (model.TaskResponse, error) {
+	// 担当者は完了状態だけを更新できます。
+	current, err := tu.getEditableTask(ctx, task, userId, taskId, true)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	// 「この回のみ」の更新ではシリーズを変えないので、今と違うrruleが指定された場合は無視せずにエラーにします。
+	// (取得したタスクをそのまま送り返すクライアントのために、今と同じrruleは受け付けます。)
+	if task.RRule != "" && (current.Series == nil || !sameRRule(task.RRule, current.Series.RRule)) {
+		return model.TaskResponse{}, ErrRRuleRequiresFutureScope
+	}
+	// 独自の項目の値は移動先のプロジェクトの定義でチェックするので、バリデーションは更新前のタスクを取得してから行います。
+	if err := tu.validateTask(ctx, &task, &current); err != nil {
 		return model.TaskResponse{}, err
 	}
-	// tu.tr.UpdateTaskでtaskオブジェクトのアドレス,userId,taskIdを渡していきます。
-	if err := tu.tr.UpdateTask(&task, userId, taskId); err != nil {
+	// タスクの更新・変更履歴・リマインダー・次の回の生成は1つのトランザクションで行います。
+	err = tu.tx.Do(ctx, func(ctx context.Context) error {
+		// tu.tr.UpdateTaskでtaskオブジェクトのアドレス,taskIdを渡していきます。
+		if err := tu.tr.UpdateTask(ctx, &task, taskId); err != nil {
+			return err
+		}
+		// 「この回のみ」の更新なのでシリーズは変わりません。
+		task.Series = current.Series
+		if err := tu.recordVersion(ctx, action, &current, &task, userId); err != nil {
+			return err
+		}
+		if err := tu.rescheduleReminders(ctx, current, task); err != nil {
+			return err
+		}
+		// 繰り返しタスクが未完了から完了になった場合は次の回を生成
+		if task.Completed && !current.Completed && current.Series != nil {
+			return tu.createNextOccurrence(ctx, current, userId)
+		}
+		return nil
+	})
+	if err != nil {
 		// エラーが発生した場合は、TaskResponseの0値のインスタンスとerrをreturnで返す
 		return model.TaskResponse{}, err
 	}
 	// 成功した場合は、第1引数で渡したtaskのアドレスが指し示す先のメモリ領域のタスクの値が更新後のタスクで書きかえられていますので、
 	// ID,Title,CreatedAt,UpdatedAtの値を取り出して
 	// 新しくタスクレスポンス構造体の実体(model.TaskResponse)を作成してreturnで返す
-	resTask := model.TaskResponse{
-		ID:        task.ID,
-		Title:     task.Title,
-		CreatedAt: task.CreatedAt,
-		UpdatedAt: task.UpdatedAt,
-	}
+	resTask := newTaskResponse(task)
 	// その作成した構造体をreturnで返すのとerrの値としてnilを返す
 	return resTask, nil
 }
 
-func (tu *taskUsecase) DeleteTask(userId uint, taskId uint) error {
-	// tu.tr.DeleteTaskでuserIdとtaskIdを渡していきます。
-	if err := tu.tr.DeleteTask(userId, taskId); err != nil {
+func (tu *taskUsecase) DeleteTask(ctx context.Context, userId uint, taskId uint) error {
+	// 削除できるのはタスクの作成者とプロジェクトの所有者だけ
+	if err := tu.ps.CanDeleteTask(ctx, userId, taskId); err != nil {
 		return err
 	}
-	// そして成功した場合は、returnでnilを返す
+	return tu.tx.Do(ctx, func(ctx context.Context) error {
+		// 変更履歴に削除前の内容を残すために、削除するタスクを取得しておきます。
+		current := model.Task{}
+		if err := tu.tr.GetTaskById(ctx, &current, taskId); err != nil {
+			return err
+		}
+		// tu.tr.DeleteTaskでtaskIdを渡していきます。
+		if err := tu.tr.DeleteTask(ctx, taskId); err != nil {
+			return err
+		}
+		// そして成功した場合は、returnで変更履歴の記録の結果を返す
+		return tu.recordVersion(ctx, model.TaskActionDelete, &current, nil, userId)
+	})
+}
+
+func (tu *taskUsecase) UpdateFutureTasks(ctx context.Context, task model.Task, userId uint, taskId uint) (model.TaskResponse, error) {
+	current, err := tu.getEditableTask(ctx, task, userId, taskId, false)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.validateTask(ctx, &task, &current); err != nil {
+		return model.TaskResponse{}, err
+	}
+	// 繰り返しではないタスクにrruleが指定されていない場合は、通常の更新と同じ
+	if current.Series == nil && task.RRule == "" {
+		return tu.UpdateTask(ctx, task, userId, taskId)
+	}
+	// シリーズの書き換え・分割と、この回以降のタスクの付け替えは、途中で失敗するとシリーズが中途半端に分かれたままになるので、
+	// 1つのトランザクションで実行します。
+	err = tu.tx.Do(ctx, func(ctx context.Context) error {
+		var err error
+		task, err = tu.updateFutureTasks(ctx, task, current, userId, taskId)
+		return err
+	})
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	return newTaskResponse(task), nil
+}
+
+// updateFutureTasksはUpdateFutureTasksの処理の本体で、更新した後のタスクを返す
+func (tu *taskUsecase) updateFutureTasks(ctx context.Context, task model.Task, current model.Task, userId uint, taskId uint) (model.Task, error) {
+	var newSeries *model.TaskSeries
+	if current.Series != nil {
+		old := *current.Series
+		occurrence := old.DTStart
+		if current.RecurrenceId != nil {
+			occurrence = *current.RecurrenceId
+		}
+		if task.RRule != "" && occurrence.Equal(old.DTStart) {
+			// 最初の回から変更する場合は、シリーズ自体をそのまま書き換える
+			old.Title = task.Title
+			old.RRule = task.RRule
+			old.Timezone = timezoneOrDefault(task.Timezone, old.Timezone)
+			old.DTStart = *task.DueDate
+			if err := tu.tsr.UpdateSeries(ctx, &old, current.UserId, old.ID); err != nil {
+				return model.Task{}, err
+			}
+			newSeries = &old
+		} else {
+			// 途中の回から変更する場合は、元のシリーズをこの回の直前で打ち切り(UNTIL)、
+			// この回以降を新しいシリーズとして作成する
+			rule, err := rrule.Parse(old.RRule)
+			if err != nil {
+				return model.Task{}, err
+			}
+			old.RRule = rule.WithUntil(occurrence.Add(-time.Second)).String()
+			if err := tu.tsr.UpdateSeries(ctx, &old, current.UserId, old.ID); err != nil {
+				return model.Task{}, err
+			}
+			if task.RRule != "" {
+				task.Timezone = timezoneOrDefault(task.Timezone, old.Timezone)
+				series, err := tu.createSeries(ctx, task, current.UserId)
+				if err != nil {
+					return model.Task{}, err
+				}
+				newSeries = &series
+			}
+		}
+		// この回より後に生成済みの回も新しいシリーズ(繰り返しをやめる場合はシリーズ無し)に移す
+		var newSeriesId *uint
+		if newSeries != nil {
+			newSeriesId = &newSeries.ID
+		}
+		moved := []model.Task{}
+		if err := tu.tr.MoveFutureOccurrences(ctx, &moved, old.ID, occurrence, taskId, newSeriesId, task.Title); err != nil {
+			return model.Task{}, err
+		}
+		for i := range moved {
+			after := moved[i]
+			after.Title = task.Title
+			after.SeriesId = newSeriesId
+			if newSeriesId == nil {
+				after.RecurrenceId = nil
+			}
+			if err := tu.recordVersion(ctx, model.TaskActionUpdate, &moved[i], &after, userId); err != nil {
+				return model.Task{}, err
+			}
+		}
+	} else {
+		// 繰り返しではないタスクを、この回から始まる繰り返しタスクにする
+		// シリーズはタスクの作成者のものとして作ります。
+		series, err := tu.createSeries(ctx, task, current.UserId)
+		if err != nil {
+			return model.Task{}, err
+		}
+		newSeries = &series
+	}
+
+	var seriesId *uint
+	var recurrenceId *time.Time
+	if newSeries != nil {
+		seriesId = &newSeries.ID
+		recurrenceId = &newSeries.DTStart
+	}
+	if err := tu.tr.SetTaskSeries(ctx, taskId, seriesId, recurrenceId); err != nil {
+		return model.Task{}, err
+	}
+	if err := tu.tr.UpdateTask(ctx, &task, taskId); err != nil {
+		return model.Task{}, err
+	}
+	task.Series = newSeries
+	if err := tu.recordVersion(ctx, model.TaskActionUpdate, &current, &task, userId); err != nil {
+		return model.Task{}, err
+	}
+	if err := tu.rescheduleReminders(ctx, current, task); err != nil {
+		return model.Task{}, err
+	}
+	if task.Completed && !current.Completed && newSeries != nil {
+		updated := model.Task{}
+		if err := tu.tr.GetTaskById(ctx, &updated, taskId); err != nil {
+			return model.Task{}, err
+		}
+		if err := tu.createNextOccurrence(ctx, updated, userId); err != nil {
+			return model.Task{}, err
+		}
+	}
+	return task, nil
+}
+
+// createSeriesはタスクの内容から繰り返しのシリーズを作成する
+// 期限日(due_date)がRRuleの展開の起点(DTSTART)になります。
+func (tu *taskUsecase) createSeries(ctx context.Context, task model.Task, userId uint) (model.TaskSeries, error) {
+	series := model.TaskSeries{
+		Title:    task.Title,
+		RRule:    task.RRule,
+		Timezone: timezoneOrDefault(task.Timezone, "UTC"),
+		DTStart:  *task.DueDate,
+		UserId:   userId,
+	}
+	if err := tu.tsr.CreateSeries(ctx, &series); err != nil {
+		return model.TaskSeries{}, err
+	}
+	return series, nil
+}
+
+// createNextOccurrenceは完了した回(current)の次の回をシリーズのRRuleから計算して作成する
+// シリーズが終了している場合や、次の回が既に生成済みの場合は何もしません。
+// 次の回の作成者は元の回と同じで、actorIdは変更履歴に記録する完了させたユーザーです。
+func (tu *taskUsecase) createNextOccurrence(ctx context.Context, current model.Task, actorId uint) error {
+	series := current.Series
+	rule, err := rrule.Parse(series.RRule)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(series.Timezone)
+	if err != nil {
+		return err
+	}
+	// 夏時間をまたいでも同じ時刻になるように、シリーズのタイムゾーンで展開する
+	dtstart := series.DTStart.In(loc)
+	after := dtstart
+	if current.RecurrenceId != nil {
+		after = *current.RecurrenceId
+	}
+	next, ok := rule.After(dtstart, after, false)
+	if !ok {
+		return nil
+	}
+	exists, err := tu.tr.ExistsOccurrence(ctx, series.ID, next)
+	if err != nil || exists {
+		return err
+	}
+	nextTask := model.Task{
+		Title:        series.Title,
+		DueDate:      &next,
+		SeriesId:     &series.ID,
+		RecurrenceId: &next,
+		ProjectId:    current.ProjectId,
+		AssigneeId:   current.AssigneeId,
+		CustomFields: current.CustomFields,
+		UserId:       current.UserId,
+	}
+	if err := tu.createAtEnd(ctx, &nextTask); err != nil {
+		return err
+	}
+	if err := tu.recordVersion(ctx, model.TaskActionCreate, nil, &nextTask, actorId); err != nil {
+		return err
+	}
+	// 期限日の何分前という指定のリマインダーは次の回にも引き継ぐ
+	return tu.rr.CopyOffsetReminders(ctx, current.ID, nextTask)
+}
+
+// getEditableTaskはタスクを更新できるか確認して、更新前のタスクを返す
+// 別のプロジェクトに移す場合は、タスクの作成者(またはプロジェクトの所有者)で、移動先のプロジェクトにeditor以上の権限が必要です。
+// allowStatusOnlyがtrueの場合は、editorの権限が無くても担当者なら完了状態だけの更新を許可します。
+func (tu *taskUsecase) getEditableTask(ctx context.Context, task model.Task, userId uint, taskId uint, allowStatusOnly bool) (model.Task, error) {
+	statusOnly := false
+	if err := tu.ps.CanEditTask(ctx, userId, taskId); err != nil {
+		if !allowStatusOnly || !errors.Is(err, permission.ErrForbidden) {
+			return model.Task{}, err
+		}
+		if err := tu.ps.CanUpdateTaskStatus(ctx, userId, taskId); err != nil {
+			return model.Task{}, err
+		}
+		statusOnly = true
+	}
+	// 完了状態の変化を判定するために、更新前のタスクを取得しておきます。
+	current := model.Task{}
+	if err := tu.tr.GetTaskById(ctx, &current, taskId); err != nil {
+		return model.Task{}, err
+	}
+	if statusOnly {
+		if task.Title != current.Title || task.Description != current.Description || !sameTime(task.DueDate, current.DueDate) || !sameId(task.ProjectId, current.ProjectId) ||
+			(task.CustomFields != nil && !reflect.DeepEqual(compactCustomFields(task.CustomFields), current.CustomFields)) ||
+			(task.Tags != nil && !reflect.DeepEqual(normalizeTags(task.Tags), []string(current.Tags))) {
+			return model.Task{}, permission.ErrForbidden
+		}
+		return current, nil
+	}
+	if sameId(current.ProjectId, task.ProjectId) {
+		return current, nil
+	}
+	if err := tu.ps.CanDeleteTask(ctx, userId, taskId); err != nil {
+		return model.Task{}, err
+	}
+	if task.ProjectId != nil {
+		if err := tu.ps.CanEditProject(ctx, userId, *task.ProjectId); err != nil {
+			return model.Task{}, err
+		}
+	}
+	return current, nil
+}
+
+// validateTaskはタスクが属するプロジェクトの独自の項目の定義を読み込んで、タスクのバリデーションを実行する
+// currentは更新前のタスク(作成の場合はnil)で、custom_fieldsが省略された場合は同じプロジェクトの間だけ更新前の値を引き継ぎます。
+func (tu *taskUsecase) validateTask(ctx context.Context, task *model.Task, current *model.Task) error {
+	if task.CustomFields == nil && current != nil && sameId(task.ProjectId, current.ProjectId) {
+		task.CustomFields = current.CustomFields
+	}
+	task.CustomFields = compactCustomFields(task.CustomFields)
+	if task.Tags == nil && current != nil {
+		task.Tags = current.Tags
+	}
+	fields := []model.CustomField{}
+	if task.ProjectId != nil {
+		if err := tu.cfr.GetFieldsByProject(ctx, &fields, *task.ProjectId); err != nil {
+			return err
+		}
+	}
+	if err := tu.tv.TaskValidate(*task, fields); err != nil {
+		return err
+	}
+	task.Tags = model.TaskTags(normalizeTags(task.Tags))
+	return nil
+}
+
+// checkParentはサブタスクとして作成するタスクの親を確認する
+// 親のタスクの編集権限が必要で、サブタスクの下にはサブタスクを作れません。
+func (tu *taskUsecase) checkParent(ctx context.Context, userId uint, parentId *uint) error {
+	if parentId == nil {
+		return nil
+	}
+	if err := tu.ps.CanEditTask(ctx, userId, *parentId); err != nil {
+		return err
+	}
+	parent := model.Task{}
+	if err := tu.tr.GetTaskById(ctx, &parent, *parentId); err != nil {
+		return err
+	}
+	if parent.ParentId != nil {
+		return fmt.Errorf("subtask cannot have subtasks")
+	}
 	return nil
 }
+
+// compactCustomFieldsは値がnullの項目を取り除く(nullを指定するとその項目の値を消せます)
+func compactCustomFields(values model.CustomFieldValues) model.CustomFieldValues {
+	compacted := model.CustomFieldValues{}
+	for k, v := range values {
+		if v != nil {
+			compacted[k] = v
+		}
+	}
+	return compacted
+}
+
+func sameId(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// rescheduleRemindersは期限日が変わった場合に、オフセット指定のリマインダーの通知時刻を再計算する
+func (tu *taskUsecase) rescheduleReminders(ctx context.Context, current model.Task, updated model.Task) error {
+	if sameTime(current.DueDate, updated.DueDate) {
+		return nil
+	}
+	return tu.rr.RescheduleOffsetReminders(ctx, current.ID, updated.DueDate)
+}
+
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// sameRRuleは2つのrruleが同じ繰り返しか、正規化した文字列で比べる
+func sameRRule(a string, b string) bool {
+	ra, errA := rrule.Parse(a)
+	rb, errB := rrule.Parse(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ra.String() == rb.String()
+}
+
+func timezoneOrDefault(tz string, def string) string {
+	if tz == "" {
+		return def
+	}
+	return tz
+}
+
+func (tu *taskUsecase) MoveTask(ctx context.Context, req model.TaskMoveRequest, userId uint, taskId uint) (model.TaskResponse, error) {
+	if req.Before == nil && req.After == nil {
+		return model.TaskResponse{}, fmt.Errorf("before or after is required")
+	}
+	if err := tu.ps.CanEditTask(ctx, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	task := model.Task{}
+	if err := tu.tr.GetTaskById(ctx, &task, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	// 順位はタスクの作成者ごとの並び順なので、前後のタスクも作成者の一覧(owner)の中から探します。
+	owner := task.UserId
+	// 同時に同じ場所へ移動したタスクが同じ順位にならないように、順位のロックを取ってから前後の順位を読みます。
+	var position string
+	err := tu.tx.Do(ctx, func(ctx context.Context) error {
+		if err := tu.tr.LockPositions(ctx, owner); err != nil {
+			return err
+		}
+		// 移動先の前後のタスクの順位を求める
+		// 片方だけ指定された場合は、もう片方はそのタスクの隣に並んでいるタスクの順位を使います。
+		lower, upper := "", ""
+		if req.After != nil {
+			anchor, err := tu.getAnchorTask(ctx, userId, owner, *req.After)
+			if err != nil {
+				return err
+			}
+			lower = anchor.Position
+			if req.Before == nil {
+				next, err := tu.tr.GetAdjacentPosition(ctx, owner, taskId, anchor.Position, true)
+				if err != nil {
+					return err
+				}
+				upper = next
+			}
+		}
+		if req.Before != nil {
+			anchor, err := tu.getAnchorTask(ctx, userId, owner, *req.Before)
+			if err != nil {
+				return err
+			}
+			upper = anchor.Position
+			if req.After == nil {
+				prev, err := tu.tr.GetAdjacentPosition(ctx, owner, taskId, anchor.Position, false)
+				if err != nil {
+					return err
+				}
+				lower = prev
+			}
+		}
+		var err error
+		position, err = rank.Between(lower, upper)
+		if err != nil {
+			// 順位が重複している場合などはバックグラウンドの振り直しが終わるまで移動できない
+			return err
+		}
+		if err := tu.tr.UpdatePosition(ctx, taskId, position); err != nil {
+			return err
+		}
+		before := task
+		task.Position = position
+		return tu.recordVersion(ctx, model.TaskActionUpdate, &before, &task, userId)
+	})
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	return newTaskResponse(task), nil
+}
+
+// getAnchorTaskは並び替えの基準にするタスクを取得する
+// 移動するタスクと同じ作成者(owner)の一覧に並んでいるタスクでなければいけません。
+func (tu *taskUsecase) getAnchorTask(ctx context.Context, userId uint, owner uint, anchorId uint) (model.Task, error) {
+	if err := tu.ps.CanViewTask(ctx, userId, anchorId); err != nil {
+		return model.Task{}, err
+	}
+	anchor := model.Task{}
+	if err := tu.tr.GetTaskById(ctx, &anchor, anchorId); err != nil {
+		return model.Task{}, err
+	}
+	if anchor.UserId != owner {
+		return model.Task{}, fmt.Errorf("tasks belong to different lists")
+	}
+	return anchor, nil
+}
+
+// createAtEndはタスクを、ユーザーのタスクの一覧の末尾の順位で作成する
+// 同時に作成したタスクが同じ順位にならないように、末尾の順位の取得と作成を1つのトランザクションで行います。
+func (tu *taskUsecase) createAtEnd(ctx context.Context, task *model.Task) error {
+	return tu.tx.Do(ctx, func(ctx context.Context) error {
+		last, err := tu.tr.GetLastPosition(ctx, task.UserId)
+		if err != nil {
+			return err
+		}
+		position, err := rank.Between(last, "")
+		if err != nil {
+			return err
+		}
+		task.Position = position
+		return tu.tr.CreateTask(ctx, task)
+	})
+}
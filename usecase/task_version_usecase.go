@@ -0,0 +1,309 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/rank"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// taskSnapshotはタスクの中で履歴に残すフィールドを取り出す
+func taskSnapshot(task *model.Task) model.TaskSnapshot {
+	// 独自の項目の値が無い場合も、変更の比較で違いが出ないように空のmapで残します。
+	customFields := task.CustomFields
+	if customFields == nil {
+		customFields = model.CustomFieldValues{}
+	}
+	tags := task.Tags
+	if tags == nil {
+		tags = model.TaskTags{}
+	}
+	return model.TaskSnapshot{
+		Title:          task.Title,
+		Description:    task.Description,
+		Completed:      task.Completed,
+		DueDate:        task.DueDate,
+		Position:       task.Position,
+		ProjectId:      task.ProjectId,
+		AssigneeId:     task.AssigneeId,
+		OrganizationId: task.OrganizationId,
+		SeriesId:       task.SeriesId,
+		RecurrenceId:   task.RecurrenceId,
+		CustomFields:   customFields,
+		Tags:           tags,
+		UserId:         task.UserId,
+	}
+}
+
+// snapshotFieldsはスナップショットをJSONのフィールド名をキーにしたmapに変換する
+// nilの場合(作成前・削除後)は空のmapを返します。
+func snapshotFields(task *model.Task) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if task == nil {
+		return fields, nil
+	}
+	b, err := json.Marshal(taskSnapshot(task))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// diffTaskは変更前後のタスクを比べて、値が変わったフィールドだけを返す
+func diffTask(before *model.Task, after *model.Task) (map[string]model.FieldChange, error) {
+	oldFields, err := snapshotFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := snapshotFields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]model.FieldChange{}
+	for key, n := range newFields {
+		if o, ok := oldFields[key]; !ok || !reflect.DeepEqual(o, n) {
+			changes[key] = model.FieldChange{Old: oldFields[key], New: n}
+		}
+	}
+	for key, o := range oldFields {
+		if _, ok := newFields[key]; !ok {
+			changes[key] = model.FieldChange{Old: o, New: nil}
+		}
+	}
+	return changes, nil
+}
+
+// recordVersionはタスクの変更を履歴に記録する
+// 作成の場合はbeforeを、削除の場合はafterをnilにします。何も変わっていない更新は記録しません。
+func (tu *taskUsecase) recordVersion(ctx context.Context, action string, before *model.Task, after *model.Task, actorId uint) error {
+	changes, err := diffTask(before, after)
+	if err != nil {
+		return err
+	}
+	if action == model.TaskActionUpdate && len(changes) == 0 {
+		return nil
+	}
+	// 削除の場合は、後から復元できるように削除前の内容をスナップショットにする
+	current := after
+	if current == nil {
+		current = before
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	snapshotJSON, err := json.Marshal(taskSnapshot(current))
+	if err != nil {
+		return err
+	}
+	version := model.TaskVersion{
+		TaskId:   current.ID,
+		Action:   action,
+		Changes:  string(changesJSON),
+		Snapshot: string(snapshotJSON),
+		ActorId:  actorId,
+	}
+	if err := tu.tvr.CreateVersion(ctx, &version); err != nil {
+		return err
+	}
+	// 変更履歴を記録するタスクの変更は全て、/eventsに接続しているクライアントにも送ります。
+	// トランザクションの中の場合は、ロールバックした変更を送らないようにコミットした後に送ります。
+	changed := *current
+	repository.AfterCommit(ctx, func() { tu.publishChange(action, &changed, actorId) })
+	return nil
+}
+
+func newTaskVersionResponse(version model.TaskVersion) (model.TaskVersionResponse, error) {
+	changes := map[string]model.FieldChange{}
+	if err := json.Unmarshal([]byte(version.Changes), &changes); err != nil {
+		return model.TaskVersionResponse{}, err
+	}
+	return model.TaskVersionResponse{
+		Version:   version.Version,
+		Action:    version.Action,
+		Changes:   changes,
+		ActorId:   version.ActorId,
+		CreatedAt: version.CreatedAt,
+	}, nil
+}
+
+// ownsTaskHistoryは削除されたタスクも含めて、ユーザーが履歴を見られるか確認する
+// タスクが残っていればタスクの閲覧権限を、削除されていれば最新の履歴のスナップショットの作成者を見ます。
+func (tu *taskUsecase) ownsTaskHistory(ctx context.Context, userId uint, taskId uint, versions []model.TaskVersion) error {
+	err := tu.ps.CanViewTask(ctx, userId, taskId)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if len(versions) == 0 || versions[0].Action != model.TaskActionDelete {
+		return repository.ErrObjectNotExist
+	}
+	snapshot := model.TaskSnapshot{}
+	if err := json.Unmarshal([]byte(versions[0].Snapshot), &snapshot); err != nil {
+		return err
+	}
+	// 削除されたタスクはテナントで絞り込めないので、スナップショットの組織がリクエストの組織と一致するかも確認します。
+	t, _ := tenant.FromContext(ctx)
+	if snapshot.UserId != userId || !sameId(snapshot.OrganizationId, t.OrganizationIdOrNil()) {
+		return repository.ErrObjectNotExist
+	}
+	return nil
+}
+
+func (tu *taskUsecase) GetTaskHistory(ctx context.Context, userId uint, taskId uint) ([]model.TaskVersionResponse, error) {
+	versions := []model.TaskVersion{}
+	if err := tu.tvr.GetVersions(ctx, &versions, taskId); err != nil {
+		return nil, err
+	}
+	if err := tu.ownsTaskHistory(ctx, userId, taskId, versions); err != nil {
+		return nil, err
+	}
+	resVersions := []model.TaskVersionResponse{}
+	for _, v := range versions {
+		res, err := newTaskVersionResponse(v)
+		if err != nil {
+			return nil, err
+		}
+		resVersions = append(resVersions, res)
+	}
+	return resVersions, nil
+}
+
+func (tu *taskUsecase) RestoreTaskVersion(ctx context.Context, userId uint, taskId uint, number int) (model.TaskResponse, error) {
+	versions := []model.TaskVersion{}
+	if err := tu.tvr.GetVersions(ctx, &versions, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.ownsTaskHistory(ctx, userId, taskId, versions); err != nil {
+		return model.TaskResponse{}, err
+	}
+	version := model.TaskVersion{}
+	if err := tu.tvr.GetVersion(ctx, &version, taskId, number); err != nil {
+		return model.TaskResponse{}, err
+	}
+	snapshot := model.TaskSnapshot{}
+	if err := json.Unmarshal([]byte(version.Snapshot), &snapshot); err != nil {
+		return model.TaskResponse{}, err
+	}
+
+	current := model.Task{}
+	err := tu.tr.GetTaskById(ctx, &current, taskId)
+	if err == nil {
+		// タスクが残っている場合は、内容のフィールドだけを戻します。
+		// 順位と繰り返しのシリーズは、その後の並べ替えやシリーズの分割と食い違わないように今のままにします。
+		// 権限の確認はupdateTaskの中で行います。
+		restored := model.Task{Title: snapshot.Title, Description: snapshot.Description, Completed: snapshot.Completed, DueDate: snapshot.DueDate, ProjectId: snapshot.ProjectId,
+			Tags: snapshot.Tags}
+		if snapshot.CustomFields != nil {
+			customFields, err := tu.restorableCustomFields(ctx, snapshot.CustomFields, snapshot.ProjectId)
+			if err != nil {
+				return model.TaskResponse{}, err
+			}
+			restored.CustomFields = customFields
+		}
+		return tu.updateTask(ctx, restored, userId, taskId, model.TaskActionRestore)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.TaskResponse{}, err
+	}
+
+	// 削除されたタスクは論理削除した行を同じIDのまま戻します。
+	// 論理削除の間も残していたリマインダー・コメント・添付ファイルも、そのまま元に戻ります。
+	task := model.Task{
+		ID:          taskId,
+		Title:       snapshot.Title,
+		Description: snapshot.Description,
+		Completed:   snapshot.Completed,
+		DueDate:     snapshot.DueDate,
+		Tags:        snapshot.Tags,
+		UserId:      userId,
+	}
+	// プロジェクトが残っていて、まだ編集できる場合はプロジェクトにも戻す
+	if snapshot.ProjectId != nil {
+		if err := tu.ps.CanEditProject(ctx, userId, *snapshot.ProjectId); err == nil {
+			task.ProjectId = snapshot.ProjectId
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, permission.ErrForbidden) {
+			return model.TaskResponse{}, err
+		}
+	}
+	if task.ProjectId != nil {
+		customFields, err := tu.restorableCustomFields(ctx, snapshot.CustomFields, task.ProjectId)
+		if err != nil {
+			return model.TaskResponse{}, err
+		}
+		task.CustomFields = customFields
+	}
+	if err := tu.validateTask(ctx, &task, nil); err != nil {
+		return model.TaskResponse{}, err
+	}
+	var restoredSeries *model.TaskSeries
+	if snapshot.SeriesId != nil && snapshot.RecurrenceId != nil {
+		// シリーズが残っていて、同じ回がまだ生成されていなければ繰り返しの回として戻す
+		series := model.TaskSeries{}
+		if err := tu.tsr.GetSeriesById(ctx, &series, userId, *snapshot.SeriesId); err == nil {
+			exists, err := tu.tr.ExistsOccurrence(ctx, series.ID, *snapshot.RecurrenceId)
+			if err != nil {
+				return model.TaskResponse{}, err
+			}
+			if !exists {
+				task.SeriesId = snapshot.SeriesId
+				task.RecurrenceId = snapshot.RecurrenceId
+				restoredSeries = &series
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.TaskResponse{}, err
+		}
+	}
+	err = tu.tx.Do(ctx, func(ctx context.Context) error {
+		// 論理削除したタスクの行を、一覧の末尾の順位で戻します。
+		last, err := tu.tr.GetLastPosition(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if task.Position, err = rank.Between(last, ""); err != nil {
+			return err
+		}
+		if err := tu.tr.RestoreTask(ctx, &task); err != nil {
+			return err
+		}
+		task.Series = restoredSeries
+		return tu.recordVersion(ctx, model.TaskActionRestore, nil, &task, userId)
+	})
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	return newTaskResponse(task), nil
+}
+
+// restorableCustomFieldsはスナップショットの独自の項目の値のうち、プロジェクトの今の定義に合う値だけを返す
+// 履歴を残した後に削除された項目や、選択肢から外された値は戻しません。
+func (tu *taskUsecase) restorableCustomFields(ctx context.Context, values model.CustomFieldValues, projectId *uint) (model.CustomFieldValues, error) {
+	restored := model.CustomFieldValues{}
+	if projectId == nil {
+		return restored, nil
+	}
+	fields := []model.CustomField{}
+	if err := tu.cfr.GetFieldsByProject(ctx, &fields, *projectId); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		key := strconv.FormatUint(uint64(field.ID), 10)
+		if v, ok := values[key]; ok && tu.tv.CustomFieldValueValidate(field, v) == nil {
+			restored[key] = v
+		}
+	}
+	return restored, nil
+}
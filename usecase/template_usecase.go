@@ -0,0 +1,334 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/storage"
+	"go-rest-api/validator"
+	"regexp"
+	"time"
+)
+
+type ITemplateUsecase interface {
+	GetTemplates(ctx context.Context, userId uint) ([]model.TemplateResponse, error)
+	GetTemplateById(ctx context.Context, userId uint, templateId uint) (model.TemplateResponse, error)
+	CreateTemplate(ctx context.Context, template model.Template) (model.TemplateResponse, error)
+	UpdateTemplate(ctx context.Context, template model.Template, userId uint, templateId uint) (model.TemplateResponse, error)
+	DeleteTemplate(ctx context.Context, userId uint, templateId uint) error
+	// InstantiateTemplateはテンプレートのプレースホルダーを置き換えて、タスクを1つのトランザクションでまとめて作成する
+	InstantiateTemplate(ctx context.Context, req model.TemplateInstantiateRequest, userId uint, templateId uint) ([]model.TaskResponse, error)
+	// DuplicateTaskはタスクを複製する(独自の項目の値・タグ・添付ファイル・サブタスクはリクエストで指定した場合だけコピーします)
+	DuplicateTask(ctx context.Context, req model.TaskDuplicateRequest, userId uint, taskId uint) (model.TaskResponse, error)
+}
+
+type templateUsecase struct {
+	tmr repository.ITemplateRepository
+	// テンプレートと複製のタスクは、タスクのユースケースでバリデーションと権限の確認をしてから作成します。
+	tr repository.ITaskRepository
+	tu ITaskUsecase
+	// 複製するタスクの添付ファイルは、中身もBlobStoreの新しいキーにコピーします。
+	ar  repository.IAttachmentRepository
+	bs  storage.BlobStore
+	ps  permission.IPermissionService
+	tmv validator.ITemplateValidator
+	// タスクの複製は、サブタスクと添付ファイルも含めて1つのトランザクションで作成します。
+	tx repository.ITransaction
+}
+
+func NewTemplateUsecase(tmr repository.ITemplateRepository, tr repository.ITaskRepository, tu ITaskUsecase, ar repository.IAttachmentRepository,
+	bs storage.BlobStore, ps permission.IPermissionService, tmv validator.ITemplateValidator, tx repository.ITransaction) ITemplateUsecase {
+	return &templateUsecase{tmr, tr, tu, ar, bs, ps, tmv, tx}
+}
+
+// placeholderPatternは{{date}}や{{ name }}のようなプレースホルダー
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+func newTemplateResponse(template model.Template) model.TemplateResponse {
+	items := template.Items
+	if items == nil {
+		items = []model.TemplateItem{}
+	}
+	return model.TemplateResponse{
+		ID:        template.ID,
+		Name:      template.Name,
+		Items:     items,
+		ProjectId: template.ProjectId,
+		CreatedAt: template.CreatedAt,
+		UpdatedAt: template.UpdatedAt,
+	}
+}
+
+// validateTemplateはテンプレートをチェックする
+// プロジェクトを指定する場合は、そのプロジェクトにタスクを作成できる(editor以上の)権限が必要です。
+func (tmu *templateUsecase) validateTemplate(ctx context.Context, template *model.Template) error {
+	if err := tmu.tmv.TemplateValidate(*template); err != nil {
+		return err
+	}
+	if template.ProjectId != nil {
+		if err := tmu.ps.CanEditProject(ctx, template.UserId, *template.ProjectId); err != nil {
+			return err
+		}
+	}
+	for i := range template.Items {
+		template.Items[i].CustomFields = compactCustomFields(template.Items[i].CustomFields)
+	}
+	return nil
+}
+
+func (tmu *templateUsecase) GetTemplates(ctx context.Context, userId uint) ([]model.TemplateResponse, error) {
+	templates := []model.Template{}
+	if err := tmu.tmr.GetTemplates(ctx, &templates, userId); err != nil {
+		return nil, err
+	}
+	resTemplates := []model.TemplateResponse{}
+	for _, v := range templates {
+		resTemplates = append(resTemplates, newTemplateResponse(v))
+	}
+	return resTemplates, nil
+}
+
+func (tmu *templateUsecase) GetTemplateById(ctx context.Context, userId uint, templateId uint) (model.TemplateResponse, error) {
+	template := model.Template{}
+	if err := tmu.tmr.GetTemplateById(ctx, &template, userId, templateId); err != nil {
+		return model.TemplateResponse{}, err
+	}
+	return newTemplateResponse(template), nil
+}
+
+func (tmu *templateUsecase) CreateTemplate(ctx context.Context, template model.Template) (model.TemplateResponse, error) {
+	if err := tmu.validateTemplate(ctx, &template); err != nil {
+		return model.TemplateResponse{}, err
+	}
+	if err := tmu.tmr.CreateTemplate(ctx, &template); err != nil {
+		return model.TemplateResponse{}, err
+	}
+	return newTemplateResponse(template), nil
+}
+
+func (tmu *templateUsecase) UpdateTemplate(ctx context.Context, template model.Template, userId uint, templateId uint) (model.TemplateResponse, error) {
+	template.UserId = userId
+	if err := tmu.validateTemplate(ctx, &template); err != nil {
+		return model.TemplateResponse{}, err
+	}
+	if err := tmu.tmr.UpdateTemplate(ctx, &template, userId, templateId); err != nil {
+		return model.TemplateResponse{}, err
+	}
+	return newTemplateResponse(template), nil
+}
+
+func (tmu *templateUsecase) DeleteTemplate(ctx context.Context, userId uint, templateId uint) error {
+	if err := tmu.tmr.DeleteTemplate(ctx, userId, templateId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tmu *templateUsecase) InstantiateTemplate(ctx context.Context, req model.TemplateInstantiateRequest, userId uint, templateId uint) ([]model.TaskResponse, error) {
+	template := model.Template{}
+	if err := tmu.tmr.GetTemplateById(ctx, &template, userId, templateId); err != nil {
+		return nil, err
+	}
+	// 基準の日付は、省略された場合はUTCの今日にします。
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.Date != "" {
+		parsed, err := time.Parse(model.TemplateDateLayout, req.Date)
+		if err != nil {
+			return nil, fmt.Errorf("date must be YYYY-MM-DD")
+		}
+		date = parsed
+	}
+	values := map[string]string{}
+	for k, v := range req.Variables {
+		values[k] = v
+	}
+	values["date"] = date.Format(model.TemplateDateLayout)
+
+	tasks := []model.Task{}
+	for i, item := range template.Items {
+		title, err := expandPlaceholders(item.Title, values)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+		customFields := model.CustomFieldValues{}
+		for k, v := range item.CustomFields {
+			// プレースホルダーを置き換えるのは文字列の値だけです。
+			if s, ok := v.(string); ok {
+				expanded, err := expandPlaceholders(s, values)
+				if err != nil {
+					return nil, fmt.Errorf("task %d: %w", i+1, err)
+				}
+				v = expanded
+			}
+			customFields[k] = v
+		}
+		task := model.Task{Title: title, ProjectId: template.ProjectId, CustomFields: customFields}
+		if item.DueInDays != nil {
+			due := date.AddDate(0, 0, *item.DueInDays)
+			task.DueDate = &due
+		}
+		tasks = append(tasks, task)
+	}
+	return tmu.tu.CreateTasks(ctx, tasks, userId)
+}
+
+// expandPlaceholdersはsの中のプレースホルダーをvaluesの値に置き換える
+// 値の無いプレースホルダーは、入力の誤りに気付けるようにエラーにします。
+func expandPlaceholders(s string, values map[string]string) (string, error) {
+	var missing string
+	expanded := placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholderPattern.FindStringSubmatch(m)[1]
+		v, ok := values[name]
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return m
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("no value for placeholder {{%s}}", missing)
+	}
+	return expanded, nil
+}
+
+func (tmu *templateUsecase) DuplicateTask(ctx context.Context, req model.TaskDuplicateRequest, userId uint, taskId uint) (model.TaskResponse, error) {
+	// 複製は新しいタスクの作成なので、元のタスクは見えれば十分です(プロジェクトのタスクの場合はプロジェクトのeditor以上の権限が必要)。
+	if err := tmu.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	source := model.Task{}
+	if err := tmu.tr.GetTaskById(ctx, &source, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	subtasks := []model.Task{}
+	if req.IncludeSubtasks {
+		if err := tmu.tr.GetSubtasks(ctx, &subtasks, taskId); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	// 元のタスクがサブタスクの場合は、親のタスクを編集できれば複製も同じ親のサブタスクにします。
+	task := duplicatedTask(source, req)
+	if source.ParentId != nil && tmu.ps.CanEditTask(ctx, userId, *source.ParentId) == nil {
+		task.ParentId = source.ParentId
+	}
+	if req.Title != nil {
+		task.Title = *req.Title
+	}
+	// 途中で失敗した場合は、作成したタスクとサブタスク・添付ファイルの行をまとめてロールバックして、
+	// コピーした添付ファイルの中身だけは行が残らないのでBlobStoreから削除します。
+	var res model.TaskResponse
+	blobs := []model.Attachment{}
+	err := tmu.tx.Do(ctx, func(ctx context.Context) error {
+		tasks := []model.Task{task}
+		resTasks, err := tmu.tu.CreateTasks(ctx, tasks, userId)
+		if err != nil {
+			return err
+		}
+		res = resTasks[0]
+		copied := []model.Task{}
+		for _, v := range subtasks {
+			subtask := duplicatedTask(v, req)
+			subtask.ParentId = &tasks[0].ID
+			copied = append(copied, subtask)
+		}
+		if len(copied) > 0 {
+			if _, err := tmu.tu.CreateTasks(ctx, copied, userId); err != nil {
+				return err
+			}
+		}
+		if !req.IncludeAttachments {
+			return nil
+		}
+		if err := tmu.copyAttachments(ctx, userId, taskId, tasks[0].ID, &blobs); err != nil {
+			return err
+		}
+		for i := range copied {
+			if err := tmu.copyAttachments(ctx, userId, subtasks[i].ID, copied[i].ID, &blobs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, v := range blobs {
+			deleteAttachmentBlobs(tmu.bs, v)
+		}
+		return model.TaskResponse{}, err
+	}
+	return res, nil
+}
+
+// duplicatedTaskは複製するタスクの内容を、リクエストで指定した項目も含めてsourceからコピーする
+func duplicatedTask(source model.Task, req model.TaskDuplicateRequest) model.Task {
+	task := model.Task{Title: source.Title, Description: source.Description, DueDate: source.DueDate, ProjectId: source.ProjectId}
+	if req.IncludeCustomFields {
+		task.CustomFields = source.CustomFields
+	}
+	if req.IncludeTags {
+		task.Tags = source.Tags
+	}
+	return task
+}
+
+// copyAttachmentsはsourceIdのタスクの添付ファイルを、中身も含めてtaskIdのタスクにコピーする
+// 添付ファイルは削除する時に中身も消すので、元のタスクとは別のキーに保存します。
+// 中身をコピーした添付ファイルはblobsに追加するので、失敗した場合は呼び出し元でblobsの中身を削除します。
+func (tmu *templateUsecase) copyAttachments(ctx context.Context, userId uint, sourceId uint, taskId uint, blobs *[]model.Attachment) error {
+	attachments := []model.Attachment{}
+	if err := tmu.ar.GetAttachmentsByTask(ctx, &attachments, sourceId); err != nil {
+		return err
+	}
+	for _, v := range attachments {
+		attachment := model.Attachment{
+			FileName:    v.FileName,
+			ContentType: v.ContentType,
+			Size:        v.Size,
+			SHA256:      v.SHA256,
+			TaskId:      taskId,
+			UserId:      userId,
+		}
+		err := tmu.copyAttachment(ctx, v, &attachment)
+		// 中身のコピーが途中で失敗した場合も、コピーできた分のキーは設定されています。
+		*blobs = append(*blobs, attachment)
+		if err != nil {
+			return err
+		}
+		if err := tmu.ar.CreateAttachment(ctx, &attachment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyAttachmentは添付ファイルの中身(とサムネイル)を新しいキーにコピーして、attachmentにキーを設定する
+func (tmu *templateUsecase) copyAttachment(ctx context.Context, source model.Attachment, attachment *model.Attachment) error {
+	key, err := newStorageKey(attachment.TaskId)
+	if err != nil {
+		return err
+	}
+	if err := tmu.copyBlob(ctx, source.StorageKey, key, source.ContentType); err != nil {
+		return err
+	}
+	attachment.StorageKey = key
+	if source.ThumbnailKey != "" {
+		thumbKey := key + ".thumb.png"
+		if err := tmu.copyBlob(ctx, source.ThumbnailKey, thumbKey, "image/png"); err != nil {
+			return err
+		}
+		attachment.ThumbnailKey = thumbKey
+	}
+	return nil
+}
+
+func (tmu *templateUsecase) copyBlob(ctx context.Context, from string, to string, contentType string) error {
+	blob, err := tmu.bs.Open(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	return tmu.bs.Put(ctx, to, blob, blob.Size(), contentType)
+}
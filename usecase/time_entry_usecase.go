@@ -0,0 +1,266 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/tenant"
+	"go-rest-api/validator"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// タイマーの操作のエラー
+var (
+	ErrTimerRunning    = errors.New("another timer is already running")
+	ErrTimerNotRunning = errors.New("timer is not running on this task")
+)
+
+type ITimeEntryUsecase interface {
+	GetTimeEntries(ctx context.Context, userId uint, taskId uint) ([]model.TimeEntryResponse, error)
+	// StartTimerはタスクのタイマーを開始する(他のタスクでタイマーが動いている場合はErrTimerRunning)
+	StartTimer(ctx context.Context, req model.TimerRequest, userId uint, taskId uint) (model.TimeEntryResponse, error)
+	// StopTimerはタスクで動いているタイマーを停止する
+	StopTimer(ctx context.Context, userId uint, taskId uint) (model.TimeEntryResponse, error)
+	CreateTimeEntry(ctx context.Context, req model.TimeEntryRequest, userId uint, taskId uint) (model.TimeEntryResponse, error)
+	UpdateTimeEntry(ctx context.Context, req model.TimeEntryRequest, userId uint, taskId uint, entryId uint) (model.TimeEntryResponse, error)
+	DeleteTimeEntry(ctx context.Context, userId uint, taskId uint, entryId uint) error
+	// GetTimeReportはログインしているユーザーの作業時間を、日・タスク・プロジェクト・タグごとに集計する
+	GetTimeReport(ctx context.Context, req model.TimeReportRequest, userId uint) (model.TimeReportResponse, error)
+}
+
+type timeEntryUsecase struct {
+	ter repository.ITimeEntryRepository
+	// 作業時間を記録できるのは、タスクの状態を更新できる担当者とeditor以上のユーザー
+	ps  permission.IPermissionService
+	tev validator.ITimeEntryValidator
+}
+
+func NewTimeEntryUsecase(ter repository.ITimeEntryRepository, ps permission.IPermissionService, tev validator.ITimeEntryValidator) ITimeEntryUsecase {
+	return &timeEntryUsecase{ter, ps, tev}
+}
+
+func newTimeEntryResponse(entry model.TimeEntry) model.TimeEntryResponse {
+	endedAt := time.Now()
+	if entry.EndedAt != nil {
+		endedAt = *entry.EndedAt
+	}
+	return model.TimeEntryResponse{
+		ID:          entry.ID,
+		Description: entry.Description,
+		StartedAt:   entry.StartedAt,
+		EndedAt:     entry.EndedAt,
+		Seconds:     int64(endedAt.Sub(entry.StartedAt).Seconds()),
+		Tags:        entry.Tags,
+		TaskId:      entry.TaskId,
+		UserId:      entry.UserId,
+		CreatedAt:   entry.CreatedAt,
+		UpdatedAt:   entry.UpdatedAt,
+	}
+}
+
+// normalizeTagsはタグの前後の空白を取り除いて、重複したタグをまとめる
+// タグが無い場合もjsonbに空の配列で保存できるように、nilではなく空のスライスを返します。
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// runningTimerはユーザーの動いているタイマーを返す(タイマーが無い場合はnil)
+// タイマーは組織をまたいでユーザーごとに1つだけなので、テナントで絞り込まずに探します。
+func (teu *timeEntryUsecase) runningTimer(ctx context.Context, userId uint) (*model.TimeEntry, error) {
+	entry := model.TimeEntry{}
+	if err := teu.ter.GetRunningTimeEntry(tenant.WithSystem(ctx), &entry, userId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (teu *timeEntryUsecase) GetTimeEntries(ctx context.Context, userId uint, taskId uint) ([]model.TimeEntryResponse, error) {
+	if err := teu.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return nil, err
+	}
+	entries := []model.TimeEntry{}
+	if err := teu.ter.GetTimeEntriesByTask(ctx, &entries, taskId); err != nil {
+		return nil, err
+	}
+	resEntries := []model.TimeEntryResponse{}
+	for _, v := range entries {
+		resEntries = append(resEntries, newTimeEntryResponse(v))
+	}
+	return resEntries, nil
+}
+
+func (teu *timeEntryUsecase) StartTimer(ctx context.Context, req model.TimerRequest, userId uint, taskId uint) (model.TimeEntryResponse, error) {
+	if err := teu.tev.TimerValidate(req); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	if err := teu.ps.CanUpdateTaskStatus(ctx, userId, taskId); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	running, err := teu.runningTimer(ctx, userId)
+	if err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	if running != nil {
+		return model.TimeEntryResponse{}, ErrTimerRunning
+	}
+	entry := model.TimeEntry{
+		Description: req.Description,
+		StartedAt:   time.Now(),
+		Tags:        normalizeTags(req.Tags),
+		TaskId:      taskId,
+		UserId:      userId,
+	}
+	if err := teu.ter.CreateTimeEntry(ctx, &entry); err != nil {
+		// 同時に開始された場合は、ユーザーごとに1つだけのユニークインデックスで片方が失敗します。
+		if running, _ := teu.runningTimer(ctx, userId); running != nil {
+			return model.TimeEntryResponse{}, ErrTimerRunning
+		}
+		return model.TimeEntryResponse{}, err
+	}
+	return newTimeEntryResponse(entry), nil
+}
+
+func (teu *timeEntryUsecase) StopTimer(ctx context.Context, userId uint, taskId uint) (model.TimeEntryResponse, error) {
+	if err := teu.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	entry := model.TimeEntry{}
+	if err := teu.ter.StopTimeEntry(ctx, &entry, userId, taskId, time.Now()); err != nil {
+		running, rerr := teu.runningTimer(ctx, userId)
+		if rerr == nil && (running == nil || running.TaskId != taskId) {
+			return model.TimeEntryResponse{}, ErrTimerNotRunning
+		}
+		return model.TimeEntryResponse{}, err
+	}
+	return newTimeEntryResponse(entry), nil
+}
+
+func (teu *timeEntryUsecase) CreateTimeEntry(ctx context.Context, req model.TimeEntryRequest, userId uint, taskId uint) (model.TimeEntryResponse, error) {
+	// 手動で追加する作業時間は、終了時刻まで指定します(動いているタイマーはStartTimerで作成)。
+	if err := teu.tev.TimeEntryValidate(req, false); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	if err := teu.ps.CanUpdateTaskStatus(ctx, userId, taskId); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	entry := model.TimeEntry{
+		Description: req.Description,
+		StartedAt:   *req.StartedAt,
+		EndedAt:     req.EndedAt,
+		Tags:        normalizeTags(req.Tags),
+		TaskId:      taskId,
+		UserId:      userId,
+	}
+	if err := teu.ter.CreateTimeEntry(ctx, &entry); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	return newTimeEntryResponse(entry), nil
+}
+
+func (teu *timeEntryUsecase) UpdateTimeEntry(ctx context.Context, req model.TimeEntryRequest, userId uint, taskId uint, entryId uint) (model.TimeEntryResponse, error) {
+	// 作業時間を編集できるのは記録したユーザー本人だけ
+	if err := teu.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	current := model.TimeEntry{}
+	if err := teu.ter.GetTimeEntryById(ctx, &current, userId, taskId, entryId); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	// 動いているタイマーは終了時刻を省略するとそのまま動き続け、終了時刻を指定すると停止します。
+	// 停止済みの作業時間を、終了時刻を外して動いている状態に戻すことはできません。
+	if err := teu.tev.TimeEntryValidate(req, current.EndedAt == nil); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	entry := model.TimeEntry{
+		Description: req.Description,
+		StartedAt:   *req.StartedAt,
+		EndedAt:     req.EndedAt,
+		Tags:        normalizeTags(req.Tags),
+	}
+	if err := teu.ter.UpdateTimeEntry(ctx, &entry, userId, taskId, entryId); err != nil {
+		return model.TimeEntryResponse{}, err
+	}
+	return newTimeEntryResponse(entry), nil
+}
+
+func (teu *timeEntryUsecase) DeleteTimeEntry(ctx context.Context, userId uint, taskId uint, entryId uint) error {
+	if err := teu.ps.CanViewTask(ctx, userId, taskId); err != nil {
+		return err
+	}
+	if err := teu.ter.DeleteTimeEntry(ctx, userId, taskId, entryId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (teu *timeEntryUsecase) GetTimeReport(ctx context.Context, req model.TimeReportRequest, userId uint) (model.TimeReportResponse, error) {
+	if err := teu.tev.TimeReportValidate(req); err != nil {
+		return model.TimeReportResponse{}, err
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		return model.TimeReportResponse{}, err
+	}
+	// fromの日の0時からtoの翌日の0時の直前までを、timezoneの日付で集計します。
+	from, err := time.ParseInLocation("2006-01-02", req.From, loc)
+	if err != nil {
+		return model.TimeReportResponse{}, err
+	}
+	to, err := time.ParseInLocation("2006-01-02", req.To, loc)
+	if err != nil {
+		return model.TimeReportResponse{}, err
+	}
+	filter := model.TimeReportFilter{
+		From:     from,
+		To:       to.AddDate(0, 0, 1),
+		GroupBy:  req.GroupBy,
+		Timezone: req.Timezone,
+	}
+	rows := []model.TimeReportRow{}
+	if err := teu.ter.GetTimeReport(ctx, &rows, userId, filter); err != nil {
+		return model.TimeReportResponse{}, err
+	}
+	report := model.TimeReportResponse{
+		From:    req.From,
+		To:      req.To,
+		GroupBy: req.GroupBy,
+		Rows:    rows,
+	}
+	// タグごとの集計では複数のタグが付いた作業時間が重複して数えられるので、合計はタグ以外の集計と同じく作業時間の合計にします。
+	if req.GroupBy == model.TimeGroupByTag {
+		total := []model.TimeReportRow{}
+		filter.GroupBy = model.TimeGroupByDay
+		if err := teu.ter.GetTimeReport(ctx, &total, userId, filter); err != nil {
+			return model.TimeReportResponse{}, err
+		}
+		for _, v := range total {
+			report.TotalSeconds += v.Seconds
+		}
+		return report, nil
+	}
+	for _, v := range rows {
+		report.TotalSeconds += v.Seconds
+	}
+	return report, nil
+}
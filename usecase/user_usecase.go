@@ -19,6 +19,8 @@ type IUserUsecase interface {
 	SignUp(user model.User) (model.UserResponse, error)
 	// Loginの1つ目の返り値は、JWTtokenを返すためにstring型を割り当て、2つ目はerrorインターフェイス型
 	Login(user model.User) (string, error)
+	// GetUserByIdはユーザーIDのユーザーを返す(/graphqlのmeで使います)
+	GetUserById(userId uint) (model.UserResponse, error)
 }
 
 // Usecase構造体
@@ -140,3 +142,11 @@ func (uu *userUsecase) Login(user model.User) (string, error) {
 	// 成功した場合はjwt tokenとnilを返す
 	return tokenString, nil
 }
+
+func (uu *userUsecase) GetUserById(userId uint) (model.UserResponse, error) {
+	user := model.User{}
+	if err := uu.ur.GetUserById(&user, userId); err != nil {
+		return model.UserResponse{}, err
+	}
+	return model.UserResponse{ID: user.ID, Email: user.Email}, nil
+}
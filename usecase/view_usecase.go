@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"context"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/taskquery"
+	"go-rest-api/validator"
+)
+
+type IViewUsecase interface {
+	GetViews(ctx context.Context, userId uint) ([]model.ViewResponse, error)
+	GetViewById(ctx context.Context, userId uint, viewId uint) (model.ViewResponse, error)
+	CreateView(ctx context.Context, view model.View) (model.ViewResponse, error)
+	UpdateView(ctx context.Context, view model.View, userId uint, viewId uint) (model.ViewResponse, error)
+	DeleteView(ctx context.Context, userId uint, viewId uint) error
+	// GetViewTasksはビューの検索クエリと並び順でタスクの一覧を返す
+	GetViewTasks(ctx context.Context, userId uint, viewId uint) ([]model.TaskResponse, error)
+}
+
+type viewUsecase struct {
+	vr repository.IViewRepository
+	// ビューのタスクの一覧は、GET /tasksと同じくタスクのユースケースで取得します。
+	tu ITaskUsecase
+	vv validator.IViewValidator
+}
+
+func NewViewUsecase(vr repository.IViewRepository, tu ITaskUsecase, vv validator.IViewValidator) IViewUsecase {
+	return &viewUsecase{vr, tu, vv}
+}
+
+func newViewResponse(view model.View) model.ViewResponse {
+	return model.ViewResponse{
+		ID:        view.ID,
+		Name:      view.Name,
+		Query:     view.Query,
+		Sort:      view.Sort,
+		Scope:     view.Scope,
+		CreatedAt: view.CreatedAt,
+		UpdatedAt: view.UpdatedAt,
+	}
+}
+
+// validateViewはビューをチェックする(検索クエリの誤りはtaskquery.Errorで位置を返します)
+func (vu *viewUsecase) validateView(view *model.View) error {
+	if view.Scope == "" {
+		view.Scope = model.TaskScopeAll
+	}
+	if err := vu.vv.ViewValidate(*view); err != nil {
+		return err
+	}
+	if _, err := taskquery.Parse(view.Query); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vu *viewUsecase) GetViews(ctx context.Context, userId uint) ([]model.ViewResponse, error) {
+	views := []model.View{}
+	if err := vu.vr.GetViews(ctx, &views, userId); err != nil {
+		return nil, err
+	}
+	resViews := []model.ViewResponse{}
+	for _, v := range views {
+		resViews = append(resViews, newViewResponse(v))
+	}
+	return resViews, nil
+}
+
+func (vu *viewUsecase) GetViewById(ctx context.Context, userId uint, viewId uint) (model.ViewResponse, error) {
+	view := model.View{}
+	if err := vu.vr.GetViewById(ctx, &view, userId, viewId); err != nil {
+		return model.ViewResponse{}, err
+	}
+	return newViewResponse(view), nil
+}
+
+func (vu *viewUsecase) CreateView(ctx context.Context, view model.View) (model.ViewResponse, error) {
+	if err := vu.validateView(&view); err != nil {
+		return model.ViewResponse{}, err
+	}
+	if err := vu.vr.CreateView(ctx, &view); err != nil {
+		return model.ViewResponse{}, err
+	}
+	return newViewResponse(view), nil
+}
+
+func (vu *viewUsecase) UpdateView(ctx context.Context, view model.View, userId uint, viewId uint) (model.ViewResponse, error) {
+	if err := vu.validateView(&view); err != nil {
+		return model.ViewResponse{}, err
+	}
+	if err := vu.vr.UpdateView(ctx, &view, userId, viewId); err != nil {
+		return model.ViewResponse{}, err
+	}
+	return newViewResponse(view), nil
+}
+
+func (vu *viewUsecase) DeleteView(ctx context.Context, userId uint, viewId uint) error {
+	if err := vu.vr.DeleteView(ctx, userId, viewId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vu *viewUsecase) GetViewTasks(ctx context.Context, userId uint, viewId uint) ([]model.TaskResponse, error) {
+	view := model.View{}
+	if err := vu.vr.GetViewById(ctx, &view, userId, viewId); err != nil {
+		return nil, err
+	}
+	sort, err := model.ParseTaskSort(view.Sort)
+	if err != nil {
+		return nil, err
+	}
+	// 項目の名前はビューを開いた時点の定義で解決するので、保存した後に項目が削除された場合はここでエラーになります。
+	filter := model.TaskFilter{Scope: view.Scope, Sort: sort, Query: view.Query}
+	return vu.tu.GetAllTasks(ctx, userId, filter)
+}
@@ -0,0 +1,260 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"go-rest-api/model"
+	"go-rest-api/notifier"
+	"go-rest-api/permission"
+	"go-rest-api/repository"
+	"go-rest-api/validator"
+	"time"
+)
+
+type IWebhookUsecase interface {
+	GetWebhooks(ctx context.Context, userId uint) ([]model.WebhookResponse, error)
+	GetWebhookById(ctx context.Context, userId uint, webhookId uint) (model.WebhookResponse, error)
+	// CreateWebhookはWebhookを登録する(署名の鍵はこのレスポンスと作り直した時しか返しません)
+	CreateWebhook(ctx context.Context, userId uint, req model.WebhookRequest) (model.WebhookResponse, error)
+	UpdateWebhook(ctx context.Context, userId uint, webhookId uint, req model.WebhookRequest) (model.WebhookResponse, error)
+	// RotateSecretは署名の鍵を作り直す(まだ送信していない配信も新しい鍵で署名します)
+	RotateSecret(ctx context.Context, userId uint, webhookId uint) (model.WebhookResponse, error)
+	DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error
+	// GetDeliveriesはWebhookの配信のログを新しい順に取得する
+	GetDeliveries(ctx context.Context, userId uint, webhookId uint) ([]model.WebhookDeliveryResponse, error)
+	// Redeliverは配信のログの1件と同じ本文を、新しい配信としてもう一度送信する
+	Redeliver(ctx context.Context, userId uint, webhookId uint, deliveryId uint) (model.WebhookDeliveryResponse, error)
+	// DispatchTaskEventsはアウトボックスのイベントを、通知の対象のWebhookの配信に振り分ける(返り値は処理したイベントの件数)
+	DispatchTaskEvents(ctx context.Context, limit int) (int, error)
+	// SendDueDeliveriesは送信時刻を過ぎた配信を送信する(返り値は処理した配信の件数)
+	SendDueDeliveries(ctx context.Context, limit int) (int, error)
+}
+
+type webhookUsecase struct {
+	wr repository.IWebhookRepository
+	ps permission.IPermissionService
+	ws notifier.IWebhookSender
+	wv validator.IWebhookValidator
+}
+
+// maxWebhookDeliveriesは配信のログで返す最大の件数
+const maxWebhookDeliveries = 100
+
+func NewWebhookUsecase(wr repository.IWebhookRepository, ps permission.IPermissionService, ws notifier.IWebhookSender, wv validator.IWebhookValidator) IWebhookUsecase {
+	return &webhookUsecase{wr, ps, ws, wv}
+}
+
+func newWebhookResponse(webhook model.Webhook) model.WebhookResponse {
+	return model.WebhookResponse{
+		ID:             webhook.ID,
+		URL:            webhook.URL,
+		Events:         webhook.Events,
+		Active:         webhook.Active,
+		OrganizationId: webhook.OrganizationId,
+		CreatedAt:      webhook.CreatedAt,
+		UpdatedAt:      webhook.UpdatedAt,
+	}
+}
+
+func newWebhookDeliveryResponse(delivery model.WebhookDelivery) model.WebhookDeliveryResponse {
+	return model.WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		WebhookId:      delivery.WebhookId,
+		EventId:        delivery.EventId,
+		Event:          delivery.Event,
+		Payload:        json.RawMessage(delivery.Payload),
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastResponse:   delivery.LastResponse,
+		LastError:      delivery.LastError,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
+
+// newWebhookSecretは署名の鍵を作る
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// uniqueEventsはイベントの種類の重複を取り除く
+func uniqueEvents(events []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			unique = append(unique, e)
+		}
+	}
+	return unique
+}
+
+func (wu *webhookUsecase) GetWebhooks(ctx context.Context, userId uint) ([]model.WebhookResponse, error) {
+	webhooks := []model.Webhook{}
+	if err := wu.wr.GetWebhooks(ctx, &webhooks, userId); err != nil {
+		return nil, err
+	}
+	resWebhooks := []model.WebhookResponse{}
+	for _, v := range webhooks {
+		resWebhooks = append(resWebhooks, newWebhookResponse(v))
+	}
+	return resWebhooks, nil
+}
+
+func (wu *webhookUsecase) GetWebhookById(ctx context.Context, userId uint, webhookId uint) (model.WebhookResponse, error) {
+	webhook := model.Webhook{}
+	if err := wu.wr.GetWebhookById(ctx, &webhook, userId, webhookId); err != nil {
+		return model.WebhookResponse{}, err
+	}
+	return newWebhookResponse(webhook), nil
+}
+
+func (wu *webhookUsecase) CreateWebhook(ctx context.Context, userId uint, req model.WebhookRequest) (model.WebhookResponse, error) {
+	if err := wu.wv.WebhookValidate(req); err != nil {
+		return model.WebhookResponse{}, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return model.WebhookResponse{}, err
+	}
+	webhook := model.Webhook{URL: req.URL, Secret: secret, Events: uniqueEvents(req.Events), Active: true, UserId: userId}
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	if err := wu.wr.CreateWebhook(ctx, &webhook); err != nil {
+		return model.WebhookResponse{}, err
+	}
+	res := newWebhookResponse(webhook)
+	res.Secret = secret
+	return res, nil
+}
+
+func (wu *webhookUsecase) UpdateWebhook(ctx context.Context, userId uint, webhookId uint, req model.WebhookRequest) (model.WebhookResponse, error) {
+	if err := wu.wv.WebhookValidate(req); err != nil {
+		return model.WebhookResponse{}, err
+	}
+	webhook := model.Webhook{}
+	if err := wu.wr.GetWebhookById(ctx, &webhook, userId, webhookId); err != nil {
+		return model.WebhookResponse{}, err
+	}
+	webhook.URL = req.URL
+	webhook.Events = uniqueEvents(req.Events)
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	if err := wu.wr.UpdateWebhook(ctx, &webhook, userId, webhookId); err != nil {
+		return model.WebhookResponse{}, err
+	}
+	return newWebhookResponse(webhook), nil
+}
+
+func (wu *webhookUsecase) RotateSecret(ctx context.Context, userId uint, webhookId uint) (model.WebhookResponse, error) {
+	webhook := model.Webhook{}
+	if err := wu.wr.GetWebhookById(ctx, &webhook, userId, webhookId); err != nil {
+		return model.WebhookResponse{}, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return model.WebhookResponse{}, err
+	}
+	webhook.Secret = secret
+	if err := wu.wr.UpdateWebhook(ctx, &webhook, userId, webhookId); err != nil {
+		return model.WebhookResponse{}, err
+	}
+	res := newWebhookResponse(webhook)
+	res.Secret = secret
+	return res, nil
+}
+
+func (wu *webhookUsecase) DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error {
+	return wu.wr.DeleteWebhook(ctx, userId, webhookId)
+}
+
+func (wu *webhookUsecase) GetDeliveries(ctx context.Context, userId uint, webhookId uint) ([]model.WebhookDeliveryResponse, error) {
+	// 配信のログのテーブルは組織で絞り込まないので、先にWebhookが自分のものか(今の組織のものか)を確認します。
+	webhook := model.Webhook{}
+	if err := wu.wr.GetWebhookById(ctx, &webhook, userId, webhookId); err != nil {
+		return nil, err
+	}
+	deliveries := []model.WebhookDelivery{}
+	if err := wu.wr.GetDeliveries(ctx, &deliveries, webhookId, maxWebhookDeliveries); err != nil {
+		return nil, err
+	}
+	resDeliveries := []model.WebhookDeliveryResponse{}
+	for _, v := range deliveries {
+		resDeliveries = append(resDeliveries, newWebhookDeliveryResponse(v))
+	}
+	return resDeliveries, nil
+}
+
+func (wu *webhookUsecase) Redeliver(ctx context.Context, userId uint, webhookId uint, deliveryId uint) (model.WebhookDeliveryResponse, error) {
+	webhook := model.Webhook{}
+	if err := wu.wr.GetWebhookById(ctx, &webhook, userId, webhookId); err != nil {
+		return model.WebhookDeliveryResponse{}, err
+	}
+	original := model.WebhookDelivery{}
+	if err := wu.wr.GetDeliveryById(ctx, &original, webhookId, deliveryId); err != nil {
+		return model.WebhookDeliveryResponse{}, err
+	}
+	// 元の配信のログは残したまま、同じイベントの配信を作ってワーカーに送信させます。
+	now := time.Now()
+	delivery := model.WebhookDelivery{
+		WebhookId:     webhookId,
+		EventId:       original.EventId,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        model.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+	}
+	if err := wu.wr.CreateDelivery(ctx, &delivery); err != nil {
+		return model.WebhookDeliveryResponse{}, err
+	}
+	return newWebhookDeliveryResponse(delivery), nil
+}
+
+func (wu *webhookUsecase) DispatchTaskEvents(ctx context.Context, limit int) (int, error) {
+	return wu.wr.DispatchTaskEvents(ctx, time.Now(), limit, func(event model.TaskOutboxEvent, webhooks []model.Webhook) ([]model.Webhook, error) {
+		targets := []model.Webhook{}
+		for _, w := range webhooks {
+			// Webhookを登録したユーザーが見られるタスクのイベントだけを送ります。
+			ok, err := canSeeTaskChange(ctx, wu.ps, w.UserId, model.TaskChangeEvent{
+				Event:      event.Event,
+				TaskId:     event.TaskId,
+				UserId:     event.UserId,
+				AssigneeId: event.AssigneeId,
+				ProjectId:  event.ProjectId,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				targets = append(targets, w)
+			}
+		}
+		return targets, nil
+	})
+}
+
+func (wu *webhookUsecase) SendDueDeliveries(ctx context.Context, limit int) (int, error) {
+	deliveries := []model.WebhookDelivery{}
+	if err := wu.wr.ClaimDueDeliveries(ctx, time.Now(), limit, &deliveries); err != nil {
+		return 0, err
+	}
+	// 送信はトランザクションの外なので、送信先の応答が遅くても配信の行をロックしたままにはなりません。
+	for _, d := range deliveries {
+		result := wu.ws.Send(ctx, d.Webhook, d)
+		if err := wu.wr.RecordDeliveryResult(ctx, d, time.Now(), result); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
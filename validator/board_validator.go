@@ -0,0 +1,44 @@
+package validator
+
+import (
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IBoardValidator interface {
+	BoardValidate(board model.Board) error
+	BoardColumnValidate(column model.BoardColumn) error
+}
+
+type boardValidator struct{}
+
+func NewBoardValidator() IBoardValidator {
+	return &boardValidator{}
+}
+
+func (bv *boardValidator) BoardValidate(board model.Board) error {
+	// Nameに値が存在するかと、最大100文字になっているかチェック
+	return validation.ValidateStruct(&board,
+		validation.Field(
+			&board.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 100).Error("limited max 100 char"),
+		),
+	)
+}
+
+func (bv *boardValidator) BoardColumnValidate(column model.BoardColumn) error {
+	// 列の名前は最大50文字、WIPの上限は指定する場合は1以上
+	return validation.ValidateStruct(&column,
+		validation.Field(
+			&column.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 50).Error("limited max 50 char"),
+		),
+		validation.Field(
+			&column.WipLimit,
+			validation.Min(1).Error("must be no less than 1"),
+		),
+	)
+}
@@ -0,0 +1,28 @@
+package validator
+
+import (
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ICommentValidator interface {
+	CommentValidate(comment model.Comment) error
+}
+
+type commentValidator struct{}
+
+func NewCommentValidator() ICommentValidator {
+	return &commentValidator{}
+}
+
+func (cv *commentValidator) CommentValidate(comment model.Comment) error {
+	// Bodyに値が存在するかと、最大2000文字になっているかチェック
+	return validation.ValidateStruct(&comment,
+		validation.Field(
+			&comment.Body,
+			validation.Required.Error("body is required"),
+			validation.RuneLength(1, 2000).Error("limited max 2000 char"),
+		),
+	)
+}
@@ -0,0 +1,57 @@
+package validator
+
+import (
+	"errors"
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ICustomFieldValidator interface {
+	CustomFieldValidate(field model.CustomField) error
+}
+
+type customFieldValidator struct{}
+
+func NewCustomFieldValidator() ICustomFieldValidator {
+	return &customFieldValidator{}
+}
+
+func (cfv *customFieldValidator) CustomFieldValidate(field model.CustomField) error {
+	// 名前は最大50文字、種類は決められた5種類のいずれか
+	// selectとmulti_selectには1つ以上の選択肢が必要で、選択肢は重複しない最大50文字の文字列
+	isSelect := field.Type == model.CustomFieldSelect || field.Type == model.CustomFieldMultiSelect
+	return validation.ValidateStruct(&field,
+		validation.Field(
+			&field.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 50).Error("limited max 50 char"),
+		),
+		validation.Field(
+			&field.Type,
+			validation.Required.Error("type is required"),
+			validation.In(model.CustomFieldText, model.CustomFieldNumber, model.CustomFieldDate,
+				model.CustomFieldSelect, model.CustomFieldMultiSelect).Error("must be text, number, date, select or multi_select"),
+		),
+		validation.Field(
+			&field.Options,
+			validation.When(isSelect, validation.Required.Error("options are required for select fields")),
+			validation.When(!isSelect, validation.Empty.Error("options are only for select fields")),
+			validation.Length(0, 100).Error("limited max 100 options"),
+			validation.Each(
+				validation.Required.Error("option must not be empty"),
+				validation.RuneLength(1, 50).Error("option is limited max 50 char"),
+			),
+			validation.By(func(value interface{}) error {
+				seen := map[string]bool{}
+				for _, o := range field.Options {
+					if seen[o] {
+						return errors.New("options must be unique")
+					}
+					seen[o] = true
+				}
+				return nil
+			}),
+		),
+	)
+}
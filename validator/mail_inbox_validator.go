@@ -0,0 +1,43 @@
+package validator
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type IMailInboxValidator interface {
+	MailInboxValidate(req model.MailInboxRequest) error
+}
+
+type mailInboxValidator struct{}
+
+func NewMailInboxValidator() IMailInboxValidator {
+	return &mailInboxValidator{}
+}
+
+func (miv *mailInboxValidator) MailInboxValidate(req model.MailInboxRequest) error {
+	// 送信者は最大100件で、メールアドレスか"@example.com"の形のドメインかをチェック
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.AllowedSenders,
+			validation.Length(0, model.MaxAllowedSenders).Error("limited max 100 senders"),
+			validation.Each(validation.By(func(value interface{}) error {
+				sender, _ := value.(string)
+				if domain, ok := strings.CutPrefix(sender, "@"); ok {
+					if err := is.Domain.Validate(domain); err != nil || domain == "" {
+						return errors.New("is not valid domain")
+					}
+					return nil
+				}
+				if err := is.EmailFormat.Validate(sender); err != nil || sender == "" {
+					return errors.New("is not valid email format")
+				}
+				return nil
+			})),
+		),
+	)
+}
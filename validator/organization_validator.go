@@ -0,0 +1,58 @@
+package validator
+
+import (
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type IOrganizationValidator interface {
+	OrganizationValidate(org model.Organization) error
+	MemberValidate(req model.MemberRequest) error
+	InvitationValidate(req model.InvitationRequest) error
+}
+
+type organizationValidator struct{}
+
+func NewOrganizationValidator() IOrganizationValidator {
+	return &organizationValidator{}
+}
+
+func (ov *organizationValidator) OrganizationValidate(org model.Organization) error {
+	// Nameに値が存在するかと、最大100文字になっているかチェック
+	return validation.ValidateStruct(&org,
+		validation.Field(
+			&org.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 100).Error("limited max 100 char"),
+		),
+	)
+}
+
+func (ov *organizationValidator) MemberValidate(req model.MemberRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.Role,
+			validation.Required.Error("role is required"),
+			validation.In(model.OrgRoleOwner, model.OrgRoleAdmin, model.OrgRoleMember).Error("must be owner, admin or member"),
+		),
+	)
+}
+
+func (ov *organizationValidator) InvitationValidate(req model.InvitationRequest) error {
+	// 招待する相手のメールアドレスと、権限(adminかmember)をチェック
+	// ownerは招待では付与できないので、メンバーになった後に権限を変更します。
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.Email,
+			validation.Required.Error("email is required"),
+			is.Email.Error("is not valid email format"),
+		),
+		validation.Field(
+			&req.Role,
+			validation.Required.Error("role is required"),
+			validation.In(model.OrgRoleAdmin, model.OrgRoleMember).Error("must be admin or member"),
+		),
+	)
+}
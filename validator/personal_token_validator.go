@@ -0,0 +1,28 @@
+package validator
+
+import (
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IPersonalTokenValidator interface {
+	PersonalTokenValidate(token model.PersonalToken) error
+}
+
+type personalTokenValidator struct{}
+
+func NewPersonalTokenValidator() IPersonalTokenValidator {
+	return &personalTokenValidator{}
+}
+
+func (ptv *personalTokenValidator) PersonalTokenValidate(token model.PersonalToken) error {
+	// 名前(どのアプリで使うトークンか)は最大50文字
+	return validation.ValidateStruct(&token,
+		validation.Field(
+			&token.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 50).Error("limited max 50 char"),
+		),
+	)
+}
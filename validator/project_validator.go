@@ -0,0 +1,28 @@
+package validator
+
+import (
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IProjectValidator interface {
+	ProjectValidate(project model.Project) error
+}
+
+type projectValidator struct{}
+
+func NewProjectValidator() IProjectValidator {
+	return &projectValidator{}
+}
+
+func (pv *projectValidator) ProjectValidate(project model.Project) error {
+	// Nameに値が存在するかと、最大100文字になっているかチェック
+	return validation.ValidateStruct(&project,
+		validation.Field(
+			&project.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 100).Error("limited max 100 char"),
+		),
+	)
+}
@@ -0,0 +1,69 @@
+package validator
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"go-rest-api/netguard"
+	"net/url"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type IReminderValidator interface {
+	ReminderValidate(req model.ReminderRequest) error
+}
+
+type reminderValidator struct{}
+
+func NewReminderValidator() IReminderValidator {
+	return &reminderValidator{}
+}
+
+func (rv *reminderValidator) ReminderValidate(req model.ReminderRequest) error {
+	// remind_atとoffset_minutesはどちらか一方だけを指定する
+	// channelはlog、email、webhookのいずれかで、送信先(target)はチャンネルに合わせた形式かチェックします。
+	// webhookのURLは、サーバーの内部のネットワーク(localhostやプライベートのIPアドレス)を指していないかもチェックします。
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.RemindAt,
+			validation.By(func(value interface{}) error {
+				if (req.RemindAt == nil) == (req.OffsetMinutes == nil) {
+					return errors.New("either remind_at or offset_minutes is required")
+				}
+				return nil
+			}),
+		),
+		validation.Field(
+			&req.OffsetMinutes,
+			validation.Min(0).Error("must be no less than 0"),
+		),
+		validation.Field(
+			&req.Channel,
+			validation.Required.Error("channel is required"),
+			validation.In("log", "email", "webhook").Error("must be log, email or webhook"),
+		),
+		validation.Field(
+			&req.Target,
+			validation.When(req.Channel == "email", is.Email.Error("is not valid email format")),
+			validation.When(req.Channel == "webhook", is.URL.Error("is not valid url"), validation.By(publicURL)),
+		),
+	)
+}
+
+// publicURLはURLのホストが、サーバーの内部のネットワーク(localhostやプライベートのIPアドレス)を指していないかチェックする
+// 名前で指定されたホストは送信する時に、解決したアドレスを確認します。
+func publicURL(value interface{}) error {
+	s, _ := value.(string)
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return errors.New("is not valid url")
+	}
+	if err := netguard.CheckHost(u.Hostname()); err != nil {
+		return errors.New("must not point to a private or local address")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package validator
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IShareLinkValidator interface {
+	ShareLinkValidate(req model.ShareLinkRequest) error
+}
+
+type shareLinkValidator struct{}
+
+func NewShareLinkValidator() IShareLinkValidator {
+	return &shareLinkValidator{}
+}
+
+// maxShareLinkLifetimeは公開リンクの有効期限の上限
+const maxShareLinkLifetime = 90 * 24 * time.Hour
+
+func (lv *shareLinkValidator) ShareLinkValidate(req model.ShareLinkRequest) error {
+	// 有効期限は未来の日時で、90日以内になっているかチェック
+	// パスワードはbcryptで扱える72バイトまで
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.ExpiresAt,
+			validation.By(func(value interface{}) error {
+				if req.ExpiresAt == nil {
+					return nil
+				}
+				if !req.ExpiresAt.After(time.Now()) {
+					return errors.New("must be in the future")
+				}
+				if req.ExpiresAt.After(time.Now().Add(maxShareLinkLifetime)) {
+					return errors.New("must be within 90 days")
+				}
+				return nil
+			}),
+		),
+		validation.Field(
+			&req.Password,
+			validation.Length(0, 72).Error("limited max 72 bytes"),
+		),
+	)
+}
@@ -0,0 +1,34 @@
+package validator
+
+import (
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type IShareValidator interface {
+	ShareValidate(req model.ShareRequest) error
+}
+
+type shareValidator struct{}
+
+func NewShareValidator() IShareValidator {
+	return &shareValidator{}
+}
+
+func (sv *shareValidator) ShareValidate(req model.ShareRequest) error {
+	// 共有する相手のメールアドレスと、権限(viewerかeditor)をチェック
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.Email,
+			validation.Required.Error("email is required"),
+			is.Email.Error("is not valid email format"),
+		),
+		validation.Field(
+			&req.Role,
+			validation.Required.Error("role is required"),
+			validation.In(model.RoleViewer, model.RoleEditor).Error("must be viewer or editor"),
+		),
+	)
+}
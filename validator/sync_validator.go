@@ -0,0 +1,73 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ISyncValidator interface {
+	SyncValidate(req model.SyncRequest) error
+}
+
+type syncValidator struct{}
+
+func NewSyncValidator() ISyncValidator {
+	return &syncValidator{}
+}
+
+func (sv *syncValidator) SyncValidate(req model.SyncRequest) error {
+	// クライアントのIDは最大100文字でサーバーのノードのIDは使えない、変更は1回に最大500個
+	// 項目の値はタスクの作成・更新のバリデーションでチェックするので、ここでは変更の形だけをチェックします。
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.ClientId,
+			validation.Required.Error("client_id is required"),
+			validation.RuneLength(1, 100).Error("limited max 100 char"),
+			validation.NotIn(model.SyncServerNode).Error("is reserved"),
+		),
+		validation.Field(
+			&req.Changes,
+			validation.Length(0, model.MaxSyncChanges).Error(fmt.Sprintf("limited max %d changes", model.MaxSyncChanges)),
+			validation.Each(validation.By(func(value interface{}) error {
+				change := value.(model.SyncChange)
+				if change.ModifiedAt.IsZero() {
+					return errors.New("modified_at is required")
+				}
+				switch change.Op {
+				case model.SyncOpCreate:
+					if change.ClientRef == "" {
+						return errors.New("client_ref is required")
+					}
+				case model.SyncOpUpdate, model.SyncOpDelete:
+					if change.TaskId == 0 {
+						return errors.New("task_id is required")
+					}
+				default:
+					return errors.New("op must be create, update or delete")
+				}
+				if change.Op != model.SyncOpDelete && len(change.Fields) == 0 {
+					return errors.New("fields is required")
+				}
+				for name := range change.Fields {
+					if !isSyncField(name) {
+						return fmt.Errorf("%s is not a syncable field", name)
+					}
+				}
+				return nil
+			})),
+		),
+	)
+}
+
+// isSyncFieldは同期で変更できる項目(model.SyncFields)かを確認する
+func isSyncField(name string) bool {
+	for _, f := range model.SyncFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,25 @@
 package validator
 
 import (
+	"errors"
+	"fmt"
 	"go-rest-api/model"
+	"go-rest-api/rrule"
+	"strconv"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
 
 type ITaskValidator interface {
 	// ITaskValidatorという名前のインターフェースを定義し、TaskValidateメソッドを定義。
-	TaskValidate(task model.Task) error
+	// fieldsにはタスクが属するプロジェクトの独自の項目の定義を渡し、CustomFieldsの値を定義に合わせてチェックします。
+	TaskValidate(task model.Task, fields []model.CustomField) error
+	// CustomFieldValueValidateは独自の項目の1つの値が、項目の定義に合っているかチェックする
+	CustomFieldValueValidate(field model.CustomField, value interface{}) error
+	// TaskFilterValidateはタスクの一覧の独自の項目での絞り込みと並び替えが、項目の種類に合っているかチェックする
+	// 絞り込みと並び替えの項目の種類(Type・FieldType)は、項目の定義から設定しておきます。
+	TaskFilterValidate(filter model.TaskFilter) error
 }
 
 type taskValidator struct{}
@@ -22,7 +33,7 @@ func NewTaskValidator() ITaskValidator {
 
 // taskValidatorをポインターレシーバー(*)として受け取る形でTaskValidateメソッドを定義しまして、
 // 引数でバリデーションで評価したいtaskのオブジェクトを受け取れるようにしています。
-func (tv *taskValidator) TaskValidate(task model.Task) error {
+func (tv *taskValidator) TaskValidate(task model.Task, fields []model.CustomField) error {
 	// そして、validationパッケージで定義されてるValidateStruct関数を実行しまして、
 	// 第1引数にタスクオブジェクトのアドレス(&task)を渡します。
 	// そして第2引数でtaskのTitleに対するvalidationを実装しています。
@@ -33,7 +44,162 @@ func (tv *taskValidator) TaskValidate(task model.Task) error {
 		validation.Field(
 			&task.Title,
 			validation.Required.Error("title is required"),
-			validation.RuneLength(1, 10).Error("limited max 10 char"),
+			validation.RuneLength(1, model.MaxTaskTitleRunes).Error("limited max 10 char"),
+		),
+		// 説明は最大10000文字
+		validation.Field(
+			&task.Description,
+			validation.RuneLength(0, model.MaxTaskDescriptionRunes).Error("limited max 10000 char"),
+		),
+		// TaskTagsはjsonbに変換するValueを持っているので、[]stringに戻してからチェックします。
+		validation.Field(
+			&task.Tags,
+			validation.By(func(value interface{}) error {
+				return validation.Validate([]string(task.Tags), tagRules()...)
+			}),
+		),
+		// 繰り返しタスクの場合は、RRuleがRFC 5545の形式として正しいかどうかと、
+		// 展開の起点になる期限日(due_date)が指定されているかをチェックします。
+		validation.Field(
+			&task.RRule,
+			validation.By(func(value interface{}) error {
+				if task.RRule == "" {
+					return nil
+				}
+				if _, err := rrule.Parse(task.RRule); err != nil {
+					return err
+				}
+				return nil
+			}),
+		),
+		validation.Field(
+			&task.DueDate,
+			validation.When(task.RRule != "", validation.Required.Error("due_date is required for recurring task")),
+		),
+		// Timezoneは"Asia/Tokyo"のようなIANAタイムゾーン名であるかチェック
+		validation.Field(
+			&task.Timezone,
+			validation.By(func(value interface{}) error {
+				if task.Timezone == "" {
+					return nil
+				}
+				if _, err := time.LoadLocation(task.Timezone); err != nil {
+					return errors.New("is not valid timezone")
+				}
+				return nil
+			}),
+		),
+		// CustomFieldsはプロジェクトで定義された項目だけで、必須の項目に値があり、値が項目の種類に合っているかチェック
+		validation.Field(
+			&task.CustomFields,
+			validation.By(func(value interface{}) error {
+				defined := map[string]model.CustomField{}
+				for _, f := range fields {
+					defined[strconv.FormatUint(uint64(f.ID), 10)] = f
+				}
+				for key, v := range task.CustomFields {
+					field, ok := defined[key]
+					if !ok {
+						return fmt.Errorf("field %s is not defined in the project", key)
+					}
+					if err := tv.CustomFieldValueValidate(field, v); err != nil {
+						return fmt.Errorf("%s: %w", field.Name, err)
+					}
+				}
+				for key, field := range defined {
+					if _, ok := task.CustomFields[key]; field.Required && !ok {
+						return fmt.Errorf("%s is required", field.Name)
+					}
+				}
+				return nil
+			}),
 		),
 	)
 }
+
+func (tv *taskValidator) CustomFieldValueValidate(field model.CustomField, value interface{}) error {
+	inOptions := func(s string) bool {
+		for _, o := range field.Options {
+			if o == s {
+				return true
+			}
+		}
+		return false
+	}
+	switch field.Type {
+	case model.CustomFieldText:
+		s, ok := value.(string)
+		if !ok {
+			return errors.New("must be a string")
+		}
+		if field.Required && s == "" {
+			return errors.New("must not be empty")
+		}
+		if len([]rune(s)) > 1000 {
+			return errors.New("limited max 1000 char")
+		}
+	case model.CustomFieldNumber:
+		if _, ok := value.(float64); !ok {
+			return errors.New("must be a number")
+		}
+	case model.CustomFieldDate:
+		s, ok := value.(string)
+		if !ok {
+			return errors.New("must be a date string")
+		}
+		if _, err := time.Parse(model.CustomFieldDateLayout, s); err != nil {
+			return errors.New("must be YYYY-MM-DD")
+		}
+	case model.CustomFieldSelect:
+		s, ok := value.(string)
+		if !ok || !inOptions(s) {
+			return errors.New("must be one of the options")
+		}
+	case model.CustomFieldMultiSelect:
+		items, ok := value.([]interface{})
+		if !ok {
+			return errors.New("must be an array of options")
+		}
+		if field.Required && len(items) == 0 {
+			return errors.New("must not be empty")
+		}
+		seen := map[string]bool{}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok || !inOptions(s) {
+				return errors.New("must be an array of options")
+			}
+			if seen[s] {
+				return errors.New("must not contain duplicate options")
+			}
+			seen[s] = true
+		}
+	default:
+		return fmt.Errorf("unknown field type %s", field.Type)
+	}
+	return nil
+}
+
+func (tv *taskValidator) TaskFilterValidate(filter model.TaskFilter) error {
+	for _, f := range filter.CustomFields {
+		// 範囲の指定(gte・lte)ができるのはnumberとdateの項目だけ
+		if f.Op != model.CustomFieldOpEq && f.Type != model.CustomFieldNumber && f.Type != model.CustomFieldDate {
+			return fmt.Errorf("field.%d: %s is only available for number and date fields", f.FieldId, f.Op)
+		}
+		switch f.Type {
+		case model.CustomFieldNumber:
+			if _, err := strconv.ParseFloat(f.Value, 64); err != nil {
+				return fmt.Errorf("field.%d: must be a number", f.FieldId)
+			}
+		case model.CustomFieldDate:
+			if _, err := time.Parse(model.CustomFieldDateLayout, f.Value); err != nil {
+				return fmt.Errorf("field.%d: must be YYYY-MM-DD", f.FieldId)
+			}
+		}
+	}
+	// multi_selectの値は複数あるので、並び替えには使えません。
+	if filter.Sort.Key == model.TaskSortCustomField && filter.Sort.FieldType == model.CustomFieldMultiSelect {
+		return fmt.Errorf("field.%d: multi_select field cannot be used for sort", filter.Sort.FieldId)
+	}
+	return nil
+}
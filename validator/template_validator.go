@@ -0,0 +1,53 @@
+package validator
+
+import (
+	"errors"
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ITemplateValidator interface {
+	// TemplateValidateはテンプレートの名前とタスクの一覧をチェックする
+	// プレースホルダーを置き換えた後のタイトルと項目の値は、インスタンス化する時にタスクのバリデーションでチェックします。
+	TemplateValidate(template model.Template) error
+}
+
+type templateValidator struct{}
+
+func NewTemplateValidator() ITemplateValidator {
+	return &templateValidator{}
+}
+
+func (tmv *templateValidator) TemplateValidate(template model.Template) error {
+	// 名前は最大50文字、タスクは1つのテンプレートに最大100個
+	return validation.ValidateStruct(&template,
+		validation.Field(
+			&template.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 50).Error("limited max 50 char"),
+		),
+		validation.Field(
+			&template.Items,
+			validation.Required.Error("items is required"),
+			validation.Length(1, 100).Error("limited max 100 items"),
+			validation.Each(validation.By(func(value interface{}) error {
+				item := value.(model.TemplateItem)
+				// タイトルはタスクと同じ最大10文字です(プレースホルダーを含む場合は置き換える前の長さでもチェックします)。
+				if item.Title == "" {
+					return errors.New("title is required")
+				}
+				if err := validation.Validate(item.Title, validation.RuneLength(1, model.MaxTaskTitleRunes).Error("title is limited max 10 char")); err != nil {
+					return err
+				}
+				if item.DueInDays != nil {
+					if err := validation.Validate(*item.DueInDays, validation.Min(0).Error("due_in_days must be 0 or more"),
+						validation.Max(3650).Error("due_in_days must be 3650 or less")); err != nil {
+						return err
+					}
+				}
+				return nil
+			})),
+		),
+	)
+}
@@ -0,0 +1,144 @@
+package validator
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ITimeEntryValidator interface {
+	// TimeEntryValidateは手動で追加・編集する作業時間をチェックする(runningがtrueの場合は終了時刻を省略できる)
+	TimeEntryValidate(req model.TimeEntryRequest, running bool) error
+	TimerValidate(req model.TimerRequest) error
+	TimeReportValidate(req model.TimeReportRequest) error
+}
+
+type timeEntryValidator struct{}
+
+func NewTimeEntryValidator() ITimeEntryValidator {
+	return &timeEntryValidator{}
+}
+
+// maxTagsは1つの作業時間(またはタスク)に付けられるタグの数の上限
+const maxTags = 20
+
+// maxTimeReportRangeはレポートで1度に集計できる期間の上限
+const maxTimeReportRange = 366 * 24 * time.Hour
+
+// tagRulesは作業時間とタスクのタグの共通のチェック
+func tagRules() []validation.Rule {
+	return []validation.Rule{
+		validation.Length(0, maxTags).Error("limited max 20 tags"),
+		validation.Each(
+			validation.Required.Error("tag must not be empty"),
+			validation.RuneLength(1, 50).Error("tag is limited max 50 char"),
+		),
+	}
+}
+
+// timeEntryTagRulesは説明とタグの共通のチェック
+func timeEntryTagRules(tags *[]string) *validation.FieldRules {
+	return validation.Field(tags, tagRules()...)
+}
+
+func (tev *timeEntryValidator) TimeEntryValidate(req model.TimeEntryRequest, running bool) error {
+	// 開始時刻は必須で、終了時刻は開始時刻より後で未来ではないかチェック
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.StartedAt,
+			validation.Required.Error("started_at is required"),
+			validation.By(func(value interface{}) error {
+				if req.StartedAt != nil && req.StartedAt.After(time.Now()) {
+					return errors.New("must not be in the future")
+				}
+				return nil
+			}),
+		),
+		validation.Field(
+			&req.EndedAt,
+			validation.When(!running, validation.Required.Error("ended_at is required")),
+			validation.By(func(value interface{}) error {
+				if req.EndedAt == nil || req.StartedAt == nil {
+					return nil
+				}
+				if !req.EndedAt.After(*req.StartedAt) {
+					return errors.New("must be after started_at")
+				}
+				if req.EndedAt.After(time.Now()) {
+					return errors.New("must not be in the future")
+				}
+				return nil
+			}),
+		),
+		validation.Field(
+			&req.Description,
+			validation.RuneLength(0, 1000).Error("limited max 1000 char"),
+		),
+		timeEntryTagRules(&req.Tags),
+	)
+}
+
+func (tev *timeEntryValidator) TimerValidate(req model.TimerRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.Description,
+			validation.RuneLength(0, 1000).Error("limited max 1000 char"),
+		),
+		timeEntryTagRules(&req.Tags),
+	)
+}
+
+func (tev *timeEntryValidator) TimeReportValidate(req model.TimeReportRequest) error {
+	// fromとtoはYYYY-MM-DDの日付で、toがfrom以降かつ1年以内かチェック
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.From,
+			validation.Required.Error("from is required"),
+			validation.Date("2006-01-02").Error("must be YYYY-MM-DD"),
+		),
+		validation.Field(
+			&req.To,
+			validation.Required.Error("to is required"),
+			validation.Date("2006-01-02").Error("must be YYYY-MM-DD"),
+			validation.By(func(value interface{}) error {
+				from, err := time.Parse("2006-01-02", req.From)
+				if err != nil {
+					return nil
+				}
+				to, err := time.Parse("2006-01-02", req.To)
+				if err != nil {
+					return nil
+				}
+				if to.Before(from) {
+					return errors.New("must not be before from")
+				}
+				if to.Sub(from) >= maxTimeReportRange {
+					return errors.New("range is limited to 366 days")
+				}
+				return nil
+			}),
+		),
+		validation.Field(
+			&req.GroupBy,
+			validation.Required.Error("group_by is required"),
+			validation.In(model.TimeGroupByDay, model.TimeGroupByTask, model.TimeGroupByProject, model.TimeGroupByTag).
+				Error("must be day, task, project or tag"),
+		),
+		validation.Field(
+			&req.Timezone,
+			validation.By(func(value interface{}) error {
+				// LocalはサーバーのタイムゾーンでPostgreSQLには渡せないので受け付けません。
+				if _, err := time.LoadLocation(req.Timezone); err != nil || req.Timezone == "Local" {
+					return errors.New("is not valid timezone")
+				}
+				return nil
+			}),
+		),
+		validation.Field(
+			&req.Format,
+			validation.In("json", "csv").Error("must be json or csv"),
+		),
+	)
+}
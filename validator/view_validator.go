@@ -0,0 +1,45 @@
+package validator
+
+import (
+	"go-rest-api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IViewValidator interface {
+	// ViewValidateはビューの名前・並び順・一覧の種類をチェックする
+	// 検索クエリはエラーの位置を返せるように、ユースケースでパースしてチェックします。
+	ViewValidate(view model.View) error
+}
+
+type viewValidator struct{}
+
+func NewViewValidator() IViewValidator {
+	return &viewValidator{}
+}
+
+func (vv *viewValidator) ViewValidate(view model.View) error {
+	// 名前は最大50文字、検索クエリは最大500文字
+	return validation.ValidateStruct(&view,
+		validation.Field(
+			&view.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 50).Error("limited max 50 char"),
+		),
+		validation.Field(
+			&view.Query,
+			validation.RuneLength(0, 500).Error("limited max 500 char"),
+		),
+		validation.Field(
+			&view.Sort,
+			validation.By(func(value interface{}) error {
+				_, err := model.ParseTaskSort(view.Sort)
+				return err
+			}),
+		),
+		validation.Field(
+			&view.Scope,
+			validation.In(model.TaskScopeOwned, model.TaskScopeShared, model.TaskScopeAll).Error("must be owned, shared or all"),
+		),
+	)
+}
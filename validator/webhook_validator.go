@@ -0,0 +1,49 @@
+package validator
+
+import (
+	"errors"
+	"go-rest-api/model"
+	"net/url"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type IWebhookValidator interface {
+	WebhookValidate(req model.WebhookRequest) error
+}
+
+type webhookValidator struct{}
+
+func NewWebhookValidator() IWebhookValidator {
+	return &webhookValidator{}
+}
+
+func (wv *webhookValidator) WebhookValidate(req model.WebhookRequest) error {
+	// URLはhttpかhttpsのURLで最大2000文字(サーバーの内部のネットワークを指すURLは不可)、イベントの種類は1つ以上でTaskEventsのいずれかをチェック
+	events := make([]interface{}, len(model.TaskEvents))
+	for i, e := range model.TaskEvents {
+		events[i] = e
+	}
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.URL,
+			validation.Required.Error("url is required"),
+			validation.RuneLength(1, 2000).Error("limited max 2000 char"),
+			is.URL.Error("is not valid url"),
+			validation.By(func(value interface{}) error {
+				u, err := url.Parse(value.(string))
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+					return errors.New("must be http or https url")
+				}
+				return nil
+			}),
+			validation.By(publicURL),
+		),
+		validation.Field(
+			&req.Events,
+			validation.Required.Error("events is required"),
+			validation.Each(validation.In(events...).Error("is not valid event")),
+		),
+	)
+}